@@ -0,0 +1,181 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/pkgversion"
+	"patchmon-agent/internal/utils"
+)
+
+// lastActionsFile keeps the last run of each server command type
+const lastActionsFile = "last_actions.json"
+
+// Remote action outcomes
+const (
+	actionRunning     = "running"
+	actionSuccess     = "success"
+	actionFailed      = "failed"
+	actionCancelled   = "cancelled"
+	actionRefused     = "refused"
+	actionSkipped     = "skipped"
+	actionInterrupted = "interrupted"
+	actionDeferred    = "deferred"
+)
+
+// untrackedActions are high-frequency session traffic rather than commands
+var untrackedActions = map[string]bool{
+	"ssh_proxy_input":      true,
+	"ssh_proxy_resize":     true,
+	"ssh_proxy_disconnect": true,
+	"rdp_proxy_input":      true,
+	"rdp_proxy_disconnect": true,
+}
+
+var lastActionsMu sync.Mutex
+
+// recordActionStart marks a server command as running
+func recordActionStart(m wsMsg) {
+	recordActionOutcome(m, actionRunning, "")
+}
+
+// finishAction records how a server command ended and, if the server sent a
+// command_id, reports the outcome to it
+func finishAction(m wsMsg, err error) {
+	outcome, detail := actionSuccess, ""
+	switch {
+	case err == nil:
+	case errors.Is(err, context.Canceled):
+		outcome, detail = actionCancelled, err.Error()
+	default:
+		outcome, detail = actionFailed, err.Error()
+	}
+	recordActionOutcome(m, outcome, detail)
+	sendCommandResult(m, outcome, detail)
+	m.stepDone(err)
+}
+
+func recordActionOutcome(m wsMsg, outcome, detail string) {
+	jobs.update(m.jobID, outcome, detail)
+	if m.kind == "" || untrackedActions[m.kind] {
+		return
+	}
+	lastActionsMu.Lock()
+	defer lastActionsMu.Unlock()
+	actions := loadLastActions()
+	actions[m.kind] = &models.RemoteAction{
+		At:           time.Now().UTC(),
+		ParamsDigest: m.paramsDigest(),
+		Outcome:      outcome,
+		Error:        detail,
+		AgentVersion: pkgversion.Version,
+	}
+	saveLastActions(actions)
+}
+
+// lastActions returns the recorded actions for the ping payload
+func lastActions() map[string]*models.RemoteAction {
+	lastActionsMu.Lock()
+	defer lastActionsMu.Unlock()
+	actions := loadLastActions()
+	if len(actions) == 0 {
+		return nil
+	}
+	return actions
+}
+
+// settleInterruptedActions resolves actions still "running" or "deferred"
+// from before a restart. An update that was running and left a different
+// agent version behind succeeded; anything else was interrupted.
+func settleInterruptedActions() {
+	lastActionsMu.Lock()
+	defer lastActionsMu.Unlock()
+	actions := loadLastActions()
+	changed := false
+	for kind, a := range actions {
+		if a.Outcome != actionRunning && a.Outcome != actionDeferred {
+			continue
+		}
+		if a.Outcome == actionRunning && (kind == "update_agent" || kind == "update_notification") && a.AgentVersion != pkgversion.Version {
+			a.Outcome = actionSuccess
+		} else {
+			a.Outcome = actionInterrupted
+		}
+		changed = true
+	}
+	if changed {
+		saveLastActions(actions)
+	}
+}
+
+func loadLastActions() map[string]*models.RemoteAction {
+	actions := map[string]*models.RemoteAction{}
+	data, err := os.ReadFile(cfgManager.StatePath(lastActionsFile))
+	if err != nil {
+		return actions
+	}
+	if err := json.Unmarshal(data, &actions); err != nil || actions == nil {
+		return map[string]*models.RemoteAction{}
+	}
+	return actions
+}
+
+func saveLastActions(actions map[string]*models.RemoteAction) {
+	data, err := json.MarshalIndent(actions, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := utils.WriteFileAtomic(cfgManager.StatePath(lastActionsFile), data, 0600); err != nil {
+		logger.WithError(err).Debug("Failed to record last remote actions")
+	}
+}
+
+// paramsDigest hashes the command's parameters so runs can be compared across
+// hosts. Credentials and session data are left out.
+func (m wsMsg) paramsDigest() string {
+	params := map[string]interface{}{
+		"version":                  m.version,
+		"force":                    m.force,
+		"integration":              m.integrationName,
+		"enabled":                  m.integrationEnabled,
+		"profile_type":             m.profileType,
+		"profile_id":               m.profileID,
+		"enable_remediation":       m.enableRemediation,
+		"fetch_remote_resources":   m.fetchRemoteResources,
+		"openscap_enabled":         m.openscapEnabled,
+		"docker_bench_enabled":     m.dockerBenchEnabled,
+		"rule_id":                  m.ruleID,
+		"image_name":               m.imageName,
+		"container_name":           m.containerName,
+		"scan_all_images":          m.scanAllImages,
+		"mode":                     m.complianceMode,
+		"on_demand_only":           m.complianceOnDemandOnly,
+		"config":                   m.applyConfig,
+		"sections":                 m.reportSections,
+		"interval":                 m.interval,
+		"compliance_scan_interval": m.complianceScanInterval,
+		"duration_seconds":         int(m.pauseDuration.Seconds()),
+		"patch_run_id":             m.patchRunID,
+		"patch_type":               m.patchType,
+		"package_names":            m.packageNames,
+		"dry_run":                  m.dryRun,
+		"ssh_host":                 m.sshProxyHost,
+		"ssh_port":                 m.sshProxyPort,
+		"ssh_username":             m.sshProxyUsername,
+		"rdp_host":                 m.rdpProxyHost,
+		"rdp_port":                 m.rdpProxyPort,
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"patchmon-agent/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLastActionsRecordOutcomes(t *testing.T) {
+	cfgManager = config.New()
+	cfgManager.SetConfigFile(filepath.Join(t.TempDir(), "config.yml"))
+	logger = logrus.New()
+	logger.SetOutput(io.Discard)
+
+	if lastActions() != nil {
+		t.Fatal("expected no recorded actions initially")
+	}
+
+	scan := wsMsg{kind: "compliance_scan", profileID: "cis"}
+	recordActionStart(scan)
+	if got := lastActions()["compliance_scan"]; got == nil || got.Outcome != actionRunning {
+		t.Fatalf("compliance_scan = %+v, want running", got)
+	}
+	finishAction(scan, context.Canceled)
+	if got := lastActions()["compliance_scan"].Outcome; got != actionCancelled {
+		t.Fatalf("outcome = %q, want cancelled", got)
+	}
+
+	finishAction(wsMsg{kind: "report_now"}, errors.New("server unreachable"))
+	if got := lastActions()["report_now"]; got.Outcome != actionFailed || got.Error != "server unreachable" {
+		t.Fatalf("report_now = %+v", got)
+	}
+
+	recordActionStart(wsMsg{kind: "ssh_proxy_input"})
+	if _, ok := lastActions()["ssh_proxy_input"]; ok {
+		t.Fatal("session traffic should not be recorded")
+	}
+}
+
+func TestLastActionsSettleAfterRestart(t *testing.T) {
+	cfgManager = config.New()
+	cfgManager.SetConfigFile(filepath.Join(t.TempDir(), "config.yml"))
+	logger = logrus.New()
+	logger.SetOutput(io.Discard)
+
+	recordActionStart(wsMsg{kind: "run_patch", patchRunID: "r1"})
+	recordActionStart(wsMsg{kind: "update_agent"})
+	actions := lastActions()
+	actions["update_agent"].AgentVersion = "previous" // the update replaced this version
+	saveLastActions(actions)
+
+	settleInterruptedActions()
+	actions = lastActions()
+	if got := actions["run_patch"].Outcome; got != actionInterrupted {
+		t.Errorf("run_patch = %q, want interrupted", got)
+	}
+	if got := actions["update_agent"].Outcome; got != actionSuccess {
+		t.Errorf("update_agent = %q, want success after a version change", got)
+	}
+}
+
+func TestParamsDigest(t *testing.T) {
+	a := wsMsg{kind: "run_patch", patchRunID: "r1", packageNames: []string{"openssl"}, sshProxyPassword: "x"}
+	b := wsMsg{kind: "run_patch", patchRunID: "r1", packageNames: []string{"openssl"}, sshProxyPassword: "y"}
+	c := wsMsg{kind: "run_patch", patchRunID: "r1", packageNames: []string{"curl"}}
+	if a.paramsDigest() != b.paramsDigest() {
+		t.Error("secrets should not affect the digest")
+	}
+	if a.paramsDigest() == c.paramsDigest() {
+		t.Error("different parameters should give different digests")
+	}
+}
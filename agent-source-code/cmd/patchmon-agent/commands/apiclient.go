@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/client"
+	"patchmon-agent/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	apiClientMu    sync.Mutex
+	apiClientCache *client.Client
+	apiClientKey   apiClientState
+	// apiServers is the servers list the cached client copies payloads to
+	apiServers []*serverProfile
+)
+
+// apiClientState is what a cached client was built from. Reloading the config
+// or credentials swaps these pointers, so a change forces a new client.
+type apiClientState struct {
+	config      *models.Config
+	credentials *models.Credentials
+	skipVerify  bool
+}
+
+// apiClient returns the shared PatchMon API client. Handlers use it rather than
+// building their own so requests share one client and its pooled connections.
+func apiClient() *client.Client {
+	cfg := cfgManager.GetConfig()
+	key := apiClientState{
+		config:      cfg,
+		credentials: cfgManager.GetCredentials(),
+		skipVerify:  cfg.SkipSSLVerify || client.IsSkipSSLVerifyEnvSet(),
+	}
+
+	apiClientMu.Lock()
+	defer apiClientMu.Unlock()
+	if apiClientCache == nil || apiClientKey != key {
+		apiClientCache = client.New(cfgManager, logger)
+		apiClientCache.SetSchemaVersion(loadServerSchemaVersion())
+		apiServers = loadServerProfiles()
+		servers := make([]*client.Client, len(apiServers))
+		for i, s := range apiServers {
+			servers[i] = s.client
+		}
+		apiClientCache.SetServers(servers)
+		apiClientKey = key
+	}
+	return apiClientCache
+}
+
+// resetAPIClient drops the cached client, so the next apiClient call builds a
+// new one and its servers list
+func resetAPIClient() {
+	apiClientMu.Lock()
+	defer apiClientMu.Unlock()
+	apiClientCache = nil
+}
+
+// serverSchemaFile holds the payload schema version from the server's last
+// handshake, so report runs from cron render for it without pinging first
+const serverSchemaFile = "server_schema_version"
+
+func loadServerSchemaVersion() int {
+	data, err := os.ReadFile(cfgManager.StatePath(serverSchemaFile))
+	if err != nil {
+		return 0
+	}
+	v, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return v
+}
+
+// rememberServerSchema applies the payload schema version announced by the
+// server (0 when it announced none) and saves it for later runs
+func rememberServerSchema(v int) {
+	if v == loadServerSchemaVersion() {
+		return
+	}
+	c := apiClient()
+	c.SetSchemaVersion(v)
+	if effective := c.SchemaVersion(); effective < models.SchemaVersion {
+		logger.WithFields(logrus.Fields{
+			"server_schema": v,
+			"agent_schema":  models.SchemaVersion,
+		}).Warnf("Server accepts an older payload schema, sending schema %d payloads", effective)
+	} else {
+		logger.WithField("server_schema", v).Info("Sending current payload schema")
+	}
+	if err := utils.WriteFileAtomic(cfgManager.StatePath(serverSchemaFile), []byte(strconv.Itoa(v)+"\n"), 0600); err != nil {
+		logger.WithError(err).Debug("Failed to save server schema version")
+	}
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"patchmon-agent/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestAPIClientReusedUntilCredentialsChange(t *testing.T) {
+	dir := t.TempDir()
+	cfgManager = config.New()
+	cfgManager.SetConfigFile(filepath.Join(dir, "config.yml"))
+	cfgManager.GetConfig().CredentialsFile = filepath.Join(dir, "credentials.yml")
+	logger = logrus.New()
+	logger.SetOutput(io.Discard)
+
+	if err := cfgManager.SaveCredentials("id-1", "key-1"); err != nil {
+		t.Fatalf("SaveCredentials: %v", err)
+	}
+	first := apiClient()
+	if apiClient() != first {
+		t.Fatal("expected the same client while config and credentials are unchanged")
+	}
+
+	if err := cfgManager.SaveCredentials("id-2", "key-2"); err != nil {
+		t.Fatalf("SaveCredentials: %v", err)
+	}
+	if apiClient() == first {
+		t.Fatal("expected a new client after the credentials changed")
+	}
+}
+
+func TestResetConnectionsRebuildsAPIClient(t *testing.T) {
+	dir := t.TempDir()
+	cfgManager = config.New()
+	cfgManager.SetConfigFile(filepath.Join(dir, "config.yml"))
+	logger = logrus.New()
+	logger.SetOutput(io.Discard)
+
+	first := apiClient()
+	if err := watchdogResetConnections(context.Background(), nil); err != nil {
+		t.Fatalf("watchdogResetConnections: %v", err)
+	}
+	if apiClient() == first {
+		t.Fatal("expected a new client after reset_connections")
+	}
+}
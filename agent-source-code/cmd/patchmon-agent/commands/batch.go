@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// maxBatchSteps bounds how many commands one batch message may carry
+const maxBatchSteps = 20
+
+// batchableCommands are the commands a batch may contain. The ones mapped to
+// true restart or replace the agent, so they may only be the last step.
+var batchableCommands = map[string]bool{
+	"pause":                         false,
+	"resume":                        false,
+	"settings_update":               false,
+	"report_now":                    false,
+	"refresh_integration_status":    false,
+	"docker_inventory_refresh":      false,
+	"hardware_inventory":            false,
+	"run_patch":                     false,
+	"compliance_scan":               false,
+	"compliance_scan_cancel":        false,
+	"compliance_ckl_export":         false,
+	"patch_run_stop":                false,
+	"upgrade_ssg":                   false,
+	"install_scanner":               false,
+	"remediate_rule":                false,
+	"docker_image_scan":             false,
+	"docker_update_container":       false,
+	"docker_prune":                  false,
+	"package_update":                false,
+	"set_compliance_mode":           false,
+	"set_compliance_on_demand_only": false,
+	"update_agent":                  true,
+	"update_notification":           true,
+	"integration_toggle":            true,
+	"apply_config":                  true,
+}
+
+// errBatchStepFailed stops a batch after a step that did not succeed
+var errBatchStepFailed = errors.New("earlier batch step did not succeed")
+
+// commandBatch is a batch message: commands that run one after another, each
+// starting only once the previous one has finished. The reader parses every
+// step with the same validation as a standalone command before any of them
+// runs, so one invalid step rejects the whole batch.
+type commandBatch struct {
+	commandID       string
+	continueOnError bool
+	raw             []json.RawMessage
+	next            int
+	steps           []wsMsg
+	err             error          // first step that failed validation
+	server          *serverProfile // servers entry that sent it, nil for patchmon_server
+}
+
+func newCommandBatch(commandID string, commands []json.RawMessage, continueOnError bool) (*commandBatch, error) {
+	switch {
+	case len(commands) == 0:
+		return nil, errors.New("batch has no commands")
+	case len(commands) > maxBatchSteps:
+		return nil, fmt.Errorf("batch has %d commands, at most %d are allowed", len(commands), maxBatchSteps)
+	}
+	return &commandBatch{commandID: commandID, continueOnError: continueOnError, raw: commands}, nil
+}
+
+// parsing reports whether steps are still waiting to be parsed
+func (b *commandBatch) parsing() bool {
+	return b != nil && b.next < len(b.raw)
+}
+
+// nextStep returns the next step's raw message
+func (b *commandBatch) nextStep() []byte {
+	data := b.raw[b.next]
+	b.next++
+	return data
+}
+
+// check rejects a step type that can't be batched
+func (b *commandBatch) check(command string) bool {
+	last, ok := batchableCommands[command]
+	switch {
+	case !ok:
+		b.reject(command, "command can't be batched")
+	case last && b.next != len(b.raw):
+		b.reject(command, "command restarts the agent, so it must be the last step")
+	default:
+		return true
+	}
+	return false
+}
+
+// add queues a parsed step
+func (b *commandBatch) add(m wsMsg) {
+	b.steps = append(b.steps, m)
+}
+
+// reject records a step that failed validation
+func (b *commandBatch) reject(command, detail string) {
+	if b.err == nil {
+		b.err = fmt.Errorf("step %d (%s): %s", b.next, command, detail)
+	}
+}
+
+// start runs the batch once every step has been parsed, or rejects it
+func (b *commandBatch) start(conn *websocket.Conn, out chan<- wsMsg) {
+	if b.err == nil && len(b.steps) != len(b.raw) {
+		b.err = errors.New("batch contains a command that could not be parsed")
+	}
+	if b.err != nil {
+		logger.WithError(b.err).Warn("Rejecting command batch")
+		nackCommand(conn, "batch", b.commandID, models.NackInvalid, b.err.Error())
+		return
+	}
+	batch := wsMsg{kind: "batch", commandID: b.commandID, server: b.server}
+	jobs.enqueue(&batch)
+	for i := range b.steps {
+		jobs.enqueue(&b.steps[i])
+	}
+	recordActionStart(batch)
+	ackCommand(conn, batch.kind, batch.commandID)
+	logger.WithField("steps", len(b.steps)).Info("Running command batch")
+	go func() {
+		ctx, done := jobs.context(context.Background(), batch.jobID)
+		defer done()
+		finishAction(batch, b.run(ctx, out))
+	}()
+}
+
+// run hands each step to the dispatcher and waits for it to finish. After a
+// failed step the rest are skipped unless continue_on_error was set.
+// Cancelling ctx cancels the running step and skips the rest.
+func (b *commandBatch) run(ctx context.Context, out chan<- wsMsg) error {
+	var errs []error
+	for i, m := range b.steps {
+		if ctx.Err() != nil {
+			b.skip(b.steps[i:], "batch cancelled")
+			errs = append(errs, ctx.Err())
+			break
+		}
+		done := make(chan error, 1)
+		m.done = done
+		out <- m
+		var err error
+		select {
+		case err = <-done:
+		case <-ctx.Done():
+			if cancelErr := jobs.cancel(m.jobID); cancelErr != nil {
+				logger.WithError(cancelErr).Debug("Could not cancel batch step")
+			}
+			err = <-done
+		}
+		if err == nil {
+			continue
+		}
+		logger.WithError(err).WithFields(logrus.Fields{
+			"step":    i + 1,
+			"command": m.kind,
+		}).Warn("Command batch step failed")
+		errs = append(errs, fmt.Errorf("step %d (%s): %w", i+1, m.kind, err))
+		if b.continueOnError {
+			continue
+		}
+		b.skip(b.steps[i+1:], errBatchStepFailed.Error())
+		break
+	}
+	return errors.Join(errs...)
+}
+
+// skip tells the server steps won't run
+func (b *commandBatch) skip(steps []wsMsg, reason string) {
+	for _, m := range steps {
+		recordActionOutcome(m, actionSkipped, reason)
+		nackCommand(m.replyConn(), m.kind, m.commandID, models.NackSkipped, reason)
+	}
+}
+
+// stepDone tells a waiting batch how this step ended
+func (m wsMsg) stepDone(err error) {
+	if m.done == nil {
+		return
+	}
+	select {
+	case m.done <- err:
+	default:
+	}
+}
@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"patchmon-agent/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+func setupBatchTest(t *testing.T) {
+	t.Helper()
+	cfgManager = config.New()
+	cfgManager.SetConfigFile(filepath.Join(t.TempDir(), "config.yml"))
+	logger = logrus.New()
+	logger.SetOutput(io.Discard)
+}
+
+// dispatch stands in for the service loop, failing the commands in fail
+func dispatch(out <-chan wsMsg, fail map[string]bool, ran *[]string) {
+	for m := range out {
+		*ran = append(*ran, m.kind)
+		var err error
+		if fail[m.kind] {
+			err = errors.New("boom")
+		}
+		finishAction(m, err)
+	}
+}
+
+func batchOf(kinds ...string) *commandBatch {
+	b := &commandBatch{}
+	for _, kind := range kinds {
+		b.steps = append(b.steps, wsMsg{kind: kind})
+	}
+	return b
+}
+
+func TestBatchRunsStepsInOrder(t *testing.T) {
+	setupBatchTest(t)
+	out := make(chan wsMsg)
+	var ran []string
+	finished := make(chan struct{})
+	go func() { dispatch(out, map[string]bool{"compliance_scan": true}, &ran); close(finished) }()
+
+	err := batchOf("upgrade_ssg", "compliance_scan", "report_now").run(context.Background(), out)
+	close(out)
+	<-finished
+	if err == nil {
+		t.Fatal("expected the failed step to fail the batch")
+	}
+	if want := []string{"upgrade_ssg", "compliance_scan"}; !reflect.DeepEqual(ran, want) {
+		t.Fatalf("ran %v, want %v", ran, want)
+	}
+	if got := lastActions()["report_now"]; got == nil || got.Outcome != actionSkipped {
+		t.Fatalf("report_now recorded as %+v, want skipped", got)
+	}
+}
+
+func TestBatchContinueOnError(t *testing.T) {
+	setupBatchTest(t)
+	out := make(chan wsMsg)
+	var ran []string
+	finished := make(chan struct{})
+	go func() { dispatch(out, map[string]bool{"upgrade_ssg": true}, &ran); close(finished) }()
+
+	b := batchOf("upgrade_ssg", "compliance_scan", "report_now")
+	b.continueOnError = true
+	err := b.run(context.Background(), out)
+	close(out)
+	<-finished
+	if err == nil {
+		t.Fatal("expected an error for the failed step")
+	}
+	if want := []string{"upgrade_ssg", "compliance_scan", "report_now"}; !reflect.DeepEqual(ran, want) {
+		t.Fatalf("ran %v, want %v", ran, want)
+	}
+}
+
+func TestBatchValidation(t *testing.T) {
+	setupBatchTest(t)
+	if _, err := newCommandBatch("", nil, false); err == nil {
+		t.Fatal("empty batch accepted")
+	}
+
+	raw := func(n int) []json.RawMessage { return make([]json.RawMessage, n) }
+	if _, err := newCommandBatch("", raw(maxBatchSteps+1), false); err == nil {
+		t.Fatal("oversized batch accepted")
+	}
+
+	tests := []struct {
+		name  string
+		kinds []string
+		ok    bool
+	}{
+		{"workflow", []string{"upgrade_ssg", "compliance_scan", "report_now"}, true},
+		{"restart last", []string{"report_now", "update_agent"}, true},
+		{"restart first", []string{"update_agent", "report_now"}, false},
+		{"nested batch", []string{"batch"}, false},
+		{"session traffic", []string{"ssh_proxy_input"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := newCommandBatch("", raw(len(tt.kinds)), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ok := true
+			for _, kind := range tt.kinds {
+				b.nextStep()
+				ok = b.check(kind) && ok
+			}
+			if ok != tt.ok || (b.err == nil) != tt.ok {
+				t.Fatalf("check = %v, err = %v, want ok %v", ok, b.err, tt.ok)
+			}
+		})
+	}
+}
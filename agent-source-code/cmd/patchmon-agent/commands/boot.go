@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/system"
+	"patchmon-agent/internal/utils"
+)
+
+// bootHeartbeatInterval is how often serve records that the host is still up,
+// which bounds how far the downtime after a reboot can be overstated
+const bootHeartbeatInterval = time.Minute
+
+// rebootReportWindow caps the startup report window after a reboot
+const rebootReportWindow = 30 * time.Second
+
+// bootState is the boot the last successful report came from and when the
+// agent last saw that boot running
+type bootState struct {
+	BootID   string    `json:"bootId"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+var bootStateMu sync.Mutex
+
+func loadBootState() bootState {
+	var s bootState
+	if data, err := os.ReadFile(cfgManager.StatePath(bootStateFile)); err == nil {
+		_ = json.Unmarshal(data, &s)
+	}
+	return s
+}
+
+func saveBootState(s bootState) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+	if err := utils.WriteFileAtomic(cfgManager.StatePath(bootStateFile), data, 0600); err != nil {
+		logger.WithError(err).Debug("Failed to save boot state")
+	}
+}
+
+// bootChange returns the boot details to send when the host has rebooted
+// since the last successful report, or nil. Nothing is reported before the
+// agent has recorded a boot, so a fresh install doesn't look like a reboot.
+func bootChange(state bootState, bootID string, bootTime time.Time) *models.BootInfo {
+	if state.BootID == "" || bootID == "" || system.SameBoot(state.BootID, bootID) {
+		return nil
+	}
+	info := &models.BootInfo{BootID: bootID, PreviousBootID: state.BootID, BootTime: bootTime}
+	if !state.LastSeen.IsZero() {
+		lastSeen := state.LastSeen
+		info.LastSeen = &lastSeen
+		if downtime := bootTime.Sub(lastSeen); downtime > 0 {
+			info.DowntimeSeconds = int64(downtime.Seconds())
+		}
+	}
+	return info
+}
+
+// detectReboot returns the current boot ID, and the boot details for the
+// next report when the host has rebooted since the last one
+func detectReboot(detector *system.Detector) (string, *models.BootInfo) {
+	bootID, bootTime, err := detector.GetBootID()
+	if err != nil {
+		logger.WithError(err).Debug("Boot ID unavailable, skipping reboot detection")
+		return "", nil
+	}
+	bootStateMu.Lock()
+	defer bootStateMu.Unlock()
+	return bootID, bootChange(loadBootState(), bootID, bootTime)
+}
+
+// markBootReported records the current boot after a successful report, so
+// the reboot is reported once
+func markBootReported(bootID string) {
+	if bootID == "" {
+		return
+	}
+	bootStateMu.Lock()
+	defer bootStateMu.Unlock()
+	saveBootState(bootState{BootID: bootID, LastSeen: time.Now()})
+}
+
+// touchBootState moves the last-seen time forward while the recorded boot is
+// still running. After a reboot it leaves the state alone until the reboot
+// has been reported.
+func touchBootState(detector *system.Detector) {
+	bootID, _, err := detector.GetBootID()
+	if err != nil {
+		return
+	}
+	bootStateMu.Lock()
+	defer bootStateMu.Unlock()
+	s := loadBootState()
+	if s.BootID == "" || !system.SameBoot(s.BootID, bootID) {
+		return
+	}
+	s.LastSeen = time.Now()
+	saveBootState(s)
+}
+
+// runBootHeartbeat keeps the last-seen time fresh until serve stops, so the
+// downtime reported after a reboot is accurate to about a minute
+func runBootHeartbeat(ctx context.Context) {
+	detector := newSystemDetector()
+	ticker := time.NewTicker(bootHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			touchBootState(detector)
+			return
+		case <-ticker.C:
+			touchBootState(detector)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBootChange(t *testing.T) {
+	lastSeen := time.Date(2026, 10, 3, 22, 0, 0, 0, time.UTC)
+	bootTime := lastSeen.Add(4 * time.Minute)
+
+	if info := bootChange(bootState{}, "b2", bootTime); info != nil {
+		t.Errorf("first run reported a reboot: %+v", info)
+	}
+	if info := bootChange(bootState{BootID: "b1", LastSeen: lastSeen}, "b1", bootTime); info != nil {
+		t.Errorf("same boot reported a reboot: %+v", info)
+	}
+
+	info := bootChange(bootState{BootID: "b1", LastSeen: lastSeen}, "b2", bootTime)
+	if info == nil {
+		t.Fatal("reboot not detected")
+	}
+	if info.BootID != "b2" || info.PreviousBootID != "b1" || !info.BootTime.Equal(bootTime) {
+		t.Errorf("boot info = %+v", info)
+	}
+	if info.LastSeen == nil || !info.LastSeen.Equal(lastSeen) || info.DowntimeSeconds != 240 {
+		t.Errorf("downtime = %d since %v, want 240s", info.DowntimeSeconds, info.LastSeen)
+	}
+
+	// A clock stepped back across the reboot mustn't give negative downtime
+	info = bootChange(bootState{BootID: "b1", LastSeen: bootTime.Add(time.Minute)}, "b2", bootTime)
+	if info == nil || info.DowntimeSeconds != 0 {
+		t.Errorf("boot info with last seen after boot = %+v", info)
+	}
+}
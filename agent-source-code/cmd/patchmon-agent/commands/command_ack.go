@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/logutil"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// sendCommandReply answers a server command that carried a command_id.
+// Servers that send no IDs get no replies.
+func sendCommandReply(conn *websocket.Conn, reply models.CommandReply) {
+	if conn == nil || reply.CommandID == "" {
+		return
+	}
+	reply.Timestamp = time.Now().UTC()
+	if err := sendWSMessage(conn, reply); err != nil {
+		logger.WithError(err).WithFields(logrus.Fields{
+			"type":       reply.Type,
+			"command":    logutil.Sanitize(reply.Command),
+			"command_id": logutil.Sanitize(reply.CommandID),
+		}).Debug("Failed to send command reply")
+	}
+}
+
+// currentWsConn returns the live WebSocket connection, if any
+func currentWsConn() *websocket.Conn {
+	globalWsConnMu.RLock()
+	defer globalWsConnMu.RUnlock()
+	return globalWsConn
+}
+
+// ackCommand confirms a command was received and accepted
+func ackCommand(conn *websocket.Conn, command, commandID string) {
+	sendCommandReply(conn, models.CommandReply{Type: models.CommandAck, CommandID: commandID, Command: command})
+}
+
+// nackCommand tells the server a command was rejected and won't run
+func nackCommand(conn *websocket.Conn, command, commandID, reason, detail string) {
+	sendCommandReply(conn, models.CommandReply{Type: models.CommandNack, CommandID: commandID, Command: command, Reason: reason, Error: detail})
+}
+
+// sendCommandResult reports how an accepted command ended
+func sendCommandResult(m wsMsg, outcome, detail string) {
+	if untrackedActions[m.kind] {
+		return
+	}
+	sendCommandReply(m.replyConn(), models.CommandReply{Type: models.CommandResult, CommandID: m.commandID, Command: m.kind, Outcome: outcome, Error: detail})
+}
@@ -0,0 +1,172 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/config"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// messageServer returns a connection to a WebSocket server that forwards the
+// raw messages it receives to the returned channel
+func messageServer(t *testing.T) (*websocket.Conn, <-chan []byte) {
+	t.Helper()
+	messages := make(chan []byte, 8)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			messages <- data
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, messages
+}
+
+// replyServer is messageServer decoding each message as a command reply
+func replyServer(t *testing.T) (*websocket.Conn, <-chan models.CommandReply) {
+	t.Helper()
+	conn, messages := messageServer(t)
+	replies := make(chan models.CommandReply, 8)
+	go func() {
+		for data := range messages {
+			var reply models.CommandReply
+			if json.Unmarshal(data, &reply) == nil {
+				replies <- reply
+			}
+		}
+	}()
+	return conn, replies
+}
+
+func nextMessage(t *testing.T, messages <-chan []byte) string {
+	t.Helper()
+	select {
+	case m := <-messages:
+		return string(m)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no WebSocket message received")
+		return ""
+	}
+}
+
+func nextReply(t *testing.T, replies <-chan models.CommandReply) models.CommandReply {
+	t.Helper()
+	select {
+	case r := <-replies:
+		return r
+	case <-time.After(2 * time.Second):
+		t.Fatal("no command reply received")
+		return models.CommandReply{}
+	}
+}
+
+func TestCommandReplies(t *testing.T) {
+	cfgManager = config.New()
+	cfgManager.SetConfigFile(filepath.Join(t.TempDir(), "config.yml"))
+	logger = logrus.New()
+	logger.SetOutput(io.Discard)
+
+	conn, replies := replyServer(t)
+	globalWsConnMu.Lock()
+	globalWsConn = conn
+	globalWsConnMu.Unlock()
+	t.Cleanup(func() {
+		globalWsConnMu.Lock()
+		globalWsConn = nil
+		globalWsConnMu.Unlock()
+	})
+
+	// Without a command_id nothing is sent
+	nackCommand(conn, "compliance_scan", "", models.NackInvalid, "bad profile")
+	finishAction(wsMsg{kind: "report_now"}, nil)
+
+	nackCommand(conn, "compliance_scan", "c-1", models.NackInvalid, "bad profile")
+	got := nextReply(t, replies)
+	if got.Type != models.CommandNack || got.CommandID != "c-1" || got.Command != "compliance_scan" || got.Reason != models.NackInvalid || got.Error != "bad profile" {
+		t.Fatalf("nack = %+v", got)
+	}
+
+	scan := wsMsg{kind: "compliance_scan", commandID: "c-2"}
+	ackCommand(currentWsConn(), scan.kind, scan.commandID)
+	if got := nextReply(t, replies); got.Type != models.CommandAck || got.CommandID != "c-2" {
+		t.Fatalf("ack = %+v", got)
+	}
+	finishAction(scan, errors.New("oscap failed"))
+	got = nextReply(t, replies)
+	if got.Type != models.CommandResult || got.CommandID != "c-2" || got.Outcome != actionFailed || got.Error != "oscap failed" {
+		t.Fatalf("result = %+v", got)
+	}
+	if got.Timestamp.IsZero() {
+		t.Fatal("reply has no timestamp")
+	}
+
+	// Session traffic gets no results
+	finishAction(wsMsg{kind: "ssh_proxy_input", commandID: "c-3"}, nil)
+	select {
+	case r := <-replies:
+		t.Fatalf("unexpected reply %+v", r)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWebSocketMessagesAreRedacted(t *testing.T) {
+	cfgManager = config.New()
+	cfgManager.SetConfigFile(filepath.Join(t.TempDir(), "config.yml"))
+	cfgManager.GetConfig().Redaction = &models.RedactionConfig{Patterns: []string{`secret-\w+`}}
+	resetAPIClient()
+	t.Cleanup(resetAPIClient)
+	logger = logrus.New()
+	logger.SetOutput(io.Discard)
+
+	conn, messages := messageServer(t)
+
+	sendCommandReply(conn, models.CommandReply{Type: models.CommandResult, CommandID: "c-1", Error: "login with secret-abc failed"})
+	if got := nextMessage(t, messages); strings.Contains(got, "secret-abc") || !strings.Contains(got, "[redacted]") {
+		t.Fatalf("command reply not redacted: %s", got)
+	}
+	if err := sendWSMessage(conn, map[string]interface{}{"type": "agent_resources", "note": "secret-def"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := nextMessage(t, messages); strings.Contains(got, "secret-def") {
+		t.Fatalf("message not redacted: %s", got)
+	}
+
+	// Proxy streams carry the session's bytes verbatim
+	sendSSHProxyMessage(conn, "ssh_proxy_data", "s-1", "echo secret-ghi")
+	if got := nextMessage(t, messages); !strings.Contains(got, "secret-ghi") {
+		t.Fatalf("proxy data was altered: %s", got)
+	}
+
+	// An invalid redaction config drops messages instead of sending them unmasked
+	cfgManager.GetConfig().Redaction = &models.RedactionConfig{Patterns: []string{"("}}
+	resetAPIClient()
+	if err := sendWSMessage(conn, map[string]interface{}{"type": "agent_resources"}); err == nil {
+		t.Fatal("sendWSMessage succeeded with an invalid redaction config")
+	}
+}
@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/integrations/compliance"
+	"patchmon-agent/internal/network"
+	"patchmon-agent/internal/system"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultCKLProfile is the profile scanned for a checklist when none is given
+const defaultCKLProfile = "stig"
+
+var (
+	cklProfile string
+	cklResults string
+	cklOutput  string
+	cklRole    string
+)
+
+var complianceCKLCmd = &cobra.Command{
+	Use:   "compliance-ckl",
+	Short: "Export OpenSCAP results as a DISA STIG Viewer checklist (.ckl)",
+	Long: `Runs an OpenSCAP scan of a profile (the STIG profile by default) and writes
+the results as a STIG Viewer checklist. With --results, an existing XCCDF
+results file from oscap xccdf eval --results is converted instead.`,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		// Converting a results file needs no privileges; scanning does
+		if cklResults == "" {
+			if err := checkRoot(); err != nil {
+				return err
+			}
+		}
+		var out io.Writer = os.Stdout
+		if cklOutput != "" && cklOutput != "-" {
+			f, err := os.OpenFile(cklOutput, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		asset := cklAsset()
+		asset.Role = cklRole
+
+		if cklResults != "" {
+			data, err := os.ReadFile(cklResults)
+			if err != nil {
+				return err
+			}
+			ckl, err := compliance.BuildCKL(data, asset)
+			if err != nil {
+				return err
+			}
+			return compliance.WriteCKL(out, ckl)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 25*time.Minute)
+		defer cancel()
+		scan, err := compliance.New(logger).ExportCKL(ctx, cklProfile, asset, out)
+		if err != nil {
+			return err
+		}
+		if out != os.Stdout {
+			fmt.Printf("Wrote %s: %d rules, %d open, score %.1f%%\n", cklOutput, scan.TotalRules, scan.Failed, scan.Score)
+		}
+		return nil
+	},
+}
+
+func init() {
+	complianceCKLCmd.Flags().StringVar(&cklProfile, "profile", defaultCKLProfile, "profile to scan (short name or XCCDF profile ID)")
+	complianceCKLCmd.Flags().StringVar(&cklResults, "results", "", "convert this XCCDF results file instead of scanning")
+	complianceCKLCmd.Flags().StringVarP(&cklOutput, "output", "o", "", "write the checklist here instead of stdout")
+	complianceCKLCmd.Flags().StringVar(&cklRole, "role", "", "asset role: None, Workstation, Member Server or Domain Controller")
+	rootCmd.AddCommand(complianceCKLCmd)
+}
+
+// cklAsset describes this host for a checklist's ASSET section
+func cklAsset() compliance.CKLAsset {
+	detector := newSystemDetector()
+	asset := compliance.CKLAsset{HostIP: detector.GetIPAddress()}
+	asset.HostName, _ = detector.GetHostname()
+	cfg := cfgManager.GetConfig()
+	fqdn, _ := system.New(logger).WithHostnameOptions(system.HostnameOptions{
+		Override: cfg.HostnameOverride,
+		UseFQDN:  true,
+	}).GetHostname()
+	if fqdn != asset.HostName {
+		asset.HostFQDN = fqdn
+	}
+	for _, iface := range network.New(logger).GetNetworkInfo().NetworkInterfaces {
+		if slices.ContainsFunc(iface.Addresses, func(a models.NetworkAddress) bool { return a.Address == asset.HostIP }) {
+			asset.HostMAC = iface.MACAddress
+			break
+		}
+	}
+	return asset
+}
+
+// uploadComplianceCKL answers compliance_ckl_export: it scans the profile and
+// uploads the checklist
+func uploadComplianceCKL(ctx context.Context, profileID, commandID string) error {
+	if !cfgManager.IsIntegrationEnabled("compliance") {
+		return fmt.Errorf("compliance integration is not enabled")
+	}
+	if profileID == "" {
+		profileID = defaultCKLProfile
+	}
+	if !complianceScanRunning.CompareAndSwap(false, true) {
+		return fmt.Errorf("a compliance scan is already running")
+	}
+	defer complianceScanRunning.Store(false)
+
+	scanCtx, cancel := context.WithTimeout(ctx, 25*time.Minute)
+	defer cancel()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := compliance.New(logger).ExportCKL(scanCtx, profileID, cklAsset(), zw); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	detector := newSystemDetector()
+	hostname, _ := detector.GetHostname()
+	uploadCtx, uploadCancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer uploadCancel()
+	return commandClient(ctx).SendComplianceCKL(uploadCtx, &models.ComplianceCKLInfo{
+		ProfileID: profileID,
+		CommandID: commandID,
+		Hostname:  hostname,
+		MachineID: detector.GetMachineID(),
+	}, buf.Bytes())
+}
@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/notify"
+	"patchmon-agent/internal/utils"
+)
+
+// complianceBaselineFile keeps the last uploaded result of each compliance
+// profile, so the next scan can be compared with it
+const complianceBaselineFile = "compliance_baseline.json"
+
+// maxRegressionRulesNotified bounds the rule IDs listed in a notification
+const maxRegressionRulesNotified = 10
+
+// complianceBaseline is the stored result of one profile
+type complianceBaseline struct {
+	Score       float64           `json:"score"`
+	CompletedAt *time.Time        `json:"completedAt,omitempty"`
+	Rules       map[string]string `json:"rules"` // rule ID -> status
+}
+
+var complianceBaselineMu sync.Mutex
+
+func complianceProfileKey(scan models.ComplianceScan) string {
+	return scan.ProfileType + "/" + scan.ProfileName
+}
+
+// ruleFailing reports whether a result counts as failing. Docker Bench
+// reports failed checks as warn.
+func ruleFailing(status string) bool {
+	return status == "fail" || status == "warn"
+}
+
+// addComplianceDiffs sets Diff on each completed scan that has a stored
+// previous result
+func addComplianceDiffs(scans []models.ComplianceScan) {
+	complianceBaselineMu.Lock()
+	baselines := loadComplianceBaselines()
+	complianceBaselineMu.Unlock()
+	for i := range scans {
+		if scans[i].Status != "completed" {
+			continue
+		}
+		if prev, ok := baselines[complianceProfileKey(scans[i])]; ok {
+			scans[i].Diff = diffComplianceScan(prev, scans[i])
+		}
+	}
+}
+
+// diffComplianceScan compares a scan with the stored result of its profile
+func diffComplianceScan(prev complianceBaseline, scan models.ComplianceScan) *models.ComplianceScanDiff {
+	diff := &models.ComplianceScanDiff{
+		PreviousCompletedAt: prev.CompletedAt,
+		PreviousScore:       prev.Score,
+		ScoreChange:         math.Round((scan.Score-prev.Score)*10) / 10,
+	}
+	for _, r := range scan.Results {
+		before, seen := prev.Rules[r.RuleID]
+		switch {
+		case ruleFailing(r.Status) && (!seen || !ruleFailing(before)):
+			diff.NewlyFailing = append(diff.NewlyFailing, r.RuleID)
+		case r.Status == "pass" && seen && ruleFailing(before):
+			diff.NewlyPassing = append(diff.NewlyPassing, r.RuleID)
+		}
+	}
+	sort.Strings(diff.NewlyFailing)
+	sort.Strings(diff.NewlyPassing)
+	return diff
+}
+
+// saveComplianceBaselines stores completed scans as the results the next
+// scans are compared with. Call it once the server has accepted them.
+func saveComplianceBaselines(scans []models.ComplianceScan) {
+	complianceBaselineMu.Lock()
+	defer complianceBaselineMu.Unlock()
+	baselines := loadComplianceBaselines()
+	changed := false
+	for _, scan := range scans {
+		if scan.Status != "completed" {
+			continue
+		}
+		rules := make(map[string]string, len(scan.Results))
+		for _, r := range scan.Results {
+			rules[r.RuleID] = r.Status
+		}
+		baselines[complianceProfileKey(scan)] = complianceBaseline{Score: scan.Score, CompletedAt: scan.CompletedAt, Rules: rules}
+		changed = true
+	}
+	if !changed {
+		return
+	}
+	data, err := json.Marshal(baselines)
+	if err != nil {
+		return
+	}
+	if err := utils.WriteFileAtomic(cfgManager.StatePath(complianceBaselineFile), data, 0600); err != nil {
+		logger.WithError(err).Debug("Failed to save compliance baseline")
+	}
+}
+
+func loadComplianceBaselines() map[string]complianceBaseline {
+	baselines := map[string]complianceBaseline{}
+	data, err := os.ReadFile(cfgManager.StatePath(complianceBaselineFile))
+	if err != nil {
+		return baselines
+	}
+	if err := json.Unmarshal(data, &baselines); err != nil || baselines == nil {
+		return map[string]complianceBaseline{}
+	}
+	return baselines
+}
+
+// noteComplianceRegressions notifies about rules that started failing
+func noteComplianceRegressions(scans []models.ComplianceScan) {
+	n := newNotifier()
+	if !n.Enabled() {
+		return
+	}
+	for _, scan := range scans {
+		if scan.Diff == nil || len(scan.Diff.NewlyFailing) == 0 {
+			continue
+		}
+		rules := scan.Diff.NewlyFailing
+		listed := strings.Join(rules[:min(len(rules), maxRegressionRulesNotified)], ", ")
+		if len(rules) > maxRegressionRulesNotified {
+			listed += fmt.Sprintf(" and %d more", len(rules)-maxRegressionRulesNotified)
+		}
+		go sendNotifications(n, notify.Event{
+			Type:    notify.EventComplianceRegression,
+			Title:   "Compliance regression",
+			Message: fmt.Sprintf("%d rules started failing on %s (%s, score %.1f%% → %.1f%%): %s", len(rules), notifyHostname(), scan.ProfileName, scan.Diff.PreviousScore, scan.Score, listed),
+			Details: map[string]string{
+				"profile":       scan.ProfileName,
+				"newly_failing": strconv.Itoa(len(rules)),
+				"newly_passing": strconv.Itoa(len(scan.Diff.NewlyPassing)),
+				"score_change":  fmt.Sprintf("%.1f", scan.Diff.ScoreChange),
+			},
+		})
+	}
+}
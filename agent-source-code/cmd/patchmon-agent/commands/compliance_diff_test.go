@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+func scanWith(score float64, statuses map[string]string) models.ComplianceScan {
+	scan := models.ComplianceScan{ProfileName: "level1_server", ProfileType: "openscap", Status: "completed", Score: score}
+	for id, status := range statuses {
+		scan.Results = append(scan.Results, models.ComplianceResult{RuleID: id, Status: status})
+	}
+	return scan
+}
+
+func TestComplianceDiff(t *testing.T) {
+	setupBatchTest(t)
+
+	first := []models.ComplianceScan{scanWith(80, map[string]string{
+		"a": "pass", "b": "fail", "c": "pass", "d": "notapplicable",
+	})}
+	addComplianceDiffs(first)
+	if first[0].Diff != nil {
+		t.Fatalf("first scan has a diff: %+v", first[0].Diff)
+	}
+	saveComplianceBaselines(first)
+
+	second := []models.ComplianceScan{
+		scanWith(72.5, map[string]string{
+			"a": "fail", "b": "pass", "c": "pass", "d": "fail", "e": "fail",
+		}),
+		{ProfileName: "level2_server", ProfileType: "openscap", Status: "completed"},
+	}
+	addComplianceDiffs(second)
+	diff := second[0].Diff
+	if diff == nil {
+		t.Fatal("second scan has no diff")
+	}
+	if want := []string{"a", "d", "e"}; !reflect.DeepEqual(diff.NewlyFailing, want) {
+		t.Errorf("newly failing = %v, want %v", diff.NewlyFailing, want)
+	}
+	if want := []string{"b"}; !reflect.DeepEqual(diff.NewlyPassing, want) {
+		t.Errorf("newly passing = %v, want %v", diff.NewlyPassing, want)
+	}
+	if diff.PreviousScore != 80 || diff.ScoreChange != -7.5 {
+		t.Errorf("score %v change %v, want 80 and -7.5", diff.PreviousScore, diff.ScoreChange)
+	}
+	if second[1].Diff != nil {
+		t.Error("profile without a baseline has a diff")
+	}
+
+	// Failed scans neither get a diff nor replace the baseline
+	failed := []models.ComplianceScan{{ProfileName: "level1_server", ProfileType: "openscap", Status: "failed"}}
+	addComplianceDiffs(failed)
+	saveComplianceBaselines(failed)
+	if failed[0].Diff != nil {
+		t.Error("failed scan has a diff")
+	}
+	if got := loadComplianceBaselines()["openscap/level1_server"].Score; got != 80 {
+		t.Errorf("baseline score = %v, want 80", got)
+	}
+}
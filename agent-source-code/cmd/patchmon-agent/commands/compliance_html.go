@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/client"
+	"patchmon-agent/internal/integrations/compliance"
+	"patchmon-agent/internal/logutil"
+)
+
+// complianceReportDir holds the oscap HTML report of recent OpenSCAP scans,
+// next to the config file
+const complianceReportDir = "compliance_reports"
+
+// uploadHTMLReports uploads the stored HTML report of each scan when
+// upload_html_report is on. Reports that fail to upload stay on disk; the scan
+// results themselves have already been sent.
+func uploadHTMLReports(httpClient *client.Client, scans []models.ComplianceScan, hostname, machineID string) {
+	if !cfgManager.GetComplianceUploadHTMLReport() {
+		return
+	}
+	for _, scan := range scans {
+		if scan.HTMLReport == "" {
+			continue
+		}
+		log := logger.WithField("report_id", logutil.Sanitize(scan.HTMLReport))
+		gz, err := gzipHTMLReport(scan.HTMLReport)
+		if err != nil {
+			log.WithError(err).Warn("Failed to read HTML report")
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		err = httpClient.SendComplianceHTMLReport(ctx, &models.ComplianceHTMLReportInfo{
+			ReportID:    scan.HTMLReport,
+			ProfileName: scan.ProfileName,
+			Hostname:    hostname,
+			MachineID:   machineID,
+		}, gz)
+		cancel()
+		if err != nil {
+			log.WithError(err).Warn("Failed to upload HTML report")
+			continue
+		}
+		log.Debug("Uploaded HTML report")
+	}
+}
+
+func gzipHTMLReport(id string) ([]byte, error) {
+	path, err := compliance.HTMLReportPath(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"patchmon-agent/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// lastComplianceScanFile holds the time of the last completed scheduled
+	// compliance scan, so a slot missed while the agent was down is caught up
+	lastComplianceScanFile = "last_compliance_scan"
+
+	// complianceStartupDelay keeps the first catch-up scan clear of startup work
+	complianceStartupDelay = 30 * time.Second
+	// complianceCatchUpWindow spreads catch-up scans after a fleet-wide restart
+	complianceCatchUpWindow = 15 * time.Minute
+)
+
+// complianceScheduler runs scheduled scans in "enabled" compliance mode. Each
+// host gets a fixed slot within the interval derived from its api_id, so scans
+// are spread across the fleet and a restart doesn't trigger an extra scan.
+type complianceScheduler struct {
+	apiID    string
+	interval time.Duration
+	stopCh   chan struct{}
+	resetCh  chan time.Duration
+}
+
+func newComplianceScheduler(intervalMinutes int, apiID string) *complianceScheduler {
+	return &complianceScheduler{
+		apiID:    apiID,
+		interval: time.Duration(intervalMinutes) * time.Minute,
+		stopCh:   make(chan struct{}),
+		resetCh:  make(chan time.Duration, 1),
+	}
+}
+
+func (cs *complianceScheduler) Start() {
+	go cs.loop()
+}
+
+func (cs *complianceScheduler) Stop() {
+	close(cs.stopCh)
+}
+
+func (cs *complianceScheduler) Reset(intervalMinutes int) {
+	newInterval := time.Duration(intervalMinutes) * time.Minute
+	select {
+	case cs.resetCh <- newInterval:
+	default:
+	}
+}
+
+func (cs *complianceScheduler) offset() time.Duration {
+	return utils.CalculateScanOffset(cs.apiID, cs.interval)
+}
+
+func (cs *complianceScheduler) loop() {
+	now := time.Now()
+	next := firstComplianceRun(now, loadLastComplianceScan(), cs.interval, cs.offset())
+	logger.WithFields(logrus.Fields{
+		"compliance_scan_interval_minutes": int(cs.interval.Minutes()),
+		"next_scan":                        next.Format(time.RFC3339),
+	}).Info("Compliance scheduler started")
+
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-cs.stopCh:
+			logger.Info("Compliance scheduler stopped")
+			return
+		case newInterval := <-cs.resetCh:
+			cs.interval = newInterval
+			next = nextComplianceSlot(time.Now(), cs.interval, cs.offset())
+			timer.Reset(time.Until(next))
+			logger.WithFields(logrus.Fields{
+				"compliance_scan_interval_minutes": int(cs.interval.Minutes()),
+				"next_scan":                        next.Format(time.RFC3339),
+			}).Info("Compliance scan interval updated")
+		case <-timer.C:
+			if runScheduledComplianceScan() {
+				saveLastComplianceScan(time.Now())
+			}
+			next = nextComplianceSlot(time.Now(), cs.interval, cs.offset())
+			timer.Reset(time.Until(next))
+			logger.WithField("next_scan", next.Format(time.RFC3339)).Debug("Next scheduled compliance scan")
+		}
+	}
+}
+
+// nextComplianceSlot returns the host's first slot after now. Slots are
+// interval-aligned wall-clock times shifted by offset, so they stay put across
+// restarts.
+func nextComplianceSlot(now time.Time, interval, offset time.Duration) time.Time {
+	slot := now.Truncate(interval).Add(offset)
+	for !slot.After(now) {
+		slot = slot.Add(interval)
+	}
+	return slot
+}
+
+// firstComplianceRun returns when the first scan after startup should run: the
+// next slot, or soon if the previous slot was missed (or no scan ever ran).
+// Catch-up scans are spread over complianceCatchUpWindow by the same offset.
+func firstComplianceRun(now, lastScan time.Time, interval, offset time.Duration) time.Time {
+	next := nextComplianceSlot(now, interval, offset)
+	previous := next.Add(-interval)
+	if lastScan.Before(previous) {
+		catchUp := now.Add(complianceStartupDelay + offset%complianceCatchUpWindow)
+		if catchUp.Before(next) {
+			return catchUp
+		}
+	}
+	return next
+}
+
+func loadLastComplianceScan() time.Time {
+	data, err := os.ReadFile(cfgManager.StatePath(lastComplianceScanFile))
+	if err != nil {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func saveLastComplianceScan(t time.Time) {
+	if err := utils.WriteFileAtomic(cfgManager.StatePath(lastComplianceScanFile), []byte(t.UTC().Format(time.RFC3339)+"\n"), 0600); err != nil {
+		logger.WithError(err).Debug("Failed to record last compliance scan time")
+	}
+}
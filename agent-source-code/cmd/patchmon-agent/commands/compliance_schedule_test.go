@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextComplianceSlot(t *testing.T) {
+	week := 7 * 24 * time.Hour
+	offset := 50 * time.Hour
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	slot := nextComplianceSlot(now, week, offset)
+	if !slot.After(now) || slot.Sub(now) > week {
+		t.Fatalf("slot %v not within one interval after %v", slot, now)
+	}
+	// Stable across restarts: asking again later in the same interval gives the same slot
+	if again := nextComplianceSlot(now.Add(time.Hour), week, offset); !again.Equal(slot) {
+		t.Fatalf("slot moved from %v to %v", slot, again)
+	}
+	if after := nextComplianceSlot(slot, week, offset); !after.Equal(slot.Add(week)) {
+		t.Fatalf("slot after %v = %v, want one interval later", slot, after)
+	}
+}
+
+func TestFirstComplianceRun(t *testing.T) {
+	week := 7 * 24 * time.Hour
+	offset := 50 * time.Hour
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	next := nextComplianceSlot(now, week, offset)
+
+	// Scanned in the previous slot: wait for the next one
+	if got := firstComplianceRun(now, next.Add(-week).Add(time.Minute), week, offset); !got.Equal(next) {
+		t.Fatalf("got %v, want next slot %v", got, next)
+	}
+
+	// Never scanned: catch up soon, within the startup delay plus the catch-up window
+	got := firstComplianceRun(now, time.Time{}, week, offset)
+	if got.Before(now.Add(complianceStartupDelay)) || got.After(now.Add(complianceStartupDelay+complianceCatchUpWindow)) {
+		t.Fatalf("catch-up at %v, want within %v of startup", got, complianceStartupDelay+complianceCatchUpWindow)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"strings"
 
+	"patchmon-agent/internal/config"
 	"patchmon-agent/internal/pkgversion"
 
 	"github.com/spf13/cobra"
@@ -50,10 +51,40 @@ Example:
 	},
 }
 
+// configProfilesCmd lists the named configuration profiles
+var configProfilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "List named configuration profiles",
+	Long: `List the configuration profiles under ` + config.ProfilesDir() + `.
+
+Select one with --profile on any command, for example:
+  patchmon-agent --profile staging config set-api <API_ID> <API_KEY> <SERVER_URL>
+  patchmon-agent --profile staging serve`,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		names, err := config.ListProfiles()
+		if err != nil {
+			return fmt.Errorf("failed to list profiles: %w", err)
+		}
+		if len(names) == 0 {
+			fmt.Printf("No profiles in %s\n", config.ProfilesDir())
+			return nil
+		}
+		for _, name := range names {
+			marker := " "
+			if name == cfgManager.Profile() {
+				marker = "*"
+			}
+			fmt.Printf("%s %s\n", marker, name)
+		}
+		return nil
+	},
+}
+
 func init() {
 	// Add subcommands to config
 	configCmd.AddCommand(configShowCmd)
 	configCmd.AddCommand(configSetAPICmd)
+	configCmd.AddCommand(configProfilesCmd)
 }
 
 func showConfig() error {
@@ -71,6 +102,9 @@ func showConfig() error {
 		fmt.Printf("  Server: Not configured\n")
 	}
 	fmt.Printf("  Agent Version: %s\n", pkgversion.Version)
+	if profile := cfgManager.Profile(); profile != "" {
+		fmt.Printf("  Profile: %s\n", profile)
+	}
 	fmt.Printf("  Config File: %s\n", cfgManager.GetConfigFile())
 	fmt.Printf("  Credentials File: %s\n", cfg.CredentialsFile)
 	fmt.Printf("  Log File: %s\n", cfg.LogFile)
@@ -4,9 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 	"github.com/spf13/cobra"
-	"patchmon-agent/internal/client"
-	"patchmon-agent/pkg/models"
 )
 
 // pingCmd represents the ping command
@@ -38,12 +37,13 @@ func pingServer() (*models.PingResponse, error) {
 	}
 
 	// Create client and ping
-	httpClient := client.New(cfgManager, logger)
+	httpClient := apiClient()
 	ctx := context.Background()
-	response, err := httpClient.Ping(ctx)
+	response, err := httpClient.Ping(ctx, &models.PingRequest{ClockSkewSeconds: measureClockSkew(ctx, httpClient), LastActions: lastActions()})
 	if err != nil {
 		return nil, fmt.Errorf("connectivity test failed: %w", err)
 	}
+	rememberServerSchema(response.SchemaVersion)
 
 	return response, nil
 }
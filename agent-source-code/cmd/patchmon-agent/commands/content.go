@@ -0,0 +1,333 @@
+package commands
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/config"
+	"patchmon-agent/internal/content"
+	"patchmon-agent/internal/integrations/compliance"
+	"patchmon-agent/internal/pkgversion"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// contentDir holds compliance content brought in with content import
+	contentDir = "content"
+	// contentSigningKeyFile is the Ed25519 key content export signs with
+	contentSigningKeyFile = "content_signing_key"
+)
+
+var (
+	contentSigningKey  string
+	contentNoSSG       bool
+	contentOVAL        []int
+	contentDockerBench bool
+	contentTrustedKeys []string
+)
+
+var contentCmd = &cobra.Command{
+	Use:   "content",
+	Short: "Move compliance content to hosts without network access",
+	Long: `Bundle SSG datastreams, OVAL CVE feeds and Docker Bench assets on a connected
+host, carry the bundle over, and import it on disconnected hosts. Bundles are
+signed; import only accepts those signed by a key in content_trusted_keys or
+given with --trusted-key. The service uses imported content without further
+configuration, unless config.yml names its own cve_feed_url,
+docker_bench_script or docker_bench_image.`,
+}
+
+var contentExportCmd = &cobra.Command{
+	Use:   "export FILE",
+	Short: "Write a signed bundle of this host's compliance content",
+	Long: `Write a signed bundle (tar.gz) with the SSG datastreams OpenSCAP uses here,
+the OVAL feeds for the RHEL versions given with --oval (fetched from
+cve_feed_url), and with --docker-bench the Docker Bench image or
+docker_bench_script checkout.
+
+The bundle is signed with --signing-key, which is created on first use. Add
+the printed public key to content_trusted_keys on the hosts importing it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		if err := checkRoot(); err != nil {
+			return err
+		}
+		applyToolPolicy()
+		keyPath := contentSigningKey
+		if keyPath == "" {
+			keyPath = cfgManager.StatePath(contentSigningKeyFile)
+		}
+		key, created, err := content.LoadOrCreateKey(keyPath)
+		if err != nil {
+			return err
+		}
+		if created {
+			fmt.Printf("🔑 Created signing key %s\n", keyPath)
+		}
+
+		staging, err := os.MkdirTemp("", "patchmon-content-")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = os.RemoveAll(staging) }()
+		hostname, _ := newSystemDetector().GetHostname()
+		m := &content.Manifest{CreatedAt: time.Now().UTC(), CreatedBy: hostname, AgentVersion: pkgversion.Version}
+		if err := stageContent(context.Background(), staging, m); err != nil {
+			return err
+		}
+
+		out, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		err = content.Export(out, staging, m, key)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(args[0])
+			return fmt.Errorf("failed to write bundle: %w", err)
+		}
+		fmt.Printf("✅ Wrote %s (%d files)\n", args[0], len(m.Files))
+		fmt.Printf("   Trust it on the importing hosts with content_trusted_keys: [%s]\n", content.PublicKey(key))
+		return nil
+	},
+}
+
+var contentImportCmd = &cobra.Command{
+	Use:   "import FILE",
+	Short: "Verify and install a bundle written by content export",
+	Long: `Verify a bundle's signature and checksums and install its content in the
+agent's state directory, replacing any earlier import. A Docker Bench image
+in the bundle is loaded into Docker. The running service uses the new
+content within a minute.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		if err := checkRoot(); err != nil {
+			return err
+		}
+		trusted, err := trustedContentKeys(append(cfgManager.GetConfig().ContentTrustedKeys, contentTrustedKeys...))
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer func() { _ = in.Close() }()
+
+		dir := cfgManager.StatePath(contentDir)
+		staged := dir + ".new"
+		_ = os.RemoveAll(staged)
+		m, err := content.Import(in, staged, trusted)
+		if err != nil {
+			return fmt.Errorf("refusing %s: %w", args[0], err)
+		}
+		if err := content.Install(staged, dir); err != nil {
+			_ = os.RemoveAll(staged)
+			return fmt.Errorf("failed to install content: %w", err)
+		}
+		fmt.Printf("✅ Imported content from %s, exported %s\n", m.CreatedBy, m.CreatedAt.Local().Format("2006-01-02 15:04"))
+		printImportedContent(dir, m)
+
+		if m.DockerBenchImage != "" {
+			image := filepath.Join(dir, filepath.FromSlash(content.DockerBenchImageFile))
+			if output, err := compliance.LoadDockerImage(context.Background(), image); err != nil {
+				fmt.Printf("⚠️  Could not load the Docker Bench image: %v\n%s", err, output)
+				fmt.Println("   Import the bundle again once Docker is running.")
+			}
+		}
+		fmt.Println("   The running service uses it within a minute.")
+		return nil
+	},
+}
+
+func init() {
+	contentExportCmd.Flags().StringVar(&contentSigningKey, "signing-key", "", "Ed25519 signing key, created if missing (default content_signing_key next to the config file)")
+	contentExportCmd.Flags().BoolVar(&contentNoSSG, "no-ssg", false, "leave out the SSG datastreams")
+	contentExportCmd.Flags().IntSliceVar(&contentOVAL, "oval", nil, "RHEL major versions whose OVAL feeds to include, e.g. 8,9")
+	contentExportCmd.Flags().BoolVar(&contentDockerBench, "docker-bench", false, "include the Docker Bench image, or the docker_bench_script checkout")
+	contentImportCmd.Flags().StringSliceVar(&contentTrustedKeys, "trusted-key", nil, "public key to accept in addition to content_trusted_keys")
+	contentCmd.AddCommand(contentExportCmd)
+	contentCmd.AddCommand(contentImportCmd)
+	rootCmd.AddCommand(contentCmd)
+}
+
+// stageContent gathers what export bundles into staging, laid out as the
+// bundle is
+func stageContent(ctx context.Context, staging string, m *content.Manifest) error {
+	if !contentNoSSG {
+		files := compliance.SSGDatastreams()
+		if len(files) == 0 {
+			return fmt.Errorf("no SSG datastreams found; install scap-security-guide or pass --no-ssg")
+		}
+		for _, f := range files {
+			if err := copyContentFile(f, filepath.Join(staging, content.SSGDir, filepath.Base(f))); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, major := range contentOVAL {
+		dest := filepath.Join(staging, content.OVALDir, filepath.FromSlash(compliance.CVEFeedPath(major)))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		out, err := os.Create(dest)
+		if err != nil {
+			return err
+		}
+		err = compliance.DownloadCVEFeedArchive(ctx, major, out)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch the RHEL %d OVAL feed: %w", major, err)
+		}
+	}
+
+	if !contentDockerBench {
+		return nil
+	}
+	if script := compliance.DockerBenchScript(); script != "" {
+		src := filepath.Dir(script)
+		if err := copyContentTree(src, filepath.Join(staging, filepath.FromSlash(content.DockerBenchScriptDir))); err != nil {
+			return fmt.Errorf("failed to copy the Docker Bench checkout: %w", err)
+		}
+		m.DockerBenchScript = content.DockerBenchScriptDir + "/" + filepath.Base(script)
+		return nil
+	}
+	image := compliance.DockerBenchImage()
+	dest := filepath.Join(staging, filepath.FromSlash(content.DockerBenchImageFile))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if output, err := compliance.SaveDockerImage(ctx, image, dest); err != nil {
+		return fmt.Errorf("failed to save %s (pull it first): %w\n%s", image, err, output)
+	}
+	m.DockerBenchImage = image
+	return nil
+}
+
+// copyContentTree copies the regular files under src to dest, skipping
+// dotfiles such as .git
+func copyContentTree(src, dest string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != src && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		return copyContentFile(p, filepath.Join(dest, rel))
+	})
+}
+
+func copyContentFile(src, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func trustedContentKeys(encoded []string) ([]ed25519.PublicKey, error) {
+	keys := make([]ed25519.PublicKey, 0, len(encoded))
+	for _, s := range encoded {
+		key, err := content.ParsePublicKey(s)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no trusted content keys: add the exporting host's key to content_trusted_keys or pass --trusted-key")
+	}
+	return keys, nil
+}
+
+func printImportedContent(dir string, m *content.Manifest) {
+	if m.Has(content.SSGDir) {
+		fmt.Printf("   SSG datastreams: %s\n", filepath.Join(dir, content.SSGDir))
+	}
+	if m.Has(content.OVALDir) {
+		fmt.Printf("   OVAL feeds:      %s\n", filepath.Join(dir, content.OVALDir))
+	}
+	if m.DockerBenchScript != "" {
+		fmt.Printf("   Docker Bench:    %s\n", filepath.Join(dir, filepath.FromSlash(m.DockerBenchScript)))
+	} else if m.DockerBenchImage != "" {
+		fmt.Printf("   Docker Bench:    %s\n", m.DockerBenchImage)
+	}
+}
+
+// applyImportedContent points the scanners at imported content. Settings in
+// config.yml win over it.
+func applyImportedContent(cfg *models.Config, feed *compliance.CVEFeedSettings) {
+	dir := cfgManager.StatePath(contentDir)
+	m, err := content.Load(dir)
+	if err != nil {
+		logger.WithError(err).Warn("Ignoring imported compliance content")
+	}
+	if m == nil {
+		compliance.SetImportedSSGDir("")
+		return
+	}
+	if m.Has(content.SSGDir) {
+		compliance.SetImportedSSGDir(filepath.Join(dir, content.SSGDir))
+	} else {
+		compliance.SetImportedSSGDir("")
+	}
+	if cfg.CVEFeedURL == "" && m.Has(content.OVALDir) {
+		feed.URL = filepath.Join(dir, content.OVALDir)
+	}
+	if cfg.DockerBenchScript == "" && m.DockerBenchScript != "" {
+		compliance.SetDockerBenchScript(filepath.Join(dir, filepath.FromSlash(m.DockerBenchScript)))
+	}
+	if cfg.DockerBenchImage == "" && m.DockerBenchImage != "" && config.ValidImageRef(m.DockerBenchImage) {
+		compliance.SetDockerBenchImage(m.DockerBenchImage)
+	}
+}
+
+// importedContentStamp changes with every import, so serve notices one
+func importedContentStamp() time.Time {
+	info, err := os.Stat(filepath.Join(cfgManager.StatePath(contentDir), content.ManifestFile))
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
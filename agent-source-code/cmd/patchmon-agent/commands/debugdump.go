@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/logutil"
+)
+
+const (
+	// debugDumpDir holds goroutine dumps, next to config.yml
+	debugDumpDir = "debug"
+	// debugDumpKeep is how many dumps are kept; older ones are removed
+	debugDumpKeep = 5
+)
+
+// debugQueues reports the depth of serve's channels by name; serve registers
+// them as it creates them
+var (
+	debugQueuesMu sync.Mutex
+	debugQueues   = map[string]func() models.QueueDepth{}
+)
+
+func registerDebugQueue[T any](name string, ch chan T) {
+	debugQueuesMu.Lock()
+	defer debugQueuesMu.Unlock()
+	debugQueues[name] = func() models.QueueDepth { return models.QueueDepth{Len: len(ch), Cap: cap(ch)} }
+}
+
+// debugSummary counts goroutines, sessions, jobs and queued messages
+func debugSummary() models.DebugSummary {
+	summary := models.DebugSummary{
+		At:                 time.Now(),
+		Goroutines:         runtime.NumGoroutine(),
+		WebSocketConnected: currentWsConn() != nil,
+		Jobs:               len(jobs.list("")),
+		Queues:             map[string]models.QueueDepth{},
+		Resources:          agentResources.sample(),
+	}
+	sshProxySessionsMu.RLock()
+	summary.SSHSessions = len(sshProxySessions)
+	sshProxySessionsMu.RUnlock()
+	rdpProxySessionsMu.RLock()
+	summary.RDPSessions = len(rdpProxySessions)
+	rdpProxySessionsMu.RUnlock()
+
+	debugQueuesMu.Lock()
+	for name, depth := range debugQueues {
+		summary.Queues[name] = depth()
+	}
+	debugQueuesMu.Unlock()
+	return summary
+}
+
+// writeDebugDump writes the summary and every goroutine's stack to a new file
+// in the debug directory and logs the summary
+func writeDebugDump() (models.DebugSummary, string, error) {
+	summary := debugSummary()
+	fields := logrus.Fields{
+		"goroutines":   summary.Goroutines,
+		"ssh_sessions": summary.SSHSessions,
+		"rdp_sessions": summary.RDPSessions,
+		"jobs":         summary.Jobs,
+	}
+	for name, q := range summary.Queues {
+		fields["queue_"+name] = fmt.Sprintf("%d/%d", q.Len, q.Cap)
+	}
+	logger.WithFields(fields).Info("Debug dump")
+
+	var buf bytes.Buffer
+	header, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return summary, "", err
+	}
+	buf.Write(header)
+	buf.WriteString("\n\n")
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 2); err != nil {
+		return summary, "", fmt.Errorf("failed to collect goroutine stacks: %w", err)
+	}
+
+	dir := cfgManager.StatePath(debugDumpDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return summary, "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	path := filepath.Join(dir, "goroutines-"+summary.At.UTC().Format("20060102T150405Z")+".txt")
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return summary, "", fmt.Errorf("failed to write debug dump: %w", err)
+	}
+	pruneDebugDumps(dir)
+	logger.WithField("file", path).Info("Goroutine stacks written")
+	return summary, path, nil
+}
+
+// pruneDebugDumps keeps the newest debugDumpKeep dumps. The names sort by
+// time.
+func pruneDebugDumps(dir string) {
+	paths, err := filepath.Glob(filepath.Join(dir, "goroutines-*.txt"))
+	if err != nil || len(paths) <= debugDumpKeep {
+		return
+	}
+	slices.Sort(paths)
+	for _, path := range paths[:len(paths)-debugDumpKeep] {
+		_ = os.Remove(path)
+	}
+}
+
+// handleDebugDumpCommand answers a debug_dump WebSocket message with the
+// summary and where the stacks were written
+func handleDebugDumpCommand(conn *websocket.Conn, commandID string) {
+	summary, path, err := writeDebugDump()
+	reply := models.DebugDumpReply{Type: "debug_dump", CommandID: commandID, Summary: summary, File: path}
+	if err != nil {
+		logger.WithError(err).Warn("Debug dump failed")
+		reply.Error = err.Error()
+	}
+	if err := sendWSMessage(conn, reply); err != nil {
+		logger.WithError(err).WithField("command_id", logutil.Sanitize(commandID)).Debug("Failed to send debug dump reply")
+	}
+}
+
+// runDebugSignalHandler writes a debug dump on SIGUSR1 (not on Windows)
+func runDebugSignalHandler(ctx context.Context) {
+	sigCh := make(chan os.Signal, 1)
+	if !notifyDebugSignal(sigCh) {
+		return
+	}
+	defer stopDebugSignal(sigCh)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigCh:
+			if _, _, err := writeDebugDump(); err != nil {
+				logger.WithError(err).Warn("Debug dump failed")
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"patchmon-agent/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestWriteDebugDump(t *testing.T) {
+	cfgManager = config.New()
+	cfgManager.SetConfigFile(filepath.Join(t.TempDir(), "config.yml"))
+	logger = logrus.New()
+	logger.SetOutput(io.Discard)
+
+	queue := make(chan int, 4)
+	queue <- 1
+	registerDebugQueue("test_queue", queue)
+
+	summary, path, err := writeDebugDump()
+	if err != nil {
+		t.Fatalf("writeDebugDump: %v", err)
+	}
+	if q := summary.Queues["test_queue"]; q.Len != 1 || q.Cap != 4 {
+		t.Errorf("test_queue depth = %+v, want 1/4", q)
+	}
+	if summary.Goroutines == 0 {
+		t.Error("no goroutines counted")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "TestWriteDebugDump") {
+		t.Error("dump has no stack for the test's own goroutine")
+	}
+}
+
+func TestPruneDebugDumps(t *testing.T) {
+	dir := t.TempDir()
+	for i := range 8 {
+		name := fmt.Sprintf("goroutines-20261001T00000%dZ.txt", i)
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pruneDebugDumps(dir)
+	left, _ := filepath.Glob(filepath.Join(dir, "goroutines-*.txt"))
+	if len(left) != debugDumpKeep || filepath.Base(left[0]) != "goroutines-20261001T000003Z.txt" {
+		t.Errorf("kept %v, want the newest %d", left, debugDumpKeep)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package commands
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func notifyDebugSignal(ch chan<- os.Signal) bool {
+	signal.Notify(ch, syscall.SIGUSR1)
+	return true
+}
+
+func stopDebugSignal(ch chan<- os.Signal) {
+	signal.Stop(ch)
+}
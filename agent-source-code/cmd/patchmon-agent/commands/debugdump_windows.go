@@ -0,0 +1,10 @@
+//go:build windows
+
+package commands
+
+import "os"
+
+// Windows has no SIGUSR1; use the debug_dump WebSocket command instead
+func notifyDebugSignal(chan<- os.Signal) bool { return false }
+
+func stopDebugSignal(chan<- os.Signal) {}
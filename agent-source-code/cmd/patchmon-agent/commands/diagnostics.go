@@ -2,14 +2,18 @@ package commands
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/client"
+	"patchmon-agent/internal/connectivity"
 	"patchmon-agent/internal/pkgversion"
-	"patchmon-agent/internal/system"
 	"patchmon-agent/internal/utils"
 
 	"github.com/spf13/cobra"
@@ -21,10 +25,21 @@ var diagnosticsCmd = &cobra.Command{
 	Short: "Show detailed system diagnostics",
 	Long:  "Display comprehensive diagnostic information about the agent, system, and configuration.",
 	RunE: func(_ *cobra.Command, _ []string) error {
+		if diagnosticsFixPermissions {
+			if err := checkRoot(); err != nil {
+				return err
+			}
+		}
 		return showDiagnostics()
 	},
 }
 
+var diagnosticsFixPermissions bool
+
+func init() {
+	diagnosticsCmd.Flags().BoolVar(&diagnosticsFixPermissions, "fix-permissions", false, "correct unsafe owners and modes of the agent's files")
+}
+
 func showDiagnostics() error {
 	cfg := cfgManager.GetConfig()
 
@@ -33,7 +48,7 @@ func showDiagnostics() error {
 	// System Information
 	fmt.Printf("System Information:\n")
 
-	systemDetector := system.New(logger)
+	systemDetector := newSystemDetector()
 
 	osType, osVersion, err := systemDetector.DetectOS()
 	if err != nil {
@@ -47,13 +62,24 @@ func showDiagnostics() error {
 	kernelVersion := systemDetector.GetKernelVersion()
 	fmt.Printf("  Kernel: %s\n", kernelVersion)
 
-	if hostname, err := os.Hostname(); err == nil {
+	if hostname, err := systemDetector.GetHostname(); err == nil {
 		fmt.Printf("  Hostname: %s\n", hostname)
+		if cfg.HostnameOverride != "" {
+			fmt.Printf("    (from hostname_override)\n")
+		} else if cfg.UseFQDN {
+			fmt.Printf("    (fully qualified, use_fqdn)\n")
+		}
 	}
 
 	// Show machine ID
 	machineID := systemDetector.GetMachineID()
 	fmt.Printf("  Machine ID: %s\n", machineID)
+	if localMachineIdentity() != "" {
+		fmt.Printf("    (from identity reset)\n")
+	}
+	if conflict := loadRegistrationConflict(); conflict != nil && conflict.MachineID == machineID {
+		fmt.Printf("  ❌ %s", conflict.describe())
+	}
 
 	fmt.Printf("\n")
 
@@ -81,16 +107,74 @@ func showDiagnostics() error {
 	}
 	fmt.Printf("\n")
 
+	printFilePermissions(diagnosticsFixPermissions)
+	fmt.Printf("\n")
+
 	// Network Connectivity & API Credentials
 	fmt.Printf("Network Connectivity & API Credentials:\n")
 	fmt.Printf("  Server URL: %s\n", cfg.PatchmonServer)
 
 	// Basic network connectivity test
-	serverHost, serverPort := extractURLHostAndPort(cfg.PatchmonServer)
-	if isReachable := utils.TCPPing(serverHost, serverPort); isReachable {
-		fmt.Printf("  ✅ Server is reachable\n")
+	if cfg.RelayURL != "" {
+		fmt.Printf("  Relay URL: %s\n", cfg.RelayURL)
+		printRelayReachable(cfg)
 	} else {
-		fmt.Printf("  ❌ Server is not reachable\n")
+		serverHost, serverPort := extractURLHostAndPort(cfg.PatchmonServer)
+		if isReachable := utils.TCPPing(serverHost, serverPort); isReachable {
+			fmt.Printf("  ✅ Server is reachable\n")
+		} else {
+			fmt.Printf("  ❌ Server is not reachable\n")
+		}
+		if cfg.ClientTLS != nil {
+			if _, err := client.ServerTLSConfig(cfg, false); err != nil {
+				fmt.Printf("  ❌ Client certificate is unusable: %v\n", err)
+			} else {
+				fmt.Printf("  ✅ Client certificate loaded: %s\n", cfg.ClientTLS.CertFile)
+			}
+		}
+	}
+
+	// Payload types routed elsewhere by the endpoints map
+	endpointNames := make([]string, 0, len(cfg.Endpoints))
+	for name := range cfg.Endpoints {
+		endpointNames = append(endpointNames, name)
+	}
+	sort.Strings(endpointNames)
+	for _, name := range endpointNames {
+		endpointURL := cfg.Endpoints[name]
+		host, port := extractURLHostAndPort(endpointURL)
+		if utils.TCPPing(host, port) {
+			fmt.Printf("  ✅ %s endpoint is reachable: %s\n", name, endpointURL)
+		} else {
+			fmt.Printf("  ❌ %s endpoint is not reachable: %s\n", name, endpointURL)
+		}
+	}
+
+	// DNS and transport self-test
+	if target, ok := connectivityTarget(); ok {
+		printConnectivityCheck(newConnectivityChecker().Check(context.Background(), target))
+	}
+
+	// Clock skew against the server's Date header
+	if skew, err := apiClient().GetClockSkew(context.Background()); err != nil {
+		fmt.Printf("  ❌ Clock skew could not be measured: %v\n", err)
+	} else if skew.Abs() >= utils.ClockSkewWarnThreshold {
+		fmt.Printf("  ❌ Clock skew vs server: %s (check NTP)\n", skew)
+	} else {
+		fmt.Printf("  ✅ Clock skew vs server: %s\n", skew)
+	}
+
+	if state := loadPause(); state != nil {
+		fmt.Printf("  ⏸️  Paused until %s (by %s)", state.Until.Local().Format("2006-01-02 15:04:05"), state.Source)
+		if state.Reason != "" {
+			fmt.Printf(": %s", state.Reason)
+		}
+		fmt.Printf("\n")
+	}
+
+	// Most recent watchdog incident, if any
+	if incidents := loadWatchdogIncidents(); len(incidents) > 0 {
+		printWatchdogIncident(incidents[len(incidents)-1])
 	}
 
 	// API credentials and server connectivity test
@@ -124,22 +208,87 @@ func showDiagnostics() error {
 	return nil
 }
 
-// extractURLHostAndPort extracts the host and port from a URL string
-func extractURLHostAndPort(url string) (host string, port string) {
-	trimmed := strings.TrimPrefix(url, "http://")
-	trimmed = strings.TrimPrefix(trimmed, "https://")
-	hostPort := strings.SplitN(trimmed, "/", 2)[0]
-	if strings.Contains(hostPort, ":") {
-		parts := strings.SplitN(hostPort, ":", 2)
-		host = parts[0]
-		port = parts[1]
-	} else {
-		host = hostPort
-		if strings.HasPrefix(url, "https://") {
-			port = "443"
+// printConnectivityCheck prints the DNS / large request self-test results
+func printConnectivityCheck(r *models.ConnectivityCheck) {
+	if r.ServerHost == "" {
+		for _, p := range r.Problems {
+			fmt.Printf("  ❌ %s\n", p)
+		}
+		return
+	}
+	if !r.HostIsIP {
+		if r.SystemDNSOK {
+			fmt.Printf("  ✅ System DNS resolves %s: %s\n", r.ServerHost, strings.Join(r.SystemDNSAddrs, ", "))
+		} else {
+			fmt.Printf("  ❌ System DNS cannot resolve %s: %s\n", r.ServerHost, r.SystemDNSError)
+		}
+		if r.FallbackDNSOK {
+			fmt.Printf("  ✅ Fallback DNS (%s) resolves %s: %s\n", r.FallbackResolver, r.ServerHost, strings.Join(r.FallbackDNSAddrs, ", "))
 		} else {
-			port = "80"
+			fmt.Printf("  ❌ Fallback DNS (%s) cannot resolve %s: %s\n", r.FallbackResolver, r.ServerHost, r.FallbackDNSError)
 		}
+		if r.DoHFallbackOK {
+			fmt.Printf("  ✅ DNS-over-HTTPS fallback resolves %s: %s\n", r.ServerHost, strings.Join(r.DoHFallbackAddrs, ", "))
+		} else if r.DoHFallbackError != "" {
+			fmt.Printf("  ❌ DNS-over-HTTPS fallback cannot resolve %s: %s\n", r.ServerHost, r.DoHFallbackError)
+		}
+	}
+	for _, f := range r.AddressFamilies {
+		printAddressFamily(f)
+	}
+	if r.TCPReachable {
+		if r.LargeRequestOK {
+			fmt.Printf("  ✅ Large padded request succeeded (no MTU/TLS middlebox issues detected)\n")
+		} else {
+			fmt.Printf("  ❌ Large padded request failed: %s\n", r.LargeRequestErr)
+		}
+	}
+	for _, p := range r.Problems {
+		fmt.Printf("  ⚠️  %s\n", p)
+	}
+}
+
+func printWatchdogIncident(inc models.WatchdogIncident) {
+	status := "⚠️  unresolved"
+	if inc.ResolvedAt != nil {
+		status = "✅ resolved " + inc.ResolvedAt.Local().Format("2006-01-02 15:04:05")
+	}
+	fmt.Printf("  Last watchdog incident: %s (%s)\n", inc.StartedAt.Local().Format("2006-01-02 15:04:05"), status)
+	fmt.Printf("    Reason: %s\n", inc.Reason)
+	if len(inc.Actions) > 0 {
+		fmt.Printf("    Actions: %s\n", strings.Join(inc.Actions, ", "))
+	}
+}
+
+// printAddressFamily prints one address family of the connectivity self-test
+func printAddressFamily(f models.AddressFamilyCheck) {
+	name := "IPv4"
+	if f.Family == "ipv6" {
+		name = "IPv6"
+	}
+	route := "no route on this host"
+	if f.Routed {
+		route = "routed"
+	}
+	switch {
+	case len(f.Addrs) == 0:
+		fmt.Printf("  ➖ %s: server has no address (%s)\n", name, route)
+	case f.Reachable:
+		fmt.Printf("  ✅ %s: server reachable at %s\n", name, f.Addrs[0])
+	default:
+		fmt.Printf("  ❌ %s: server not reachable at %s (%s): %s\n", name, f.Addrs[0], route, f.Error)
+	}
+}
+
+// extractURLHostAndPort extracts the host and port from a URL string. IPv6
+// literals come back without brackets, ready for net.JoinHostPort.
+func extractURLHostAndPort(rawURL string) (host string, port string) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	host, port, err := connectivity.SplitServerURL(rawURL)
+	if err != nil {
+		return "", ""
 	}
 	return host, port
 }
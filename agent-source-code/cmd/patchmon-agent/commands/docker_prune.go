@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/integrations/docker"
+	"patchmon-agent/internal/logutil"
+	"patchmon-agent/internal/pkgversion"
+)
+
+const (
+	// dockerPruneTimeout bounds a prune; removing many images can be slow
+	dockerPruneTimeout = 30 * time.Minute
+	// maxPruneAgeDays caps older_than_days
+	maxPruneAgeDays = 3650
+)
+
+// dockerPruneAge is the age a stopped container must have to be pruned: the
+// command's older_than_days, else docker_prune.container_age_days, else the
+// default
+func dockerPruneAge(days int) time.Duration {
+	if days <= 0 {
+		if cfg := cfgManager.GetConfig().DockerPrune; cfg != nil && cfg.ContainerAgeDays > 0 {
+			days = cfg.ContainerAgeDays
+		}
+	}
+	if days <= 0 {
+		return docker.DefaultPruneContainerAge
+	}
+	return time.Duration(min(days, maxPruneAgeDays)) * 24 * time.Hour
+}
+
+// pruneDocker answers docker_prune: it removes old stopped containers,
+// unused networks and dangling images, or on a dry run lists them, and sends
+// the result to the server
+func pruneDocker(ctx context.Context, olderThanDays int, dryRun bool, commandID string) error {
+	if !cfgManager.IsIntegrationEnabled("docker") {
+		return fmt.Errorf("docker integration is not enabled")
+	}
+	if cfg := cfgManager.GetConfig().DockerPrune; !dryRun && (cfg == nil || !cfg.Enabled) {
+		return fmt.Errorf("docker_prune is not enabled in config.yml (dry runs are allowed)")
+	}
+	dockerInteg := docker.New(logger)
+	if !dockerInteg.IsAvailable() {
+		return fmt.Errorf("docker is not available on this system")
+	}
+	defer func() { _ = dockerInteg.Close() }()
+
+	pruneCtx, cancel := context.WithTimeout(ctx, dockerPruneTimeout)
+	defer cancel()
+	result := dockerInteg.Prune(pruneCtx, dockerPruneAge(olderThanDays), dryRun)
+	result.CommandID = commandID
+	logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
+		"dry_run":         dryRun,
+		"containers":      len(result.Containers),
+		"images":          len(result.Images),
+		"networks":        len(result.Networks),
+		"reclaimed_bytes": result.ReclaimedBytes,
+	})).Info("Docker prune finished")
+
+	detector := newSystemDetector()
+	hostname, _ := detector.GetHostname()
+	sendCtx, sendCancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer sendCancel()
+	if _, err := commandClient(ctx).SendDockerPrune(sendCtx, &models.DockerPrunePayload{
+		DockerPruneResult: *result,
+		Hostname:          hostname,
+		MachineID:         detector.GetMachineID(),
+		AgentVersion:      pkgversion.Version,
+	}); err != nil {
+		logger.WithError(err).Warn("Failed to send Docker prune result")
+	}
+
+	if !dryRun && len(result.Containers)+len(result.Images)+len(result.Networks) > 0 {
+		refreshDockerInventory(ctx)
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("docker prune incomplete: %s", strings.Join(result.Errors, "; "))
+	}
+	return nil
+}
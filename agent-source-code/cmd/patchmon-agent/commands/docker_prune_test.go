@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/config"
+	"patchmon-agent/internal/integrations/docker"
+)
+
+func TestDockerPruneAge(t *testing.T) {
+	cfgManager = config.New()
+	cfgManager.SetConfigFile(filepath.Join(t.TempDir(), "config.yml"))
+
+	if got := dockerPruneAge(0); got != docker.DefaultPruneContainerAge {
+		t.Errorf("dockerPruneAge(0) without config = %v, want the default", got)
+	}
+	cfgManager.GetConfig().DockerPrune = &models.DockerPruneConfig{Enabled: true, ContainerAgeDays: 30}
+	if got := dockerPruneAge(0); got != 30*24*time.Hour {
+		t.Errorf("dockerPruneAge(0) = %v, want container_age_days", got)
+	}
+	if got := dockerPruneAge(2); got != 2*24*time.Hour {
+		t.Errorf("dockerPruneAge(2) = %v, want the command's age", got)
+	}
+}
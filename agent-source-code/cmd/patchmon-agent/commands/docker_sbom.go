@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/client"
+	"patchmon-agent/internal/integrations/docker"
+	"patchmon-agent/internal/logutil"
+	"patchmon-agent/internal/utils"
+)
+
+const (
+	// sbomStateFile records which image IDs already have an SBOM on the server
+	sbomStateFile = "sbom_uploaded.json"
+	// sbomRefreshAge re-uploads old SBOMs occasionally in case the server lost them;
+	// image IDs are content addresses, so the SBOM itself never changes
+	sbomRefreshAge = 30 * 24 * time.Hour
+)
+
+// uploadImageSBOMs generates and uploads SBOMs for images not uploaded recently.
+// Runs sequentially: SBOM tools are CPU and IO heavy and this is background work.
+func uploadImageSBOMs(httpClient *client.Client, dockerData *models.DockerData, hostname, machineID string) {
+	generator := docker.NewSBOMGenerator(logger)
+	if generator == nil {
+		logger.Warn("docker_sbom is enabled but neither syft nor trivy is installed, skipping SBOM upload")
+		return
+	}
+
+	statePath := cfgManager.StatePath(sbomStateFile)
+	uploaded := loadImageTimes(statePath)
+	count := syncImageSBOMs(dockerData.Images, uploaded, func(img models.DockerImage) error {
+		ref := img.Repository + ":" + img.Tag
+		// Generate is bounded by docker.SBOMTimeout; the upload gets its own time
+		gz, err := generator.Generate(context.Background(), ref, img.EngineRef)
+		if err != nil {
+			logger.WithError(err).WithField("image", logutil.Sanitize(ref)).Warn("SBOM generation failed")
+			return err
+		}
+		err = httpClient.SendImageSBOM(context.Background(), &models.ImageSBOMInfo{
+			ImageID:    img.ImageID,
+			Repository: img.Repository,
+			Tag:        img.Tag,
+			Digest:     img.Digest,
+			Format:     docker.SBOMFormat,
+			Tool:       generator.Tool(),
+			Hostname:   hostname,
+			MachineID:  machineID,
+		}, gz)
+		if err != nil {
+			logger.WithError(err).WithField("image", logutil.Sanitize(ref)).Warn("SBOM upload failed (will retry on next report)")
+		}
+		return err
+	})
+
+	if err := saveImageTimes(statePath, uploaded); err != nil {
+		logger.WithError(err).Debug("Failed to save SBOM upload state")
+	}
+	if count > 0 {
+		logger.WithField("count", count).Info("Uploaded image SBOMs")
+	}
+}
+
+// syncImageSBOMs calls upload once for each image ID in images not uploaded
+// within sbomRefreshAge and records those that succeed in uploaded, which is
+// first pruned of images no longer present. It returns how many were uploaded.
+func syncImageSBOMs(images []models.DockerImage, uploaded map[string]time.Time, upload func(models.DockerImage) error) int {
+	// Prune entries for images that no longer exist so the state file stays small
+	present := make(map[string]bool, len(images))
+	for _, img := range images {
+		present[img.ImageID] = true
+	}
+	for id := range uploaded {
+		if !present[id] {
+			delete(uploaded, id)
+		}
+	}
+
+	done := make(map[string]bool)
+	count := 0
+	for _, img := range images {
+		if img.ImageID == "" || done[img.ImageID] {
+			continue
+		}
+		done[img.ImageID] = true
+		if at, ok := uploaded[img.ImageID]; ok && time.Since(at) < sbomRefreshAge {
+			continue
+		}
+		if upload(img) != nil {
+			continue
+		}
+		uploaded[img.ImageID] = time.Now()
+		count++
+	}
+	return count
+}
+
+// loadImageTimes reads a state file mapping image IDs to when something was
+// last done for them
+func loadImageTimes(path string) map[string]time.Time {
+	state := make(map[string]time.Time)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return make(map[string]time.Time)
+	}
+	return state
+}
+
+func saveImageTimes(path string, state map[string]time.Time) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(path, data, 0600)
+}
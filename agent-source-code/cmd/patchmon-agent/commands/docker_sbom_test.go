@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+func TestSyncImageSBOMs(t *testing.T) {
+	recent := time.Now().Add(-time.Hour)
+	uploaded := map[string]time.Time{
+		"sha256:gone":   recent,                                      // image removed since
+		"sha256:recent": recent,                                      // uploaded within sbomRefreshAge
+		"sha256:stale":  time.Now().Add(-sbomRefreshAge - time.Hour), // due for a refresh
+	}
+	images := []models.DockerImage{
+		{Repository: "nginx", Tag: "1.27", ImageID: "sha256:new"},
+		{Repository: "nginx", Tag: "latest", ImageID: "sha256:new"}, // same image, another tag
+		{Repository: "redis", Tag: "7", ImageID: "sha256:recent"},
+		{Repository: "postgres", Tag: "16", ImageID: "sha256:stale"},
+		{Repository: "broken", Tag: "1", ImageID: "sha256:fails"},
+		{Repository: "dangling", Tag: "<none>"},
+	}
+
+	var calls []string
+	count := syncImageSBOMs(images, uploaded, func(img models.DockerImage) error {
+		calls = append(calls, img.Repository+":"+img.Tag)
+		if img.ImageID == "sha256:fails" {
+			return errors.New("syft failed")
+		}
+		return nil
+	})
+
+	if want := []string{"nginx:1.27", "postgres:16", "broken:1"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("uploaded %v, want %v", calls, want)
+	}
+	if count != 2 {
+		t.Fatalf("count = %d, want 2", count)
+	}
+	if _, ok := uploaded["sha256:gone"]; ok {
+		t.Fatal("expected the removed image to be pruned from the state")
+	}
+	if _, ok := uploaded["sha256:fails"]; ok {
+		t.Fatal("expected a failed upload not to be recorded, so it is retried")
+	}
+	if !uploaded["sha256:recent"].Equal(recent) {
+		t.Fatal("expected the recent upload to be left alone")
+	}
+	if time.Since(uploaded["sha256:stale"]) > time.Minute || time.Since(uploaded["sha256:new"]) > time.Minute {
+		t.Fatalf("expected refreshed and new uploads to be recorded now, got %v", uploaded)
+	}
+}
+
+func TestImageTimesRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), sbomStateFile)
+	at := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	if err := saveImageTimes(path, map[string]time.Time{"sha256:a": at}); err != nil {
+		t.Fatalf("saveImageTimes: %v", err)
+	}
+	if got := loadImageTimes(path); !got["sha256:a"].Equal(at) || len(got) != 1 {
+		t.Fatalf("loadImageTimes = %v", got)
+	}
+	if got := loadImageTimes(filepath.Join(t.TempDir(), "missing.json")); len(got) != 0 {
+		t.Fatalf("expected an empty state for a missing file, got %v", got)
+	}
+}
@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/integrations/docker"
+	"patchmon-agent/internal/pkgversion"
+)
+
+// dockerUpdateTimeout bounds a container update: the pull, the recreate and
+// the wait for the new container to become healthy
+const dockerUpdateTimeout = 30 * time.Minute
+
+// dockerUpdatePolicy returns the docker_update allowlist from config.yml, or
+// nil when none is configured
+func dockerUpdatePolicy() *docker.UpdatePolicy {
+	cfg := cfgManager.GetConfig().DockerUpdate
+	if cfg == nil || (len(cfg.Containers) == 0 && len(cfg.Labels) == 0) {
+		return nil
+	}
+	policy := &docker.UpdatePolicy{
+		Containers:    cfg.Containers,
+		Labels:        cfg.Labels,
+		HealthTimeout: docker.DefaultUpdateHealthTimeout,
+	}
+	if cfg.HealthTimeout > 0 {
+		policy.HealthTimeout = time.Duration(cfg.HealthTimeout) * time.Second
+	}
+	return policy
+}
+
+// updateDockerContainer answers docker_update_container: it pulls the
+// container's image and recreates the container from it if it changed,
+// rolling back if the new container doesn't come up healthy. The outcome is
+// sent to the server whatever it is.
+func updateDockerContainer(ctx context.Context, name, commandID string) error {
+	if !cfgManager.IsIntegrationEnabled("docker") {
+		return fmt.Errorf("docker integration is not enabled")
+	}
+	policy := dockerUpdatePolicy()
+	if policy == nil {
+		return fmt.Errorf("no containers are allowed to be updated (docker_update is not configured)")
+	}
+	dockerInteg := docker.New(logger)
+	if !dockerInteg.IsAvailable() {
+		return fmt.Errorf("docker is not available on this system")
+	}
+	defer func() { _ = dockerInteg.Close() }()
+
+	updateCtx, cancel := context.WithTimeout(ctx, dockerUpdateTimeout)
+	defer cancel()
+	result := dockerInteg.UpdateContainer(updateCtx, name, *policy)
+	result.CommandID = commandID
+
+	detector := newSystemDetector()
+	hostname, _ := detector.GetHostname()
+	sendCtx, sendCancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer sendCancel()
+	if _, err := commandClient(ctx).SendDockerContainerUpdate(sendCtx, &models.DockerContainerUpdatePayload{
+		DockerContainerUpdateResult: *result,
+		Hostname:                    hostname,
+		MachineID:                   detector.GetMachineID(),
+		AgentVersion:                pkgversion.Version,
+	}); err != nil {
+		logger.WithError(err).Warn("Failed to send Docker container update result")
+	}
+
+	if result.Status == models.ContainerUpdateUpToDate {
+		return nil
+	}
+	if result.Status == models.ContainerUpdateUpdated || result.NewContainer != "" || result.RollbackError != "" {
+		// The container was replaced or touched
+		refreshDockerInventory(ctx)
+	}
+	switch {
+	case result.Status == models.ContainerUpdateUpdated:
+		return nil
+	case result.Status == models.ContainerUpdateRolledBack:
+		return fmt.Errorf("update rolled back: %s", result.Error)
+	case result.RollbackError != "":
+		return fmt.Errorf("%s; rollback failed: %s", result.Error, result.RollbackError)
+	default:
+		return errors.New(result.Error)
+	}
+}
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/pkgversion"
+
+	"github.com/spf13/cobra"
+)
+
+// Enrollment codes are typed by hand, so they are short and case-insensitive;
+// the server makes them single use and expires them quickly
+const (
+	minEnrollCodeLength = 6
+	maxEnrollCodeLength = 16
+)
+
+var (
+	enrollCode   string
+	enrollServer string
+	enrollForce  bool
+)
+
+var enrollCmd = &cobra.Command{
+	Use:   "enroll --code <CODE>",
+	Short: "Enroll this host with a short code from the PatchMon web interface",
+	Long: `Exchange a short-lived enrollment code, shown in the PatchMon web interface
+when adding a host, for this host's API credentials. It saves the server URL
+and credentials like config set-api, without typing the API key by hand.
+
+The code is only sent over https. Dashes and spaces in the code are ignored
+and letters may be typed in either case.
+
+Example:
+  patchmon-agent enroll --code ABC-123 --server https://patchmon.example.com`,
+	Args: cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		if err := checkRoot(); err != nil {
+			return err
+		}
+		return enroll(enrollCode, enrollServer)
+	},
+}
+
+func init() {
+	enrollCmd.Flags().StringVar(&enrollCode, "code", "", "enrollment code shown in the PatchMon web interface")
+	enrollCmd.Flags().StringVar(&enrollServer, "server", "", "PatchMon server URL (default: patchmon_server from config.yml)")
+	enrollCmd.Flags().BoolVar(&enrollForce, "force", false, "replace credentials this host already has")
+	_ = enrollCmd.MarkFlagRequired("code")
+	rootCmd.AddCommand(enrollCmd)
+}
+
+func enroll(code, serverURL string) error {
+	code, err := normalizeEnrollCode(code)
+	if err != nil {
+		return err
+	}
+	if serverURL == "" {
+		serverURL = cfgManager.GetConfig().PatchmonServer
+	}
+	serverURL, err = enrollServerURL(serverURL)
+	if err != nil {
+		return err
+	}
+
+	_ = cfgManager.LoadCredentials()
+	if creds := cfgManager.GetCredentials(); creds != nil && creds.APIID != "" && !enrollForce {
+		return fmt.Errorf("this host already has credentials (API ID %s); pass --force to replace them", creds.APIID)
+	}
+
+	// The client builds request URLs from the config it shares; the new
+	// server is only saved once enrollment has succeeded
+	cfgManager.GetConfig().PatchmonServer = serverURL
+
+	detector := newSystemDetector()
+	hostname, _ := detector.GetHostname()
+	osType, osVersion, _ := detector.DetectOS()
+	req := &models.EnrollRequest{
+		Code:           code,
+		Hostname:       hostname,
+		MachineID:      detector.GetMachineID(),
+		OSType:         osType,
+		OSVersion:      osVersion,
+		Architecture:   detector.GetArchitecture(),
+		AgentVersion:   pkgversion.Version,
+		AgentPublicKey: agentPublicKey(),
+	}
+
+	logger.WithField("server", serverURL).Info("Enrolling with server...")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	resp, err := apiClient().Enroll(ctx, req)
+	if err != nil {
+		return fmt.Errorf("enrollment failed (codes are single use and expire; request a new one if this one was used or is old): %w", err)
+	}
+	if resp.APIID == "" || resp.APIKey == "" {
+		return errors.New("server accepted the code but returned no credentials")
+	}
+
+	return configureCreds(resp.APIID, resp.APIKey, serverURL)
+}
+
+// normalizeEnrollCode uppercases the code and drops the dashes and spaces
+// the UI groups it with
+func normalizeEnrollCode(code string) (string, error) {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+	if len(code) < minEnrollCodeLength || len(code) > maxEnrollCodeLength {
+		return "", fmt.Errorf("enrollment code must be %d to %d letters and digits", minEnrollCodeLength, maxEnrollCodeLength)
+	}
+	for _, r := range code {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return "", fmt.Errorf("enrollment code may only contain letters and digits, got %q", r)
+		}
+	}
+	return code, nil
+}
+
+// enrollServerURL checks the server URL an enrollment code may be sent to.
+// A code is as good as an API key until used, so plain http is refused.
+func enrollServerURL(serverURL string) (string, error) {
+	if serverURL == "" {
+		return "", errors.New("no server configured; pass --server https://patchmon.example.com")
+	}
+	u, err := url.Parse(serverURL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid server URL %q", serverURL)
+	}
+	if u.Scheme != "https" {
+		return "", fmt.Errorf("enrollment codes are only sent over https, not %s; use config set-api for a plain http server", u.Scheme)
+	}
+	return strings.TrimRight(serverURL, "/"), nil
+}
@@ -0,0 +1,32 @@
+package commands
+
+import "testing"
+
+func TestNormalizeEnrollCode(t *testing.T) {
+	for in, want := range map[string]string{
+		"ABC123":       "ABC123",
+		" abc-123 ":    "ABC123",
+		"k7q2 m9x4 p1": "K7Q2M9X4P1",
+	} {
+		if got, err := normalizeEnrollCode(in); err != nil || got != want {
+			t.Errorf("normalizeEnrollCode(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "AB12", "ABC_123", "ABCDEFGHIJKLMNOPQ", "ÄBC123"} {
+		if _, err := normalizeEnrollCode(bad); err == nil {
+			t.Errorf("normalizeEnrollCode(%q) accepted", bad)
+		}
+	}
+}
+
+func TestEnrollServerURL(t *testing.T) {
+	got, err := enrollServerURL("https://patchmon.example.com/")
+	if err != nil || got != "https://patchmon.example.com" {
+		t.Errorf("enrollServerURL = %q, %v", got, err)
+	}
+	for _, bad := range []string{"", "http://patchmon.example.com", "patchmon.example.com", "https://"} {
+		if _, err := enrollServerURL(bad); err == nil {
+			t.Errorf("enrollServerURL(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"fmt"
+
+	"patchmon-agent/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// auditFilePermissions logs agent files other users can read or change and,
+// with fix, corrects them. serve runs it at startup.
+func auditFilePermissions(fix bool) {
+	for _, issue := range cfgManager.AuditFilePermissions() {
+		entry := logger.WithFields(logrus.Fields{"path": issue.Path, "problem": issue.Problem})
+		if !fix {
+			entry.Warnf("Unsafe agent file permissions, fix with %q or set fix_file_permissions: true", issue.Fix)
+			continue
+		}
+		if err := config.FixFilePermission(issue); err != nil {
+			entry.WithError(err).Warn("Failed to fix agent file permissions")
+			continue
+		}
+		entry.WithField("fix", issue.Fix).Info("Fixed agent file permissions")
+	}
+}
+
+// printFilePermissions is the diagnostics section for auditFilePermissions
+func printFilePermissions(fix bool) {
+	fmt.Printf("File Permissions:\n")
+	issues := cfgManager.AuditFilePermissions()
+	if len(issues) == 0 {
+		fmt.Printf("  ✅ Config, credentials, state and log files are owned and protected correctly\n")
+		return
+	}
+	for _, issue := range issues {
+		if !fix {
+			fmt.Printf("  ❌ %s is %s (fix: %s)\n", issue.Path, issue.Problem, issue.Fix)
+			continue
+		}
+		if err := config.FixFilePermission(issue); err != nil {
+			fmt.Printf("  ❌ %s is %s: %v\n", issue.Path, issue.Problem, err)
+		} else {
+			fmt.Printf("  ✅ %s was %s, fixed (%s)\n", issue.Path, issue.Problem, issue.Fix)
+		}
+	}
+	if !fix {
+		fmt.Printf("  Run patchmon-agent diagnostics --fix-permissions as root to fix these\n")
+	}
+}
@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/hardware"
+	"patchmon-agent/internal/pkgversion"
+)
+
+// inventoryBudget is the time budget for a hardware_inventory command: the
+// server's timeout_seconds, capped, or the default when it sent none
+func inventoryBudget(timeoutSeconds int) time.Duration {
+	if timeoutSeconds <= 0 {
+		return hardware.DefaultInventoryBudget
+	}
+	return min(time.Duration(timeoutSeconds)*time.Second, hardware.MaxInventoryBudget)
+}
+
+// uploadHardwareInventory answers hardware_inventory: it runs the deep
+// hardware collection within budget and uploads whatever it gathered, marked
+// incomplete if the budget ran out
+func uploadHardwareInventory(ctx context.Context, budget time.Duration, commandID string) error {
+	inv := hardware.New(logger).CollectInventory(ctx, budget)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	detector := newSystemDetector()
+	hostname, _ := detector.GetHostname()
+	uploadCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	_, err := commandClient(ctx).SendHardwareInventory(uploadCtx, &models.HardwareInventoryPayload{
+		HardwareInventory: *inv,
+		CommandID:         commandID,
+		Hostname:          hostname,
+		MachineID:         detector.GetMachineID(),
+		AgentVersion:      pkgversion.Version,
+	})
+	return err
+}
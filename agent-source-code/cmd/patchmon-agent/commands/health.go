@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/client"
+	"patchmon-agent/internal/connectivity"
+	"patchmon-agent/internal/doh"
+	"patchmon-agent/internal/localapi"
+	"patchmon-agent/internal/logutil"
+	"patchmon-agent/internal/utils"
+)
+
+// connectivityCheckInterval is how often serve re-runs the DNS/transport self-test
+const connectivityCheckInterval = 30 * time.Minute
+
+// localState backs the local read-only API with the latest collected data
+var localState = localapi.NewState()
+
+// agentHealth is the serve process's current health state
+var (
+	agentHealth   models.AgentHealth
+	agentHealthMu sync.RWMutex
+)
+
+// getAgentHealth returns a copy of the current health state
+func getAgentHealth() models.AgentHealth {
+	agentHealthMu.RLock()
+	defer agentHealthMu.RUnlock()
+	return agentHealth
+}
+
+// measureClockSkew measures the local clock's offset from the server, records it
+// in the health state and warns when it exceeds utils.ClockSkewWarnThreshold.
+// Returns nil when the skew could not be measured.
+func measureClockSkew(ctx context.Context, httpClient *client.Client) *float64 {
+	skew, err := httpClient.GetClockSkew(ctx)
+	if err != nil {
+		logger.WithError(err).Debug("Could not measure clock skew against server")
+		return nil
+	}
+
+	seconds := skew.Seconds()
+	agentHealthMu.Lock()
+	agentHealth.ClockSkewSeconds = &seconds
+	agentHealthMu.Unlock()
+
+	if skew.Abs() >= utils.ClockSkewWarnThreshold {
+		logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
+			"skew":       skew.String(),
+			"local_time": time.Now().UTC().Format(time.RFC3339),
+		})).Warn("⚠️  Local clock differs significantly from the PatchMon server; check NTP/chrony/timesyncd")
+	} else {
+		logger.WithField("skew", skew.String()).Debug("Clock skew against server within tolerance")
+	}
+	return &seconds
+}
+
+// configureDNSOverHTTPS installs the dns_over_https resolver that server
+// connections fall back to when the system resolver fails
+func configureDNSOverHTTPS() {
+	servers := cfgManager.GetConfig().DNSOverHTTPS
+	if len(servers) == 0 {
+		utils.SetFallbackLookup(nil)
+		return
+	}
+	resolver, err := doh.New(logger, servers)
+	if err != nil {
+		logger.WithError(err).Error("DNS-over-HTTPS fallback disabled")
+		utils.SetFallbackLookup(nil)
+		return
+	}
+	utils.SetFallbackLookup(resolver.LookupHost)
+}
+
+// newConnectivityChecker builds a checker from the current config. It presents
+// the relay or client_tls certificate; if that can't be loaded the test runs
+// without one, and the request fails as real ones would.
+func newConnectivityChecker() *connectivity.Checker {
+	cfg := cfgManager.GetConfig()
+	skipVerify := cfg.SkipSSLVerify || client.IsSkipSSLVerifyEnvSet()
+	checker := connectivity.New(logger, skipVerify, cfgManager.GetFallbackDNSServers())
+	if tlsConfig, err := client.TLSConfig(cfg, skipVerify); err == nil && tlsConfig != nil {
+		checker.SetTLSConfig(tlsConfig)
+	}
+	return checker
+}
+
+// connectivityTarget is the URL the connectivity self-test probes: the relay
+// when relay_url is set. A relay on a unix socket has nothing to resolve or
+// dial over the network, so there is nothing to test and ok is false.
+func connectivityTarget() (serverURL string, ok bool) {
+	cfg := cfgManager.GetConfig()
+	if _, socket := client.RelaySocket(cfg); socket {
+		return "", false
+	}
+	return client.ServerURL(cfg), true
+}
+
+// runConnectivityMonitor periodically runs the connectivity self-test and records
+// the result in the health state. Problems are logged when they first appear and
+// when they clear, so a persistent fault does not flood the log.
+func runConnectivityMonitor(ctx context.Context) {
+	checker := newConnectivityChecker()
+	serverURL, ok := connectivityTarget()
+	if !ok {
+		return
+	}
+
+	var lastProblems string
+	check := func() {
+		result := checker.Check(ctx, serverURL)
+
+		agentHealthMu.Lock()
+		agentHealth.Connectivity = result
+		agentHealthMu.Unlock()
+
+		problems := strings.Join(result.Problems, "; ")
+		switch {
+		case problems != "" && problems != lastProblems:
+			logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
+				"server_host": result.ServerHost,
+				"problems":    problems,
+			})).Warn("Connectivity self-test found problems")
+		case problems == "" && lastProblems != "":
+			logger.WithField("server_host", logutil.Sanitize(result.ServerHost)).Info("✅ Connectivity self-test passing again")
+		default:
+			logger.Debug("Connectivity self-test completed")
+		}
+		lastProblems = problems
+	}
+
+	check()
+
+	ticker := time.NewTicker(connectivityCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			check()
+		}
+	}
+}
+
+// runLocalAPI serves the local read-only API until ctx is cancelled
+func runLocalAPI(ctx context.Context, listen string) {
+	token := cfgManager.GetConfig().LocalAPIToken
+	ln, err := localapi.Listen(listen, token, cfgManager.GetConfig().LocalAPIGroup)
+	if err != nil {
+		logger.WithError(err).Warn("Local API disabled")
+		return
+	}
+	if err := localapi.NewServer(logger, localState, getAgentHealth, token).Serve(ctx, ln); err != nil {
+		logger.WithError(err).Warn("Local API stopped")
+	}
+}
@@ -0,0 +1,177 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/config"
+	"patchmon-agent/internal/hooks"
+	"patchmon-agent/internal/logutil"
+	"patchmon-agent/internal/pkgversion"
+
+	"github.com/spf13/cobra"
+)
+
+// aptPendingFile holds the apt transaction between the pre-install and post-invoke hooks
+const aptPendingFile = "apt_pending_transaction.json"
+
+// hooksCmd manages the optional apt/dnf transaction hooks
+var hooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "Manage package manager transaction hooks",
+	Long: `Install or remove apt and dnf hooks that notify the running agent when a
+package transaction completes, so patch history is recorded precisely and a
+report is sent straight away.`,
+}
+
+var hooksInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install apt/dnf hooks for the package managers on this host",
+	RunE: func(_ *cobra.Command, _ []string) error {
+		if err := checkRoot(); err != nil {
+			return err
+		}
+		agentPath, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to locate agent binary: %w", err)
+		}
+		written, err := hooks.Install(agentPath)
+		for _, path := range written {
+			fmt.Printf("✅ Installed %s\n", path)
+		}
+		return err
+	},
+}
+
+var hooksUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove apt/dnf hooks installed by the agent",
+	RunE: func(_ *cobra.Command, _ []string) error {
+		if err := checkRoot(); err != nil {
+			return err
+		}
+		removed, err := hooks.Uninstall()
+		for _, path := range removed {
+			fmt.Printf("✅ Removed %s\n", path)
+		}
+		if err == nil && len(removed) == 0 {
+			fmt.Println("No hooks installed")
+		}
+		return err
+	},
+}
+
+// The commands below are invoked by the hooks themselves. They must never fail
+// the package manager, so errors are logged rather than returned.
+
+var hooksAptPreCmd = &cobra.Command{
+	Use:    "apt-pre",
+	Short:  "Record an apt transaction (DPkg::Pre-Install-Pkgs hook)",
+	Hidden: true,
+	Run: func(_ *cobra.Command, _ []string) {
+		items, err := hooks.ParseAptPreInstall(os.Stdin)
+		if err != nil {
+			logger.WithError(err).Warn("apt hook: failed to parse package list")
+			return
+		}
+		now := time.Now().UTC()
+		tx := &models.PackageTransaction{Manager: "apt", StartedAt: &now, Packages: items}
+		if err := hooks.SavePending(cfgManager.StatePath(aptPendingFile), tx); err != nil {
+			logger.WithError(err).Warn("apt hook: failed to save pending transaction")
+		}
+	},
+}
+
+var hooksAptPostCmd = &cobra.Command{
+	Use:    "apt-post",
+	Short:  "Report a completed apt transaction (DPkg::Post-Invoke hook)",
+	Hidden: true,
+	Run: func(_ *cobra.Command, _ []string) {
+		tx, err := hooks.TakePending(cfgManager.StatePath(aptPendingFile))
+		if err != nil {
+			logger.WithError(err).Warn("apt hook: failed to load pending transaction")
+			return
+		}
+		if tx == nil {
+			return
+		}
+		notifyAgent(tx)
+	},
+}
+
+var hooksDnfCmd = &cobra.Command{
+	Use:    "dnf",
+	Short:  "Report a completed dnf transaction (dnf plugin)",
+	Hidden: true,
+	Run: func(_ *cobra.Command, _ []string) {
+		var tx models.PackageTransaction
+		if err := json.NewDecoder(io.LimitReader(os.Stdin, 4<<20)).Decode(&tx); err != nil {
+			logger.WithError(err).Warn("dnf hook: failed to parse transaction")
+			return
+		}
+		tx.Manager = "dnf"
+		notifyAgent(&tx)
+	},
+}
+
+func init() {
+	hooksCmd.AddCommand(hooksInstallCmd)
+	hooksCmd.AddCommand(hooksUninstallCmd)
+	hooksCmd.AddCommand(hooksAptPreCmd)
+	hooksCmd.AddCommand(hooksAptPostCmd)
+	hooksCmd.AddCommand(hooksDnfCmd)
+	rootCmd.AddCommand(hooksCmd)
+}
+
+func notifyAgent(tx *models.PackageTransaction) {
+	tx.CompletedAt = time.Now().UTC()
+	if len(tx.Packages) == 0 {
+		return
+	}
+	// Every profile's serve reports the same packages
+	profiles, _ := config.ListProfiles()
+	for _, name := range append([]string{""}, profiles...) {
+		if err := hooks.Notify(hooks.SocketPath(name), tx); err != nil {
+			// serve not running; the next periodic report still picks up the change
+			logger.WithError(err).Debug("Package hook could not reach the agent")
+		}
+	}
+}
+
+// listenForPackageHooks receives hook notifications in serve, forwards each
+// transaction to the server and signals that the package list changed
+func listenForPackageHooks(ctx context.Context, changed chan<- struct{}) {
+	err := hooks.Listen(ctx, logger, hooks.SocketPath(cfgManager.Profile()), func(tx *models.PackageTransaction) {
+		logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
+			"manager":  tx.Manager,
+			"packages": len(tx.Packages),
+		})).Info("Package transaction completed")
+
+		systemDetector := newSystemDetector()
+		hostname, _ := systemDetector.GetHostname()
+		payload := &models.PackageTransactionPayload{
+			Transaction:  tx,
+			Hostname:     hostname,
+			MachineID:    systemDetector.GetMachineID(),
+			AgentVersion: pkgversion.Version,
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		if err := apiClient().SendPackageTransaction(sendCtx, payload); err != nil {
+			logger.WithError(err).Warn("Failed to send package transaction (the next report still includes the new package state)")
+		}
+
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		logger.WithError(err).Warn("Package hook listener unavailable")
+	}
+}
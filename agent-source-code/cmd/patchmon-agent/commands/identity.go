@@ -0,0 +1,212 @@
+package commands
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"patchmon-agent/internal/client"
+	"patchmon-agent/internal/system"
+	"patchmon-agent/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// machineIdentityFile replaces the OS machine ID once identity reset has
+	// been run, for clones that share /etc/machine-id
+	machineIdentityFile = "machine_id"
+	// registrationConflictFile records a conflict reported by the server
+	registrationConflictFile = "registration_conflict.json"
+	// registrationConflictRetry is how long reports are held back after a
+	// conflict before trying once more, in case it was resolved server-side
+	registrationConflictRetry = time.Hour
+)
+
+// registrationConflict is what the server said about a conflicting
+// registration, kept so every later report can explain itself without
+// contacting the server again
+type registrationConflict struct {
+	DetectedAt       time.Time `json:"detectedAt"`
+	MachineID        string    `json:"machineId"`
+	Hostname         string    `json:"hostname"`
+	StatusCode       int       `json:"statusCode"`
+	Message          string    `json:"message,omitempty"`
+	ExistingHostname string    `json:"existingHostname,omitempty"`
+}
+
+var identityCmd = &cobra.Command{
+	Use:   "identity",
+	Short: "Show or reset the machine identity this agent reports",
+}
+
+var identityShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the machine ID and any registration conflict",
+	RunE: func(_ *cobra.Command, _ []string) error {
+		machineID := newSystemDetector().GetMachineID()
+		source := "operating system"
+		if localMachineIdentity() != "" {
+			source = cfgManager.StatePath(machineIdentityFile)
+		}
+		fmt.Printf("Machine ID: %s (from %s)\n", machineID, source)
+
+		conflict := loadRegistrationConflict()
+		if conflict == nil || conflict.MachineID != machineID {
+			fmt.Println("Registration: no conflict recorded")
+			return nil
+		}
+		fmt.Println()
+		fmt.Print(conflict.describe())
+		return nil
+	},
+}
+
+var identityResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Mint a new machine ID for this agent",
+	Long: `Generate a new machine ID for this agent and use it instead of the operating
+system's. Use it when the server reports that this host's machine ID is
+already registered to another host, usually because a VM or image was cloned
+without resetting /etc/machine-id.
+
+The next report registers with the new ID. The old host record on the server
+is left alone; delete it there if it belonged to this host.`,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		if err := checkRoot(); err != nil {
+			return err
+		}
+		previous := newSystemDetector().GetMachineID()
+		id, err := resetMachineIdentity()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Machine ID reset\n   Old: %s\n   New: %s\n", previous, id)
+		fmt.Println("   The running service uses it from its next report. Send one now with: patchmon-agent report")
+		return nil
+	},
+}
+
+func init() {
+	identityCmd.AddCommand(identityShowCmd)
+	identityCmd.AddCommand(identityResetCmd)
+	rootCmd.AddCommand(identityCmd)
+}
+
+// localMachineIdentity returns the machine ID minted by identity reset, or ""
+func localMachineIdentity() string {
+	data, err := os.ReadFile(cfgManager.StatePath(machineIdentityFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// applyMachineIdentity makes the detector report the minted machine ID, if any
+func applyMachineIdentity(d *system.Detector) *system.Detector {
+	return d.WithMachineID(localMachineIdentity())
+}
+
+// resetMachineIdentity writes a new random machine ID in /etc/machine-id
+// format and forgets state tied to the old registration
+func resetMachineIdentity() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate machine ID: %w", err)
+	}
+	id := hex.EncodeToString(raw)
+	if err := utils.WriteFileAtomic(cfgManager.StatePath(machineIdentityFile), []byte(id+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to save machine ID: %w", err)
+	}
+
+	// The conflict belonged to the old ID; the last hostname and report would
+	// otherwise be sent as a rename of, and partial update to, the old host
+	for _, name := range []string{registrationConflictFile, lastHostnameFile, lastReportFile} {
+		if err := os.Remove(cfgManager.StatePath(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.WithError(err).WithField("file", name).Warn("Failed to remove state from the old identity")
+		}
+	}
+	return id, nil
+}
+
+func loadRegistrationConflict() *registrationConflict {
+	data, err := os.ReadFile(cfgManager.StatePath(registrationConflictFile))
+	if err != nil {
+		return nil
+	}
+	var c registrationConflict
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil
+	}
+	return &c
+}
+
+// recordRegistrationConflict saves and logs a conflict returned by the server
+func recordRegistrationConflict(conflict *client.RegistrationConflictError, machineID, hostname string) {
+	c := &registrationConflict{
+		DetectedAt:       time.Now().UTC(),
+		MachineID:        machineID,
+		Hostname:         hostname,
+		StatusCode:       conflict.StatusCode,
+		Message:          conflict.Message,
+		ExistingHostname: conflict.ExistingHostname,
+	}
+	if data, err := json.Marshal(c); err == nil {
+		if err := utils.WriteFileAtomic(cfgManager.StatePath(registrationConflictFile), data, 0600); err != nil {
+			logger.WithError(err).Warn("Failed to save registration conflict")
+		}
+	}
+	logger.Error(c.describe())
+}
+
+// clearRegistrationConflict forgets a conflict once a report gets through
+func clearRegistrationConflict() {
+	if err := os.Remove(cfgManager.StatePath(registrationConflictFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.WithError(err).Debug("Failed to remove registration conflict")
+	}
+}
+
+// registrationConflictHold returns an error while a recent conflict is
+// recorded for the current machine ID, so reports don't keep hitting the
+// server with a registration it has already refused. Once
+// registrationConflictRetry has passed, one report is let through in case the
+// conflict was resolved on the server.
+func registrationConflictHold(machineID string) error {
+	c := loadRegistrationConflict()
+	if c == nil {
+		return nil
+	}
+	if c.MachineID != machineID {
+		// identity reset (or a changed OS machine ID) resolved it
+		clearRegistrationConflict()
+		return nil
+	}
+	if time.Since(c.DetectedAt) >= registrationConflictRetry {
+		return nil
+	}
+	return fmt.Errorf("report held back by an unresolved registration conflict, retrying after %s:\n%s",
+		c.DetectedAt.Add(registrationConflictRetry).Local().Format("15:04"), c.describe())
+}
+
+// describe explains the conflict and what to do about it
+func (c *registrationConflict) describe() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Registration conflict: the server refused this host's registration (status %d)\n", c.StatusCode)
+	fmt.Fprintf(&b, "   Machine ID: %s\n", c.MachineID)
+	fmt.Fprintf(&b, "   This host:  %s\n", c.Hostname)
+	if c.ExistingHostname != "" {
+		fmt.Fprintf(&b, "   Registered: %s\n", c.ExistingHostname)
+	}
+	if c.Message != "" {
+		fmt.Fprintf(&b, "   Server:     %s\n", c.Message)
+	}
+	fmt.Fprintf(&b, "   Detected:   %s\n", c.DetectedAt.Local().Format("2006-01-02 15:04:05"))
+	b.WriteString("   If this host is a clone, give it its own identity with: patchmon-agent identity reset\n")
+	b.WriteString("   If it replaced the registered host, delete the old host on the server instead.\n")
+	return b.String()
+}
@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"patchmon-agent/internal/client"
+	"patchmon-agent/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRegistrationConflictHoldAndReset(t *testing.T) {
+	cfgManager = config.New()
+	cfgManager.SetConfigFile(filepath.Join(t.TempDir(), "config.yml"))
+	logger = logrus.New()
+	logger.SetOutput(io.Discard)
+
+	osMachineID := newSystemDetector().GetMachineID()
+	if err := registrationConflictHold(osMachineID); err != nil {
+		t.Fatalf("hold without a conflict: %v", err)
+	}
+
+	recordRegistrationConflict(&client.RegistrationConflictError{StatusCode: 409, ExistingHostname: "web-1"}, osMachineID, "web-2")
+	if err := registrationConflictHold(osMachineID); err == nil {
+		t.Fatal("expected reports to be held after a conflict")
+	}
+
+	if err := os.WriteFile(cfgManager.StatePath(lastHostnameFile), []byte("web-2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	id, err := resetMachineIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := newSystemDetector().GetMachineID(); got != id || got == osMachineID {
+		t.Fatalf("machine ID after reset = %q, want new ID %q", got, id)
+	}
+	if err := registrationConflictHold(id); err != nil {
+		t.Fatalf("hold after reset: %v", err)
+	}
+	if _, err := os.Stat(cfgManager.StatePath(lastHostnameFile)); !os.IsNotExist(err) {
+		t.Fatal("last_hostname from the old identity should be removed")
+	}
+}
+
+func TestRegistrationConflictIgnoredForOtherMachineID(t *testing.T) {
+	cfgManager = config.New()
+	cfgManager.SetConfigFile(filepath.Join(t.TempDir(), "config.yml"))
+	logger = logrus.New()
+	logger.SetOutput(io.Discard)
+
+	recordRegistrationConflict(&client.RegistrationConflictError{StatusCode: 409}, "old-id", "web-2")
+	if err := registrationConflictHold("new-id"); err != nil {
+		t.Fatalf("conflict for another machine ID should not hold reports: %v", err)
+	}
+	if loadRegistrationConflict() != nil {
+		t.Fatal("stale conflict should be cleared")
+	}
+}
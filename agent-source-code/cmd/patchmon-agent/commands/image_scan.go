@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"patchmon-agent/internal/integrations/compliance"
+	"patchmon-agent/internal/logutil"
+)
+
+const (
+	// imageScanStateFile records when each image ID's CVE results last reached
+	// the server
+	imageScanStateFile = "image_cve_scans.json"
+	// imageScanCacheFile holds the last CVE results of each image ID
+	imageScanCacheFile = "image_cve_cache.json"
+	// cveFeedDir holds the OVAL feeds image scans evaluate
+	cveFeedDir = "cve_feeds"
+
+	defaultImageScanConcurrency = 2
+	maxImageScanConcurrency     = 8
+	defaultImageScanTimeout     = 15 * time.Minute
+	defaultImageScanSkipWindow  = 24 * time.Hour
+	defaultImageScanCacheTTL    = 7 * 24 * time.Hour
+	// imageScanAllTimeout bounds a scan of every image
+	imageScanAllTimeout = 6 * time.Hour
+)
+
+// imageScanOptions returns the worker pool settings for scanning all images.
+// Unless force is set, images scanned within image_scan_skip_hours are
+// skipped and cached results are reused. scanned collects the IDs of the images scanned; the scanner calls
+// its callbacks one at a time.
+func imageScanOptions(force bool, scanned *[]string) compliance.ImageScanOptions {
+	cfg := cfgManager.GetConfig()
+	opts := compliance.ImageScanOptions{
+		Concurrency:  defaultImageScanConcurrency,
+		ImageTimeout: defaultImageScanTimeout,
+		CachePath:    cfgManager.StatePath(imageScanCacheFile),
+		CacheTTL:     defaultImageScanCacheTTL,
+		Scanned: func(imageID string) {
+			*scanned = append(*scanned, imageID)
+		},
+		Progress: func(done, total int, image string) {
+			// Between the 5% sent at the start and the 80% of the parsing phase
+			pct := 5 + 75*done/total
+			sendComplianceProgress("evaluating", "Docker Image CVE Scan", fmt.Sprintf("Scanned %d of %d images (%s)", done, total, logutil.Sanitize(image)), float64(pct), "")
+		},
+	}
+	if cfg.ImageScanConcurrency > 0 {
+		opts.Concurrency = min(cfg.ImageScanConcurrency, maxImageScanConcurrency)
+	}
+	if cfg.ImageScanTimeout > 0 {
+		opts.ImageTimeout = time.Duration(cfg.ImageScanTimeout) * time.Second
+	}
+	if hours := cfg.ImageScanCacheHours; hours != nil {
+		opts.CacheTTL = time.Duration(max(*hours, 0)) * time.Hour
+	}
+	if force {
+		// Every cached result counts as stale; fresh ones are still stored
+		opts.FeedUpdated = time.Now()
+	}
+	if window := imageScanSkipWindow(); !force && window > 0 {
+		last := loadImageTimes(cfgManager.StatePath(imageScanStateFile))
+		opts.Skip = func(imageID string) bool {
+			at, ok := last[imageID]
+			return ok && time.Since(at) < window
+		}
+	}
+	return opts
+}
+
+func imageScanSkipWindow() time.Duration {
+	if hours := cfgManager.GetConfig().ImageScanSkipHours; hours != nil {
+		return time.Duration(max(*hours, 0)) * time.Hour
+	}
+	return defaultImageScanSkipWindow
+}
+
+// recordImageScans notes images whose results the server has accepted, so the
+// next scan of all images can skip them. Entries past the skip window are
+// dropped.
+func recordImageScans(imageIDs []string) {
+	if len(imageIDs) == 0 {
+		return
+	}
+	path := cfgManager.StatePath(imageScanStateFile)
+	times := loadImageTimes(path)
+	window := imageScanSkipWindow()
+	for id, at := range times {
+		if time.Since(at) >= window {
+			delete(times, id)
+		}
+	}
+	now := time.Now()
+	for _, id := range imageIDs {
+		times[id] = now
+	}
+	if err := saveImageTimes(path, times); err != nil {
+		logger.WithError(err).Debug("Failed to save image scan state")
+	}
+}
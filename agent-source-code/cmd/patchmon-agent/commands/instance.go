@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"os"
+
+	"patchmon-agent/internal/config"
+	"patchmon-agent/internal/pidlock"
+)
+
+// servePIDFile is the pidfile serve holds an exclusive lock on, in the state dir
+const servePIDFile = "patchmon-agent.pid"
+
+// serveLock is held for the lifetime of the serve process and released by the
+// OS on exit, so a restart (or crash) never leaves it stuck
+var serveLock *pidlock.Lock
+
+// acquireServeLock makes sure only one serve process runs per host. Two
+// instances (e.g. a systemd unit plus a hand-started one) would send
+// interleaved, conflicting reports.
+func acquireServeLock() error {
+	lock, err := pidlock.Acquire(cfgManager.StatePath(servePIDFile))
+	if err != nil {
+		return err
+	}
+	serveLock = lock
+	return nil
+}
+
+// skipLegacyCronReport reports whether this report run should be skipped
+// because it was started by a leftover cron entry while serve is already
+// reporting. Interactive runs always proceed.
+func skipLegacyCronReport() bool {
+	if _, err := os.Stat(config.CronFilePath); err != nil {
+		return false
+	}
+	if !pidlock.IsHeld(cfgManager.StatePath(servePIDFile)) {
+		return false
+	}
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		logger.WithField("cron_file", config.CronFilePath).Warn("The agent service is running and a legacy cron entry still exists; remove the cron file to avoid duplicate reports")
+		return false
+	}
+	logger.WithField("cron_file", config.CronFilePath).Warn("Skipping report from legacy cron entry: the agent service is already running. Remove the cron file to silence this warning")
+	return true
+}
@@ -0,0 +1,355 @@
+package commands
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/logutil"
+
+	"github.com/gorilla/websocket"
+)
+
+// Finished jobs are kept this long, and at most maxFinishedJobs of them
+const (
+	finishedJobRetention = 24 * time.Hour
+	maxFinishedJobs      = 50
+)
+
+// jobActions are the server commands that run in the background and so get a
+// job ID and an entry in the job table
+var jobActions = map[string]bool{
+	"batch":                      true,
+	"refresh_integration_status": true,
+	"docker_inventory_refresh":   true,
+	"hardware_inventory":         true,
+	"run_patch":                  true,
+	"compliance_scan":            true,
+	"compliance_ckl_export":      true,
+	"upgrade_ssg":                true,
+	"install_scanner":            true,
+	"remediate_rule":             true,
+	"docker_image_scan":          true,
+	"docker_update_container":    true,
+	"docker_prune":               true,
+	"package_update":             true,
+	"update_agent":               true,
+	"update_notification":        true,
+}
+
+// cancellableJobs can be cancelled while running. The rest (SSG and scanner
+// installs, agent, container and package updates) can only be cancelled while
+// queued, since stopping them halfway would leave the host in a worse state
+// than finishing.
+var cancellableJobs = map[string]bool{
+	"batch":                      true,
+	"report_now":                 true,
+	"refresh_integration_status": true,
+	"docker_inventory_refresh":   true,
+	"hardware_inventory":         true,
+	"run_patch":                  true,
+	"compliance_scan":            true,
+	"compliance_ckl_export":      true,
+	"remediate_rule":             true,
+	"docker_image_scan":          true,
+	"docker_prune":               true,
+}
+
+// errCancelledWhileQueued ends a job cancelled before the dispatcher reached it
+var errCancelledWhileQueued = fmt.Errorf("cancelled before it started: %w", context.Canceled)
+
+// jobStates maps the outcomes recorded for remote actions to job states
+var jobStates = map[string]string{
+	actionRunning:   models.JobRunning,
+	actionSuccess:   models.JobSucceeded,
+	actionFailed:    models.JobFailed,
+	actionRefused:   models.JobFailed,
+	actionCancelled: models.JobCancelled,
+	actionSkipped:   models.JobCancelled,
+	actionDeferred:  models.JobWaiting,
+}
+
+// jobTable tracks background commands from receipt to completion. It lives in
+// memory only; last_actions.json covers what survives a restart.
+type jobTable struct {
+	mu      sync.Mutex
+	jobs    map[string]*models.Job
+	cancels map[string]func() // how to stop each running cancellable job, or wake a waiting one
+	// cancelled holds running jobs whose cancel came before their cancel
+	// function was registered
+	cancelled map[string]bool
+	// servers holds the servers entry whose command queued each job, nil
+	// for patchmon_server
+	servers map[string]*serverProfile
+}
+
+func newJobTable() *jobTable {
+	return &jobTable{
+		jobs:      make(map[string]*models.Job),
+		cancels:   make(map[string]func()),
+		cancelled: make(map[string]bool),
+		servers:   make(map[string]*serverProfile),
+	}
+}
+
+var jobs = newJobTable()
+
+// enqueue gives m a job ID and records it as queued. The server's command_id
+// is used as the ID when there is one.
+func (t *jobTable) enqueue(m *wsMsg) {
+	if !isJob(m) {
+		return
+	}
+	id := m.commandID
+	if id == "" {
+		id = newJobID()
+	}
+	m.jobID = id
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.jobs[id] = &models.Job{ID: id, Command: m.kind, State: models.JobQueued, QueuedAt: time.Now().UTC()}
+	t.servers[id] = m.server
+	t.prune()
+}
+
+// isJob reports whether m is tracked as a job. A report_now is when it has
+// docker or compliance sections, which refresh in the background.
+func isJob(m *wsMsg) bool {
+	if m.kind == "report_now" {
+		_, extras := splitReportSections(m.reportSections)
+		return len(extras) > 0
+	}
+	return jobActions[m.kind]
+}
+
+// update moves a job to the state matching a remote action outcome
+func (t *jobTable) update(id, outcome, detail string) {
+	state, ok := jobStates[outcome]
+	if id == "" || !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	job, ok := t.jobs[id]
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	job.State = state
+	job.Error = detail
+	switch state {
+	case models.JobRunning:
+		job.StartedAt = &now
+	case models.JobWaiting:
+	default:
+		job.FinishedAt = &now
+		delete(t.cancelled, id)
+	}
+	t.prune()
+}
+
+// hold records when a waiting job's maintenance window opens
+func (t *jobTable) hold(id string, runAfter time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if job, ok := t.jobs[id]; ok {
+		at := runAfter.UTC()
+		job.RunAfter = &at
+	}
+}
+
+// release queues a waiting job again for the dispatcher. It leaves a job
+// cancelled while it waited alone.
+func (t *jobTable) release(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if job, ok := t.jobs[id]; ok && job.State == models.JobWaiting {
+		job.State = models.JobQueued
+		job.Error = ""
+		job.RunAfter = nil
+	}
+}
+
+// onCancel registers how to stop a running job, and calls it straight away if
+// the job was already cancelled. The returned function unregisters it.
+func (t *jobTable) onCancel(id string, cancel func()) (release func()) {
+	if id == "" {
+		return func() {}
+	}
+	t.mu.Lock()
+	t.cancels[id] = cancel
+	requested := t.cancelled[id]
+	delete(t.cancelled, id)
+	t.mu.Unlock()
+	if requested {
+		cancel()
+	}
+	return func() {
+		t.mu.Lock()
+		delete(t.cancels, id)
+		t.mu.Unlock()
+	}
+}
+
+// context returns a context for a job that job_cancel cancels. Call done when
+// the job ends.
+func (t *jobTable) context(parent context.Context, id string) (ctx context.Context, done func()) {
+	ctx, cancel := context.WithCancel(parent)
+	release := t.onCancel(id, cancel)
+	return ctx, func() {
+		release()
+		cancel()
+	}
+}
+
+// cancel stops a job. A queued or waiting job is marked cancelled and skipped
+// when the dispatcher reaches it; a running one has its context cancelled and reports
+// the cancelled state once it has stopped.
+func (t *jobTable) cancel(id string) error {
+	if id == "" {
+		return errors.New("missing job_id")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	job, ok := t.jobs[id]
+	switch {
+	case !ok:
+		return fmt.Errorf("no job %q", id)
+	case job.State == models.JobQueued || job.State == models.JobWaiting:
+		waiting := job.State == models.JobWaiting
+		now := time.Now().UTC()
+		job.State = models.JobCancelled
+		job.Error = errCancelledWhileQueued.Error()
+		job.FinishedAt = &now
+		// Hand a waiting job back to the dispatcher now rather than when
+		// its window opens
+		if wake, ok := t.cancels[id]; ok {
+			wake()
+		} else if waiting {
+			t.cancelled[id] = true
+		}
+		return nil
+	case job.State != models.JobRunning:
+		return fmt.Errorf("job %q already %s", id, job.State)
+	case !cancellableJobs[job.Command]:
+		return fmt.Errorf("%s can't be cancelled once it has started", job.Command)
+	}
+	if cancel, ok := t.cancels[id]; ok {
+		cancel()
+	} else {
+		t.cancelled[id] = true
+	}
+	return nil
+}
+
+// cancelFor is cancel for a job_cancel from server, which may only cancel the
+// jobs its own commands queued
+func (t *jobTable) cancelFor(server *serverProfile, id string) error {
+	t.mu.Lock()
+	_, ok := t.jobs[id]
+	owned := ok && t.servers[id] == server
+	t.mu.Unlock()
+	if ok && !owned {
+		return fmt.Errorf("no job %q", id)
+	}
+	return t.cancel(id)
+}
+
+// jobErr reports a job stopped through its context as cancelled, even when
+// the command it ran only failed with e.g. "signal: killed"
+func jobErr(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, context.Canceled) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ctx.Err(), err)
+}
+
+// cancelledWhileQueued reports whether a job was cancelled before it started
+func (t *jobTable) cancelledWhileQueued(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	job, ok := t.jobs[id]
+	return ok && job.State == models.JobCancelled && job.StartedAt == nil
+}
+
+// list returns the job with the given ID, or every job when id is empty,
+// oldest first
+func (t *jobTable) list(id string) []models.Job {
+	return t.collect(id, func(*serverProfile) bool { return true })
+}
+
+// listFor is list limited to the jobs queued by server's commands
+func (t *jobTable) listFor(server *serverProfile, id string) []models.Job {
+	return t.collect(id, func(queuedBy *serverProfile) bool { return queuedBy == server })
+}
+
+func (t *jobTable) collect(id string, keep func(queuedBy *serverProfile) bool) []models.Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := []models.Job{}
+	for _, job := range t.jobs {
+		if (id == "" || job.ID == id) && keep(t.servers[job.ID]) {
+			list = append(list, *job)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].QueuedAt.Before(list[j].QueuedAt) })
+	return list
+}
+
+// prune drops finished jobs past the retention period or the count limit.
+// Queued and running jobs are always kept.
+func (t *jobTable) prune() {
+	var finished []*models.Job
+	for id, job := range t.jobs {
+		if job.FinishedAt == nil {
+			continue
+		}
+		if time.Since(*job.FinishedAt) > finishedJobRetention {
+			delete(t.jobs, id)
+			delete(t.servers, id)
+			continue
+		}
+		finished = append(finished, job)
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].FinishedAt.After(*finished[j].FinishedAt) })
+	for _, job := range finished[maxFinishedJobs:] {
+		delete(t.jobs, job.ID)
+		delete(t.servers, job.ID)
+	}
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().UTC().Format("20060102T150405.000000000")
+	}
+	return "job-" + hex.EncodeToString(b)
+}
+
+// jobsForPing returns the whole job table for the ping payload
+func jobsForPing() []models.Job {
+	list := jobs.list("")
+	if len(list) == 0 {
+		return nil
+	}
+	return list
+}
+
+// sendJobStatus answers a job_status message from server with the jobs its
+// own commands queued
+func sendJobStatus(conn *websocket.Conn, server *serverProfile, commandID, jobID string) {
+	reply := models.JobStatusReply{Type: "job_status", CommandID: commandID, Jobs: jobs.listFor(server, jobID)}
+	if err := sendWSMessage(conn, reply); err != nil {
+		logger.WithError(err).WithField("job_id", logutil.Sanitize(jobID)).Debug("Failed to send job status")
+	}
+}
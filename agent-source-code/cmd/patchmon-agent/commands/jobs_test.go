@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+func TestJobLifecycle(t *testing.T) {
+	setupBatchTest(t)
+	jobs = newJobTable()
+
+	scan := wsMsg{kind: "compliance_scan", commandID: "c-1"}
+	jobs.enqueue(&scan)
+	report := wsMsg{kind: "report_now"}
+	jobs.enqueue(&report)
+	if report.jobID != "" {
+		t.Fatalf("report_now is not a background command but got job %q", report.jobID)
+	}
+	scanReport := wsMsg{kind: "report_now", commandID: "r-1", reportSections: []string{"packages", "compliance"}}
+	jobs.enqueue(&scanReport)
+	if scanReport.jobID != "r-1" {
+		t.Fatalf("report_now refreshing compliance got job %q", scanReport.jobID)
+	}
+	update := wsMsg{kind: "update_agent"}
+	jobs.enqueue(&update)
+	if !strings.HasPrefix(update.jobID, "job-") {
+		t.Fatalf("generated job ID = %q", update.jobID)
+	}
+
+	if got := jobs.list("c-1"); len(got) != 1 || got[0].State != models.JobQueued {
+		t.Fatalf("queued job = %+v", got)
+	}
+	recordActionStart(scan)
+	if got := jobs.list("c-1")[0]; got.State != models.JobRunning || got.StartedAt == nil {
+		t.Fatalf("running job = %+v", got)
+	}
+	finishAction(scan, errors.New("oscap failed"))
+	if got := jobs.list("c-1")[0]; got.State != models.JobFailed || got.Error != "oscap failed" || got.FinishedAt == nil {
+		t.Fatalf("failed job = %+v", got)
+	}
+
+	recordActionOutcome(update, actionSkipped, "agent paused")
+	if got := jobs.list(update.jobID)[0]; got.State != models.JobCancelled {
+		t.Fatalf("skipped job = %+v", got)
+	}
+
+	if got := jobsForPing(); len(got) != 3 || got[0].ID != "c-1" {
+		t.Fatalf("ping jobs = %+v", got)
+	}
+}
+
+func TestJobTableKeepsUnfinishedJobs(t *testing.T) {
+	setupBatchTest(t)
+	jobs = newJobTable()
+
+	running := wsMsg{kind: "run_patch", commandID: "running"}
+	jobs.enqueue(&running)
+	recordActionStart(running)
+	for i := 0; i < maxFinishedJobs+5; i++ {
+		m := wsMsg{kind: "docker_image_scan", commandID: fmt.Sprintf("scan-%d", i)}
+		jobs.enqueue(&m)
+		finishAction(m, nil)
+	}
+	if got := len(jobs.list("")); got != maxFinishedJobs+1 {
+		t.Fatalf("job table holds %d jobs, want %d", got, maxFinishedJobs+1)
+	}
+	if len(jobs.list("running")) != 1 {
+		t.Fatal("running job was pruned")
+	}
+
+	old := time.Now().Add(-finishedJobRetention - time.Minute)
+	latest := fmt.Sprintf("scan-%d", maxFinishedJobs+4)
+	jobs.jobs[latest].FinishedAt = &old
+	jobs.prune()
+	if len(jobs.list(latest)) != 0 {
+		t.Fatal("expired job was kept")
+	}
+}
+
+func TestJobCancel(t *testing.T) {
+	setupBatchTest(t)
+	jobs = newJobTable()
+
+	if err := jobs.cancel("missing"); err == nil {
+		t.Fatal("cancelled a job that doesn't exist")
+	}
+
+	queued := wsMsg{kind: "compliance_scan", commandID: "queued"}
+	jobs.enqueue(&queued)
+	if err := jobs.cancel("queued"); err != nil {
+		t.Fatal(err)
+	}
+	if !jobs.cancelledWhileQueued("queued") {
+		t.Fatal("queued job not marked cancelled")
+	}
+	finishAction(queued, errCancelledWhileQueued)
+	if got := jobs.list("queued")[0]; got.State != models.JobCancelled {
+		t.Fatalf("queued job = %+v", got)
+	}
+	if err := jobs.cancel("queued"); err == nil {
+		t.Fatal("cancelled a finished job")
+	}
+
+	update := wsMsg{kind: "update_agent", commandID: "update"}
+	jobs.enqueue(&update)
+	recordActionStart(update)
+	if err := jobs.cancel("update"); err == nil {
+		t.Fatal("cancelled a running agent update")
+	}
+
+	// Cancelled before the job registered its context
+	scan := wsMsg{kind: "docker_image_scan", commandID: "scan"}
+	jobs.enqueue(&scan)
+	recordActionStart(scan)
+	if err := jobs.cancel("scan"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, done := jobs.context(context.Background(), scan.jobID)
+	defer done()
+	if ctx.Err() == nil {
+		t.Fatal("job context not cancelled")
+	}
+	finishAction(scan, jobErr(ctx, errors.New("signal: killed")))
+	if got := jobs.list("scan")[0]; got.State != models.JobCancelled {
+		t.Fatalf("cancelled scan = %+v", got)
+	}
+}
+
+func TestJobsScopedToServer(t *testing.T) {
+	setupBatchTest(t)
+	jobs = newJobTable()
+	msp := &serverProfile{name: "msp"}
+
+	own := wsMsg{kind: "compliance_scan", commandID: "main-1"}
+	jobs.enqueue(&own)
+	theirs := wsMsg{kind: "compliance_scan", commandID: "msp-1", server: msp}
+	jobs.enqueue(&theirs)
+
+	if got := jobs.listFor(msp, ""); len(got) != 1 || got[0].ID != "msp-1" {
+		t.Fatalf("msp jobs = %+v", got)
+	}
+	if got := jobs.listFor(nil, "msp-1"); len(got) != 0 {
+		t.Fatalf("patchmon_server sees another server's job: %+v", got)
+	}
+	if err := jobs.cancelFor(msp, "main-1"); err == nil {
+		t.Fatal("a server cancelled a job it didn't start")
+	}
+	if got := jobs.list("main-1")[0]; got.State != models.JobQueued {
+		t.Fatalf("job = %+v", got)
+	}
+	if err := jobs.cancelFor(msp, "msp-1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(jobsForPing()) != 2 {
+		t.Fatal("ping lists every job")
+	}
+}
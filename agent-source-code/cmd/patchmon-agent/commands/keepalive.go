@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+const (
+	defaultWSPingInterval = 30 * time.Second
+	defaultWSReadTimeout  = 90 * time.Second
+	minWSPingInterval     = 5 * time.Second
+)
+
+// wsKeepalive holds the ping interval and read deadline for one WebSocket
+// session. They start from config.yml and can be replaced by the values in the
+// server's "connected" handshake, so they're read atomically by the ping loop
+// and pong handler.
+type wsKeepalive struct {
+	ping atomic.Int64
+	read atomic.Int64
+}
+
+func newWSKeepalive(cfg *models.Config) *wsKeepalive {
+	k := &wsKeepalive{}
+	k.ping.Store(int64(defaultWSPingInterval))
+	k.read.Store(int64(defaultWSReadTimeout))
+	k.apply(cfg.WSPingInterval, cfg.WSReadTimeout)
+	return k
+}
+
+// apply sets the intervals in seconds; zero keeps the current value. The read
+// deadline must outlast at least one ping round trip, so a deadline at or below
+// the ping interval is raised to three intervals.
+func (k *wsKeepalive) apply(pingSeconds, readSeconds int) {
+	ping := k.pingInterval()
+	if pingSeconds > 0 {
+		ping = max(time.Duration(pingSeconds)*time.Second, minWSPingInterval)
+	}
+	read := k.readTimeout()
+	if readSeconds > 0 {
+		read = time.Duration(readSeconds) * time.Second
+	}
+	if read <= ping {
+		read = 3 * ping
+	}
+	k.ping.Store(int64(ping))
+	k.read.Store(int64(read))
+}
+
+func (k *wsKeepalive) pingInterval() time.Duration {
+	return time.Duration(k.ping.Load())
+}
+
+func (k *wsKeepalive) readTimeout() time.Duration {
+	return time.Duration(k.read.Load())
+}
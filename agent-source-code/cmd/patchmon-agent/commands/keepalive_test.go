@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+func TestWSKeepalive(t *testing.T) {
+	k := newWSKeepalive(&models.Config{})
+	if k.pingInterval() != 30*time.Second || k.readTimeout() != 90*time.Second {
+		t.Fatalf("defaults = %s/%s, want 30s/90s", k.pingInterval(), k.readTimeout())
+	}
+
+	// A proxy with a 60s idle cutoff needs pings well inside it
+	k = newWSKeepalive(&models.Config{WSPingInterval: 20, WSReadTimeout: 50})
+	if k.pingInterval() != 20*time.Second || k.readTimeout() != 50*time.Second {
+		t.Fatalf("config = %s/%s, want 20s/50s", k.pingInterval(), k.readTimeout())
+	}
+
+	// Server handshake overrides only what it sends
+	k.apply(15, 0)
+	if k.pingInterval() != 15*time.Second || k.readTimeout() != 50*time.Second {
+		t.Fatalf("handshake = %s/%s, want 15s/50s", k.pingInterval(), k.readTimeout())
+	}
+
+	// A deadline shorter than the ping interval would drop every idle session
+	k.apply(40, 30)
+	if k.readTimeout() != 120*time.Second {
+		t.Fatalf("read timeout = %s, want 120s", k.readTimeout())
+	}
+
+	k.apply(1, 0)
+	if k.pingInterval() != 5*time.Second {
+		t.Fatalf("ping interval = %s, want 5s minimum", k.pingInterval())
+	}
+}
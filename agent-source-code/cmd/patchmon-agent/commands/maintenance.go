@@ -0,0 +1,172 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/logutil"
+	"patchmon-agent/internal/maintenance"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maintenanceRecheck is how often a held command checks whether its window
+// has opened, rather than sleeping until it should, so windows changed by
+// apply_config and clock changes are picked up
+var maintenanceRecheck = time.Minute
+
+// defaultMaintenanceActions are the server commands held for a maintenance
+// window when config.yml doesn't list its own: the ones that restart the
+// agent or its integrations, install or change packages or containers, or fix
+// compliance findings
+var defaultMaintenanceActions = map[string]bool{
+	"update_agent":            true,
+	"update_notification":     true,
+	"run_patch":               true,
+	"package_update":          true,
+	"docker_update_container": true,
+	"docker_prune":            true,
+	"integration_toggle":      true,
+	"install_scanner":         true,
+	"upgrade_ssg":             true,
+	"remediate_rule":          true,
+	"compliance_scan":         true,
+}
+
+// heldForMaintenance reports whether m waits for a maintenance window when
+// one is configured. Dry runs and the harmless forms of commands in the
+// default list never wait.
+func heldForMaintenance(m wsMsg) bool {
+	if m.dryRun {
+		return false
+	}
+	if cfg := cfgManager.GetConfig().Maintenance; cfg != nil && len(cfg.Actions) > 0 {
+		for _, action := range cfg.Actions {
+			if action == m.kind {
+				return true
+			}
+		}
+		return false
+	}
+	switch m.kind {
+	case "update_notification":
+		return m.force
+	case "compliance_scan":
+		return m.enableRemediation
+	}
+	return defaultMaintenanceActions[m.kind]
+}
+
+// maintenanceSchedule compiles the windows in config.yml, nil when there are none
+func maintenanceSchedule() (*maintenance.Schedule, error) {
+	return maintenance.New(cfgManager.GetConfig().Maintenance)
+}
+
+// maintenanceStatus returns the window state for pings, or nil when no
+// windows are configured
+func maintenanceStatus() *models.MaintenanceStatus {
+	schedule, err := maintenanceSchedule()
+	if err != nil {
+		return &models.MaintenanceStatus{Error: err.Error()}
+	}
+	if schedule == nil {
+		return nil
+	}
+	now := time.Now()
+	status := &models.MaintenanceStatus{}
+	status.Open, status.Window = schedule.Open(now)
+	if !status.Open {
+		if next, ok := schedule.Next(now); ok {
+			next = next.UTC()
+			status.NextOpen = &next
+		}
+	}
+	return status
+}
+
+// deferToMaintenanceWindow holds m until a maintenance window opens, then
+// hands it back to the dispatcher on out. It reports whether m was held or
+// refused rather than free to run now. Invalid windows refuse the command, so
+// a typo in config.yml can't let it run at any time.
+func deferToMaintenanceWindow(ctx context.Context, m wsMsg, out chan<- wsMsg) bool {
+	if !heldForMaintenance(m) {
+		return false
+	}
+	schedule, err := maintenanceSchedule()
+	if err == nil {
+		now := time.Now()
+		if open, _ := schedule.Open(now); open {
+			return false
+		}
+		next, ok := schedule.Next(now)
+		if ok {
+			holdForWindow(ctx, m, next, out)
+			return true
+		}
+		err = errors.New("no maintenance window opens within a year")
+	}
+
+	reason := fmt.Sprintf("maintenance windows: %v", err)
+	logger.WithFields(logrus.Fields{
+		"action": m.kind,
+		"reason": reason,
+	}).Warn("Refusing server command")
+	recordActionOutcome(m, actionRefused, reason)
+	nackCommand(m.replyConn(), m.kind, m.commandID, models.NackRefused, reason)
+	m.stepDone(errors.New("refused"))
+	return true
+}
+
+// holdForWindow reports m as waiting and starts the goroutine that releases it
+func holdForWindow(ctx context.Context, m wsMsg, next time.Time, out chan<- wsMsg) {
+	logger.WithFields(logrus.Fields{
+		"action":    m.kind,
+		"run_after": next.Format(time.RFC3339),
+	}).Info("Holding server command until the maintenance window opens")
+	recordActionOutcome(m, actionDeferred, "waiting for maintenance window")
+	jobs.hold(m.jobID, next)
+	runAfter := next.UTC()
+	sendCommandReply(m.replyConn(), models.CommandReply{Type: models.CommandDeferred, CommandID: m.commandID, Command: m.kind, RunAfter: &runAfter})
+
+	go func() {
+		waitCtx, done := jobs.context(ctx, m.jobID)
+		defer done()
+		waitForMaintenanceWindow(waitCtx)
+		if ctx.Err() != nil {
+			return
+		}
+		// The dispatcher checks again: a cancel, a pause or a window that
+		// closed in the meantime all still apply
+		jobs.release(m.jobID)
+		select {
+		case out <- m:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// waitForMaintenanceWindow returns once a window is open, the windows in
+// config.yml are no longer valid, or ctx is done
+func waitForMaintenanceWindow(ctx context.Context) {
+	ticker := time.NewTicker(maintenanceRecheck)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			schedule, err := maintenanceSchedule()
+			if err != nil {
+				logger.WithError(err).Warn("Maintenance windows are invalid, releasing held commands to be refused")
+				return
+			}
+			if open, name := schedule.Open(now); open {
+				logger.WithField("window", logutil.Sanitize(name)).Info("Maintenance window open, running held command")
+				return
+			}
+		}
+	}
+}
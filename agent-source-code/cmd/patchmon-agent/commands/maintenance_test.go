@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// closedWindow configures a daily window that opens in two hours
+func closedWindow(t *testing.T, actions ...string) {
+	t.Helper()
+	start := time.Now().Add(2 * time.Hour)
+	cfgManager.GetConfig().Maintenance = &models.MaintenanceConfig{
+		Windows: []models.MaintenanceWindow{{Name: "nightly", Start: start.Format("15:04"), End: start.Add(time.Hour).Format("15:04")}},
+		Actions: actions,
+	}
+}
+
+func TestHeldForMaintenance(t *testing.T) {
+	setupBatchTest(t)
+
+	for _, tc := range []struct {
+		m    wsMsg
+		held bool
+	}{
+		{wsMsg{kind: "update_agent"}, true},
+		{wsMsg{kind: "run_patch"}, true},
+		{wsMsg{kind: "run_patch", dryRun: true}, false},
+		{wsMsg{kind: "package_update", dryRun: true}, false},
+		{wsMsg{kind: "docker_prune"}, true},
+		{wsMsg{kind: "docker_prune", dryRun: true}, false},
+		{wsMsg{kind: "integration_toggle"}, true},
+		{wsMsg{kind: "install_scanner"}, true},
+		{wsMsg{kind: "upgrade_ssg"}, true},
+		{wsMsg{kind: "update_notification"}, false},
+		{wsMsg{kind: "update_notification", force: true}, true},
+		{wsMsg{kind: "compliance_scan"}, false},
+		{wsMsg{kind: "compliance_scan", enableRemediation: true}, true},
+		{wsMsg{kind: "report_now"}, false},
+	} {
+		if got := heldForMaintenance(tc.m); got != tc.held {
+			t.Errorf("heldForMaintenance(%+v) = %v, want %v", tc.m, got, tc.held)
+		}
+	}
+
+	closedWindow(t, "report_now")
+	if !heldForMaintenance(wsMsg{kind: "report_now"}) || heldForMaintenance(wsMsg{kind: "update_agent"}) {
+		t.Error("maintenance.actions should replace the default list")
+	}
+}
+
+func TestDeferToMaintenanceWindow(t *testing.T) {
+	setupBatchTest(t)
+	jobs = newJobTable()
+	out := make(chan wsMsg, 1)
+
+	if deferToMaintenanceWindow(context.Background(), wsMsg{kind: "run_patch"}, out) {
+		t.Fatal("held a command with no windows configured")
+	}
+
+	closedWindow(t)
+	update := wsMsg{kind: "update_agent", commandID: "update"}
+	jobs.enqueue(&update)
+	if !deferToMaintenanceWindow(context.Background(), update, out) {
+		t.Fatal("update_agent not held outside the window")
+	}
+	job := jobs.list("update")[0]
+	if job.State != models.JobWaiting || job.RunAfter == nil || job.FinishedAt != nil {
+		t.Fatalf("waiting job = %+v", job)
+	}
+	if got := lastActions()["update_agent"]; got == nil || got.Outcome != actionDeferred {
+		t.Fatalf("last action = %+v", got)
+	}
+	if status := maintenanceStatus(); status == nil || status.Open || status.NextOpen == nil {
+		t.Fatalf("maintenance status = %+v", status)
+	}
+
+	// Cancelling a waiting job hands it straight back to the dispatcher,
+	// which then skips it
+	if err := jobs.cancel("update"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-out:
+		if !jobs.cancelledWhileQueued(m.jobID) {
+			t.Fatalf("released job = %+v", jobs.list("update")[0])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled job not released")
+	}
+}
+
+func TestHeldCommandRunsWhenWindowOpens(t *testing.T) {
+	setupBatchTest(t)
+	jobs = newJobTable()
+	maintenanceRecheck = 10 * time.Millisecond
+	t.Cleanup(func() { maintenanceRecheck = time.Minute })
+	out := make(chan wsMsg, 1)
+
+	// Held while the window was closed; it has opened since
+	cfgManager.GetConfig().Maintenance = &models.MaintenanceConfig{Windows: []models.MaintenanceWindow{{Cron: "* * * * *", Duration: "1m"}}}
+	patch := wsMsg{kind: "package_update", commandID: "patch"}
+	jobs.enqueue(&patch)
+	holdForWindow(context.Background(), patch, time.Now(), out)
+
+	select {
+	case m := <-out:
+		job := jobs.list(m.jobID)[0]
+		if job.State != models.JobQueued || job.RunAfter != nil {
+			t.Fatalf("released job = %+v", job)
+		}
+		if deferToMaintenanceWindow(context.Background(), m, out) {
+			t.Fatal("held again with the window open")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("job not released when the window opened")
+	}
+}
+
+func TestInvalidMaintenanceWindowsRefuse(t *testing.T) {
+	setupBatchTest(t)
+	jobs = newJobTable()
+	cfgManager.GetConfig().Maintenance = &models.MaintenanceConfig{Windows: []models.MaintenanceWindow{{Start: "22:00"}}}
+
+	done := make(chan error, 1)
+	remediate := wsMsg{kind: "remediate_rule", commandID: "fix", done: done}
+	jobs.enqueue(&remediate)
+	if !deferToMaintenanceWindow(context.Background(), remediate, make(chan wsMsg, 1)) {
+		t.Fatal("ran a held command with invalid windows")
+	}
+	if got := jobs.list("fix")[0]; got.State != models.JobFailed {
+		t.Fatalf("refused job = %+v", got)
+	}
+	if err := <-done; err == nil {
+		t.Fatal("batch step not told it was refused")
+	}
+	if status := maintenanceStatus(); status == nil || status.Error == "" {
+		t.Fatalf("maintenance status = %+v", status)
+	}
+}
@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"patchmon-agent/internal/localapi"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	metricsFormat   string
+	metricsInterval int
+)
+
+// metricsCmd prints host patch metrics for existing dashboards
+var metricsCmd = &cobra.Command{
+	Use:   "metrics [update_every]",
+	Short: "Print patch metrics for Telegraf or Netdata",
+	Long: `Print pending updates, security updates, reboot required and compliance score
+from the running agent (requires local_api_listen).
+
+  --format influx   One InfluxDB line protocol point, for Telegraf's exec input
+  --format netdata  Run as a Netdata external plugin; Netdata passes update_every
+                    as the first argument`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		cfg := cfgManager.GetConfig()
+		if cfg.LocalAPIListen == "" {
+			return errors.New("local API is disabled; set local_api_listen in config.yml")
+		}
+
+		switch metricsFormat {
+		case "influx":
+			sum, err := localapi.FetchSummary(context.Background(), cfg.LocalAPIListen, cfg.LocalAPIToken)
+			if err != nil {
+				return err
+			}
+			fmt.Print(localapi.FormatInflux(*sum, time.Now()))
+			return nil
+		case "netdata":
+			interval := metricsInterval
+			if len(args) == 1 {
+				n, err := strconv.Atoi(args[0])
+				if err != nil || n <= 0 {
+					return fmt.Errorf("invalid update_every %q", args[0])
+				}
+				interval = n
+			}
+			return runNetdataPlugin(cfg.LocalAPIListen, cfg.LocalAPIToken, interval)
+		default:
+			return fmt.Errorf("unknown format %q (use influx or netdata)", metricsFormat)
+		}
+	},
+}
+
+func init() {
+	metricsCmd.Flags().StringVar(&metricsFormat, "format", "influx", "output format: influx or netdata")
+	metricsCmd.Flags().IntVar(&metricsInterval, "interval", 60, "netdata update interval in seconds")
+	rootCmd.AddCommand(metricsCmd)
+}
+
+// runNetdataPlugin speaks the Netdata external plugin protocol on stdout until
+// Netdata closes the pipe. Patch state changes slowly, so the minimum interval
+// is a minute regardless of what Netdata asks for.
+func runNetdataPlugin(listen, token string, interval int) error {
+	interval = max(interval, 60)
+	fmt.Print(localapi.NetdataCharts(interval))
+
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		sum, err := localapi.FetchSummary(ctx, listen, token)
+		cancel()
+		if err != nil {
+			// Netdata reads stderr into its error log
+			fmt.Fprintln(os.Stderr, err)
+		} else if _, err := fmt.Print(localapi.NetdataValues(*sum)); err != nil {
+			return nil // Netdata went away
+		}
+		<-ticker.C
+	}
+}
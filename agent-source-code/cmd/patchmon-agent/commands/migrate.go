@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"patchmon-agent/internal/config"
+	"patchmon-agent/internal/crontab"
+	"patchmon-agent/internal/service"
+
+	"github.com/spf13/cobra"
+)
+
+var migrateTimeout time.Duration
+
+// migratePollInterval is how often migrate-to-service checks for the first report
+var migratePollInterval = 2 * time.Second
+
+// migrateToServiceCmd moves hosts installed by old agents from cron mode to serve
+var migrateToServiceCmd = &cobra.Command{
+	Use:   "migrate-to-service",
+	Short: "Switch a legacy cron-mode install to the agent service",
+	Long: `Upgrade an agent that was installed in cron mode:
+
+  1. Migrate config keys (legacy credentials file, flat compliance keys)
+  2. Install, enable and start the service unit (systemd, OpenRC or FreeBSD rc.d)
+  3. Remove the legacy cron entries (/etc/cron.d/patchmon-agent and root's crontab)
+  4. Wait for the service's first successful report
+
+The cron entries are only removed once the service is running, so a failed
+migration leaves the host reporting as before.`,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		if err := checkRoot(); err != nil {
+			return err
+		}
+		if runtime.GOOS == "windows" {
+			return errors.New("migrate-to-service is not needed on Windows; the installer always registers the Windows service")
+		}
+		if cfgManager.Profile() != "" {
+			return errors.New("migrate-to-service installs the default agent's service; run a profile from a service of its own (see the README)")
+		}
+		return migrateToService(migrateTimeout)
+	},
+}
+
+func init() {
+	migrateToServiceCmd.Flags().DurationVar(&migrateTimeout, "timeout", 3*time.Minute, "how long to wait for the service's first successful report")
+	rootCmd.AddCommand(migrateToServiceCmd)
+}
+
+func migrateToService(timeout time.Duration) error {
+	started := time.Now()
+
+	imported, err := cfgManager.ImportLegacyCredentials(config.LegacyCredentialsFile)
+	if err != nil {
+		return err
+	}
+	if imported {
+		fmt.Printf("✅ Imported %s into %s\n", config.LegacyCredentialsFile, cfgManager.GetConfig().CredentialsFile)
+	}
+	if err := cfgManager.LoadCredentials(); err != nil {
+		return fmt.Errorf("cannot migrate without working credentials: %w", err)
+	}
+	if cfgManager.GetConfig().PatchmonServer == "" {
+		return errors.New("patchmon_server is not set in config.yml")
+	}
+	// Saving rewrites flat keys from older releases in the current layout
+	if err := cfgManager.SaveConfig(); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Config keys up to date in %s\n", cfgManager.GetConfigFile())
+
+	agentPath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate agent binary: %w", err)
+	}
+	initSystem := service.Detect()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	unitPath, written, err := service.Install(ctx, initSystem, agentPath)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to install the %s service (cron entries left in place): %w", initSystem, err)
+	}
+	if written {
+		fmt.Printf("✅ Installed %s\n", unitPath)
+	}
+	fmt.Printf("✅ Enabled and started the %s service\n", initSystem)
+
+	cron := crontab.New(logger)
+	if _, err := os.Stat(config.CronFilePath); err == nil {
+		if err := cron.Remove(); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Removed %s\n", config.CronFilePath)
+	}
+	if n, err := cron.RemoveUserEntries(); err != nil {
+		return err
+	} else if n > 0 {
+		fmt.Printf("✅ Removed %d patchmon-agent entries from root's crontab\n", n)
+	}
+
+	fmt.Printf("⏳ Waiting up to %s for the service's first report...\n", timeout)
+	if !waitForReport(started, timeout) {
+		return fmt.Errorf("the service did not send a successful report within %s; check its logs (%s)", timeout, cfgManager.GetConfig().LogFile)
+	}
+	fmt.Println("✅ Service reported successfully, migration complete")
+	return nil
+}
+
+// waitForReport waits for the service to record a report delivered after since
+func waitForReport(since time.Time, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if loadLastReportSuccess().After(since) {
+			return true
+		}
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(migratePollInterval)
+	}
+}
@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"patchmon-agent/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestWaitForReport(t *testing.T) {
+	cfgManager = config.New()
+	cfgManager.SetConfigFile(filepath.Join(t.TempDir(), "config.yml"))
+	logger = logrus.New()
+	logger.SetOutput(io.Discard)
+	migratePollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { migratePollInterval = 2 * time.Second })
+
+	since := time.Now()
+	if waitForReport(since, 30*time.Millisecond) {
+		t.Fatal("expected no report before the service sent one")
+	}
+
+	// Other state files changing is not a report
+	saveLastReport(nil)
+	if waitForReport(since, 30*time.Millisecond) {
+		t.Fatal("expected only a delivered report to count")
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		recordReportSuccess()
+	}()
+	if !waitForReport(since, 2*time.Second) {
+		t.Fatal("expected the service's report to be seen")
+	}
+	if waitForReport(time.Now(), 30*time.Millisecond) {
+		t.Fatal("expected a report from before the migration not to count")
+	}
+}
@@ -0,0 +1,212 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/notify"
+	"patchmon-agent/internal/redact"
+	"patchmon-agent/internal/utils"
+)
+
+// notifyStateFile remembers what has been notified, so a condition that
+// persists across reports (or report runs from cron) is announced once
+const notifyStateFile = "notify_state.json"
+
+type notifyState struct {
+	ReportFailures int             `json:"reportFailures"`
+	ReportAlerted  bool            `json:"reportAlerted"`
+	RebootRequired bool            `json:"rebootRequired"`
+	ComplianceLow  map[string]bool `json:"complianceLow,omitempty"` // profile -> below threshold
+}
+
+var notifyMu sync.Mutex
+
+func notificationsConfig() *models.NotificationsConfig {
+	return cfgManager.GetConfig().Notifications
+}
+
+// newNotifier builds a notifier that masks events with the configured
+// redaction. An invalid redaction config disables notifications rather than
+// sending them unmasked.
+func newNotifier() *notify.Notifier {
+	redactor, err := redact.New(cfgManager.GetConfig().Redaction)
+	if err != nil {
+		logger.WithError(err).Warn("Redaction config is invalid, not sending notifications")
+		return notify.New(nil, logger)
+	}
+	n := notify.New(notificationsConfig(), logger)
+	n.SetRedactor(redactor)
+	return n
+}
+
+// notifyHostname names the host in notifications as it is reported, so
+// hostname_override and use_fqdn apply
+func notifyHostname() string {
+	hostname, _ := newSystemDetector().GetHostname()
+	return hostname
+}
+
+// updateNotifyState applies fn to the saved state and sends the events it
+// returns. Nothing happens when no notification target is configured.
+func updateNotifyState(fn func(*notifyState) []notify.Event) {
+	n := newNotifier()
+	if !n.Enabled() {
+		return
+	}
+
+	notifyMu.Lock()
+	path := cfgManager.StatePath(notifyStateFile)
+	state := &notifyState{}
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, state)
+	}
+	events := fn(state)
+	if data, err := json.Marshal(state); err == nil {
+		if err := utils.WriteFileAtomic(path, data, 0600); err != nil {
+			logger.WithError(err).Debug("Failed to save notification state")
+		}
+	}
+	notifyMu.Unlock()
+
+	sendNotifications(n, events...)
+}
+
+func sendNotifications(n *notify.Notifier, events ...notify.Event) {
+	if len(events) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	hostname := notifyHostname()
+	for _, ev := range events {
+		ev.Hostname = hostname
+		n.Send(ctx, ev)
+	}
+}
+
+// noteReportResult tracks consecutive report failures, notifying once when
+// report_failure_threshold is reached and again when reports recover
+func noteReportResult(reportErr error) {
+	updateNotifyState(func(s *notifyState) []notify.Event {
+		if reportErr == nil {
+			failures, alerted := s.ReportFailures, s.ReportAlerted
+			s.ReportFailures, s.ReportAlerted = 0, false
+			if !alerted {
+				return nil
+			}
+			return []notify.Event{{
+				Type:    notify.EventReportRecovered,
+				Title:   "PatchMon agent reporting again",
+				Message: fmt.Sprintf("%s reached the PatchMon server after %d failed reports", notifyHostname(), failures),
+			}}
+		}
+
+		s.ReportFailures++
+		threshold := notificationsConfig().ReportFailureThreshold
+		if threshold <= 0 {
+			threshold = notify.DefaultReportFailureThreshold
+		}
+		if s.ReportAlerted || s.ReportFailures < threshold {
+			return nil
+		}
+		s.ReportAlerted = true
+		return []notify.Event{{
+			Type:    notify.EventReportFailed,
+			Title:   "PatchMon agent can't report",
+			Message: fmt.Sprintf("%s failed to report %d times in a row: %v", notifyHostname(), s.ReportFailures, reportErr),
+			Details: map[string]string{"error": reportErr.Error()},
+		}}
+	})
+}
+
+// noteRebootRequired notifies when a host starts needing a reboot
+func noteRebootRequired(needsReboot bool, reason string) {
+	updateNotifyState(func(s *notifyState) []notify.Event {
+		was := s.RebootRequired
+		s.RebootRequired = needsReboot
+		if !needsReboot || was {
+			return nil
+		}
+		message := notifyHostname() + " needs a reboot"
+		if reason != "" {
+			message += ": " + reason
+		}
+		return []notify.Event{{
+			Type:    notify.EventRebootRequired,
+			Title:   "Reboot required",
+			Message: message,
+			Details: map[string]string{"reason": reason},
+		}}
+	})
+}
+
+// noteComplianceScores notifies when a profile's score drops below
+// compliance_score_threshold
+func noteComplianceScores(scans []models.ComplianceScan) {
+	cfg := notificationsConfig()
+	if cfg == nil || cfg.ComplianceScoreThreshold <= 0 {
+		return
+	}
+	updateNotifyState(func(s *notifyState) []notify.Event {
+		if s.ComplianceLow == nil {
+			s.ComplianceLow = map[string]bool{}
+		}
+		var events []notify.Event
+		for _, scan := range scans {
+			if scan.Status != "completed" {
+				continue
+			}
+			low := scan.Score < cfg.ComplianceScoreThreshold
+			was := s.ComplianceLow[scan.ProfileName]
+			s.ComplianceLow[scan.ProfileName] = low
+			if !low || was {
+				continue
+			}
+			events = append(events, notify.Event{
+				Type:    notify.EventComplianceScoreLow,
+				Title:   "Compliance score dropped",
+				Message: fmt.Sprintf("%s scored %.1f%% on %s (threshold %.1f%%)", notifyHostname(), scan.Score, scan.ProfileName, cfg.ComplianceScoreThreshold),
+				Details: map[string]string{
+					"profile": scan.ProfileName,
+					"score":   fmt.Sprintf("%.1f", scan.Score),
+				},
+			})
+		}
+		return events
+	})
+}
+
+// watchContainerEvents passes integration events on to the WebSocket loop and
+// notifies when a container keeps exiting
+func watchContainerEvents(in <-chan interface{}, out chan<- interface{}) {
+	var detector *notify.CrashLoopDetector
+	if cfg := notificationsConfig(); cfg != nil {
+		detector = notify.NewCrashLoopDetector(cfg.CrashLoopRestarts, time.Duration(cfg.CrashLoopWindow)*time.Second)
+	} else {
+		detector = notify.NewCrashLoopDetector(0, 0)
+	}
+	for event := range in {
+		if ev, ok := event.(models.DockerStatusEvent); ok && ev.Type == "container_die" && detector.Exited(ev.ContainerID, ev.Timestamp) {
+			n := newNotifier()
+			if n.Enabled() {
+				go sendNotifications(n, notify.Event{
+					Type:    notify.EventContainerCrashLoop,
+					Title:   "Container crash-looping",
+					Message: fmt.Sprintf("Container %s (%s) on %s exited %d times in %s", ev.Name, ev.Image, notifyHostname(), detector.Restarts(), detector.Window()),
+					Details: map[string]string{
+						"container_id": ev.ContainerID,
+						"container":    ev.Name,
+						"image":        ev.Image,
+					},
+				})
+			}
+		}
+		out <- event
+	}
+}
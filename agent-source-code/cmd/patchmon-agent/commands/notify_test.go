@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"patchmon-agent/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestNotifyHostnameMatchesReports(t *testing.T) {
+	cfgManager = config.New()
+	cfgManager.SetConfigFile(filepath.Join(t.TempDir(), "config.yml"))
+	logger = logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfgManager.GetConfig().UseFQDN = true
+	reported, err := newSystemDetector().GetHostname()
+	if err != nil {
+		t.Fatalf("GetHostname: %v", err)
+	}
+	if got := notifyHostname(); got != reported {
+		t.Fatalf("notifyHostname() = %q, reports use %q", got, reported)
+	}
+
+	cfgManager.GetConfig().HostnameOverride = "db-eu-1"
+	if got := notifyHostname(); got != "db-eu-1" {
+		t.Fatalf("notifyHostname() = %q, want the hostname_override", got)
+	}
+}
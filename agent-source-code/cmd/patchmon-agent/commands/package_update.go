@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/patching"
+	"patchmon-agent/internal/pkgversion"
+)
+
+const (
+	// packageUpdateTimeout bounds a package_update run, kernel and large
+	// dependency updates included
+	packageUpdateTimeout = 60 * time.Minute
+	// packageUpdateLogFile is the transaction log of package_update runs, in
+	// the state directory
+	packageUpdateLogFile = "package_updates.jsonl"
+)
+
+// runPackageUpdate answers package_update: it updates the selected packages,
+// or every package when none are selected, or on a dry run lists what would
+// change. The result is logged and sent to the server whatever it is.
+func runPackageUpdate(ctx context.Context, packageNames []string, dryRun bool, commandID string) error {
+	if runtime.GOOS == "windows" {
+		return fmt.Errorf("package_update is not supported on Windows, use run_patch")
+	}
+	updateCtx, cancel := context.WithTimeout(ctx, packageUpdateTimeout)
+	defer cancel()
+	result := patching.New(logger, packageCacheRefresh()).Apply(updateCtx, patching.Request{
+		Packages: packageNames,
+		DryRun:   dryRun,
+	})
+	result.CommandID = commandID
+
+	if err := patching.AppendLog(cfgManager.StatePath(packageUpdateLogFile), result); err != nil {
+		logger.WithError(err).Warn("Failed to write package update log")
+	}
+
+	detector := newSystemDetector()
+	hostname, _ := detector.GetHostname()
+	sendCtx, sendCancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer sendCancel()
+	if err := commandClient(ctx).SendPackageUpdate(sendCtx, &models.PackageUpdatePayload{
+		Result:       result,
+		Hostname:     hostname,
+		MachineID:    detector.GetMachineID(),
+		AgentVersion: pkgversion.Version,
+	}); err != nil {
+		logger.WithError(err).Warn("Failed to send package update result")
+	}
+
+	// A failed run may still have changed packages
+	if !dryRun && len(result.Packages) > 0 {
+		sendPostPatchReport()
+	}
+	if result.Status == models.PackageUpdateFailed {
+		return errors.New(result.Error)
+	}
+	return nil
+}
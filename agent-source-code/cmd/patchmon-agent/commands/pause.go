@@ -0,0 +1,187 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/client"
+	"patchmon-agent/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// pauseStateFile holds the active pause, shared by the CLI and serve
+	pauseStateFile = "paused.json"
+	// maxPauseDuration caps a pause so a forgotten one can't silence a host for good
+	maxPauseDuration = 7 * 24 * time.Hour
+)
+
+// pausableActions are the server commands held back while paused. Cancelling
+// running work, config sync and SSH/RDP sessions stay available, since remote
+// access is often what incident response needs.
+var pausableActions = map[string]bool{
+	"report_now":                    true,
+	"update_agent":                  true,
+	"update_notification":           true,
+	"run_patch":                     true,
+	"integration_toggle":            true,
+	"compliance_scan":               true,
+	"compliance_ckl_export":         true,
+	"upgrade_ssg":                   true,
+	"install_scanner":               true,
+	"remediate_rule":                true,
+	"docker_image_scan":             true,
+	"docker_update_container":       true,
+	"docker_prune":                  true,
+	"package_update":                true,
+	"docker_inventory_refresh":      true,
+	"hardware_inventory":            true,
+	"set_compliance_mode":           true,
+	"set_compliance_on_demand_only": true,
+	"apply_config":                  true,
+	"secrets_update":                true,
+}
+
+var pauseReason string
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause <duration>",
+	Short: "Suspend reporting, scans and remote actions for a while",
+	Long: `Suspend reports, scheduled compliance scans and server-initiated actions
+(patching, agent updates, scans) for the given duration, e.g. "2h" or "30m",
+during incident response or an in-place OS upgrade. The service stays
+connected and tells the server it is paused, so the host doesn't show as
+offline. It resumes automatically when the duration ends.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		if err := checkRoot(); err != nil {
+			return err
+		}
+		d, err := time.ParseDuration(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", args[0], err)
+		}
+		state, err := savePause(d, pauseReason, "cli")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("⏸️  Agent paused until %s\n", state.Until.Local().Format("2006-01-02 15:04:05"))
+		fmt.Println("   The running service picks this up within a minute. Resume early with: patchmon-agent resume")
+		return nil
+	},
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "End a pause started with pause",
+	RunE: func(_ *cobra.Command, _ []string) error {
+		if err := checkRoot(); err != nil {
+			return err
+		}
+		cleared, err := clearPause()
+		if err != nil {
+			return err
+		}
+		if !cleared {
+			fmt.Println("Agent is not paused")
+			return nil
+		}
+		fmt.Println("▶️  Agent resumed; the running service reports within a minute")
+		return nil
+	},
+}
+
+func init() {
+	pauseCmd.Flags().StringVar(&pauseReason, "reason", "", "why the agent is paused (shown on the server)")
+	rootCmd.AddCommand(pauseCmd)
+	rootCmd.AddCommand(resumeCmd)
+}
+
+// loadPause returns the active pause, or nil. An expired pause file is removed.
+func loadPause() *models.PauseState {
+	path := cfgManager.StatePath(pauseStateFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var state models.PauseState
+	if err := json.Unmarshal(data, &state); err != nil {
+		logger.WithError(err).Warn("Ignoring unreadable pause state")
+		return nil
+	}
+	if !time.Now().Before(state.Until) {
+		_ = os.Remove(path)
+		return nil
+	}
+	return &state
+}
+
+func savePause(d time.Duration, reason, source string) (*models.PauseState, error) {
+	if d <= 0 {
+		return nil, errors.New("pause duration must be positive")
+	}
+	if d > maxPauseDuration {
+		return nil, fmt.Errorf("pause duration %s exceeds the maximum of %s", d, maxPauseDuration)
+	}
+	now := time.Now()
+	state := &models.PauseState{PausedAt: now, Until: now.Add(d), Reason: reason, Source: source}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.WriteFileAtomic(cfgManager.StatePath(pauseStateFile), data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save pause state: %w", err)
+	}
+	return state, nil
+}
+
+// clearPause removes the pause, reporting whether there was one
+func clearPause() (bool, error) {
+	active := loadPause() != nil
+	if err := os.Remove(cfgManager.StatePath(pauseStateFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, fmt.Errorf("failed to clear pause state: %w", err)
+	}
+	return active, nil
+}
+
+// skipWhilePaused logs and returns true if what should be held back
+func skipWhilePaused(what string) bool {
+	state := loadPause()
+	if state == nil {
+		return false
+	}
+	logger.WithFields(logrus.Fields{
+		"action": what,
+		"until":  state.Until.Format(time.RFC3339),
+	}).Info("Agent paused, skipping")
+	return true
+}
+
+// pauseChanged reports whether the pause state differs between two checks
+func pauseChanged(before, after *models.PauseState) bool {
+	if before == nil || after == nil {
+		return before != after
+	}
+	return !before.Until.Equal(after.Until)
+}
+
+// sendPauseStatus pings the server so it shows the current pause state
+func sendPauseStatus(ctx context.Context, httpClient *client.Client, state *models.PauseState) {
+	req := &models.PingRequest{Status: "active", LastActions: lastActions(), Jobs: jobsForPing(), Resources: agentResources.sample(), Maintenance: maintenanceStatus()}
+	if state != nil {
+		req.Status = "paused"
+		req.Paused = state
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if _, err := httpClient.Ping(ctx, req); err != nil {
+		logger.WithError(err).Warn("Failed to send pause status to server")
+	}
+}
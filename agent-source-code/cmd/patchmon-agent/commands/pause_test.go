@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"patchmon-agent/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestPauseLifecycle(t *testing.T) {
+	cfgManager = config.New()
+	cfgManager.SetConfigFile(filepath.Join(t.TempDir(), "config.yml"))
+	logger = logrus.New()
+	logger.SetOutput(io.Discard)
+
+	if loadPause() != nil {
+		t.Fatal("expected no pause initially")
+	}
+	if _, err := savePause(0, "", "cli"); err == nil {
+		t.Fatal("expected zero duration to be rejected")
+	}
+	if _, err := savePause(30*24*time.Hour, "", "cli"); err == nil {
+		t.Fatal("expected a month-long pause to be rejected")
+	}
+
+	state, err := savePause(time.Hour, "OS upgrade", "server")
+	if err != nil {
+		t.Fatalf("savePause: %v", err)
+	}
+	loaded := loadPause()
+	if loaded == nil || loaded.Reason != "OS upgrade" || !loaded.Until.Equal(state.Until) {
+		t.Fatalf("loadPause = %+v, want %+v", loaded, state)
+	}
+	if !skipWhilePaused("report") {
+		t.Fatal("expected actions to be skipped while paused")
+	}
+	if pauseChanged(state, loaded) || !pauseChanged(nil, loaded) || !pauseChanged(loaded, nil) {
+		t.Fatal("pauseChanged gave the wrong answer")
+	}
+
+	cleared, err := clearPause()
+	if err != nil || !cleared {
+		t.Fatalf("clearPause = %v, %v", cleared, err)
+	}
+	if loadPause() != nil {
+		t.Fatal("expected pause to be cleared")
+	}
+
+	// Expired pauses resume on their own
+	if _, err := savePause(time.Nanosecond, "", "cli"); err != nil {
+		t.Fatalf("savePause: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if loadPause() != nil {
+		t.Fatal("expected expired pause to be ignored")
+	}
+}
@@ -0,0 +1,168 @@
+package commands
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/config"
+	"patchmon-agent/internal/logutil"
+
+	"github.com/sirupsen/logrus"
+)
+
+// observerRefused are the server commands that change the host or the agent.
+// In observer mode they are refused so the agent only collects and reports;
+// scans, inventory refreshes, settings sync, pause and cancels still work.
+var observerRefused = map[string]bool{
+	"update_agent":                  true,
+	"run_patch":                     true,
+	"integration_toggle":            true,
+	"upgrade_ssg":                   true,
+	"install_scanner":               true,
+	"remediate_rule":                true,
+	"docker_update_container":       true,
+	"set_compliance_mode":           true,
+	"set_compliance_on_demand_only": true,
+	"apply_config":                  true,
+	"secrets_update":                true,
+	"ssh_proxy":                     true,
+	"rdp_proxy":                     true,
+}
+
+// actionPermissions returns the allow_* flags a server command needs
+func actionPermissions(m wsMsg) []string {
+	switch m.kind {
+	case "report_now":
+		// A compliance section runs a full scan
+		if slices.Contains(m.reportSections, "compliance") {
+			return []string{config.AllowReportNow, config.AllowComplianceScan}
+		}
+		return []string{config.AllowReportNow}
+	case "hardware_inventory":
+		return []string{config.AllowReportNow}
+	case "compliance_scan":
+		if m.enableRemediation {
+			return []string{config.AllowComplianceScan, config.AllowRemediation}
+		}
+		return []string{config.AllowComplianceScan}
+	case "compliance_ckl_export":
+		return []string{config.AllowComplianceScan}
+	case "remediate_rule":
+		return []string{config.AllowRemediation}
+	case "update_agent":
+		return []string{config.AllowAgentUpdate}
+	case "update_notification":
+		if m.force {
+			return []string{config.AllowAgentUpdate}
+		}
+	case "ssh_proxy":
+		return []string{config.AllowSSHProxy}
+	case "rdp_proxy":
+		return []string{config.AllowRDPProxy}
+	case "run_patch":
+		if !m.dryRun {
+			return []string{config.AllowRunPatch}
+		}
+	case "docker_inventory_refresh", "docker_image_scan", "docker_update_container", "docker_prune":
+		return []string{config.AllowDockerActions}
+	case "package_update":
+		if !m.dryRun {
+			return []string{config.AllowPackageUpdate}
+		}
+	}
+	return nil
+}
+
+// remoteActionRefusal returns why a server command must not run, or "" if it
+// may. observer in a servers entry's credentials file puts commands from that
+// server in observer mode.
+func remoteActionRefusal(m wsMsg) string {
+	if cfgManager.IsObserverMode() || (m.server != nil && m.server.cfg.IsObserverMode()) {
+		switch {
+		case observerRefused[m.kind]:
+			return "agent is in observer mode"
+		case m.kind == "compliance_scan" && m.enableRemediation:
+			return "agent is in observer mode, remediation is not allowed"
+		case m.kind == "update_notification" && m.force:
+			return "agent is in observer mode, forced updates are not allowed"
+		case m.kind == "docker_prune" && !m.dryRun:
+			return "agent is in observer mode, only dry runs of docker_prune are allowed"
+		case m.kind == "package_update" && !m.dryRun:
+			return "agent is in observer mode, only dry runs of package_update are allowed"
+		}
+	}
+	for _, flag := range actionPermissions(m) {
+		if !cfgManager.IsActionAllowed(flag) {
+			return flag + " is false in config.yml"
+		}
+	}
+	return ""
+}
+
+// dockerBenchImageRefusal returns why the docker_bench_image in a
+// settings_update must not be applied, or "" if it may. The image runs
+// privileged with the Docker socket mounted, so the server may only move it to
+// a digest-pinned image, and not at all in observer mode.
+func dockerBenchImageRefusal(m wsMsg) string {
+	switch {
+	case cfgManager.IsObserverMode() || (m.server != nil && m.server.cfg.IsObserverMode()):
+		return "agent is in observer mode"
+	case !config.DigestPinnedImageRef(m.dockerBenchImage):
+		return "image is not pinned by digest (repo@sha256:...)"
+	}
+	return ""
+}
+
+// refuseRemoteAction logs a refused server command and, where the server waits
+// on an answer (patch runs, proxy sessions), tells it why. It reports whether
+// the command was refused.
+func refuseRemoteAction(m wsMsg) bool {
+	reason := remoteActionRefusal(m)
+	if reason == "" {
+		return false
+	}
+	logger.WithFields(logrus.Fields{
+		"action": m.kind,
+		"reason": reason,
+	}).Warn("Refusing server command")
+	recordActionOutcome(m, actionRefused, reason)
+	nackCommand(m.replyConn(), m.kind, m.commandID, models.NackRefused, reason)
+
+	switch m.kind {
+	case "run_patch":
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := commandClient(withServer(ctx, m.server)).SendPatchOutput(ctx, m.patchRunID, "failed", "", "refused: "+reason); err != nil {
+			logger.WithError(err).WithField("patch_run_id", logutil.Sanitize(m.patchRunID)).Debug("Failed to report refused patch run")
+		}
+	case "ssh_proxy", "rdp_proxy":
+		globalWsConnMu.RLock()
+		wsConn := globalWsConn
+		globalWsConnMu.RUnlock()
+		if wsConn == nil {
+			break
+		}
+		if m.kind == "ssh_proxy" {
+			sendSSHProxyError(wsConn, m.sshProxySessionID, "refused: "+reason)
+		} else {
+			sendRDPProxyError(wsConn, m.rdpProxySessionID, "refused: "+reason)
+		}
+	}
+	return true
+}
+
+// autoUpdateAllowed reports whether the agent may update itself when the server
+// says an update is due
+func autoUpdateAllowed() bool {
+	if cfgManager.IsObserverMode() {
+		logger.Info("Agent update available but observer mode is on, not updating")
+		return false
+	}
+	if !cfgManager.IsActionAllowed(config.AllowAgentUpdate) {
+		logger.Info("Agent update available but allow_agent_update is false, not updating")
+		return false
+	}
+	return true
+}
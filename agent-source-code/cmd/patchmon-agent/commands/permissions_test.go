@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"patchmon-agent/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestObserverModeRefusesMutatingCommands(t *testing.T) {
+	cfgManager = config.New()
+	cfgManager.SetConfigFile(filepath.Join(t.TempDir(), "config.yml"))
+	logger = logrus.New()
+	logger.SetOutput(io.Discard)
+
+	if reason := remoteActionRefusal(wsMsg{kind: "update_agent"}); reason != "" {
+		t.Fatalf("update_agent refused outside observer mode: %s", reason)
+	}
+
+	cfgManager.GetConfig().ObserverMode = true
+	for _, m := range []wsMsg{
+		{kind: "update_agent"},
+		{kind: "run_patch"},
+		{kind: "ssh_proxy"},
+		{kind: "integration_toggle"},
+		{kind: "secrets_update"},
+		{kind: "docker_update_container"},
+		{kind: "compliance_scan", enableRemediation: true},
+		{kind: "update_notification", force: true},
+		{kind: "docker_prune"},
+		{kind: "package_update"},
+	} {
+		if remoteActionRefusal(m) == "" {
+			t.Errorf("%s (remediation=%v, force=%v) allowed in observer mode", m.kind, m.enableRemediation, m.force)
+		}
+	}
+	for _, m := range []wsMsg{
+		{kind: "report_now"},
+		{kind: "compliance_scan"},
+		{kind: "docker_inventory_refresh"},
+		{kind: "hardware_inventory"},
+		{kind: "update_notification"},
+		{kind: "docker_prune", dryRun: true},
+		{kind: "package_update", dryRun: true},
+		{kind: "pause"},
+	} {
+		if reason := remoteActionRefusal(m); reason != "" {
+			t.Errorf("%s refused in observer mode: %s", m.kind, reason)
+		}
+	}
+}
+
+func TestAllowFlagsGateServerCommands(t *testing.T) {
+	cfgManager = config.New()
+	cfgManager.SetConfigFile(filepath.Join(t.TempDir(), "config.yml"))
+	logger = logrus.New()
+	logger.SetOutput(io.Discard)
+
+	off := false
+	cfgManager.GetConfig().AllowRemediation = &off
+	cfgManager.GetConfig().AllowDockerActions = &off
+
+	if remoteActionRefusal(wsMsg{kind: "compliance_scan"}) != "" {
+		t.Error("a scan without remediation should be allowed")
+	}
+	for _, m := range []wsMsg{
+		{kind: "compliance_scan", enableRemediation: true},
+		{kind: "remediate_rule"},
+		{kind: "docker_image_scan"},
+		{kind: "docker_inventory_refresh"},
+		{kind: "docker_update_container"},
+		{kind: "docker_prune", dryRun: true},
+	} {
+		if remoteActionRefusal(m) == "" {
+			t.Errorf("%s (remediation=%v) allowed with its allow_* flag off", m.kind, m.enableRemediation)
+		}
+	}
+	cfgManager.GetConfig().AllowComplianceScan = &off
+	if reason := remoteActionRefusal(wsMsg{kind: "report_now", reportSections: []string{"docker"}}); reason != "" {
+		t.Errorf("report_now refused with allow_compliance_scan off: %s", reason)
+	}
+	if remoteActionRefusal(wsMsg{kind: "report_now", reportSections: []string{"docker", "compliance"}}) == "" {
+		t.Error("report_now with a compliance section allowed with allow_compliance_scan off")
+	}
+	cfgManager.GetConfig().AllowComplianceScan = nil
+
+	if remoteActionRefusal(wsMsg{kind: "update_agent"}) != "" {
+		t.Error("unset allow_agent_update should default to allowed")
+	}
+
+	cfgManager.GetConfig().AllowRDPProxy = &off
+	cfgManager.GetConfig().AllowRunPatch = &off
+	if remoteActionRefusal(wsMsg{kind: "rdp_proxy"}) == "" {
+		t.Error("rdp_proxy allowed with allow_rdp_proxy off")
+	}
+	if remoteActionRefusal(wsMsg{kind: "ssh_proxy"}) != "" {
+		t.Error("allow_rdp_proxy should not affect ssh_proxy")
+	}
+	if remoteActionRefusal(wsMsg{kind: "run_patch"}) == "" {
+		t.Error("run_patch allowed with allow_run_patch off")
+	}
+	if reason := remoteActionRefusal(wsMsg{kind: "run_patch", dryRun: true}); reason != "" {
+		t.Errorf("run_patch dry run refused: %s", reason)
+	}
+
+	// package_update is off until config.yml allows it; dry runs always work
+	if remoteActionRefusal(wsMsg{kind: "package_update"}) == "" {
+		t.Error("unset allow_package_update should default to refused")
+	}
+	if reason := remoteActionRefusal(wsMsg{kind: "package_update", dryRun: true}); reason != "" {
+		t.Errorf("package_update dry run refused: %s", reason)
+	}
+	on := true
+	cfgManager.GetConfig().AllowPackageUpdate = &on
+	if reason := remoteActionRefusal(wsMsg{kind: "package_update"}); reason != "" {
+		t.Errorf("package_update refused with allow_package_update on: %s", reason)
+	}
+}
+
+func TestServerDockerBenchImageMustBePinned(t *testing.T) {
+	cfgManager = config.New()
+	cfgManager.SetConfigFile(filepath.Join(t.TempDir(), "config.yml"))
+
+	pinned := "jauderho/docker-bench-security@sha256:" + strings.Repeat("ab", 32)
+	if reason := dockerBenchImageRefusal(wsMsg{kind: "settings_update", dockerBenchImage: pinned}); reason != "" {
+		t.Errorf("pinned image refused: %s", reason)
+	}
+	for _, image := range []string{"jauderho/docker-bench-security:latest", "evil/bench", "--privileged"} {
+		if dockerBenchImageRefusal(wsMsg{kind: "settings_update", dockerBenchImage: image}) == "" {
+			t.Errorf("%s accepted without a digest", image)
+		}
+	}
+
+	cfgManager.GetConfig().ObserverMode = true
+	if dockerBenchImageRefusal(wsMsg{kind: "settings_update", dockerBenchImage: pinned}) == "" {
+		t.Error("image change accepted in observer mode")
+	}
+}
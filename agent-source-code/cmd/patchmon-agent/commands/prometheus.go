@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"patchmon-agent/internal/metrics"
+	"patchmon-agent/internal/pkgversion"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// Metrics served on metrics_listen. Counters and summaries are updated as
+// things happen; the rest are read from the agent's state on each scrape.
+var (
+	promMetrics = metrics.NewRegistry()
+
+	agentInfo       = promMetrics.Gauge("patchmon_agent_info", "Agent version, always 1.", "version")
+	reportsTotal    = promMetrics.Counter("patchmon_agent_reports_total", "Reports sent to patchmon_server, by result.", "result")
+	wsConnectsTotal = promMetrics.Counter("patchmon_agent_websocket_connects_total", "WebSocket connections established, by server.", "server")
+	wsConnected     = promMetrics.Gauge("patchmon_agent_websocket_connected", "Whether the WebSocket to patchmon_server is up.")
+	lastReportTime  = promMetrics.Gauge("patchmon_agent_last_report_timestamp_seconds", "Unix time of the last successful report.")
+	scanDuration    = promMetrics.Summary("patchmon_agent_scan_duration_seconds", "Time taken by completed scans, by scanner.", "scan")
+	packagesTotal   = promMetrics.Gauge("patchmon_agent_packages_total", "Installed packages in the last report.")
+	packagesPending = promMetrics.Gauge("patchmon_agent_packages_pending", "Packages with an update available.")
+	securityPending = promMetrics.Gauge("patchmon_agent_security_updates_pending", "Packages with a security update available.")
+	rebootRequired  = promMetrics.Gauge("patchmon_agent_reboot_required", "Whether the host needs a reboot.")
+	complianceScore = promMetrics.Gauge("patchmon_agent_compliance_score", "Lowest score across the latest compliance scans.")
+)
+
+// noteReportMetric counts a report sent to patchmon_server
+func noteReportMetric(err error) {
+	if err != nil {
+		reportsTotal.Inc("failure")
+		return
+	}
+	reportsTotal.Inc("success")
+}
+
+// noteScanDurations records how long each completed scan took
+func noteScanDurations(scans []models.ComplianceScan) {
+	for _, scan := range scans {
+		if scan.CompletedAt == nil || scan.StartedAt.IsZero() {
+			continue
+		}
+		scanType := scan.ProfileType
+		if scanType == "" {
+			scanType = "openscap"
+		}
+		scanDuration.Observe(scan.CompletedAt.Sub(scan.StartedAt).Seconds(), scanType)
+	}
+}
+
+// collectMetrics sets the gauges read from the agent's state
+func collectMetrics() {
+	agentInfo.Set(1, pkgversion.Version)
+
+	wsConnected.Set(boolMetric(currentWsConn() != nil))
+	if health := getAgentHealth(); health.LastReportAt != nil {
+		lastReportTime.Set(float64(health.LastReportAt.Unix()))
+	}
+
+	summary := localState.Summary()
+	if summary.CollectedAt == nil {
+		// Nothing collected yet; zeros would read as a fully patched host
+		return
+	}
+	packagesTotal.Set(float64(summary.TotalPackages))
+	packagesPending.Set(float64(summary.PendingUpdates))
+	securityPending.Set(float64(summary.SecurityUpdates))
+	rebootRequired.Set(boolMetric(summary.RebootRequired))
+	if summary.ComplianceScore != nil {
+		complianceScore.Set(*summary.ComplianceScore)
+	}
+}
+
+func boolMetric(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// runMetricsListener serves /metrics on listen until ctx is cancelled
+func runMetricsListener(ctx context.Context, listen string) {
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		logger.WithError(err).Warn("Metrics listener disabled")
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promMetrics.Handler(collectMetrics))
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	logger.WithField("address", ln.Addr().String()).Info("Metrics listening")
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.WithError(err).Warn("Metrics listener stopped")
+	}
+}
@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"patchmon-agent/internal/localapi"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+func scrape(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	promMetrics.Handler(collectMetrics).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	return rec.Body.String()
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	localState = localapi.NewState()
+	t.Cleanup(func() { localState = localapi.NewState() })
+
+	// No package gauges until a report has been collected
+	if body := scrape(t); strings.Contains(body, "patchmon_agent_packages_pending") || !strings.Contains(body, "patchmon_agent_info{") {
+		t.Fatalf("metrics before the first report:\n%s", body)
+	}
+
+	localState.SetReport(&models.ReportPayload{
+		NeedsReboot: true,
+		Packages: []models.Package{
+			{Name: "openssl", NeedsUpdate: true, IsSecurityUpdate: true},
+			{Name: "vim", NeedsUpdate: true},
+			{Name: "bash"},
+		},
+	})
+	started := time.Now().Add(-90 * time.Second)
+	done := started.Add(90 * time.Second)
+	noteScanDurations([]models.ComplianceScan{
+		{ProfileType: "docker-bench", StartedAt: started, CompletedAt: &done},
+		{ProfileType: "openscap", StartedAt: started}, // still running
+	})
+	noteReportMetric(nil)
+
+	body := scrape(t)
+	for _, want := range []string{
+		"patchmon_agent_packages_total 3\n",
+		"patchmon_agent_packages_pending 2\n",
+		"patchmon_agent_security_updates_pending 1\n",
+		"patchmon_agent_reboot_required 1\n",
+		`patchmon_agent_reports_total{result="success"}`,
+		`patchmon_agent_scan_duration_seconds_sum{scan="docker-bench"} 90` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `scan="openscap"`) {
+		t.Errorf("unfinished scan observed:\n%s", body)
+	}
+}
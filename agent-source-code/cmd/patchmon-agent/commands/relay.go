@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/client"
+	"patchmon-agent/internal/relay"
+	"patchmon-agent/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// relayCmd runs only the relay, for a bastion that isn't itself monitored
+var relayCmd = &cobra.Command{
+	Use:   "relay",
+	Short: "Forward other agents' traffic to the PatchMon server",
+	Long: `Accept API and WebSocket traffic from agents that cannot reach the PatchMon
+server and forward it to patchmon_server. Agents point relay_url at this host.
+
+relay.listen is "host:port" for agents on other hosts, which must present a
+client certificate signed by relay.ca_file, or "unix:/path.sock" for agents in
+containers on this host. serve also runs the relay when relay.listen is set,
+so a monitored bastion needs no separate service.`,
+	Args: cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		cfg := cfgManager.GetConfig()
+		if cfg.Relay == nil || cfg.Relay.Listen == "" {
+			return errors.New("relay.listen is not set in config.yml")
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return runRelay(ctx, cfg)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(relayCmd)
+}
+
+// runRelay serves relay.listen until ctx is cancelled
+func runRelay(ctx context.Context, cfg *models.Config) error {
+	srv, err := relay.New(logger, cfg)
+	if err != nil {
+		return err
+	}
+	ln, err := relay.Listen(cfg.Relay)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(ctx, ln)
+}
+
+// printRelayReachable reports whether relay_url can be reached and its
+// certificates loaded, for diagnostics
+func printRelayReachable(cfg *models.Config) {
+	if _, err := client.RelayTLSConfig(cfg, false); err != nil {
+		fmt.Printf("  ❌ Relay config is invalid: %v\n", err)
+		return
+	}
+	if path, ok := client.RelaySocket(cfg); ok {
+		conn, err := net.DialTimeout("unix", path, 5*time.Second)
+		if err != nil {
+			fmt.Printf("  ❌ Relay socket is not reachable: %v\n", err)
+			return
+		}
+		_ = conn.Close()
+		fmt.Printf("  ✅ Relay socket is reachable\n")
+		return
+	}
+	host, port := extractURLHostAndPort(cfg.RelayURL)
+	if utils.TCPPing(host, port) {
+		fmt.Printf("  ✅ Relay is reachable\n")
+	} else {
+		fmt.Printf("  ❌ Relay is not reachable\n")
+	}
+}
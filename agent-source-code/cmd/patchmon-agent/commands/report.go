@@ -7,46 +7,202 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 	"patchmon-agent/internal/client"
+	"patchmon-agent/internal/config"
 	"patchmon-agent/internal/hardware"
+	"patchmon-agent/internal/ignore"
 	"patchmon-agent/internal/integrations"
+	"patchmon-agent/internal/integrations/accounts"
 	"patchmon-agent/internal/integrations/compliance"
 	"patchmon-agent/internal/integrations/docker"
+	"patchmon-agent/internal/integrations/jails"
+	"patchmon-agent/internal/integrations/kubernetes"
+	"patchmon-agent/internal/integrations/langpkg"
+	"patchmon-agent/internal/integrations/nspawn"
+	"patchmon-agent/internal/integrations/proxmox"
+	"patchmon-agent/internal/integrations/schedtasks"
+	"patchmon-agent/internal/integrations/tlscerts"
+	"patchmon-agent/internal/keyservices"
 	"patchmon-agent/internal/network"
 	"patchmon-agent/internal/packages"
 	"patchmon-agent/internal/pkgversion"
 	"patchmon-agent/internal/repositories"
+	"patchmon-agent/internal/sshd"
 	"patchmon-agent/internal/system"
-	"patchmon-agent/pkg/models"
+	"patchmon-agent/internal/tracing"
+	"patchmon-agent/internal/utils"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-var reportJSON bool
+var (
+	reportJSON     bool
+	reportSections []string
+)
 
 // reportCmd represents the report command
 var reportCmd = &cobra.Command{
 	Use:   "report",
 	Short: "Report system and package information to server",
-	Long:  "Collect and report system, package, and repository information to the PatchMon server.",
+	Long: `Collect and report system, package, and repository information to the PatchMon server.
+
+With --sections only the listed sections are collected again (packages, repos,
+hardware, network, docker, compliance); the rest is carried over from the last
+report, e.g. --sections hardware after a RAM upgrade.`,
 	RunE: func(_ *cobra.Command, _ []string) error {
 		if err := checkRoot(); err != nil {
 			return err
 		}
 
-		return sendReport(reportJSON)
+		if !reportJSON && skipLegacyCronReport() {
+			return nil
+		}
+
+		sections, err := parseReportSections(reportSections)
+		if err != nil {
+			return err
+		}
+		applyToolPolicy()
+		if !reportJSON {
+			defer startTracing()()
+		}
+		return sendReportSections(reportJSON, sections)
 	},
 }
 
+// Agent state files kept next to config.yml
+const (
+	// packageCountHistoryFile holds recent package counts for drop detection
+	packageCountHistoryFile = "package_count_history.json"
+	// pendingUpdatesFile holds when each pending update was first seen
+	pendingUpdatesFile = "pending_updates.json"
+	// osvCacheFile caches OSV lookups for language packages
+	osvCacheFile = "osv_cache.json"
+	// dockerDigestCacheFile caches what registries said running containers'
+	// tags point to
+	dockerDigestCacheFile = "docker_digest_cache.json"
+	// lastHostnameFile is the hostname of the last successful report, used to
+	// tell the server about renames
+	lastHostnameFile = "last_hostname"
+	// lastReportFile is the last report sent, which partial reports start from
+	lastReportFile = "last_report.json"
+	// lastReportSuccessFile holds when a report was last delivered, for
+	// migrate-to-service to see the service's first one
+	lastReportSuccessFile = "last_report_success"
+	// bootStateFile holds the boot the last report came from, to spot reboots
+	bootStateFile = "boot_state.json"
+)
+
+// recordPendingSince sets PendingSince on packages needing an update from the
+// locally kept first-seen dates, and saves the dates for the next report
+func recordPendingSince(pkgs []models.Package) {
+	path := cfgManager.StatePath(pendingUpdatesFile)
+	history, err := packages.LoadPendingHistory(path)
+	if err != nil {
+		logger.WithError(err).Warn("Pending update history unavailable, starting over")
+	}
+	history.Apply(pkgs, time.Now())
+	if err := history.Save(path); err != nil {
+		logger.WithError(err).Debug("Failed to save pending update history")
+	}
+}
+
+// newSystemDetector returns a system detector honouring hostname_override,
+// use_fqdn and a machine ID from identity reset, so every collector reports the
+// same host
+func newSystemDetector() *system.Detector {
+	cfg := cfgManager.GetConfig()
+	return applyMachineIdentity(system.New(logger).WithHostnameOptions(system.HostnameOptions{
+		Override: cfg.HostnameOverride,
+		UseFQDN:  cfg.UseFQDN,
+	}))
+}
+
+// packageCacheRefresh returns the package_cache_refresh settings for package
+// collection and the package installs done by scanners
+func packageCacheRefresh() packages.CacheRefreshConfig {
+	return packages.CacheRefreshConfig{
+		Mode:   cfgManager.GetPackageCacheRefreshMode(),
+		MaxAge: cfgManager.GetPackageCacheRefreshMaxAge(),
+	}
+}
+
+// applyToolPolicy passes package_cache_refresh, auto_install_tools (always off
+// in observer mode), the Docker Bench script/image settings, the HTML report
+// directory and any imported content on to the compliance scanners
+func applyToolPolicy() {
+	cfg := cfgManager.GetConfig()
+	compliance.SetPackageCacheRefresh(packageCacheRefresh())
+	compliance.SetAutoInstallTools(cfgManager.GetAutoInstallTools() && !cfgManager.IsObserverMode())
+	compliance.SetDockerBenchScript(cfg.DockerBenchScript)
+	if cfg.DockerBenchImage != "" && !config.ValidImageRef(cfg.DockerBenchImage) {
+		logger.WithField("docker_bench_image", cfg.DockerBenchImage).Warn("Ignoring invalid docker_bench_image, using the default image")
+		compliance.SetDockerBenchImage("")
+	} else {
+		compliance.SetDockerBenchImage(cfg.DockerBenchImage)
+	}
+	compliance.SetHTMLReportDir(cfgManager.StatePath(complianceReportDir))
+	feed := compliance.CVEFeedSettings{
+		URL:    cfg.CVEFeedURL,
+		MaxAge: time.Duration(cfg.CVEFeedMaxAge) * time.Hour,
+	}
+	if cfgManager.GetCVEFeedCache() {
+		feed.Dir = cfgManager.StatePath(cveFeedDir)
+	}
+	applyImportedContent(cfg, &feed)
+	compliance.SetCVEFeed(feed)
+	for _, err := range compliance.SetCVEWaivers(cfg.ImageCVEWaivers) {
+		logger.WithError(err).Warn("Skipping invalid image CVE waiver")
+	}
+}
+
 func init() {
 	reportCmd.Flags().BoolVar(&reportJSON, "json", false, "Output the JSON report payload to stdout instead of sending to server")
+	reportCmd.Flags().StringSliceVar(&reportSections, "sections", nil, "only refresh these sections (packages, repos, hardware, network, docker, compliance)")
+}
+
+// applyIgnoreList filters items through the configured ignore patterns, logging
+// invalid patterns instead of failing the report
+func applyIgnoreList[T any](kind string, patterns []string, items []T, filter func(*ignore.Matcher, []T) ([]T, int)) []T {
+	if len(patterns) == 0 {
+		return items
+	}
+	matcher, errs := ignore.Compile(patterns)
+	for _, err := range errs {
+		logger.WithError(err).Warnf("Skipping invalid %s ignore pattern", kind)
+	}
+	kept, removed := filter(matcher, items)
+	if removed > 0 {
+		logger.WithFields(logrus.Fields{"kind": kind, "ignored": removed}).Debug("Applied ignore list")
+	}
+	return kept
 }
 
 func sendReport(outputJSON bool) error {
+	return sendReportSections(outputJSON, nil)
+}
+
+// sendReportSections sends a report refreshing only the given sections, or a
+// full report when sections is empty
+func sendReportSections(outputJSON bool, only []string) error {
+	ctx, span := tracing.Start(context.Background(), "report",
+		attribute.StringSlice("report.sections", only),
+		attribute.Bool("report.json", outputJSON))
+	err := collectAndSendReport(ctx, outputJSON, only)
+	tracing.End(span, err)
+	return err
+}
+
+// collectAndSendReport does the work of sendReportSections under its span
+func collectAndSendReport(ctx context.Context, outputJSON bool, only []string) error {
 	// Start tracking execution time
 	startTime := time.Now()
 	logger.Debug("Starting report process")
@@ -61,17 +217,39 @@ func sendReport(outputJSON bool) error {
 			logger.WithError(err).Debug("Failed to load credentials")
 			return err
 		}
+		if err := registrationConflictHold(newSystemDetector().GetMachineID()); err != nil {
+			return err
+		}
+	}
+
+	// A partial report re-collects the requested core sections and carries the
+	// rest over from the last report sent; docker and compliance are refreshed
+	// on their own after it
+	core, extras := splitReportSections(only)
+	var previous *models.ReportPayload
+	if len(core) > 0 {
+		if previous = loadLastReport(); previous == nil {
+			logger.Info("No previous report to refresh from, sending a full report")
+			only, core, extras = nil, nil, nil
+		}
+	}
+	if len(only) > 0 && len(core) == 0 {
+		if outputJSON {
+			return errors.New("--json needs at least one of packages, repos, hardware or network")
+		}
+		return refreshExtraSections(ctx, extras)
+	}
+	want := func(section string) bool {
+		return len(only) == 0 || slices.Contains(core, section)
 	}
 
 	// Initialise managers
-	systemDetector := system.New(logger)
-	packageMgr := packages.New(logger, packages.CacheRefreshConfig{
-		Mode:   cfgManager.GetPackageCacheRefreshMode(),
-		MaxAge: cfgManager.GetPackageCacheRefreshMaxAge(),
-	})
+	systemDetector := newSystemDetector()
+	packageMgr := packages.New(logger, packageCacheRefresh())
 	repoMgr := repositories.New(logger)
 	hardwareMgr := hardware.New(logger)
 	networkMgr := network.New(logger)
+	sshdMgr := sshd.New(logger)
 
 	// OPTIMIZATION: Run all independent collectors concurrently. Each of these
 	// pieces of work is IO-bound (file reads, subprocess spawns) with no data
@@ -94,6 +272,9 @@ func sendReport(outputJSON bool) error {
 		pkgErr                        error
 		repoList                      []models.Repository
 		repoErr                       error
+		subscription                  *models.SubscriptionStatus
+		sshdConfig                    *models.SSHDConfig
+		sshdErr                       error
 		machineID, detectedPackageMgr string
 	)
 
@@ -110,6 +291,8 @@ func sendReport(outputJSON bool) error {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
+			_, span := tracing.Start(ctx, "collect."+name)
+			defer span.End()
 			defer func() {
 				if r := recover(); r != nil {
 					panicMu.Lock()
@@ -127,19 +310,49 @@ func sendReport(outputJSON bool) error {
 	runTask("architecture", func() { architecture = systemDetector.GetArchitecture() })
 	runTask("systemInfo", func() { systemInfo = systemDetector.GetSystemInfo() })
 	runTask("ip", func() { ipAddress = systemDetector.GetIPAddress() })
-	runTask("hardware", func() { hardwareInfo = hardwareMgr.GetHardwareInfo() })
-	runTask("network", func() {
-		networkInfo = networkMgr.GetNetworkInfo()
-		if networkInfo.DNSServers == nil {
-			networkInfo.DNSServers = []string{}
+	if want("hardware") {
+		runTask("hardware", func() { hardwareInfo = hardwareMgr.GetHardwareInfo() })
+	} else {
+		hardwareInfo = models.HardwareInfo{
+			CPUModel:     previous.CPUModel,
+			CPUCores:     previous.CPUCores,
+			RAMInstalled: previous.RAMInstalled,
+			SwapSize:     previous.SwapSize,
+			DiskDetails:  previous.DiskDetails,
+			GPUs:         previous.GPUs,
 		}
-	})
+	}
+	if want("network") {
+		runTask("network", func() {
+			networkInfo = networkMgr.GetNetworkInfo()
+			if networkInfo.DNSServers == nil {
+				networkInfo.DNSServers = []string{}
+			}
+		})
+	} else {
+		networkInfo = models.NetworkInfo{
+			GatewayIP:         previous.GatewayIP,
+			DNSServers:        previous.DNSServers,
+			NetworkInterfaces: previous.NetworkInterfaces,
+		}
+	}
 	runTask("reboot", func() { needsReboot, rebootReason = systemDetector.CheckRebootRequired() })
 	runTask("kernel", func() { installedKernel = systemDetector.GetLatestInstalledKernel() })
 	runTask("machineID", func() { machineID = systemDetector.GetMachineID() })
 	runTask("packageMgr", func() { detectedPackageMgr = packageMgr.DetectPackageManager() })
-	runTask("packages", func() { packageList, pkgErr = packageMgr.GetPackages() })
-	runTask("repos", func() { repoList, repoErr = repoMgr.GetRepositories() })
+	runTask("sshd", func() { sshdConfig, sshdErr = sshdMgr.GetConfig() })
+	if want("packages") {
+		runTask("packages", func() { packageList, pkgErr = packageMgr.GetPackages() })
+	} else {
+		packageList = previous.Packages
+	}
+	if want("repos") {
+		runTask("repos", func() { repoList, repoErr = repoMgr.GetRepositories() })
+		runTask("subscription", func() { subscription = repoMgr.GetSubscription() })
+	} else {
+		repoList = previous.Repositories
+		subscription = previous.Subscription
+	}
 
 	wg.Wait()
 
@@ -160,12 +373,41 @@ func sendReport(outputJSON bool) error {
 		return fmt.Errorf("failed to get hostname: %w", hostnameErr)
 	}
 	if pkgErr != nil {
-		return fmt.Errorf("failed to get packages: %w", pkgErr)
+		var err error
+		if packageList, err = packagesAfterFailure(previous, pkgErr); err != nil {
+			return err
+		}
 	}
 	if repoErr != nil {
 		logger.WithError(repoErr).Warn("Failed to get repositories")
 		repoList = []models.Repository{}
 	}
+	// Driver drift is judged against this report's packages, fresh or carried over
+	hardware.MarkGPUDriverUpdates(hardwareInfo.GPUs, packageList)
+
+	// Tell the server which sections are incomplete rather than sending partial
+	// data silently
+	sections := make(models.SectionStatuses, len(reportSectionTasks))
+	for name, tasks := range reportSectionTasks {
+		sections[name] = sectionStatus(tasks, taskPanics, nil)
+	}
+	sections["packages"] = sectionStatus(reportSectionTasks["packages"], taskPanics, pkgErr)
+	sections["repositories"] = sectionStatus(reportSectionTasks["repositories"], taskPanics, repoErr)
+	// Hosts without an OpenSSH server have no sshd section at all
+	if _, panicked := taskPanics["sshd"]; sshdConfig != nil || sshdErr != nil || panicked {
+		sections["sshd"] = sectionStatus([]string{"sshd"}, taskPanics, sshdErr)
+	}
+	if previous != nil {
+		for name, section := range reportSectionNames {
+			if !want(name) {
+				if status, ok := previous.Sections[section]; ok {
+					sections[section] = status
+				} else {
+					delete(sections, section)
+				}
+			}
+		}
+	}
 
 	// Guarantee non-nil slices so JSON marshals as [] not null
 	if packageList == nil {
@@ -175,6 +417,24 @@ func sendReport(outputJSON bool) error {
 		repoList = []models.Repository{}
 	}
 
+	// Drop ignored packages and repositories before anything counts or reports them
+	packageList = applyIgnoreList("package", cfgManager.GetConfig().IgnorePackages, packageList, (*ignore.Matcher).FilterPackages)
+	repoList = applyIgnoreList("repository", cfgManager.GetConfig().IgnoreRepositories, repoList, (*ignore.Matcher).FilterRepositories)
+
+	// Classify repositories (distro / vendor / custom) and tag packages with their source's class
+	repositories.ClassifyRepositories(repoList)
+	repositories.MarkEntitlementGated(repoList)
+	repositories.TagPackages(packageList, repoList)
+
+	// Partial reports reuse the previous packages, pending dates included, as
+	// does a report whose package collection failed
+	if want("packages") && pkgErr == nil {
+		recordPendingSince(packageList)
+	}
+
+	// Web servers, databases and caches, for application-owner dashboards
+	keyServices := keyservices.New(logger).Detect(packageList)
+
 	logger.WithFields(logrus.Fields{"osType": osType, "osVersion": osVersion}).Info("Detected OS")
 	logger.WithFields(logrus.Fields{
 		"needs_reboot":     needsReboot,
@@ -217,6 +477,33 @@ func sendReport(outputJSON bool) error {
 		}).Debug("Package summary")
 	}
 
+	// Compare against recent history so a broken package database isn't
+	// reported as if most packages had been uninstalled
+	var warnings []string
+	packageCountSuspect := false
+	historyPath := cfgManager.StatePath(packageCountHistoryFile)
+	countHistory, err := packages.LoadCountHistory(historyPath)
+	if err != nil {
+		logger.WithError(err).Warn("Package count history unavailable, skipping drop detection")
+	}
+	if suspect, baseline := countHistory.CheckDrop(len(packageList)); suspect && want("packages") {
+		packageCountSuspect = true
+		warning := fmt.Sprintf("package count dropped from a recent baseline of %d to %d; the package database may be broken", baseline, len(packageList))
+		warnings = append(warnings, warning)
+		sections["packages"] = models.SectionStatus{Status: models.SectionDegraded, Error: warning}
+		logger.WithFields(logrus.Fields{
+			"count":    len(packageList),
+			"baseline": baseline,
+		}).Warn("Suspicious drop in package count, flagging report")
+	}
+
+	// An unentitled RHEL or SLES host sees no vendor updates at all, which
+	// would otherwise read as fully patched
+	if warning := repositories.SubscriptionWarning(subscription, repoList); warning != "" {
+		warnings = append(warnings, warning)
+		logger.WithField("provider", subscription.Provider).Warn(warning)
+	}
+
 	logger.WithField("count", len(repoList)).Info("Found repositories")
 	if logger.IsLevelEnabled(logrus.DebugLevel) {
 		for _, repo := range repoList {
@@ -229,12 +516,49 @@ func sendReport(outputJSON bool) error {
 		}
 	}
 
+	// The first report after a reboot says so, and confirms a pending reboot
+	// is done rather than leaving the server to infer it
+	bootID, boot := detectReboot(systemDetector)
+	if boot != nil {
+		if last := loadLastReport(); last != nil && last.NeedsReboot && !needsReboot {
+			boot.RebootRequiredCleared = true
+		}
+		since := boot.BootTime.Add(-24 * time.Hour)
+		if boot.LastSeen != nil {
+			since = *boot.LastSeen
+		}
+		boot.CrashEvidence, boot.CrashSummary = systemDetector.CheckPreviousShutdown(since)
+		boot.UncleanShutdown = len(boot.CrashEvidence) > 0
+		entry := logger.WithFields(logrus.Fields{
+			"previous_boot_id": boot.PreviousBootID,
+			"downtime_seconds": boot.DowntimeSeconds,
+		})
+		if boot.UncleanShutdown {
+			entry.WithFields(logrus.Fields{
+				"evidence": strings.Join(boot.CrashEvidence, "; "),
+				"crash":    boot.CrashSummary,
+			}).Warn("Host rebooted after an unclean shutdown")
+		} else {
+			entry.Info("Host rebooted since the last report")
+		}
+	}
+
+	for name, status := range loadIntegrationSections() {
+		sections[name] = status
+	}
+	for name, status := range sections {
+		if status.Status != models.SectionOK {
+			logger.WithFields(logrus.Fields{"section": name, "status": status.Status, "error": status.Error}).Warn("Report section incomplete")
+		}
+	}
+
 	// Calculate execution time (in seconds, with millisecond precision)
 	executionTime := time.Since(startTime).Seconds()
 	logger.WithField("execution_time_seconds", executionTime).Debug("Data collection completed")
 
 	// Create payload
 	payload := &models.ReportPayload{
+		SchemaVersion:          models.SchemaVersion,
 		Packages:               packageList,
 		Repositories:           repoList,
 		OSType:                 osType,
@@ -249,11 +573,13 @@ func sendReport(outputJSON bool) error {
 		SELinuxStatus:          systemInfo.SELinuxStatus,
 		SystemUptime:           systemInfo.SystemUptime,
 		LoadAverage:            systemInfo.LoadAverage,
+		Jailed:                 systemInfo.Jailed,
 		CPUModel:               hardwareInfo.CPUModel,
 		CPUCores:               hardwareInfo.CPUCores,
 		RAMInstalled:           hardwareInfo.RAMInstalled,
 		SwapSize:               hardwareInfo.SwapSize,
 		DiskDetails:            hardwareInfo.DiskDetails,
+		GPUs:                   hardwareInfo.GPUs,
 		GatewayIP:              networkInfo.GatewayIP,
 		DNSServers:             networkInfo.DNSServers,
 		NetworkInterfaces:      networkInfo.NetworkInterfaces,
@@ -261,6 +587,14 @@ func sendReport(outputJSON bool) error {
 		NeedsReboot:            needsReboot,
 		RebootReason:           rebootReason,
 		PackageManager:         detectedPackageMgr,
+		PackageCountSuspect:    packageCountSuspect,
+		Warnings:               warnings,
+		Sections:               sections,
+		RefreshedSections:      core,
+		SSHDConfig:             sshdConfig,
+		KeyServices:            keyServices,
+		Subscription:           subscription,
+		Boot:                   boot,
 	}
 
 	// If --report-json flag is set, output JSON and exit
@@ -275,16 +609,59 @@ func sendReport(outputJSON bool) error {
 		return nil
 	}
 
+	// Local consumers get fresh data even if the server is unreachable
+	localState.SetReport(payload)
+	noteRebootRequired(needsReboot, rebootReason)
+
 	// Send report
 	logger.Info("Sending report to PatchMon server...")
-	httpClient := client.New(cfgManager, logger)
-	ctx := context.Background()
-	response, err := httpClient.SendUpdate(ctx, payload)
+	httpClient := apiClient()
+
+	// Tell the server about a rename first so the report updates the existing host
+	lastHostnamePath := cfgManager.StatePath(lastHostnameFile)
+	if previous := readLastHostname(lastHostnamePath); previous != "" && previous != hostname {
+		payload.PreviousHostname = previous
+		notifyHostnameChange(ctx, httpClient, previous, hostname, machineID)
+	}
+	sendCtx, sendSpan := tracing.Start(ctx, "report.send", attribute.Int("report.packages", len(packageList)))
+	response, err := httpClient.SendUpdate(sendCtx, payload)
+	tracing.End(sendSpan, err)
+	noteReportResult(err)
+	noteReportMetric(err)
 	if err != nil {
+		var conflict *client.RegistrationConflictError
+		if errors.As(err, &conflict) {
+			recordRegistrationConflict(conflict, machineID, hostname)
+		}
+		switch client.ErrorCode(err) {
+		case models.ErrInvalidAPIKey:
+			logger.Error("The server rejected this host's API credentials; re-register it with 'patchmon-agent config set-api'")
+		case models.ErrPayloadTooLarge:
+			logger.Error("The report is larger than the server accepts; raise the server's body size limit or exclude packages with ignore_packages")
+		}
 		return fmt.Errorf("failed to send report: %w", err)
 	}
+	clearRegistrationConflict()
+	recordReportSuccess()
+	if err := utils.WriteFileAtomic(lastHostnamePath, []byte(hostname+"\n"), 0600); err != nil {
+		logger.WithError(err).Debug("Failed to save last reported hostname")
+	}
 
-	logger.Info("Report sent successfully")
+	saveLastReport(payload)
+	markBootReported(bootID)
+
+	if want("packages") {
+		countHistory.Record(len(packageList), time.Now())
+		if err := countHistory.Save(historyPath); err != nil {
+			logger.WithError(err).Debug("Failed to save package count history")
+		}
+	}
+
+	if response.Duplicate {
+		logger.Info("Report was already received by the server (duplicate submission ignored)")
+	} else {
+		logger.Info("Report sent successfully")
+	}
 	logger.WithField("count", response.PackagesProcessed).Info("Processed packages")
 
 	// Handle agent auto-update (server-initiated)
@@ -295,14 +672,16 @@ func sendReport(outputJSON bool) error {
 			"message": response.AutoUpdate.Message,
 		}).Info("PatchMon agent update detected")
 
-		logger.Info("Automatically updating PatchMon agent to latest version...")
-		if err := updateAgent(); err != nil {
-			logger.WithError(err).Warn("PatchMon agent update failed, but data was sent successfully")
-		} else {
-			logger.Info("PatchMon agent update completed successfully")
-			// updateAgent() will exit the process after restart, so we won't reach here
-			// But if it does return, skip the update check to prevent loops
-			return nil
+		if autoUpdateAllowed() {
+			logger.Info("Automatically updating PatchMon agent to latest version...")
+			if err := updateAgent(); err != nil {
+				logger.WithError(err).Warn("PatchMon agent update failed, but data was sent successfully")
+			} else {
+				logger.Info("PatchMon agent update completed successfully")
+				// updateAgent() will exit the process after restart, so we won't reach here
+				// But if it does return, skip the update check to prevent loops
+				return nil
+			}
 		}
 	} else {
 		// Proactive update check after report (with timeout to prevent hanging)
@@ -332,6 +711,9 @@ func sendReport(outputJSON bool) error {
 				logger.WithError(err).Warn("Failed to check for updates after report (non-critical)")
 				return
 			}
+			if versionInfo.HasUpdate && !autoUpdateAllowed() {
+				return
+			}
 			if versionInfo.HasUpdate {
 				logger.WithFields(logrus.Fields{
 					"current": versionInfo.CurrentVersion,
@@ -361,14 +743,24 @@ func sendReport(outputJSON bool) error {
 
 	// Collect and send integration data (Docker, etc.) separately
 	// This ensures failures in integrations don't affect core system reporting
-	sendIntegrationData()
+	if len(only) > 0 {
+		if err := refreshExtraSections(ctx, extras); err != nil {
+			logger.WithError(err).Warn("Failed to refresh requested sections")
+		}
+	} else {
+		sendIntegrationData(ctx)
+	}
 
 	logger.Debug("Report process completed")
 	return nil
 }
 
-// sendIntegrationData collects and sends data from integrations (Docker, etc.)
-func sendIntegrationData() {
+// sendIntegrationData collects and sends data from integrations (Docker, etc.).
+// With names, only those integrations are collected and the stored status of
+// the others is kept.
+func sendIntegrationData(ctx context.Context, names ...string) {
+	ctx, span := tracing.Start(ctx, "integrations", attribute.StringSlice("integrations.names", names))
+	defer span.End()
 	logger.Debug("Starting integration data collection")
 
 	// Create integration manager
@@ -382,17 +774,39 @@ func sendIntegrationData() {
 	integrationMgr.SetEnabledChecker(func(name string) bool {
 		return cfgManager.IsIntegrationEnabled(name)
 	})
+	attachSecrets(integrationMgr)
 
 	// Register available integrations
-	integrationMgr.Register(docker.New(logger))
-
-	// Future: integrationMgr.Register(proxmox.New(logger))
-	// Future: integrationMgr.Register(kubernetes.New(logger))
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	register := func(integ integrations.Integration) {
+		if len(names) == 0 || slices.Contains(names, integ.Name()) {
+			integrationMgr.Register(integ)
+		}
+	}
+	register(newDockerIntegration())
+	register(langpkg.New(logger, cfgManager.StatePath(osvCacheFile)))
+	register(accounts.New(logger))
+	register(tlscerts.New(logger, cfgManager.GetConfig().TLSCertPaths))
+	register(schedtasks.New(logger))
+	register(jails.New(logger))
+	register(nspawn.New(logger))
+	register(proxmox.New(logger))
+	register(kubernetes.New(logger))
+
+	collectCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 
-	integrationData := integrationMgr.CollectAll(ctx)
+	integrationData := integrationMgr.CollectAll(collectCtx)
+
+	// Record how each integration went; the next report carries it
+	sections := make(models.SectionStatuses, len(integrationData))
+	if len(names) > 0 {
+		for name, status := range loadIntegrationSections() {
+			if !slices.Contains(names, name) {
+				sections[name] = status
+			}
+		}
+	}
+	defer saveIntegrationSections(sections)
 
 	if len(integrationData) == 0 {
 		logger.Debug("No integration data to send")
@@ -400,28 +814,176 @@ func sendIntegrationData() {
 	}
 
 	// Get system info for integration payloads
-	systemDetector := system.New(logger)
+	systemDetector := newSystemDetector()
 	hostname, _ := systemDetector.GetHostname()
 	machineID := systemDetector.GetMachineID()
 
 	// Create HTTP client
-	httpClient := client.New(cfgManager, logger)
+	httpClient := apiClient()
 
 	// Send Docker data if available
-	if dockerData, exists := integrationData["docker"]; exists && dockerData.Error == "" {
-		sendDockerData(httpClient, dockerData, hostname, machineID)
+	if dockerData, exists := integrationData["docker"]; exists {
+		var sendErr error
+		if dockerData.Error == "" {
+			sendErr = sendDockerData(httpClient, dockerData, hostname, machineID)
+			if cfgManager.GetConfig().DockerSBOM {
+				if data, ok := dockerData.Data.(*models.DockerData); ok {
+					uploadImageSBOMs(httpClient, data, hostname, machineID)
+				}
+			}
+		}
+		sections["docker"] = integrationSectionStatus(dockerData, sendErr)
 	}
 
-	// Future: Send other integration data here
+	for _, sender := range integrationSenders(httpClient, hostname, machineID) {
+		data, exists := integrationData[sender.name]
+		if !exists {
+			continue
+		}
+		var sendErr error
+		if data.Error == "" {
+			sendErr = sender.send(data)
+		}
+		sections[sender.name] = integrationSectionStatus(data, sendErr)
+	}
+}
+
+// readLastHostname returns the hostname of the last successful report, or "" if unknown
+func readLastHostname(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// notifyHostnameChange sends a hostname-change event. Failure is not fatal: the
+// report also carries previousHostname.
+func notifyHostnameChange(ctx context.Context, httpClient *client.Client, previous, hostname, machineID string) {
+	logger.WithFields(logrus.Fields{
+		"previous": previous,
+		"hostname": hostname,
+	}).Info("Hostname changed since last report, notifying server")
+
+	event := &models.HostnameChangeEvent{
+		PreviousHostname: previous,
+		Hostname:         hostname,
+		MachineID:        machineID,
+		ChangedAt:        time.Now().UTC(),
+	}
+	if err := httpClient.SendHostnameChange(ctx, event); err != nil {
+		logger.WithError(err).Warn("Failed to send hostname change event")
+	}
+}
+
+// integrationSender sends one integration's data through its own
+// integrations endpoint
+type integrationSender struct {
+	name string
+	send func(*models.IntegrationData) error
+}
+
+// integrationSenders lists the integrations sent on their own after Docker,
+// in the order they are sent
+func integrationSenders(httpClient *client.Client, hostname, machineID string) []integrationSender {
+	return []integrationSender{
+		{langpkg.IntegrationName, func(d *models.IntegrationData) error {
+			return sendIntegration(d, "language package data", func(ctx context.Context, data *models.LanguagePackagesData) error {
+				_, err := httpClient.SendLanguagePackages(ctx, &models.LanguagePackagesPayload{LanguagePackagesData: *data, Hostname: hostname, MachineID: machineID, AgentVersion: pkgversion.Version})
+				return err
+			})
+		}},
+		{accounts.IntegrationName, func(d *models.IntegrationData) error {
+			return sendIntegration(d, "user account data", func(ctx context.Context, data *models.UserAccountsData) error {
+				_, err := httpClient.SendUserAccounts(ctx, &models.UserAccountsPayload{UserAccountsData: *data, Hostname: hostname, MachineID: machineID, AgentVersion: pkgversion.Version})
+				return err
+			})
+		}},
+		{tlscerts.IntegrationName, func(d *models.IntegrationData) error {
+			return sendIntegration(d, "TLS certificate data", func(ctx context.Context, data *models.TLSCertificatesData) error {
+				_, err := httpClient.SendTLSCertificates(ctx, &models.TLSCertificatesPayload{TLSCertificatesData: *data, Hostname: hostname, MachineID: machineID, AgentVersion: pkgversion.Version})
+				return err
+			})
+		}},
+		{schedtasks.IntegrationName, func(d *models.IntegrationData) error {
+			return sendIntegration(d, "scheduled task data", func(ctx context.Context, data *models.ScheduledTasksData) error {
+				_, err := httpClient.SendScheduledTasks(ctx, &models.ScheduledTasksPayload{ScheduledTasksData: *data, Hostname: hostname, MachineID: machineID, AgentVersion: pkgversion.Version})
+				return err
+			})
+		}},
+		{jails.IntegrationName, func(d *models.IntegrationData) error {
+			return sendIntegration(d, "jail data", func(ctx context.Context, data *models.JailsData) error {
+				_, err := httpClient.SendJails(ctx, &models.JailsPayload{JailsData: *data, Hostname: hostname, MachineID: machineID, AgentVersion: pkgversion.Version})
+				return err
+			})
+		}},
+		{nspawn.IntegrationName, func(d *models.IntegrationData) error {
+			return sendIntegration(d, "nspawn machine data", func(ctx context.Context, data *models.NspawnData) error {
+				_, err := httpClient.SendNspawn(ctx, &models.NspawnPayload{NspawnData: *data, Hostname: hostname, MachineID: machineID, AgentVersion: pkgversion.Version})
+				return err
+			})
+		}},
+		{proxmox.IntegrationName, func(d *models.IntegrationData) error {
+			return sendIntegration(d, "Proxmox data", func(ctx context.Context, data *models.ProxmoxData) error {
+				_, err := httpClient.SendProxmoxData(ctx, &models.ProxmoxPayload{ProxmoxData: *data, Hostname: hostname, MachineID: machineID, AgentVersion: pkgversion.Version})
+				return err
+			})
+		}},
+		{kubernetes.IntegrationName, func(d *models.IntegrationData) error {
+			return sendIntegration(d, "Kubernetes node data", func(ctx context.Context, data *models.KubernetesData) error {
+				// The node's package list carries the updates from the last report
+				if last := loadLastReport(); last != nil {
+					kubernetes.MarkPackageUpdates(data.Packages, last.Packages)
+				}
+				_, err := httpClient.SendKubernetesData(ctx, &models.KubernetesPayload{KubernetesData: *data, Hostname: hostname, MachineID: machineID, AgentVersion: pkgversion.Version})
+				return err
+			})
+		}},
+	}
+}
+
+// sendIntegration sends the data an integration collected, which must be a
+// *D, with send. what names the data in the log. A server whose payload
+// schema predates the endpoint is skipped with a note rather than a warning.
+func sendIntegration[D any](integrationData *models.IntegrationData, what string, send func(ctx context.Context, data *D) error) error {
+	data, ok := integrationData.Data.(*D)
+	if !ok {
+		logger.Warn("Failed to extract " + what + " from integration")
+		return errors.New("unexpected " + what)
+	}
+
+	logger.Info("Sending " + what + " to server...")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := send(ctx, data); err != nil {
+		if errors.Is(err, client.ErrUnsupportedBySchema) {
+			logger.WithError(err).Info("Not sending " + what)
+		} else {
+			logger.WithError(err).Warn("Failed to send " + what + " (will retry on next report)")
+		}
+		return err
+	}
+
+	logger.Info("Sent " + what + " to server")
+	return nil
+}
+
+// newDockerIntegration returns the Docker integration with the registry digest
+// check configured
+func newDockerIntegration() *docker.Integration {
+	d := docker.New(logger)
+	d.SetDigestCheck(cfgManager.StatePath(dockerDigestCacheFile), cfgManager.GetDockerDigestCheckInterval())
+	return d
 }
 
 // sendDockerData sends Docker integration data to server
-func sendDockerData(httpClient *client.Client, integrationData *models.IntegrationData, hostname, machineID string) {
+func sendDockerData(httpClient *client.Client, integrationData *models.IntegrationData, hostname, machineID string) error {
 	// Extract Docker data from integration data
 	dockerData, ok := integrationData.Data.(*models.DockerData)
 	if !ok {
 		logger.Warn("Failed to extract Docker data from integration")
-		return
+		return errors.New("unexpected Docker data")
 	}
 
 	payload := &models.DockerPayload{
@@ -444,7 +1006,7 @@ func sendDockerData(httpClient *client.Client, integrationData *models.Integrati
 	response, err := httpClient.SendDockerData(ctx, payload)
 	if err != nil {
 		logger.WithError(err).Warn("Failed to send Docker data (will retry on next report)")
-		return
+		return err
 	}
 
 	logger.WithFields(logrus.Fields{
@@ -454,6 +1016,7 @@ func sendDockerData(httpClient *client.Client, integrationData *models.Integrati
 		"networks":   response.NetworksReceived,
 		"updates":    response.UpdatesFound,
 	}).Info("Docker data sent successfully")
+	return nil
 }
 
 // sendComplianceData sends compliance scan data to server
@@ -477,6 +1040,10 @@ func sendComplianceData(httpClient *client.Client, integrationData *models.Integ
 		AgentVersion:   pkgversion.Version,
 		ScanType:       scanType,
 	}
+	localState.SetComplianceScans(complianceData.Scans)
+	noteComplianceScores(complianceData.Scans)
+	noteScanDurations(complianceData.Scans)
+	addComplianceDiffs(complianceData.Scans)
 
 	totalRules := 0
 	for _, scan := range complianceData.Scans {
@@ -496,6 +1063,9 @@ func sendComplianceData(httpClient *client.Client, integrationData *models.Integ
 		logger.WithError(err).Warn("Failed to send compliance data (will retry on next report)")
 		return
 	}
+	saveComplianceBaselines(complianceData.Scans)
+	noteComplianceRegressions(complianceData.Scans)
+	uploadHTMLReports(httpClient, complianceData.Scans, hostname, machineID)
 
 	logger.WithFields(logrus.Fields{
 		"scans_received": response.ScansReceived,
@@ -503,10 +1073,15 @@ func sendComplianceData(httpClient *client.Client, integrationData *models.Integ
 	}).Info("Compliance data sent successfully")
 }
 
-func runScheduledComplianceScan() {
+// runScheduledComplianceScan runs the default profile and uploads the results.
+// It reports whether a scan completed.
+func runScheduledComplianceScan() bool {
 	if !cfgManager.IsIntegrationEnabled("compliance") || cfgManager.IsComplianceOnDemandOnly() {
 		logger.Debug("Skipping scheduled compliance scan (not in enabled mode)")
-		return
+		return false
+	}
+	if skipWhilePaused("scheduled compliance scan") {
+		return false
 	}
 
 	if !complianceScanRunning.CompareAndSwap(false, true) {
@@ -514,7 +1089,7 @@ func runScheduledComplianceScan() {
 		source := complianceScanSource
 		complianceScanCancelMu.Unlock()
 		logger.WithField("running_source", source).Debug("Skipping scheduled compliance scan (scan already running)")
-		return
+		return false
 	}
 
 	complianceScanCancelMu.Lock()
@@ -543,10 +1118,12 @@ func runScheduledComplianceScan() {
 
 	if !complianceInteg.IsAvailable() {
 		logger.Debug("Compliance scanning not available on this system, skipping scheduled scan")
-		return
+		return false
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 25*time.Minute)
+	ctx, span := tracing.Start(context.Background(), "compliance.scan", attribute.String("compliance.source", "scheduled"))
+	defer span.End()
+	ctx, cancel := context.WithTimeout(ctx, 25*time.Minute)
 	defer cancel()
 
 	complianceScanCancelMu.Lock()
@@ -558,29 +1135,77 @@ func runScheduledComplianceScan() {
 		complianceScanCancelMu.Unlock()
 	}()
 
-	integrationData, err := complianceInteg.Collect(ctx)
+	var integrationData *models.IntegrationData
+	var err error
+	if profiles := cfgManager.GetComplianceRoleScanProfiles(); len(profiles) > 0 {
+		integrationData, err = collectRoleProfiles(ctx, complianceInteg, profiles)
+	} else {
+		integrationData, err = complianceInteg.Collect(ctx)
+	}
 	if err != nil {
 		if errors.Is(err, context.Canceled) {
 			logger.Info("Scheduled compliance scan was cancelled")
 		} else {
 			logger.WithError(err).Warn("Scheduled compliance scan failed")
 		}
-		return
+		return false
 	}
 
 	if integrationData == nil || integrationData.Error != "" {
 		if integrationData != nil {
 			logger.WithField("error", integrationData.Error).Warn("Scheduled compliance scan returned error")
 		}
-		return
+		return false
 	}
 
-	systemDetector := system.New(logger)
+	systemDetector := newSystemDetector()
 	hostname, _ := systemDetector.GetHostname()
 	machineID := systemDetector.GetMachineID()
 
-	httpClient := client.New(cfgManager, logger)
+	httpClient := apiClient()
 	sendComplianceData(httpClient, integrationData, hostname, machineID, "scheduled")
 
 	logger.WithField("elapsed_ms", time.Since(startTime).Milliseconds()).Info("Scheduled compliance scan completed")
+	return true
+}
+
+// collectRoleProfiles runs each profile mapped to the host's roles and merges
+// the results into one upload
+func collectRoleProfiles(ctx context.Context, integ *compliance.Integration, profiles []string) (*models.IntegrationData, error) {
+	logger.WithFields(logrus.Fields{
+		"roles":    cfgManager.GetComplianceRoles(),
+		"profiles": profiles,
+	}).Info("Scanning the compliance profiles for this host's roles")
+	openscapEnabled := cfgManager.GetComplianceOpenscapEnabled()
+	dockerBenchEnabled := cfgManager.GetComplianceDockerBenchEnabled()
+
+	var merged *models.IntegrationData
+	var mergedData *models.ComplianceData
+	for _, profile := range profiles {
+		data, err := integ.CollectWithOptions(ctx, &models.ComplianceScanOptions{
+			ProfileID:          profile,
+			OpenSCAPEnabled:    &openscapEnabled,
+			DockerBenchEnabled: &dockerBenchEnabled,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		compData, ok := data.Data.(*models.ComplianceData)
+		if !ok {
+			continue
+		}
+		if merged == nil {
+			merged, mergedData = data, compData
+			continue
+		}
+		mergedData.Scans = append(mergedData.Scans, compData.Scans...)
+		merged.ExecutionTime += data.ExecutionTime
+	}
+	if merged == nil {
+		return nil, errors.New("no compliance data collected")
+	}
+	return merged, nil
 }
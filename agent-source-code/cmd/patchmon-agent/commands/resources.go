@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+	"github.com/sirupsen/logrus"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+const (
+	// resourceSampleInterval is how often serve samples its own resource use
+	// and sends it to the server
+	resourceSampleInterval = 5 * time.Minute
+	// rssLimitSamples is how many samples in a row must exceed max_rss_mb
+	// before serve restarts, so a short spike (a big compliance datastream)
+	// doesn't restart it
+	rssLimitSamples = 3
+)
+
+// resourceSampler measures the agent process. CPU use is averaged between
+// samples, so the first sample reports none.
+type resourceSampler struct {
+	mu      sync.Mutex
+	proc    *process.Process
+	lastCPU float64
+	lastAt  time.Time
+}
+
+var agentResources = &resourceSampler{}
+
+// sample measures the process now and records the result in the health state
+func (s *resourceSampler) sample() *models.AgentResources {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	res := &models.AgentResources{
+		SampledAt:  now,
+		HeapBytes:  mem.HeapInuse,
+		Goroutines: runtime.NumGoroutine(),
+	}
+
+	if s.proc == nil {
+		proc, err := process.NewProcess(int32(os.Getpid()))
+		if err != nil {
+			logger.WithError(err).Debug("Cannot inspect own process")
+			return res
+		}
+		s.proc = proc
+	}
+	if info, err := s.proc.MemoryInfo(); err == nil {
+		res.RSSBytes = info.RSS
+	}
+	if fds, err := s.proc.NumFDs(); err == nil {
+		res.OpenFiles = int(fds)
+	}
+	if times, err := s.proc.Times(); err == nil {
+		used := times.User + times.System
+		if !s.lastAt.IsZero() {
+			res.CPUPercent = cpuPercent(used-s.lastCPU, now.Sub(s.lastAt))
+		}
+		s.lastCPU, s.lastAt = used, now
+	}
+
+	agentHealthMu.Lock()
+	agentHealth.Resources = res
+	agentHealthMu.Unlock()
+	return res
+}
+
+// cpuPercent converts CPU seconds used over a wall-clock interval into a
+// percentage of one CPU
+func cpuPercent(cpuSeconds float64, elapsed time.Duration) float64 {
+	if elapsed <= 0 || cpuSeconds < 0 {
+		return 0
+	}
+	return cpuSeconds / elapsed.Seconds() * 100
+}
+
+// rssOverLimit reports whether rss exceeds max_rss_mb; a limit of 0 is off
+func rssOverLimit(rss uint64, limitMB int) bool {
+	return limitMB > 0 && rss > uint64(limitMB)*1024*1024
+}
+
+// runResourceMonitor samples the agent's resource use, sends it to the server
+// over the WebSocket and restarts serve when RSS stays above max_rss_mb
+func runResourceMonitor(ctx context.Context) {
+	overLimit := 0
+	ticker := time.NewTicker(resourceSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		res := agentResources.sample()
+		sendResources(res)
+		fields := logrus.Fields{
+			"rss_mb":     res.RSSBytes / (1024 * 1024),
+			"goroutines": res.Goroutines,
+			"open_files": res.OpenFiles,
+			"cpu":        res.CPUPercent,
+		}
+		logger.WithFields(fields).Debug("Agent resource usage")
+
+		limit := cfgManager.GetConfig().MaxRSSMB
+		if !rssOverLimit(res.RSSBytes, limit) {
+			overLimit = 0
+			continue
+		}
+		overLimit++
+		fields["max_rss_mb"] = limit
+		if overLimit < rssLimitSamples {
+			logger.WithFields(fields).Warn("Agent memory use is above max_rss_mb")
+			continue
+		}
+		if runtime.GOOS == "windows" {
+			logger.WithFields(fields).Error("Agent memory use has stayed above max_rss_mb; restart the PatchMon agent service")
+			overLimit = 0
+			continue
+		}
+		logger.WithFields(fields).Error("Agent memory use has stayed above max_rss_mb, restarting")
+		if err := restartService("", ""); err != nil {
+			logger.WithError(err).Error("Failed to restart after exceeding max_rss_mb")
+		}
+		overLimit = 0
+	}
+}
+
+// sendResources sends a resource sample over the WebSocket when connected
+func sendResources(res *models.AgentResources) {
+	conn := currentWsConn()
+	if conn == nil {
+		return
+	}
+	if err := sendWSMessage(conn, map[string]interface{}{
+		"type":      "agent_resources",
+		"resources": res,
+	}); err != nil {
+		logger.WithError(err).Debug("Failed to send resource usage via WebSocket")
+	}
+}
@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCPUPercent(t *testing.T) {
+	if got := cpuPercent(30, time.Minute); got != 50 {
+		t.Errorf("cpuPercent(30s, 1m) = %v, want 50", got)
+	}
+	if got := cpuPercent(120, time.Minute); got != 200 {
+		t.Errorf("cpuPercent(120s, 1m) = %v, want 200 (two CPUs busy)", got)
+	}
+	if got := cpuPercent(1, 0); got != 0 {
+		t.Errorf("cpuPercent over no time = %v, want 0", got)
+	}
+}
+
+func TestRSSOverLimit(t *testing.T) {
+	if rssOverLimit(1<<40, 0) {
+		t.Error("a zero limit must be off")
+	}
+	if rssOverLimit(200*1024*1024, 256) {
+		t.Error("200 MB is under a 256 MB limit")
+	}
+	if !rssOverLimit(300*1024*1024, 256) {
+		t.Error("300 MB is over a 256 MB limit")
+	}
+}
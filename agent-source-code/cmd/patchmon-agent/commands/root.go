@@ -21,6 +21,7 @@ var (
 	logger     *logrus.Logger
 	configFile string
 	logLevel   string
+	profile    string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -31,9 +32,20 @@ var rootCmd = &cobra.Command{
 	Long: `PatchMon Agent v` + pkgversion.Version + `
 
 A monitoring agent that sends package information to PatchMon.`,
-	PersistentPreRun: func(cmd *cobra.Command, _ []string) {
+	PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+		if profile != "" {
+			if cmd.Flag("config").Changed {
+				return fmt.Errorf("--config and --profile can't be used together")
+			}
+			if err := config.ValidateProfileName(profile); err != nil {
+				return err
+			}
+		}
 		initialiseAgent()
 		updateLogLevel(cmd)
+		applyRuntimeTuning()
+		configureDNSOverHTTPS()
+		return nil
 	},
 }
 
@@ -50,6 +62,7 @@ func init() {
 	// Add global flags
 	rootCmd.PersistentFlags().StringVar(&configFile, "config", configFile, "config file path")
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", logLevel, "log level (debug, info, warn, error)")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "named configuration profile under "+config.ProfilesDir())
 
 	// Add all subcommands
 	rootCmd.AddCommand(reportCmd)
@@ -81,7 +94,12 @@ func initialiseAgent() {
 
 	// Initialise configuration manager
 	cfgManager = config.New()
-	cfgManager.SetConfigFile(configFile)
+	if profile != "" {
+		// Validated before initialiseAgent runs
+		_ = cfgManager.UseProfile(profile)
+	} else {
+		cfgManager.SetConfigFile(configFile)
+	}
 
 	// Load config early to determine log file path
 	_ = cfgManager.LoadConfig()
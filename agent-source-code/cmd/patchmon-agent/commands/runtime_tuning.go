@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"context"
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/mem"
+	"github.com/sirupsen/logrus"
+)
+
+const mib = 1024 * 1024
+
+// cgroupMemoryFiles hold a container's memory limit (cgroup v2, then v1)
+var cgroupMemoryFiles = []string{
+	"/sys/fs/cgroup/memory.max",
+	"/sys/fs/cgroup/memory/memory.limit_in_bytes",
+}
+
+// runtimeTuning is the Go runtime's GC target, soft memory limit and
+// parallelism for the agent
+type runtimeTuning struct {
+	GCPercent   int   // -1 turns the GC off
+	MemoryLimit int64 // bytes; 0 = none
+	MaxProcs    int
+}
+
+// autoRuntimeTuning sizes the runtime to the host. Small hosts and LXCs
+// collect garbage sooner and get a tight limit; large hosts get room to
+// parse big compliance datastreams without thrashing the GC.
+func autoRuntimeTuning(totalMemory uint64, cpus int) runtimeTuning {
+	t := runtimeTuning{GCPercent: 100, MaxProcs: min(cpus, max(2, min(cpus/2, 8)))}
+	if totalMemory == 0 {
+		// Memory unknown: what the agent always used
+		t.GCPercent, t.MemoryLimit = 50, 100*mib
+		return t
+	}
+	if totalMemory < 2*1024*mib {
+		t.GCPercent = 50
+	}
+	t.MemoryLimit = int64(min(max(totalMemory/16, 64*mib), 1024*mib))
+	return t
+}
+
+// hostMemory returns the memory available to the agent: the host's, or the
+// container's cgroup limit when lower. 0 when unknown.
+func hostMemory() uint64 {
+	var total uint64
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if vm, err := mem.VirtualMemoryWithContext(ctx); err == nil {
+		total = vm.Total
+	}
+	for _, path := range cgroupMemoryFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		limit, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			continue // "max": no limit
+		}
+		if total == 0 || limit < total {
+			total = limit
+		}
+		break
+	}
+	return total
+}
+
+// applyRuntimeTuning sets GOGC, the soft memory limit and GOMAXPROCS from
+// config.yml, sizing whatever isn't set to the host. Each is left alone when
+// its environment variable is set, which the Go runtime has already applied.
+func applyRuntimeTuning() {
+	cfg := cfgManager.GetConfig()
+	cpus := runtime.GOMAXPROCS(0) // cgroup CPU limits are already applied to this
+	t := autoRuntimeTuning(hostMemory(), cpus)
+	if cfg.GOGC != nil {
+		t.GCPercent = max(*cfg.GOGC, -1)
+	}
+	if cfg.MemoryLimitMB != nil {
+		t.MemoryLimit = int64(max(*cfg.MemoryLimitMB, 0)) * mib
+	}
+	if cfg.MaxProcs > 0 {
+		t.MaxProcs = cfg.MaxProcs
+	}
+
+	fields := logrus.Fields{}
+	if os.Getenv("GOGC") == "" {
+		debug.SetGCPercent(t.GCPercent)
+		fields["gogc"] = t.GCPercent
+	}
+	if os.Getenv("GOMEMLIMIT") == "" {
+		limit := t.MemoryLimit
+		if limit == 0 {
+			limit = math.MaxInt64
+		}
+		debug.SetMemoryLimit(limit)
+		fields["memory_limit_mb"] = t.MemoryLimit / mib
+	}
+	if os.Getenv("GOMAXPROCS") == "" {
+		runtime.GOMAXPROCS(t.MaxProcs)
+		fields["max_procs"] = t.MaxProcs
+	}
+	logger.WithFields(fields).Debug("Go runtime tuned")
+}
@@ -0,0 +1,22 @@
+package commands
+
+import "testing"
+
+func TestAutoRuntimeTuning(t *testing.T) {
+	tests := []struct {
+		name   string
+		memory uint64
+		cpus   int
+		want   runtimeTuning
+	}{
+		{"tiny LXC", 512 * mib, 1, runtimeTuning{GCPercent: 50, MemoryLimit: 64 * mib, MaxProcs: 1}},
+		{"small VM", 4096 * mib, 2, runtimeTuning{GCPercent: 100, MemoryLimit: 256 * mib, MaxProcs: 2}},
+		{"large host", 256 * 1024 * mib, 64, runtimeTuning{GCPercent: 100, MemoryLimit: 1024 * mib, MaxProcs: 8}},
+		{"memory unknown", 0, 4, runtimeTuning{GCPercent: 50, MemoryLimit: 100 * mib, MaxProcs: 2}},
+	}
+	for _, tt := range tests {
+		if got := autoRuntimeTuning(tt.memory, tt.cpus); got != tt.want {
+			t.Errorf("%s: autoRuntimeTuning = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
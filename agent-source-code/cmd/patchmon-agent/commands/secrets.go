@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"sync"
+
+	"patchmon-agent/internal/integrations"
+	"patchmon-agent/internal/logutil"
+	"patchmon-agent/internal/secrets"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// agentKeyFile holds the agent's private key for server-pushed secrets
+	agentKeyFile = "agent_key"
+	// secretsFile is the local keystore of sealed integration secrets
+	secretsFile = "secrets.json"
+)
+
+var (
+	secretStoreMu sync.Mutex
+	secretStore   *secrets.Store
+	agentKey      *secrets.Key
+)
+
+// loadSecretStore opens the agent key and keystore once per process, creating
+// the key on first use
+func loadSecretStore() (*secrets.Store, *secrets.Key, error) {
+	secretStoreMu.Lock()
+	defer secretStoreMu.Unlock()
+	if secretStore != nil {
+		return secretStore, agentKey, nil
+	}
+	key, err := secrets.LoadOrCreateKey(cfgManager.StatePath(agentKeyFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	store, err := secrets.OpenStore(cfgManager.StatePath(secretsFile), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	secretStore, agentKey = store, key
+	return store, key, nil
+}
+
+// agentPublicKey returns the key the server encrypts secrets to, or "" if the
+// keystore is unavailable
+func agentPublicKey() string {
+	_, key, err := loadSecretStore()
+	if err != nil {
+		logger.WithError(err).Warn("Secrets keystore unavailable, server-pushed secrets are disabled")
+		return ""
+	}
+	return key.PublicKey()
+}
+
+// attachSecrets gives the manager's secret-consuming integrations their scope
+func attachSecrets(mgr *integrations.Manager) {
+	if store, _, err := loadSecretStore(); err == nil {
+		mgr.SetSecretStore(store)
+	}
+}
+
+// handleSecretsUpdate stores secrets the server sealed to the agent key and
+// reports the result over the WebSocket, returning the error that failed the
+// command. Values are never logged.
+func handleSecretsUpdate(conn *websocket.Conn, integration string, set map[string]string, remove []string) error {
+	result := map[string]interface{}{
+		"type":        "secrets_update_result",
+		"integration": integration,
+		"success":     true,
+	}
+	store, _, err := loadSecretStore()
+	if err == nil {
+		err = store.Update(integration, set, remove)
+	}
+	if err != nil {
+		logger.WithError(err).WithField("integration", logutil.Sanitize(integration)).Warn("Rejected secrets_update")
+		result["success"] = false
+		result["error"] = err.Error()
+	} else {
+		logger.WithFields(logrus.Fields{
+			"integration": logutil.Sanitize(integration),
+			"set":         len(set),
+			"removed":     len(remove),
+		}).Info("Integration secrets updated")
+		result["names"] = store.Names()[integration]
+	}
+
+	if sendErr := sendWSMessage(conn, result); sendErr != nil {
+		logger.WithError(sendErr).Debug("Failed to send secrets_update result")
+	}
+	return err
+}
@@ -0,0 +1,207 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/utils"
+)
+
+// reportSectionNames maps the core sections a partial report can refresh to
+// their name in the section status block
+var reportSectionNames = map[string]string{
+	"packages": "packages",
+	"repos":    "repositories",
+	"hardware": "hardware",
+	"network":  "network",
+}
+
+// extraReportSections are sent separately from the report itself
+var extraReportSections = []string{"docker", "compliance"}
+
+// parseReportSections validates a sections list from the CLI or server
+func parseReportSections(sections []string) ([]string, error) {
+	var out []string
+	for _, section := range sections {
+		section = strings.ToLower(strings.TrimSpace(section))
+		if section == "" {
+			continue
+		}
+		if _, ok := reportSectionNames[section]; !ok && !slices.Contains(extraReportSections, section) {
+			return nil, fmt.Errorf("unknown report section %q (use packages, repos, hardware, network, docker or compliance)", section)
+		}
+		if !slices.Contains(out, section) {
+			out = append(out, section)
+		}
+	}
+	return out, nil
+}
+
+// splitReportSections separates core report sections from docker/compliance
+func splitReportSections(sections []string) (core, extras []string) {
+	for _, section := range sections {
+		if _, ok := reportSectionNames[section]; ok {
+			core = append(core, section)
+		} else {
+			extras = append(extras, section)
+		}
+	}
+	return core, extras
+}
+
+// refreshExtraSections refreshes docker and/or compliance on their own
+func refreshExtraSections(ctx context.Context, extras []string) error {
+	var errs []error
+	if slices.Contains(extras, "docker") {
+		if cfgManager.IsIntegrationEnabled("docker") {
+			sendIntegrationData(ctx, "docker")
+		} else {
+			errs = append(errs, errors.New("docker integration is not enabled"))
+		}
+	}
+	if slices.Contains(extras, "compliance") {
+		errs = append(errs, refreshComplianceSection(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// refreshComplianceSection runs a compliance scan with the configured
+// scanners, unless one is already running
+func refreshComplianceSection(ctx context.Context) error {
+	if !complianceScanRunning.CompareAndSwap(false, true) {
+		return errors.New("a compliance scan is already running")
+	}
+	complianceScanCancelMu.Lock()
+	complianceScanSource = "on-demand"
+	complianceScanCancelMu.Unlock()
+	defer func() {
+		complianceScanCancelMu.Lock()
+		complianceScanSource = ""
+		complianceScanCancelMu.Unlock()
+		complianceScanRunning.Store(false)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, 25*time.Minute)
+	defer cancel()
+	complianceScanCancelMu.Lock()
+	complianceScanCancel = cancel
+	complianceScanCancelMu.Unlock()
+	defer func() {
+		complianceScanCancelMu.Lock()
+		complianceScanCancel = nil
+		complianceScanCancelMu.Unlock()
+	}()
+
+	return runComplianceScanWithOptions(ctx, &models.ComplianceScanOptions{})
+}
+
+// loadLastReport returns the last report sent, or nil
+func loadLastReport() *models.ReportPayload {
+	data, err := os.ReadFile(cfgManager.StatePath(lastReportFile))
+	if err != nil {
+		return nil
+	}
+	var payload models.ReportPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		logger.WithError(err).Debug("Ignoring unreadable last report")
+		return nil
+	}
+	return &payload
+}
+
+// packagesAfterFailure returns the packages to report when collecting them
+// failed: those of the last report sent, so the server doesn't take an empty
+// list as everything being removed. Without a last report nothing is sent.
+func packagesAfterFailure(previous *models.ReportPayload, pkgErr error) ([]models.Package, error) {
+	if previous == nil {
+		previous = loadLastReport()
+	}
+	if previous == nil {
+		return nil, fmt.Errorf("failed to get packages: %w", pkgErr)
+	}
+	logger.WithError(pkgErr).Warn("Failed to get packages, reporting the last list sent")
+	return previous.Packages, nil
+}
+
+func saveLastReport(payload *models.ReportPayload) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	if err := utils.WriteFileAtomic(cfgManager.StatePath(lastReportFile), data, 0600); err != nil {
+		logger.WithError(err).Debug("Failed to save last report")
+	}
+}
+
+// integrationStatusFile keeps the outcome of the last integration run. Integrations
+// are collected after the report is sent, so their status rides on the next report.
+const integrationStatusFile = "integration_status.json"
+
+// reportSectionTasks maps each report section to the collector tasks feeding it.
+// A panic in any of them marks the section failed; the report still goes out.
+var reportSectionTasks = map[string][]string{
+	"system":       {"os", "hostname", "architecture", "systemInfo", "ip", "reboot", "kernel", "machineID", "packageMgr"},
+	"hardware":     {"hardware"},
+	"network":      {"network"},
+	"packages":     {"packages"},
+	"repositories": {"repos", "subscription"},
+}
+
+// sectionStatus summarises one section from its collector panics and error
+func sectionStatus(tasks []string, panics map[string]any, err error) models.SectionStatus {
+	for _, task := range tasks {
+		if p, ok := panics[task]; ok {
+			return models.SectionStatus{Status: models.SectionFailed, Error: fmt.Sprintf("%s collector panicked: %v", task, p)}
+		}
+	}
+	if err != nil {
+		return models.SectionStatus{Status: models.SectionFailed, Error: err.Error()}
+	}
+	return models.SectionStatus{Status: models.SectionOK}
+}
+
+// integrationSectionStatus summarises an integration run: a collection error
+// means nothing was sent, an upload error means the server kept its old data
+func integrationSectionStatus(data *models.IntegrationData, sendErr error) models.SectionStatus {
+	now := time.Now().UTC()
+	switch {
+	case data.Error != "":
+		return models.SectionStatus{Status: models.SectionFailed, Error: data.Error, CheckedAt: &now}
+	case sendErr != nil:
+		return models.SectionStatus{Status: models.SectionDegraded, Error: "collected but not delivered: " + sendErr.Error(), CheckedAt: &now}
+	default:
+		return models.SectionStatus{Status: models.SectionOK, CheckedAt: &now}
+	}
+}
+
+func loadIntegrationSections() models.SectionStatuses {
+	data, err := os.ReadFile(cfgManager.StatePath(integrationStatusFile))
+	if err != nil {
+		return nil
+	}
+	var sections models.SectionStatuses
+	if err := json.Unmarshal(data, &sections); err != nil {
+		logger.WithError(err).Debug("Ignoring unreadable integration status")
+		return nil
+	}
+	return sections
+}
+
+// saveIntegrationSections replaces the stored integration status, so an
+// integration that was disabled since doesn't keep reporting its last failure
+func saveIntegrationSections(sections models.SectionStatuses) {
+	data, err := json.Marshal(sections)
+	if err != nil {
+		return
+	}
+	if err := utils.WriteFileAtomic(cfgManager.StatePath(integrationStatusFile), data, 0600); err != nil {
+		logger.WithError(err).Debug("Failed to save integration status")
+	}
+}
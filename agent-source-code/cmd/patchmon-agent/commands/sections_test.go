@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSectionStatus(t *testing.T) {
+	panics := map[string]any{"hardware": "index out of range"}
+
+	if got := sectionStatus([]string{"network"}, panics, nil); got.Status != models.SectionOK {
+		t.Fatalf("network = %+v, want ok", got)
+	}
+	if got := sectionStatus([]string{"hardware"}, panics, nil); got.Status != models.SectionFailed || got.Error == "" {
+		t.Fatalf("hardware = %+v, want failed with error", got)
+	}
+	if got := sectionStatus([]string{"repos"}, panics, errors.New("permission denied")); got.Status != models.SectionFailed || got.Error != "permission denied" {
+		t.Fatalf("repos = %+v, want failed with error", got)
+	}
+}
+
+func TestPackagesAfterFailure(t *testing.T) {
+	cfgManager = config.New()
+	cfgManager.SetConfigFile(filepath.Join(t.TempDir(), "config.yml"))
+	logger = logrus.New()
+	logger.SetOutput(io.Discard)
+	pkgErr := errors.New("dpkg-query: status database locked")
+
+	if _, err := packagesAfterFailure(nil, pkgErr); !errors.Is(err, pkgErr) {
+		t.Fatalf("err = %v, want the package error when there is no last report", err)
+	}
+
+	saveLastReport(&models.ReportPayload{Packages: []models.Package{{Name: "bash"}}})
+	got, err := packagesAfterFailure(nil, pkgErr)
+	if err != nil || len(got) != 1 || got[0].Name != "bash" {
+		t.Fatalf("got %v, %v; want the last report's packages", got, err)
+	}
+
+	// A partial report already has the last report loaded
+	got, err = packagesAfterFailure(&models.ReportPayload{Packages: []models.Package{{Name: "curl"}}}, pkgErr)
+	if err != nil || len(got) != 1 || got[0].Name != "curl" {
+		t.Fatalf("got %v, %v; want the given report's packages", got, err)
+	}
+}
+
+func TestIntegrationSectionStatus(t *testing.T) {
+	timedOut := &models.IntegrationData{Name: "docker", Error: "context deadline exceeded"}
+	if got := integrationSectionStatus(timedOut, nil); got.Status != models.SectionFailed || got.CheckedAt == nil {
+		t.Fatalf("collection error = %+v, want failed", got)
+	}
+
+	ok := &models.IntegrationData{Name: "docker"}
+	if got := integrationSectionStatus(ok, errors.New("status 502")); got.Status != models.SectionDegraded {
+		t.Fatalf("upload error = %+v, want degraded", got)
+	}
+	if got := integrationSectionStatus(ok, nil); got.Status != models.SectionOK || got.Error != "" {
+		t.Fatalf("success = %+v, want ok", got)
+	}
+}
+
+func TestParseReportSections(t *testing.T) {
+	got, err := parseReportSections([]string{"Hardware", " docker ", "hardware", ""})
+	if err != nil {
+		t.Fatalf("parseReportSections: %v", err)
+	}
+	if len(got) != 2 || got[0] != "hardware" || got[1] != "docker" {
+		t.Fatalf("parseReportSections = %v, want [hardware docker]", got)
+	}
+	if _, err := parseReportSections([]string{"kernel"}); err == nil {
+		t.Fatal("expected unknown section to be rejected")
+	}
+
+	core, extras := splitReportSections([]string{"packages", "compliance", "network"})
+	if len(core) != 2 || len(extras) != 1 || extras[0] != "compliance" {
+		t.Fatalf("splitReportSections = %v / %v", core, extras)
+	}
+}
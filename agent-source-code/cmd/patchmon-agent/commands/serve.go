@@ -18,12 +18,14 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 	"patchmon-agent/internal/client"
 	"patchmon-agent/internal/config"
 	"patchmon-agent/internal/integrations"
@@ -32,12 +34,12 @@ import (
 	"patchmon-agent/internal/logutil"
 	"patchmon-agent/internal/packages"
 	"patchmon-agent/internal/pkgversion"
-	"patchmon-agent/internal/system"
+	"patchmon-agent/internal/tracing"
 	"patchmon-agent/internal/utils"
-	"patchmon-agent/pkg/models"
 
 	"github.com/gorilla/websocket"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/knownhosts"
 )
@@ -100,7 +102,15 @@ func runServiceLoop(stopCh <-chan struct{}) error {
 		return loadErr
 	}
 
-	httpClient := client.New(cfgManager, logger)
+	if err := acquireServeLock(); err != nil {
+		return err
+	}
+	auditFilePermissions(cfgManager.GetConfig().FixFilePermissions)
+	applyToolPolicy()
+	settleInterruptedActions()
+	defer startTracing()()
+
+	httpClient := apiClient()
 	ctx := context.Background()
 
 	// Get api_id for offset calculation
@@ -232,20 +242,71 @@ func runServiceLoop(stopCh <-chan struct{}) error {
 
 	// Send startup ping to notify server that agent has started
 	logger.Info("🚀 Agent starting up, notifying server...")
-	if _, err := httpClient.Ping(ctx); err != nil {
+	startupPing := &models.PingRequest{ClockSkewSeconds: measureClockSkew(ctx, httpClient), Status: "active", AgentPublicKey: agentPublicKey(), ObserverMode: cfgManager.IsObserverMode(), Permissions: cfgManager.ActionPermissions(), LastActions: lastActions(), Jobs: jobsForPing(), Resources: agentResources.sample(), Maintenance: maintenanceStatus()}
+	paused := loadPause()
+	if paused != nil {
+		startupPing.Status, startupPing.Paused = "paused", paused
+	}
+	if resp, err := httpClient.Ping(ctx, startupPing); err != nil {
 		logger.WithError(err).Warn("startup ping failed, will retry")
 	} else {
+		rememberServerSchema(resp.SchemaVersion)
 		logger.Info("✅ Startup notification sent to server")
 	}
 
+	// Read-only API for other tooling on this host
+	if listen := cfgManager.GetConfig().LocalAPIListen; listen != "" {
+		go runLocalAPI(ctx, listen)
+	}
+
+	// Prometheus metrics
+	if listen := cfgManager.GetConfig().MetricsListen; listen != "" {
+		go runMetricsListener(ctx, listen)
+	}
+
+	// Forward traffic for agents that can't reach the server themselves
+	if cfg := cfgManager.GetConfig(); cfg.Relay != nil && cfg.Relay.Listen != "" {
+		go func() {
+			if err := runRelay(ctx, cfg); err != nil {
+				logger.WithError(err).Error("Relay stopped")
+			}
+		}()
+	}
+
+	// Remember when this boot was last seen running, for the downtime the
+	// first report after a reboot carries
+	go runBootHeartbeat(ctx)
+
+	// Watch our own CPU, memory and descriptors for slow leaks
+	go runResourceMonitor(ctx)
+	go runDebugSignalHandler(ctx)
+
+	// Keep DNS/transport health fresh so resolver or MTU breakage is visible
+	go runConnectivityMonitor(ctx)
+
+	// Recover from windows of silence (stuck reports, WebSocket that won't come back).
+	// The WebSocket counts as down until wsLoop's first successful dial.
+	recordWebSocketState(false)
+	go runWatchdog(ctx)
+
 	// Start websocket loop FIRST so agent appears online immediately
 	logger.Info("Establishing WebSocket connection...")
 	messages := make(chan wsMsg, 10)
 	dockerEvents := make(chan interface{}, 100)
-	go wsLoop(messages, dockerEvents)
+	registerDebugQueue("messages", messages)
+	registerDebugQueue("docker_events", dockerEvents)
+	registerDebugQueue("compliance_progress", complianceProgressChan)
+	go wsLoop(nil, messages, dockerEvents)
+	for _, server := range serverProfiles() {
+		go wsLoop(server, messages, nil)
+	}
 
-	// Start integration monitoring (Docker real-time events, etc.)
-	startIntegrationMonitoring(ctx, dockerEvents)
+	// Start integration monitoring (Docker real-time events, etc.). Events pass
+	// through the crash-loop watcher on their way to the WebSocket.
+	integrationEvents := make(chan interface{}, 100)
+	registerDebugQueue("integration_events", integrationEvents)
+	go watchContainerEvents(integrationEvents, dockerEvents)
+	startIntegrationMonitoring(ctx, integrationEvents)
 
 	// Report current integration status on startup (wait a moment for WebSocket)
 	go func() {
@@ -253,8 +314,34 @@ func runServiceLoop(stopCh <-chan struct{}) error {
 		reportIntegrationStatus(ctx)
 	}()
 
-	// Run initial report in background so it doesn't block WebSocket
+	// Run initial report in background so it doesn't block WebSocket. It waits
+	// for this host's point in the startup window, which the server can
+	// stretch via slow_start in its "connected" message.
+	window := startupReportWindow()
+	if _, boot := detectReboot(newSystemDetector()); boot != nil {
+		// Maintenance reboots should show up on the server within a minute
+		window = min(window, rebootReportWindow)
+		logger.WithField("previous_boot_id", boot.PreviousBootID).Info("Host has rebooted, reporting promptly")
+	}
+	gate := newStartupReportGate(apiID, window)
+	startupGateMu.Lock()
+	startupGate = gate
+	startupGateMu.Unlock()
 	go func() {
+		defer func() {
+			startupGateMu.Lock()
+			startupGate = nil
+			startupGateMu.Unlock()
+		}()
+		if delay := time.Until(gate.deadline()); delay > 0 {
+			logger.WithField("delay_seconds", int(delay.Seconds())).Info("Delaying initial report to spread startup load")
+		}
+		if err := gate.wait(ctx); err != nil {
+			return
+		}
+		if skipWhilePaused("initial report") {
+			return
+		}
 		logger.Info("Sending initial report on startup (background)...")
 		if err := sendReport(false); err != nil {
 			logger.WithError(err).Warn("initial report failed")
@@ -263,13 +350,37 @@ func runServiceLoop(stopCh <-chan struct{}) error {
 		}
 	}()
 
-	var compScheduler *complianceScheduler
-	if cfgManager.IsIntegrationEnabled("compliance") && !cfgManager.IsComplianceOnDemandOnly() {
-		compScheduler = newComplianceScheduler(cfgManager.GetComplianceScanInterval())
-		compScheduler.Start()
-		defer compScheduler.Stop()
+	// Report within a minute of packages being installed or removed instead of
+	// waiting for the next interval
+	packagesChanged := make(chan struct{}, 1)
+	if !cfgManager.GetConfig().DisablePackageWatch {
+		if targets := packages.DefaultWatchTargets(); len(targets) > 0 {
+			watcher := packages.NewWatcher(logger, targets)
+			go func() {
+				err := watcher.Run(ctx, func() {
+					select {
+					case packagesChanged <- struct{}{}:
+					default:
+					}
+				})
+				if err != nil {
+					logger.WithError(err).Warn("Package database watcher unavailable, relying on periodic reports")
+				}
+			}()
+		}
 	}
 
+	// apt/dnf hooks (see `patchmon-agent hooks install`) notify over a unix socket
+	if runtime.GOOS != "windows" {
+		go listenForPackageHooks(ctx, packagesChanged)
+	}
+
+	// Always running: set_compliance_mode can switch to "enabled" without a
+	// restart, and each scheduled run checks the mode itself
+	compScheduler := newComplianceScheduler(cfgManager.GetComplianceScanInterval(), apiID)
+	compScheduler.Start()
+	defer compScheduler.Stop()
+
 	// Create ticker with initial interval for package reports
 	ticker := time.NewTicker(time.Duration(intervalMinutes) * time.Minute)
 	defer ticker.Stop()
@@ -285,6 +396,12 @@ func runServiceLoop(stopCh <-chan struct{}) error {
 	// Track current interval for offset recalculation on updates
 	currentInterval := intervalMinutes
 
+	// Pauses can start or end from the CLI, or simply expire; content import
+	// is picked up on the same tick
+	pauseCheck := time.NewTicker(time.Minute)
+	defer pauseCheck.Stop()
+	contentStamp := importedContentStamp()
+
 	// Create a stop channel that never closes if none provided (for Unix systems)
 	effectiveStopCh := stopCh
 	if effectiveStopCh == nil {
@@ -303,13 +420,85 @@ func runServiceLoop(stopCh <-chan struct{}) error {
 			logger.Debug("Offset period completed, periodic reports will now start")
 		case <-ticker.C:
 			// Only process ticker events after offset has passed
-			if offsetPassed {
+			if offsetPassed && !skipWhilePaused("periodic report") {
 				if err := sendReport(false); err != nil {
 					logger.WithError(err).Warn("periodic report failed")
 				}
 			}
+		case <-packagesChanged:
+			if skipWhilePaused("package change report") {
+				continue
+			}
+			if err := sendReport(false); err != nil {
+				logger.WithError(err).Warn("package change report failed")
+			}
+		case <-pauseCheck.C:
+			current := loadPause()
+			if pauseChanged(paused, current) {
+				paused = current
+				sendPauseStatus(ctx, httpClient, paused)
+				if paused == nil {
+					logger.Info("▶️  Agent resumed, sending report")
+					if err := sendReport(false); err != nil {
+						logger.WithError(err).Warn("report after resume failed")
+					}
+				} else {
+					logger.WithField("until", paused.Until.Format(time.RFC3339)).Info("⏸️  Agent paused")
+				}
+			}
+			if stamp := importedContentStamp(); !stamp.Equal(contentStamp) {
+				contentStamp = stamp
+				logger.Info("Imported compliance content changed, applying it")
+				applyToolPolicy()
+			}
 		case m := <-messages:
+			if jobs.cancelledWhileQueued(m.jobID) {
+				finishAction(m, errCancelledWhileQueued)
+				continue
+			}
+			if pausableActions[m.kind] && skipWhilePaused(m.kind) {
+				recordActionOutcome(m, actionSkipped, "agent paused")
+				nackCommand(m.replyConn(), m.kind, m.commandID, models.NackSkipped, "agent paused")
+				m.stepDone(errors.New("agent paused"))
+				continue
+			}
+			if refuseRemoteAction(m) {
+				m.stepDone(errors.New("refused"))
+				continue
+			}
+			if deferToMaintenanceWindow(ctx, m, messages) {
+				continue
+			}
+			recordActionStart(m)
+			if !untrackedActions[m.kind] {
+				ackCommand(m.replyConn(), m.kind, m.commandID)
+			}
+			// Results the command sends go back to the server that sent it
+			ctx := withServer(ctx, m.server)
 			switch m.kind {
+			case "pause":
+				state, err := savePause(m.pauseDuration, m.pauseReason, "server")
+				finishAction(m, err)
+				if err != nil {
+					logger.WithError(err).Warn("pause failed")
+					continue
+				}
+				paused = state
+				sendPauseStatus(ctx, httpClient, paused)
+				logger.WithField("until", paused.Until.Format(time.RFC3339)).Info("⏸️  Agent paused by server")
+			case "resume":
+				_, err := clearPause()
+				finishAction(m, err)
+				if err != nil {
+					logger.WithError(err).Warn("resume failed")
+					continue
+				}
+				paused = nil
+				sendPauseStatus(ctx, httpClient, nil)
+				logger.Info("▶️  Agent resumed by server, sending report")
+				if err := sendReport(false); err != nil {
+					logger.WithError(err).Warn("report after resume failed")
+				}
 			case "settings_update":
 				if m.interval > 0 && m.interval != currentInterval {
 					// Save new interval to config.yml
@@ -346,7 +535,7 @@ func runServiceLoop(stopCh <-chan struct{}) error {
 
 					logger.WithField("new_interval", m.interval).Info("interval updated, no report sent")
 				}
-				if m.complianceScanInterval > 0 && compScheduler != nil {
+				if m.complianceScanInterval > 0 {
 					if err := cfgManager.SetComplianceScanInterval(m.complianceScanInterval); err != nil {
 						logger.WithError(err).Warn("Failed to save compliance scan interval to config.yml")
 					} else {
@@ -358,29 +547,110 @@ func runServiceLoop(stopCh <-chan struct{}) error {
 					if err := cfgManager.SetPackageCacheRefresh(m.packageCacheRefreshMode, m.packageCacheRefreshMaxAge); err != nil {
 						logger.WithError(err).Warn("Failed to save package cache refresh settings to config.yml")
 					} else {
+						applyToolPolicy()
 						logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
 							"mode":    m.packageCacheRefreshMode,
 							"max_age": m.packageCacheRefreshMaxAge,
 						})).Info("Package cache refresh settings updated")
 					}
 				}
+				if m.dockerBenchImage != "" && m.dockerBenchImage != cfgManager.GetConfig().DockerBenchImage {
+					if reason := dockerBenchImageRefusal(m); reason != "" {
+						logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
+							"image":  m.dockerBenchImage,
+							"reason": reason,
+						})).Warn("Refusing Docker Bench image from server")
+					} else if err := cfgManager.SetDockerBenchImage(m.dockerBenchImage); err != nil {
+						logger.WithError(err).Warn("Failed to save Docker Bench image to config.yml")
+					} else {
+						applyToolPolicy()
+						logger.WithField("image", logutil.Sanitize(m.dockerBenchImage)).Info("Docker Bench image updated")
+					}
+				}
+				if m.complianceRoles != nil || m.complianceRoleProfiles != nil {
+					roles := m.complianceRoles
+					if roles == nil {
+						roles = cfgManager.GetComplianceRoles()
+					}
+					if err := cfgManager.SetComplianceRoles(roles, m.complianceRoleProfiles); err != nil {
+						logger.WithError(err).Warn("Failed to save compliance roles to config.yml")
+					} else {
+						logger.WithField("profiles", cfgManager.GetComplianceRoleScanProfiles()).Info("Compliance roles updated")
+					}
+				}
+				finishAction(m, nil)
 			case "report_now":
-				if err := sendReport(false); err != nil {
-					logger.WithError(err).Warn("report_now failed")
+				// Only the report itself is sent here. Docker and compliance
+				// sections, a compliance scan included, refresh as a job so the
+				// loop keeps taking cancels and other commands meanwhile.
+				core, extras := splitReportSections(m.reportSections)
+				var err error
+				if len(core) > 0 || len(extras) == 0 {
+					err = sendReportSections(false, core)
+				}
+				if err != nil || len(extras) == 0 {
+					finishAction(m, err)
+					if err != nil {
+						logger.WithError(err).Warn("report_now failed")
+					}
+					continue
 				}
+				go func(msg wsMsg) {
+					jobCtx, done := jobs.context(ctx, msg.jobID)
+					defer done()
+					err := jobErr(jobCtx, refreshExtraSections(jobCtx, extras))
+					finishAction(msg, err)
+					if err != nil {
+						logger.WithError(err).Warn("report_now failed to refresh sections")
+					}
+				}(m)
 			case "update_agent":
 				if err := updateAgent(); err != nil {
+					finishAction(m, err)
 					logger.WithError(err).Warn("update_agent failed")
 				}
 			case "refresh_integration_status":
 				logger.Info("Refreshing integration status on server request...")
-				go reportIntegrationStatus(ctx)
+				go func(msg wsMsg) {
+					jobCtx, done := jobs.context(ctx, msg.jobID)
+					defer done()
+					reportIntegrationStatus(jobCtx)
+					finishAction(msg, jobCtx.Err())
+				}(m)
 			case "docker_inventory_refresh":
 				logger.Info("Refreshing Docker inventory on server request...")
-				go refreshDockerInventory(ctx)
+				go func(msg wsMsg) {
+					jobCtx, done := jobs.context(ctx, msg.jobID)
+					defer done()
+					refreshDockerInventory(jobCtx)
+					finishAction(msg, jobCtx.Err())
+				}(m)
+			case "hardware_inventory":
+				logger.Info("Collecting deep hardware inventory on server request...")
+				go func(msg wsMsg) {
+					jobCtx, done := jobs.context(ctx, msg.jobID)
+					defer done()
+					err := jobErr(jobCtx, uploadHardwareInventory(jobCtx, msg.inventoryBudget, msg.commandID))
+					finishAction(msg, err)
+					if err != nil {
+						logger.WithError(err).Warn("hardware_inventory failed")
+					} else {
+						logger.Info("Hardware inventory uploaded")
+					}
+				}(m)
 			case "run_patch":
 				go func(msg wsMsg) {
-					if err := runPatch(msg.patchRunID, msg.patchType, msg.packageNames, msg.dryRun); err != nil {
+					// A cancelled job counts as stopped, like patch_run_stop
+					jobCtx, cancelJob := context.WithCancel(ctx)
+					defer cancelJob()
+					release := jobs.onCancel(msg.jobID, func() {
+						patchRunStopped.Store(msg.patchRunID, true)
+						cancelJob()
+					})
+					defer release()
+					err := runPatch(jobCtx, msg.patchRunID, msg.patchType, msg.packageNames, msg.dryRun)
+					finishAction(msg, err)
+					if err != nil {
 						logger.WithError(err).Warn("run_patch failed")
 					} else {
 						logger.Info("run_patch completed successfully")
@@ -391,13 +661,17 @@ func runServiceLoop(stopCh <-chan struct{}) error {
 				if m.force {
 					logger.Info("Force update requested, updating agent now")
 					if err := updateAgent(); err != nil {
+						finishAction(m, err)
 						logger.WithError(err).Warn("forced update failed")
 					}
 				} else {
+					finishAction(m, nil)
 					logger.Info("Update available, run 'patchmon-agent update-agent' to update")
 				}
 			case "integration_toggle":
-				if err := toggleIntegration(m.integrationName, m.integrationEnabled); err != nil {
+				err := toggleIntegration(m.integrationName, m.integrationEnabled)
+				finishAction(m, err)
+				if err != nil {
 					logger.WithError(err).Warn("integration_toggle failed")
 				} else {
 					logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
@@ -440,7 +714,8 @@ func runServiceLoop(stopCh <-chan struct{}) error {
 						complianceScanRunning.Store(false)
 					}()
 
-					ctx, cancel := context.WithCancel(context.Background())
+					ctx, cancel := jobs.context(context.Background(), msg.jobID)
+					defer cancel()
 					complianceScanCancelMu.Lock()
 					complianceScanCancel = cancel
 					complianceScanCancelMu.Unlock()
@@ -456,7 +731,9 @@ func runServiceLoop(stopCh <-chan struct{}) error {
 						OpenSCAPEnabled:      msg.openscapEnabled,
 						DockerBenchEnabled:   msg.dockerBenchEnabled,
 					}
-					if err := runComplianceScanWithOptions(ctx, options); err != nil {
+					err := jobErr(ctx, runComplianceScanWithOptions(ctx, options))
+					finishAction(msg, err)
+					if err != nil {
 						if errors.Is(err, context.Canceled) {
 							logger.Info("Compliance scan was cancelled")
 						} else {
@@ -475,6 +752,7 @@ func runServiceLoop(stopCh <-chan struct{}) error {
 				cancelFn := complianceScanCancel
 				complianceScanCancel = nil
 				complianceScanCancelMu.Unlock()
+				finishAction(m, nil)
 				if cancelFn != nil {
 					cancelFn()
 					logger.Info("Compliance scan cancel requested and sent to running scan")
@@ -482,6 +760,7 @@ func runServiceLoop(stopCh <-chan struct{}) error {
 					logger.Debug("Compliance scan cancel requested but no scan is running")
 				}
 			case "patch_run_stop":
+				finishAction(m, nil)
 				if v, ok := patchRunCancels.Load(m.patchRunID); ok {
 					if cancelFn, ok := v.(context.CancelFunc); ok && cancelFn != nil {
 						patchRunStopped.Store(m.patchRunID, true)
@@ -494,31 +773,52 @@ func runServiceLoop(stopCh <-chan struct{}) error {
 			case "upgrade_ssg":
 				targetVersion := m.version
 				logger.WithField("target_version", targetVersion).Info("Upgrading SSG content packages...")
-				go func() {
-					if err := upgradeSSGContent(targetVersion); err != nil {
+				go func(msg wsMsg) {
+					err := upgradeSSGContent(targetVersion)
+					finishAction(msg, err)
+					if err != nil {
 						logger.WithError(err).Warn("upgrade_ssg failed")
 					} else {
 						logger.Info("SSG content packages upgraded successfully")
 					}
-				}()
+				}(m)
 			case "install_scanner":
 				logger.Info("Install scanner requested (OpenSCAP + SSG)...")
-				go func() {
-					if err := runInstallScanner(); err != nil {
+				go func(msg wsMsg) {
+					err := runInstallScanner()
+					finishAction(msg, err)
+					if err != nil {
 						logger.WithError(err).Warn("install_scanner failed")
 					} else {
 						logger.Info("Install scanner completed successfully")
 					}
-				}()
+				}(m)
 			case "remediate_rule":
 				logger.WithField("rule_id", logutil.Sanitize(m.ruleID)).Info("Remediating single rule...")
-				go func(ruleID string) {
-					if err := remediateSingleRule(ruleID); err != nil {
-						logger.WithError(err).WithField("rule_id", logutil.Sanitize(ruleID)).Warn("remediate_rule failed")
+				go func(msg wsMsg) {
+					jobCtx, done := jobs.context(context.Background(), msg.jobID)
+					defer done()
+					err := jobErr(jobCtx, remediateSingleRule(jobCtx, msg.ruleID))
+					finishAction(msg, err)
+					if err != nil {
+						logger.WithError(err).WithField("rule_id", logutil.Sanitize(msg.ruleID)).Warn("remediate_rule failed")
+					} else {
+						logger.WithField("rule_id", logutil.Sanitize(msg.ruleID)).Info("Single rule remediation completed")
+					}
+				}(m)
+			case "compliance_ckl_export":
+				logger.WithField("profile_id", logutil.Sanitize(m.profileID)).Info("Exporting STIG Viewer checklist...")
+				go func(msg wsMsg) {
+					jobCtx, done := jobs.context(ctx, msg.jobID)
+					defer done()
+					err := jobErr(jobCtx, uploadComplianceCKL(jobCtx, msg.profileID, msg.commandID))
+					finishAction(msg, err)
+					if err != nil {
+						logger.WithError(err).Warn("compliance_ckl_export failed")
 					} else {
-						logger.WithField("rule_id", logutil.Sanitize(ruleID)).Info("Single rule remediation completed")
+						logger.Info("STIG Viewer checklist uploaded")
 					}
-				}(m.ruleID)
+				}(m)
 			case "docker_image_scan":
 				logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
 					"image_name":      m.imageName,
@@ -526,12 +826,56 @@ func runServiceLoop(stopCh <-chan struct{}) error {
 					"scan_all_images": m.scanAllImages,
 				})).Info("Running Docker image CVE scan...")
 				go func(msg wsMsg) {
-					if err := runDockerImageScan(msg.imageName, msg.containerName, msg.scanAllImages); err != nil {
+					jobCtx, done := jobs.context(context.Background(), msg.jobID)
+					defer done()
+					err := jobErr(jobCtx, runDockerImageScan(jobCtx, msg.imageName, msg.containerName, msg.scanAllImages, msg.force))
+					finishAction(msg, err)
+					if err != nil {
 						logger.WithError(err).Warn("docker_image_scan failed")
 					} else {
 						logger.Info("Docker image CVE scan completed successfully")
 					}
 				}(m)
+			case "docker_update_container":
+				logger.WithField("container_name", logutil.Sanitize(m.containerName)).Info("Updating Docker container...")
+				go func(msg wsMsg) {
+					jobCtx, done := jobs.context(ctx, msg.jobID)
+					defer done()
+					err := jobErr(jobCtx, updateDockerContainer(jobCtx, msg.containerName, msg.commandID))
+					finishAction(msg, err)
+					if err != nil {
+						logger.WithError(err).Warn("docker_update_container failed")
+					} else {
+						logger.Info("Docker container update completed")
+					}
+				}(m)
+			case "docker_prune":
+				logger.WithField("dry_run", m.dryRun).Info("Pruning Docker containers, images and networks...")
+				go func(msg wsMsg) {
+					jobCtx, done := jobs.context(ctx, msg.jobID)
+					defer done()
+					err := jobErr(jobCtx, pruneDocker(jobCtx, msg.pruneAgeDays, msg.dryRun, msg.commandID))
+					finishAction(msg, err)
+					if err != nil {
+						logger.WithError(err).Warn("docker_prune failed")
+					}
+				}(m)
+			case "package_update":
+				logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
+					"package_names": m.packageNames,
+					"dry_run":       m.dryRun,
+				})).Info("Updating packages...")
+				go func(msg wsMsg) {
+					jobCtx, done := jobs.context(ctx, msg.jobID)
+					defer done()
+					err := jobErr(jobCtx, runPackageUpdate(jobCtx, msg.packageNames, msg.dryRun, msg.commandID))
+					finishAction(msg, err)
+					if err != nil {
+						logger.WithError(err).Warn("package_update failed")
+					} else {
+						logger.Info("Package update completed")
+					}
+				}(m)
 			case "set_compliance_mode":
 				logger.WithField("mode", logutil.Sanitize(m.complianceMode)).Info("Setting compliance mode...")
 				// Convert string mode to ComplianceMode type
@@ -544,16 +888,23 @@ func runServiceLoop(stopCh <-chan struct{}) error {
 				case "enabled":
 					mode = config.ComplianceEnabled
 				default:
+					finishAction(m, fmt.Errorf("invalid compliance mode"))
 					logger.WithField("mode", logutil.Sanitize(m.complianceMode)).Warn("Invalid compliance mode, ignoring")
 					continue
 				}
-				if err := cfgManager.SetComplianceMode(mode); err != nil {
+				err := cfgManager.SetComplianceMode(mode)
+				finishAction(m, err)
+				if err != nil {
 					logger.WithError(err).Warn("Failed to set compliance mode")
 				} else {
 					logger.WithField("mode", logutil.Sanitize(m.complianceMode)).Info("Compliance mode updated in config.yml")
 				}
+			case "secrets_update":
+				finishAction(m, handleSecretsUpdate(m.replyConn(), m.integrationName, m.secrets, m.secretsRemove))
 			case "apply_config":
-				if err := applyConfig(m.applyConfig); err != nil {
+				err := applyConfig(m.applyConfig)
+				finishAction(m, err)
+				if err != nil {
 					logger.WithError(err).Warn("apply_config failed")
 				} else {
 					logger.Info("apply_config completed, service will restart")
@@ -567,7 +918,9 @@ func runServiceLoop(stopCh <-chan struct{}) error {
 				} else {
 					mode = config.ComplianceEnabled
 				}
-				if err := cfgManager.SetComplianceMode(mode); err != nil {
+				err := cfgManager.SetComplianceMode(mode)
+				finishAction(m, err)
+				if err != nil {
 					logger.WithError(err).Warn("Failed to set compliance mode")
 				} else {
 					logger.WithField("mode", string(mode)).Info("Compliance mode updated in config.yml (from legacy on-demand-only)")
@@ -580,6 +933,7 @@ func runServiceLoop(stopCh <-chan struct{}) error {
 				if wsConn != nil {
 					go handleSSHProxy(m, wsConn)
 				}
+				finishAction(m, nil)
 			case "ssh_proxy_input":
 				globalWsConnMu.RLock()
 				wsConn := globalWsConn
@@ -609,6 +963,7 @@ func runServiceLoop(stopCh <-chan struct{}) error {
 				if wsConn != nil {
 					go handleRDPProxy(m, wsConn)
 				}
+				finishAction(m, nil)
 			case "rdp_proxy_input":
 				globalWsConnMu.RLock()
 				wsConn := globalWsConn
@@ -648,7 +1003,7 @@ func (a *ssgClientAdapter) DownloadSSGContent(ctx context.Context, filename, des
 // upgradeSSGContent upgrades the SCAP Security Guide content packages.
 // Prefers downloading from PatchMon server; falls back to GitHub if server has no content.
 func upgradeSSGContent(targetVersion string) error {
-	httpClient := client.New(cfgManager, logger)
+	httpClient := apiClient()
 	complianceInteg := compliance.New(logger)
 
 	downloader := &ssgClientAdapter{c: httpClient}
@@ -696,7 +1051,7 @@ func upgradeSSGContent(targetVersion string) error {
 // runInstallScanner installs OpenSCAP and SSG content (apt/dnf install, update SSG) and reports status via HTTP
 // Sends granular install events so the frontend can display real-time progress.
 func runInstallScanner() error {
-	httpClient := client.New(cfgManager, logger)
+	httpClient := apiClient()
 	ctx := context.Background()
 	enabled := cfgManager.IsIntegrationEnabled("compliance")
 
@@ -747,10 +1102,11 @@ func runInstallScanner() error {
 
 	if err := openscapScanner.EnsureInstalled(); err != nil {
 		logger.WithError(err).Warn("EnsureInstalled failed")
+		_, msg := toolInstallFailure(err, "OpenSCAP installation failed")
 		events[len(events)-1] = models.InstallEvent{
 			Step:      "install_openscap",
 			Status:    "failed",
-			Message:   fmt.Sprintf("OpenSCAP installation failed: %s", err.Error()),
+			Message:   msg,
 			Timestamp: events[len(events)-1].Timestamp,
 		}
 		addEvent("complete", "failed", "Installation failed")
@@ -824,10 +1180,11 @@ func runInstallScanner() error {
 		if dockerBenchScanner.IsAvailable() {
 			if err := dockerBenchScanner.EnsureInstalled(); err != nil {
 				logger.WithError(err).Warn("Failed to pre-pull Docker Bench image")
+				_, msg := toolInstallFailure(err, "Docker Bench image pull failed")
 				events[len(events)-1] = models.InstallEvent{
 					Step:      "docker_bench",
 					Status:    "failed",
-					Message:   fmt.Sprintf("Docker Bench image pull failed: %s", err.Error()),
+					Message:   msg,
 					Timestamp: events[len(events)-1].Timestamp,
 				}
 			} else {
@@ -862,7 +1219,7 @@ func runInstallScanner() error {
 }
 
 // remediateSingleRule remediates a single failed compliance rule
-func remediateSingleRule(ruleID string) error {
+func remediateSingleRule(ctx context.Context, ruleID string) error {
 	if ruleID == "" {
 		return fmt.Errorf("rule ID is required")
 	}
@@ -878,7 +1235,7 @@ func remediateSingleRule(ruleID string) error {
 	// Run scan with remediation for just this rule
 	// Use level1_server as the default profile - it contains most common rules
 	// The --rule flag will filter to just the specified rule
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 
 	options := &models.ComplianceScanOptions{
@@ -908,7 +1265,7 @@ func reportIntegrationStatus(ctx context.Context) {
 	logger.Debug("Reporting integration status...")
 
 	// Create HTTP client for API calls
-	httpClient := client.New(cfgManager, logger)
+	httpClient := apiClient()
 
 	// Report compliance integration status if enabled
 	if cfgManager.IsIntegrationEnabled("compliance") {
@@ -967,6 +1324,14 @@ func reportIntegrationStatus(ctx context.Context) {
 					components["oscap-docker"] = "failed"
 				}
 			}
+
+			// Tool and feed versions, so the server can flag hosts scanning
+			// with stale content
+			scannerDetails.DockerBenchImage = dockerBenchScanner.ImageDigest(ctx)
+			scannerDetails.OscapDockerVersion = oscapDockerScanner.GetVersion()
+			scannerDetails.CVEFeeds = compliance.CVEFeedStatuses()
+			scannerDetails.SyftVersion = docker.SyftVersion(ctx)
+			scannerDetails.TrivyVersion, scannerDetails.TrivyDBUpdatedAt = docker.TrivyVersion(ctx)
 		} else {
 			// Docker integration not enabled - mark as unavailable (not failed)
 			components["docker-bench"] = "unavailable"
@@ -1040,7 +1405,7 @@ func refreshDockerInventory(ctx context.Context) {
 	}
 
 	// Create Docker integration
-	dockerInteg := docker.New(logger)
+	dockerInteg := newDockerIntegration()
 	if !dockerInteg.IsAvailable() {
 		logger.Warn("Docker is not available on this system")
 		return
@@ -1057,7 +1422,7 @@ func refreshDockerInventory(ctx context.Context) {
 	}
 
 	// Get system info for payload
-	systemDetector := system.New(logger)
+	systemDetector := newSystemDetector()
 	hostname, _ := systemDetector.GetHostname()
 	machineID := systemDetector.GetMachineID()
 
@@ -1084,7 +1449,7 @@ func refreshDockerInventory(ctx context.Context) {
 	})).Info("Sending Docker inventory to server...")
 
 	// Create HTTP client and send data
-	httpClient := client.New(cfgManager, logger)
+	httpClient := apiClient()
 	sendCtx, sendCancel := context.WithTimeout(ctx, 30*time.Second)
 	defer sendCancel()
 
@@ -1111,6 +1476,7 @@ func startIntegrationMonitoring(ctx context.Context, eventChan chan<- interface{
 	integrationMgr.SetEnabledChecker(func(name string) bool {
 		return cfgManager.IsIntegrationEnabled(name)
 	})
+	attachSecrets(integrationMgr)
 
 	// Register integrations
 	dockerInteg := docker.New(logger)
@@ -1132,10 +1498,21 @@ func startIntegrationMonitoring(ctx context.Context, eventChan chan<- interface{
 
 type wsMsg struct {
 	kind                      string
+	commandID                 string        // Server correlation ID, echoed in acknowledgements
+	done                      chan<- error  // For batch steps: receives how the step ended
+	jobID                     string        // Job table entry for background commands
+	pauseDuration             time.Duration // For pause
+	pauseReason               string        // For pause
+	reportSections            []string      // For report_now: refresh only these sections
+	inventoryBudget           time.Duration // For hardware_inventory
+	pruneAgeDays              int           // For docker_prune: older_than_days
 	interval                  int
 	complianceScanInterval    int
 	packageCacheRefreshMode   string
 	packageCacheRefreshMaxAge int
+	dockerBenchImage          string              // For settings_update
+	complianceRoles           []string            // For settings_update: host roles, nil if not sent
+	complianceRoleProfiles    map[string][]string // For settings_update: role -> compliance profiles
 	version                   string
 	force                     bool
 	integrationName           string
@@ -1148,11 +1525,13 @@ type wsMsg struct {
 	dockerBenchEnabled        *bool                  // For compliance_scan: per-host Docker Bench scanner toggle
 	ruleID                    string                 // For remediate_rule: specific rule ID to remediate
 	imageName                 string                 // For docker_image_scan: Docker image to scan
-	containerName             string                 // For docker_image_scan: Docker container to scan
+	containerName             string                 // For docker_image_scan and docker_update_container
 	scanAllImages             bool                   // For docker_image_scan: scan all images on system
 	complianceOnDemandOnly    bool                   // For set_compliance_on_demand_only (legacy)
 	complianceMode            string                 // For set_compliance_mode: "disabled", "on-demand", or "enabled"
 	applyConfig               map[string]interface{} // For apply_config: full config to apply
+	secrets                   map[string]string      // For secrets_update: values sealed to the agent key
+	secretsRemove             []string               // For secrets_update: names to delete
 	// SSH proxy fields
 	sshProxySessionID  string // Unique session ID for SSH proxy
 	sshProxyHost       string // SSH target host
@@ -1167,14 +1546,17 @@ type wsMsg struct {
 	// run_patch fields
 	patchRunID   string
 	patchType    string
-	packageNames []string
-	dryRun       bool
-	sshProxyData string // SSH input data
+	packageNames []string // For run_patch and package_update
+	dryRun       bool     // For run_patch, package_update and docker_prune
+	sshProxyData string   // SSH input data
 	// RDP proxy fields
 	rdpProxySessionID string // Unique session ID for RDP proxy
 	rdpProxyHost      string // RDP target host (default localhost)
 	rdpProxyPort      int    // RDP target port (default 3389)
 	rdpProxyData      string // RDP input data (base64)
+	// server is the entry of the servers list that sent the command, nil
+	// for patchmon_server
+	server *serverProfile
 }
 
 // Input validation patterns for WebSocket message fields
@@ -1270,6 +1652,27 @@ var complianceScanCancel context.CancelFunc
 var complianceScanCancelMu sync.Mutex
 var complianceScanSource string
 
+// wsPayload marshals v and applies the configured redaction
+func wsPayload(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal WebSocket message: %w", err)
+	}
+	return apiClient().RedactJSON(data)
+}
+
+// sendWSMessage writes v, redacted, to conn. Only the SSH and RDP proxy
+// streams, which must reach the browser byte for byte, bypass it.
+func sendWSMessage(conn *websocket.Conn, v interface{}) error {
+	data, err := wsPayload(v)
+	if err != nil {
+		return err
+	}
+	return writeWebSocketTextMessage(conn, data)
+}
+
+// writeWebSocketTextMessage writes payload as is, serialised with every other
+// writer on the connection
 func writeWebSocketTextMessage(conn *websocket.Conn, payload []byte) error {
 	globalWsWriteMu.Lock()
 	defer globalWsWriteMu.Unlock()
@@ -1292,76 +1695,21 @@ var patchRunCancels sync.Map
 // the runner can report stage="cancelled" instead of "failed" after the process exits.
 var patchRunStopped sync.Map
 
-type complianceScheduler struct {
-	interval time.Duration
-	stopCh   chan struct{}
-	resetCh  chan time.Duration
-}
-
-func newComplianceScheduler(intervalMinutes int) *complianceScheduler {
-	return &complianceScheduler{
-		interval: time.Duration(intervalMinutes) * time.Minute,
-		stopCh:   make(chan struct{}),
-		resetCh:  make(chan time.Duration, 1),
-	}
-}
-
-func (cs *complianceScheduler) Start() {
-	go cs.loop()
-}
-
-func (cs *complianceScheduler) Stop() {
-	close(cs.stopCh)
-}
-
-func (cs *complianceScheduler) Reset(intervalMinutes int) {
-	newInterval := time.Duration(intervalMinutes) * time.Minute
-	select {
-	case cs.resetCh <- newInterval:
-	default:
-	}
-}
-
-func (cs *complianceScheduler) loop() {
-	logger.WithField("compliance_scan_interval_minutes", int(cs.interval.Minutes())).Info("Compliance scheduler started")
-
-	select {
-	case <-time.After(30 * time.Second):
-	case <-cs.stopCh:
-		return
-	}
-
-	runScheduledComplianceScan()
-
-	ticker := time.NewTicker(cs.interval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-cs.stopCh:
-			logger.Info("Compliance scheduler stopped")
-			return
-		case newInterval := <-cs.resetCh:
-			ticker.Stop()
-			cs.interval = newInterval
-			ticker = time.NewTicker(cs.interval)
-			logger.WithField("compliance_scan_interval_minutes", int(cs.interval.Minutes())).Info("Compliance scan interval updated")
-		case <-ticker.C:
-			runScheduledComplianceScan()
-		}
-	}
-}
-
-func wsLoop(out chan<- wsMsg, dockerEvents <-chan interface{}) {
+// wsLoop keeps a WebSocket to profile open, nil meaning patchmon_server
+func wsLoop(profile *serverProfile, out chan<- wsMsg, dockerEvents <-chan interface{}) {
 	backoff := time.Second
 	for {
 		// connectOnce resets backoff to 1s on successful dial so a long-lived
 		// agent that drops its WS (e.g. Windows bouncing TermService/firewall
 		// when RDP settings change) reconnects fast instead of waiting out the
 		// escalated backoff from its prior drops.
-		connected, err := connectOnce(out, dockerEvents, &backoff)
+		connected, err := connectOnce(profile, out, dockerEvents, &backoff)
 		if err != nil {
-			logger.WithError(err).Warn("ws disconnected; retrying")
+			entry := logger.WithError(err)
+			if profile != nil {
+				entry = entry.WithField("server", profile.name)
+			}
+			entry.Warn("ws disconnected; retrying")
 		}
 		sleepFor := backoff
 		if !connected && backoff < 30*time.Second {
@@ -1371,13 +1719,20 @@ func wsLoop(out chan<- wsMsg, dockerEvents <-chan interface{}) {
 	}
 }
 
-func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *time.Duration) (connected bool, err error) {
-	server := cfgManager.GetConfig().PatchmonServer
+// connectOnce runs one WebSocket session with profile, or with
+// patchmon_server when profile is nil. Only patchmon_server's session carries
+// Docker events, compliance progress and proxy sessions.
+func connectOnce(profile *serverProfile, out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *time.Duration) (connected bool, err error) {
+	cfgMgr, httpClient := cfgManager, apiClient()
+	if profile != nil {
+		cfgMgr, httpClient = profile.cfg, profile.client
+	}
+	server := client.ServerURL(cfgMgr.GetConfig())
 	if server == "" {
 		return false, nil
 	}
-	apiID := cfgManager.GetCredentials().APIID
-	apiKey := cfgManager.GetCredentials().APIKey
+	apiID := cfgMgr.GetCredentials().APIID
+	apiKey := cfgMgr.GetCredentials().APIKey
 
 	// Convert http(s) -> ws(s)
 	wsURL := server
@@ -1396,26 +1751,53 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 	if strings.HasSuffix(wsURL, "/") {
 		wsURL = strings.TrimRight(wsURL, "/")
 	}
-	wsURL = wsURL + "/api/" + cfgManager.GetConfig().APIVersion + "/agents/ws"
+	wsURL = wsURL + "/api/" + cfgMgr.GetConfig().APIVersion + "/agents/ws"
 	header := http.Header{}
 	header.Set("X-API-ID", apiID)
 	header.Set("X-API-KEY", apiKey)
+	authHeaders := httpClient.AuthHeaders()
+	if err := authHeaders.Apply(context.Background(), header); err != nil {
+		return false, err
+	}
 
 	// SECURITY: Configure WebSocket dialer for insecure connections if needed
 	// WARNING: This exposes the agent to man-in-the-middle attacks!
-	dialer := websocket.DefaultDialer
-	if cfgManager.GetConfig().SkipSSLVerify || client.IsSkipSSLVerifyEnvSet() {
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: websocket.DefaultDialer.HandshakeTimeout,
+		NetDialContext:   utils.DialContext(30 * time.Second),
+	}
+	skipVerify := cfgMgr.GetConfig().SkipSSLVerify || client.IsSkipSSLVerifyEnvSet()
+	if skipVerify {
 		logger.Warn("TLS verification disabled for WebSocket")
 		// Operator-gated insecure TLS for lab/air-gapped deployments with self-signed certs.
-		dialer = &websocket.Dialer{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
+		dialer.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: true,
+		}
+	}
+	if cfg := cfgMgr.GetConfig(); cfg.RelayURL != "" {
+		tlsConfig, err := client.RelayTLSConfig(cfg, skipVerify)
+		if err != nil {
+			return false, err
+		}
+		dialer.TLSClientConfig = tlsConfig
+		if dial := client.RelayDialContext(cfg); dial != nil {
+			dialer.NetDialContext = dial
+			dialer.Proxy = nil
+		}
+	} else if cfg.ClientTLS != nil {
+		tlsConfig, err := client.ServerTLSConfig(cfg, skipVerify)
+		if err != nil {
+			return false, err
 		}
+		dialer.TLSClientConfig = tlsConfig
 	}
 
-	conn, _, err := dialer.Dial(wsURL, header)
+	conn, resp, err := dialer.Dial(wsURL, header)
 	if err != nil {
+		if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			authHeaders.Invalidate()
+		}
 		return false, err
 	}
 	// Reset reconnect backoff now that the session is live. Without this, a
@@ -1434,9 +1816,12 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 		}
 	}()
 
+	// Keepalive timings come from config.yml until the server's handshake says otherwise
+	keepalive := newWSKeepalive(cfgMgr.GetConfig())
+
 	// ping loop - now with cancellation support
 	go func() {
-		t := time.NewTicker(30 * time.Second)
+		t := time.NewTimer(keepalive.pingInterval())
 		defer t.Stop()
 		for {
 			select {
@@ -1446,118 +1831,74 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second)); err != nil {
 					return // Connection closed, exit goroutine
 				}
+				t.Reset(keepalive.pingInterval())
 			}
 		}
 	}()
 
 	// Set read deadlines and extend them on pong frames to avoid idle timeouts
-	_ = conn.SetReadDeadline(time.Now().Add(90 * time.Second))
+	_ = conn.SetReadDeadline(time.Now().Add(keepalive.readTimeout()))
 	conn.SetPongHandler(func(string) error {
-		return conn.SetReadDeadline(time.Now().Add(90 * time.Second))
+		return conn.SetReadDeadline(time.Now().Add(keepalive.readTimeout()))
 	})
 
 	// SECURITY: Limit WebSocket message size to prevent DoS attacks (64KB max)
 	conn.SetReadLimit(64 * 1024)
 
-	logger.WithField("url", logutil.Sanitize(wsURL)).Info("WebSocket connected")
+	if profile != nil {
+		logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
+			"url":    wsURL,
+			"server": profile.name,
+		})).Info("WebSocket connected")
+		wsConnectsTotal.Inc(profile.name)
+		profile.setConn(conn)
+		defer profile.setConn(nil)
+	} else {
+		logger.WithField("url", logutil.Sanitize(wsURL)).Info("WebSocket connected")
+		wsConnectsTotal.Inc("patchmon_server")
+		recordWebSocketState(true)
+		defer recordWebSocketState(false)
+
+		// Reconcile integration statuses the server may have missed while the
+		// link was down; failures are retried by the client
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			if err := apiClient().ResendIntegrationStatuses(ctx); err != nil {
+				logger.WithError(err).Debug("Failed to reconcile integration statuses")
+			}
+		}()
 
-	// Store connection globally for SSH proxy handlers
-	globalWsConnMu.Lock()
-	globalWsConn = conn
-	globalWsConnMu.Unlock()
-	defer func() {
+		// Store connection globally for SSH proxy handlers
 		globalWsConnMu.Lock()
-		globalWsConn = nil
+		globalWsConn = conn
 		globalWsConnMu.Unlock()
-	}()
-
-	// Create a goroutine to send Docker events through WebSocket - with cancellation support
-	go func() {
-		// OPTIMIZATION: Add a ticker to prevent goroutine buildup
-		ticker := time.NewTicker(1 * time.Minute)
-		defer ticker.Stop()
+		defer func() {
+			globalWsConnMu.Lock()
+			globalWsConn = nil
+			globalWsConnMu.Unlock()
+		}()
 
-		for {
-			select {
-			case <-done:
-				return
-			case <-ticker.C:
-				// Periodic health check
-				continue
-			case event, ok := <-dockerEvents:
-				if !ok {
-					return // Channel closed
-				}
-				if dockerEvent, ok := event.(models.DockerStatusEvent); ok {
-					eventJSON, err := json.Marshal(map[string]interface{}{
-						"type":         "docker_status",
-						"event":        dockerEvent,
-						"container_id": dockerEvent.ContainerID,
-						"name":         dockerEvent.Name,
-						"status":       dockerEvent.Status,
-						"timestamp":    dockerEvent.Timestamp,
-					})
-					if err != nil {
-						logger.WithError(err).Warn("Failed to marshal Docker event")
-						continue
-					}
+		go forwardDockerEvents(conn, done, dockerEvents)
+		go forwardComplianceProgress(conn, done)
+	}
 
-					if err := writeWebSocketTextMessage(conn, eventJSON); err != nil {
-						logger.WithError(err).Debug("Failed to send Docker event via WebSocket")
-						return
-					}
-				}
+	// Set while the steps of a batch message are being parsed
+	var batch *commandBatch
+	for {
+		var data []byte
+		if batch.parsing() {
+			data = batch.nextStep()
+		} else {
+			if batch != nil {
+				batch.start(conn, out)
+				batch = nil
 			}
-		}
-	}()
-
-	// Create a goroutine to send compliance scan progress updates through WebSocket
-	go func() {
-		// OPTIMIZATION: Add a ticker to prevent goroutine buildup
-		ticker := time.NewTicker(1 * time.Minute)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-done:
-				return
-			case <-ticker.C:
-				// Periodic health check
-				continue
-			case progress, ok := <-complianceProgressChan:
-				if !ok {
-					return // Channel closed
-				}
-				progressJSON, err := json.Marshal(map[string]interface{}{
-					"type":         "compliance_scan_progress",
-					"phase":        progress.Phase,
-					"profile_name": progress.ProfileName,
-					"message":      progress.Message,
-					"progress":     progress.Progress,
-					"error":        progress.Error,
-					"timestamp":    time.Now().Format(time.RFC3339),
-				})
-				if err != nil {
-					logger.WithError(err).Warn("Failed to marshal compliance progress event")
-					continue
-				}
-
-				if err := writeWebSocketTextMessage(conn, progressJSON); err != nil {
-					logger.WithError(err).Debug("Failed to send compliance progress via WebSocket")
-					return
-				}
-				logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
-					"phase":   progress.Phase,
-					"message": progress.Message,
-				})).Debug("Sent compliance progress update via WebSocket")
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return connected, err
 			}
-		}
-	}()
-
-	for {
-		_, data, err := conn.ReadMessage()
-		if err != nil {
-			return connected, err
+			data = msg
 		}
 		var payload struct {
 			Type                      string                 `json:"type"`
@@ -1565,6 +1906,9 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 			ComplianceScanInterval    int                    `json:"compliance_scan_interval"`
 			PackageCacheRefreshMode   string                 `json:"package_cache_refresh_mode"`
 			PackageCacheRefreshMaxAge int                    `json:"package_cache_refresh_max_age"`
+			DockerBenchImage          string                 `json:"docker_bench_image"`       // For settings_update: image ref, optionally @sha256 pinned
+			ComplianceRoles           []string               `json:"compliance_roles"`         // For settings_update: host roles
+			ComplianceRoleProfiles    map[string][]string    `json:"compliance_role_profiles"` // For settings_update: role -> compliance profiles
 			Version                   string                 `json:"version"`
 			Force                     bool                   `json:"force"`
 			Message                   string                 `json:"message"`
@@ -1578,7 +1922,7 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 			DockerBenchEnabled        *bool                  `json:"docker_bench_enabled"`   // For compliance_scan: per-host toggle
 			RuleID                    string                 `json:"rule_id"`                // For remediate_rule: specific rule to remediate
 			ImageName                 string                 `json:"image_name"`             // For docker_image_scan: Docker image to scan
-			ContainerName             string                 `json:"container_name"`         // For docker_image_scan: container to scan
+			ContainerName             string                 `json:"container_name"`         // For docker_image_scan and docker_update_container
 			ScanAllImages             bool                   `json:"scan_all_images"`        // For docker_image_scan: scan all images
 			OnDemandOnly              bool                   `json:"on_demand_only"`         // For set_compliance_on_demand_only (legacy)
 			Mode                      string                 `json:"mode"`                   // For set_compliance_mode: "disabled", "on-demand", or "enabled"
@@ -1600,32 +1944,160 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 			PatchType    string   `json:"patch_type"`
 			PackageName  string   `json:"package_name"`
 			PackageNames []string `json:"package_names"`
-			DryRun       bool     `json:"dry_run"`
+			DryRun       bool     `json:"dry_run"`  // For run_patch, package_update and docker_prune
+			All          bool     `json:"all"`      // For package_update: every available update
+			Sections     []string `json:"sections"` // For report_now
+			// hardware_inventory fields
+			TimeoutSeconds int `json:"timeout_seconds"`
+			// docker_prune fields
+			OlderThanDays int `json:"older_than_days"`
+			// pause fields
+			DurationSeconds int    `json:"duration_seconds"`
+			Reason          string `json:"reason"`
+			// connected handshake fields (seconds)
+			PingInterval int `json:"ping_interval"`
+			ReadTimeout  int `json:"read_timeout"`
+			SlowStart    int `json:"slow_start"` // window for a pending initial report
+			// Newest payload schema the server accepts (connected message)
+			SchemaVersion int `json:"schema_version"`
+			// secrets_update fields: values are sealed to the agent public key
+			Secrets map[string]string `json:"secrets"`
+			Remove  []string          `json:"remove"`
+			// Correlation ID echoed in command_ack / command_nack / command_result
+			CommandID string `json:"command_id"`
+			JobID     string `json:"job_id"` // For job_status
+			// batch fields
+			Commands        []json.RawMessage `json:"commands"`
+			ContinueOnError bool              `json:"continue_on_error"`
 		}
 		if err := json.Unmarshal(data, &payload); err != nil {
 			logger.WithError(err).WithField("message_bytes", len(data)).Warn("Failed to parse WebSocket message")
 			continue
 		}
 		logger.WithField("type", logutil.Sanitize(payload.Type)).Debug("Parsed WebSocket message type")
+		// Batch steps are collected and only run once the whole batch is valid
+		queue := func(m wsMsg) {
+			m.commandID = payload.CommandID
+			m.server = profile
+			if batch != nil {
+				batch.add(m)
+				return
+			}
+			jobs.enqueue(&m)
+			out <- m
+		}
+		reject := func(reason, detail string) {
+			if batch != nil {
+				batch.reject(payload.Type, detail)
+				return
+			}
+			nackCommand(conn, payload.Type, payload.CommandID, reason, detail)
+		}
+		if batch != nil && !batch.check(payload.Type) {
+			continue
+		}
+		if profile != nil {
+			if reason := profile.refusal(payload.Type, payload.Sections); reason != "" {
+				logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
+					"server": profile.name,
+					"type":   payload.Type,
+				})).Warn("Refusing command from server")
+				reject(models.NackRefused, reason)
+				continue
+			}
+		}
 		switch payload.Type {
+		case "connected":
+			if payload.PingInterval > 0 || payload.ReadTimeout > 0 {
+				keepalive.apply(payload.PingInterval, payload.ReadTimeout)
+				_ = conn.SetReadDeadline(time.Now().Add(keepalive.readTimeout()))
+				logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
+					"ping_interval": keepalive.pingInterval().String(),
+					"read_timeout":  keepalive.readTimeout().String(),
+				})).Info("Using WebSocket keepalive timings from server")
+			}
+			if profile != nil {
+				profile.client.SetSchemaVersion(payload.SchemaVersion)
+				continue
+			}
+			applySlowStart(payload.SlowStart)
+			rememberServerSchema(payload.SchemaVersion)
+		case "batch":
+			b, err := newCommandBatch(payload.CommandID, payload.Commands, payload.ContinueOnError)
+			if err != nil {
+				reject(models.NackInvalid, err.Error())
+				continue
+			}
+			b.server = profile
+			logger.WithField("steps", len(payload.Commands)).Info("batch received")
+			batch = b
+		case "job_status":
+			sendJobStatus(conn, profile, payload.CommandID, payload.JobID)
+		case "debug_dump":
+			// Answered from the read loop so it works even when the command
+			// loop is stuck
+			logger.Info("debug_dump received")
+			ackCommand(conn, payload.Type, payload.CommandID)
+			go handleDebugDumpCommand(conn, payload.CommandID)
+		case "job_cancel":
+			if err := jobs.cancelFor(profile, payload.JobID); err != nil {
+				logger.WithError(err).Warn("job_cancel rejected")
+				reject(models.NackInvalid, err.Error())
+				continue
+			}
+			logger.WithField("job_id", logutil.Sanitize(payload.JobID)).Info("Job cancellation requested")
+			ackCommand(conn, payload.Type, payload.CommandID)
 		case "settings_update":
 			logger.WithField("interval", payload.UpdateInterval).Info("settings_update received")
-			out <- wsMsg{kind: "settings_update", interval: payload.UpdateInterval, complianceScanInterval: payload.ComplianceScanInterval, packageCacheRefreshMode: payload.PackageCacheRefreshMode, packageCacheRefreshMaxAge: payload.PackageCacheRefreshMaxAge}
+			queue(wsMsg{kind: "settings_update", interval: payload.UpdateInterval, complianceScanInterval: payload.ComplianceScanInterval, packageCacheRefreshMode: payload.PackageCacheRefreshMode, packageCacheRefreshMaxAge: payload.PackageCacheRefreshMaxAge, dockerBenchImage: payload.DockerBenchImage, complianceRoles: payload.ComplianceRoles, complianceRoleProfiles: payload.ComplianceRoleProfiles})
+		case "pause":
+			if payload.DurationSeconds <= 0 {
+				logger.Warn("pause missing duration_seconds")
+				reject(models.NackInvalid, "missing duration_seconds")
+				continue
+			}
+			logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
+				"duration_seconds": payload.DurationSeconds,
+				"reason":           payload.Reason,
+			})).Info("pause received")
+			queue(wsMsg{kind: "pause", pauseDuration: time.Duration(payload.DurationSeconds) * time.Second, pauseReason: payload.Reason})
+		case "secrets_update":
+			if payload.Integration == "" {
+				logger.Warn("secrets_update missing integration")
+				reject(models.NackInvalid, "missing integration")
+				continue
+			}
+			logger.WithField("integration", logutil.Sanitize(payload.Integration)).Info("secrets_update received")
+			queue(wsMsg{kind: "secrets_update", integrationName: payload.Integration, secrets: payload.Secrets, secretsRemove: payload.Remove})
+		case "resume":
+			logger.Info("resume received")
+			queue(wsMsg{kind: "resume"})
 		case "report_now":
-			logger.Info("report_now received")
-			out <- wsMsg{kind: "report_now"}
+			sections, err := parseReportSections(payload.Sections)
+			if err != nil {
+				logger.WithError(err).Warn("Invalid sections in report_now")
+				reject(models.NackInvalid, err.Error())
+				continue
+			}
+			logger.WithField("sections", strings.Join(sections, ",")).Info("report_now received")
+			queue(wsMsg{kind: "report_now", reportSections: sections})
 		case "update_agent":
 			logger.Info("update_agent received")
-			out <- wsMsg{kind: "update_agent"}
+			queue(wsMsg{kind: "update_agent"})
 		case "refresh_integration_status":
 			logger.Info("refresh_integration_status received")
-			out <- wsMsg{kind: "refresh_integration_status"}
+			queue(wsMsg{kind: "refresh_integration_status"})
 		case "docker_inventory_refresh":
 			logger.Info("docker_inventory_refresh received")
-			out <- wsMsg{kind: "docker_inventory_refresh"}
+			queue(wsMsg{kind: "docker_inventory_refresh"})
+		case "hardware_inventory":
+			budget := inventoryBudget(payload.TimeoutSeconds)
+			logger.WithField("budget", budget.String()).Info("hardware_inventory received")
+			queue(wsMsg{kind: "hardware_inventory", inventoryBudget: budget})
 		case "run_patch":
 			if payload.PatchRunID == "" {
 				logger.Warn("run_patch missing patch_run_id")
+				reject(models.NackInvalid, "missing patch_run_id")
 				continue
 			}
 			patchType := payload.PatchType
@@ -1634,6 +2106,7 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 			}
 			if patchType != "patch_all" && patchType != "patch_package" {
 				logger.WithField("patch_type", logutil.Sanitize(patchType)).Warn("Invalid patch_type in run_patch")
+				reject(models.NackInvalid, "invalid patch_type")
 				continue
 			}
 			var packageNames []string
@@ -1647,6 +2120,7 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 				}
 				if len(packageNames) == 0 {
 					logger.Warn("run_patch package_names had no valid names")
+					reject(models.NackInvalid, "no valid package names")
 					continue
 				}
 			} else if payload.PackageName != "" {
@@ -1654,10 +2128,12 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 					packageNames = []string{payload.PackageName}
 				} else {
 					logger.WithError(fmt.Errorf("invalid package name")).WithField("package_name", logutil.Sanitize(payload.PackageName)).Warn("Invalid package_name in run_patch")
+					reject(models.NackInvalid, "invalid package_name")
 					continue
 				}
 			} else if patchType == "patch_package" {
 				logger.Warn("run_patch patch_package requires package_name or package_names")
+				reject(models.NackInvalid, "patch_package requires package_name or package_names")
 				continue
 			}
 			logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
@@ -1666,38 +2142,39 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 				"package_names": packageNames,
 				"dry_run":       payload.DryRun,
 			})).Info("run_patch received")
-			out <- wsMsg{
+			queue(wsMsg{
 				kind:         "run_patch",
 				patchRunID:   payload.PatchRunID,
 				patchType:    patchType,
 				packageNames: packageNames,
 				dryRun:       payload.DryRun,
-			}
+			})
 		case "update_notification":
 			logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
 				"version": payload.Version,
 				"force":   payload.Force,
 				"message": payload.Message,
 			})).Info("update_notification received")
-			out <- wsMsg{
+			queue(wsMsg{
 				kind:    "update_notification",
 				version: payload.Version,
 				force:   payload.Force,
-			}
+			})
 		case "integration_toggle":
 			logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
 				"integration": payload.Integration,
 				"enabled":     payload.Enabled,
 			})).Info("integration_toggle received")
-			out <- wsMsg{
+			queue(wsMsg{
 				kind:               "integration_toggle",
 				integrationName:    payload.Integration,
 				integrationEnabled: payload.Enabled,
-			}
+			})
 		case "compliance_scan":
 			// Validate profile ID to prevent command injection
 			if err := validateProfileID(payload.ProfileID); err != nil {
 				logger.WithError(err).WithField("profile_id", logutil.Sanitize(payload.ProfileID)).Warn("Invalid profile ID in compliance_scan message")
+				reject(models.NackInvalid, err.Error())
 				continue
 			}
 			profileType := payload.ProfileType
@@ -1709,7 +2186,7 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 				"profile_id":         payload.ProfileID,
 				"enable_remediation": payload.EnableRemediation,
 			})).Info("compliance_scan received")
-			out <- wsMsg{
+			queue(wsMsg{
 				kind:                 "compliance_scan",
 				profileType:          profileType,
 				profileID:            payload.ProfileID,
@@ -1717,40 +2194,52 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 				fetchRemoteResources: payload.FetchRemoteResources,
 				openscapEnabled:      payload.OpenSCAPEnabled,
 				dockerBenchEnabled:   payload.DockerBenchEnabled,
+			})
+		case "compliance_ckl_export":
+			if err := validateProfileID(payload.ProfileID); err != nil {
+				logger.WithError(err).WithField("profile_id", logutil.Sanitize(payload.ProfileID)).Warn("Invalid profile ID in compliance_ckl_export message")
+				reject(models.NackInvalid, err.Error())
+				continue
 			}
+			logger.WithField("profile_id", logutil.Sanitize(payload.ProfileID)).Info("compliance_ckl_export received")
+			queue(wsMsg{kind: "compliance_ckl_export", profileID: payload.ProfileID})
 		case "compliance_scan_cancel":
 			logger.Info("compliance_scan_cancel received")
-			out <- wsMsg{kind: "compliance_scan_cancel"}
+			queue(wsMsg{kind: "compliance_scan_cancel"})
 		case "patch_run_stop":
 			if payload.PatchRunID == "" {
 				logger.Warn("patch_run_stop missing patch_run_id")
+				reject(models.NackInvalid, "missing patch_run_id")
 				continue
 			}
 			logger.WithField("patch_run_id", logutil.Sanitize(payload.PatchRunID)).Info("patch_run_stop received")
-			out <- wsMsg{kind: "patch_run_stop", patchRunID: payload.PatchRunID}
+			queue(wsMsg{kind: "patch_run_stop", patchRunID: payload.PatchRunID})
 		case "upgrade_ssg":
 			logger.WithField("version", payload.Version).Info("upgrade_ssg received from WebSocket")
-			out <- wsMsg{kind: "upgrade_ssg", version: payload.Version}
+			queue(wsMsg{kind: "upgrade_ssg", version: payload.Version})
 			logger.Info("upgrade_ssg sent to message channel")
 		case "install_scanner":
 			logger.Info("install_scanner received from WebSocket")
-			out <- wsMsg{kind: "install_scanner"}
+			queue(wsMsg{kind: "install_scanner"})
 		case "remediate_rule":
 			// Validate rule ID to prevent command injection
 			if err := validateRuleID(payload.RuleID); err != nil {
 				logger.WithError(err).WithField("rule_id", logutil.Sanitize(payload.RuleID)).Warn("Invalid rule ID in remediate_rule message")
+				reject(models.NackInvalid, err.Error())
 				continue
 			}
 			logger.WithField("rule_id", logutil.Sanitize(payload.RuleID)).Info("remediate_rule received")
-			out <- wsMsg{kind: "remediate_rule", ruleID: payload.RuleID}
+			queue(wsMsg{kind: "remediate_rule", ruleID: payload.RuleID})
 		case "docker_image_scan":
 			// Validate Docker image and container names to prevent command injection
 			if err := validateDockerImageName(payload.ImageName); err != nil {
 				logger.WithError(err).WithField("image_name", logutil.Sanitize(payload.ImageName)).Warn("Invalid image name in docker_image_scan message")
+				reject(models.NackInvalid, err.Error())
 				continue
 			}
 			if err := validateDockerContainerName(payload.ContainerName); err != nil {
 				logger.WithError(err).WithField("container_name", logutil.Sanitize(payload.ContainerName)).Warn("Invalid container name in docker_image_scan message")
+				reject(models.NackInvalid, err.Error())
 				continue
 			}
 			logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
@@ -1758,27 +2247,78 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 				"container_name":  payload.ContainerName,
 				"scan_all_images": payload.ScanAllImages,
 			})).Info("docker_image_scan received")
-			out <- wsMsg{
+			queue(wsMsg{
 				kind:          "docker_image_scan",
 				imageName:     payload.ImageName,
 				containerName: payload.ContainerName,
 				scanAllImages: payload.ScanAllImages,
+				force:         payload.Force,
+			})
+		case "docker_update_container":
+			if payload.ContainerName == "" {
+				reject(models.NackInvalid, "container_name is required")
+				continue
+			}
+			if err := validateDockerContainerName(payload.ContainerName); err != nil {
+				logger.WithError(err).WithField("container_name", logutil.Sanitize(payload.ContainerName)).Warn("Invalid container name in docker_update_container message")
+				reject(models.NackInvalid, err.Error())
+				continue
+			}
+			logger.WithField("container_name", logutil.Sanitize(payload.ContainerName)).Info("docker_update_container received")
+			queue(wsMsg{kind: "docker_update_container", containerName: payload.ContainerName})
+		case "docker_prune":
+			if payload.OlderThanDays < 0 || payload.OlderThanDays > maxPruneAgeDays {
+				reject(models.NackInvalid, fmt.Sprintf("older_than_days must be between 0 and %d", maxPruneAgeDays))
+				continue
+			}
+			logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
+				"older_than_days": payload.OlderThanDays,
+				"dry_run":         payload.DryRun,
+			})).Info("docker_prune received")
+			queue(wsMsg{kind: "docker_prune", pruneAgeDays: payload.OlderThanDays, dryRun: payload.DryRun})
+		case "package_update":
+			if len(payload.PackageNames) == 0 && !payload.All {
+				reject(models.NackInvalid, "package_names or all is required")
+				continue
+			}
+			if len(payload.PackageNames) > 0 && payload.All {
+				reject(models.NackInvalid, "package_names and all are exclusive")
+				continue
+			}
+			invalid := ""
+			for _, n := range payload.PackageNames {
+				if !validAptPackagePattern.MatchString(n) {
+					invalid = n
+					break
+				}
+			}
+			if invalid != "" {
+				logger.WithField("package_name", logutil.Sanitize(invalid)).Warn("Invalid package name in package_update")
+				reject(models.NackInvalid, "invalid package name")
+				continue
 			}
+			logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
+				"package_names": payload.PackageNames,
+				"all":           payload.All,
+				"dry_run":       payload.DryRun,
+			})).Info("package_update received")
+			queue(wsMsg{kind: "package_update", packageNames: payload.PackageNames, dryRun: payload.DryRun})
 		case "set_compliance_mode":
 			logger.WithField("mode", logutil.Sanitize(payload.Mode)).Info("set_compliance_mode received")
 			// Validate mode
 			validModes := map[string]bool{"disabled": true, "on-demand": true, "enabled": true}
 			if !validModes[payload.Mode] {
 				logger.WithField("mode", logutil.Sanitize(payload.Mode)).Warn("Invalid compliance mode, ignoring")
+				reject(models.NackInvalid, "invalid compliance mode")
 				continue
 			}
-			out <- wsMsg{
+			queue(wsMsg{
 				kind:           "set_compliance_mode",
 				complianceMode: payload.Mode,
-			}
+			})
 		case "apply_config":
 			logger.Info("apply_config received")
-			out <- wsMsg{kind: "apply_config", applyConfig: payload.Config}
+			queue(wsMsg{kind: "apply_config", applyConfig: payload.Config})
 		case "set_compliance_on_demand_only":
 			// Legacy handler - convert to new format
 			logger.WithField("on_demand_only", payload.OnDemandOnly).Info("set_compliance_on_demand_only received (legacy)")
@@ -1786,10 +2326,10 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 			if payload.OnDemandOnly {
 				mode = "on-demand"
 			}
-			out <- wsMsg{
+			queue(wsMsg{
 				kind:           "set_compliance_mode",
 				complianceMode: mode,
-			}
+			})
 		case "ssh_proxy":
 			// Validate SSH proxy is enabled in config
 			if !cfgManager.IsIntegrationEnabled("ssh-proxy-enabled") {
@@ -1808,11 +2348,13 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 						"Note: This cannot be pushed from the server to the agent and should require you to manually do this for security reasons."
 					sendSSHProxyError(wsConn, payload.SessionID, errorMsg)
 				}
+				reject(models.NackRefused, "ssh-proxy-enabled is not set in config.yml")
 				continue
 			}
 			// Validate session ID
 			if payload.SessionID == "" {
 				logger.Warn("SSH proxy request missing session_id")
+				reject(models.NackInvalid, "missing session_id")
 				continue
 			}
 			// Validate host
@@ -1824,6 +2366,7 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 				if wsConn != nil {
 					sendSSHProxyError(wsConn, payload.SessionID, fmt.Sprintf("Invalid host: %v", err))
 				}
+				reject(models.NackInvalid, "invalid host: "+err.Error())
 				continue
 			}
 			// Validate port
@@ -1835,6 +2378,7 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 				if wsConn != nil {
 					sendSSHProxyError(wsConn, payload.SessionID, "Invalid port (must be 1-65535)")
 				}
+				reject(models.NackInvalid, "invalid port")
 				continue
 			}
 			logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
@@ -1843,7 +2387,7 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 				"port":       payload.Port,
 				"username":   payload.Username,
 			})).Info("ssh_proxy received")
-			out <- wsMsg{
+			queue(wsMsg{
 				kind:               "ssh_proxy",
 				sshProxySessionID:  payload.SessionID,
 				sshProxyHost:       payload.Host,
@@ -1855,37 +2399,37 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 				sshProxyTerminal:   payload.Terminal,
 				sshProxyCols:       payload.Cols,
 				sshProxyRows:       payload.Rows,
-			}
+			})
 		case "ssh_proxy_input":
 			if payload.SessionID == "" {
 				logger.Warn("ssh_proxy_input missing session_id")
 				continue
 			}
-			out <- wsMsg{
+			queue(wsMsg{
 				kind:              "ssh_proxy_input",
 				sshProxySessionID: payload.SessionID,
 				sshProxyData:      payload.Data,
-			}
+			})
 		case "ssh_proxy_resize":
 			if payload.SessionID == "" {
 				logger.Warn("ssh_proxy_resize missing session_id")
 				continue
 			}
-			out <- wsMsg{
+			queue(wsMsg{
 				kind:              "ssh_proxy_resize",
 				sshProxySessionID: payload.SessionID,
 				sshProxyCols:      payload.Cols,
 				sshProxyRows:      payload.Rows,
-			}
+			})
 		case "ssh_proxy_disconnect":
 			if payload.SessionID == "" {
 				logger.Warn("ssh_proxy_disconnect missing session_id")
 				continue
 			}
-			out <- wsMsg{
+			queue(wsMsg{
 				kind:              "ssh_proxy_disconnect",
 				sshProxySessionID: payload.SessionID,
-			}
+			})
 		case "rdp_proxy":
 			if !cfgManager.IsIntegrationEnabled("rdp-proxy-enabled") {
 				logger.Warn("RDP proxy requested but not enabled in config.yml")
@@ -1902,10 +2446,12 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 						"Note: This cannot be pushed from the server and requires manual configuration for security."
 					sendRDPProxyError(wsConn, payload.SessionID, errorMsg)
 				}
+				reject(models.NackRefused, "rdp-proxy-enabled is not set in config.yml")
 				continue
 			}
 			if payload.SessionID == "" {
 				logger.Warn("rdp_proxy request missing session_id")
+				reject(models.NackInvalid, "missing session_id")
 				continue
 			}
 			rdpHost := payload.Host
@@ -1920,6 +2466,7 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 				if wsConn != nil {
 					sendRDPProxyError(wsConn, payload.SessionID, fmt.Sprintf("Invalid host: %v", err))
 				}
+				reject(models.NackInvalid, "invalid host: "+err.Error())
 				continue
 			}
 			port := payload.Port
@@ -1931,39 +2478,125 @@ func connectOnce(out chan<- wsMsg, dockerEvents <-chan interface{}, backoff *tim
 				"host":       rdpHost,
 				"port":       port,
 			})).Info("rdp_proxy received")
-			out <- wsMsg{
+			queue(wsMsg{
 				kind:              "rdp_proxy",
 				rdpProxySessionID: payload.SessionID,
 				rdpProxyHost:      rdpHost,
 				rdpProxyPort:      port,
-			}
+			})
 		case "rdp_proxy_input":
 			if payload.SessionID == "" {
 				logger.Warn("rdp_proxy_input missing session_id")
 				continue
 			}
-			out <- wsMsg{
+			queue(wsMsg{
 				kind:              "rdp_proxy_input",
 				rdpProxySessionID: payload.SessionID,
 				rdpProxyData:      payload.Data,
-			}
+			})
 		case "rdp_proxy_disconnect":
 			if payload.SessionID == "" {
 				logger.Warn("rdp_proxy_disconnect missing session_id")
 				continue
 			}
-			out <- wsMsg{
+			queue(wsMsg{
 				kind:              "rdp_proxy_disconnect",
 				rdpProxySessionID: payload.SessionID,
-			}
+			})
 		default:
-			if payload.Type != "" && payload.Type != "connected" {
+			if payload.Type != "" {
 				logger.WithField("type", logutil.Sanitize(payload.Type)).Warn("Unknown WebSocket message type")
+				reject(models.NackUnknown, "unknown command type")
 			}
 		}
 	}
 }
 
+// forwardDockerEvents sends Docker container events over patchmon_server's
+// WebSocket until done is closed
+func forwardDockerEvents(conn *websocket.Conn, done <-chan struct{}, dockerEvents <-chan interface{}) {
+	// OPTIMIZATION: Add a ticker to prevent goroutine buildup
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			// Periodic health check
+			continue
+		case event, ok := <-dockerEvents:
+			if !ok {
+				return // Channel closed
+			}
+			if dockerEvent, ok := event.(models.DockerStatusEvent); ok {
+				eventJSON, err := wsPayload(map[string]interface{}{
+					"type":         "docker_status",
+					"event":        dockerEvent,
+					"container_id": dockerEvent.ContainerID,
+					"name":         dockerEvent.Name,
+					"status":       dockerEvent.Status,
+					"timestamp":    dockerEvent.Timestamp,
+				})
+				if err != nil {
+					logger.WithError(err).Warn("Dropping Docker event")
+					continue
+				}
+
+				if err := writeWebSocketTextMessage(conn, eventJSON); err != nil {
+					logger.WithError(err).Debug("Failed to send Docker event via WebSocket")
+					return
+				}
+			}
+		}
+	}
+}
+
+// forwardComplianceProgress sends compliance scan progress over
+// patchmon_server's WebSocket until done is closed
+func forwardComplianceProgress(conn *websocket.Conn, done <-chan struct{}) {
+	// OPTIMIZATION: Add a ticker to prevent goroutine buildup
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			// Periodic health check
+			continue
+		case progress, ok := <-complianceProgressChan:
+			if !ok {
+				return // Channel closed
+			}
+			progressJSON, err := wsPayload(map[string]interface{}{
+				"type":         "compliance_scan_progress",
+				"phase":        progress.Phase,
+				"profile_name": progress.ProfileName,
+				"message":      progress.Message,
+				"progress":     progress.Progress,
+				"error":        progress.Error,
+				"timestamp":    time.Now().Format(time.RFC3339),
+			})
+			if err != nil {
+				logger.WithError(err).Warn("Dropping compliance progress event")
+				continue
+			}
+
+			if err := writeWebSocketTextMessage(conn, progressJSON); err != nil {
+				logger.WithError(err).Debug("Failed to send compliance progress via WebSocket")
+				return
+			}
+			logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
+				"phase":   progress.Phase,
+				"message": progress.Message,
+			})).Debug("Sent compliance progress update via WebSocket")
+		}
+	}
+}
+
 // dryRunOutputIndicatesError returns true if the output contains dependency or
 // resolution error messages. Used to distinguish "declined" (exit 1, success)
 // from actual dependency/validation failures (exit 1, failure).
@@ -2041,6 +2674,24 @@ func splitFreeBSDPatchTargets(packageNames []string) ([]string, bool) {
 	return filtered, includeBase
 }
 
+// sendPostPatchReport sends a report so the server sees the packages a patch
+// run changed, giving up after two minutes
+func sendPostPatchReport() {
+	logger.Info("Sending post-patch report to refresh package lists...")
+	reportDone := make(chan error, 1)
+	go func() { reportDone <- sendReport(false) }()
+	select {
+	case err := <-reportDone:
+		if err != nil {
+			logger.WithError(err).Warn("Post-patch report failed")
+		} else {
+			logger.Info("Post-patch report sent successfully")
+		}
+	case <-time.After(2 * time.Minute):
+		logger.Warn("Post-patch report timed out after 2 minutes; will retry on next scheduled report")
+	}
+}
+
 // runPatch runs package manager update and upgrade (patch_all) or install (patch_package).
 // Supports apt-get (Debian/Ubuntu), dnf, yum (RHEL-based), pkg (FreeBSD), pacman (Arch),
 // and windows (WinGet for applications + WUA COM API for OS updates).
@@ -2194,19 +2845,16 @@ func patchRunTrailer(wasStopped bool, stepErr error, dryRun bool) string {
 }
 
 // When dryRun is true, simulates and sends dry_run_completed instead of completed.
-func runPatch(patchRunID, patchType string, packageNames []string, dryRun bool) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+func runPatch(ctx context.Context, patchRunID, patchType string, packageNames []string, dryRun bool) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
 	defer cancel()
 
 	// Register cancel fn so the server can request an interrupt via "patch_run_stop".
 	patchRunCancels.Store(patchRunID, cancel)
 	defer patchRunCancels.Delete(patchRunID)
 
-	httpClient := client.New(cfgManager, logger)
-	packageMgr := packages.New(logger, packages.CacheRefreshConfig{
-		Mode:   cfgManager.GetPackageCacheRefreshMode(),
-		MaxAge: cfgManager.GetPackageCacheRefreshMaxAge(),
-	})
+	httpClient := commandClient(ctx)
+	packageMgr := packages.New(logger, packageCacheRefresh())
 	pkgManager := packageMgr.DetectPackageManager()
 
 	if pkgManager == "windows" {
@@ -2477,23 +3125,11 @@ func runPatch(patchRunID, patchType string, packageNames []string, dryRun bool)
 	// Post-patch inventory report: runs after success AND after user-triggered
 	// stop (a cancelled run may leave packages in a partially-changed state).
 	if !dryRun && (wasStopped || stepErr == nil) {
-		logger.Info("Sending post-patch report to refresh package lists...")
-		reportDone := make(chan error, 1)
-		go func() { reportDone <- sendReport(false) }()
-		select {
-		case err := <-reportDone:
-			if err != nil {
-				logger.WithError(err).Warn("Post-patch report failed")
-			} else {
-				logger.Info("Post-patch report sent successfully")
-			}
-		case <-time.After(2 * time.Minute):
-			logger.Warn("Post-patch report timed out after 2 minutes; will retry on next scheduled report")
-		}
+		sendPostPatchReport()
 	}
 
 	if wasStopped {
-		return fmt.Errorf("patch run stopped by user")
+		return fmt.Errorf("patch run stopped by user: %w", context.Canceled)
 	}
 	return nil
 }
@@ -2619,23 +3255,11 @@ func runPatchWindows(ctx context.Context, httpClient *client.Client, patchRunID,
 	}
 
 	if !dryRun {
-		logger.Info("Sending post-patch report to refresh package lists...")
-		reportDone := make(chan error, 1)
-		go func() { reportDone <- sendReport(false) }()
-		select {
-		case err := <-reportDone:
-			if err != nil {
-				logger.WithError(err).Warn("Post-patch report failed")
-			} else {
-				logger.Info("Post-patch report sent successfully")
-			}
-		case <-time.After(2 * time.Minute):
-			logger.Warn("Post-patch report timed out after 2 minutes; will retry on next scheduled report")
-		}
+		sendPostPatchReport()
 	}
 
 	if wasStopped {
-		return fmt.Errorf("patch run stopped by user")
+		return fmt.Errorf("patch run stopped by user: %w", context.Canceled)
 	}
 	return nil
 }
@@ -2740,6 +3364,16 @@ func applyConfig(cfg map[string]interface{}) error {
 	return restartService("", "")
 }
 
+// toolInstallFailure returns the component status and event message for a
+// failed EnsureInstalled. With auto_install_tools off the error already names
+// the packages to install, so it is passed through as "missing".
+func toolInstallFailure(err error, failedMsg string) (string, string) {
+	if missing, ok := compliance.AsToolsMissing(err); ok {
+		return "missing", missing.Error()
+	}
+	return "failed", fmt.Sprintf("%s: %s", failedMsg, err.Error())
+}
+
 // toggleIntegration toggles an integration on or off and restarts the service
 func toggleIntegration(integrationName string, enabled bool) error {
 	logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
@@ -2750,7 +3384,7 @@ func toggleIntegration(integrationName string, enabled bool) error {
 	// Handle compliance tools installation/removal
 	if integrationName == "compliance" {
 		// Create HTTP client for sending status updates
-		httpClient := client.New(cfgManager, logger)
+		httpClient := apiClient()
 		ctx := context.Background()
 
 		components := make(map[string]string)
@@ -2800,8 +3434,9 @@ func toggleIntegration(integrationName string, enabled bool) error {
 
 			if err := openscapScanner.EnsureInstalled(); err != nil {
 				logger.WithError(err).Warn("Failed to install OpenSCAP (will try again on next scan)")
-				components["openscap"] = "failed"
-				events[len(events)-1] = models.InstallEvent{Step: "install_openscap", Status: "failed", Message: fmt.Sprintf("OpenSCAP installation failed: %s", err.Error()), Timestamp: events[len(events)-1].Timestamp}
+				status, msg := toolInstallFailure(err, "OpenSCAP installation failed")
+				components["openscap"] = status
+				events[len(events)-1] = models.InstallEvent{Step: "install_openscap", Status: "failed", Message: msg, Timestamp: events[len(events)-1].Timestamp}
 			} else {
 				logger.Info("OpenSCAP installed successfully")
 				components["openscap"] = "ready"
@@ -2818,8 +3453,9 @@ func toggleIntegration(integrationName string, enabled bool) error {
 				if dockerBenchScanner.IsAvailable() {
 					if err := dockerBenchScanner.EnsureInstalled(); err != nil {
 						logger.WithError(err).Warn("Failed to pre-pull Docker Bench image (will pull on first scan)")
-						components["docker-bench"] = "failed"
-						events[len(events)-1] = models.InstallEvent{Step: "docker_bench", Status: "failed", Message: fmt.Sprintf("Docker Bench image pull failed: %s", err.Error()), Timestamp: events[len(events)-1].Timestamp}
+						status, msg := toolInstallFailure(err, "Docker Bench image pull failed")
+						components["docker-bench"] = status
+						events[len(events)-1] = models.InstallEvent{Step: "docker_bench", Status: "failed", Message: msg, Timestamp: events[len(events)-1].Timestamp}
 					} else {
 						logger.Info("Docker Bench image pulled successfully")
 						components["docker-bench"] = "ready"
@@ -2834,7 +3470,10 @@ func toggleIntegration(integrationName string, enabled bool) error {
 				if !oscapDockerScanner.IsAvailable() {
 					if err := oscapDockerScanner.EnsureInstalled(); err != nil {
 						errMsg := err.Error()
-						if strings.Contains(errMsg, "not available") || strings.Contains(errMsg, "not supported") {
+						if _, ok := compliance.AsToolsMissing(err); ok {
+							logger.WithError(err).Warn("oscap-docker missing")
+							components["oscap-docker"] = "missing"
+						} else if strings.Contains(errMsg, "not available") || strings.Contains(errMsg, "not supported") {
 							logger.WithError(err).Info("oscap-docker not available on this platform")
 							components["oscap-docker"] = "unavailable"
 						} else {
@@ -2855,16 +3494,26 @@ func toggleIntegration(integrationName string, enabled bool) error {
 
 			// Determine overall status
 			allReady := true
-			for _, status := range components {
-				if status == "failed" {
+			var missing []string
+			for name, status := range components {
+				switch status {
+				case "failed":
 					allReady = false
-					break
+				case "missing":
+					allReady = false
+					missing = append(missing, name)
 				}
 			}
-			if allReady {
+			switch {
+			case allReady:
 				overallStatus = "ready"
 				statusMessage = "Compliance tools installed and ready"
-			} else {
+			case len(missing) > 0:
+				// auto_install_tools is off; the install events carry the package names
+				sort.Strings(missing)
+				overallStatus = "partial"
+				statusMessage = fmt.Sprintf("Tools missing, manual install required: %s", strings.Join(missing, ", "))
+			default:
 				overallStatus = "partial"
 				statusMessage = "Some compliance tools failed to install"
 			}
@@ -2918,29 +3567,37 @@ func toggleIntegration(integrationName string, enabled bool) error {
 			logger.WithError(err).Warn("Failed to send initial compliance removal status")
 		}
 
-		// Remove OpenSCAP packages
-		openscapScanner := compliance.NewOpenSCAPScanner(logger)
-		if err := openscapScanner.Cleanup(); err != nil {
-			logger.WithError(err).Warn("Failed to remove OpenSCAP packages")
-			components["openscap"] = "cleanup-failed"
+		overallStatus = "disabled"
+		statusMessage = "Compliance disabled and tools removed"
+		if !cfgManager.GetAutoInstallTools() {
+			// Packages are managed by the administrator; removing them is as
+			// much a change as installing them
+			logger.Info("auto_install_tools is off, leaving compliance tools installed")
+			components["openscap"] = "left-installed"
+			components["docker-bench"] = "left-installed"
+			statusMessage = "Compliance disabled; tools left installed (auto_install_tools is off)"
 		} else {
-			logger.Info("OpenSCAP packages removed successfully")
-			components["openscap"] = "removed"
-		}
-
-		// Clean up Docker Bench images
-		dockerBenchScanner := compliance.NewDockerBenchScanner(logger)
-		if dockerBenchScanner.IsAvailable() {
-			if err := dockerBenchScanner.Cleanup(); err != nil {
-				logger.WithError(err).Debug("Failed to cleanup Docker Bench image")
-				components["docker-bench"] = "cleanup-failed"
+			// Remove OpenSCAP packages
+			openscapScanner := compliance.NewOpenSCAPScanner(logger)
+			if err := openscapScanner.Cleanup(); err != nil {
+				logger.WithError(err).Warn("Failed to remove OpenSCAP packages")
+				components["openscap"] = "cleanup-failed"
 			} else {
-				components["docker-bench"] = "removed"
+				logger.Info("OpenSCAP packages removed successfully")
+				components["openscap"] = "removed"
 			}
-		}
 
-		overallStatus = "disabled"
-		statusMessage = "Compliance disabled and tools removed"
+			// Clean up Docker Bench images
+			dockerBenchScanner := compliance.NewDockerBenchScanner(logger)
+			if dockerBenchScanner.IsAvailable() {
+				if err := dockerBenchScanner.Cleanup(); err != nil {
+					logger.WithError(err).Debug("Failed to cleanup Docker Bench image")
+					components["docker-bench"] = "cleanup-failed"
+				} else {
+					components["docker-bench"] = "removed"
+				}
+			}
+		}
 		logger.Info("Compliance cleanup complete")
 
 		// Send final status update for disable
@@ -2959,7 +3616,7 @@ func toggleIntegration(integrationName string, enabled bool) error {
 	if integrationName == "docker" && enabled {
 		if cfgManager.IsIntegrationEnabled("compliance") {
 			logger.Info("Docker enabled with Compliance already active - setting up Docker scanning tools...")
-			httpClient := client.New(cfgManager, logger)
+			httpClient := apiClient()
 			ctx := context.Background()
 
 			openscapScanner := compliance.NewOpenSCAPScanner(logger)
@@ -3032,6 +3689,12 @@ func toggleIntegration(integrationName string, enabled bool) error {
 
 	logger.Info("Config updated, restarting patchmon-agent service...")
 
+	// FreeBSD / pfSense: rc.d, through the same detached helper an agent
+	// update uses; it exits the process. So does a profile's serve.
+	if runtime.GOOS == "freebsd" || cfgManager.Profile() != "" {
+		return restartService("", "")
+	}
+
 	// Restart the service to apply changes (supports systemd and OpenRC)
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
@@ -3048,66 +3711,6 @@ func toggleIntegration(integrationName string, enabled bool) error {
 		logger.WithField("output", logutil.Sanitize(string(output))).Debug("Service restart command completed")
 		logger.Info("Service restarted successfully")
 		return nil
-	} else if runtime.GOOS == "freebsd" {
-		// FreeBSD / pfSense: use rc.d helper script (same approach as version_update.go)
-		logger.Debug("Detected FreeBSD, scheduling service restart via helper script")
-		if err := os.MkdirAll("/etc/patchmon", 0700); err != nil {
-			logger.WithError(err).Warn("Failed to create /etc/patchmon directory, will try anyway")
-		}
-		helperScript := `#!/bin/sh
-sleep 2
-# Prefer service, fallback to rc.d script (pfSense, minimal env)
-if [ -x /usr/sbin/service ]; then
-    /usr/sbin/service patchmon_agent restart 2>/dev/null || /usr/sbin/service patchmon_agent start 2>/dev/null
-else
-    /usr/local/etc/rc.d/patchmon_agent restart 2>/dev/null || /usr/local/etc/rc.d/patchmon_agent start 2>/dev/null
-fi
-rm -f "$0"
-`
-		randomBytes := make([]byte, 8)
-		if _, err := rand.Read(randomBytes); err != nil {
-			randomBytes = []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
-		}
-		helperPath := filepath.Join("/etc/patchmon", fmt.Sprintf("restart-%s.sh", hex.EncodeToString(randomBytes)))
-		dirInfo, err := os.Lstat("/etc/patchmon")
-		if err == nil && dirInfo.Mode()&os.ModeSymlink != 0 {
-			logger.Warn("Security: /etc/patchmon is a symlink, refusing to create helper script")
-			os.Exit(0)
-		}
-		file, err := os.OpenFile(helperPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0700)
-		if err != nil {
-			logger.WithError(err).Warn("Failed to create restart helper script, exiting to let daemon -r respawn")
-			os.Exit(0)
-		}
-		if _, err := file.WriteString(helperScript); err != nil {
-			_ = file.Close()
-			_ = os.Remove(helperPath)
-			os.Exit(0)
-		}
-		_ = file.Close()
-		fileInfo, err := os.Lstat(helperPath)
-		if err != nil || fileInfo.Mode()&os.ModeSymlink != 0 {
-			_ = os.Remove(helperPath)
-			os.Exit(0)
-		}
-		var cmd *exec.Cmd
-		if _, nohupErr := exec.LookPath("nohup"); nohupErr == nil {
-			cmd = exec.Command("nohup", helperPath)
-		} else {
-			cmd = exec.Command("/bin/sh", helperPath)
-		}
-		cmd.Stdout = nil
-		cmd.Stderr = nil
-		cmd.SysProcAttr = sysProcAttrForDetach()
-		if err := cmd.Start(); err != nil {
-			_ = os.Remove(helperPath)
-			logger.WithError(err).Warn("Failed to start restart helper, exiting to let daemon -r respawn")
-			os.Exit(0)
-		}
-		logger.Info("Scheduled service restart via helper script (FreeBSD), exiting now...")
-		time.Sleep(500 * time.Millisecond)
-		os.Exit(0)
-		return nil
 	} else if _, err := exec.LookPath("rc-service"); err == nil {
 		// OpenRC is available (Alpine Linux)
 		// Since we're running inside the service, we can't stop ourselves directly
@@ -3345,7 +3948,39 @@ func sendComplianceProgress(phase, profileName, message string, progress float64
 // runComplianceScanWithOptions runs an on-demand compliance scan with options and sends results to server.
 // ctx can be cancelled from the server (e.g. user clicks Cancel) to abort the scan.
 // Run scan now works for both on-demand and scheduled compliance modes.
-func runComplianceScanWithOptions(ctx context.Context, options *models.ComplianceScanOptions) error {
+// Progress range covered by rule evaluation; the rest is setup and upload
+const (
+	evaluationProgressStart = 15
+	evaluationProgressEnd   = 80
+)
+
+// ruleProgressReporter turns evaluated rule counts into progress updates,
+// sending one per whole percent so a large profile can't flood the channel
+func ruleProgressReporter(profileName string) compliance.ProgressFunc {
+	last := -1
+	return func(evaluated, total int) {
+		if total <= 0 {
+			// Unknown rule count: report every 25 rules without moving the bar
+			if evaluated%25 == 0 {
+				sendComplianceProgress("evaluating", profileName, fmt.Sprintf("Evaluated %d rules...", evaluated), evaluationProgressStart, "")
+			}
+			return
+		}
+		pct := evaluationProgressStart + (evaluationProgressEnd-evaluationProgressStart)*evaluated/total
+		if pct <= last {
+			return
+		}
+		last = pct
+		sendComplianceProgress("evaluating", profileName, fmt.Sprintf("Evaluated %d of %d rules", evaluated, total), float64(pct), "")
+	}
+}
+
+func runComplianceScanWithOptions(ctx context.Context, options *models.ComplianceScanOptions) (err error) {
+	ctx, span := tracing.Start(ctx, "compliance.scan",
+		attribute.String("compliance.source", "on-demand"),
+		attribute.String("compliance.profile", options.ProfileID))
+	defer func() { tracing.End(span, err) }()
+
 	profileName := options.ProfileID
 	if profileName == "" {
 		profileName = "default"
@@ -3378,6 +4013,7 @@ func runComplianceScanWithOptions(ctx context.Context, options *models.Complianc
 
 	// Send progress: evaluating
 	sendComplianceProgress("evaluating", profileName, "Running OpenSCAP evaluation (this may take several minutes)...", 15, "")
+	complianceInteg.SetProgressFunc(ruleProgressReporter(profileName))
 
 	// Run the scan with options (25 min max; ctx can cancel earlier)
 	scanCtx, timeoutCancel := context.WithTimeout(ctx, 25*time.Minute)
@@ -3413,7 +4049,7 @@ func runComplianceScanWithOptions(ctx context.Context, options *models.Complianc
 	sendComplianceProgress("sending", profileName, "Uploading results to server...", 90, "")
 
 	// Get system info
-	systemDetector := system.New(logger)
+	systemDetector := newSystemDetector()
 	hostname, _ := systemDetector.GetHostname()
 	machineID := systemDetector.GetMachineID()
 
@@ -3425,6 +4061,10 @@ func runComplianceScanWithOptions(ctx context.Context, options *models.Complianc
 		AgentVersion:   pkgversion.Version,
 		ScanType:       "on-demand",
 	}
+	localState.SetComplianceScans(complianceData.Scans)
+	noteComplianceScores(complianceData.Scans)
+	noteScanDurations(complianceData.Scans)
+	addComplianceDiffs(complianceData.Scans)
 
 	// Debug: log what we're about to send
 	for i, scan := range payload.Scans {
@@ -3446,7 +4086,7 @@ func runComplianceScanWithOptions(ctx context.Context, options *models.Complianc
 	}
 
 	// Send to server
-	httpClient := client.New(cfgManager, logger)
+	httpClient := apiClient()
 	sendCtx, sendCancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer sendCancel()
 
@@ -3455,6 +4095,9 @@ func runComplianceScanWithOptions(ctx context.Context, options *models.Complianc
 		sendComplianceProgress("failed", profileName, "Failed to send results", 0, err.Error())
 		return fmt.Errorf("failed to send compliance data: %w", err)
 	}
+	saveComplianceBaselines(complianceData.Scans)
+	noteComplianceRegressions(complianceData.Scans)
+	uploadHTMLReports(httpClient, complianceData.Scans, payload.Hostname, payload.MachineID)
 
 	// Send progress: completed with score
 	score := float64(0)
@@ -3477,7 +4120,10 @@ func runComplianceScanWithOptions(ctx context.Context, options *models.Complianc
 }
 
 // runDockerImageScan runs a CVE scan on Docker images using oscap-docker
-func runDockerImageScan(imageName, containerName string, scanAllImages bool) error {
+func runDockerImageScan(ctx context.Context, imageName, containerName string, scanAllImages, force bool) (err error) {
+	ctx, span := tracing.Start(ctx, "docker.image_scan", attribute.Bool("docker.all_images", scanAllImages))
+	defer func() { tracing.End(span, err) }()
+
 	logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
 		"image_name":      imageName,
 		"container_name":  containerName,
@@ -3501,16 +4147,21 @@ func runDockerImageScan(imageName, containerName string, scanAllImages bool) err
 		return fmt.Errorf("oscap-docker is not available")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	timeout := 30 * time.Minute
+	if scanAllImages {
+		timeout = imageScanAllTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	var scans []*models.ComplianceScan
+	var scannedImages []string
 
 	if scanAllImages {
 		// Scan all Docker images
 		sendComplianceProgress("started", "Docker Image CVE Scan", "Scanning all Docker images for CVEs...", 5, "")
 
-		results, err := oscapDockerScanner.ScanAllImages(ctx)
+		results, err := oscapDockerScanner.ScanAllImages(ctx, imageScanOptions(force, &scannedImages))
 		if err != nil {
 			sendComplianceProgress("failed", "Docker Image CVE Scan", "Failed to scan images", 0, err.Error())
 			return fmt.Errorf("failed to scan all images: %w", err)
@@ -3554,6 +4205,7 @@ func runDockerImageScan(imageName, containerName string, scanAllImages bool) err
 	for i, scan := range scans {
 		scanValues[i] = *scan
 	}
+	noteScanDurations(scanValues)
 
 	// Create compliance data structure
 	complianceData := &models.ComplianceData{
@@ -3564,7 +4216,7 @@ func runDockerImageScan(imageName, containerName string, scanAllImages bool) err
 	sendComplianceProgress("sending", "Docker Image CVE Scan", "Uploading results to server...", 90, "")
 
 	// Get system info
-	systemDetector := system.New(logger)
+	systemDetector := newSystemDetector()
 	hostname, _ := systemDetector.GetHostname()
 	machineID := systemDetector.GetMachineID()
 
@@ -3575,9 +4227,10 @@ func runDockerImageScan(imageName, containerName string, scanAllImages bool) err
 		MachineID:      machineID,
 		AgentVersion:   pkgversion.Version,
 	}
+	addComplianceDiffs(complianceData.Scans)
 
 	// Send to server
-	httpClient := client.New(cfgManager, logger)
+	httpClient := apiClient()
 	sendCtx, sendCancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer sendCancel()
 
@@ -3586,6 +4239,9 @@ func runDockerImageScan(imageName, containerName string, scanAllImages bool) err
 		sendComplianceProgress("failed", "Docker Image CVE Scan", "Failed to send results", 0, err.Error())
 		return fmt.Errorf("failed to send Docker image scan data: %w", err)
 	}
+	recordImageScans(scannedImages)
+	saveComplianceBaselines(complianceData.Scans)
+	noteComplianceRegressions(complianceData.Scans)
 
 	// Send progress: completed
 	totalCVEs := 0
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"patchmon-agent/internal/client"
+	"patchmon-agent/internal/config"
+
+	"github.com/gorilla/websocket"
+)
+
+// serverProfile is an entry of the servers list in config.yml: another
+// PatchMon server that gets copies of the payloads it takes and has its own
+// WebSocket, over which only its allow_commands are accepted
+type serverProfile struct {
+	name    string
+	cfg     *config.Manager
+	client  *client.Client
+	allowed map[string]bool
+
+	connMu sync.RWMutex
+	conn   *websocket.Conn
+}
+
+// proxyActions run over patchmon_server's WebSocket only; their sessions
+// stream on globalWsConn
+var proxyActions = map[string]bool{
+	"ssh_proxy":            true,
+	"ssh_proxy_input":      true,
+	"ssh_proxy_resize":     true,
+	"ssh_proxy_disconnect": true,
+	"rdp_proxy":            true,
+	"rdp_proxy_input":      true,
+	"rdp_proxy_disconnect": true,
+}
+
+// loadServerProfiles builds the servers list. An entry whose config or
+// credentials are unusable is logged and left out, so it can't stop reports
+// to the others.
+func loadServerProfiles() []*serverProfile {
+	if err := cfgManager.ValidateServers(); err != nil {
+		logger.WithError(err).Error("Invalid servers config, reporting to patchmon_server only")
+		return nil
+	}
+	var profiles []*serverProfile
+	for _, p := range cfgManager.GetConfig().Servers {
+		mgr, err := cfgManager.ForServer(p)
+		if err != nil {
+			logger.WithError(err).Error("Not reporting to server")
+			continue
+		}
+		profile := &serverProfile{
+			name:    p.Name,
+			cfg:     mgr,
+			client:  client.NewServer(mgr, p, logger),
+			allowed: make(map[string]bool, len(p.AllowCommands)),
+		}
+		for _, kind := range p.AllowCommands {
+			profile.allowed[kind] = true
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles
+}
+
+// serverProfiles returns the servers list of the current config
+func serverProfiles() []*serverProfile {
+	apiClient()
+	apiClientMu.Lock()
+	defer apiClientMu.Unlock()
+	return apiServers
+}
+
+// refusal returns why a command of type kind from this server is refused
+// before it is queued, or "" if it isn't. sections are a report_now's, as
+// sent: a compliance section runs a scan, so it also needs compliance_scan.
+func (s *serverProfile) refusal(kind string, sections []string) string {
+	switch {
+	case kind == "connected" || kind == "batch":
+		// Each step of a batch is checked on its own
+		return ""
+	case proxyActions[kind]:
+		return "proxy sessions are only accepted from patchmon_server"
+	case !s.allowed[kind]:
+		return "not in allow_commands for server " + s.name
+	case kind == "report_now" && !s.allowed["compliance_scan"] && slices.ContainsFunc(sections, isComplianceSection):
+		return "compliance section needs compliance_scan in allow_commands for server " + s.name
+	}
+	return ""
+}
+
+func isComplianceSection(section string) bool {
+	return strings.EqualFold(strings.TrimSpace(section), "compliance")
+}
+
+func (s *serverProfile) setConn(conn *websocket.Conn) {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	s.conn = conn
+}
+
+func (s *serverProfile) wsConn() *websocket.Conn {
+	s.connMu.RLock()
+	defer s.connMu.RUnlock()
+	return s.conn
+}
+
+// replyConn returns the WebSocket of the server that sent m
+func (m wsMsg) replyConn() *websocket.Conn {
+	if m.server != nil {
+		return m.server.wsConn()
+	}
+	return currentWsConn()
+}
+
+type serverKey struct{}
+
+// withServer marks ctx as running a command from server; nil means
+// patchmon_server
+func withServer(ctx context.Context, server *serverProfile) context.Context {
+	if server == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, serverKey{}, server)
+}
+
+// commandClient returns the client for the server whose command runs under
+// ctx. Results that answer a command, like patch run output, go back to that
+// server only; payloads the agent collects go to every server that takes them.
+func commandClient(ctx context.Context) *client.Client {
+	if server, ok := ctx.Value(serverKey{}).(*serverProfile); ok {
+		return server.client
+	}
+	return apiClient()
+}
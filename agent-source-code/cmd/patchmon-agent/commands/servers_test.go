@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// mspServer configures a servers entry for an MSP's central instance
+func mspServer(t *testing.T, credentials string, allow ...string) *serverProfile {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "msp.yml")
+	if err := os.WriteFile(path, []byte(credentials), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := cfgManager.GetConfig()
+	cfg.PatchmonServer = "https://patchmon.customer.example"
+	cfg.Servers = []models.ServerProfile{{Name: "msp", Server: "https://central.msp.example", CredentialsFile: path, AllowCommands: allow}}
+	profiles := loadServerProfiles()
+	if len(profiles) != 1 {
+		t.Fatalf("loaded %d servers", len(profiles))
+	}
+	return profiles[0]
+}
+
+func TestServerAllowCommands(t *testing.T) {
+	setupBatchTest(t)
+	msp := mspServer(t, "api_id: msp\napi_key: key\n", "report_now", "ssh_proxy")
+
+	for kind, refused := range map[string]bool{
+		"connected":    false,
+		"batch":        false,
+		"report_now":   false,
+		"run_patch":    true,
+		"job_cancel":   true,
+		"ssh_proxy":    true,
+		"apply_config": true,
+	} {
+		if got := msp.refusal(kind, nil) != ""; got != refused {
+			t.Errorf("refusal(%q) = %q", kind, msp.refusal(kind, nil))
+		}
+	}
+
+	// A compliance section runs a scan msp isn't allowed to start
+	if msp.refusal("report_now", []string{"docker", " Compliance"}) == "" {
+		t.Error("report_now with a compliance section allowed without compliance_scan")
+	}
+	scanner := mspServer(t, "api_id: msp\napi_key: key\n", "report_now", "compliance_scan")
+	if reason := scanner.refusal("report_now", []string{"compliance"}); reason != "" {
+		t.Errorf("report_now with a compliance section refused: %s", reason)
+	}
+}
+
+func TestServerObserverCredentials(t *testing.T) {
+	setupBatchTest(t)
+	msp := mspServer(t, "api_id: msp\napi_key: key\nobserver: true\n", "run_patch", "package_update")
+
+	if reason := remoteActionRefusal(wsMsg{kind: "run_patch"}); reason != "" {
+		t.Fatalf("patchmon_server's run_patch refused: %s", reason)
+	}
+	if reason := remoteActionRefusal(wsMsg{kind: "run_patch", server: msp}); reason == "" {
+		t.Error("run_patch from an observer server was allowed")
+	}
+	if reason := remoteActionRefusal(wsMsg{kind: "package_update", dryRun: true, server: msp}); reason != "" {
+		t.Errorf("dry run refused: %s", reason)
+	}
+}
+
+func TestCommandResultsGoToSender(t *testing.T) {
+	setupBatchTest(t)
+	msp := mspServer(t, "api_id: msp\napi_key: key\n")
+
+	main := apiClient()
+	if len(main.Servers()) != 1 {
+		t.Fatalf("payloads copied to %d servers", len(main.Servers()))
+	}
+	if got := commandClient(context.Background()); got != main {
+		t.Error("patchmon_server's command not answered with the main client")
+	}
+	if got := commandClient(withServer(context.Background(), msp)); got != msp.client || got.Server() != "msp" {
+		t.Error("msp's command not answered with its client")
+	}
+	if (wsMsg{server: msp}).replyConn() != nil {
+		t.Error("reply routed to a connection msp doesn't have")
+	}
+}
@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSimulatedHostIsDeterministic(t *testing.T) {
+	a := newSimulatedHost("sim-0001", 0, 7).report(time.Hour)
+	b := newSimulatedHost("sim-0001", 0, 7).report(time.Hour)
+	aj, _ := json.Marshal(a)
+	bj, _ := json.Marshal(b)
+	if string(aj) != string(bj) {
+		t.Fatal("the same seed and hostname gave different reports")
+	}
+
+	other := newSimulatedHost("sim-0002", 1, 7).report(time.Hour)
+	if other.MachineID == a.MachineID || other.IP == a.IP {
+		t.Errorf("hosts share machine ID or IP: %s %s", a.MachineID, a.IP)
+	}
+	reseeded := newSimulatedHost("sim-0001", 0, 8).report(time.Hour)
+	if reseeded.MachineID == a.MachineID {
+		t.Error("another seed gave the same host")
+	}
+}
+
+func TestSimulatedHostReport(t *testing.T) {
+	h := newSimulatedHost("sim-0042", 41, 1)
+	first := h.report(0)
+	if len(first.Packages) < 300 || first.Packages[0].Name != "bash" {
+		t.Fatalf("unexpected packages: %d, first %q", len(first.Packages), first.Packages[0].Name)
+	}
+	for _, pkg := range first.Packages {
+		if pkg.IsSecurityUpdate && !pkg.NeedsUpdate {
+			t.Fatalf("%s is a security update without an update", pkg.Name)
+		}
+		if pkg.NeedsUpdate && pkg.AvailableVersion == "" {
+			t.Fatalf("%s needs an update without an available version", pkg.Name)
+		}
+	}
+	if first.Hostname != "sim-0042" || first.PackageManager == "" || len(first.Repositories) == 0 {
+		t.Errorf("incomplete report: %+v", first)
+	}
+
+	updates := func() int {
+		n := 0
+		for _, pkg := range h.packages {
+			if pkg.NeedsUpdate {
+				n++
+			}
+		}
+		return n
+	}
+	before := updates()
+	for range 100 {
+		h.report(time.Hour)
+	}
+	if updates() <= before {
+		t.Error("no new updates turned up over 100 rounds")
+	}
+	if got := h.report(26 * time.Hour).SystemUptime; got == first.SystemUptime {
+		t.Errorf("uptime did not move on: %s", got)
+	}
+}
+
+func TestSimulatedAddressesAreUnique(t *testing.T) {
+	seen := make(map[string]bool, maxSimulatedHosts)
+	for i := range maxSimulatedHosts {
+		ip, gateway := simulatedAddress(i)
+		if seen[ip] || ip == gateway {
+			t.Fatalf("host %d reuses %s", i, ip)
+		}
+		seen[ip] = true
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	if got := percentile(sorted, 50); got != 5 {
+		t.Errorf("p50 = %d, want 5", got)
+	}
+	if got := percentile(sorted, 95); got != 9 {
+		t.Errorf("p95 = %d, want 9", got)
+	}
+	if got := percentile(nil, 95); got != 0 {
+		t.Errorf("empty p95 = %d", got)
+	}
+}
@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"patchmon-agent/internal/utils"
+)
+
+// defaultStartupReportWindow spreads initial reports after a fleet-wide
+// restart when startup_report_window is not set
+const defaultStartupReportWindow = 2 * time.Minute
+
+// startupGate is the pending initial report's gate while serve starts, so the
+// server's "connected" message can push it back (slow start)
+var (
+	startupGateMu sync.Mutex
+	startupGate   *startupReportGate
+)
+
+// startupReportGate delays the initial report to a per-host point within a
+// window. The point is derived from the api_id so a fleet restarted at once
+// reports spread across the window instead of all at the same moment.
+type startupReportGate struct {
+	apiID   string
+	start   time.Time
+	mu      sync.Mutex
+	until   time.Time
+	changed chan struct{}
+}
+
+func newStartupReportGate(apiID string, window time.Duration) *startupReportGate {
+	g := &startupReportGate{apiID: apiID, start: time.Now(), changed: make(chan struct{}, 1)}
+	g.until = g.start.Add(utils.CalculateScanOffset(apiID, window))
+	return g
+}
+
+// setWindow moves the report to this host's point within a new window,
+// measured from when serve started
+func (g *startupReportGate) setWindow(window time.Duration) time.Time {
+	g.mu.Lock()
+	g.until = g.start.Add(utils.CalculateScanOffset(g.apiID, window))
+	until := g.until
+	g.mu.Unlock()
+	select {
+	case g.changed <- struct{}{}:
+	default:
+	}
+	return until
+}
+
+func (g *startupReportGate) deadline() time.Time {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.until
+}
+
+// wait blocks until the deadline, following any change made while waiting
+func (g *startupReportGate) wait(ctx context.Context) error {
+	for {
+		timer := time.NewTimer(time.Until(g.deadline()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-g.changed:
+			timer.Stop()
+		case <-timer.C:
+			return nil
+		}
+	}
+}
+
+// startupReportWindow returns startup_report_window, or the default when unset
+func startupReportWindow() time.Duration {
+	if w := cfgManager.GetConfig().StartupReportWindow; w != nil {
+		return time.Duration(max(*w, 0)) * time.Second
+	}
+	return defaultStartupReportWindow
+}
+
+// applySlowStart handles a slow_start directive from the server's "connected"
+// message while the initial report is still pending
+func applySlowStart(seconds int) {
+	startupGateMu.Lock()
+	g := startupGate
+	startupGateMu.Unlock()
+	if g == nil || seconds <= 0 {
+		return
+	}
+	until := g.setWindow(time.Duration(seconds) * time.Second)
+	logger.WithField("report_at", until.Format(time.RFC3339)).Info("Server requested slow start, initial report rescheduled")
+}
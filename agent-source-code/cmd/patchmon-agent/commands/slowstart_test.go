@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStartupReportGate(t *testing.T) {
+	g := newStartupReportGate("patchmon_abc123", 0)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := g.wait(ctx); err != nil {
+		t.Fatalf("zero window should not delay: %v", err)
+	}
+
+	// Deterministic per host and within the window
+	window := time.Hour
+	a := newStartupReportGate("patchmon_abc123", window)
+	b := newStartupReportGate("patchmon_abc123", window)
+	if da, db := a.deadline().Sub(a.start), b.deadline().Sub(b.start); da != db || da < 0 || da >= window {
+		t.Fatalf("offsets %v and %v, want equal and within %v", da, db, window)
+	}
+
+	// A server slow_start moves a pending report and wakes the waiter
+	g = newStartupReportGate("patchmon_abc123", 0)
+	g.setWindow(window)
+	done := make(chan error, 1)
+	go func() { done <- g.wait(ctx) }()
+	g.setWindow(0)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("waiter did not follow the shortened window")
+	}
+}
@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"patchmon-agent/internal/pkgversion"
+	"patchmon-agent/internal/tracing"
+)
+
+// startTracing exports spans when tracing is configured. The returned func
+// flushes spans still buffered and is called when the command finishes.
+func startTracing() func() {
+	cfg := cfgManager.GetConfig().Tracing
+	if !tracing.Enabled(cfg) {
+		return func() {}
+	}
+	hostname, _ := newSystemDetector().GetHostname()
+	shutdown, err := tracing.Setup(context.Background(), cfg, hostname, pkgversion.Version)
+	if err != nil {
+		logger.WithError(err).Warn("Tracing disabled")
+		return func() {}
+	}
+	logger.Info("Exporting OpenTelemetry traces")
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			logger.WithError(err).Debug("Failed to flush traces")
+		}
+	}
+}
@@ -17,10 +17,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 	"patchmon-agent/internal/client"
 	"patchmon-agent/internal/config"
 	"patchmon-agent/internal/logutil"
 	"patchmon-agent/internal/pkgversion"
+	"patchmon-agent/internal/utils"
 
 	"github.com/spf13/cobra"
 )
@@ -313,7 +315,7 @@ func getServerVersionInfo() (*ServerVersionInfo, error) {
 	architecture := getArchitecture()
 	platform := getPlatform()
 	currentVersion := strings.TrimPrefix(pkgversion.Version, "v")
-	url := fmt.Sprintf("%s/api/v1/hosts/agent/version?arch=%s&os=%s&type=go&currentVersion=%s", cfg.PatchmonServer, architecture, platform, currentVersion)
+	url := fmt.Sprintf("%s/api/v1/hosts/agent/version?arch=%s&os=%s&type=go&currentVersion=%s", client.ServerURL(cfg), architecture, platform, currentVersion)
 
 	ctx, cancel := context.WithTimeout(context.Background(), versionCheckTimeout)
 	defer cancel()
@@ -326,11 +328,15 @@ func getServerVersionInfo() (*ServerVersionInfo, error) {
 	req.Header.Set("User-Agent", fmt.Sprintf("patchmon-agent/%s", pkgversion.Version))
 	req.Header.Set("X-API-ID", credentials.APIID)
 	req.Header.Set("X-API-KEY", credentials.APIKey)
+	if err := apiClient().AuthHeaders().Apply(ctx, req.Header); err != nil {
+		return nil, err
+	}
 
 	// Create HTTP client with proper timeouts (shorter for version checks)
 	httpClient := &http.Client{
 		Timeout: versionCheckTimeout,
 		Transport: &http.Transport{
+			DialContext:           utils.DialContext(versionCheckTimeout),
 			ResponseHeaderTimeout: 5 * time.Second,
 		},
 	}
@@ -339,12 +345,17 @@ func getServerVersionInfo() (*ServerVersionInfo, error) {
 	if cfg.SkipSSLVerify || client.IsSkipSSLVerifyEnvSet() {
 		logger.Warn("TLS verification disabled for version check")
 		httpClient.Transport = &http.Transport{
+			DialContext:           utils.DialContext(versionCheckTimeout),
 			ResponseHeaderTimeout: 5 * time.Second,
 			TLSClientConfig: &tls.Config{
 				InsecureSkipVerify: true,
 			},
 		}
 	}
+	httpClient, err = withServerTransport(httpClient, cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
@@ -368,6 +379,32 @@ func getServerVersionInfo() (*ServerVersionInfo, error) {
 	return &versionInfo, nil
 }
 
+// withServerTransport returns a copy of c that reaches relay_url when one is
+// set, or presents client_tls to the server. c is never modified, since it may
+// be http.DefaultClient.
+func withServerTransport(c *http.Client, cfg *models.Config) (*http.Client, error) {
+	if cfg.RelayURL == "" && cfg.ClientTLS == nil {
+		return c, nil
+	}
+	tlsConfig, err := client.TLSConfig(cfg, cfg.SkipSSLVerify || client.IsSkipSSLVerifyEnvSet())
+	if err != nil {
+		return nil, err
+	}
+	base, ok := c.Transport.(*http.Transport)
+	if !ok {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	t := base.Clone()
+	t.TLSClientConfig = tlsConfig
+	if dial := client.RelayDialContext(cfg); dial != nil {
+		t.DialContext = dial
+		t.Proxy = nil
+	}
+	out := *c
+	out.Transport = t
+	return &out, nil
+}
+
 // getLatestBinaryFromServer fetches the latest binary information from the PatchMon server
 func getLatestBinaryFromServer() (*ServerVersionResponse, error) {
 	cfgManager := config.New()
@@ -384,7 +421,7 @@ func getLatestBinaryFromServer() (*ServerVersionResponse, error) {
 
 	architecture := getArchitecture()
 	platform := getPlatform()
-	url := fmt.Sprintf("%s/api/v1/hosts/agent/download?arch=%s&os=%s", cfg.PatchmonServer, architecture, platform)
+	url := fmt.Sprintf("%s/api/v1/hosts/agent/download?arch=%s&os=%s", client.ServerURL(cfg), architecture, platform)
 
 	ctx, cancel := context.WithTimeout(context.Background(), serverTimeout)
 	defer cancel()
@@ -397,20 +434,32 @@ func getLatestBinaryFromServer() (*ServerVersionResponse, error) {
 	req.Header.Set("User-Agent", fmt.Sprintf("patchmon-agent/%s", pkgversion.Version))
 	req.Header.Set("X-API-ID", credentials.APIID)
 	req.Header.Set("X-API-KEY", credentials.APIKey)
+	if err := client.NewAuthHeaders(cfg.AuthHeaders).Apply(ctx, req.Header); err != nil {
+		return nil, err
+	}
 
 	// Operator-gated insecure TLS for lab/air-gapped deployments.
 	// WARNING: This is dangerous for binary downloads even with hash verification!
-	httpClient := http.DefaultClient
+	httpClient := &http.Client{Transport: &http.Transport{
+		Proxy:       http.ProxyFromEnvironment,
+		DialContext: utils.DialContext(serverTimeout),
+	}}
 	if cfg.SkipSSLVerify || client.IsSkipSSLVerifyEnvSet() {
 		logger.Warn("TLS verification disabled for binary download")
 		httpClient = &http.Client{
 			Transport: &http.Transport{
+				Proxy:       http.ProxyFromEnvironment,
+				DialContext: utils.DialContext(serverTimeout),
 				TLSClientConfig: &tls.Config{
 					InsecureSkipVerify: true,
 				},
 			},
 		}
 	}
+	httpClient, err = withServerTransport(httpClient, cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
@@ -598,6 +647,13 @@ func markRecentUpdate() {
 
 // restartService restarts the patchmon-agent service (supports systemd, OpenRC, and FreeBSD rc.d)
 func restartService(_ string, _ string) error {
+	// A profile's serve runs from a unit of its own, not patchmon-agent;
+	// exiting lets its service manager start it again
+	if profile := cfgManager.Profile(); profile != "" {
+		logger.WithField("profile", profile).Info("Exiting for the profile's service to restart the agent")
+		os.Exit(0)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
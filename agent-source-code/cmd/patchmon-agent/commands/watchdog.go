@@ -0,0 +1,290 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/client"
+	"patchmon-agent/internal/logutil"
+	"patchmon-agent/internal/utils"
+)
+
+const (
+	// watchdogCheckInterval is how often serve checks for a window of silence
+	watchdogCheckInterval = 5 * time.Minute
+	// watchdogMissedIntervals is how many report intervals may pass without a
+	// successful report before the watchdog intervenes
+	watchdogMissedIntervals = 3
+	// watchdogWebSocketDownLimit is how long the WebSocket may stay down, despite
+	// wsLoop's own retries, before the watchdog intervenes
+	watchdogWebSocketDownLimit = time.Hour
+	// watchdogRestartCooldown stops a host with a permanently broken network from
+	// restarting the agent over and over
+	watchdogRestartCooldown = 6 * time.Hour
+	// watchdogIncidentsFile keeps recent incidents so they survive a self-restart
+	watchdogIncidentsFile = "watchdog_incidents.json"
+	watchdogMaxIncidents  = 20
+)
+
+// watchdogStep is one escalating recovery action, run for the current incident
+type watchdogStep struct {
+	name string
+	run  func(ctx context.Context, incident *models.WatchdogIncident) error
+}
+
+// watchdogSteps are tried in order, one per check, until the agent recovers
+var watchdogSteps = []watchdogStep{
+	{"reload_config", watchdogReloadConfig},
+	{"reset_connections", watchdogResetConnections},
+	{"reresolve_dns", watchdogReresolveDNS},
+	{"restart_service", watchdogRestartService},
+}
+
+// recordReportSuccess marks a report as delivered in the health state and in
+// lastReportSuccessFile, which other agent processes read
+func recordReportSuccess() {
+	now := time.Now()
+	agentHealthMu.Lock()
+	agentHealth.LastReportAt = &now
+	agentHealthMu.Unlock()
+	if err := utils.WriteFileAtomic(cfgManager.StatePath(lastReportSuccessFile), []byte(now.UTC().Format(time.RFC3339Nano)+"\n"), 0600); err != nil {
+		logger.WithError(err).Debug("Failed to record report delivery")
+	}
+}
+
+// loadLastReportSuccess returns when a report was last delivered by any agent
+// process, or zero if none has been
+func loadLastReportSuccess() time.Time {
+	data, err := os.ReadFile(cfgManager.StatePath(lastReportSuccessFile))
+	if err != nil {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// recordWebSocketState tracks when the WebSocket went down. Only the first
+// disconnect of an outage sets the timestamp so retries don't reset the clock.
+func recordWebSocketState(connected bool) {
+	agentHealthMu.Lock()
+	defer agentHealthMu.Unlock()
+	if connected {
+		agentHealth.WebSocketDownSince = nil
+	} else if agentHealth.WebSocketDownSince == nil {
+		now := time.Now()
+		agentHealth.WebSocketDownSince = &now
+	}
+}
+
+// silenceReasons returns why the agent is considered silent, or nil if healthy.
+// lastReport is the last successful report, or the serve start time if none.
+func silenceReasons(now, lastReport time.Time, wsDownSince *time.Time, interval time.Duration) []string {
+	var reasons []string
+	if limit := watchdogMissedIntervals * interval; now.Sub(lastReport) > limit {
+		reasons = append(reasons, fmt.Sprintf("no successful report for %s (limit %s)", now.Sub(lastReport).Round(time.Minute), limit))
+	}
+	if wsDownSince != nil && now.Sub(*wsDownSince) > watchdogWebSocketDownLimit {
+		reasons = append(reasons, fmt.Sprintf("WebSocket down for %s", now.Sub(*wsDownSince).Round(time.Minute)))
+	}
+	return reasons
+}
+
+// runWatchdog notices windows of silence (no successful report for several
+// intervals, or a WebSocket that stays down) and escalates through recovery
+// steps, one per check, until the agent is healthy again.
+func runWatchdog(ctx context.Context) {
+	startedAt := time.Now()
+
+	var incident *models.WatchdogIncident
+	step := 0
+
+	ticker := time.NewTicker(watchdogCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		interval := time.Duration(cfgManager.GetConfig().UpdateInterval) * time.Minute
+		if interval <= 0 {
+			interval = 60 * time.Minute
+		}
+		// Silence is expected while paused; resuming sends a report straight away
+		if loadPause() != nil {
+			continue
+		}
+
+		health := getAgentHealth()
+		lastReport := startedAt
+		if health.LastReportAt != nil {
+			lastReport = *health.LastReportAt
+		}
+		reasons := silenceReasons(time.Now(), lastReport, health.WebSocketDownSince, interval)
+
+		if len(reasons) == 0 {
+			if incident != nil {
+				now := time.Now()
+				incident.ResolvedAt = &now
+				saveWatchdogIncident(incident)
+				logger.WithField("actions", strings.Join(incident.Actions, ", ")).Info("✅ Watchdog: agent recovered")
+				incident = nil
+				step = 0
+			}
+			continue
+		}
+
+		if incident == nil {
+			incident = &models.WatchdogIncident{StartedAt: time.Now(), Reason: strings.Join(reasons, "; ")}
+			logger.WithField("reason", logutil.Sanitize(incident.Reason)).Warn("⚠️  Watchdog: agent has gone silent, starting recovery")
+		}
+
+		s := watchdogSteps[step%len(watchdogSteps)]
+		step++
+		action := s.name
+		// Persist before running so a restart still leaves a record of it
+		incident.Actions = append(incident.Actions, action)
+		saveWatchdogIncident(incident)
+
+		logger.WithField("action", action).Info("Watchdog: running recovery step")
+		if err := s.run(ctx, incident); err != nil {
+			logger.WithError(err).WithField("action", action).Warn("Watchdog: recovery step failed")
+			incident.Actions[len(incident.Actions)-1] = fmt.Sprintf("%s (failed: %v)", action, err)
+			saveWatchdogIncident(incident)
+			continue
+		}
+
+		// See whether the step was enough; the next check decides whether to escalate
+		if err := sendReport(false); err != nil {
+			logger.WithError(err).Debug("Watchdog: report still failing after recovery step")
+		}
+	}
+}
+
+func watchdogReloadConfig(_ context.Context, _ *models.WatchdogIncident) error {
+	if err := cfgManager.LoadConfig(); err != nil {
+		return err
+	}
+	return cfgManager.LoadCredentials()
+}
+
+// watchdogResetConnections drops pooled connections, the cached API client and
+// the WebSocket so the next request and wsLoop redial from scratch with the
+// current config
+func watchdogResetConnections(_ context.Context, _ *models.WatchdogIncident) error {
+	resetAPIClient()
+	client.CloseIdleConnections()
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		t.CloseIdleConnections()
+	}
+	globalWsConnMu.Lock()
+	conn := globalWsConn
+	globalWsConnMu.Unlock()
+	if conn != nil {
+		_ = conn.Close()
+	}
+	return nil
+}
+
+// watchdogReresolveDNS re-runs the connectivity self-test, which resolves the
+// server afresh through both the system and fallback resolvers
+func watchdogReresolveDNS(ctx context.Context, _ *models.WatchdogIncident) error {
+	target, ok := connectivityTarget()
+	if !ok {
+		return nil
+	}
+	result := newConnectivityChecker().Check(ctx, target)
+	agentHealthMu.Lock()
+	agentHealth.Connectivity = result
+	agentHealthMu.Unlock()
+	if len(result.Problems) > 0 {
+		return errors.New(strings.Join(result.Problems, "; "))
+	}
+	return nil
+}
+
+// watchdogRestartService restarts the agent unless the watchdog already did
+// within watchdogRestartCooldown. The restart is recorded on the incident
+// first, since the process doesn't survive it.
+func watchdogRestartService(_ context.Context, incident *models.WatchdogIncident) error {
+	if runtime.GOOS == "windows" {
+		return errors.New("self-restart is not supported on Windows")
+	}
+	if last := lastWatchdogRestart(loadWatchdogIncidents()); time.Since(last) < watchdogRestartCooldown {
+		return fmt.Errorf("skipped, last watchdog restart was %s ago", time.Since(last).Round(time.Minute))
+	}
+	now := time.Now()
+	incident.LastRestartAt = &now
+	saveWatchdogIncident(incident)
+	logger.Warn("Watchdog: restarting patchmon-agent service")
+	return restartService("", "")
+}
+
+// lastWatchdogRestart returns when the watchdog last attempted a restart
+func lastWatchdogRestart(incidents []models.WatchdogIncident) time.Time {
+	var last time.Time
+	for _, inc := range incidents {
+		if inc.LastRestartAt != nil && inc.LastRestartAt.After(last) {
+			last = *inc.LastRestartAt
+		}
+	}
+	return last
+}
+
+func loadWatchdogIncidents() []models.WatchdogIncident {
+	data, err := os.ReadFile(cfgManager.StatePath(watchdogIncidentsFile))
+	if err != nil {
+		return nil
+	}
+	var incidents []models.WatchdogIncident
+	if err := json.Unmarshal(data, &incidents); err != nil {
+		return nil
+	}
+	return incidents
+}
+
+// saveWatchdogIncident inserts or updates the incident (keyed by start time),
+// keeps the newest watchdogMaxIncidents and mirrors it into the health state
+func saveWatchdogIncident(incident *models.WatchdogIncident) {
+	snapshot := *incident
+	snapshot.Actions = append([]string(nil), incident.Actions...)
+
+	agentHealthMu.Lock()
+	agentHealth.LastIncident = &snapshot
+	agentHealthMu.Unlock()
+
+	incidents := loadWatchdogIncidents()
+	replaced := false
+	for i := range incidents {
+		if incidents[i].StartedAt.Equal(snapshot.StartedAt) {
+			incidents[i] = snapshot
+			replaced = true
+		}
+	}
+	if !replaced {
+		incidents = append(incidents, snapshot)
+	}
+	if len(incidents) > watchdogMaxIncidents {
+		incidents = incidents[len(incidents)-watchdogMaxIncidents:]
+	}
+
+	data, err := json.Marshal(incidents)
+	if err != nil {
+		return
+	}
+	if err := utils.WriteFileAtomic(cfgManager.StatePath(watchdogIncidentsFile), data, 0600); err != nil {
+		logger.WithError(err).Debug("Failed to save watchdog incidents")
+	}
+}
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+func TestSilenceReasons(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	interval := 60 * time.Minute
+
+	t.Run("healthy agent", func(t *testing.T) {
+		if got := silenceReasons(now, now.Add(-90*time.Minute), nil, interval); len(got) != 0 {
+			t.Fatalf("expected no reasons, got %v", got)
+		}
+	})
+
+	t.Run("missed reports", func(t *testing.T) {
+		got := silenceReasons(now, now.Add(-4*time.Hour), nil, interval)
+		if len(got) != 1 || !strings.Contains(got[0], "no successful report") {
+			t.Fatalf("expected missed report reason, got %v", got)
+		}
+	})
+
+	t.Run("websocket down past limit", func(t *testing.T) {
+		down := now.Add(-2 * time.Hour)
+		got := silenceReasons(now, now, &down, interval)
+		if len(got) != 1 || !strings.Contains(got[0], "WebSocket down") {
+			t.Fatalf("expected websocket reason, got %v", got)
+		}
+	})
+
+	t.Run("brief websocket outage is tolerated", func(t *testing.T) {
+		down := now.Add(-10 * time.Minute)
+		if got := silenceReasons(now, now, &down, interval); len(got) != 0 {
+			t.Fatalf("expected no reasons, got %v", got)
+		}
+	})
+}
+
+func TestLastWatchdogRestart(t *testing.T) {
+	started := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// A long incident restarts well after it began; the cooldown runs from
+	// the restart
+	restarted := started.Add(8 * time.Hour)
+	incidents := []models.WatchdogIncident{
+		{StartedAt: started, Actions: []string{"reload_config", "restart_service"}, LastRestartAt: &restarted},
+		{StartedAt: started.Add(9 * time.Hour), Actions: []string{"reload_config", "restart_service (failed: skipped)"}},
+	}
+
+	if got := lastWatchdogRestart(incidents); !got.Equal(restarted) {
+		t.Fatalf("lastWatchdogRestart() = %v, want %v", got, restarted)
+	}
+	if got := lastWatchdogRestart(nil); !got.IsZero() {
+		t.Fatalf("expected zero time for no incidents, got %v", got)
+	}
+}
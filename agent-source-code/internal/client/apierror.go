@@ -0,0 +1,154 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// Back-off applied when the server rate-limits or rejects the credentials
+const (
+	defaultRetryAfter     = time.Minute
+	maxRetryAfter         = time.Hour
+	invalidAPIKeyBackoff  = 5 * time.Minute
+	maxErrorMessageLength = 200
+)
+
+// APIError is a request the server refused. Code comes from the response body
+// when the server sends one and is otherwise inferred from the status, so
+// callers can act on it without matching message text.
+type APIError struct {
+	// Op names the request, e.g. "update request"
+	Op         string
+	StatusCode int
+	Code       models.ErrorCode
+	Message    string
+	// RetryAfter is how long the server asked the agent to wait
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s failed with status %d", e.Op, e.StatusCode)
+	if e.Code != "" {
+		msg += " (" + string(e.Code) + ")"
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// ErrorCode returns the server error code carried by err, or "" if err is not
+// a refused API request
+func ErrorCode(err error) models.ErrorCode {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
+
+// statusCodes are the codes implied by a status when the server sends none
+var statusCodes = map[int]models.ErrorCode{
+	http.StatusUnauthorized:          models.ErrInvalidAPIKey,
+	http.StatusRequestEntityTooLarge: models.ErrPayloadTooLarge,
+	http.StatusTooManyRequests:       models.ErrRateLimited,
+	http.StatusServiceUnavailable:    models.ErrServerUnavailable,
+}
+
+// parseErrorResponse reads the error body of a failed request
+func parseErrorResponse(body []byte) (models.ErrorResponse, bool) {
+	var parsed models.ErrorResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return parsed, false
+	}
+	return parsed, parsed.Code != "" || parsed.Error != "" || parsed.Message != ""
+}
+
+// apiError builds the error for a failed request and, when the server asked
+// for it, stops further requests for a while; see checkBackoff
+func (c *Client) apiError(op string, resp *resty.Response) *APIError {
+	e := &APIError{Op: op, StatusCode: resp.StatusCode()}
+	if parsed, ok := parseErrorResponse(resp.Body()); ok {
+		e.Code = parsed.Code
+		e.Message = parsed.Message
+		if e.Message == "" {
+			e.Message = parsed.Error
+		}
+		e.Message = truncateResponse(e.Message, maxErrorMessageLength)
+		e.RetryAfter = time.Duration(parsed.RetryAfter) * time.Second
+	} else {
+		e.Message = truncateResponse(resp.String(), maxErrorMessageLength)
+	}
+	if e.Code == "" {
+		e.Code = statusCodes[e.StatusCode]
+	}
+	if e.RetryAfter <= 0 {
+		e.RetryAfter = parseRetryAfter(resp.Header().Get("Retry-After"), time.Now())
+	}
+
+	switch e.Code {
+	case models.ErrRateLimited, models.ErrServerUnavailable:
+		if e.RetryAfter <= 0 {
+			e.RetryAfter = defaultRetryAfter
+		}
+		c.backOff(e)
+	case models.ErrInvalidAPIKey:
+		// Every request would be refused the same way until the host is
+		// re-registered; don't hammer the server meanwhile
+		e.RetryAfter = invalidAPIKeyBackoff
+		c.backOff(e)
+	}
+	return e
+}
+
+// parseRetryAfter reads a Retry-After header: seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// backOff refuses requests until e.RetryAfter has passed
+func (c *Client) backOff(e *APIError) {
+	wait := e.RetryAfter
+	if wait > maxRetryAfter {
+		wait = maxRetryAfter
+	}
+	c.backoff.Store(&backoffState{until: time.Now().Add(wait), cause: e})
+	c.logger.WithFields(logrus.Fields{
+		"code":  e.Code,
+		"retry": wait.String(),
+	}).Warn("Pausing requests to the server")
+}
+
+// backoffState is a pause requested by the server
+type backoffState struct {
+	until time.Time
+	cause *APIError
+}
+
+// checkBackoff fails while a server-requested pause is in effect. The error
+// wraps the response that started it, so ErrorCode still works.
+func (c *Client) checkBackoff() error {
+	state := c.backoff.Load()
+	if state == nil || !time.Now().Before(state.until) {
+		return nil
+	}
+	return fmt.Errorf("not contacting server until %s: %w", state.until.Format(time.RFC3339), state.cause)
+}
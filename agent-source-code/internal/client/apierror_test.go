@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIErrorCodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		header  string
+		body    string
+		code    models.ErrorCode
+		message string
+		backoff bool
+	}{
+		{"code from body", http.StatusBadRequest, "", `{"code":"ERR_SCHEMA_UNSUPPORTED","message":"schema 9 unsupported"}`, models.ErrSchemaUnsupported, "schema 9 unsupported", false},
+		{"inferred from status", http.StatusRequestEntityTooLarge, "", `<html>too big</html>`, models.ErrPayloadTooLarge, "<html>too big</html>", false},
+		{"old server error field", http.StatusBadRequest, "", `{"error":"bad payload"}`, "", "bad payload", false},
+		{"rate limited", http.StatusTooManyRequests, "120", `{"error":"slow down"}`, models.ErrRateLimited, "slow down", true},
+		{"invalid key", http.StatusUnauthorized, "", `{"code":"ERR_INVALID_API_KEY","message":"unknown api id"}`, models.ErrInvalidAPIKey, "unknown api id", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if tt.header != "" {
+					w.Header().Set("Retry-After", tt.header)
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			c := testClient(srv.URL, "")
+			err := c.SendPackageTransaction(context.Background(), &models.PackageTransactionPayload{
+				Transaction: &models.PackageTransaction{Manager: "apt"},
+			})
+			var apiErr *APIError
+			require.True(t, errors.As(err, &apiErr), "got %v", err)
+			assert.Equal(t, tt.status, apiErr.StatusCode)
+			assert.Equal(t, tt.code, apiErr.Code)
+			assert.Equal(t, tt.message, apiErr.Message)
+			assert.Equal(t, tt.backoff, c.checkBackoff() != nil)
+		})
+	}
+}
+
+func TestBackoffStopsRequests(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"code":"ERR_SERVER_UNAVAILABLE","message":"maintenance","retryAfter":30}`))
+	}))
+	defer srv.Close()
+
+	c := testClient(srv.URL, "")
+	_, err := c.SendUpdate(context.Background(), &models.ReportPayload{Hostname: "web-1"})
+	require.Error(t, err)
+	_, err = c.SendUpdate(context.Background(), &models.ReportPayload{Hostname: "web-1"})
+	assert.ErrorContains(t, err, "not contacting server until")
+	assert.Equal(t, models.ErrServerUnavailable, ErrorCode(err))
+	assert.Equal(t, int32(1), calls.Load())
+
+	c.backoff.Store(&backoffState{until: time.Now().Add(-time.Second)})
+	_, err = c.SendUpdate(context.Background(), &models.ReportPayload{Hostname: "web-1"})
+	require.Error(t, err)
+	assert.Equal(t, int32(2), calls.Load(), "requests resume after the pause")
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, 90*time.Second, parseRetryAfter("90", now))
+	assert.Equal(t, 2*time.Minute, parseRetryAfter("Sat, 17 Oct 2026 12:02:00 GMT", now))
+	assert.Zero(t, parseRetryAfter("soon", now))
+}
+
+func TestMachineIDConflictCode(t *testing.T) {
+	conflict := registrationConflict(http.StatusBadRequest, []byte(`{"code":"ERR_MACHINE_ID_CONFLICT","message":"taken","existingHostname":"db-1"}`))
+	require.NotNil(t, conflict)
+	assert.Equal(t, "db-1", conflict.ExistingHostname)
+}
+
+func TestOversizedComplianceIsSplit(t *testing.T) {
+	var batches []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload models.CompliancePayload
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(body, &payload))
+		if len(payload.Scans) > 1 {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		batches = append(batches, len(payload.Scans))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"message":"ok","scans_received":1}`))
+	}))
+	defer srv.Close()
+
+	payload := &models.CompliancePayload{Hostname: "web-1"}
+	payload.Scans = []models.ComplianceScan{{ProfileName: "a"}, {ProfileName: "b"}, {ProfileName: "c"}}
+	result, err := testClient(srv.URL, "").SendComplianceData(context.Background(), payload)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.ScansReceived)
+	assert.Equal(t, []int{1, 1, 1}, batches)
+}
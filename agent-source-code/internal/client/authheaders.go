@@ -0,0 +1,176 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/utils"
+)
+
+const (
+	// defaultAuthHeaderCache is how long an auth_headers command's output is
+	// reused when cache_seconds is not set
+	defaultAuthHeaderCache = 5 * time.Minute
+	// authHeaderCommandTimeout bounds one run of the auth_headers command
+	authHeaderCommandTimeout = 30 * time.Second
+)
+
+// reservedHeaders are set by the agent itself and can't be replaced by
+// auth_headers. Keys are canonical, so X-Payload-SHA256 is X-Payload-Sha256.
+var reservedHeaders = map[string]bool{
+	"X-Api-Id":                   true,
+	"X-Api-Key":                  true,
+	"Host":                       true,
+	"Content-Type":               true,
+	"Content-Length":             true,
+	"Content-Encoding":           true,
+	"Idempotency-Key":            true,
+	"X-Payload-Sha256":           true,
+	"X-Payload-Encryption":       true,
+	"X-Payload-Content-Type":     true,
+	"X-Payload-Content-Encoding": true,
+}
+
+// AuthHeaders adds the configured auth_headers to requests to the server.
+// Fixed headers are validated once; the command is run when its cached
+// output has expired. A nil AuthHeaders adds nothing.
+type AuthHeaders struct {
+	static http.Header
+	argv   []string
+	ttl    time.Duration
+	err    error // Invalid fixed headers; every request fails with it
+	run    func(ctx context.Context, argv []string) ([]byte, error)
+
+	mu      sync.Mutex
+	cached  http.Header
+	expires time.Time
+}
+
+// NewAuthHeaders builds the headers described by cfg, or returns nil when
+// cfg adds none
+func NewAuthHeaders(cfg *models.AuthHeadersConfig) *AuthHeaders {
+	if cfg == nil || (len(cfg.Headers) == 0 && len(cfg.Command) == 0) {
+		return nil
+	}
+	a := &AuthHeaders{static: http.Header{}, argv: cfg.Command, ttl: defaultAuthHeaderCache, run: runAuthHeaderCommand}
+	if cfg.CacheSeconds > 0 {
+		a.ttl = time.Duration(cfg.CacheSeconds) * time.Second
+	}
+	for name, value := range cfg.Headers {
+		if err := checkAuthHeader(name, value); err != nil {
+			a.err = fmt.Errorf("invalid auth_headers: %w", err)
+			break
+		}
+		a.static.Set(name, value)
+	}
+	return a
+}
+
+func runAuthHeaderCommand(ctx context.Context, argv []string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, authHeaderCommandTimeout)
+	defer cancel()
+	return utils.CommandContext(ctx, argv[0], argv[1:]...).Output()
+}
+
+// Apply sets the headers on h. It fails when the fixed headers are invalid
+// or the command fails, so the request isn't sent without them only to be
+// turned away by the proxy.
+func (a *AuthHeaders) Apply(ctx context.Context, h http.Header) error {
+	if a == nil {
+		return nil
+	}
+	if a.err != nil {
+		return a.err
+	}
+	for name, values := range a.static {
+		h[name] = values
+	}
+	if len(a.argv) == 0 {
+		return nil
+	}
+	fromCommand, err := a.commandHeaders(ctx)
+	if err != nil {
+		return err
+	}
+	for name, values := range fromCommand {
+		h[name] = values
+	}
+	return nil
+}
+
+// Invalidate drops the command's cached headers, so the next request runs it
+// again. Called when the server or proxy rejects a request, in case the
+// token expired before cache_seconds did.
+func (a *AuthHeaders) Invalidate() {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	a.expires = time.Time{}
+	a.mu.Unlock()
+}
+
+func (a *AuthHeaders) commandHeaders(ctx context.Context) (http.Header, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cached != nil && time.Now().Before(a.expires) {
+		return a.cached, nil
+	}
+	out, err := a.run(ctx, a.argv)
+	if err != nil {
+		return nil, fmt.Errorf("auth_headers command %s failed: %w", a.argv[0], err)
+	}
+	headers, err := parseHeaderLines(out)
+	if err != nil {
+		return nil, fmt.Errorf("auth_headers command %s: %w", a.argv[0], err)
+	}
+	a.cached, a.expires = headers, time.Now().Add(a.ttl)
+	return headers, nil
+}
+
+// parseHeaderLines parses "Name: value" lines; blank lines are skipped
+func parseHeaderLines(out []byte) (http.Header, error) {
+	headers := http.Header{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("output line is not \"Name: value\"")
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if err := checkAuthHeader(name, value); err != nil {
+			return nil, err
+		}
+		headers.Set(name, value)
+	}
+	if len(headers) == 0 {
+		return nil, fmt.Errorf("printed no headers")
+	}
+	return headers, nil
+}
+
+// checkAuthHeader rejects header names the agent sets itself and names or
+// values that aren't valid in HTTP
+func checkAuthHeader(name, value string) error {
+	if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+		return fmt.Errorf("invalid header name %q", name)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("header %s has a line break in its value", name)
+	}
+	if reservedHeaders[http.CanonicalHeaderKey(name)] {
+		return fmt.Errorf("header %s is set by the agent and can't be replaced", name)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/config"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthHeadersCommandIsCached(t *testing.T) {
+	a := NewAuthHeaders(&models.AuthHeadersConfig{
+		Headers: map[string]string{"cf-access-client-id": "abc.access"},
+		Command: []string{"/usr/local/bin/proxy-token"},
+	})
+	runs := 0
+	a.run = func(context.Context, []string) ([]byte, error) {
+		runs++
+		return []byte("Authorization: Bearer t0ken\n\n"), nil
+	}
+
+	for range 2 {
+		h := http.Header{}
+		require.NoError(t, a.Apply(context.Background(), h))
+		assert.Equal(t, "abc.access", h.Get("CF-Access-Client-Id"))
+		assert.Equal(t, "Bearer t0ken", h.Get("Authorization"))
+	}
+	assert.Equal(t, 1, runs)
+
+	a.Invalidate()
+	require.NoError(t, a.Apply(context.Background(), http.Header{}))
+	assert.Equal(t, 2, runs, "a rejected request makes the next one fetch a new token")
+
+	a.Invalidate()
+	a.run = func(context.Context, []string) ([]byte, error) { return nil, errors.New("exit status 1") }
+	assert.ErrorContains(t, a.Apply(context.Background(), http.Header{}), "proxy-token failed")
+}
+
+func TestAuthHeadersRejected(t *testing.T) {
+	assert.Nil(t, NewAuthHeaders(&models.AuthHeadersConfig{}))
+	assert.NoError(t, (*AuthHeaders)(nil).Apply(context.Background(), http.Header{}))
+
+	for _, name := range []string{"x-api-key", HeaderIdempotencyKey, HeaderPayloadSHA256, HeaderPayloadEncryption, HeaderPayloadContentType} {
+		bad := NewAuthHeaders(&models.AuthHeadersConfig{Headers: map[string]string{name: "other"}})
+		assert.ErrorContains(t, bad.Apply(context.Background(), http.Header{}), "set by the agent", name)
+	}
+
+	for _, out := range []string{"", "Bearer t0ken\n", "Bad Name: x\n", "X-API-ID: other\n", "Idempotency-Key: k\n"} {
+		_, err := parseHeaderLines([]byte(out))
+		assert.Error(t, err, "%q", out)
+	}
+}
+
+func TestAuthHeadersSentWithRequests(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"apiId":"id","apiKey":"key"}`))
+	}))
+	defer srv.Close()
+
+	cfg := config.New()
+	cfg.GetConfig().PatchmonServer = srv.URL
+	cfg.GetConfig().AuthHeaders = &models.AuthHeadersConfig{Headers: map[string]string{"cf-access-client-secret": "s3cret"}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	_, err := New(cfg, logger).Enroll(context.Background(), &models.EnrollRequest{Code: "ABC123"})
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", got.Get("CF-Access-Client-Secret"))
+}
@@ -3,14 +3,23 @@ package client
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 	"patchmon-agent/internal/config"
-	"patchmon-agent/pkg/models"
+	"patchmon-agent/internal/redact"
+	"patchmon-agent/internal/utils"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/sirupsen/logrus"
@@ -22,6 +31,33 @@ type Client struct {
 	config      *models.Config
 	credentials *models.Credentials
 	logger      *logrus.Logger
+	// schemaVersion is the payload schema announced by the server; see
+	// SetSchemaVersion
+	schemaVersion atomic.Int32
+	// redactor masks outgoing payloads; redactErr holds an invalid config,
+	// which fails uploads
+	redactor  *redact.Redactor
+	redactErr error
+	// endpoints routes payload types to other base URLs; see apiURL
+	endpoints map[string]endpointOverride
+	// statuses orders and retries integration status reports
+	statuses *statusTracker
+	// backoff is a pause the server asked for; see checkBackoff
+	backoff atomic.Pointer[backoffState]
+	// authHeaders are the auth_headers added to every request
+	authHeaders *AuthHeaders
+	// relayErr holds an unusable relay_url or relay config, which fails
+	// every request rather than bypassing the relay
+	relayErr error
+	// tlsErr holds an unusable client_tls config, which fails every request
+	// rather than sending it without the client certificate
+	tlsErr error
+	// server names the servers entry this client sends to; payloads limits
+	// what it is sent (nil for everything). servers are the clients payloads
+	// are copied to; see mirror.
+	server   string
+	payloads map[string]bool
+	servers  []*Client
 }
 
 // truncateResponse truncates a response string to prevent leaking sensitive data in logs
@@ -34,6 +70,21 @@ func truncateResponse(s string, maxLen int) string {
 	return s[:maxLen] + "... (truncated)"
 }
 
+// Report integrity headers sent with every update
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	HeaderPayloadSHA256  = "X-Payload-SHA256"
+)
+
+// newIdempotencyKey returns a random 128-bit hex key identifying one report submission
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate idempotency key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
 // IsSkipSSLVerifyEnvSet returns true if PATCHMON_SKIP_SSL_VERIFY is set to "true" or "1"
 func IsSkipSSLVerifyEnvSet() bool {
 	v := strings.ToLower(strings.TrimSpace(os.Getenv("PATCHMON_SKIP_SSL_VERIFY")))
@@ -42,7 +93,35 @@ func IsSkipSSLVerifyEnvSet() bool {
 
 // New creates a new HTTP client
 func New(configMgr *config.Manager, logger *logrus.Logger) *Client {
-	client := resty.New()
+	// Configure TLS based on skip_ssl_verify (config or PATCHMON_SKIP_SSL_VERIFY env)
+	cfg := configMgr.GetConfig()
+	skipVerify := cfg.SkipSSLVerify || IsSkipSSLVerifyEnvSet()
+	if skipVerify {
+		// Operator-gated insecure TLS for lab/air-gapped deployments.
+		logger.Warn("TLS certificate verification disabled - use only with trusted self-signed or internal CA certificates")
+	}
+
+	// Connections are pooled across all clients; see sharedTransport. A relay
+	// or client_tls needs its own client certificate, or a unix socket dialer.
+	transport := sharedTransport(skipVerify)
+	var relayErr, tlsErr error
+	if cfg.RelayURL != "" {
+		var relay *http.Transport
+		if relay, relayErr = relayTransport(cfg, skipVerify); relayErr != nil {
+			logger.WithError(relayErr).Error("Requests to the relay will fail until relay_url and relay are fixed")
+		} else {
+			transport = dedicatedTransport(relay)
+		}
+	} else if cfg.ClientTLS != nil {
+		var tlsConfig *tls.Config
+		if tlsConfig, tlsErr = ServerTLSConfig(cfg, skipVerify); tlsErr != nil {
+			logger.WithError(tlsErr).Error("Requests to the server will fail until client_tls is fixed")
+		} else {
+			transport = dedicatedTransport(newTransport(skipVerify))
+			transport.TLSClientConfig = tlsConfig
+		}
+	}
+	client := resty.NewWithClient(&http.Client{Transport: transport})
 	client.SetTimeout(30 * time.Second)
 	client.SetRetryCount(3)
 	client.SetRetryWaitTime(2 * time.Second)
@@ -50,15 +129,38 @@ func New(configMgr *config.Manager, logger *logrus.Logger) *Client {
 	// Configure Resty to use our logger
 	client.SetLogger(logger)
 
-	// Configure TLS based on skip_ssl_verify (config or PATCHMON_SKIP_SSL_VERIFY env)
-	cfg := configMgr.GetConfig()
-	skipVerify := cfg.SkipSSLVerify || IsSkipSSLVerifyEnvSet()
-	if skipVerify {
-		// Operator-gated insecure TLS for lab/air-gapped deployments.
-		logger.Warn("TLS certificate verification disabled - use only with trusted self-signed or internal CA certificates")
-		client.SetTLSClientConfig(&tls.Config{
-			InsecureSkipVerify: true,
-		})
+	redactor, redactErr := redact.New(cfg.Redaction)
+	if redactErr != nil {
+		logger.WithError(redactErr).Error("Invalid redaction config, uploads will fail until it is fixed")
+	}
+
+	authHeaders := NewAuthHeaders(cfg.AuthHeaders)
+	if authHeaders != nil && authHeaders.err != nil {
+		logger.WithError(authHeaders.err).Error("Requests to the server will fail until auth_headers is fixed")
+	}
+	client.OnBeforeRequest(func(_ *resty.Client, req *resty.Request) error {
+		return authHeaders.Apply(req.Context(), req.Header)
+	})
+	client.OnAfterResponse(func(_ *resty.Client, resp *resty.Response) error {
+		if resp.StatusCode() == http.StatusUnauthorized || resp.StatusCode() == http.StatusForbidden {
+			authHeaders.Invalidate()
+		}
+		return nil
+	})
+
+	endpoints, unknown := parseEndpoints(cfg.Endpoints)
+	if cfg.RelayURL != "" && len(endpoints) > 0 {
+		// Everything goes through the relay; its own config decides where
+		logger.Warn("Ignoring endpoints config, relay_url is set")
+		endpoints, unknown = nil, nil
+	}
+	if len(unknown) > 0 {
+		logger.WithFields(logrus.Fields{"unknown": unknown, "known": EndpointNames}).Warn("Ignoring unknown endpoints config keys")
+	}
+	for name, override := range endpoints {
+		if override.err != nil {
+			logger.WithError(override.err).Errorf("Invalid %s endpoint, those uploads will fail until it is fixed", name)
+		}
 	}
 
 	return &Client{
@@ -66,25 +168,54 @@ func New(configMgr *config.Manager, logger *logrus.Logger) *Client {
 		config:      cfg,
 		credentials: configMgr.GetCredentials(),
 		logger:      logger,
+		redactor:    redactor,
+		redactErr:   redactErr,
+		endpoints:   endpoints,
+		statuses:    integrationStatuses,
+		authHeaders: authHeaders,
+		relayErr:    relayErr,
+		tlsErr:      tlsErr,
 	}
 }
 
-// Ping sends a ping request to the server
-func (c *Client) Ping(ctx context.Context) (*models.PingResponse, error) {
-	url := fmt.Sprintf("%s/api/%s/hosts/ping", c.config.PatchmonServer, c.config.APIVersion)
+// AuthHeaders returns the auth_headers this client adds, for requests to the
+// server made without it (the WebSocket, agent downloads)
+func (c *Client) AuthHeaders() *AuthHeaders {
+	return c.authHeaders
+}
+
+// Ping sends a ping request to the server. payload is optional.
+func (c *Client) Ping(ctx context.Context, payload *models.PingRequest) (*models.PingResponse, error) {
+	defer c.mirror(EndpointPing, func(s *Client) error {
+		_, err := s.Ping(ctx, payload)
+		return err
+	})()
+
+	url, err := c.apiURL(EndpointPing, "hosts/ping")
+	if err != nil {
+		return nil, err
+	}
 
 	c.logger.WithFields(logrus.Fields{
 		"url":    url,
 		"method": "POST",
 	}).Debug("Sending ping request to server")
 
-	resp, err := c.client.R().
+	req := c.client.R().
 		SetContext(ctx).
 		SetHeader("Content-Type", "application/json").
 		SetHeader("X-API-ID", c.credentials.APIID).
 		SetHeader("X-API-KEY", c.credentials.APIKey).
-		SetResult(&models.PingResponse{}).
-		Post(url)
+		SetResult(&models.PingResponse{})
+	if payload != nil {
+		// Schema 1 servers take a ping without a body
+		if p := payload.ForSchema(c.SchemaVersion()); p != nil {
+			if err := c.setJSONPayload(req, p); err != nil {
+				return nil, err
+			}
+		}
+	}
+	resp, err := req.Post(url)
 
 	if err != nil {
 		return nil, fmt.Errorf("ping request failed: %w", err)
@@ -92,7 +223,7 @@ func (c *Client) Ping(ctx context.Context) (*models.PingResponse, error) {
 
 	if resp.StatusCode() != 200 {
 		c.logger.WithField("response", resp.String()).Debug("Full error response from ping request")
-		return nil, fmt.Errorf("ping request failed with status %d: %s", resp.StatusCode(), truncateResponse(resp.String(), 200))
+		return nil, c.apiError("ping request", resp)
 	}
 
 	result, ok := resp.Result().(*models.PingResponse)
@@ -103,23 +234,77 @@ func (c *Client) Ping(ctx context.Context) (*models.PingResponse, error) {
 	return result, nil
 }
 
+// GetClockSkew measures the local clock's offset from the server using the
+// Date header of the unauthenticated /health endpoint. Positive means the local
+// clock is ahead.
+func (c *Client) GetClockSkew(ctx context.Context) (time.Duration, error) {
+	url := fmt.Sprintf("%s/health", strings.TrimRight(ServerURL(c.config), "/"))
+
+	c.logger.WithFields(logrus.Fields{
+		"url":    url,
+		"method": "GET",
+	}).Debug("Measuring clock skew against server")
+
+	resp, err := c.client.R().
+		SetContext(ctx).
+		Get(url)
+	if err != nil {
+		return 0, fmt.Errorf("clock skew request failed: %w", err)
+	}
+
+	// Resty stamps the attempt that got this response once auth_headers and
+	// the other hooks have run, so neither they nor earlier retries widen the
+	// window
+	return utils.CalculateClockSkew(resp.Header().Get("Date"), resp.Request.Time, resp.ReceivedAt())
+}
+
 // SendUpdate sends package update information to the server
 func (c *Client) SendUpdate(ctx context.Context, payload *models.ReportPayload) (*models.UpdateResponse, error) {
-	url := fmt.Sprintf("%s/api/%s/hosts/update", c.config.PatchmonServer, c.config.APIVersion)
+	defer c.mirror(EndpointReport, func(s *Client) error {
+		_, err := s.SendUpdate(ctx, payload)
+		return err
+	})()
+
+	url, err := c.apiURL(EndpointReport, "hosts/update")
+	if err != nil {
+		return nil, err
+	}
+
+	// Marshal up front so the hash covers exactly the bytes on the wire. Resty
+	// re-sends the same body and headers on retry, so a retried submission
+	// carries the same idempotency key and the server can drop the duplicate.
+	body, err := json.Marshal(payload.ForSchema(c.SchemaVersion()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal update payload: %w", err)
+	}
+	if body, err = c.RedactJSON(body); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	idempotencyKey, err := newIdempotencyKey()
+	if err != nil {
+		return nil, err
+	}
 
 	c.logger.WithFields(logrus.Fields{
-		"url":    url,
-		"method": "POST",
+		"url":             url,
+		"method":          "POST",
+		"payload_hash":    payloadHash,
+		"idempotency_key": idempotencyKey,
 	}).Debug("Sending update to server")
 
-	resp, err := c.client.R().
+	req := c.client.R().
 		SetContext(ctx).
-		SetHeader("Content-Type", "application/json").
 		SetHeader("X-API-ID", c.credentials.APIID).
 		SetHeader("X-API-KEY", c.credentials.APIKey).
-		SetBody(payload).
-		SetResult(&models.UpdateResponse{}).
-		Post(url)
+		SetHeader(HeaderIdempotencyKey, idempotencyKey).
+		SetHeader(HeaderPayloadSHA256, payloadHash).
+		SetResult(&models.UpdateResponse{})
+	if err := c.setPayload(req, body, "application/json", ""); err != nil {
+		return nil, err
+	}
+	resp, err := req.Post(url)
 
 	if err != nil {
 		return nil, fmt.Errorf("update request failed: %w", err)
@@ -127,7 +312,10 @@ func (c *Client) SendUpdate(ctx context.Context, payload *models.ReportPayload)
 
 	if resp.StatusCode() != 200 {
 		c.logger.WithField("response", resp.String()).Debug("Full error response from update request")
-		return nil, fmt.Errorf("update request failed with status %d: %s", resp.StatusCode(), truncateResponse(resp.String(), 200))
+		if conflict := registrationConflict(resp.StatusCode(), resp.Body()); conflict != nil {
+			return nil, conflict
+		}
+		return nil, c.apiError("update request", resp)
 	}
 
 	result, ok := resp.Result().(*models.UpdateResponse)
@@ -135,12 +323,23 @@ func (c *Client) SendUpdate(ctx context.Context, payload *models.ReportPayload)
 		return nil, fmt.Errorf("invalid response format")
 	}
 
+	// Older servers don't echo the hash; only a mismatch is worth flagging
+	if result.PayloadHash != "" && result.PayloadHash != payloadHash {
+		c.logger.WithFields(logrus.Fields{
+			"sent_hash":  payloadHash,
+			"acked_hash": result.PayloadHash,
+		}).Warn("Server acknowledged a different report payload than the one sent")
+	}
+
 	return result, nil
 }
 
 // GetUpdateInterval gets the current update interval from server
 func (c *Client) GetUpdateInterval(ctx context.Context) (*models.UpdateIntervalResponse, error) {
-	url := fmt.Sprintf("%s/api/%s/settings/update-interval", c.config.PatchmonServer, c.config.APIVersion)
+	url, err := c.apiURL(EndpointSettings, "settings/update-interval")
+	if err != nil {
+		return nil, err
+	}
 
 	c.logger.Debug("Getting update interval from server")
 
@@ -158,7 +357,7 @@ func (c *Client) GetUpdateInterval(ctx context.Context) (*models.UpdateIntervalR
 
 	if resp.StatusCode() != 200 {
 		c.logger.WithField("response", resp.String()).Debug("Full error response from update interval request")
-		return nil, fmt.Errorf("update interval request failed with status %d: %s", resp.StatusCode(), truncateResponse(resp.String(), 200))
+		return nil, c.apiError("update interval request", resp)
 	}
 
 	result, ok := resp.Result().(*models.UpdateIntervalResponse)
@@ -171,21 +370,30 @@ func (c *Client) GetUpdateInterval(ctx context.Context) (*models.UpdateIntervalR
 
 // SendDockerData sends Docker integration data to the server
 func (c *Client) SendDockerData(ctx context.Context, payload *models.DockerPayload) (*models.DockerResponse, error) {
-	url := fmt.Sprintf("%s/api/%s/integrations/docker", c.config.PatchmonServer, c.config.APIVersion)
+	defer c.mirror(EndpointDocker, func(s *Client) error {
+		_, err := s.SendDockerData(ctx, payload)
+		return err
+	})()
+
+	url, err := c.apiURL(EndpointDocker, "integrations/docker")
+	if err != nil {
+		return nil, err
+	}
 
 	c.logger.WithFields(logrus.Fields{
 		"url":    url,
 		"method": "POST",
 	}).Debug("Sending Docker data to server")
 
-	resp, err := c.client.R().
+	req := c.client.R().
 		SetContext(ctx).
-		SetHeader("Content-Type", "application/json").
 		SetHeader("X-API-ID", c.credentials.APIID).
 		SetHeader("X-API-KEY", c.credentials.APIKey).
-		SetBody(payload).
-		SetResult(&models.DockerResponse{}).
-		Post(url)
+		SetResult(&models.DockerResponse{})
+	if err := c.setJSONPayload(req, payload.ForSchema(c.SchemaVersion())); err != nil {
+		return nil, err
+	}
+	resp, err := req.Post(url)
 
 	if err != nil {
 		return nil, fmt.Errorf("docker data request failed: %w", err)
@@ -193,7 +401,7 @@ func (c *Client) SendDockerData(ctx context.Context, payload *models.DockerPaylo
 
 	if resp.StatusCode() != 200 {
 		c.logger.WithField("response", resp.String()).Debug("Full error response from docker data request")
-		return nil, fmt.Errorf("docker data request failed with status %d: %s", resp.StatusCode(), truncateResponse(resp.String(), 200))
+		return nil, c.apiError("docker data request", resp)
 	}
 
 	result, ok := resp.Result().(*models.DockerResponse)
@@ -204,9 +412,317 @@ func (c *Client) SendDockerData(ctx context.Context, payload *models.DockerPaylo
 	return result, nil
 }
 
+// SendDockerContainerUpdate sends the outcome of a docker_update_container
+// command to the server
+func (c *Client) SendDockerContainerUpdate(ctx context.Context, payload *models.DockerContainerUpdatePayload) (*models.DockerContainerUpdateResponse, error) {
+	url, err := c.apiURL(EndpointDocker, "integrations/docker/container-update")
+	if err != nil {
+		return nil, err
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"url":       url,
+		"method":    "POST",
+		"container": payload.ContainerName,
+		"status":    payload.Status,
+	}).Debug("Sending Docker container update result to server")
+
+	req := c.client.R().
+		SetContext(ctx).
+		SetHeader("X-API-ID", c.credentials.APIID).
+		SetHeader("X-API-KEY", c.credentials.APIKey).
+		SetResult(&models.DockerContainerUpdateResponse{})
+	if err := c.setJSONPayload(req, payload.ForSchema(c.SchemaVersion())); err != nil {
+		return nil, err
+	}
+	resp, err := req.Post(url)
+
+	if err != nil {
+		return nil, fmt.Errorf("docker container update request failed: %w", err)
+	}
+
+	if resp.StatusCode() != 200 {
+		c.logger.WithField("response", resp.String()).Debug("Full error response from docker container update request")
+		return nil, c.apiError("docker container update request", resp)
+	}
+
+	result, ok := resp.Result().(*models.DockerContainerUpdateResponse)
+	if !ok {
+		return nil, fmt.Errorf("invalid response format")
+	}
+
+	return result, nil
+}
+
+// SendDockerPrune sends the outcome of a docker_prune command, or its dry
+// run, to the server
+func (c *Client) SendDockerPrune(ctx context.Context, payload *models.DockerPrunePayload) (*models.DockerPruneResponse, error) {
+	url, err := c.apiURL(EndpointDocker, "integrations/docker/prune")
+	if err != nil {
+		return nil, err
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"url":     url,
+		"method":  "POST",
+		"dry_run": payload.DryRun,
+	}).Debug("Sending Docker prune result to server")
+
+	req := c.client.R().
+		SetContext(ctx).
+		SetHeader("X-API-ID", c.credentials.APIID).
+		SetHeader("X-API-KEY", c.credentials.APIKey).
+		SetResult(&models.DockerPruneResponse{})
+	if err := c.setJSONPayload(req, payload.ForSchema(c.SchemaVersion())); err != nil {
+		return nil, err
+	}
+	resp, err := req.Post(url)
+
+	if err != nil {
+		return nil, fmt.Errorf("docker prune request failed: %w", err)
+	}
+
+	if resp.StatusCode() != 200 {
+		c.logger.WithField("response", resp.String()).Debug("Full error response from docker prune request")
+		return nil, c.apiError("docker prune request", resp)
+	}
+
+	result, ok := resp.Result().(*models.DockerPruneResponse)
+	if !ok {
+		return nil, fmt.Errorf("invalid response format")
+	}
+
+	return result, nil
+}
+
+// SendImageSBOM uploads a gzip-compressed SBOM for a Docker image. Image metadata
+// travels as query parameters so the body can stay an opaque compressed blob.
+func (c *Client) SendImageSBOM(ctx context.Context, info *models.ImageSBOMInfo, gzBody []byte) error {
+	// The other servers send from the caller's metadata and body, which this
+	// client leaves alone while it redacts its own copies
+	orig := *info
+	defer c.mirror(EndpointDocker, func(s *Client) error {
+		info := orig
+		return s.SendImageSBOM(ctx, &info, gzBody)
+	})()
+
+	url, err := c.apiURL(EndpointDocker, "integrations/docker/sbom")
+	if err != nil {
+		return err
+	}
+	if err := c.requireSchema(2, "image SBOM upload"); err != nil {
+		return err
+	}
+	sent := info.ForSchema(c.SchemaVersion())
+	if err := c.redactor.Value(sent); err != nil {
+		return fmt.Errorf("failed to redact SBOM metadata: %w", err)
+	}
+	body, err := c.redactGzipJSON(gzBody)
+	if err != nil {
+		return err
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"url":        url,
+		"method":     "POST",
+		"image_id":   sent.ImageID,
+		"size_bytes": len(body),
+	}).Debug("Uploading image SBOM to server")
+
+	req := c.client.R().
+		SetContext(ctx).
+		SetHeader("X-API-ID", c.credentials.APIID).
+		SetHeader("X-API-KEY", c.credentials.APIKey).
+		SetQueryParams(map[string]string{
+			"image_id":       sent.ImageID,
+			"repository":     sent.Repository,
+			"tag":            sent.Tag,
+			"digest":         sent.Digest,
+			"format":         sent.Format,
+			"tool":           sent.Tool,
+			"hostname":       sent.Hostname,
+			"machine_id":     sent.MachineID,
+			"schema_version": strconv.Itoa(sent.SchemaVersion),
+		})
+	if err := c.setPayload(req, body, "application/vnd.cyclonedx+json", "gzip"); err != nil {
+		return err
+	}
+	resp, err := req.Post(url)
+
+	if err != nil {
+		return fmt.Errorf("sbom upload failed: %w", err)
+	}
+
+	if resp.StatusCode() != 200 && resp.StatusCode() != 201 {
+		c.logger.WithField("response", resp.String()).Debug("Full error response from sbom upload")
+		return c.apiError("sbom upload", resp)
+	}
+
+	return nil
+}
+
+// SendLanguagePackages sends language package inventory (pip, npm, gem) to the server
+func (c *Client) SendLanguagePackages(ctx context.Context, payload *models.LanguagePackagesPayload) (*models.LanguagePackagesResponse, error) {
+	defer c.mirror(EndpointLanguagePackages, func(s *Client) error {
+		_, err := s.SendLanguagePackages(ctx, payload)
+		return err
+	})()
+	return postIntegration[models.LanguagePackagesResponse](ctx, c, EndpointLanguagePackages, "integrations/language-packages", "language packages", payload.ForSchema(c.SchemaVersion()))
+}
+
+// SendUserAccounts sends the local user account and sudoers summary to the server
+func (c *Client) SendUserAccounts(ctx context.Context, payload *models.UserAccountsPayload) (*models.UserAccountsResponse, error) {
+	defer c.mirror(EndpointUserAccounts, func(s *Client) error {
+		_, err := s.SendUserAccounts(ctx, payload)
+		return err
+	})()
+	return postIntegration[models.UserAccountsResponse](ctx, c, EndpointUserAccounts, "integrations/user-accounts", "user accounts", payload.ForSchema(c.SchemaVersion()))
+}
+
+// SendTLSCertificates sends the certificate inventory to the server
+func (c *Client) SendTLSCertificates(ctx context.Context, payload *models.TLSCertificatesPayload) (*models.TLSCertificatesResponse, error) {
+	defer c.mirror(EndpointTLSCertificates, func(s *Client) error {
+		_, err := s.SendTLSCertificates(ctx, payload)
+		return err
+	})()
+	return postIntegration[models.TLSCertificatesResponse](ctx, c, EndpointTLSCertificates, "integrations/tls-certificates", "TLS certificates", payload.ForSchema(c.SchemaVersion()))
+}
+
+// SendScheduledTasks sends the cron job and systemd timer inventory to the server
+func (c *Client) SendScheduledTasks(ctx context.Context, payload *models.ScheduledTasksPayload) (*models.ScheduledTasksResponse, error) {
+	defer c.mirror(EndpointScheduledTasks, func(s *Client) error {
+		_, err := s.SendScheduledTasks(ctx, payload)
+		return err
+	})()
+	return postIntegration[models.ScheduledTasksResponse](ctx, c, EndpointScheduledTasks, "integrations/scheduled-tasks", "scheduled tasks", payload.ForSchema(c.SchemaVersion()))
+}
+
+// SendJails sends the FreeBSD jail inventory to the server
+func (c *Client) SendJails(ctx context.Context, payload *models.JailsPayload) (*models.JailsResponse, error) {
+	defer c.mirror(EndpointJails, func(s *Client) error {
+		_, err := s.SendJails(ctx, payload)
+		return err
+	})()
+	return postIntegration[models.JailsResponse](ctx, c, EndpointJails, "integrations/jails", "jails", payload.ForSchema(c.SchemaVersion()))
+}
+
+// SendNspawn sends the systemd-nspawn machine inventory to the server
+func (c *Client) SendNspawn(ctx context.Context, payload *models.NspawnPayload) (*models.NspawnResponse, error) {
+	defer c.mirror(EndpointNspawn, func(s *Client) error {
+		_, err := s.SendNspawn(ctx, payload)
+		return err
+	})()
+	return postIntegration[models.NspawnResponse](ctx, c, EndpointNspawn, "integrations/nspawn", "nspawn", payload.ForSchema(c.SchemaVersion()))
+}
+
+// SendProxmoxData sends the Proxmox VE guest, cluster and update inventory to
+// the server
+func (c *Client) SendProxmoxData(ctx context.Context, payload *models.ProxmoxPayload) (*models.ProxmoxResponse, error) {
+	defer c.mirror(EndpointProxmox, func(s *Client) error {
+		_, err := s.SendProxmoxData(ctx, payload)
+		return err
+	})()
+	return postIntegration[models.ProxmoxResponse](ctx, c, EndpointProxmox, "integrations/proxmox", "proxmox", payload.ForSchema(c.SchemaVersion()))
+}
+
+// SendKubernetesData sends the state of the Kubernetes node to the server
+func (c *Client) SendKubernetesData(ctx context.Context, payload *models.KubernetesPayload) (*models.KubernetesResponse, error) {
+	defer c.mirror(EndpointKubernetes, func(s *Client) error {
+		_, err := s.SendKubernetesData(ctx, payload)
+		return err
+	})()
+	return postIntegration[models.KubernetesResponse](ctx, c, EndpointKubernetes, "integrations/kubernetes", "kubernetes", payload.ForSchema(c.SchemaVersion()))
+}
+
+// postIntegration posts an integration payload, rendered for the server's
+// schema, to an endpoint added in payload schema 2 and returns the server's
+// response. what names the payload in errors.
+func postIntegration[R any](ctx context.Context, c *Client, endpoint, path, what string, payload interface{}) (*R, error) {
+	url, err := c.apiURL(endpoint, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.requireSchema(2, what+" upload"); err != nil {
+		return nil, err
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"url":     url,
+		"method":  "POST",
+		"payload": what,
+	}).Debug("Sending integration data to server")
+
+	req := c.client.R().
+		SetContext(ctx).
+		SetHeader("X-API-ID", c.credentials.APIID).
+		SetHeader("X-API-KEY", c.credentials.APIKey).
+		SetResult(new(R))
+	if err := c.setJSONPayload(req, payload); err != nil {
+		return nil, err
+	}
+	resp, err := req.Post(url)
+	if err != nil {
+		return nil, fmt.Errorf("%s request failed: %w", what, err)
+	}
+
+	if resp.StatusCode() != 200 {
+		c.logger.WithField("response", resp.String()).Debugf("Full error response from %s request", what)
+		return nil, c.apiError(what+" request", resp)
+	}
+
+	result, ok := resp.Result().(*R)
+	if !ok {
+		return nil, fmt.Errorf("invalid response format")
+	}
+	return result, nil
+}
+
+// SendHardwareInventory uploads the deep hardware inventory collected for a
+// hardware_inventory command
+func (c *Client) SendHardwareInventory(ctx context.Context, payload *models.HardwareInventoryPayload) (*models.HardwareInventoryResponse, error) {
+	url, err := c.apiURL(EndpointReport, "hosts/hardware-inventory")
+	if err != nil {
+		return nil, err
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"url":    url,
+		"method": "POST",
+	}).Debug("Sending hardware inventory to server")
+
+	req := c.client.R().
+		SetContext(ctx).
+		SetHeader("X-API-ID", c.credentials.APIID).
+		SetHeader("X-API-KEY", c.credentials.APIKey).
+		SetResult(&models.HardwareInventoryResponse{})
+	if err := c.setJSONPayload(req, payload.ForSchema(c.SchemaVersion())); err != nil {
+		return nil, err
+	}
+	resp, err := req.Post(url)
+
+	if err != nil {
+		return nil, fmt.Errorf("hardware inventory request failed: %w", err)
+	}
+
+	if resp.StatusCode() != 200 {
+		c.logger.WithField("response", resp.String()).Debug("Full error response from hardware inventory request")
+		return nil, c.apiError("hardware inventory request", resp)
+	}
+
+	result, ok := resp.Result().(*models.HardwareInventoryResponse)
+	if !ok {
+		return nil, fmt.Errorf("invalid response format")
+	}
+
+	return result, nil
+}
+
 // GetIntegrationStatus gets the current integration status from server
 func (c *Client) GetIntegrationStatus(ctx context.Context) (*models.IntegrationStatusResponse, error) {
-	url := fmt.Sprintf("%s/api/%s/hosts/integrations", c.config.PatchmonServer, c.config.APIVersion)
+	url, err := c.apiURL(EndpointIntegrations, "hosts/integrations")
+	if err != nil {
+		return nil, err
+	}
 
 	c.logger.Debug("Getting integration status from server")
 
@@ -224,7 +740,7 @@ func (c *Client) GetIntegrationStatus(ctx context.Context) (*models.IntegrationS
 
 	if resp.StatusCode() != 200 {
 		c.logger.WithField("response", resp.String()).Debug("Full error response from integration status request")
-		return nil, fmt.Errorf("integration status request failed with status %d: %s", resp.StatusCode(), truncateResponse(resp.String(), 200))
+		return nil, c.apiError("integration status request", resp)
 	}
 
 	result, ok := resp.Result().(*models.IntegrationStatusResponse)
@@ -235,33 +751,122 @@ func (c *Client) GetIntegrationStatus(ctx context.Context) (*models.IntegrationS
 	return result, nil
 }
 
-// SendIntegrationSetupStatus sends the setup status of an integration to the server
-func (c *Client) SendIntegrationSetupStatus(ctx context.Context, status *models.IntegrationSetupStatus) error {
-	url := fmt.Sprintf("%s/api/%s/hosts/integration-status", c.config.PatchmonServer, c.config.APIVersion)
+// SendHostnameChange notifies the server that this host's reported hostname changed
+func (c *Client) SendHostnameChange(ctx context.Context, event *models.HostnameChangeEvent) error {
+	defer c.mirror(EndpointReport, func(s *Client) error {
+		return s.SendHostnameChange(ctx, event)
+	})()
+
+	url, err := c.apiURL(EndpointReport, "hosts/hostname-change")
+	if err != nil {
+		return err
+	}
+	if err := c.requireSchema(2, "hostname change"); err != nil {
+		return err
+	}
 
 	c.logger.WithFields(logrus.Fields{
-		"integration": status.Integration,
-		"enabled":     status.Enabled,
-		"status":      status.Status,
-	}).Info("Sending integration setup status to server")
+		"url":    url,
+		"method": "POST",
+	}).Debug("Sending hostname change to server")
 
-	resp, err := c.client.R().
+	req := c.client.R().
 		SetContext(ctx).
-		SetHeader("Content-Type", "application/json").
 		SetHeader("X-API-ID", c.credentials.APIID).
-		SetHeader("X-API-KEY", c.credentials.APIKey).
-		SetBody(status).
-		Post(url)
+		SetHeader("X-API-KEY", c.credentials.APIKey)
+	if err := c.setJSONPayload(req, event.ForSchema(c.SchemaVersion())); err != nil {
+		return err
+	}
+	resp, err := req.Post(url)
 
 	if err != nil {
-		return fmt.Errorf("integration setup status request failed: %w", err)
+		return fmt.Errorf("hostname change request failed: %w", err)
 	}
 
 	if resp.StatusCode() != 200 {
-		return fmt.Errorf("integration setup status request failed with status %d", resp.StatusCode())
+		c.logger.WithField("response", resp.String()).Debug("Full error response from hostname change request")
+		return c.apiError("hostname change request", resp)
+	}
+
+	return nil
+}
+
+// SendPackageTransaction sends a package manager transaction reported by the apt/dnf hooks
+func (c *Client) SendPackageTransaction(ctx context.Context, payload *models.PackageTransactionPayload) error {
+	defer c.mirror(EndpointPackageTransactions, func(s *Client) error {
+		return s.SendPackageTransaction(ctx, payload)
+	})()
+
+	url, err := c.apiURL(EndpointPackageTransactions, "hosts/package-transactions")
+	if err != nil {
+		return err
+	}
+	if err := c.requireSchema(2, "package transaction"); err != nil {
+		return err
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"url":      url,
+		"method":   "POST",
+		"manager":  payload.Transaction.Manager,
+		"packages": len(payload.Transaction.Packages),
+	}).Debug("Sending package transaction to server")
+
+	req := c.client.R().
+		SetContext(ctx).
+		SetHeader("X-API-ID", c.credentials.APIID).
+		SetHeader("X-API-KEY", c.credentials.APIKey)
+	if err := c.setJSONPayload(req, payload.ForSchema(c.SchemaVersion())); err != nil {
+		return err
+	}
+	resp, err := req.Post(url)
+
+	if err != nil {
+		return fmt.Errorf("package transaction request failed: %w", err)
+	}
+
+	if resp.StatusCode() != 200 && resp.StatusCode() != 201 {
+		c.logger.WithField("response", resp.String()).Debug("Full error response from package transaction request")
+		return c.apiError("package transaction request", resp)
+	}
+
+	return nil
+}
+
+// SendPackageUpdate sends the outcome of a package_update command, or its
+// dry run, to the server
+func (c *Client) SendPackageUpdate(ctx context.Context, payload *models.PackageUpdatePayload) error {
+	url, err := c.apiURL(EndpointPatching, "patching/package-updates")
+	if err != nil {
+		return err
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"url":      url,
+		"method":   "POST",
+		"status":   payload.Result.Status,
+		"dry_run":  payload.Result.DryRun,
+		"packages": len(payload.Result.Packages),
+	}).Debug("Sending package update result to server")
+
+	req := c.client.R().
+		SetContext(ctx).
+		SetHeader("X-API-ID", c.credentials.APIID).
+		SetHeader("X-API-KEY", c.credentials.APIKey)
+	if err := c.setJSONPayload(req, payload.ForSchema(c.SchemaVersion())); err != nil {
+		return err
+	}
+	resp, err := req.Post(url)
+
+	if err != nil {
+		return fmt.Errorf("package update request failed: %w", err)
+	}
+
+	if resp.StatusCode() != 200 && resp.StatusCode() != 201 {
+		c.logger.WithField("response", resp.String()).Debug("Full error response from package update request")
+		return c.apiError("package update request", resp)
 	}
 
-	c.logger.Info("Integration setup status sent successfully")
 	return nil
 }
 
@@ -278,9 +883,45 @@ func (c *Client) SendDockerStatusEvent(event *models.DockerStatusEvent) error {
 	return nil
 }
 
-// SendComplianceData sends compliance scan data to the server
+// SendComplianceData sends compliance scan data to the server. If the server
+// refuses it as too large, the scans are sent in halves.
 func (c *Client) SendComplianceData(ctx context.Context, payload *models.CompliancePayload) (*models.ComplianceResponse, error) {
-	url := fmt.Sprintf("%s/api/%s/compliance/scans", c.config.PatchmonServer, c.config.APIVersion)
+	defer c.mirror(EndpointCompliance, func(s *Client) error {
+		_, err := s.SendComplianceData(ctx, payload)
+		return err
+	})()
+	return c.sendComplianceScans(ctx, payload)
+}
+
+// sendComplianceScans sends payload, splitting it while the server finds it
+// too large
+func (c *Client) sendComplianceScans(ctx context.Context, payload *models.CompliancePayload) (*models.ComplianceResponse, error) {
+	result, err := c.sendComplianceData(ctx, payload)
+	if ErrorCode(err) != models.ErrPayloadTooLarge || len(payload.Scans) < 2 {
+		return result, err
+	}
+
+	c.logger.WithField("scans", len(payload.Scans)).Info("Compliance payload too large, splitting it")
+	half := len(payload.Scans) / 2
+	first, second := *payload, *payload
+	first.Scans, second.Scans = payload.Scans[:half], payload.Scans[half:]
+	firstResult, err := c.sendComplianceScans(ctx, &first)
+	if err != nil {
+		return nil, err
+	}
+	secondResult, err := c.sendComplianceScans(ctx, &second)
+	if err != nil {
+		return nil, err
+	}
+	secondResult.ScansReceived += firstResult.ScansReceived
+	return secondResult, nil
+}
+
+func (c *Client) sendComplianceData(ctx context.Context, payload *models.CompliancePayload) (*models.ComplianceResponse, error) {
+	url, err := c.apiURL(EndpointCompliance, "compliance/scans")
+	if err != nil {
+		return nil, err
+	}
 
 	c.logger.WithFields(logrus.Fields{
 		"url":    url,
@@ -288,14 +929,15 @@ func (c *Client) SendComplianceData(ctx context.Context, payload *models.Complia
 		"scans":  len(payload.Scans),
 	}).Debug("Sending compliance data to server")
 
-	resp, err := c.client.R().
+	req := c.client.R().
 		SetContext(ctx).
-		SetHeader("Content-Type", "application/json").
 		SetHeader("X-API-ID", c.credentials.APIID).
 		SetHeader("X-API-KEY", c.credentials.APIKey).
-		SetBody(payload).
-		SetResult(&models.ComplianceResponse{}).
-		Post(url)
+		SetResult(&models.ComplianceResponse{})
+	if err := c.setJSONPayload(req, payload.ForSchema(c.SchemaVersion())); err != nil {
+		return nil, err
+	}
+	resp, err := req.Post(url)
 
 	if err != nil {
 		return nil, fmt.Errorf("compliance data request failed: %w", err)
@@ -303,7 +945,7 @@ func (c *Client) SendComplianceData(ctx context.Context, payload *models.Complia
 
 	if resp.StatusCode() != 200 {
 		c.logger.WithField("response", resp.String()).Debug("Full error response from compliance data request")
-		return nil, fmt.Errorf("compliance data request failed with status %d: %s", resp.StatusCode(), truncateResponse(resp.String(), 200))
+		return nil, c.apiError("compliance data request", resp)
 	}
 
 	result, ok := resp.Result().(*models.ComplianceResponse)
@@ -314,6 +956,109 @@ func (c *Client) SendComplianceData(ctx context.Context, payload *models.Complia
 	return result, nil
 }
 
+// SendComplianceCKL uploads a gzip-compressed STIG Viewer checklist. It
+// answers a compliance_ckl_export, so only this client's server gets it.
+func (c *Client) SendComplianceCKL(ctx context.Context, info *models.ComplianceCKLInfo, gzBody []byte) error {
+	url, err := c.apiURL(EndpointCompliance, "compliance/ckl")
+	if err != nil {
+		return err
+	}
+	if err := c.redactor.Value(info); err != nil {
+		return fmt.Errorf("failed to redact checklist metadata: %w", err)
+	}
+	gzBody, err = c.redactGzipText(gzBody)
+	if err != nil {
+		return err
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"url":        url,
+		"method":     "POST",
+		"profile_id": info.ProfileID,
+		"size_bytes": len(gzBody),
+	}).Debug("Uploading compliance checklist to server")
+
+	req := c.client.R().
+		SetContext(ctx).
+		SetHeader("X-API-ID", c.credentials.APIID).
+		SetHeader("X-API-KEY", c.credentials.APIKey).
+		SetQueryParams(map[string]string{
+			"profile_id": info.ProfileID,
+			"command_id": info.CommandID,
+			"hostname":   info.Hostname,
+			"machine_id": info.MachineID,
+		})
+	if err := c.setPayload(req, gzBody, "application/xml", "gzip"); err != nil {
+		return err
+	}
+	resp, err := req.Post(url)
+	if err != nil {
+		return fmt.Errorf("checklist upload failed: %w", err)
+	}
+
+	if resp.StatusCode() != 200 && resp.StatusCode() != 201 {
+		c.logger.WithField("response", resp.String()).Debug("Full error response from checklist upload")
+		return c.apiError("checklist upload", resp)
+	}
+
+	return nil
+}
+
+// SendComplianceHTMLReport uploads a gzip-compressed OpenSCAP HTML report,
+// copied like the scan results to the servers that take compliance
+func (c *Client) SendComplianceHTMLReport(ctx context.Context, info *models.ComplianceHTMLReportInfo, gzBody []byte) error {
+	// Each server redacts its own copy of the metadata and body
+	orig, origBody := *info, gzBody
+	defer c.mirror(EndpointCompliance, func(s *Client) error {
+		info := orig
+		return s.SendComplianceHTMLReport(ctx, &info, origBody)
+	})()
+
+	url, err := c.apiURL(EndpointCompliance, "compliance/reports")
+	if err != nil {
+		return err
+	}
+	if err := c.redactor.Value(info); err != nil {
+		return fmt.Errorf("failed to redact report metadata: %w", err)
+	}
+	body, err := c.redactGzipText(gzBody)
+	if err != nil {
+		return err
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"url":        url,
+		"method":     "POST",
+		"report_id":  info.ReportID,
+		"size_bytes": len(body),
+	}).Debug("Uploading compliance HTML report to server")
+
+	req := c.client.R().
+		SetContext(ctx).
+		SetHeader("X-API-ID", c.credentials.APIID).
+		SetHeader("X-API-KEY", c.credentials.APIKey).
+		SetQueryParams(map[string]string{
+			"report_id":    info.ReportID,
+			"profile_name": info.ProfileName,
+			"hostname":     info.Hostname,
+			"machine_id":   info.MachineID,
+		})
+	if err := c.setPayload(req, body, "text/html", "gzip"); err != nil {
+		return err
+	}
+	resp, err := req.Post(url)
+	if err != nil {
+		return fmt.Errorf("html report upload failed: %w", err)
+	}
+
+	if resp.StatusCode() != 200 && resp.StatusCode() != 201 {
+		c.logger.WithField("response", resp.String()).Debug("Full error response from html report upload")
+		return c.apiError("html report upload", resp)
+	}
+
+	return nil
+}
+
 // SSGVersionResponse represents the server's response to GET /compliance/ssg-version.
 type SSGVersionResponse struct {
 	Version string   `json:"version"`
@@ -322,7 +1067,10 @@ type SSGVersionResponse struct {
 
 // GetSSGVersion fetches the server's embedded SSG version and available content files.
 func (c *Client) GetSSGVersion(ctx context.Context) (*SSGVersionResponse, error) {
-	url := fmt.Sprintf("%s/api/%s/compliance/ssg-version", c.config.PatchmonServer, c.config.APIVersion)
+	url, err := c.apiURL(EndpointCompliance, "compliance/ssg-version")
+	if err != nil {
+		return nil, err
+	}
 
 	resp, err := c.client.R().
 		SetContext(ctx).
@@ -335,7 +1083,7 @@ func (c *Client) GetSSGVersion(ctx context.Context) (*SSGVersionResponse, error)
 		return nil, fmt.Errorf("ssg-version request failed: %w", err)
 	}
 	if resp.StatusCode() != 200 {
-		return nil, fmt.Errorf("ssg-version request failed with status %d", resp.StatusCode())
+		return nil, c.apiError("ssg-version request", resp)
 	}
 	result, ok := resp.Result().(*SSGVersionResponse)
 	if !ok {
@@ -346,7 +1094,10 @@ func (c *Client) GetSSGVersion(ctx context.Context) (*SSGVersionResponse, error)
 
 // DownloadSSGContent downloads a specific SSG datastream file from the server.
 func (c *Client) DownloadSSGContent(ctx context.Context, filename, destPath string) error {
-	url := fmt.Sprintf("%s/api/%s/compliance/ssg-content/%s", c.config.PatchmonServer, c.config.APIVersion, filename)
+	url, err := c.apiURL(EndpointCompliance, "compliance/ssg-content/"+filename)
+	if err != nil {
+		return err
+	}
 
 	resp, err := c.client.R().
 		SetContext(ctx).
@@ -359,39 +1110,43 @@ func (c *Client) DownloadSSGContent(ctx context.Context, filename, destPath stri
 		return fmt.Errorf("ssg-content download failed: %w", err)
 	}
 	if resp.StatusCode() != 200 {
-		return fmt.Errorf("ssg-content download failed with status %d", resp.StatusCode())
+		return c.apiError("ssg-content download", resp)
 	}
 	return nil
 }
 
 // SendPatchOutput sends patch run output/status to the server (agent-facing patching endpoint)
 func (c *Client) SendPatchOutput(ctx context.Context, patchRunID, stage, output, errorMessage string) error {
-	url := fmt.Sprintf("%s/api/%s/patching/runs/%s/output", c.config.PatchmonServer, c.config.APIVersion, patchRunID)
+	url, err := c.apiURL(EndpointPatching, fmt.Sprintf("patching/runs/%s/output", patchRunID))
+	if err != nil {
+		return err
+	}
 
-	body := map[string]interface{}{
+	payload := map[string]interface{}{
 		"stage": stage,
 	}
 	if output != "" {
-		body["output"] = output
+		payload["output"] = output
 	}
 	if errorMessage != "" {
-		body["error_message"] = errorMessage
+		payload["error_message"] = errorMessage
 	}
 
-	resp, err := c.client.R().
+	req := c.client.R().
 		SetContext(ctx).
-		SetHeader("Content-Type", "application/json").
 		SetHeader("X-API-ID", c.credentials.APIID).
-		SetHeader("X-API-KEY", c.credentials.APIKey).
-		SetBody(body).
-		Post(url)
+		SetHeader("X-API-KEY", c.credentials.APIKey)
+	if err := c.setJSONPayload(req, payload); err != nil {
+		return err
+	}
+	resp, err := req.Post(url)
 
 	if err != nil {
 		return fmt.Errorf("patch output request failed: %w", err)
 	}
 
 	if resp.StatusCode() != 200 {
-		return fmt.Errorf("patch output request failed with status %d: %s", resp.StatusCode(), truncateResponse(resp.String(), 200))
+		return c.apiError("patch output request", resp)
 	}
 
 	return nil
@@ -406,56 +1161,67 @@ type WindowsUpdateResult struct {
 
 // SendWindowsUpdateResult reports a single per-update install result to the server.
 func (c *Client) SendWindowsUpdateResult(ctx context.Context, patchRunID string, result WindowsUpdateResult) error {
-	url := fmt.Sprintf("%s/api/%s/patching/windows-updates/result", c.config.PatchmonServer, c.config.APIVersion)
-	body := map[string]interface{}{
+	url, err := c.apiURL(EndpointPatching, "patching/windows-updates/result")
+	if err != nil {
+		return err
+	}
+	payload := map[string]interface{}{
 		"patch_run_id": patchRunID,
 		"guid":         result.GUID,
 		"success":      result.Success,
 	}
 	if result.Error != "" {
-		body["error"] = result.Error
+		payload["error"] = result.Error
 	}
-	resp, err := c.client.R().
+	req := c.client.R().
 		SetContext(ctx).
-		SetHeader("Content-Type", "application/json").
 		SetHeader("X-API-ID", c.credentials.APIID).
-		SetHeader("X-API-KEY", c.credentials.APIKey).
-		SetBody(body).
-		Post(url)
+		SetHeader("X-API-KEY", c.credentials.APIKey)
+	if err := c.setJSONPayload(req, payload); err != nil {
+		return err
+	}
+	resp, err := req.Post(url)
 	if err != nil {
 		return fmt.Errorf("windows update result request failed: %w", err)
 	}
 	if resp.StatusCode() != 200 {
-		return fmt.Errorf("windows update result request failed with status %d", resp.StatusCode())
+		return c.apiError("windows update result request", resp)
 	}
 	return nil
 }
 
 // SendWindowsRebootStatus reports whether a reboot is needed after Windows Update installation.
 func (c *Client) SendWindowsRebootStatus(ctx context.Context, patchRunID string, needsReboot bool) error {
-	url := fmt.Sprintf("%s/api/%s/patching/windows-updates/reboot", c.config.PatchmonServer, c.config.APIVersion)
-	resp, err := c.client.R().
+	url, err := c.apiURL(EndpointPatching, "patching/windows-updates/reboot")
+	if err != nil {
+		return err
+	}
+	req := c.client.R().
 		SetContext(ctx).
-		SetHeader("Content-Type", "application/json").
 		SetHeader("X-API-ID", c.credentials.APIID).
-		SetHeader("X-API-KEY", c.credentials.APIKey).
-		SetBody(map[string]interface{}{
-			"patch_run_id": patchRunID,
-			"needs_reboot": needsReboot,
-		}).
-		Post(url)
+		SetHeader("X-API-KEY", c.credentials.APIKey)
+	if err := c.setJSONPayload(req, map[string]interface{}{
+		"patch_run_id": patchRunID,
+		"needs_reboot": needsReboot,
+	}); err != nil {
+		return err
+	}
+	resp, err := req.Post(url)
 	if err != nil {
 		return fmt.Errorf("windows reboot status request failed: %w", err)
 	}
 	if resp.StatusCode() != 200 {
-		return fmt.Errorf("windows reboot status request failed with status %d", resp.StatusCode())
+		return c.apiError("windows reboot status request", resp)
 	}
 	return nil
 }
 
 // GetApprovedWindowsUpdateGUIDs fetches the list of WUA GUIDs approved for installation on this host.
 func (c *Client) GetApprovedWindowsUpdateGUIDs(ctx context.Context) ([]string, error) {
-	url := fmt.Sprintf("%s/api/%s/patching/windows-updates/approved", c.config.PatchmonServer, c.config.APIVersion)
+	url, err := c.apiURL(EndpointPatching, "patching/windows-updates/approved")
+	if err != nil {
+		return nil, err
+	}
 	var result struct {
 		GUIDs []string `json:"guids"`
 	}
@@ -469,7 +1235,7 @@ func (c *Client) GetApprovedWindowsUpdateGUIDs(ctx context.Context) ([]string, e
 		return nil, fmt.Errorf("get approved GUIDs request failed: %w", err)
 	}
 	if resp.StatusCode() != 200 {
-		return nil, fmt.Errorf("get approved GUIDs request failed with status %d", resp.StatusCode())
+		return nil, c.apiError("get approved GUIDs request", resp)
 	}
 	r, ok := resp.Result().(*struct {
 		GUIDs []string `json:"guids"`
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// ServerTLSConfig returns the TLS settings for connections to
+// patchmon_server when client_tls is set, or nil to use the defaults. The
+// certificate is presented in addition to the API credentials.
+func ServerTLSConfig(cfg *models.Config, skipVerify bool) (*tls.Config, error) {
+	ct := cfg.ClientTLS
+	if ct == nil {
+		return nil, nil
+	}
+	if ct.CertFile == "" || ct.KeyFile == "" {
+		return nil, errors.New("client_tls needs both cert_file and key_file")
+	}
+	return clientCertTLSConfig(ct.CertFile, ct.KeyFile, ct.CAFile, skipVerify, "client certificate")
+}
+
+// TLSConfig returns the TLS settings for the first hop towards the server:
+// the relay's when relay_url is set, otherwise client_tls. nil means the
+// defaults.
+func TLSConfig(cfg *models.Config, skipVerify bool) (*tls.Config, error) {
+	if cfg.RelayURL != "" {
+		return RelayTLSConfig(cfg, skipVerify)
+	}
+	return ServerTLSConfig(cfg, skipVerify)
+}
+
+// clientCertTLSConfig presents the certificate in certFile and keyFile,
+// rereading them at each handshake so a renewed certificate is picked up
+// without a restart, and verifies the other side against caFile if set.
+// what names the certificate in errors.
+func clientCertTLSConfig(certFile, keyFile, caFile string, skipVerify bool, what string) (*tls.Config, error) {
+	// Fail now rather than at the first handshake
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", what, err)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: skipVerify,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load %s: %w", what, err)
+			}
+			return &cert, nil
+		},
+	}
+	if caFile != "" {
+		pool, err := LoadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/config"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert signs a certificate for cn, valid for 127.0.0.1, with parent
+// (self-signed when nil) and writes it and its key to dir
+func writeCert(t *testing.T, dir, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid, tmpl.KeyUsage = true, true, x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certFile, keyFile := filepath.Join(dir, cn+".pem"), filepath.Join(dir, cn+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return cert, key, certFile, keyFile
+}
+
+func TestClientTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey, caFile, _ := writeCert(t, dir, "ca", nil, nil)
+	_, _, serverCert, serverKey := writeCert(t, dir, "server", ca, caKey)
+	_, _, agentCert, agentKey := writeCert(t, dir, "web-01", ca, caKey)
+
+	var peer string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		peer = r.TLS.PeerCertificates[0].Subject.CommonName
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"apiId":"id","apiKey":"key"}`))
+	}))
+	pool, err := LoadCertPool(caFile)
+	require.NoError(t, err)
+	cert, err := tls.LoadX509KeyPair(serverCert, serverKey)
+	require.NoError(t, err)
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, ClientCAs: pool, ClientAuth: tls.RequireAndVerifyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	enroll := func(ct *models.ClientTLSConfig) error {
+		mgr := config.New()
+		mgr.GetConfig().PatchmonServer = srv.URL
+		mgr.GetConfig().ClientTLS = ct
+		logger := logrus.New()
+		logger.SetOutput(io.Discard)
+		c := New(mgr, logger)
+		c.client.SetRetryCount(0)
+		_, err := c.Enroll(context.Background(), &models.EnrollRequest{Code: "ABC123"})
+		return err
+	}
+
+	require.NoError(t, enroll(&models.ClientTLSConfig{CertFile: agentCert, KeyFile: agentKey, CAFile: caFile}))
+	assert.Equal(t, "web-01", peer)
+
+	assert.Error(t, enroll(&models.ClientTLSConfig{CAFile: caFile}), "client_tls without a certificate is refused before sending")
+	assert.Error(t, enroll(&models.ClientTLSConfig{CertFile: agentCert, KeyFile: caFile, CAFile: caFile}))
+
+	// Without client_tls the server's CA isn't trusted, and with it but no
+	// certificate the handshake is refused
+	assert.Error(t, enroll(nil))
+	tlsConfig, err := ServerTLSConfig(&models.Config{ClientTLS: &models.ClientTLSConfig{CertFile: agentCert, KeyFile: agentKey, CAFile: caFile}}, false)
+	require.NoError(t, err)
+	tlsConfig.GetClientCertificate = nil
+	resp, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}).Get(srv.URL)
+	if err == nil {
+		_ = resp.Body.Close()
+	}
+	assert.Error(t, err)
+
+	none, err := ServerTLSConfig(&models.Config{}, false)
+	assert.NoError(t, err)
+	assert.Nil(t, none)
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// RegistrationConflictError is returned when the server refuses a report
+// because this host's machine ID is already registered to another host,
+// typically after cloning a VM or image without resetting /etc/machine-id.
+// Retrying can't fix it; the operator has to reset one of the identities.
+type RegistrationConflictError struct {
+	StatusCode int
+	// Message is the server's explanation
+	Message string
+	// ExistingHostname is the host the machine ID is registered to, when the
+	// server says
+	ExistingHostname string
+}
+
+func (e *RegistrationConflictError) Error() string {
+	msg := fmt.Sprintf("registration conflict (status %d)", e.StatusCode)
+	if e.ExistingHostname != "" {
+		msg += ": machine ID is already registered to " + e.ExistingHostname
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// registrationConflict recognises a conflicting registration in a failed
+// report response: ERR_MACHINE_ID_CONFLICT, a 409, or a 500 from older
+// servers that hit the unique constraint on machine_id instead of checking
+// for it
+func registrationConflict(status int, body []byte) *RegistrationConflictError {
+	parsed, ok := parseErrorResponse(body)
+	switch {
+	case parsed.Code == models.ErrMachineIDConflict:
+	case status == http.StatusConflict:
+	case status == http.StatusInternalServerError:
+		lower := strings.ToLower(string(body))
+		if !strings.Contains(lower, "machine_id") && !strings.Contains(lower, "machineid") {
+			return nil
+		}
+		if !strings.Contains(lower, "unique") && !strings.Contains(lower, "duplicate") && !strings.Contains(lower, "already") {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	conflict := &RegistrationConflictError{StatusCode: status}
+	if ok {
+		conflict.Message = parsed.Message
+		if conflict.Message == "" {
+			conflict.Message = parsed.Error
+		}
+		conflict.ExistingHostname = parsed.ExistingHostname
+	} else {
+		conflict.Message = truncateResponse(string(body), maxErrorMessageLength)
+	}
+	return conflict
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendUpdateReturnsRegistrationConflict(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"error":"Machine ID already registered","existingHostname":"web-1"}`))
+	}))
+	defer srv.Close()
+
+	_, err := testClient(srv.URL, "").SendUpdate(context.Background(), &models.ReportPayload{Hostname: "web-2"})
+	var conflict *RegistrationConflictError
+	require.True(t, errors.As(err, &conflict), "got %v", err)
+	assert.Equal(t, http.StatusConflict, conflict.StatusCode)
+	assert.Equal(t, "web-1", conflict.ExistingHostname)
+	assert.Equal(t, "Machine ID already registered", conflict.Message)
+	assert.Contains(t, err.Error(), "already registered to web-1")
+}
+
+func TestRegistrationConflictFromServerError(t *testing.T) {
+	body := []byte(`{"error":"Unique constraint failed on the fields: (` + "`machine_id`" + `)"}`)
+	conflict := registrationConflict(http.StatusInternalServerError, body)
+	require.NotNil(t, conflict)
+	assert.Contains(t, conflict.Message, "Unique constraint")
+
+	assert.Nil(t, registrationConflict(http.StatusInternalServerError, []byte(`{"error":"database is down"}`)))
+	assert.Nil(t, registrationConflict(http.StatusUnauthorized, []byte(`{"error":"invalid credentials"}`)))
+}
@@ -0,0 +1,189 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/nacl/box"
+)
+
+func testClient(serverURL, encryptionKey string) *Client {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return &Client{
+		client:      resty.New(),
+		config:      &models.Config{PatchmonServer: serverURL, APIVersion: "v1", PayloadEncryptionKey: encryptionKey},
+		credentials: &models.Credentials{APIID: "id", APIKey: "key"},
+		logger:      logger,
+		statuses:    newStatusTracker(),
+	}
+}
+
+func TestSealedPayloadOpensWithServerKey(t *testing.T) {
+	public, private, err := box.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	var got models.PackageTransactionPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, PayloadEncryptionSealedBox, r.Header.Get(HeaderPayloadEncryption))
+		assert.Equal(t, "application/json", r.Header.Get(HeaderPayloadContentType))
+		sealed, _ := io.ReadAll(r.Body)
+		plain, ok := box.OpenAnonymous(nil, sealed, public, private)
+		if assert.True(t, ok, "body should open with the server key") {
+			assert.NoError(t, json.Unmarshal(plain, &got))
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := testClient(srv.URL, base64.StdEncoding.EncodeToString(public[:]))
+	err = c.SendPackageTransaction(context.Background(), &models.PackageTransactionPayload{
+		Transaction: &models.PackageTransaction{Manager: "apt"},
+		Hostname:    "web-1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "web-1", got.Hostname)
+}
+
+func TestInvalidEncryptionKeyNeverSendsPlaintext(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	c := testClient(srv.URL, "not-a-key")
+	err := c.SendPackageTransaction(context.Background(), &models.PackageTransactionPayload{
+		Transaction: &models.PackageTransaction{Manager: "apt"},
+	})
+	assert.ErrorContains(t, err, "payload_encryption_key is invalid")
+	assert.False(t, called)
+}
+
+func TestEverySendMethodSealsItsBody(t *testing.T) {
+	public, private, err := box.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	plaintext := map[string]bool{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sealed, _ := io.ReadAll(r.Body)
+		_, ok := box.OpenAnonymous(nil, sealed, public, private)
+		mu.Lock()
+		if r.Header.Get(HeaderPayloadEncryption) != PayloadEncryptionSealedBox || !ok {
+			plaintext[r.URL.Path] = true
+		}
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write([]byte(`{}`))
+	require.NoError(t, zw.Close())
+
+	ctx := context.Background()
+	sends := map[string]func(c *Client) error{
+		"SendUpdate": func(c *Client) error { _, err := c.SendUpdate(ctx, &models.ReportPayload{}); return err },
+		"SendDockerData": func(c *Client) error {
+			_, err := c.SendDockerData(ctx, &models.DockerPayload{})
+			return err
+		},
+		"SendDockerContainerUpdate": func(c *Client) error {
+			_, err := c.SendDockerContainerUpdate(ctx, &models.DockerContainerUpdatePayload{})
+			return err
+		},
+		"SendDockerPrune": func(c *Client) error {
+			_, err := c.SendDockerPrune(ctx, &models.DockerPrunePayload{})
+			return err
+		},
+		"SendImageSBOM": func(c *Client) error { return c.SendImageSBOM(ctx, &models.ImageSBOMInfo{}, gz.Bytes()) },
+		"SendLanguagePackages": func(c *Client) error {
+			_, err := c.SendLanguagePackages(ctx, &models.LanguagePackagesPayload{})
+			return err
+		},
+		"SendUserAccounts": func(c *Client) error {
+			_, err := c.SendUserAccounts(ctx, &models.UserAccountsPayload{})
+			return err
+		},
+		"SendTLSCertificates": func(c *Client) error {
+			_, err := c.SendTLSCertificates(ctx, &models.TLSCertificatesPayload{})
+			return err
+		},
+		"SendScheduledTasks": func(c *Client) error {
+			_, err := c.SendScheduledTasks(ctx, &models.ScheduledTasksPayload{})
+			return err
+		},
+		"SendJails":  func(c *Client) error { _, err := c.SendJails(ctx, &models.JailsPayload{}); return err },
+		"SendNspawn": func(c *Client) error { _, err := c.SendNspawn(ctx, &models.NspawnPayload{}); return err },
+		"SendProxmoxData": func(c *Client) error {
+			_, err := c.SendProxmoxData(ctx, &models.ProxmoxPayload{})
+			return err
+		},
+		"SendKubernetesData": func(c *Client) error {
+			_, err := c.SendKubernetesData(ctx, &models.KubernetesPayload{})
+			return err
+		},
+		"SendHardwareInventory": func(c *Client) error {
+			_, err := c.SendHardwareInventory(ctx, &models.HardwareInventoryPayload{})
+			return err
+		},
+		"SendHostnameChange": func(c *Client) error { return c.SendHostnameChange(ctx, &models.HostnameChangeEvent{}) },
+		"SendPackageTransaction": func(c *Client) error {
+			return c.SendPackageTransaction(ctx, &models.PackageTransactionPayload{Transaction: &models.PackageTransaction{}})
+		},
+		"SendPackageUpdate": func(c *Client) error {
+			return c.SendPackageUpdate(ctx, &models.PackageUpdatePayload{Result: &models.PackageUpdateResult{}})
+		},
+		"SendComplianceData": func(c *Client) error {
+			_, err := c.SendComplianceData(ctx, &models.CompliancePayload{})
+			return err
+		},
+		"SendComplianceCKL": func(c *Client) error { return c.SendComplianceCKL(ctx, &models.ComplianceCKLInfo{}, gz.Bytes()) },
+		"SendComplianceHTMLReport": func(c *Client) error {
+			return c.SendComplianceHTMLReport(ctx, &models.ComplianceHTMLReportInfo{}, gz.Bytes())
+		},
+		"SendPatchOutput": func(c *Client) error { return c.SendPatchOutput(ctx, "run-1", "running", "output", "") },
+		"SendWindowsUpdateResult": func(c *Client) error {
+			return c.SendWindowsUpdateResult(ctx, "run-1", WindowsUpdateResult{GUID: "guid", Success: true})
+		},
+		"SendWindowsRebootStatus": func(c *Client) error { return c.SendWindowsRebootStatus(ctx, "run-1", true) },
+		"SendIntegrationSetupStatus": func(c *Client) error {
+			return c.SendIntegrationSetupStatus(ctx, &models.IntegrationSetupStatus{Integration: "docker", Status: "ready"})
+		},
+	}
+
+	// A new Send* method has to be added above; SendDockerStatusEvent only logs
+	typ := reflect.TypeOf(&Client{})
+	for i := 0; i < typ.NumMethod(); i++ {
+		name := typ.Method(i).Name
+		if strings.HasPrefix(name, "Send") && name != "SendDockerStatusEvent" {
+			assert.Contains(t, sends, name, "%s is not covered", name)
+		}
+	}
+
+	c := testClient(srv.URL, base64.StdEncoding.EncodeToString(public[:]))
+	for name, send := range sends {
+		assert.NoError(t, send(c), name)
+	}
+	assert.Empty(t, plaintext, "requests sent without sealing")
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Payload types that the endpoints config map can route to a different base
+// URL. Anything not overridden goes to patchmon_server.
+const (
+	EndpointPing                = "ping"
+	EndpointReport              = "report"
+	EndpointSettings            = "settings"
+	EndpointIntegrations        = "integrations"
+	EndpointDocker              = "docker"
+	EndpointLanguagePackages    = "language-packages"
+	EndpointUserAccounts        = "user-accounts"
+	EndpointTLSCertificates     = "tls-certificates"
+	EndpointScheduledTasks      = "scheduled-tasks"
+	EndpointJails               = "jails"
+	EndpointNspawn              = "nspawn"
+	EndpointProxmox             = "proxmox"
+	EndpointKubernetes          = "kubernetes"
+	EndpointPackageTransactions = "package-transactions"
+	EndpointCompliance          = "compliance"
+	EndpointPatching            = "patching"
+)
+
+// EndpointNames lists the keys accepted in the endpoints config map
+var EndpointNames = []string{
+	EndpointPing, EndpointReport, EndpointSettings, EndpointIntegrations,
+	EndpointDocker, EndpointLanguagePackages, EndpointUserAccounts,
+	EndpointTLSCertificates, EndpointScheduledTasks, EndpointJails, EndpointNspawn,
+	EndpointProxmox, EndpointKubernetes, EndpointPackageTransactions, EndpointCompliance,
+	EndpointPatching,
+}
+
+// endpointOverride is a parsed entry of the endpoints map. An invalid URL
+// keeps its error so requests of that type fail instead of going to the main
+// server.
+type endpointOverride struct {
+	base string
+	err  error
+}
+
+// parseEndpoints validates the endpoints config map. Unknown keys are
+// returned so the caller can warn about them.
+func parseEndpoints(endpoints map[string]string) (map[string]endpointOverride, []string) {
+	known := make(map[string]bool, len(EndpointNames))
+	for _, name := range EndpointNames {
+		known[name] = true
+	}
+	parsed := make(map[string]endpointOverride, len(endpoints))
+	var unknown []string
+	for name, raw := range endpoints {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !known[name] {
+			unknown = append(unknown, name)
+			continue
+		}
+		u, err := url.Parse(strings.TrimSpace(raw))
+		switch {
+		case err != nil:
+			parsed[name] = endpointOverride{err: fmt.Errorf("endpoints.%s is invalid: %w", name, err)}
+		case (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+			parsed[name] = endpointOverride{err: fmt.Errorf("endpoints.%s must be an http(s) URL, got %q", name, raw)}
+		default:
+			parsed[name] = endpointOverride{base: strings.TrimRight(u.String(), "/")}
+		}
+	}
+	sort.Strings(unknown)
+	return parsed, unknown
+}
+
+// apiURL returns the URL of an API path for a payload type, using the
+// endpoint override when one is configured and the relay when relay_url is
+// set. It fails while the server has asked the agent to back off.
+func (c *Client) apiURL(endpoint, path string) (string, error) {
+	if err := c.checkBackoff(); err != nil {
+		return "", err
+	}
+	if c.relayErr != nil {
+		return "", c.relayErr
+	}
+	if c.tlsErr != nil {
+		return "", c.tlsErr
+	}
+	base := ServerURL(c.config)
+	if override, ok := c.endpoints[endpoint]; ok {
+		if override.err != nil {
+			return "", override.err
+		}
+		base = override.base
+	}
+	return fmt.Sprintf("%s/api/%s/%s", base, c.config.APIVersion, path), nil
+}
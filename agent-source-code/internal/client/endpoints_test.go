@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointOverrideRoutesPayloadType(t *testing.T) {
+	mainCalls := 0
+	main := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mainCalls++
+		assert.Equal(t, "/api/v1/hosts/package-transactions", r.URL.Path)
+	}))
+	defer main.Close()
+	var ingestPath string
+	ingest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ingestPath = r.URL.Path
+		assert.Equal(t, "id", r.Header.Get("X-API-ID"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"message":"ok"}`))
+	}))
+	defer ingest.Close()
+
+	c := testClient(main.URL, "")
+	c.endpoints, _ = parseEndpoints(map[string]string{"Compliance": ingest.URL + "/ingest/"})
+
+	_, err := c.SendComplianceData(context.Background(), &models.CompliancePayload{Hostname: "web-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "/ingest/api/v1/compliance/scans", ingestPath)
+
+	require.NoError(t, c.SendPackageTransaction(context.Background(), &models.PackageTransactionPayload{
+		Transaction: &models.PackageTransaction{Manager: "apt"},
+	}))
+	assert.Equal(t, 1, mainCalls)
+}
+
+func TestInvalidEndpointNeverFallsBack(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+	defer srv.Close()
+
+	c := testClient(srv.URL, "")
+	c.endpoints, _ = parseEndpoints(map[string]string{EndpointPackageTransactions: "ingest.example.com"})
+	err := c.SendPackageTransaction(context.Background(), &models.PackageTransactionPayload{
+		Transaction: &models.PackageTransaction{Manager: "apt"},
+	})
+	assert.ErrorContains(t, err, "endpoints.package-transactions must be an http(s) URL")
+	assert.False(t, called)
+}
+
+func TestParseEndpointsReportsUnknownKeys(t *testing.T) {
+	parsed, unknown := parseEndpoints(map[string]string{"complaince": "https://x", "docker": "https://docker.example.com"})
+	assert.Equal(t, []string{"complaince"}, unknown)
+	assert.Equal(t, map[string]endpointOverride{"docker": {base: "https://docker.example.com"}}, parsed)
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Enroll exchanges an enrollment code for this host's credentials. It is the
+// one request sent without credentials; the code itself authenticates it.
+func (c *Client) Enroll(ctx context.Context, payload *models.EnrollRequest) (*models.EnrollResponse, error) {
+	url, err := c.apiURL(EndpointReport, "hosts/enroll")
+	if err != nil {
+		return nil, err
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"url":    url,
+		"method": "POST",
+	}).Debug("Sending enrollment request to server")
+
+	resp, err := c.client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(payload).
+		SetResult(&models.EnrollResponse{}).
+		Post(url)
+
+	if err != nil {
+		return nil, fmt.Errorf("enrollment request failed: %w", err)
+	}
+
+	if resp.StatusCode() != 200 {
+		c.logger.WithField("response", resp.String()).Debug("Full error response from enrollment request")
+		return nil, c.apiError("enrollment request", resp)
+	}
+
+	result, ok := resp.Result().(*models.EnrollResponse)
+	if !ok {
+		return nil, fmt.Errorf("invalid response format")
+	}
+
+	return result, nil
+}
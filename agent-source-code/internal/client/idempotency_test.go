@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/redact"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/nacl/box"
+)
+
+// updateAttempt is one report submission as the server saw it
+type updateAttempt struct {
+	key, hash string
+	plain     []byte
+}
+
+func TestIdempotencyKeyAndPayloadHash(t *testing.T) {
+	public, private, err := box.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	var (
+		mu       sync.Mutex
+		attempts []updateAttempt
+		dropNext = true
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sealed, _ := io.ReadAll(r.Body)
+		plain, ok := box.OpenAnonymous(nil, sealed, public, private)
+		assert.True(t, ok, "body should open with the server key")
+
+		mu.Lock()
+		attempts = append(attempts, updateAttempt{key: r.Header.Get(HeaderIdempotencyKey), hash: r.Header.Get(HeaderPayloadSHA256), plain: plain})
+		drop := dropNext
+		dropNext = false
+		mu.Unlock()
+
+		if drop {
+			// Fail the first delivery the way a dropped connection would
+			if conn, _, err := w.(http.Hijacker).Hijack(); assert.NoError(t, err) {
+				_ = conn.Close()
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"message":"ok"}`))
+	}))
+	defer srv.Close()
+
+	c := testClient(srv.URL, base64.StdEncoding.EncodeToString(public[:]))
+	c.client.SetRetryCount(2).SetRetryWaitTime(time.Millisecond)
+	c.redactor, err = redact.New(&models.RedactionConfig{Fields: []string{"gateway_ip"}})
+	require.NoError(t, err)
+	report := &models.ReportPayload{Hostname: "web-1", GatewayIP: "203.0.113.1"}
+
+	_, err = c.SendUpdate(context.Background(), report)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(attempts), 2, "the dropped delivery is retried")
+	first := attempts[0]
+	require.NotEmpty(t, first.key)
+	for _, a := range attempts[1:] {
+		assert.Equal(t, first.key, a.key, "retries of one report share its key")
+		assert.Equal(t, first.hash, a.hash)
+	}
+
+	// The hash is of the redacted JSON the server gets once it opens the box
+	sum := sha256.Sum256(first.plain)
+	assert.Equal(t, hex.EncodeToString(sum[:]), first.hash)
+	var sent map[string]interface{}
+	require.NoError(t, json.Unmarshal(first.plain, &sent))
+	assert.Equal(t, redact.DefaultReplacement, sent["gatewayIp"])
+
+	_, err = c.SendUpdate(context.Background(), report)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.key, attempts[len(attempts)-1].key, "each report gets its own key")
+}
@@ -0,0 +1,229 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Backoff between attempts to deliver an integration status that failed to send
+var (
+	statusRetryMin = 5 * time.Second
+	statusRetryMax = 5 * time.Minute
+)
+
+// statusRequestTimeout bounds each background resend
+const statusRequestTimeout = 30 * time.Second
+
+// integrationStatuses is shared by every Client so one rebuilt after a config
+// change still retries and reconciles what the previous one recorded
+var integrationStatuses = newStatusTracker()
+
+// statusTracker keeps the latest status per integration and what the server
+// has accepted, so retries never deliver an older status after a newer one
+type statusTracker struct {
+	mu       sync.Mutex
+	lastSeq  int64
+	latest   map[string]*models.IntegrationSetupStatus
+	accepted map[string]int64 // highest sequence the server acknowledged
+	sending  map[string]*sync.Mutex
+	retrying map[string]bool
+}
+
+func newStatusTracker() *statusTracker {
+	return &statusTracker{
+		latest:   make(map[string]*models.IntegrationSetupStatus),
+		accepted: make(map[string]int64),
+		sending:  make(map[string]*sync.Mutex),
+		retrying: make(map[string]bool),
+	}
+}
+
+// record stores a copy of status as the integration's current status. The
+// sequence is a nanosecond timestamp bumped past the previous one, so it keeps
+// increasing across agent restarts as long as the clock does not jump back.
+func (t *statusTracker) record(status *models.IntegrationSetupStatus) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now().UTC()
+	seq := now.UnixNano()
+	if seq <= t.lastSeq {
+		seq = t.lastSeq + 1
+	}
+	t.lastSeq = seq
+
+	// Callers keep appending to their events and components after sending
+	recorded := *status
+	recorded.InstallEvents = append([]models.InstallEvent(nil), status.InstallEvents...)
+	if status.Components != nil {
+		recorded.Components = make(map[string]string, len(status.Components))
+		for k, v := range status.Components {
+			recorded.Components[k] = v
+		}
+	}
+	recorded.Sequence = seq
+	recorded.Timestamp = now.Format(time.RFC3339Nano)
+	t.latest[status.Integration] = &recorded
+}
+
+// sendLock serialises deliveries for one integration
+func (t *statusTracker) sendLock(integration string) *sync.Mutex {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lock, ok := t.sending[integration]
+	if !ok {
+		lock = &sync.Mutex{}
+		t.sending[integration] = lock
+	}
+	return lock
+}
+
+// pending reports whether the latest status has not been acknowledged yet
+func (t *statusTracker) pending(integration string) bool {
+	latest := t.latest[integration]
+	return latest != nil && latest.Sequence > t.accepted[integration]
+}
+
+// SendIntegrationSetupStatus records status as the integration's current
+// status and delivers it. Deliveries for one integration are serialised and
+// only ever send the newest status, so a slow or retried request can't
+// overwrite a later one. A failed delivery is retried in the background until
+// the server accepts it. Integrations are managed from patchmon_server, so
+// statuses are not copied to the servers list.
+func (c *Client) SendIntegrationSetupStatus(ctx context.Context, status *models.IntegrationSetupStatus) error {
+	c.statuses.record(status)
+	if err := c.flushIntegrationStatus(ctx, status.Integration, false); err != nil {
+		c.retryIntegrationStatus(status.Integration)
+		return err
+	}
+	return nil
+}
+
+// ResendIntegrationStatuses re-sends the current status of every integration
+// that reported one, so the server recovers from anything it lost while the
+// agent was disconnected. Call it after reconnecting.
+func (c *Client) ResendIntegrationStatuses(ctx context.Context) error {
+	c.statuses.mu.Lock()
+	names := make([]string, 0, len(c.statuses.latest))
+	for name := range c.statuses.latest {
+		names = append(names, name)
+	}
+	c.statuses.mu.Unlock()
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if err := c.flushIntegrationStatus(ctx, name, true); err != nil {
+			c.retryIntegrationStatus(name)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// flushIntegrationStatus sends the integration's latest status unless the
+// server already has it (or force is set)
+func (c *Client) flushIntegrationStatus(ctx context.Context, integration string, force bool) error {
+	lock := c.statuses.sendLock(integration)
+	lock.Lock()
+	defer lock.Unlock()
+
+	c.statuses.mu.Lock()
+	status := c.statuses.latest[integration]
+	send := status != nil && (force || c.statuses.pending(integration))
+	c.statuses.mu.Unlock()
+	if !send {
+		return nil
+	}
+
+	if err := c.postIntegrationStatus(ctx, status); err != nil {
+		return err
+	}
+
+	c.statuses.mu.Lock()
+	if status.Sequence > c.statuses.accepted[integration] {
+		c.statuses.accepted[integration] = status.Sequence
+	}
+	c.statuses.mu.Unlock()
+	return nil
+}
+
+// retryIntegrationStatus starts a background retry for an integration unless
+// one is already running. It stops once the latest status is acknowledged.
+func (c *Client) retryIntegrationStatus(integration string) {
+	c.statuses.mu.Lock()
+	if c.statuses.retrying[integration] {
+		c.statuses.mu.Unlock()
+		return
+	}
+	c.statuses.retrying[integration] = true
+	c.statuses.mu.Unlock()
+
+	go func() {
+		wait := statusRetryMin
+		for {
+			time.Sleep(wait)
+			ctx, cancel := context.WithTimeout(context.Background(), statusRequestTimeout)
+			err := c.flushIntegrationStatus(ctx, integration, false)
+			cancel()
+
+			c.statuses.mu.Lock()
+			if err == nil && !c.statuses.pending(integration) {
+				c.statuses.retrying[integration] = false
+				c.statuses.mu.Unlock()
+				return
+			}
+			c.statuses.mu.Unlock()
+
+			if err != nil {
+				c.logger.WithError(err).WithField("integration", integration).Debug("Integration status retry failed")
+				if wait *= 2; wait > statusRetryMax {
+					wait = statusRetryMax
+				}
+			}
+		}
+	}()
+}
+
+// postIntegrationStatus sends one status to the server
+func (c *Client) postIntegrationStatus(ctx context.Context, status *models.IntegrationSetupStatus) error {
+	url, err := c.apiURL(EndpointIntegrations, "hosts/integration-status")
+	if err != nil {
+		return err
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"integration": status.Integration,
+		"enabled":     status.Enabled,
+		"status":      status.Status,
+		"sequence":    status.Sequence,
+	}).Info("Sending integration setup status to server")
+
+	req := c.client.R().
+		SetContext(ctx).
+		SetHeader("X-API-ID", c.credentials.APIID).
+		SetHeader("X-API-KEY", c.credentials.APIKey)
+	if err := c.setJSONPayload(req, status); err != nil {
+		return err
+	}
+	resp, err := req.Post(url)
+
+	if err != nil {
+		return fmt.Errorf("integration setup status request failed: %w", err)
+	}
+
+	if resp.StatusCode() != 200 {
+		return c.apiError("integration setup status request", resp)
+	}
+
+	c.logger.Info("Integration setup status sent successfully")
+	return nil
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statusServer records the statuses it receives and fails while failing is set
+type statusServer struct {
+	mu       sync.Mutex
+	failing  bool
+	received []models.IntegrationSetupStatus
+}
+
+func (s *statusServer) start(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var status models.IntegrationSetupStatus
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(body, &status))
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.received = append(s.received, status)
+		if s.failing {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (s *statusServer) setFailing(failing bool) {
+	s.mu.Lock()
+	s.failing = failing
+	s.mu.Unlock()
+}
+
+func (s *statusServer) statuses() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for _, st := range s.received {
+		out = append(out, st.Status)
+	}
+	return out
+}
+
+func fastStatusRetry(t *testing.T) {
+	oldMin, oldMax := statusRetryMin, statusRetryMax
+	statusRetryMin, statusRetryMax = 5*time.Millisecond, 10*time.Millisecond
+	t.Cleanup(func() { statusRetryMin, statusRetryMax = oldMin, oldMax })
+}
+
+func TestIntegrationStatusIsSequenced(t *testing.T) {
+	server := &statusServer{}
+	c := testClient(server.start(t).URL, "")
+
+	events := []models.InstallEvent{{Step: "detect_os", Status: "in_progress"}}
+	require.NoError(t, c.SendIntegrationSetupStatus(context.Background(), &models.IntegrationSetupStatus{Integration: "compliance", Status: "installing", InstallEvents: events}))
+	events[0].Status = "done"
+	assert.Equal(t, "in_progress", c.statuses.latest["compliance"].InstallEvents[0].Status, "recorded status is a copy")
+	require.NoError(t, c.SendIntegrationSetupStatus(context.Background(), &models.IntegrationSetupStatus{Integration: "compliance", Status: "ready"}))
+
+	require.Len(t, server.received, 2)
+	first, second := server.received[0], server.received[1]
+	assert.Less(t, first.Sequence, second.Sequence)
+	assert.NotEmpty(t, first.Timestamp)
+}
+
+func TestFailedStatusIsRetried(t *testing.T) {
+	fastStatusRetry(t)
+	server := &statusServer{failing: true}
+	c := testClient(server.start(t).URL, "")
+
+	err := c.SendIntegrationSetupStatus(context.Background(), &models.IntegrationSetupStatus{Integration: "docker", Status: "ready"})
+	require.Error(t, err)
+	server.setFailing(false)
+
+	assert.Eventually(t, func() bool { return len(server.statuses()) == 2 }, time.Second, 5*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, []string{"ready", "ready"}, server.statuses(), "retries stop once delivered")
+}
+
+func TestRetryNeverSendsSupersededStatus(t *testing.T) {
+	fastStatusRetry(t)
+	server := &statusServer{failing: true}
+	c := testClient(server.start(t).URL, "")
+	// Hold the delivery lock so the background retry can't run before the
+	// newer status is recorded
+	lock := c.statuses.sendLock("compliance")
+
+	require.Error(t, c.SendIntegrationSetupStatus(context.Background(), &models.IntegrationSetupStatus{Integration: "compliance", Status: "installing"}))
+	lock.Lock()
+	server.setFailing(false)
+	c.statuses.record(&models.IntegrationSetupStatus{Integration: "compliance", Status: "ready"})
+	lock.Unlock()
+
+	assert.Eventually(t, func() bool { return len(server.statuses()) == 2 }, time.Second, 5*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, []string{"installing", "ready"}, server.statuses())
+}
+
+func TestResendIntegrationStatuses(t *testing.T) {
+	server := &statusServer{}
+	c := testClient(server.start(t).URL, "")
+	ctx := context.Background()
+
+	require.NoError(t, c.SendIntegrationSetupStatus(ctx, &models.IntegrationSetupStatus{Integration: "docker", Status: "ready"}))
+	require.NoError(t, c.SendIntegrationSetupStatus(ctx, &models.IntegrationSetupStatus{Integration: "compliance", Status: "installing"}))
+	require.NoError(t, c.SendIntegrationSetupStatus(ctx, &models.IntegrationSetupStatus{Integration: "compliance", Status: "ready"}))
+	require.NoError(t, c.ResendIntegrationStatuses(ctx))
+
+	require.Len(t, server.received, 5)
+	assert.Equal(t, "compliance", server.received[3].Integration)
+	assert.Equal(t, "ready", server.received[3].Status)
+	assert.Equal(t, server.received[2].Sequence, server.received[3].Sequence, "a resend keeps the original sequence")
+	assert.Equal(t, "docker", server.received[4].Integration)
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// RedactJSON applies the configured redaction to a JSON body. An invalid
+// redaction config fails every upload rather than sending data unmasked.
+func (c *Client) RedactJSON(body []byte) ([]byte, error) {
+	if c.redactErr != nil {
+		return nil, fmt.Errorf("redaction config is invalid: %w", c.redactErr)
+	}
+	return c.redactor.JSON(body)
+}
+
+// jsonBody marshals v and redacts it
+func (c *Client) jsonBody(v interface{}) ([]byte, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	return c.RedactJSON(body)
+}
+
+// redactGzipText redacts a gzip-compressed text document such as a checklist
+// or HTML report
+func (c *Client) redactGzipText(gzBody []byte) ([]byte, error) {
+	if c.redactErr != nil {
+		return nil, fmt.Errorf("redaction config is invalid: %w", c.redactErr)
+	}
+	if c.redactor == nil {
+		return gzBody, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(gzBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress payload for redaction: %w", err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress payload for redaction: %w", err)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(c.redactor.String(string(body)))); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// redactGzipJSON redacts a gzip-compressed JSON document such as an SBOM
+func (c *Client) redactGzipJSON(gzBody []byte) ([]byte, error) {
+	if c.redactErr != nil {
+		return nil, fmt.Errorf("redaction config is invalid: %w", c.redactErr)
+	}
+	if c.redactor == nil {
+		return gzBody, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(gzBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress payload for redaction: %w", err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress payload for redaction: %w", err)
+	}
+	if body, err = c.redactor.JSON(body); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"patchmon-agent/internal/redact"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendUpdateIsRedacted(t *testing.T) {
+	var got map[string]interface{}
+	c := testClient(captureUpdate(t, &got).URL, "")
+	r, err := redact.New(&models.RedactionConfig{Fields: []string{"gateway_ip"}, IPRanges: []string{"192.168.0.0/16"}})
+	require.NoError(t, err)
+	c.redactor = r
+
+	_, err = c.SendUpdate(context.Background(), &models.ReportPayload{
+		Hostname:  "web-1",
+		IP:        "192.168.1.10",
+		GatewayIP: "203.0.113.1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "web-1", got["hostname"])
+	assert.Equal(t, redact.DefaultReplacement, got["ip"])
+	assert.Equal(t, redact.DefaultReplacement, got["gatewayIp"])
+}
+
+func TestInvalidRedactionNeverSendsUnmasked(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+	defer srv.Close()
+
+	c := testClient(srv.URL, "")
+	c.redactErr = errors.New("bad pattern")
+	_, err := c.SendUpdate(context.Background(), &models.ReportPayload{Hostname: "web-1"})
+	assert.ErrorContains(t, err, "redaction config is invalid")
+	assert.False(t, called)
+}
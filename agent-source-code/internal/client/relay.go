@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// DefaultRelaySocketPath is the relay's socket when relay.listen or relay_url
+// is just "unix"
+const DefaultRelaySocketPath = "/run/patchmon/relay.sock"
+
+// relaySocketHost stands in for the host of URLs sent over a relay's unix
+// socket; the relay forwards everything to its own server regardless
+const relaySocketHost = "patchmon-relay"
+
+// ServerURL returns the base URL server requests go to: relay_url when it is
+// set, otherwise patchmon_server
+func ServerURL(cfg *models.Config) string {
+	if cfg.RelayURL == "" {
+		return cfg.PatchmonServer
+	}
+	if _, ok := RelaySocket(cfg); ok {
+		return "http://" + relaySocketHost
+	}
+	return strings.TrimRight(cfg.RelayURL, "/")
+}
+
+// RelaySocket returns the socket path of a "unix" or "unix:<path>" relay_url
+func RelaySocket(cfg *models.Config) (string, bool) {
+	return SocketPath(cfg.RelayURL)
+}
+
+// SocketPath parses a "unix" or "unix:<path>" relay address
+func SocketPath(spec string) (string, bool) {
+	if spec != "unix" && !strings.HasPrefix(spec, "unix:") {
+		return "", false
+	}
+	if path := strings.TrimPrefix(spec, "unix:"); path != "" && path != "unix" {
+		return path, true
+	}
+	return DefaultRelaySocketPath, true
+}
+
+// RelayDialContext returns a dial function connecting to the relay's unix
+// socket whatever address is asked for, or nil when relay_url is not a socket
+func RelayDialContext(cfg *models.Config) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	path, ok := RelaySocket(cfg)
+	if !ok {
+		return nil
+	}
+	var d net.Dialer
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return d.DialContext(ctx, "unix", path)
+	}
+}
+
+// RelayTLSConfig returns the TLS settings for an https relay_url, or nil for
+// a unix socket or when no relay is configured. The relay requires a client
+// certificate; relay.cert_file is reread at each handshake so a renewed
+// certificate is picked up without a restart.
+func RelayTLSConfig(cfg *models.Config, skipVerify bool) (*tls.Config, error) {
+	if cfg.RelayURL == "" {
+		return nil, nil
+	}
+	if _, ok := RelaySocket(cfg); ok {
+		return nil, nil
+	}
+	u, err := url.Parse(cfg.RelayURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("relay_url must be https://host:port, unix or unix:/path.sock, got %q", cfg.RelayURL)
+	}
+	rc := cfg.Relay
+	if rc == nil || rc.CertFile == "" || rc.KeyFile == "" {
+		return nil, errors.New("relay_url needs relay.cert_file and relay.key_file: the relay only accepts agents with a client certificate")
+	}
+	return clientCertTLSConfig(rc.CertFile, rc.KeyFile, rc.CAFile, skipVerify, "relay client certificate")
+}
+
+// LoadCertPool reads a PEM CA bundle
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates in %s", path)
+	}
+	return pool, nil
+}
+
+// relayTransport returns a transport that reaches relay_url
+func relayTransport(cfg *models.Config, skipVerify bool) (*http.Transport, error) {
+	tlsConfig, err := RelayTLSConfig(cfg, skipVerify)
+	if err != nil {
+		return nil, err
+	}
+	t := newTransport(skipVerify)
+	if dial := RelayDialContext(cfg); dial != nil {
+		t.DialContext = dial
+		t.Proxy = nil
+		return t, nil
+	}
+	t.TLSClientConfig = tlsConfig
+	return t, nil
+}
@@ -0,0 +1,33 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// ErrUnsupportedBySchema is returned, wrapped, for uploads the server's payload
+// schema has no endpoint for
+var ErrUnsupportedBySchema = errors.New("not supported by the server's payload schema")
+
+// SetSchemaVersion sets the payload schema the server announced in its
+// handshake. 0 means the server didn't announce one and accepts the current
+// schema.
+func (c *Client) SetSchemaVersion(v int) {
+	c.schemaVersion.Store(int32(v))
+}
+
+// SchemaVersion returns the payload schema requests are rendered for
+func (c *Client) SchemaVersion() int {
+	return models.ClampSchemaVersion(int(c.schemaVersion.Load()))
+}
+
+// requireSchema fails uploads that were added in schema min when the server
+// is older, instead of posting to an endpoint it doesn't have
+func (c *Client) requireSchema(min int, what string) error {
+	if v := c.SchemaVersion(); v < min {
+		return fmt.Errorf("%s needs payload schema %d, server accepts %d: %w", what, min, v, ErrUnsupportedBySchema)
+	}
+	return nil
+}
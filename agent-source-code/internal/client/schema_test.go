@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func reportWithNewerFields() *models.ReportPayload {
+	return &models.ReportPayload{
+		Hostname:          "web-1",
+		Packages:          []models.Package{{Name: "curl", CurrentVersion: "8.5", SourceClassification: "distro", SourceVendor: "Debian"}},
+		Repositories:      []models.Repository{{Name: "main", Classification: "distro"}},
+		Warnings:          []string{"apt lock held"},
+		Sections:          models.SectionStatuses{"packages": {Status: models.SectionOK}},
+		RefreshedSections: []string{"packages"},
+	}
+}
+
+// captureUpdate returns a server that records the report body it receives
+func captureUpdate(t *testing.T, got *map[string]interface{}) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(body, got))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"message":"ok"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSendUpdateUsesCurrentSchemaByDefault(t *testing.T) {
+	var got map[string]interface{}
+	c := testClient(captureUpdate(t, &got).URL, "")
+
+	_, err := c.SendUpdate(context.Background(), reportWithNewerFields())
+	require.NoError(t, err)
+	assert.Equal(t, float64(models.SchemaVersion), got["schemaVersion"])
+	assert.Contains(t, got, "sections")
+	assert.Contains(t, got, "refreshedSections")
+}
+
+func TestSendUpdateRendersForOlderServer(t *testing.T) {
+	var got map[string]interface{}
+	c := testClient(captureUpdate(t, &got).URL, "")
+	c.SetSchemaVersion(1)
+
+	payload := reportWithNewerFields()
+	_, err := c.SendUpdate(context.Background(), payload)
+	require.NoError(t, err)
+
+	for _, field := range []string{"schemaVersion", "sections", "refreshedSections", "warnings"} {
+		assert.NotContains(t, got, field)
+	}
+	pkg := got["packages"].([]interface{})[0].(map[string]interface{})
+	assert.NotContains(t, pkg, "sourceClassification")
+	assert.Equal(t, "curl", pkg["name"])
+
+	assert.Equal(t, "distro", payload.Packages[0].SourceClassification, "the caller's payload is left alone")
+}
+
+func TestSchemaVersionIsClamped(t *testing.T) {
+	c := testClient("http://unused", "")
+	c.SetSchemaVersion(models.SchemaVersion + 5)
+	assert.Equal(t, models.SchemaVersion, c.SchemaVersion())
+	c.SetSchemaVersion(-1)
+	assert.Equal(t, models.SchemaVersion, c.SchemaVersion())
+}
+
+func TestNewerUploadsRefusedForOlderServer(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+	defer srv.Close()
+
+	c := testClient(srv.URL, "")
+	c.SetSchemaVersion(1)
+	ctx := context.Background()
+	for name, send := range map[string]func() error{
+		"language packages": func() error { _, err := c.SendLanguagePackages(ctx, &models.LanguagePackagesPayload{}); return err },
+		"user accounts":     func() error { _, err := c.SendUserAccounts(ctx, &models.UserAccountsPayload{}); return err },
+		"TLS certificates":  func() error { _, err := c.SendTLSCertificates(ctx, &models.TLSCertificatesPayload{}); return err },
+		"scheduled tasks":   func() error { _, err := c.SendScheduledTasks(ctx, &models.ScheduledTasksPayload{}); return err },
+		"jails":             func() error { _, err := c.SendJails(ctx, &models.JailsPayload{}); return err },
+		"nspawn":            func() error { _, err := c.SendNspawn(ctx, &models.NspawnPayload{}); return err },
+		"proxmox":           func() error { _, err := c.SendProxmoxData(ctx, &models.ProxmoxPayload{}); return err },
+		"kubernetes":        func() error { _, err := c.SendKubernetesData(ctx, &models.KubernetesPayload{}); return err },
+	} {
+		err := send()
+		assert.True(t, errors.Is(err, ErrUnsupportedBySchema), "%s: got %v", name, err)
+	}
+	assert.False(t, called)
+}
+
+func TestPingHasNoBodyForSchema1(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"message":"pong"}`))
+	}))
+	defer srv.Close()
+
+	c := testClient(srv.URL, "")
+	c.SetSchemaVersion(1)
+	_, err := c.Ping(context.Background(), &models.PingRequest{Status: "active"})
+	require.NoError(t, err)
+	assert.Empty(t, body)
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NewServer creates a client for an entry of the servers list. configMgr is
+// the manager config.ForServer returned for it.
+func NewServer(configMgr *config.Manager, profile models.ServerProfile, logger *logrus.Logger) *Client {
+	c := New(configMgr, logger)
+	c.server = profile.Name
+	if len(profile.Payloads) == 0 {
+		return c
+	}
+	known := make(map[string]bool, len(EndpointNames))
+	for _, name := range EndpointNames {
+		known[name] = true
+	}
+	c.payloads = make(map[string]bool, len(profile.Payloads))
+	var unknown []string
+	for _, name := range profile.Payloads {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !known[name] {
+			unknown = append(unknown, name)
+			continue
+		}
+		c.payloads[name] = true
+	}
+	if len(unknown) > 0 {
+		logger.WithFields(logrus.Fields{"server": profile.Name, "unknown": unknown, "known": EndpointNames}).Warn("Ignoring unknown payload types")
+	}
+	return c
+}
+
+// SetServers sets the clients of the other servers payloads are copied to.
+// Call it before the client is shared.
+func (c *Client) SetServers(servers []*Client) {
+	c.servers = servers
+}
+
+// Servers returns the clients set with SetServers
+func (c *Client) Servers() []*Client {
+	return c.servers
+}
+
+// Server returns the servers entry this client sends to, "" for patchmon_server
+func (c *Client) Server() string {
+	return c.server
+}
+
+// takes reports whether this server wants payloads of type endpoint
+func (c *Client) takes(endpoint string) bool {
+	return c.payloads == nil || c.payloads[endpoint]
+}
+
+// mirror starts sending a payload of type endpoint to each server that takes
+// it and returns a function that waits for them, so
+//
+//	defer c.mirror(EndpointDocker, send)()
+//
+// sends to the other servers while this client sends to its own. Their
+// failures are logged rather than returned: each server gets its copy
+// whether or not the others are reachable.
+func (c *Client) mirror(endpoint string, send func(server *Client) error) (wait func()) {
+	var wg sync.WaitGroup
+	for _, s := range c.servers {
+		if !s.takes(endpoint) {
+			continue
+		}
+		wg.Add(1)
+		go func(s *Client) {
+			defer wg.Done()
+			if err := send(s); err != nil {
+				c.logger.WithError(err).WithFields(logrus.Fields{
+					"server":  s.server,
+					"payload": endpoint,
+				}).Warn("Failed to send to server")
+			}
+		}(s)
+	}
+	return wg.Wait
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/redact"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingServer counts the API paths it is sent and keeps the last request
+// body and hostname query parameter for each
+type recordingServer struct {
+	mu        sync.Mutex
+	paths     []string
+	bodies    map[string][]byte
+	hostnames map[string]string
+}
+
+func (r *recordingServer) start(t *testing.T, status int) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.paths = append(r.paths, req.URL.Path)
+		if r.bodies == nil {
+			r.bodies, r.hostnames = map[string][]byte{}, map[string]string{}
+		}
+		r.bodies[req.URL.Path] = body
+		r.hostnames[req.URL.Path] = req.URL.Query().Get("hostname")
+		r.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"message":"ok"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func (r *recordingServer) sent() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.paths...)
+}
+
+// last returns the body and hostname parameter last sent to path
+func (r *recordingServer) last(path string) (body []byte, hostname string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.bodies[path], r.hostnames[path]
+}
+
+func gzipBytes(t *testing.T, s string) []byte {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write([]byte(s))
+	require.NoError(t, zw.Close())
+	return gz.Bytes()
+}
+
+func gunzipString(t *testing.T, b []byte) string {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	require.NoError(t, err)
+	out, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestPayloadsCopiedToServers(t *testing.T) {
+	var main, msp, audit, down recordingServer
+	c := testClient(main.start(t, http.StatusOK), "")
+	mspClient := testClient(msp.start(t, http.StatusOK), "")
+	mspClient.server = "msp"
+	auditClient := testClient(audit.start(t, http.StatusOK), "")
+	auditClient.server = "audit"
+	auditClient.payloads = map[string]bool{EndpointCompliance: true}
+	downClient := testClient(down.start(t, http.StatusServiceUnavailable), "")
+	downClient.server = "down"
+	c.SetServers([]*Client{mspClient, auditClient, downClient})
+
+	_, err := c.SendDockerData(context.Background(), &models.DockerPayload{Hostname: "web-1"})
+	require.NoError(t, err, "a failing server doesn't fail the upload")
+	_, err = c.SendComplianceData(context.Background(), &models.CompliancePayload{Hostname: "web-1"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"/api/v1/integrations/docker", "/api/v1/compliance/scans"}, main.sent())
+	assert.ElementsMatch(t, []string{"/api/v1/integrations/docker", "/api/v1/compliance/scans"}, msp.sent())
+	assert.Equal(t, []string{"/api/v1/compliance/scans"}, audit.sent(), "only the payload types listed")
+	assert.NotEmpty(t, down.sent())
+
+	// HTML reports follow the scan results
+	gz := gzipBytes(t, "<html></html>")
+	require.NoError(t, c.SendComplianceHTMLReport(context.Background(), &models.ComplianceHTMLReportInfo{ReportID: "r-1"}, gz))
+	assert.Contains(t, msp.sent(), "/api/v1/compliance/reports")
+	assert.Equal(t, []string{"/api/v1/compliance/scans", "/api/v1/compliance/reports"}, audit.sent())
+
+	// Results of a command go only to the server that sent it
+	require.NoError(t, c.SendPackageUpdate(context.Background(), &models.PackageUpdatePayload{Result: &models.PackageUpdateResult{}}))
+	require.NoError(t, c.SendComplianceCKL(context.Background(), &models.ComplianceCKLInfo{ProfileID: "stig"}, gz))
+	assert.Len(t, msp.sent(), 3)
+
+	// Image SBOMs go with the Docker data. Redaction is per server, so a
+	// server without it gets the caller's metadata and body, not this
+	// client's redacted copies.
+	c.redactor, err = redact.New(&models.RedactionConfig{Fields: []string{"hostname"}})
+	require.NoError(t, err)
+	c.SetSchemaVersion(2)
+	mspClient.SetSchemaVersion(2)
+	sbom := `{"bomFormat":"CycloneDX","hostname":"web-1"}`
+	require.NoError(t, c.SendImageSBOM(context.Background(), &models.ImageSBOMInfo{ImageID: "sha256:abc", Hostname: "web-1"}, gzipBytes(t, sbom)))
+	assert.Contains(t, msp.sent(), "/api/v1/integrations/docker/sbom")
+	assert.NotContains(t, audit.sent(), "/api/v1/integrations/docker/sbom")
+
+	body, hostname := main.last("/api/v1/integrations/docker/sbom")
+	assert.Equal(t, redact.DefaultReplacement, hostname)
+	assert.NotContains(t, gunzipString(t, body), "web-1")
+	body, hostname = msp.last("/api/v1/integrations/docker/sbom")
+	assert.Equal(t, "web-1", hostname)
+	assert.JSONEq(t, sbom, gunzipString(t, body))
+}
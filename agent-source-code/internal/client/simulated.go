@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// EnrollSimulatedHosts asks the server for credentials for fake hosts, sent
+// with this host's own credentials. Servers that don't allow simulated hosts
+// refuse with ERR_SIMULATION_DISABLED.
+func (c *Client) EnrollSimulatedHosts(ctx context.Context, payload *models.SimulatedHostsRequest) (*models.SimulatedHostsResponse, error) {
+	url, err := c.apiURL(EndpointReport, "hosts/simulated")
+	if err != nil {
+		return nil, err
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"url":   url,
+		"hosts": len(payload.Hostnames),
+	}).Debug("Enrolling simulated hosts")
+
+	resp, err := c.client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("X-API-ID", c.credentials.APIID).
+		SetHeader("X-API-KEY", c.credentials.APIKey).
+		SetBody(payload).
+		SetResult(&models.SimulatedHostsResponse{}).
+		Post(url)
+
+	if err != nil {
+		return nil, fmt.Errorf("simulated host enrollment failed: %w", err)
+	}
+
+	if resp.StatusCode() != 200 {
+		c.logger.WithField("response", resp.String()).Debug("Full error response from simulated host enrollment")
+		return nil, c.apiError("simulated host enrollment", resp)
+	}
+
+	result, ok := resp.Result().(*models.SimulatedHostsResponse)
+	if !ok {
+		return nil, fmt.Errorf("invalid response format")
+	}
+
+	return result, nil
+}
+
+// ForHost returns a client that sends as another host. It shares this
+// client's connections and settings but keeps its own backoff and integration
+// statuses, so one fake host being rate limited doesn't stop the others. Fake
+// hosts are not copied to servers entries, which never enrolled them.
+//
+// Client holds atomics and can't be copied, so every field is listed here;
+// TestForHostCoversEveryField fails when a new one is missed.
+func (c *Client) ForHost(credentials *models.Credentials) *Client {
+	host := &Client{
+		client:      c.client,
+		config:      c.config,
+		credentials: credentials,
+		logger:      c.logger,
+		redactor:    c.redactor,
+		redactErr:   c.redactErr,
+		endpoints:   c.endpoints,
+		statuses:    newStatusTracker(),
+		authHeaders: c.authHeaders,
+		relayErr:    c.relayErr,
+		tlsErr:      c.tlsErr,
+		server:      c.server,
+		payloads:    c.payloads,
+	}
+	host.schemaVersion.Store(c.schemaVersion.Load())
+	return host
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForHostKeepsPerHostState(t *testing.T) {
+	c := testClient("http://patchmon.test", "")
+	c.server = "msp"
+	c.SetServers([]*Client{testClient("http://other.test", "")})
+	c.SetSchemaVersion(2)
+	c.backoff.Store(&backoffState{until: time.Now().Add(time.Hour)})
+
+	creds := &models.Credentials{APIID: "sim-1", APIKey: "k"}
+	host := c.ForHost(creds)
+	assert.Same(t, creds, host.credentials)
+	assert.Same(t, c.client, host.client, "connections are shared")
+	assert.Equal(t, "msp", host.server)
+	assert.Equal(t, c.schemaVersion.Load(), host.schemaVersion.Load())
+
+	assert.Nil(t, host.backoff.Load(), "another host's pause doesn't apply")
+	assert.NotSame(t, c.statuses, host.statuses)
+	assert.NotSame(t, host.statuses, c.ForHost(creds).statuses, "each fake host tracks its own statuses")
+	assert.Empty(t, host.servers, "fake hosts aren't copied to servers entries")
+}
+
+// TestForHostCoversEveryField fails when Client gains a field, so ForHost is
+// checked for whether the new field should be shared with fake hosts
+func TestForHostCoversEveryField(t *testing.T) {
+	handled := map[string]bool{
+		// shared
+		"client": true, "config": true, "logger": true, "redactor": true, "redactErr": true,
+		"endpoints": true, "authHeaders": true, "relayErr": true, "tlsErr": true,
+		"server": true, "payloads": true, "schemaVersion": true,
+		// per host
+		"credentials": true, "statuses": true, "backoff": true, "servers": true,
+	}
+	typ := reflect.TypeOf(Client{})
+	for i := 0; i < typ.NumField(); i++ {
+		assert.True(t, handled[typ.Field(i).Name], "ForHost doesn't handle Client.%s", typ.Field(i).Name)
+	}
+}
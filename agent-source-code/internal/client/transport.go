@@ -0,0 +1,86 @@
+package client
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+	"time"
+	"weak"
+
+	"patchmon-agent/internal/utils"
+)
+
+var (
+	transportMu sync.Mutex
+	transports  = map[bool]*http.Transport{}
+	// dedicated are the relay and client_tls transports built for single
+	// clients, held weakly so a replaced client's transport can be collected
+	dedicated []weak.Pointer[http.Transport]
+)
+
+// sharedTransport returns the process-wide transport for the given TLS mode.
+// Every Client uses it, so reports, pings and handler calls reuse pooled
+// keep-alive connections (HTTP/2 where the server offers it) instead of paying
+// a TLS handshake per request.
+func sharedTransport(skipVerify bool) *http.Transport {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	if t, ok := transports[skipVerify]; ok {
+		return t
+	}
+	t := newTransport(skipVerify)
+	transports[skipVerify] = t
+	return t
+}
+
+func newTransport(skipVerify bool) *http.Transport {
+	return &http.Transport{
+		Proxy:       http.ProxyFromEnvironment,
+		DialContext: utils.DialContext(30 * time.Second),
+		// A custom TLS config disables Go's automatic HTTP/2 unless forced
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: skipVerify},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          16,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// dedicatedTransport registers t, built for one client, with
+// CloseIdleConnections
+func dedicatedTransport(t *http.Transport) *http.Transport {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	dedicated = append(liveTransports(), weak.Make(t))
+	return t
+}
+
+// liveTransports drops the dedicated transports that were collected. Callers
+// hold transportMu.
+func liveTransports() []weak.Pointer[http.Transport] {
+	live := dedicated[:0]
+	for _, p := range dedicated {
+		if p.Value() != nil {
+			live = append(live, p)
+		}
+	}
+	return live
+}
+
+// CloseIdleConnections closes the pooled connections of every transport this
+// package built, shared or dedicated, so the next requests dial afresh
+func CloseIdleConnections() {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	for _, t := range transports {
+		t.CloseIdleConnections()
+	}
+	dedicated = liveTransports()
+	for _, p := range dedicated {
+		if t := p.Value(); t != nil {
+			t.CloseIdleConnections()
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloseIdleConnectionsReachesEveryTransport(t *testing.T) {
+	var dials atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			dials.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	get := func(t *testing.T, c *http.Client) {
+		resp, err := c.Get(srv.URL)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+	}
+	for name, transport := range map[string]*http.Transport{
+		"shared":    sharedTransport(false),
+		"dedicated": dedicatedTransport(newTransport(false)),
+	} {
+		c := &http.Client{Transport: transport}
+		dials.Store(0)
+		get(t, c)
+		get(t, c)
+		assert.Equal(t, int32(1), dials.Load(), "%s: the connection is pooled", name)
+
+		CloseIdleConnections()
+		get(t, c)
+		assert.Equal(t, int32(2), dials.Load(), "%s: the pooled connection was closed", name)
+	}
+}
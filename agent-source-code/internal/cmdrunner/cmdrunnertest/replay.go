@@ -0,0 +1,109 @@
+// Package cmdrunnertest replays commands recorded with cmdrunner.Recorder, so
+// parsers can be tested against output captured on each supported distro
+package cmdrunnertest
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"patchmon-agent/internal/cmdrunner"
+)
+
+// Replay is a cmdrunner.Runner answering from fixture files. Commands without
+// a fixture fail as if not installed, and are listed by Missed.
+type Replay struct {
+	fixtures map[string]cmdrunner.Fixture
+	paths    map[string]string
+
+	mu     sync.Mutex
+	missed []string
+}
+
+// Load reads every *.txt fixture in dir, failing the test on a bad one
+func Load(t testing.TB, dir string) *Replay {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no command fixtures in %s", dir)
+	}
+	r := New()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := cmdrunner.ParseFixture(data)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		r.Add(f)
+	}
+	return r
+}
+
+// New returns a Replay with no fixtures, for tests that Add their own
+func New() *Replay {
+	return &Replay{fixtures: map[string]cmdrunner.Fixture{}, paths: map[string]string{}}
+}
+
+// Add registers a fixture, replacing any for the same command line. A
+// command recorded by absolute path is found on PATH at that path.
+func (r *Replay) Add(f cmdrunner.Fixture) {
+	r.fixtures[f.Key()] = f
+	name := f.Args[0]
+	if filepath.IsAbs(name) {
+		r.paths[name] = name
+		r.paths[filepath.Base(name)] = name
+	} else if _, ok := r.paths[name]; !ok {
+		r.paths[name] = "/usr/bin/" + name
+	}
+}
+
+// Output returns the recorded output, with a cmdrunner.ExitError for a
+// recorded non-zero exit
+func (r *Replay) Output(_ context.Context, name string, args ...string) ([]byte, error) {
+	key := cmdrunner.CommandKey(name, args...)
+	f, ok := r.fixtures[key]
+	if !ok {
+		r.mu.Lock()
+		r.missed = append(r.missed, key)
+		r.mu.Unlock()
+		return nil, &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+	if f.ExitCode != 0 {
+		return f.Stdout, &cmdrunner.ExitError{Code: f.ExitCode}
+	}
+	return f.Stdout, nil
+}
+
+// Run writes the recorded output to w; dir is ignored
+func (r *Replay) Run(ctx context.Context, w io.Writer, _, name string, args ...string) error {
+	out, err := r.Output(ctx, name, args...)
+	if _, writeErr := w.Write(out); writeErr != nil && err == nil {
+		err = writeErr
+	}
+	return err
+}
+
+// LookPath finds commands that have at least one fixture
+func (r *Replay) LookPath(name string) (string, error) {
+	if path, ok := r.paths[name]; ok {
+		return path, nil
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+// Missed lists the command lines run without a fixture, sorted
+func (r *Replay) Missed() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	missed := append([]string(nil), r.missed...)
+	sort.Strings(missed)
+	return missed
+}
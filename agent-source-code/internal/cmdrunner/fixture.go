@@ -0,0 +1,164 @@
+package cmdrunner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A fixture file records one command run:
+//
+//	$ "dnf" "check-update"
+//	exit 100
+//	--
+//	<standard output, to the end of the file>
+//
+// The arguments are Go-quoted so spaces, tabs and newlines in them survive.
+// The exit line is left out for a zero exit.
+
+// fixtureSeparator ends the header of a fixture file
+const fixtureSeparator = "--\n"
+
+// Fixture is one recorded command and what it printed
+type Fixture struct {
+	Args     []string // The command name and its arguments
+	ExitCode int
+	Stdout   []byte
+}
+
+// Key identifies the command line, for matching a run to its fixture
+func (f Fixture) Key() string {
+	return CommandKey(f.Args[0], f.Args[1:]...)
+}
+
+// CommandKey is the quoted command line a fixture header holds
+func CommandKey(name string, args ...string) string {
+	quoted := make([]string, 0, len(args)+1)
+	for _, arg := range append([]string{name}, args...) {
+		quoted = append(quoted, strconv.Quote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Marshal renders the fixture file
+func (f Fixture) Marshal() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("$ " + f.Key() + "\n")
+	if f.ExitCode != 0 {
+		fmt.Fprintf(&buf, "exit %d\n", f.ExitCode)
+	}
+	buf.WriteString(fixtureSeparator)
+	buf.Write(f.Stdout)
+	return buf.Bytes()
+}
+
+// ParseFixture reads a fixture file
+func ParseFixture(data []byte) (Fixture, error) {
+	var f Fixture
+	header, stdout, ok := bytes.Cut(data, []byte(fixtureSeparator))
+	if !ok {
+		return f, errors.New("fixture has no -- line")
+	}
+	f.Stdout = stdout
+	for _, line := range strings.Split(strings.TrimSpace(string(header)), "\n") {
+		switch {
+		case strings.HasPrefix(line, "$ "):
+			args, err := unquoteArgs(line[2:])
+			if err != nil {
+				return f, err
+			}
+			f.Args = args
+		case strings.HasPrefix(line, "exit "):
+			code, err := strconv.Atoi(strings.TrimSpace(line[5:]))
+			if err != nil {
+				return f, fmt.Errorf("bad exit line %q", line)
+			}
+			f.ExitCode = code
+		case strings.HasPrefix(line, "#"), line == "":
+		default:
+			return f, fmt.Errorf("unexpected fixture header line %q", line)
+		}
+	}
+	if len(f.Args) == 0 {
+		return f, errors.New("fixture has no $ command line")
+	}
+	return f, nil
+}
+
+// unquoteArgs splits a line of Go-quoted strings
+func unquoteArgs(line string) ([]string, error) {
+	var args []string
+	for line = strings.TrimSpace(line); line != ""; line = strings.TrimSpace(line) {
+		quoted, err := strconv.QuotedPrefix(line)
+		if err != nil {
+			return nil, fmt.Errorf("bad argument in %q: %w", line, err)
+		}
+		arg, _ := strconv.Unquote(quoted)
+		args = append(args, arg)
+		line = line[len(quoted):]
+	}
+	return args, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Recorder runs commands through Runner and writes a fixture for each run
+// into Dir, for building test data from a real host
+type Recorder struct {
+	Runner Runner
+	Dir    string
+
+	mu sync.Mutex
+	n  int
+}
+
+// Output runs the command and records what it printed
+func (r *Recorder) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	out, err := r.Runner.Output(ctx, name, args...)
+	code, ok := ExitCode(err)
+	if err == nil || ok {
+		r.record(Fixture{Args: append([]string{name}, args...), ExitCode: code, Stdout: out})
+	}
+	return out, err
+}
+
+// Run runs the command and records what it wrote to either stream. The
+// wrapped Runner must be a StreamRunner.
+func (r *Recorder) Run(ctx context.Context, w io.Writer, dir, name string, args ...string) error {
+	streamer, ok := r.Runner.(StreamRunner)
+	if !ok {
+		return fmt.Errorf("%T cannot stream command output", r.Runner)
+	}
+	var buf bytes.Buffer
+	err := streamer.Run(ctx, io.MultiWriter(w, &buf), dir, name, args...)
+	code, ok := ExitCode(err)
+	if err == nil || ok {
+		r.record(Fixture{Args: append([]string{name}, args...), ExitCode: code, Stdout: buf.Bytes()})
+	}
+	return err
+}
+
+// LookPath is the wrapped Runner's
+func (r *Recorder) LookPath(name string) (string, error) {
+	return r.Runner.LookPath(name)
+}
+
+func (r *Recorder) record(f Fixture) {
+	r.mu.Lock()
+	r.n++
+	n := r.n
+	r.mu.Unlock()
+
+	name := fmt.Sprintf("%03d-%s.txt", n, unsafeFileChars.ReplaceAllString(filepath.Base(f.Args[0]), "_"))
+	if err := os.MkdirAll(r.Dir, 0700); err == nil {
+		_ = os.WriteFile(filepath.Join(r.Dir, name), f.Marshal(), 0600)
+	}
+}
@@ -0,0 +1,96 @@
+package cmdrunner
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixtureRoundTrip(t *testing.T) {
+	f := Fixture{
+		Args:     []string{"rpm", "-qa", "--qf", "%{NAME}\t%{VERSION}\n", "with space"},
+		ExitCode: 100,
+		Stdout:   []byte("bash\t5.1.8\n--\nnot a separator\n"),
+	}
+	parsed, err := ParseFixture(f.Marshal())
+	require.NoError(t, err)
+	assert.Equal(t, f, parsed)
+
+	ok, err := ParseFixture([]byte("$ \"uname\" \"-s\"\n--\nLinux\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, ok.ExitCode)
+	assert.Equal(t, CommandKey("uname", "-s"), ok.Key())
+
+	for _, bad := range []string{"Linux\n", "--\nLinux\n", "$ uname\n--\n", "$ \"uname\"\nexit x\n--\n"} {
+		_, err := ParseFixture([]byte(bad))
+		assert.Error(t, err, "%q", bad)
+	}
+}
+
+type fakeRunner map[string]Fixture
+
+func (r fakeRunner) Output(_ context.Context, name string, args ...string) ([]byte, error) {
+	f := r[CommandKey(name, args...)]
+	if f.ExitCode != 0 {
+		return f.Stdout, &ExitError{Code: f.ExitCode}
+	}
+	return f.Stdout, nil
+}
+
+func (r fakeRunner) Run(ctx context.Context, w io.Writer, _, name string, args ...string) error {
+	out, err := r.Output(ctx, name, args...)
+	_, _ = w.Write(out)
+	return err
+}
+
+func (fakeRunner) LookPath(name string) (string, error) { return "/usr/sbin/" + name, nil }
+
+func TestRecorder(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "capture")
+	check := Fixture{Args: []string{"dnf", "check-update"}, ExitCode: 100, Stdout: []byte("curl.x86_64 7.76.1 baseos\n")}
+	r := &Recorder{Runner: fakeRunner{check.Key(): check}, Dir: dir}
+
+	out, err := r.Output(context.Background(), "dnf", "check-update")
+	assert.Equal(t, check.Stdout, out)
+	code, ok := ExitCode(err)
+	assert.True(t, ok)
+	assert.Equal(t, 100, code)
+
+	_, err = r.Output(context.Background(), "/usr/sbin/pkg", "info")
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, "001-dnf.txt"))
+	require.NoError(t, err)
+	recorded, err := ParseFixture(data)
+	require.NoError(t, err)
+	assert.Equal(t, check, recorded)
+	assert.FileExists(t, filepath.Join(dir, "002-pkg.txt"))
+}
+
+func TestRecorderRun(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "capture")
+	eval := Fixture{Args: []string{"oscap", "xccdf", "eval"}, ExitCode: 2, Stdout: []byte("Result\tfail\n")}
+	r := &Recorder{Runner: fakeRunner{eval.Key(): eval}, Dir: dir}
+
+	var buf bytes.Buffer
+	err := r.Run(context.Background(), &buf, "", "oscap", "xccdf", "eval")
+	code, _ := ExitCode(err)
+	assert.Equal(t, 2, code)
+	assert.Equal(t, eval.Stdout, buf.Bytes())
+
+	data, err := os.ReadFile(filepath.Join(dir, "001-oscap.txt"))
+	require.NoError(t, err)
+	recorded, err := ParseFixture(data)
+	require.NoError(t, err)
+	assert.Equal(t, eval, recorded)
+
+	// A Runner that cannot stream is refused
+	r = &Recorder{Runner: struct{ Runner }{fakeRunner{}}, Dir: dir}
+	assert.Error(t, r.Run(context.Background(), &buf, "", "oscap"))
+}
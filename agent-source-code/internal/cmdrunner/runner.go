@@ -0,0 +1,104 @@
+// Package cmdrunner runs the external commands whose output the agent parses.
+// Collectors take a Runner instead of calling os/exec directly, so tests can
+// replay output captured on real hosts (see cmdrunnertest).
+package cmdrunner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"patchmon-agent/internal/utils"
+)
+
+// Runner runs commands and finds them on PATH
+type Runner interface {
+	// Output runs the command in the C locale and returns its standard
+	// output. A non-zero exit returns the output so far and an error that
+	// ExitCode understands.
+	Output(ctx context.Context, name string, args ...string) ([]byte, error)
+	// LookPath finds an executable like exec.LookPath
+	LookPath(name string) (string, error)
+}
+
+// StreamRunner is a Runner that can also pass a command's output on as it is
+// written, for installers and scanners that run for minutes or leave their
+// results in files
+type StreamRunner interface {
+	Runner
+	// Run runs the command in dir (the agent's own when empty), in the C
+	// locale, writing its standard output and standard error to w
+	Run(ctx context.Context, w io.Writer, dir, name string, args ...string) error
+}
+
+// CombinedOutput runs the command with r and returns its standard output and
+// standard error together
+func CombinedOutput(ctx context.Context, r StreamRunner, name string, args ...string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := r.Run(ctx, &buf, "", name, args...)
+	return buf.Bytes(), err
+}
+
+// Exec runs commands for real
+type Exec struct{}
+
+// Output runs the command with utils.CommandContext
+func (Exec) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return utils.CommandContext(ctx, name, args...).Output()
+}
+
+// Run runs the command with utils.CommandContext
+func (Exec) Run(ctx context.Context, w io.Writer, dir, name string, args ...string) error {
+	cmd := utils.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Stdout = w
+	cmd.Stderr = w
+	return cmd.Run()
+}
+
+// LookPath is exec.LookPath
+func (Exec) LookPath(name string) (string, error) {
+	return exec.LookPath(name)
+}
+
+// recordEnv names a directory to record every command run and its output to,
+// for capturing test fixtures on a real host
+const recordEnv = "PATCHMON_RECORD_COMMANDS"
+
+// Default is the Runner collectors start with
+var Default StreamRunner = defaultRunner()
+
+func defaultRunner() StreamRunner {
+	if dir := os.Getenv(recordEnv); dir != "" {
+		return &Recorder{Runner: Exec{}, Dir: dir}
+	}
+	return Exec{}
+}
+
+// ExitError is a non-zero exit from a replayed command
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return "exit status " + strconv.Itoa(e.Code)
+}
+
+// ExitCode returns the exit status carried by err, from a real or replayed
+// command. ok is false when err is not an exit status (the command could not
+// be started, or was killed).
+func ExitCode(err error) (code int, ok bool) {
+	var replayed *ExitError
+	if errors.As(err, &replayed) {
+		return replayed.Code, true
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		return exitErr.ExitCode(), true
+	}
+	return 0, false
+}
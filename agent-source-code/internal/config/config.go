@@ -2,15 +2,23 @@
 package config
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"net"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
 	"runtime"
+	"strings"
+	"time"
 
-	"patchmon-agent/pkg/models"
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
 )
 
@@ -27,8 +35,16 @@ const (
 	DefaultLogLevel = "info"
 	// CronFilePath is the path to the cron configuration file (Unix only)
 	CronFilePath = "/etc/cron.d/patchmon-agent"
+	// LegacyCredentialsFile is the shell-style credentials file written by
+	// agents that predate credentials.yml
+	LegacyCredentialsFile = "/etc/patchmon/credentials"
 )
 
+// DefaultFallbackDNSServers are public resolvers used to tell a broken local
+// resolver apart from a server that is actually down. The IPv6 ones answer on
+// IPv6-only hosts, where the IPv4 ones fail at once with no route.
+var DefaultFallbackDNSServers = []string{"1.1.1.1:53", "9.9.9.9:53", "[2606:4700:4700::1111]:53", "[2620:fe::fe]:53"}
+
 // Windows default paths
 const (
 	DefaultConfigFileWindows      = "C:\\ProgramData\\PatchMon\\config.yml"
@@ -63,7 +79,14 @@ var AvailableIntegrations = []string{
 	"compliance",
 	"ssh-proxy-enabled",
 	"rdp-proxy-enabled",
-	// Future: "proxmox", "kubernetes", etc.
+	"language-packages",
+	"user-accounts",
+	"tls-certificates",
+	"scheduled-tasks",
+	"jails",
+	"nspawn",
+	"proxmox",
+	"kubernetes",
 }
 
 // Manager handles configuration management
@@ -71,6 +94,7 @@ type Manager struct {
 	config      *models.Config
 	credentials *models.Credentials
 	configFile  string
+	profile     string // set by UseProfile
 }
 
 // New creates a new configuration manager
@@ -102,6 +126,12 @@ func (m *Manager) GetConfigFile() string {
 	return m.configFile
 }
 
+// StatePath returns the path of an agent state file (history, caches) kept
+// next to the config file
+func (m *Manager) StatePath(name string) string {
+	return filepath.Join(filepath.Dir(m.configFile), name)
+}
+
 // GetConfig returns the current configuration
 func (m *Manager) GetConfig() *models.Config {
 	return m.config
@@ -127,7 +157,11 @@ func (m *Manager) LoadConfig() error {
 		return fmt.Errorf("error reading config file: %w", err)
 	}
 
-	if err := viper.Unmarshal(m.config); err != nil {
+	if err := viper.Unmarshal(m.config, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		dateToStringHook,
+	))); err != nil {
 		return fmt.Errorf("error unmarshaling config: %w", err)
 	}
 
@@ -247,7 +281,7 @@ func (m *Manager) ensureComplianceNested() {
 		}
 	}
 	if _, has := nested["scan_interval"]; !has {
-		nested["scan_interval"] = 1440
+		nested["scan_interval"] = DefaultComplianceScanInterval
 	}
 	m.config.Integrations["compliance"] = nested
 	delete(m.config.Integrations, "compliance_openscap_enabled")
@@ -287,13 +321,19 @@ func (m *Manager) SaveCredentials(apiID, apiKey string) error {
 		return err
 	}
 
+	// Re-registering keeps a read-only (observer) credential read-only
+	observer := m.credentials != nil && m.credentials.Observer
 	m.credentials = &models.Credentials{
-		APIID:  apiID,
-		APIKey: apiKey,
+		APIID:    apiID,
+		APIKey:   apiKey,
+		Observer: observer,
 	}
 
 	// Generate YAML content manually to avoid viper's default file creation
 	content := fmt.Sprintf("api_id: %s\napi_key: %s\n", apiID, apiKey)
+	if observer {
+		content += "observer: true\n"
+	}
 
 	// Use atomic write pattern to prevent TOCTOU race condition:
 	// 1. Write to temp file with secure permissions from the start
@@ -371,6 +411,155 @@ func (m *Manager) SaveConfig() error {
 	configViper.Set("report_offset", m.config.ReportOffset)
 	configViper.Set("package_cache_refresh_mode", m.config.PackageCacheRefreshMode)
 	configViper.Set("package_cache_refresh_max_age", m.config.PackageCacheRefreshMaxAge)
+	if len(m.config.FallbackDNSServers) > 0 {
+		configViper.Set("fallback_dns_servers", m.config.FallbackDNSServers)
+	}
+	if len(m.config.DNSOverHTTPS) > 0 {
+		configViper.Set("dns_over_https", m.config.DNSOverHTTPS)
+	}
+	if len(m.config.IgnorePackages) > 0 {
+		configViper.Set("ignore_packages", m.config.IgnorePackages)
+	}
+	if len(m.config.IgnoreRepositories) > 0 {
+		configViper.Set("ignore_repositories", m.config.IgnoreRepositories)
+	}
+	if m.config.DockerSBOM {
+		configViper.Set("docker_sbom", m.config.DockerSBOM)
+	}
+	if m.config.DockerDigestHours != nil {
+		configViper.Set("docker_digest_hours", *m.config.DockerDigestHours)
+	}
+	if m.config.DisablePackageWatch {
+		configViper.Set("disable_package_watch", m.config.DisablePackageWatch)
+	}
+	if m.config.LocalAPIListen != "" {
+		configViper.Set("local_api_listen", m.config.LocalAPIListen)
+	}
+	if m.config.LocalAPIToken != "" {
+		configViper.Set("local_api_token", m.config.LocalAPIToken)
+	}
+	if m.config.LocalAPIGroup != "" {
+		configViper.Set("local_api_group", m.config.LocalAPIGroup)
+	}
+	if m.config.MetricsListen != "" {
+		configViper.Set("metrics_listen", m.config.MetricsListen)
+	}
+	if m.config.HostnameOverride != "" {
+		configViper.Set("hostname_override", m.config.HostnameOverride)
+	}
+	if m.config.UseFQDN {
+		configViper.Set("use_fqdn", m.config.UseFQDN)
+	}
+	if m.config.WSPingInterval > 0 {
+		configViper.Set("ws_ping_interval", m.config.WSPingInterval)
+	}
+	if m.config.WSReadTimeout > 0 {
+		configViper.Set("ws_read_timeout", m.config.WSReadTimeout)
+	}
+	if m.config.AutoInstallTools != nil {
+		configViper.Set("auto_install_tools", *m.config.AutoInstallTools)
+	}
+	if m.config.DockerBenchScript != "" {
+		configViper.Set("docker_bench_script", m.config.DockerBenchScript)
+	}
+	if m.config.DockerBenchImage != "" {
+		configViper.Set("docker_bench_image", m.config.DockerBenchImage)
+	}
+	if m.config.ImageScanConcurrency > 0 {
+		configViper.Set("image_scan_concurrency", m.config.ImageScanConcurrency)
+	}
+	if m.config.ImageScanTimeout > 0 {
+		configViper.Set("image_scan_timeout", m.config.ImageScanTimeout)
+	}
+	if m.config.ImageScanSkipHours != nil {
+		configViper.Set("image_scan_skip_hours", *m.config.ImageScanSkipHours)
+	}
+	if m.config.ImageScanCacheHours != nil {
+		configViper.Set("image_scan_cache_hours", *m.config.ImageScanCacheHours)
+	}
+	if m.config.CVEFeedCache != nil {
+		configViper.Set("cve_feed_cache", *m.config.CVEFeedCache)
+	}
+	if m.config.CVEFeedURL != "" {
+		configViper.Set("cve_feed_url", m.config.CVEFeedURL)
+	}
+	if m.config.CVEFeedMaxAge > 0 {
+		configViper.Set("cve_feed_max_age", m.config.CVEFeedMaxAge)
+	}
+	if len(m.config.ContentTrustedKeys) > 0 {
+		configViper.Set("content_trusted_keys", m.config.ContentTrustedKeys)
+	}
+	if len(m.config.ImageCVEWaivers) > 0 {
+		configViper.Set("image_cve_waivers", m.config.ImageCVEWaivers)
+	}
+	if m.config.MaxRSSMB > 0 {
+		configViper.Set("max_rss_mb", m.config.MaxRSSMB)
+	}
+	if m.config.GOGC != nil {
+		configViper.Set("gogc", *m.config.GOGC)
+	}
+	if m.config.MemoryLimitMB != nil {
+		configViper.Set("memory_limit_mb", *m.config.MemoryLimitMB)
+	}
+	if m.config.MaxProcs > 0 {
+		configViper.Set("max_procs", m.config.MaxProcs)
+	}
+	if m.config.StartupReportWindow != nil {
+		configViper.Set("startup_report_window", *m.config.StartupReportWindow)
+	}
+	if m.config.PayloadEncryptionKey != "" {
+		configViper.Set("payload_encryption_key", m.config.PayloadEncryptionKey)
+	}
+	if m.config.ObserverMode {
+		configViper.Set("observer_mode", true)
+	}
+	if m.config.FixFilePermissions {
+		configViper.Set("fix_file_permissions", true)
+	}
+	if m.config.Notifications != nil {
+		configViper.Set("notifications", m.config.Notifications)
+	}
+	if len(m.config.TLSCertPaths) > 0 {
+		configViper.Set("tls_cert_paths", m.config.TLSCertPaths)
+	}
+	if m.config.Redaction != nil {
+		configViper.Set("redaction", m.config.Redaction)
+	}
+	if m.config.DockerUpdate != nil {
+		configViper.Set("docker_update", m.config.DockerUpdate)
+	}
+	if m.config.DockerPrune != nil {
+		configViper.Set("docker_prune", m.config.DockerPrune)
+	}
+	if m.config.Maintenance != nil {
+		configViper.Set("maintenance", m.config.Maintenance)
+	}
+	if len(m.config.Endpoints) > 0 {
+		configViper.Set("endpoints", m.config.Endpoints)
+	}
+	if m.config.AuthHeaders != nil {
+		configViper.Set("auth_headers", m.config.AuthHeaders)
+	}
+	if m.config.ClientTLS != nil {
+		configViper.Set("client_tls", m.config.ClientTLS)
+	}
+	if m.config.RelayURL != "" {
+		configViper.Set("relay_url", m.config.RelayURL)
+	}
+	if m.config.Relay != nil {
+		configViper.Set("relay", m.config.Relay)
+	}
+	if len(m.config.Servers) > 0 {
+		configViper.Set("servers", m.config.Servers)
+	}
+	if m.config.Tracing != nil {
+		configViper.Set("tracing", m.config.Tracing)
+	}
+	for flag, allowed := range m.permissionFlags() {
+		if allowed != nil {
+			configViper.Set(flag, *allowed)
+		}
+	}
 
 	// Always save integrations map with all available integrations
 	if m.config.Integrations == nil {
@@ -403,6 +592,72 @@ func (m *Manager) SaveConfig() error {
 	return nil
 }
 
+// ImportLegacyCredentials converts the shell-style credentials file of older
+// agents (API_ID="..." / API_KEY="..." / PATCHMON_URL="...") into
+// credentials.yml, and fills in the server URL if config.yml has none. It does
+// nothing when credentials.yml already exists. The legacy file is removed once
+// imported.
+func (m *Manager) ImportLegacyCredentials(path string) (bool, error) {
+	if _, err := os.Stat(m.config.CredentialsFile); err == nil {
+		return false, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("error opening legacy credentials: %w", err)
+	}
+	values, err := parseLegacyCredentials(f)
+	_ = f.Close()
+	if err != nil {
+		return false, fmt.Errorf("error reading legacy credentials: %w", err)
+	}
+	if values["API_ID"] == "" || values["API_KEY"] == "" {
+		return false, fmt.Errorf("legacy credentials file %s has no API_ID/API_KEY", path)
+	}
+
+	if m.config.PatchmonServer == "" {
+		if server := values["PATCHMON_URL"]; server != "" {
+			m.config.PatchmonServer = server
+		} else if server := values["PATCHMON_SERVER"]; server != "" {
+			m.config.PatchmonServer = server
+		}
+		if err := m.SaveConfig(); err != nil {
+			return false, err
+		}
+	}
+	if err := m.SaveCredentials(values["API_ID"], values["API_KEY"]); err != nil {
+		return false, err
+	}
+	if err := os.Remove(path); err != nil {
+		return true, fmt.Errorf("imported legacy credentials but failed to remove %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// parseLegacyCredentials reads KEY=VALUE lines, ignoring comments, an optional
+// "export " prefix and surrounding quotes
+func parseLegacyCredentials(r io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	return values, scanner.Err()
+}
+
 // SetUpdateInterval sets the update interval and saves it to config file
 func (m *Manager) SetUpdateInterval(interval int) error {
 	if interval <= 0 {
@@ -450,6 +705,153 @@ func (m *Manager) GetPackageCacheRefreshMaxAge() int {
 	return m.config.PackageCacheRefreshMaxAge
 }
 
+// imageRefPattern matches [registry[:port]/]repo[:tag][@sha256:digest]
+var imageRefPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*(:[0-9]+)?(/[a-z0-9._-]+)*(:[A-Za-z0-9._-]{1,128})?(@sha256:[a-f0-9]{64})?$`)
+
+// ValidImageRef reports whether ref is a plain Docker image reference
+func ValidImageRef(ref string) bool {
+	return imageRefPattern.MatchString(ref)
+}
+
+// DigestPinnedImageRef reports whether ref is a valid image reference pinned
+// by digest (repo@sha256:...)
+func DigestPinnedImageRef(ref string) bool {
+	return ValidImageRef(ref) && strings.Contains(ref, "@sha256:")
+}
+
+// SetDockerBenchImage sets the Docker Bench image reference and saves to config
+// file. Empty restores the default image.
+func (m *Manager) SetDockerBenchImage(ref string) error {
+	if ref != "" && !ValidImageRef(ref) {
+		return fmt.Errorf("invalid docker bench image reference: %q", ref)
+	}
+	m.config.DockerBenchImage = ref
+	return m.SaveConfig()
+}
+
+// IsObserverMode reports whether the agent only collects and reports, set by
+// observer_mode in config.yml or observer in the credentials file
+func (m *Manager) IsObserverMode() bool {
+	return m.config.ObserverMode || (m.credentials != nil && m.credentials.Observer)
+}
+
+// allow_* flags gating server-initiated actions
+const (
+	AllowReportNow      = "allow_report_now"
+	AllowComplianceScan = "allow_compliance_scan"
+	AllowRemediation    = "allow_remediation"
+	AllowAgentUpdate    = "allow_agent_update"
+	AllowSSHProxy       = "allow_ssh_proxy"
+	AllowRDPProxy       = "allow_rdp_proxy"
+	AllowDockerActions  = "allow_docker_actions"
+	AllowPackageUpdate  = "allow_package_update"
+	AllowRunPatch       = "allow_run_patch"
+)
+
+// permissionsOffByDefault are the allow_* flags that refuse their action until
+// config.yml sets them to true
+var permissionsOffByDefault = map[string]bool{
+	AllowPackageUpdate: true,
+}
+
+func (m *Manager) permissionFlags() map[string]*bool {
+	return map[string]*bool{
+		AllowReportNow:      m.config.AllowReportNow,
+		AllowComplianceScan: m.config.AllowComplianceScan,
+		AllowRemediation:    m.config.AllowRemediation,
+		AllowAgentUpdate:    m.config.AllowAgentUpdate,
+		AllowSSHProxy:       m.config.AllowSSHProxy,
+		AllowRDPProxy:       m.config.AllowRDPProxy,
+		AllowDockerActions:  m.config.AllowDockerActions,
+		AllowPackageUpdate:  m.config.AllowPackageUpdate,
+		AllowRunPatch:       m.config.AllowRunPatch,
+	}
+}
+
+// IsActionAllowed reports whether an allow_* flag permits the server to
+// trigger that action. Unset flags allow it, so existing hosts keep working,
+// except those in permissionsOffByDefault.
+func (m *Manager) IsActionAllowed(flag string) bool {
+	allowed, ok := m.permissionFlags()[flag]
+	if !ok {
+		return true
+	}
+	if allowed == nil {
+		return !permissionsOffByDefault[flag]
+	}
+	return *allowed
+}
+
+// ActionPermissions returns every allow_* flag with its effective value
+func (m *Manager) ActionPermissions() map[string]bool {
+	out := map[string]bool{}
+	for flag := range m.permissionFlags() {
+		out[flag] = m.IsActionAllowed(flag)
+	}
+	return out
+}
+
+// GetAutoInstallTools reports whether scanners may install their own packages
+// and images, defaulting to true
+func (m *Manager) GetAutoInstallTools() bool {
+	return m.config.AutoInstallTools == nil || *m.config.AutoInstallTools
+}
+
+// dateToStringHook turns the time.Time the YAML parser makes of an unquoted
+// date back into text for string fields such as image_cve_waivers.expires
+func dateToStringHook(_ reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	t, ok := data.(time.Time)
+	if !ok || to.Kind() != reflect.String {
+		return data, nil
+	}
+	if t.Equal(t.Truncate(24 * time.Hour)) {
+		return t.Format(time.DateOnly), nil
+	}
+	return t.Format(time.RFC3339), nil
+}
+
+// GetCVEFeedCache reports whether image CVE scans keep their OVAL feeds on
+// disk, defaulting to true
+func (m *Manager) GetCVEFeedCache() bool {
+	return m.config.CVEFeedCache == nil || *m.config.CVEFeedCache
+}
+
+// GetDockerDigestCheckInterval returns how long a registry's answer for a
+// running container's tag is reused, defaulting to DefaultDockerDigestCheck.
+// Zero turns the registry check off.
+func (m *Manager) GetDockerDigestCheckInterval() time.Duration {
+	hours := m.config.DockerDigestHours
+	if hours == nil {
+		return DefaultDockerDigestCheck
+	}
+	if *hours <= 0 {
+		return 0
+	}
+	return time.Duration(*hours) * time.Hour
+}
+
+// GetFallbackDNSServers returns the resolvers used to cross-check the system resolver,
+// defaulting to DefaultFallbackDNSServers. Entries without a port get ":53".
+func (m *Manager) GetFallbackDNSServers() []string {
+	servers := m.config.FallbackDNSServers
+	if len(servers) == 0 {
+		servers = DefaultFallbackDNSServers
+	}
+	out := make([]string, 0, len(servers))
+	for _, s := range servers {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			// Bare IPv6 addresses may come bracketed or not
+			s = net.JoinHostPort(strings.Trim(s, "[]"), "53")
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
 // IsIntegrationEnabled checks if an integration is enabled
 // Returns false if not specified (default behavior - integrations are disabled by default)
 // For compliance, returns true if enabled (true) or on-demand ("on-demand"), false if disabled
@@ -649,14 +1051,21 @@ func (m *Manager) SetComplianceScanners(openscapEnabled, dockerBenchEnabled bool
 	return m.SaveConfig()
 }
 
-// GetComplianceScanInterval returns the compliance scan interval in minutes (default 1440, min 60, max 10080).
+// DefaultDockerDigestCheck is how long a registry's answer for a running
+// container's tag is reused before the registry is asked again
+const DefaultDockerDigestCheck = 6 * time.Hour
+
+// DefaultComplianceScanInterval is the scheduled compliance scan cadence in minutes (weekly)
+const DefaultComplianceScanInterval = 10080
+
+// GetComplianceScanInterval returns the compliance scan interval in minutes (default weekly, min 60, max 10080).
 func (m *Manager) GetComplianceScanInterval() int {
 	if m.config.Integrations == nil {
-		return 1440
+		return DefaultComplianceScanInterval
 	}
 	val := m.getComplianceVal("scan_interval")
 	if val == nil {
-		return 1440
+		return DefaultComplianceScanInterval
 	}
 	var minutes int
 	switch v := val.(type) {
@@ -665,7 +1074,7 @@ func (m *Manager) GetComplianceScanInterval() int {
 	case float64:
 		minutes = int(v)
 	default:
-		return 1440
+		return DefaultComplianceScanInterval
 	}
 	if minutes < 60 {
 		minutes = 60
@@ -690,6 +1099,113 @@ func (m *Manager) SetComplianceScanInterval(minutes int) error {
 	return m.SaveConfig()
 }
 
+// GetComplianceUploadHTMLReport returns whether the HTML report of each
+// OpenSCAP scan is uploaded along with its results
+func (m *Manager) GetComplianceUploadHTMLReport() bool {
+	if m.config.Integrations == nil {
+		return false
+	}
+	b, _ := m.getComplianceVal("upload_html_report").(bool)
+	return b
+}
+
+// DefaultRoleProfiles are compliance profiles for host roles that
+// integrations.compliance.role_profiles doesn't map
+var DefaultRoleProfiles = map[string][]string{
+	"docker-host": {"docker-bench"},
+}
+
+// GetComplianceRoles returns the host roles from integrations.compliance.roles
+func (m *Manager) GetComplianceRoles() []string {
+	if m.config.Integrations == nil {
+		return nil
+	}
+	return stringList(m.getComplianceVal("roles"))
+}
+
+// GetComplianceRoleProfiles returns integrations.compliance.role_profiles on
+// top of DefaultRoleProfiles
+func (m *Manager) GetComplianceRoleProfiles() map[string][]string {
+	profiles := make(map[string][]string, len(DefaultRoleProfiles))
+	for role, p := range DefaultRoleProfiles {
+		profiles[role] = p
+	}
+	if m.config.Integrations == nil {
+		return profiles
+	}
+	if raw, ok := m.getComplianceVal("role_profiles").(map[string]interface{}); ok {
+		for role, v := range raw {
+			profiles[strings.ToLower(strings.TrimSpace(role))] = stringList(v)
+		}
+	}
+	return profiles
+}
+
+// GetComplianceRoleScanProfiles returns the profiles scheduled scans run for
+// the host's roles, in role order without duplicates. It is empty when no
+// role maps to a profile, and scheduled scans then use the default profile.
+func (m *Manager) GetComplianceRoleScanProfiles() []string {
+	mapping := m.GetComplianceRoleProfiles()
+	var profiles []string
+	seen := make(map[string]bool)
+	for _, role := range m.GetComplianceRoles() {
+		for _, p := range mapping[role] {
+			if !seen[p] {
+				seen[p] = true
+				profiles = append(profiles, p)
+			}
+		}
+	}
+	return profiles
+}
+
+// SetComplianceRoles saves the host roles and, if not nil, the role to
+// profile mapping
+func (m *Manager) SetComplianceRoles(roles []string, roleProfiles map[string][]string) error {
+	if m.config.Integrations == nil {
+		m.config.Integrations = make(map[string]interface{})
+	}
+	m.ensureComplianceNested()
+	nested := m.config.Integrations["compliance"].(map[string]interface{})
+	nested["roles"] = normalizeRoles(roles)
+	if roleProfiles != nil {
+		mapping := make(map[string]interface{}, len(roleProfiles))
+		for role, p := range roleProfiles {
+			mapping[strings.ToLower(strings.TrimSpace(role))] = p
+		}
+		nested["role_profiles"] = mapping
+	}
+	return m.SaveConfig()
+}
+
+// stringList reads a YAML list of strings, or a comma-separated string
+func stringList(v interface{}) []string {
+	var out []string
+	switch val := v.(type) {
+	case []string:
+		out = val
+	case []interface{}:
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+	case string:
+		out = strings.Split(val, ",")
+	}
+	return normalizeRoles(out)
+}
+
+func normalizeRoles(in []string) []string {
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
 // setupDirectories creates necessary directories
 // SECURITY: Use restrictive permissions (0750) for config directories
 // This prevents unauthorized users from reading agent configuration
@@ -0,0 +1,232 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLegacyCredentials(t *testing.T) {
+	input := `# PatchMon Agent Credentials
+PATCHMON_URL="https://patchmon.example.com"
+export API_ID='patchmon_abc123'
+API_KEY=s3cret=with=equals
+
+not a setting
+`
+	values, err := parseLegacyCredentials(strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, "https://patchmon.example.com", values["PATCHMON_URL"])
+	assert.Equal(t, "patchmon_abc123", values["API_ID"])
+	assert.Equal(t, "s3cret=with=equals", values["API_KEY"])
+	assert.Len(t, values, 3)
+}
+
+func TestGetAutoInstallTools(t *testing.T) {
+	m := New()
+	assert.True(t, m.GetAutoInstallTools(), "scanners install their own tools unless told not to")
+
+	off := false
+	m.GetConfig().AutoInstallTools = &off
+	assert.False(t, m.GetAutoInstallTools())
+}
+
+func TestValidImageRef(t *testing.T) {
+	for _, ref := range []string{
+		"jauderho/docker-bench-security:latest",
+		"registry.internal:5000/security/docker-bench:1.6.1",
+		"jauderho/docker-bench-security@sha256:" + strings.Repeat("ab", 32),
+	} {
+		assert.True(t, ValidImageRef(ref), ref)
+	}
+	for _, ref := range []string{
+		"",
+		"--privileged",
+		"repo:tag; rm -rf /",
+		"repo@sha256:short",
+	} {
+		assert.False(t, ValidImageRef(ref), ref)
+	}
+}
+
+func TestDigestPinnedImageRef(t *testing.T) {
+	assert.True(t, DigestPinnedImageRef("jauderho/docker-bench-security@sha256:"+strings.Repeat("ab", 32)))
+	assert.True(t, DigestPinnedImageRef("registry.internal:5000/bench:1.6@sha256:"+strings.Repeat("ab", 32)))
+	assert.False(t, DigestPinnedImageRef("jauderho/docker-bench-security:latest"))
+	assert.False(t, DigestPinnedImageRef("repo@sha256:short"))
+}
+
+func TestObserverCredentialSurvivesReRegistration(t *testing.T) {
+	dir := t.TempDir()
+	m := New()
+	m.GetConfig().CredentialsFile = filepath.Join(dir, "credentials.yml")
+	require.NoError(t, os.WriteFile(m.GetConfig().CredentialsFile, []byte("api_id: a\napi_key: b\nobserver: true\n"), 0600))
+	require.NoError(t, m.LoadCredentials())
+	assert.True(t, m.IsObserverMode())
+
+	require.NoError(t, m.SaveCredentials("c", "d"))
+	require.NoError(t, m.LoadCredentials())
+	assert.Equal(t, "c", m.GetCredentials().APIID)
+	assert.True(t, m.IsObserverMode(), "a new key must not lift observer mode")
+}
+
+func TestNotificationsRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	m := New()
+	m.SetConfigFile(filepath.Join(dir, "config.yml"))
+	m.GetConfig().Notifications = &models.NotificationsConfig{
+		ReportFailureThreshold: 5,
+		Webhooks:               []models.WebhookTarget{{URL: "https://hooks.example.com/x", Format: "slack", Events: []string{"reboot_required"}}},
+		Exec:                   []models.ExecTarget{{Command: []string{"/usr/local/bin/alert", "--host"}}},
+	}
+	require.NoError(t, m.SaveConfig())
+
+	loaded := New()
+	loaded.SetConfigFile(filepath.Join(dir, "config.yml"))
+	require.NoError(t, loaded.LoadConfig())
+	assert.Equal(t, m.GetConfig().Notifications, loaded.GetConfig().Notifications)
+}
+
+func TestComplianceRoleScanProfiles(t *testing.T) {
+	dir := t.TempDir()
+	m := New()
+	m.SetConfigFile(filepath.Join(dir, "config.yml"))
+	assert.Empty(t, m.GetComplianceRoleScanProfiles())
+
+	require.NoError(t, m.SetComplianceRoles([]string{" Web ", "db", "docker-host"}, map[string][]string{
+		"web": {"level1_server", "level2_server"},
+		"db":  {"level2_server", "stig"},
+	}))
+
+	loaded := New()
+	loaded.SetConfigFile(filepath.Join(dir, "config.yml"))
+	require.NoError(t, loaded.LoadConfig())
+	assert.Equal(t, []string{"web", "db", "docker-host"}, loaded.GetComplianceRoles())
+	// Union in role order, with the built-in docker-host mapping
+	assert.Equal(t, []string{"level1_server", "level2_server", "stig", "docker-bench"}, loaded.GetComplianceRoleScanProfiles())
+
+	require.NoError(t, loaded.SetComplianceRoles([]string{"mail"}, nil))
+	assert.Empty(t, loaded.GetComplianceRoleScanProfiles())
+}
+
+func TestImageCVEWaiversLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`image_cve_waivers:
+  - cve: CVE-2024-0001
+    images: ["nginx", "registry.example.com/*"]
+    expires: 2026-03-31
+    justification: Not exploitable behind the proxy
+`), 0600))
+	m := New()
+	m.SetConfigFile(path)
+	require.NoError(t, m.LoadConfig())
+	assert.Equal(t, []models.ImageCVEWaiver{{
+		CVE:           "CVE-2024-0001",
+		Images:        []string{"nginx", "registry.example.com/*"},
+		Expires:       "2026-03-31",
+		Justification: "Not exploitable behind the proxy",
+	}}, m.GetConfig().ImageCVEWaivers, "an unquoted date stays a string")
+}
+
+func TestAuditFilePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows files are protected by ACLs")
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.Chmod(dir, 0750))
+	m := New()
+	m.SetConfigFile(filepath.Join(dir, "config.yml"))
+	m.GetConfig().CredentialsFile = filepath.Join(dir, "credentials.yml")
+	m.GetConfig().LogFile = filepath.Join(dir, "logs", "patchmon-agent.log")
+	require.NoError(t, os.WriteFile(m.GetConfigFile(), []byte("log_level: info\n"), 0640))
+	require.NoError(t, os.WriteFile(m.GetConfig().CredentialsFile, []byte("api_id: a\napi_key: b\n"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "logs"), 0777))
+	require.NoError(t, os.Chmod(filepath.Join(dir, "logs"), 0777))
+
+	issues := m.AuditFilePermissions()
+	require.Len(t, issues, 2)
+	assert.Equal(t, m.GetConfig().CredentialsFile, issues[0].Path)
+	assert.Equal(t, "readable by all users (mode 0644)", issues[0].Problem)
+	assert.Equal(t, "chmod 0600", issues[0].Fix)
+	assert.Equal(t, filepath.Join(dir, "logs"), issues[1].Path)
+	assert.Equal(t, "writable by all users, writable by its group (mode 0777)", issues[1].Problem)
+
+	for _, issue := range issues {
+		require.NoError(t, FixFilePermission(issue))
+	}
+	assert.Empty(t, m.AuditFilePermissions())
+	info, err := os.Stat(m.GetConfig().CredentialsFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	m.GetConfig().LogFile = "/var/log/patchmon-agent.log"
+	assert.Empty(t, m.AuditFilePermissions(), "a shared log directory is not the agent's to judge")
+}
+
+func TestGetFallbackDNSServers(t *testing.T) {
+	m := New()
+	assert.Contains(t, m.GetFallbackDNSServers(), "[2606:4700:4700::1111]:53", "IPv6-only hosts need a resolver they can reach")
+
+	m.GetConfig().FallbackDNSServers = []string{"192.0.2.53", "2001:db8::53", "[2001:db8::54]", "[2001:db8::55]:5353", " "}
+	assert.Equal(t, []string{"192.0.2.53:53", "[2001:db8::53]:53", "[2001:db8::54]:53", "[2001:db8::55]:5353"}, m.GetFallbackDNSServers())
+}
+
+func TestForServer(t *testing.T) {
+	dir := t.TempDir()
+	m := New()
+	cfg := m.GetConfig()
+	cfg.PatchmonServer = "https://patchmon.customer.example"
+	cfg.RelayURL = "https://relay.customer.example:8443"
+	cfg.Endpoints = map[string]string{"compliance": "https://ingest.customer.example"}
+	cfg.UpdateInterval = 15
+	profile := models.ServerProfile{
+		Name:            "msp",
+		Server:          "https://central.msp.example",
+		CredentialsFile: filepath.Join(dir, "msp.yml"),
+		AllowCommands:   []string{"report_now"},
+	}
+	cfg.Servers = []models.ServerProfile{profile}
+
+	_, err := m.ForServer(profile)
+	require.ErrorContains(t, err, `server "msp": credentials file not found`)
+
+	require.NoError(t, os.WriteFile(profile.CredentialsFile, []byte("api_id: msp-id\napi_key: msp-key\nobserver: true\n"), 0600))
+	msp, err := m.ForServer(profile)
+	require.NoError(t, err)
+	assert.Equal(t, "https://central.msp.example", msp.GetConfig().PatchmonServer)
+	assert.Equal(t, "msp-id", msp.GetCredentials().APIID)
+	assert.True(t, msp.IsObserverMode())
+	assert.Empty(t, msp.GetConfig().RelayURL, "the relay belongs to patchmon_server")
+	assert.Empty(t, msp.GetConfig().Endpoints)
+	assert.Equal(t, 15, msp.GetConfig().UpdateInterval)
+	assert.Equal(t, "https://patchmon.customer.example", cfg.PatchmonServer, "the main config is unchanged")
+
+	require.NoError(t, m.ValidateServers())
+	cfg.Servers = append(cfg.Servers, profile)
+	assert.ErrorContains(t, m.ValidateServers(), "listed twice")
+	cfg.Servers = []models.ServerProfile{{Name: "msp", Server: "central.msp.example", CredentialsFile: profile.CredentialsFile}}
+	assert.ErrorContains(t, m.ValidateServers(), "must be an http(s) URL")
+}
+
+func TestUseProfile(t *testing.T) {
+	m := New()
+	require.NoError(t, m.UseProfile("staging"))
+	dir := filepath.Join(ProfilesDir(), "staging")
+	assert.Equal(t, "staging", m.Profile())
+	assert.Equal(t, filepath.Join(dir, "config.yml"), m.GetConfigFile())
+	assert.Equal(t, filepath.Join(dir, "credentials.yml"), m.GetConfig().CredentialsFile)
+	assert.Equal(t, filepath.Join(dir, "state.json"), m.StatePath("state.json"))
+	assert.NotEqual(t, DefaultLogFilePath(), m.GetConfig().LogFile)
+
+	for _, name := range []string{"", "../etc", "Prod", "a b", "-x", strings.Repeat("a", 64)} {
+		assert.Error(t, m.UseProfile(name), name)
+	}
+	assert.Equal(t, "staging", m.Profile())
+}
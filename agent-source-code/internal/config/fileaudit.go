@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// FilePermissionIssue is an agent file or directory that other users can read
+// or change, usually left behind by an installer run under the wrong account
+type FilePermissionIssue struct {
+	Path    string
+	Problem string // e.g. "readable by all users (mode 0644)"
+	Fix     string // What FixFilePermission does, e.g. "chmod 0600"
+
+	mode  fs.FileMode // Mode to set, or 0 to keep it
+	chown bool        // Give the file to the agent's user
+}
+
+// auditTarget is a path checked by AuditFilePermissions and the permission
+// bits it must not have
+type auditTarget struct {
+	path      string
+	forbidden fs.FileMode
+}
+
+// AuditFilePermissions checks the ownership and modes of config.yml,
+// credentials.yml, the state and log directories and the log file. Missing
+// files are skipped. Windows protects these with ACLs set by the installer and
+// is not checked.
+func (m *Manager) AuditFilePermissions() []FilePermissionIssue {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	stateDir := filepath.Dir(m.configFile)
+	targets := []auditTarget{
+		{m.configFile, 0o027},
+		{m.config.CredentialsFile, 0o077},
+		{stateDir, 0o027},
+		{m.config.LogFile, 0o027},
+	}
+	if dir := filepath.Dir(m.config.CredentialsFile); dir != stateDir {
+		targets = append(targets, auditTarget{dir, 0o027})
+	}
+	// A log directory of its own is the agent's; a shared one such as
+	// /var/log is left alone
+	if dir := filepath.Dir(m.config.LogFile); dir != stateDir && within(stateDir, dir) {
+		targets = append(targets, auditTarget{dir, 0o027})
+	}
+
+	var issues []FilePermissionIssue
+	for _, t := range targets {
+		info, err := os.Stat(t.path)
+		if err != nil {
+			continue
+		}
+		issues = append(issues, auditFile(t, info, os.Geteuid())...)
+	}
+	return issues
+}
+
+// auditFile returns the issues with one file. Ownership is only checked when
+// the agent runs as root: a root agent reading files another user can
+// rewrite would run with settings that user chose.
+func auditFile(t auditTarget, info fs.FileInfo, euid int) []FilePermissionIssue {
+	var issues []FilePermissionIssue
+	if uid, ok := fileOwner(info); ok && euid == 0 && uid != 0 {
+		issues = append(issues, FilePermissionIssue{
+			Path:    t.path,
+			Problem: fmt.Sprintf("owned by %s, not root", userName(uid)),
+			Fix:     "chown root",
+			chown:   true,
+		})
+	}
+
+	perm := info.Mode().Perm()
+	if bad := perm & t.forbidden; bad != 0 {
+		issues = append(issues, FilePermissionIssue{
+			Path:    t.path,
+			Problem: fmt.Sprintf("%s (mode %04o)", describeAccess(bad), perm),
+			Fix:     fmt.Sprintf("chmod %04o", perm&^t.forbidden),
+			mode:    perm &^ t.forbidden,
+		})
+	}
+	return issues
+}
+
+// describeAccess says who gets what from the given permission bits
+func describeAccess(bits fs.FileMode) string {
+	var parts []string
+	if bits&0o002 != 0 {
+		parts = append(parts, "writable by all users")
+	} else if bits&0o005 != 0 {
+		parts = append(parts, "readable by all users")
+	}
+	if bits&0o020 != 0 {
+		parts = append(parts, "writable by its group")
+	} else if bits&0o050 != 0 && len(parts) == 0 {
+		parts = append(parts, "readable by its group")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// FixFilePermission applies the issue's fix. Changing the owner needs root.
+func FixFilePermission(issue FilePermissionIssue) error {
+	if issue.chown {
+		if err := os.Chown(issue.Path, 0, -1); err != nil {
+			return fmt.Errorf("failed to change owner of %s: %w", issue.Path, err)
+		}
+	}
+	if issue.mode != 0 {
+		if err := os.Chmod(issue.Path, issue.mode); err != nil {
+			return fmt.Errorf("failed to change mode of %s: %w", issue.Path, err)
+		}
+	}
+	return nil
+}
+
+// within reports whether path is dir or below it
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
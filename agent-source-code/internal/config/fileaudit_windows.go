@@ -0,0 +1,17 @@
+//go:build windows
+
+package config
+
+import (
+	"io/fs"
+	"strconv"
+)
+
+// fileOwner is not used on Windows, where the installer sets ACLs instead
+func fileOwner(fs.FileInfo) (int, bool) {
+	return 0, false
+}
+
+func userName(uid int) string {
+	return "uid " + strconv.Itoa(uid)
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// profileNamePattern keeps a profile name usable as a directory and unit name
+var profileNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// ProfilesDir returns the directory holding named configuration profiles,
+// next to the default config file
+func ProfilesDir() string {
+	return filepath.Join(filepath.Dir(DefaultConfigFilePath()), "profiles")
+}
+
+// ValidateProfileName checks that name can be used as a profile
+func ValidateProfileName(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use lowercase letters, digits, - and _", name)
+	}
+	return nil
+}
+
+// UseProfile points the manager at the named profile. Each profile is a
+// directory under ProfilesDir with its own config.yml, credentials.yml,
+// log file and state files, so agents for different servers don't share
+// anything. credentials_file and log_file in the profile's config.yml still
+// override these defaults.
+func (m *Manager) UseProfile(name string) error {
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+	dir := filepath.Join(ProfilesDir(), name)
+	m.configFile = filepath.Join(dir, "config.yml")
+	m.config.CredentialsFile = filepath.Join(dir, "credentials.yml")
+	m.config.LogFile = filepath.Join(dir, "logs", "patchmon-agent.log")
+	m.profile = name
+	return nil
+}
+
+// Profile returns the profile in use, or "" for the default config
+func (m *Manager) Profile() string {
+	return m.profile
+}
+
+// ListProfiles returns the profiles that have a config.yml, sorted by name
+func ListProfiles() ([]string, error) {
+	entries, err := os.ReadDir(ProfilesDir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() || ValidateProfileName(e.Name()) != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(ProfilesDir(), e.Name(), "config.yml")); err == nil {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// ForServer returns a manager for one entry of the servers list: this config
+// pointed at the profile's server, credentials and payload key, with its
+// credentials loaded. The relay, endpoints, auth_headers and client_tls
+// belong to patchmon_server and aren't carried over.
+func (m *Manager) ForServer(p models.ServerProfile) (*Manager, error) {
+	if err := validateServerProfile(p); err != nil {
+		return nil, err
+	}
+	cfg := *m.config
+	cfg.PatchmonServer = p.Server
+	cfg.CredentialsFile = p.CredentialsFile
+	cfg.PayloadEncryptionKey = p.EncryptionKey
+	cfg.Endpoints = nil
+	cfg.AuthHeaders = nil
+	cfg.ClientTLS = nil
+	cfg.RelayURL = ""
+	cfg.Relay = nil
+	cfg.Servers = nil
+	profile := &Manager{config: &cfg, configFile: m.configFile}
+	if err := profile.LoadCredentials(); err != nil {
+		return nil, fmt.Errorf("server %q: %w", p.Name, err)
+	}
+	return profile, nil
+}
+
+// ValidateServers checks the servers list without loading credentials
+func (m *Manager) ValidateServers() error {
+	seen := make(map[string]bool, len(m.config.Servers))
+	for _, p := range m.config.Servers {
+		if err := validateServerProfile(p); err != nil {
+			return err
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("server %q is listed twice", p.Name)
+		}
+		seen[p.Name] = true
+	}
+	return nil
+}
+
+func validateServerProfile(p models.ServerProfile) error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("servers entry for %q has no name", p.Server)
+	}
+	u, err := url.Parse(p.Server)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("server %q: patchmon_server must be an http(s) URL, got %q", p.Name, p.Server)
+	}
+	if p.CredentialsFile == "" {
+		return fmt.Errorf("server %q has no credentials_file", p.Name)
+	}
+	return nil
+}
@@ -0,0 +1,375 @@
+// Package connectivity provides DNS and transport self-tests against the PatchMon server
+package connectivity
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// paddingSize is large enough that the request spans several full-size TCP
+	// segments (and TLS records), but small enough to fit default reverse proxy
+	// header buffers (nginx large_client_header_buffers is 8k per line).
+	paddingSize = 6000
+
+	dnsTimeout     = 5 * time.Second
+	dialTimeout    = 5 * time.Second
+	requestTimeout = 15 * time.Second
+)
+
+// Checker runs connectivity self-tests
+type Checker struct {
+	logger            *logrus.Logger
+	skipVerify        bool
+	tlsConfig         *tls.Config
+	fallbackResolvers []string
+}
+
+// New creates a new connectivity checker. fallbackResolvers are host:port
+// addresses queried directly, bypassing the system resolver configuration.
+func New(logger *logrus.Logger, skipVerify bool, fallbackResolvers []string) *Checker {
+	return &Checker{
+		logger:            logger,
+		skipVerify:        skipVerify,
+		fallbackResolvers: fallbackResolvers,
+	}
+}
+
+// SetTLSConfig makes the large request present a client certificate, for a
+// server or relay that requires one
+func (c *Checker) SetTLSConfig(tlsConfig *tls.Config) {
+	c.tlsConfig = tlsConfig
+}
+
+// Check resolves the server hostname via the system resolver and a fallback
+// resolver, tests TCP reachability, and sends a large padded request to detect
+// MTU or TLS middlebox problems. It never returns nil.
+func (c *Checker) Check(ctx context.Context, serverURL string) *models.ConnectivityCheck {
+	result := &models.ConnectivityCheck{
+		CheckedAt: utils.FormatTimeISO(utils.GetCurrentTimeUTC()),
+	}
+
+	host, port, err := SplitServerURL(serverURL)
+	if err != nil {
+		result.Problems = append(result.Problems, fmt.Sprintf("invalid server URL: %v", err))
+		return result
+	}
+	result.ServerHost = host
+
+	if net.ParseIP(host) != nil {
+		// Nothing to resolve; treat both resolvers as trivially successful
+		result.HostIsIP = true
+		result.SystemDNSOK = true
+		result.FallbackDNSOK = true
+	} else {
+		addrs, err := c.resolveSystem(ctx, host)
+		if err != nil {
+			result.SystemDNSError = err.Error()
+		} else {
+			result.SystemDNSOK = true
+			result.SystemDNSAddrs = addrs
+		}
+
+		resolver, addrs, err := c.resolveFallback(ctx, host)
+		result.FallbackResolver = resolver
+		if err != nil {
+			result.FallbackDNSError = err.Error()
+		} else {
+			result.FallbackDNSOK = true
+			result.FallbackDNSAddrs = addrs
+		}
+
+		// What the agent's own connections do when system DNS fails
+		if lookup := utils.FallbackLookup(); lookup != nil && !result.SystemDNSOK {
+			if addrs, err := lookup(ctx, host); err != nil {
+				result.DoHFallbackError = err.Error()
+			} else {
+				result.DoHFallbackOK = true
+				result.DoHFallbackAddrs = addrs
+			}
+		}
+	}
+
+	if result.SystemDNSOK {
+		result.TCPReachable = utils.TCPPing(host, port)
+	} else if result.DoHFallbackOK && len(result.DoHFallbackAddrs) > 0 {
+		result.TCPReachableByIP = utils.TCPPing(result.DoHFallbackAddrs[0], port)
+	} else if result.FallbackDNSOK && len(result.FallbackDNSAddrs) > 0 {
+		// The classic support case: DNS is broken locally but the server is fine
+		result.TCPReachableByIP = utils.TCPPing(result.FallbackDNSAddrs[0], port)
+	}
+
+	switch {
+	case result.HostIsIP:
+		result.AddressFamilies = c.checkFamilies(ctx, []string{host}, port)
+	case result.SystemDNSOK:
+		result.AddressFamilies = c.checkFamilies(ctx, result.SystemDNSAddrs, port)
+	case result.FallbackDNSOK:
+		result.AddressFamilies = c.checkFamilies(ctx, result.FallbackDNSAddrs, port)
+	}
+
+	if result.TCPReachable {
+		if err := c.largeRequest(ctx, serverURL); err != nil {
+			result.LargeRequestErr = err.Error()
+		} else {
+			result.LargeRequestOK = true
+		}
+	}
+
+	result.Problems = append(result.Problems, Diagnose(result)...)
+	return result
+}
+
+// Diagnose turns a check result into human readable problem descriptions.
+// Returns nil when everything passed.
+func Diagnose(r *models.ConnectivityCheck) []string {
+	var problems []string
+	switch {
+	case !r.SystemDNSOK && r.DoHFallbackOK:
+		problems = append(problems, fmt.Sprintf("system resolver cannot resolve %s; the agent reaches the server through the DNS-over-HTTPS fallback until it is fixed", r.ServerHost))
+	case !r.SystemDNSOK && r.FallbackDNSOK:
+		problems = append(problems, fmt.Sprintf("system resolver cannot resolve %s but %s can; check /etc/resolv.conf or local DNS", r.ServerHost, r.FallbackResolver))
+	case !r.SystemDNSOK && !r.FallbackDNSOK:
+		problems = append(problems, fmt.Sprintf("%s does not resolve via the system resolver or the fallback resolvers", r.ServerHost))
+	case r.SystemDNSOK && r.FallbackDNSOK && !r.HostIsIP && !sameAddrs(r.SystemDNSAddrs, r.FallbackDNSAddrs):
+		// Split-horizon DNS is common and fine; only worth a note
+		problems = append(problems, fmt.Sprintf("system and fallback resolvers return different addresses for %s (split-horizon DNS?)", r.ServerHost))
+	}
+
+	if r.SystemDNSOK && !r.TCPReachable {
+		problems = append(problems, "server is not reachable over TCP")
+	}
+	if r.TCPReachableByIP {
+		problems = append(problems, "server is reachable by IP but not by name; DNS is the problem")
+	}
+	if r.TCPReachable && !r.LargeRequestOK {
+		problems = append(problems, "small connections succeed but a large request failed; possible MTU black hole or TLS-inspecting middlebox")
+	}
+	return append(problems, diagnoseFamilies(r.AddressFamilies)...)
+}
+
+// diagnoseFamilies explains address family mismatches between this host and
+// the server
+func diagnoseFamilies(families []models.AddressFamilyCheck) []string {
+	var problems []string
+	reachable := false
+	for _, f := range families {
+		reachable = reachable || f.Reachable
+	}
+	for _, f := range families {
+		name := familyName(f.Family)
+		switch {
+		case f.Routed && len(f.Addrs) > 0 && !f.Reachable && reachable:
+			problems = append(problems, fmt.Sprintf("server is unreachable over %s although this host has an %s route; connections fall back to the other family after a delay", name, name))
+		case f.Routed && len(f.Addrs) == 0 && !reachable && onlyRouted(families, f.Family):
+			problems = append(problems, fmt.Sprintf("this host only has %s connectivity but the server has no %s address; publish one, use NAT64/DNS64 or reach the server through a relay", name, name))
+		}
+	}
+	return problems
+}
+
+// onlyRouted reports whether family is the only one this host can route
+func onlyRouted(families []models.AddressFamilyCheck, family string) bool {
+	for _, f := range families {
+		if f.Family != family && f.Routed {
+			return false
+		}
+	}
+	return true
+}
+
+func familyName(family string) string {
+	if family == familyIPv6 {
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
+// SplitServerURL extracts the host and port from a server URL, defaulting the
+// port from the scheme
+func SplitServerURL(serverURL string) (host, port string, err error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return "", "", err
+	}
+	host = u.Hostname()
+	if host == "" {
+		return "", "", fmt.Errorf("no host in %q", serverURL)
+	}
+	port = u.Port()
+	if port == "" {
+		if u.Scheme == "http" || u.Scheme == "ws" {
+			port = "80"
+		} else {
+			port = "443"
+		}
+	}
+	return host, port, nil
+}
+
+func (c *Checker) resolveSystem(ctx context.Context, host string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout)
+	defer cancel()
+	return net.DefaultResolver.LookupHost(ctx, host)
+}
+
+// resolveFallback tries each fallback resolver in order and returns the first
+// successful answer along with the resolver that produced it
+func (c *Checker) resolveFallback(ctx context.Context, host string) (string, []string, error) {
+	if len(c.fallbackResolvers) == 0 {
+		return "", nil, fmt.Errorf("no fallback resolvers configured")
+	}
+
+	var lastErr error
+	for _, server := range c.fallbackResolvers {
+		resolver := &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				d := net.Dialer{Timeout: dnsTimeout}
+				return d.DialContext(ctx, network, server)
+			},
+		}
+		lookupCtx, cancel := context.WithTimeout(ctx, dnsTimeout)
+		addrs, err := resolver.LookupHost(lookupCtx, host)
+		cancel()
+		if err == nil && len(addrs) > 0 {
+			return server, addrs, nil
+		}
+		c.logger.WithError(err).WithField("resolver", server).Debug("Fallback DNS lookup failed")
+		lastErr = err
+	}
+	return c.fallbackResolvers[len(c.fallbackResolvers)-1], nil, lastErr
+}
+
+const (
+	familyIPv4 = "ipv4"
+	familyIPv6 = "ipv6"
+)
+
+// routeProbes stand in for the server when it has no address in a family, to
+// ask the kernel whether this host could route the family at all
+var routeProbes = map[string]string{
+	familyIPv4: "192.0.2.1",
+	familyIPv6: "2001:db8::1",
+}
+
+// checkFamilies tests the server over IPv4 and IPv6 separately. Dialing the
+// name would let Happy Eyeballs quietly pick whichever family works.
+func (c *Checker) checkFamilies(ctx context.Context, addrs []string, port string) []models.AddressFamilyCheck {
+	byFamily := map[string][]string{}
+	for _, addr := range addrs {
+		ip, err := netip.ParseAddr(addr)
+		if err != nil {
+			continue
+		}
+		family := familyIPv4
+		if ip.Unmap().Is6() {
+			family = familyIPv6
+		}
+		byFamily[family] = append(byFamily[family], addr)
+	}
+
+	families := make([]models.AddressFamilyCheck, 0, 2)
+	for _, family := range []string{familyIPv4, familyIPv6} {
+		f := models.AddressFamilyCheck{Family: family, Addrs: byFamily[family]}
+		probe := routeProbes[family]
+		if len(f.Addrs) > 0 {
+			probe = f.Addrs[0]
+		}
+		f.Routed = hasRoute(probe)
+		if len(f.Addrs) > 0 {
+			network := "tcp4"
+			if family == familyIPv6 {
+				network = "tcp6"
+			}
+			dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+			conn, err := utils.NewDialer(dialTimeout).DialContext(dialCtx, network, net.JoinHostPort(f.Addrs[0], port))
+			cancel()
+			if err != nil {
+				f.Error = err.Error()
+				c.logger.WithError(err).WithField("family", family).Debug("Server not reachable over address family")
+			} else {
+				f.Reachable = true
+				_ = conn.Close()
+			}
+		}
+		families = append(families, f)
+	}
+	return families
+}
+
+// hasRoute reports whether the kernel has a route to ip. Connecting a UDP
+// socket selects a route and source address without sending anything.
+func hasRoute(ip string) bool {
+	conn, err := net.Dial("udp", net.JoinHostPort(ip, "9"))
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// largeRequest sends a GET to the server's /health endpoint with a padded
+// header. The server must receive the full header block before it can answer,
+// so any HTTP response (whatever the status) proves large segments get through.
+func (c *Checker) largeRequest(ctx context.Context, serverURL string) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(serverURL, "/")+"/health", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-PatchMon-Padding", strings.Repeat("x", paddingSize))
+
+	transport := &http.Transport{
+		Proxy:             http.ProxyFromEnvironment,
+		DialContext:       utils.NewDialer(requestTimeout).DialContext,
+		DisableKeepAlives: true,
+	}
+	if c.tlsConfig != nil {
+		transport.TLSClientConfig = c.tlsConfig.Clone()
+	} else if c.skipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	httpClient := &http.Client{Transport: transport}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("large request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	return nil
+}
+
+func sameAddrs(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	seen := make(map[string]bool, len(a))
+	for _, addr := range a {
+		seen[addr] = true
+	}
+	for _, addr := range b {
+		if seen[addr] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package connectivity
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitServerURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		host string
+		port string
+	}{
+		{"https://patchmon.example.com", "patchmon.example.com", "443"},
+		{"http://patchmon.example.com", "patchmon.example.com", "80"},
+		{"https://patchmon.example.com:8443/", "patchmon.example.com", "8443"},
+		{"http://10.0.0.5:3001", "10.0.0.5", "3001"},
+		{"https://[2001:db8::1]", "2001:db8::1", "443"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			host, port, err := SplitServerURL(tt.url)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.host, host)
+			assert.Equal(t, tt.port, port)
+		})
+	}
+
+	_, _, err := SplitServerURL("not a url")
+	assert.Error(t, err)
+}
+
+func TestDiagnose(t *testing.T) {
+	t.Run("healthy", func(t *testing.T) {
+		r := &models.ConnectivityCheck{
+			ServerHost:       "patchmon.example.com",
+			SystemDNSOK:      true,
+			SystemDNSAddrs:   []string{"192.0.2.10"},
+			FallbackDNSOK:    true,
+			FallbackDNSAddrs: []string{"192.0.2.10"},
+			TCPReachable:     true,
+			LargeRequestOK:   true,
+		}
+		assert.Empty(t, Diagnose(r))
+	})
+
+	t.Run("system resolver broken", func(t *testing.T) {
+		r := &models.ConnectivityCheck{
+			ServerHost:       "patchmon.example.com",
+			FallbackResolver: "1.1.1.1:53",
+			FallbackDNSOK:    true,
+			FallbackDNSAddrs: []string{"192.0.2.10"},
+			TCPReachableByIP: true,
+		}
+		problems := Diagnose(r)
+		assert.Len(t, problems, 2)
+		assert.Contains(t, problems[0], "system resolver cannot resolve")
+		assert.Contains(t, problems[1], "reachable by IP")
+	})
+
+	t.Run("large request blocked", func(t *testing.T) {
+		r := &models.ConnectivityCheck{
+			ServerHost:    "192.0.2.10",
+			HostIsIP:      true,
+			SystemDNSOK:   true,
+			FallbackDNSOK: true,
+			TCPReachable:  true,
+		}
+		problems := Diagnose(r)
+		assert.Len(t, problems, 1)
+		assert.Contains(t, problems[0], "MTU")
+	})
+}
+
+func TestDiagnoseAddressFamilies(t *testing.T) {
+	t.Run("broken IPv6 hidden by fallback", func(t *testing.T) {
+		problems := diagnoseFamilies([]models.AddressFamilyCheck{
+			{Family: "ipv4", Routed: true, Addrs: []string{"192.0.2.10"}, Reachable: true},
+			{Family: "ipv6", Routed: true, Addrs: []string{"2001:db8::10"}, Error: "i/o timeout"},
+		})
+		require.Len(t, problems, 1)
+		assert.Contains(t, problems[0], "unreachable over IPv6")
+	})
+
+	t.Run("IPv6-only host, IPv4-only server", func(t *testing.T) {
+		problems := diagnoseFamilies([]models.AddressFamilyCheck{
+			{Family: "ipv4", Addrs: []string{"192.0.2.10"}, Error: "connect: network is unreachable"},
+			{Family: "ipv6", Routed: true},
+		})
+		require.Len(t, problems, 1)
+		assert.Contains(t, problems[0], "only has IPv6 connectivity")
+	})
+
+	t.Run("dual stack", func(t *testing.T) {
+		assert.Empty(t, diagnoseFamilies([]models.AddressFamilyCheck{
+			{Family: "ipv4", Routed: true, Addrs: []string{"192.0.2.10"}, Reachable: true},
+			{Family: "ipv6", Routed: true, Addrs: []string{"2001:db8::10"}, Reachable: true},
+		}))
+	})
+}
+
+func TestCheckFamilies(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	require.NoError(t, err)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	families := New(logger, false, nil).checkFamilies(context.Background(), []string{"127.0.0.1", "not-an-ip"}, port)
+	require.Len(t, families, 2)
+
+	assert.Equal(t, "ipv4", families[0].Family)
+	assert.True(t, families[0].Routed)
+	assert.True(t, families[0].Reachable)
+	assert.Equal(t, []string{"127.0.0.1"}, families[0].Addrs)
+
+	assert.Equal(t, "ipv6", families[1].Family)
+	assert.Empty(t, families[1].Addrs)
+	assert.False(t, families[1].Reachable)
+}
@@ -46,6 +46,13 @@ const (
 	RepoTypeWSUS    = "wsus"           // Windows Server Update Services
 )
 
+// Repository classification constants
+const (
+	RepoClassDistro = "distro" // Official OS distribution repository
+	RepoClassVendor = "vendor" // Known third-party vendor (docker.com, grafana, ...)
+	RepoClassCustom = "custom" // Unknown, internal or custom repository
+)
+
 // Log level constants
 const (
 	LogLevelDebug = "debug"
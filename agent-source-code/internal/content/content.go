@@ -0,0 +1,389 @@
+// Package content builds and verifies the signed bundles that carry
+// compliance content (SSG datastreams, OVAL feeds, Docker Bench assets) to
+// hosts without network access. A bundle is a tar.gz whose first two entries
+// are manifest.json, listing every other file with its SHA-256, and
+// manifest.sig, an Ed25519 signature of the manifest. Nothing is written to
+// the destination until the signature checks out, and every file must match
+// its manifest entry.
+package content
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"patchmon-agent/internal/utils"
+)
+
+const (
+	// ManifestFile and SignatureFile are kept next to the imported content
+	ManifestFile  = "manifest.json"
+	SignatureFile = "manifest.sig"
+
+	// Directories of a bundle, by kind of content
+	SSGDir         = "ssg"
+	OVALDir        = "oval"
+	DockerBenchDir = "docker-bench"
+
+	// DockerBenchImageFile is a docker save of the Docker Bench image
+	DockerBenchImageFile = DockerBenchDir + "/image.tar"
+	// DockerBenchScriptDir holds a docker-bench-security checkout
+	DockerBenchScriptDir = DockerBenchDir + "/script"
+
+	manifestVersion = 1
+	// maxManifestSize bounds what is read before the signature is checked
+	maxManifestSize = 1 << 20
+)
+
+// Manifest describes a bundle
+type Manifest struct {
+	Version           int       `json:"version"`
+	CreatedAt         time.Time `json:"created_at"`
+	CreatedBy         string    `json:"created_by,omitempty"` // hostname of the exporting host
+	AgentVersion      string    `json:"agent_version,omitempty"`
+	DockerBenchImage  string    `json:"docker_bench_image,omitempty"`  // reference the image tar loads as
+	DockerBenchScript string    `json:"docker_bench_script,omitempty"` // bundle path of docker-bench-security.sh
+	Files             []File    `json:"files"`
+}
+
+// File is one file of a bundle
+type File struct {
+	Path   string `json:"path"` // slash-separated, relative to the bundle root
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	Exec   bool   `json:"exec,omitempty"` // extracted executable, e.g. the Docker Bench script
+}
+
+// Has reports whether the bundle has any file under dir
+func (m *Manifest) Has(dir string) bool {
+	for _, f := range m.Files {
+		if strings.HasPrefix(f.Path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Export writes the files under src to w as a bundle signed with key. The
+// Files list of m is filled in from src.
+func Export(w io.Writer, src string, m *Manifest, key ed25519.PrivateKey) error {
+	m.Version = manifestVersion
+	m.Files = nil
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return fmt.Errorf("%s: not a regular file", p)
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		sum, size, err := hashFile(p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		m.Files = append(m.Files, File{Path: filepath.ToSlash(rel), Size: size, SHA256: sum, Exec: info.Mode()&0111 != 0})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(m.Files) == 0 {
+		return errors.New("nothing to export")
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	sig := []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, manifest)) + "\n")
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if err := writeEntry(tw, ManifestFile, manifest, m.CreatedAt); err != nil {
+		return err
+	}
+	if err := writeEntry(tw, SignatureFile, sig, m.CreatedAt); err != nil {
+		return err
+	}
+	for _, f := range m.Files {
+		if err := copyEntry(tw, filepath.Join(src, filepath.FromSlash(f.Path)), f, m.CreatedAt); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte, mtime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: mtime}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func copyEntry(tw *tar.Writer, src string, f File, mtime time.Time) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	if err := tw.WriteHeader(&tar.Header{Name: f.Path, Mode: int64(fileMode(f)), Size: f.Size, ModTime: mtime}); err != nil {
+		return err
+	}
+	// A file that changed since it was hashed fails here rather than on import
+	if _, err := io.CopyN(tw, in, f.Size); err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	return nil
+}
+
+// Import verifies the bundle read from r against the trusted keys and
+// extracts it into dest, which must not exist. On any error dest is removed.
+func Import(r io.Reader, dest string, trusted []ed25519.PublicKey) (*Manifest, error) {
+	if len(trusted) == 0 {
+		return nil, errors.New("no trusted content keys configured")
+	}
+	if err := os.Mkdir(dest, 0755); err != nil {
+		return nil, err
+	}
+	m, err := extract(r, dest, trusted)
+	if err != nil {
+		_ = os.RemoveAll(dest)
+		return nil, err
+	}
+	return m, nil
+}
+
+func extract(r io.Reader, dest string, trusted []ed25519.PublicKey) (*Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a content bundle: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	manifest, err := readEntry(tr, ManifestFile, maxManifestSize)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := readEntry(tr, SignatureFile, 1024)
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(manifest, sig, trusted); err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if m.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", m.Version)
+	}
+
+	want := make(map[string]File, len(m.Files))
+	for _, f := range m.Files {
+		if !validPath(f.Path) {
+			return nil, fmt.Errorf("invalid path in manifest: %q", f.Path)
+		}
+		want[f.Path] = f
+	}
+	if _, ok := want[m.DockerBenchScript]; m.DockerBenchScript != "" && !ok {
+		return nil, fmt.Errorf("docker_bench_script %q is not in the manifest", m.DockerBenchScript)
+	}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		f, ok := want[hdr.Name]
+		if !ok {
+			return nil, fmt.Errorf("%s is not in the manifest", hdr.Name)
+		}
+		delete(want, hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || hdr.Size != f.Size {
+			return nil, fmt.Errorf("%s does not match the manifest", hdr.Name)
+		}
+		if err := extractFile(tr, filepath.Join(dest, filepath.FromSlash(f.Path)), f); err != nil {
+			return nil, err
+		}
+	}
+	if len(want) > 0 {
+		return nil, fmt.Errorf("bundle is missing %d file(s) listed in the manifest", len(want))
+	}
+	if err := os.WriteFile(filepath.Join(dest, ManifestFile), manifest, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dest, SignatureFile), sig, 0644); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func readEntry(tr *tar.Reader, name string, limit int64) ([]byte, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("not a content bundle: %w", err)
+	}
+	if hdr.Name != name || hdr.Size > limit {
+		return nil, fmt.Errorf("not a content bundle: expected %s, found %s", name, hdr.Name)
+	}
+	return io.ReadAll(tr)
+}
+
+func verify(manifest, sig []byte, trusted []ed25519.PublicKey) error {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil || len(raw) != ed25519.SignatureSize {
+		return errors.New("invalid bundle signature")
+	}
+	for _, key := range trusted {
+		if ed25519.Verify(key, manifest, raw) {
+			return nil
+		}
+	}
+	return errors.New("bundle is not signed by a trusted key")
+}
+
+// validPath accepts relative slash paths that stay inside the bundle
+func validPath(p string) bool {
+	return p != "" && p == path.Clean(p) && !path.IsAbs(p) && p != ".." && !strings.HasPrefix(p, "../") &&
+		p != ManifestFile && p != SignatureFile && !strings.Contains(p, "\\")
+}
+
+func extractFile(r io.Reader, dest string, f File) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, fileMode(f))
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(out, h), r)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != f.SHA256 {
+		return fmt.Errorf("%s: checksum mismatch", f.Path)
+	}
+	return nil
+}
+
+func fileMode(f File) os.FileMode {
+	if f.Exec {
+		return 0755
+	}
+	return 0644
+}
+
+// Load reads the manifest of content imported into dir. It returns nil and no
+// error when nothing has been imported.
+func Load(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// Install replaces dir with the verified content in staged
+func Install(staged, dir string) error {
+	old := dir + ".old"
+	_ = os.RemoveAll(old)
+	if err := os.Rename(dir, old); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Rename(staged, dir); err != nil {
+		_ = os.Rename(old, dir)
+		return err
+	}
+	return os.RemoveAll(old)
+}
+
+// LoadOrCreateKey reads a signing key from path, generating and saving a new
+// one (owner-only permissions) if the file doesn't exist
+func LoadOrCreateKey(path string) (ed25519.PrivateKey, bool, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, false, fmt.Errorf("invalid signing key in %s", path)
+		}
+		return ed25519.NewKeyFromSeed(seed), false, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, false, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(key.Seed()) + "\n"
+	if err := utils.WriteFileAtomic(path, []byte(encoded), 0600); err != nil {
+		return nil, false, fmt.Errorf("failed to save signing key: %w", err)
+	}
+	return key, true, nil
+}
+
+// PublicKey encodes the public half of key as content_trusted_keys expects it
+func PublicKey(key ed25519.PrivateKey) string {
+	return base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+}
+
+// ParsePublicKey decodes a key written by PublicKey
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid content key %q", s)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+func hashFile(p string) (string, int64, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
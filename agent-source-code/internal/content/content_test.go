@@ -0,0 +1,168 @@
+package content
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(data), 0644))
+	}
+	return dir
+}
+
+func testKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	key, created, err := LoadOrCreateKey(filepath.Join(t.TempDir(), "key"))
+	require.NoError(t, err)
+	require.True(t, created)
+	return key
+}
+
+// signedBundle builds a bundle by hand so tests can break it in ways Export
+// never would
+func signedBundle(t *testing.T, key ed25519.PrivateKey, m Manifest, entries map[string]string) []byte {
+	t.Helper()
+	manifest, err := json.Marshal(m)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, writeEntry(tw, ManifestFile, manifest, time.Now()))
+	require.NoError(t, writeEntry(tw, SignatureFile, []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, manifest))), time.Now()))
+	for name, data := range entries {
+		require.NoError(t, writeEntry(tw, name, []byte(data), time.Now()))
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func fileEntry(path, data string) File {
+	sum := sha256.Sum256([]byte(data))
+	return File{Path: path, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])}
+}
+
+func TestExportImport(t *testing.T) {
+	key := testKey(t)
+	src := writeFiles(t, map[string]string{
+		"ssg/ssg-rhel9-ds.xml":             "<ds/>",
+		"oval/RHEL9/rhel-9.oval.xml.bz2":   "BZh9",
+		"docker-bench/script/functions.sh": "#!/bin/sh",
+	})
+	script := filepath.Join(src, "docker-bench/script/docker-bench-security.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\n"), 0755))
+
+	var buf bytes.Buffer
+	m := &Manifest{CreatedAt: time.Now().UTC(), CreatedBy: "gateway", DockerBenchScript: "docker-bench/script/docker-bench-security.sh"}
+	require.NoError(t, Export(&buf, src, m, key))
+	assert.Len(t, m.Files, 4)
+
+	other := testKey(t)
+	dest := filepath.Join(t.TempDir(), "content")
+	_, err := Import(bytes.NewReader(buf.Bytes()), dest, []ed25519.PublicKey{other.Public().(ed25519.PublicKey)})
+	assert.ErrorContains(t, err, "not signed by a trusted key")
+	assert.NoDirExists(t, dest)
+
+	trusted, err := ParsePublicKey(PublicKey(key))
+	require.NoError(t, err)
+	got, err := Import(bytes.NewReader(buf.Bytes()), dest, []ed25519.PublicKey{other.Public().(ed25519.PublicKey), trusted})
+	require.NoError(t, err)
+	assert.Equal(t, "gateway", got.CreatedBy)
+	assert.True(t, got.Has(SSGDir))
+	assert.True(t, got.Has(OVALDir))
+	assert.False(t, got.Has("docker"))
+
+	data, err := os.ReadFile(filepath.Join(dest, "ssg", "ssg-rhel9-ds.xml"))
+	require.NoError(t, err)
+	assert.Equal(t, "<ds/>", string(data))
+	info, err := os.Stat(filepath.Join(dest, "docker-bench", "script", "docker-bench-security.sh"))
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&0100)
+
+	loaded, err := Load(dest)
+	require.NoError(t, err)
+	assert.Equal(t, got.Files, loaded.Files)
+	none, err := Load(t.TempDir())
+	assert.NoError(t, err)
+	assert.Nil(t, none)
+}
+
+func TestImportRejects(t *testing.T) {
+	key := testKey(t)
+	trusted := []ed25519.PublicKey{key.Public().(ed25519.PublicKey)}
+	good := fileEntry("ssg/ssg-rhel9-ds.xml", "<ds/>")
+
+	for name, bundle := range map[string][]byte{
+		"tampered file": signedBundle(t, key, Manifest{Version: 1, Files: []File{good}},
+			map[string]string{"ssg/ssg-rhel9-ds.xml": "<x/>!"}),
+		"unlisted file": signedBundle(t, key, Manifest{Version: 1, Files: []File{good}},
+			map[string]string{"ssg/ssg-rhel9-ds.xml": "<ds/>", "ssg/extra.xml": "x"}),
+		"missing file": signedBundle(t, key, Manifest{Version: 1, Files: []File{good, fileEntry("oval/x", "x")}},
+			map[string]string{"ssg/ssg-rhel9-ds.xml": "<ds/>"}),
+		"path escape": signedBundle(t, key, Manifest{Version: 1, Files: []File{fileEntry("../evil", "x")}},
+			map[string]string{"../evil": "x"}),
+		"script not bundled": signedBundle(t, key, Manifest{Version: 1, Files: []File{good}, DockerBenchScript: "docker-bench/script/run.sh"},
+			map[string]string{"ssg/ssg-rhel9-ds.xml": "<ds/>"}),
+		"unsupported version": signedBundle(t, key, Manifest{Version: 2, Files: []File{good}},
+			map[string]string{"ssg/ssg-rhel9-ds.xml": "<ds/>"}),
+		"not a bundle": []byte("plain text"),
+	} {
+		dest := filepath.Join(t.TempDir(), "content")
+		_, err := Import(bytes.NewReader(bundle), dest, trusted)
+		assert.Error(t, err, name)
+		assert.NoDirExists(t, dest, name)
+	}
+
+	_, err := Import(bytes.NewReader(nil), filepath.Join(t.TempDir(), "content"), nil)
+	assert.ErrorContains(t, err, "no trusted content keys")
+}
+
+func TestInstall(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "content")
+	for _, version := range []string{"1", "2"} {
+		staged := writeFiles(t, map[string]string{ManifestFile: version})
+		require.NoError(t, Install(staged, dir))
+		data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+		require.NoError(t, err)
+		assert.Equal(t, version, string(data))
+	}
+	assert.NoDirExists(t, dir+".old")
+}
+
+func TestLoadOrCreateKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	key, created, err := LoadOrCreateKey(path)
+	require.NoError(t, err)
+	assert.True(t, created)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	again, created, err := LoadOrCreateKey(path)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, key, again)
+
+	_, err = ParsePublicKey("not-a-key")
+	assert.Error(t, err)
+}
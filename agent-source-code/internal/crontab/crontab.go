@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"os/exec"
 	"strings"
 	"time"
 
 	"patchmon-agent/internal/config"
+	"patchmon-agent/internal/utils"
 
 	"github.com/sirupsen/logrus"
 )
@@ -106,6 +108,41 @@ func (m *Manager) Remove() error {
 	return nil
 }
 
+// RemoveUserEntries removes patchmon-agent lines from root's personal crontab,
+// where some early installs put the agent instead of /etc/cron.d. It returns
+// the number of lines removed.
+func (m *Manager) RemoveUserEntries() (int, error) {
+	if _, err := exec.LookPath("crontab"); err != nil {
+		return 0, nil
+	}
+	out, err := utils.Command("crontab", "-l").Output()
+	if err != nil {
+		// crontab -l exits non-zero when there is no crontab
+		return 0, nil
+	}
+
+	var kept []string
+	removed := 0
+	for line := range strings.SplitSeq(strings.TrimRight(string(out), "\n"), "\n") {
+		if strings.Contains(line, "patchmon-agent") && !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			removed++
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	cmd := exec.Command("crontab", "-")
+	cmd.Stdin = strings.NewReader(strings.Join(kept, "\n") + "\n")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return 0, fmt.Errorf("failed to rewrite root crontab: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	m.logger.WithField("removed", removed).Info("Removed patchmon-agent entries from root crontab")
+	return removed, nil
+}
+
 // generateCronEntries generates cron entries for both report and update-crontab commands
 func (m *Manager) generateCronEntries(updateInterval int, executablePath string) []string {
 	var schedule string
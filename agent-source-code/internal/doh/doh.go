@@ -0,0 +1,222 @@
+// Package doh resolves host names over DNS-over-HTTPS (RFC 8484). The agent
+// uses it only as a fallback when the system resolver can't resolve the
+// PatchMon server, so reports keep flowing through a local resolver outage.
+package doh
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"patchmon-agent/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	queryTimeout = 10 * time.Second
+	// Answers are reused for their TTL, within these bounds
+	minCacheTTL = 30 * time.Second
+	maxCacheTTL = time.Hour
+	// maxResponseSize is the largest DNS message over any transport
+	maxResponseSize = 65535
+)
+
+// Resolver queries DoH servers in order until one answers
+type Resolver struct {
+	logger    *logrus.Logger
+	endpoints []string
+	client    *http.Client
+	now       func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedAnswer
+}
+
+type cachedAnswer struct {
+	addrs   []string
+	expires time.Time
+}
+
+// New creates a resolver for the given DoH URLs. Each must be https with an
+// IP address for a host: the resolver is used when name resolution is broken,
+// so it can't depend on it.
+func New(logger *logrus.Logger, endpoints []string) (*Resolver, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("no DNS-over-HTTPS servers configured")
+	}
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dns_over_https URL %q: %w", endpoint, err)
+		}
+		if u.Scheme != "https" {
+			return nil, fmt.Errorf("dns_over_https URL %q must be https", endpoint)
+		}
+		if _, err := netip.ParseAddr(u.Hostname()); err != nil {
+			return nil, fmt.Errorf("dns_over_https URL %q must use an IP address, e.g. https://1.1.1.1/dns-query", endpoint)
+		}
+	}
+	return &Resolver{
+		logger:    logger,
+		endpoints: endpoints,
+		client: &http.Client{
+			Timeout: queryTimeout,
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				DialContext:         utils.NewDialer(queryTimeout).DialContext,
+				TLSHandshakeTimeout: queryTimeout,
+				ForceAttemptHTTP2:   true,
+			},
+		},
+		now:   time.Now,
+		cache: make(map[string]cachedAnswer),
+	}, nil
+}
+
+// LookupHost returns the IPv6 and IPv4 addresses of host. Every lookup that
+// reaches a DoH server is logged as a warning, since it means the system
+// resolver is failing.
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if _, err := netip.ParseAddr(host); err == nil {
+		return []string{host}, nil
+	}
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, fmt.Errorf("invalid host name %q: %w", host, err)
+	}
+
+	r.mu.Lock()
+	cached, ok := r.cache[host]
+	r.mu.Unlock()
+	if ok && r.now().Before(cached.expires) {
+		r.logger.WithField("host", host).Debug("Using cached DNS-over-HTTPS answer")
+		return cached.addrs, nil
+	}
+
+	var lastErr error
+	for _, endpoint := range r.endpoints {
+		addrs, ttl, err := r.query(ctx, endpoint, name)
+		if err != nil {
+			r.logger.WithError(err).WithField("server", endpoint).Debug("DNS-over-HTTPS query failed")
+			lastErr = err
+			continue
+		}
+		if len(addrs) == 0 {
+			lastErr = fmt.Errorf("%s has no A or AAAA records", host)
+			continue
+		}
+		r.mu.Lock()
+		r.cache[host] = cachedAnswer{addrs: addrs, expires: r.now().Add(min(max(ttl, minCacheTTL), maxCacheTTL))}
+		r.mu.Unlock()
+		r.logger.WithFields(logrus.Fields{
+			"host":   host,
+			"server": endpoint,
+			"addrs":  strings.Join(addrs, ", "),
+		}).Warn("System resolver failed; resolved the server over DNS-over-HTTPS")
+		return addrs, nil
+	}
+	return nil, lastErr
+}
+
+// query asks one server for AAAA and A records and returns the addresses and
+// the smallest TTL among them
+func (r *Resolver) query(ctx context.Context, endpoint string, name dnsmessage.Name) ([]string, time.Duration, error) {
+	var addrs []string
+	ttl := maxCacheTTL
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeAAAA, dnsmessage.TypeA} {
+		answers, answerTTL, err := r.exchange(ctx, endpoint, name, qtype)
+		if err != nil {
+			return nil, 0, err
+		}
+		addrs = append(addrs, answers...)
+		if len(answers) > 0 {
+			ttl = min(ttl, answerTTL)
+		}
+	}
+	return addrs, ttl, nil
+}
+
+// exchange sends one RFC 8484 GET query
+func (r *Resolver) exchange(ctx context.Context, endpoint string, name dnsmessage.Name, qtype dnsmessage.Type) ([]string, time.Duration, error) {
+	// ID 0 is recommended for DoH so responses are cacheable
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, 0, err
+	}
+	q := u.Query()
+	q.Set("dns", base64.RawURLEncoding.EncodeToString(packed))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, 0, err
+	}
+	return parseAnswer(body, qtype)
+}
+
+// parseAnswer extracts the addresses of qtype records from a DNS response.
+// CNAME chains are followed by the recursive server, which includes the
+// final records in the answer section.
+func parseAnswer(body []byte, qtype dnsmessage.Type) ([]string, time.Duration, error) {
+	var reply dnsmessage.Message
+	if err := reply.Unpack(body); err != nil {
+		return nil, 0, fmt.Errorf("invalid DNS response: %w", err)
+	}
+	switch reply.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, 0, nil
+	default:
+		return nil, 0, fmt.Errorf("DNS query failed: %s", reply.RCode)
+	}
+
+	var addrs []string
+	ttl := maxCacheTTL
+	for _, answer := range reply.Answers {
+		if answer.Header.Type != qtype {
+			continue
+		}
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			addrs = append(addrs, net.IP(body.A[:]).String())
+		case *dnsmessage.AAAAResource:
+			addrs = append(addrs, net.IP(body.AAAA[:]).String())
+		default:
+			continue
+		}
+		ttl = min(ttl, time.Duration(answer.Header.TTL)*time.Second)
+	}
+	return addrs, ttl, nil
+}
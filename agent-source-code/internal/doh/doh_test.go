@@ -0,0 +1,137 @@
+package doh
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"patchmon-agent/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dohServer answers RFC 8484 GET queries from records, keyed by query type
+func dohServer(t *testing.T, records map[dnsmessage.Type][]dnsmessage.Resource) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var queries atomic.Int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries.Add(1)
+		packed, err := base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		require.NoError(t, err)
+		var query dnsmessage.Message
+		require.NoError(t, query.Unpack(packed))
+		require.Len(t, query.Questions, 1)
+		assert.Equal(t, "application/dns-message", r.Header.Get("Accept"))
+
+		q := query.Questions[0]
+		reply := dnsmessage.Message{
+			Header:    dnsmessage.Header{Response: true, RecursionAvailable: true},
+			Questions: query.Questions,
+		}
+		if q.Name.String() != "patchmon.example.com." {
+			reply.RCode = dnsmessage.RCodeNameError
+		}
+		for _, rr := range records[q.Type] {
+			rr.Header.Name = q.Name
+			rr.Header.Class = dnsmessage.ClassINET
+			reply.Answers = append(reply.Answers, rr)
+		}
+		out, err := reply.Pack()
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/dns-message")
+		_, _ = w.Write(out)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &queries
+}
+
+func testResolver(t *testing.T, srv *httptest.Server) *Resolver {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	r, err := New(logger, []string{srv.URL + "/dns-query"})
+	require.NoError(t, err)
+	r.client = srv.Client()
+	return r
+}
+
+func TestNewRequiresIPHTTPS(t *testing.T) {
+	logger := logrus.New()
+	for _, bad := range []string{"http://1.1.1.1/dns-query", "https://cloudflare-dns.com/dns-query", "::"} {
+		_, err := New(logger, []string{bad})
+		assert.Error(t, err, bad)
+	}
+	_, err := New(logger, nil)
+	assert.Error(t, err)
+	_, err = New(logger, []string{"https://1.1.1.1/dns-query", "https://[2620:fe::fe]/dns-query"})
+	assert.NoError(t, err)
+}
+
+func TestLookupHost(t *testing.T) {
+	srv, queries := dohServer(t, map[dnsmessage.Type][]dnsmessage.Resource{
+		dnsmessage.TypeA: {
+			{Header: dnsmessage.ResourceHeader{Type: dnsmessage.TypeA, TTL: 300}, Body: &dnsmessage.AResource{A: [4]byte{192, 0, 2, 10}}},
+		},
+		dnsmessage.TypeAAAA: {
+			{Header: dnsmessage.ResourceHeader{Type: dnsmessage.TypeAAAA, TTL: 60}, Body: &dnsmessage.AAAAResource{AAAA: [16]byte{0x20, 0x01, 0x0d, 0xb8, 15: 0x10}}},
+		},
+	})
+	r := testResolver(t, srv)
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	addrs, err := r.LookupHost(context.Background(), "patchmon.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2001:db8::10", "192.0.2.10"}, addrs)
+	assert.EqualValues(t, 2, queries.Load(), "one AAAA and one A query")
+
+	_, err = r.LookupHost(context.Background(), "patchmon.example.com")
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, queries.Load(), "answered from cache")
+
+	now = now.Add(61 * time.Second)
+	_, err = r.LookupHost(context.Background(), "patchmon.example.com")
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, queries.Load(), "the cache lasts the smallest TTL")
+
+	_, err = r.LookupHost(context.Background(), "missing.example.com")
+	assert.ErrorContains(t, err, "no A or AAAA records")
+
+	addrs, err = r.LookupHost(context.Background(), "192.0.2.99")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"192.0.2.99"}, addrs)
+}
+
+func TestDialFallsBackToDoH(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	require.NoError(t, err)
+
+	srv, _ := dohServer(t, map[dnsmessage.Type][]dnsmessage.Resource{
+		dnsmessage.TypeA: {
+			{Header: dnsmessage.ResourceHeader{Type: dnsmessage.TypeA, TTL: 300}, Body: &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}}},
+		},
+	})
+	utils.SetFallbackLookup(testResolver(t, srv).LookupHost)
+	defer utils.SetFallbackLookup(nil)
+
+	// .invalid never resolves (RFC 6761), so only the fallback can answer
+	dial := utils.DialContext(5 * time.Second)
+	_, err = dial(context.Background(), "tcp", net.JoinHostPort("patchmon.invalid", port))
+	assert.Error(t, err, "the DoH server knows only patchmon.example.com")
+
+	conn, err := dial(context.Background(), "tcp", net.JoinHostPort("patchmon.example.com", port))
+	require.NoError(t, err)
+	_ = conn.Close()
+}
@@ -0,0 +1,190 @@
+package hardware
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// gpuTimeout bounds GPU detection, most of which is nvidia-smi
+const gpuTimeout = 15 * time.Second
+
+// gpuVendors maps the PCI vendor IDs of display controllers to GPU vendors
+var gpuVendors = map[string]string{
+	"0x10de": "nvidia",
+	"0x1002": "amd",
+	"0x8086": "intel",
+}
+
+// gpuDriverPackages match the package names of each vendor's driver and
+// compute stack across apt, dnf, zypper and pacman
+var gpuDriverPackages = map[string]*regexp.Regexp{
+	"nvidia": regexp.MustCompile(`nvidia|^cuda-drivers`),
+	"amd":    regexp.MustCompile(`amdgpu|^rocm|^hip-runtime-amd|^amd-smi`),
+	"intel":  regexp.MustCompile(`^intel-(media-va-driver|opencl|level-zero|compute-runtime|gpu)|^libze-intel-gpu|^libigc|^xserver-xorg-video-intel`),
+}
+
+// cudaVersionPattern finds the CUDA version in nvidia-smi's banner
+var cudaVersionPattern = regexp.MustCompile(`CUDA Version:\s*([0-9.]+)`)
+
+// getGPUs lists the GPUs on the PCI bus (display controllers, PCI class
+// 0x03) with their drivers and compute toolkits. Only Linux exposes the bus
+// in sysfs; elsewhere no GPUs are reported.
+func (m *Manager) getGPUs() []models.GPUInfo {
+	ctx, cancel := context.WithTimeout(context.Background(), gpuTimeout)
+	defer cancel()
+
+	dir := filepath.Join(m.root, "sys", "bus", "pci", "devices")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var gpus []models.GPUInfo
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !strings.HasPrefix(readSysfs(path, "class"), "0x03") {
+			continue
+		}
+		vendor, ok := gpuVendors[readSysfs(path, "vendor")]
+		if !ok {
+			continue
+		}
+		gpu := models.GPUInfo{Vendor: vendor, PCISlot: entry.Name()}
+		if driver, err := os.Readlink(filepath.Join(path, "driver")); err == nil {
+			gpu.Driver = filepath.Base(driver)
+			// Out-of-tree modules (nvidia, amdgpu-dkms) carry a version
+			gpu.DriverVersion = readSysfs(filepath.Join(m.root, "sys", "module", gpu.Driver), "version")
+		}
+		// amdgpu reports VRAM in bytes
+		if vram, err := strconv.ParseInt(readSysfs(path, "mem_info_vram_total"), 10, 64); err == nil {
+			gpu.MemoryMB = int(vram >> 20)
+		}
+		gpus = append(gpus, gpu)
+	}
+	if len(gpus) == 0 {
+		return nil
+	}
+
+	names := m.lspciNames(ctx)
+	for i := range gpus {
+		gpus[i].Model = names[gpus[i].PCISlot].device
+	}
+	m.addNvidiaDetails(ctx, gpus)
+	cuda, rocm := m.cudaToolkitVersion(), m.rocmVersion()
+	for i := range gpus {
+		switch gpus[i].Vendor {
+		case "nvidia":
+			gpus[i].CUDAToolkitVersion = cuda
+		case "amd":
+			gpus[i].ROCmVersion = rocm
+		}
+	}
+	return gpus
+}
+
+// addNvidiaDetails fills in the model, memory, driver and CUDA versions of
+// NVIDIA GPUs from nvidia-smi, when the driver's tools are installed
+func (m *Manager) addNvidiaDetails(ctx context.Context, gpus []models.GPUInfo) {
+	if _, err := m.runner.LookPath("nvidia-smi"); err != nil {
+		return
+	}
+	out, err := m.output(ctx, "nvidia-smi", "--query-gpu=pci.bus_id,name,memory.total,driver_version", "--format=csv,noheader,nounits")
+	if err != nil {
+		m.logger.WithError(err).Debug("nvidia-smi query failed")
+		return
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != 4 {
+			continue
+		}
+		slot := nvidiaSlot(strings.TrimSpace(fields[0]))
+		for i := range gpus {
+			if gpus[i].PCISlot != slot {
+				continue
+			}
+			gpus[i].Model = strings.TrimSpace(fields[1])
+			if mb, err := strconv.Atoi(strings.TrimSpace(fields[2])); err == nil {
+				gpus[i].MemoryMB = mb
+			}
+			gpus[i].DriverVersion = strings.TrimSpace(fields[3])
+		}
+	}
+
+	// The supported CUDA version is only in the banner
+	out, err = m.output(ctx, "nvidia-smi")
+	if err != nil {
+		return
+	}
+	if match := cudaVersionPattern.FindSubmatch(out); match != nil {
+		for i := range gpus {
+			if gpus[i].Vendor == "nvidia" {
+				gpus[i].CUDAVersion = string(match[1])
+			}
+		}
+	}
+}
+
+// nvidiaSlot converts nvidia-smi's bus ID (00000000:3B:00.0) to the sysfs
+// form (0000:3b:00.0)
+func nvidiaSlot(busID string) string {
+	domain, rest, ok := strings.Cut(strings.ToLower(busID), ":")
+	if !ok {
+		return ""
+	}
+	if len(domain) > 4 {
+		domain = domain[len(domain)-4:]
+	}
+	return domain + ":" + rest
+}
+
+// cudaToolkitVersion returns the version of the CUDA toolkit linked at
+// /usr/local/cuda: version.json since CUDA 11.1, version.txt before
+func (m *Manager) cudaToolkitVersion() string {
+	dir := filepath.Join(m.root, "usr", "local", "cuda")
+	if data, err := os.ReadFile(filepath.Join(dir, "version.json")); err == nil {
+		var parsed struct {
+			CUDA struct {
+				Version string `json:"version"`
+			} `json:"cuda"`
+		}
+		if json.Unmarshal(data, &parsed) == nil && parsed.CUDA.Version != "" {
+			return parsed.CUDA.Version
+		}
+	}
+	// CUDA Version 10.2.89
+	text := readSysfs(dir, "version.txt")
+	return strings.TrimSpace(strings.TrimPrefix(text, "CUDA Version"))
+}
+
+// rocmVersion returns the ROCm release installed under /opt/rocm, without
+// its build number (6.1.2-119 is 6.1.2)
+func (m *Manager) rocmVersion() string {
+	version, _, _ := strings.Cut(readSysfs(filepath.Join(m.root, "opt", "rocm", ".info"), "version"), "-")
+	return version
+}
+
+// MarkGPUDriverUpdates sets each GPU's DriverUpdates to the packages of its
+// vendor's driver stack that have an update available, so driver drift shows
+// up with the GPU rather than only in the package list
+func MarkGPUDriverUpdates(gpus []models.GPUInfo, pkgs []models.Package) {
+	for i := range gpus {
+		gpus[i].DriverUpdates = nil
+		pattern, ok := gpuDriverPackages[gpus[i].Vendor]
+		if !ok {
+			continue
+		}
+		for _, pkg := range pkgs {
+			if pkg.NeedsUpdate && pattern.MatchString(strings.ToLower(pkg.Name)) {
+				gpus[i].DriverUpdates = append(gpus[i].DriverUpdates, pkg.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package hardware
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner/cmdrunnertest"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func TestGetGPUs(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	m := New(logger)
+	m.runner = cmdrunnertest.Load(t, filepath.Join("testdata", "commands"))
+	m.root = t.TempDir()
+
+	nvidia := "pci0000:3a/0000:3a:00.0/0000:3b:00.0"
+	amd := "pci0000:c0/0000:c0:01.1/0000:c1:00.0"
+	writeSysfs(t, m.root, nvidia, "pci", map[string]string{"class": "0x030200", "vendor": "0x10de", "device": "0x20f1"})
+	writeSysfs(t, m.root, amd, "pci", map[string]string{"class": "0x030000", "vendor": "0x1002", "device": "0x73bf", "mem_info_vram_total": "17163091968"})
+	writeSysfs(t, m.root, "pci0000:00/0000:00:1f.6", "pci", map[string]string{"class": "0x020000", "vendor": "0x8086", "device": "0x15bb"})
+	require.NoError(t, os.Symlink("../../../../bus/pci/drivers/nvidia", filepath.Join(m.root, "sys", "devices", nvidia, "driver")))
+	require.NoError(t, os.Symlink("../../../../bus/pci/drivers/amdgpu", filepath.Join(m.root, "sys", "devices", amd, "driver")))
+	writeFile(t, filepath.Join(m.root, "sys", "module", "amdgpu", "version"), "6.7.0\n")
+	writeFile(t, filepath.Join(m.root, "usr", "local", "cuda", "version.json"), `{"cuda": {"name": "CUDA SDK", "version": "12.4.1"}}`)
+	writeFile(t, filepath.Join(m.root, "opt", "rocm", ".info", "version"), "6.1.2-119\n")
+
+	gpus := m.getGPUs()
+	require.Len(t, gpus, 2, "the Intel NIC is not a GPU")
+	assert.Equal(t, models.GPUInfo{
+		Vendor:             "nvidia",
+		Model:              "NVIDIA A100-PCIE-40GB",
+		PCISlot:            "0000:3b:00.0",
+		MemoryMB:           40960,
+		Driver:             "nvidia",
+		DriverVersion:      "550.54.15",
+		CUDAVersion:        "12.4",
+		CUDAToolkitVersion: "12.4.1",
+	}, gpus[0])
+	assert.Equal(t, models.GPUInfo{
+		Vendor:        "amd",
+		PCISlot:       "0000:c1:00.0",
+		MemoryMB:      16368,
+		Driver:        "amdgpu",
+		DriverVersion: "6.7.0",
+		ROCmVersion:   "6.1.2",
+	}, gpus[1])
+
+	MarkGPUDriverUpdates(gpus, []models.Package{
+		{Name: "nvidia-driver-550", NeedsUpdate: true},
+		{Name: "libnvidia-compute-550", NeedsUpdate: true},
+		{Name: "nvidia-utils-550", NeedsUpdate: false},
+		{Name: "rocm-hip-runtime", NeedsUpdate: true},
+		{Name: "curl", NeedsUpdate: true},
+	})
+	assert.Equal(t, []string{"nvidia-driver-550", "libnvidia-compute-550"}, gpus[0].DriverUpdates)
+	assert.Equal(t, []string{"rocm-hip-runtime"}, gpus[1].DriverUpdates)
+}
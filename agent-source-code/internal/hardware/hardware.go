@@ -11,19 +11,25 @@ import (
 	"github.com/shirou/gopsutil/v4/mem"
 	"github.com/sirupsen/logrus"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner"
 	"patchmon-agent/internal/constants"
-	"patchmon-agent/pkg/models"
 )
 
 // Manager handles hardware information collection
 type Manager struct {
 	logger *logrus.Logger
+	runner cmdrunner.Runner
+	// root prefixes the /sys paths read by the deep inventory, for tests
+	root string
 }
 
 // New creates a new hardware manager
 func New(logger *logrus.Logger) *Manager {
 	return &Manager{
 		logger: logger,
+		runner: cmdrunner.Default,
+		root:   "/",
 	}
 }
 
@@ -35,6 +41,7 @@ func (m *Manager) GetHardwareInfo() models.HardwareInfo {
 		RAMInstalled: m.getRAMSize(),
 		SwapSize:     m.getSwapSize(),
 		DiskDetails:  m.getDiskDetails(),
+		GPUs:         m.getGPUs(),
 	}
 
 	m.logger.WithFields(logrus.Fields{
@@ -43,7 +50,8 @@ func (m *Manager) GetHardwareInfo() models.HardwareInfo {
 		"ram":   fmt.Sprintf("%.2fGB", info.RAMInstalled),
 		"swap":  fmt.Sprintf("%.2fGB", info.SwapSize),
 		"disks": len(info.DiskDetails),
-	}).Debug("Collected CPU, memory, and disk information")
+		"gpus":  len(info.GPUs),
+	}).Debug("Collected CPU, memory, disk and GPU information")
 
 	return info
 }
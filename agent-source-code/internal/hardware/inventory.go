@@ -0,0 +1,574 @@
+package hardware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+const (
+	// DefaultInventoryBudget bounds a deep inventory when the server asks
+	// for no particular time budget, and MaxInventoryBudget caps what it may
+	// ask for
+	DefaultInventoryBudget = time.Minute
+	MaxInventoryBudget     = 5 * time.Minute
+	// inventoryCommandTimeout bounds each tool, so a RAID utility stuck on a
+	// wedged controller can't use up the whole budget
+	inventoryCommandTimeout = 30 * time.Second
+)
+
+// inventorySection collects one part of the deep inventory into inv
+type inventorySection struct {
+	name    string
+	collect func(ctx context.Context, inv *models.HardwareInventory) error
+}
+
+// CollectInventory runs the deep hardware inventory within budget. Sections
+// run cheapest first; those the budget doesn't reach are skipped and the
+// inventory is marked incomplete, so a slow host still gets a partial answer.
+func (m *Manager) CollectInventory(ctx context.Context, budget time.Duration) *models.HardwareInventory {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+
+	inv := &models.HardwareInventory{}
+	sections := []inventorySection{
+		{"dmi", m.collectDMI},
+		{"pci", m.collectPCI},
+		{"usb", m.collectUSB},
+		{"raid", m.collectRAID},
+	}
+	for _, section := range sections {
+		if ctx.Err() != nil {
+			inv.Incomplete = true
+			inv.Warnings = append(inv.Warnings, fmt.Sprintf("%s: skipped, the %s time budget ran out", section.name, budget))
+			continue
+		}
+		if err := section.collect(ctx, inv); err != nil {
+			if ctx.Err() != nil {
+				inv.Incomplete = true
+			}
+			inv.Warnings = append(inv.Warnings, fmt.Sprintf("%s: %v", section.name, err))
+		}
+	}
+	inv.DurationMs = time.Since(start).Milliseconds()
+
+	m.logger.WithFields(logrus.Fields{
+		"memory_modules":   len(inv.MemoryModules),
+		"pci_devices":      len(inv.PCIDevices),
+		"usb_devices":      len(inv.USBDevices),
+		"raid_controllers": len(inv.RAIDControllers),
+		"incomplete":       inv.Incomplete,
+		"duration":         time.Since(start).Round(time.Millisecond).String(),
+	}).Info("Collected deep hardware inventory")
+	return inv
+}
+
+// output runs a tool, bounded by inventoryCommandTimeout
+func (m *Manager) output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, inventoryCommandTimeout)
+	defer cancel()
+	return m.runner.Output(ctx, name, args...)
+}
+
+// collectDMI reads the system, BIOS, baseboard, chassis and memory device
+// tables with dmidecode, when it is installed
+func (m *Manager) collectDMI(ctx context.Context, inv *models.HardwareInventory) error {
+	if _, err := m.runner.LookPath("dmidecode"); err != nil {
+		m.logger.Debug("dmidecode not installed, skipping DMI inventory")
+		return nil
+	}
+	out, err := m.output(ctx, "dmidecode", "-t", "0,1,2,3,17")
+	if err != nil {
+		return fmt.Errorf("dmidecode failed: %w", err)
+	}
+	inv.DMI, inv.MemoryModules = parseDMI(string(out))
+	return nil
+}
+
+// dmiRecord is one structure from dmidecode's output
+type dmiRecord struct {
+	typ    int
+	fields map[string]string
+}
+
+// dmiPlaceholders are values vendors leave in fields they never filled in
+var dmiPlaceholders = map[string]bool{
+	"":                         true,
+	"Not Specified":            true,
+	"Not Provided":             true,
+	"Not Present":              true,
+	"Unknown":                  true,
+	"None":                     true,
+	"To Be Filled By O.E.M.":   true,
+	"To be filled by O.E.M.":   true,
+	"Default string":           true,
+	"System Serial Number":     true,
+	"System Product Name":      true,
+	"Chassis Serial Number":    true,
+	"Base Board Serial Number": true,
+}
+
+// parseDMIRecords splits dmidecode output into its structures. Multi-line
+// values (indented twice) are skipped.
+func parseDMIRecords(out string) []dmiRecord {
+	var records []dmiRecord
+	for _, line := range strings.Split(out, "\n") {
+		// Handle 0x0001, DMI type 1, 27 bytes
+		if strings.HasPrefix(line, "Handle ") {
+			record := dmiRecord{typ: -1, fields: map[string]string{}}
+			if _, rest, ok := strings.Cut(line, "DMI type "); ok {
+				typ, _, _ := strings.Cut(rest, ",")
+				if n, err := strconv.Atoi(typ); err == nil {
+					record.typ = n
+				}
+			}
+			records = append(records, record)
+			continue
+		}
+		if len(records) == 0 || !strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "\t\t") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if dmiPlaceholders[value] {
+			continue
+		}
+		records[len(records)-1].fields[key] = value
+	}
+	return records
+}
+
+// parseDMI maps dmidecode output to the inventory. The first structure of
+// each system-wide type is used; every populated memory device is listed.
+func parseDMI(out string) (*models.DMIInfo, []models.MemoryModule) {
+	var dmi models.DMIInfo
+	var modules []models.MemoryModule
+	seen := map[int]bool{}
+	for _, r := range parseDMIRecords(out) {
+		f := r.fields
+		if r.typ == 17 {
+			size := f["Size"]
+			if size == "" || strings.HasPrefix(size, "No Module") || size == "Not Installed" {
+				continue
+			}
+			modules = append(modules, models.MemoryModule{
+				Locator:      f["Locator"],
+				Size:         size,
+				Type:         f["Type"],
+				Speed:        f["Speed"],
+				Manufacturer: f["Manufacturer"],
+				PartNumber:   f["Part Number"],
+				SerialNumber: f["Serial Number"],
+			})
+			continue
+		}
+		if seen[r.typ] {
+			continue
+		}
+		seen[r.typ] = true
+		switch r.typ {
+		case 0:
+			dmi.BIOSVendor, dmi.BIOSVersion, dmi.BIOSDate = f["Vendor"], f["Version"], f["Release Date"]
+		case 1:
+			dmi.SystemManufacturer, dmi.SystemProduct, dmi.SystemVersion = f["Manufacturer"], f["Product Name"], f["Version"]
+			dmi.SystemSerial, dmi.SystemUUID, dmi.SystemSKU = f["Serial Number"], f["UUID"], f["SKU Number"]
+		case 2:
+			dmi.BoardManufacturer, dmi.BoardProduct, dmi.BoardSerial = f["Manufacturer"], f["Product Name"], f["Serial Number"]
+		case 3:
+			dmi.ChassisType, dmi.ChassisSerial, dmi.ChassisAssetTag = f["Type"], f["Serial Number"], f["Asset Tag"]
+		}
+	}
+	if dmi == (models.DMIInfo{}) {
+		return nil, modules
+	}
+	return &dmi, modules
+}
+
+// pciSlotPattern matches a PCI address as sysfs names devices
+var pciSlotPattern = regexp.MustCompile(`^[0-9a-f]{4,}:[0-9a-f]{2}:[0-9a-f]{2}\.[0-7]$`)
+
+// collectPCI lists PCI devices from sysfs, which exists only on Linux. Each
+// device's sysfs path runs through the bridges above it, giving its parent.
+func (m *Manager) collectPCI(ctx context.Context, inv *models.HardwareInventory) error {
+	dir := filepath.Join(m.root, "sys", "bus", "pci", "devices")
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	names := m.lspciNames(ctx)
+	for _, entry := range entries {
+		slot := entry.Name()
+		path := filepath.Join(dir, slot)
+		dev := models.PCIDevice{
+			Slot:     slot,
+			ClassID:  readSysfs(path, "class"),
+			VendorID: readSysfs(path, "vendor"),
+			DeviceID: readSysfs(path, "device"),
+		}
+		if target, err := filepath.EvalSymlinks(path); err == nil {
+			if parent := filepath.Base(filepath.Dir(target)); pciSlotPattern.MatchString(parent) {
+				dev.Parent = parent
+			}
+		}
+		if driver, err := os.Readlink(filepath.Join(path, "driver")); err == nil {
+			dev.Driver = filepath.Base(driver)
+		}
+		if n, ok := names[slot]; ok {
+			dev.Class, dev.Vendor, dev.Device = n.class, n.vendor, n.device
+		}
+		inv.PCIDevices = append(inv.PCIDevices, dev)
+	}
+	return nil
+}
+
+// pciNames are a device's class, vendor and device names from lspci
+type pciNames struct {
+	class, vendor, device string
+}
+
+// lspciNames maps PCI slots to their names, or returns nil when lspci is not
+// installed; the IDs from sysfs are enough for the server to look them up
+func (m *Manager) lspciNames(ctx context.Context) map[string]pciNames {
+	if _, err := m.runner.LookPath("lspci"); err != nil {
+		return nil
+	}
+	out, err := m.output(ctx, "lspci", "-vmmD")
+	if err != nil {
+		m.logger.WithError(err).Debug("lspci failed, reporting PCI IDs only")
+		return nil
+	}
+	return parseLspci(string(out))
+}
+
+// parseLspci reads lspci -vmmD output: "Key:\tValue" lines, one blank-line
+// separated record per device
+func parseLspci(out string) map[string]pciNames {
+	names := map[string]pciNames{}
+	var slot string
+	var cur pciNames
+	flush := func() {
+		if slot != "" {
+			names[slot] = cur
+		}
+		slot, cur = "", pciNames{}
+	}
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(line, ":\t")
+		if !ok {
+			if strings.TrimSpace(line) == "" {
+				flush()
+			}
+			continue
+		}
+		switch key {
+		case "Slot":
+			slot = value
+		case "Class":
+			cur.class = value
+		case "Vendor":
+			cur.vendor = value
+		case "Device":
+			cur.device = value
+		}
+	}
+	flush()
+	return names
+}
+
+// collectUSB lists USB devices and hubs from sysfs. Entries with a colon are
+// interfaces of a device rather than devices, and are left out.
+func (m *Manager) collectUSB(_ context.Context, inv *models.HardwareInventory) error {
+	dir := filepath.Join(m.root, "sys", "bus", "usb", "devices")
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.Contains(name, ":") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		inv.USBDevices = append(inv.USBDevices, models.USBDevice{
+			Path:         name,
+			Parent:       usbParent(name),
+			VendorID:     readSysfs(path, "idVendor"),
+			ProductID:    readSysfs(path, "idProduct"),
+			Manufacturer: readSysfs(path, "manufacturer"),
+			Product:      readSysfs(path, "product"),
+			Serial:       readSysfs(path, "serial"),
+			SpeedMbps:    readSysfs(path, "speed"),
+		})
+	}
+	return nil
+}
+
+// usbParent returns the hub a USB device path hangs off: 1-1.2 is on port 2
+// of 1-1, 1-1 is on the root hub usb1, and root hubs have no parent
+func usbParent(path string) string {
+	if strings.HasPrefix(path, "usb") {
+		return ""
+	}
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i]
+	}
+	bus, _, _ := strings.Cut(path, "-")
+	return "usb" + bus
+}
+
+// readSysfs returns a sysfs attribute without its trailing newline, or ""
+// when the device doesn't have it
+func readSysfs(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// RAID utilities, in order of preference. perccli is Dell's build of storcli.
+var (
+	storcliTools = []string{"storcli64", "storcli", "perccli64", "perccli"}
+	megacliTools = []string{"MegaCli64", "MegaCli", "megacli"}
+)
+
+// collectRAID reports Broadcom/LSI MegaRAID controllers through storcli, or
+// the older MegaCli when storcli isn't installed. Hosts with neither have no
+// RAID section.
+func (m *Manager) collectRAID(ctx context.Context, inv *models.HardwareInventory) error {
+	for _, tool := range storcliTools {
+		if _, err := m.runner.LookPath(tool); err != nil {
+			continue
+		}
+		// storcli exits non-zero when any controller fails, with JSON for the rest
+		out, runErr := m.output(ctx, tool, "/call", "show", "J")
+		controllers, err := parseStorcli(out)
+		if err != nil {
+			if runErr != nil {
+				return fmt.Errorf("%s failed: %w", tool, runErr)
+			}
+			return fmt.Errorf("%s: %w", tool, err)
+		}
+		inv.RAIDControllers = controllers
+		return nil
+	}
+	for _, tool := range megacliTools {
+		if _, err := m.runner.LookPath(tool); err != nil {
+			continue
+		}
+		adapters, err := m.output(ctx, tool, "-AdpAllInfo", "-aALL", "-NoLog")
+		if err != nil {
+			return fmt.Errorf("%s failed: %w", tool, err)
+		}
+		drives, err := m.output(ctx, tool, "-LDInfo", "-Lall", "-aALL", "-NoLog")
+		if err != nil {
+			return fmt.Errorf("%s failed: %w", tool, err)
+		}
+		inv.RAIDControllers = parseMegaCli(string(adapters), string(drives))
+		return nil
+	}
+	return nil
+}
+
+// storcliOutput is the part of storcli's "/call show J" output the inventory
+// reads
+type storcliOutput struct {
+	Controllers []struct {
+		CommandStatus struct {
+			Controller int    `json:"Controller"`
+			Status     string `json:"Status"`
+		} `json:"Command Status"`
+		ResponseData struct {
+			ProductName  string `json:"Product Name"`
+			SerialNumber string `json:"Serial Number"`
+			FWVersion    string `json:"FW Version"`
+			VDList       []struct {
+				DGVD  string `json:"DG/VD"`
+				Type  string `json:"TYPE"`
+				State string `json:"State"`
+				Size  string `json:"Size"`
+				Name  string `json:"Name"`
+			} `json:"VD LIST"`
+		} `json:"Response Data"`
+	} `json:"Controllers"`
+}
+
+// storcliStates expands storcli's abbreviated virtual drive states
+var storcliStates = map[string]string{
+	"Optl": "Optimal",
+	"Dgrd": "Degraded",
+	"Pdgd": "Partially Degraded",
+	"OfLn": "Offline",
+	"Rec":  "Recovery",
+	"Cac":  "CacheCade",
+}
+
+// parseStorcli reads storcli's JSON output. Controllers whose command failed
+// are left out.
+func parseStorcli(out []byte) ([]models.RAIDController, error) {
+	var parsed storcliOutput
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		return nil, fmt.Errorf("unexpected output: %w", err)
+	}
+	var controllers []models.RAIDController
+	for _, c := range parsed.Controllers {
+		if c.CommandStatus.Status != "Success" {
+			continue
+		}
+		data := c.ResponseData
+		controller := models.RAIDController{
+			Tool:     "storcli",
+			Index:    c.CommandStatus.Controller,
+			Model:    data.ProductName,
+			Serial:   data.SerialNumber,
+			Firmware: data.FWVersion,
+		}
+		for _, vd := range data.VDList {
+			state := vd.State
+			if long, ok := storcliStates[state]; ok {
+				state = long
+			}
+			controller.VirtualDrives = append(controller.VirtualDrives, models.RAIDVirtualDrive{
+				ID:        vd.DGVD,
+				Name:      vd.Name,
+				RAIDLevel: vd.Type,
+				Size:      vd.Size,
+				State:     state,
+			})
+		}
+		controller.Status = raidStatus(controller.VirtualDrives)
+		controllers = append(controllers, controller)
+	}
+	return controllers, nil
+}
+
+// megacliLevel matches MegaCli's RAID level line, e.g. "Primary-1, Secondary-0"
+var megacliLevel = regexp.MustCompile(`Primary-(\d+), Secondary-(\d+)`)
+
+// parseMegaCli reads MegaCli's -AdpAllInfo and -LDInfo output, both of which
+// are "Key : Value" lines under a header per adapter
+func parseMegaCli(adapters, drives string) []models.RAIDController {
+	var controllers []models.RAIDController
+	byIndex := map[int]int{}
+	for _, line := range strings.Split(adapters, "\n") {
+		line = strings.TrimSpace(line)
+		if rest, ok := strings.CutPrefix(line, "Adapter #"); ok {
+			index, err := strconv.Atoi(strings.TrimSpace(rest))
+			if err != nil {
+				continue
+			}
+			byIndex[index] = len(controllers)
+			controllers = append(controllers, models.RAIDController{Tool: "megacli", Index: index})
+			continue
+		}
+		key, value, ok := cutMegaCli(line)
+		if !ok || len(controllers) == 0 {
+			continue
+		}
+		c := &controllers[len(controllers)-1]
+		switch key {
+		case "Product Name":
+			c.Model = value
+		case "Serial No":
+			c.Serial = value
+		case "FW Package Build":
+			c.Firmware = value
+		}
+	}
+
+	var c *models.RAIDController
+	var vd *models.RAIDVirtualDrive
+	for _, line := range strings.Split(drives, "\n") {
+		line = strings.TrimSpace(line)
+		// Adapter 0 -- Virtual Drive Information:
+		if rest, ok := strings.CutPrefix(line, "Adapter "); ok {
+			number, _, _ := strings.Cut(rest, " ")
+			index, err := strconv.Atoi(number)
+			if i, known := byIndex[index]; err == nil && known {
+				c = &controllers[i]
+			} else {
+				c = nil
+			}
+			vd = nil
+			continue
+		}
+		if c == nil {
+			continue
+		}
+		// Virtual Drive: 0 (Target Id: 0)
+		if rest, ok := strings.CutPrefix(line, "Virtual Drive:"); ok {
+			id, _, _ := strings.Cut(strings.TrimSpace(rest), " ")
+			c.VirtualDrives = append(c.VirtualDrives, models.RAIDVirtualDrive{ID: id})
+			vd = &c.VirtualDrives[len(c.VirtualDrives)-1]
+			continue
+		}
+		key, value, ok := cutMegaCli(line)
+		if !ok || vd == nil {
+			continue
+		}
+		switch key {
+		case "Name":
+			vd.Name = value
+		case "Size":
+			vd.Size = value
+		case "State":
+			vd.State = value
+		case "RAID Level":
+			if level := megacliLevel.FindStringSubmatch(value); level != nil {
+				// Spanned arrays (RAID 10, 50, 60) have secondary level 3
+				vd.RAIDLevel = "RAID" + level[1]
+				if level[2] == "3" {
+					vd.RAIDLevel += "0"
+				}
+			}
+		}
+	}
+	for i := range controllers {
+		controllers[i].Status = raidStatus(controllers[i].VirtualDrives)
+	}
+	return controllers
+}
+
+// cutMegaCli splits a "Key : Value" line
+func cutMegaCli(line string) (string, string, bool) {
+	key, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", "", false
+	}
+	return strings.TrimSpace(key), strings.TrimSpace(value), true
+}
+
+// raidStatus summarises a controller from its virtual drives: Optimal when
+// all of them are, Degraded otherwise, and unknown without any
+func raidStatus(drives []models.RAIDVirtualDrive) string {
+	if len(drives) == 0 {
+		return ""
+	}
+	for _, vd := range drives {
+		if vd.State != "Optimal" {
+			return "Degraded"
+		}
+	}
+	return "Optimal"
+}
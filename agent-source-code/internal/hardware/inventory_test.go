@@ -0,0 +1,166 @@
+package hardware
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner/cmdrunnertest"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSysfs creates a sysfs device directory under root with the given
+// attributes, and its link under bus
+func writeSysfs(t *testing.T, root, device, bus string, attrs map[string]string) {
+	t.Helper()
+	dir := filepath.Join(root, "sys", "devices", device)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	for name, value := range attrs {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(value+"\n"), 0o644))
+	}
+	link := filepath.Join(root, "sys", "bus", bus, "devices", filepath.Base(device))
+	require.NoError(t, os.MkdirAll(filepath.Dir(link), 0o755))
+	require.NoError(t, os.Symlink(dir, link))
+}
+
+func testManager(t *testing.T) (*Manager, *cmdrunnertest.Replay) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	replay := cmdrunnertest.Load(t, filepath.Join("testdata", "commands"))
+	m := New(logger)
+	m.runner = replay
+	m.root = t.TempDir()
+
+	writeSysfs(t, m.root, "pci0000:00/0000:00:1c.0", "pci", map[string]string{"class": "0x060400", "vendor": "0x8086", "device": "0xa190"})
+	writeSysfs(t, m.root, "pci0000:00/0000:00:1c.0/0000:01:00.0", "pci", map[string]string{"class": "0x020000", "vendor": "0x14e4", "device": "0x165f"})
+	require.NoError(t, os.Symlink("../../../../bus/pci/drivers/tg3", filepath.Join(m.root, "sys/devices/pci0000:00/0000:00:1c.0/0000:01:00.0/driver")))
+	writeSysfs(t, m.root, "pci0000:00/0000:00:14.0/usb1", "usb", map[string]string{"idVendor": "1d6b", "idProduct": "0002", "product": "xHCI Host Controller", "speed": "480"})
+	writeSysfs(t, m.root, "pci0000:00/0000:00:14.0/usb1/1-1", "usb", map[string]string{"idVendor": "0424", "idProduct": "2514", "speed": "480"})
+	writeSysfs(t, m.root, "pci0000:00/0000:00:14.0/usb1/1-1/1-1.2", "usb", map[string]string{"idVendor": "0781", "idProduct": "5583", "manufacturer": "SanDisk", "product": "Ultra Fit", "serial": "4C530001", "speed": "5000"})
+	writeSysfs(t, m.root, "pci0000:00/0000:00:14.0/usb1/1-1/1-1.2/1-1.2:1.0", "usb", map[string]string{"bInterfaceClass": "08"})
+	return m, replay
+}
+
+func TestCollectInventory(t *testing.T) {
+	m, replay := testManager(t)
+
+	inv := m.CollectInventory(context.Background(), DefaultInventoryBudget)
+	assert.Empty(t, replay.Missed())
+	assert.False(t, inv.Incomplete)
+	assert.Empty(t, inv.Warnings)
+
+	require.NotNil(t, inv.DMI)
+	assert.Equal(t, models.DMIInfo{
+		SystemManufacturer: "Dell Inc.",
+		SystemProduct:      "PowerEdge R640",
+		SystemSerial:       "8XK2Q53",
+		SystemUUID:         "4c4c4544-0058-4b10-8032-b8c04f513533",
+		SystemSKU:          "SKU=NotProvided;ModelName=PowerEdge R640",
+		BIOSVendor:         "Dell Inc.",
+		BIOSVersion:        "2.19.0",
+		BIOSDate:           "03/07/2024",
+		BoardManufacturer:  "Dell Inc.",
+		BoardProduct:       "0H28RR",
+		BoardSerial:        ".8XK2Q53.CNCMS0009A0123.",
+		ChassisType:        "Rack Mount Chassis",
+		ChassisSerial:      "8XK2Q53",
+	}, *inv.DMI, "placeholders are dropped")
+	require.Len(t, inv.MemoryModules, 1, "empty slots are left out")
+	assert.Equal(t, models.MemoryModule{
+		Locator: "A1", Size: "32 GB", Type: "DDR4", Speed: "2933 MT/s",
+		Manufacturer: "00AD063200AD", PartNumber: "HMA84GR7CJR4N-WM", SerialNumber: "4A7C3B21",
+	}, inv.MemoryModules[0])
+
+	require.Len(t, inv.PCIDevices, 2)
+	assert.Empty(t, inv.PCIDevices[0].Parent)
+	assert.Equal(t, models.PCIDevice{
+		Slot:     "0000:01:00.0",
+		Parent:   "0000:00:1c.0",
+		ClassID:  "0x020000",
+		VendorID: "0x14e4",
+		DeviceID: "0x165f",
+		Class:    "Ethernet controller",
+		Vendor:   "Broadcom Inc. and subsidiaries",
+		Device:   "NetXtreme BCM5720 Gigabit Ethernet PCIe",
+		Driver:   "tg3",
+	}, inv.PCIDevices[1])
+
+	require.Len(t, inv.USBDevices, 3, "interfaces are not devices")
+	parents := map[string]string{}
+	for _, dev := range inv.USBDevices {
+		parents[dev.Path] = dev.Parent
+	}
+	assert.Equal(t, map[string]string{"usb1": "", "1-1": "usb1", "1-1.2": "1-1"}, parents)
+	assert.Equal(t, "SanDisk", inv.USBDevices[1].Manufacturer)
+	assert.Equal(t, "5000", inv.USBDevices[1].SpeedMbps)
+
+	require.Len(t, inv.RAIDControllers, 1, "controllers whose command failed are left out")
+	raid := inv.RAIDControllers[0]
+	assert.Equal(t, "storcli", raid.Tool)
+	assert.Equal(t, "PERC H740P Mini", raid.Model)
+	assert.Equal(t, "5.160.02-3552", raid.Firmware)
+	assert.Equal(t, "Degraded", raid.Status)
+	assert.Equal(t, []models.RAIDVirtualDrive{
+		{ID: "0/0", Name: "os", RAIDLevel: "RAID1", Size: "446.625 GB", State: "Optimal"},
+		{ID: "1/1", Name: "data", RAIDLevel: "RAID5", Size: "3.637 TB", State: "Degraded"},
+	}, raid.VirtualDrives)
+}
+
+func TestCollectInventoryBudget(t *testing.T) {
+	m, _ := testManager(t)
+
+	inv := m.CollectInventory(context.Background(), 0)
+	assert.True(t, inv.Incomplete)
+	assert.Len(t, inv.Warnings, 4, "every section is skipped")
+	assert.Nil(t, inv.DMI)
+	assert.Empty(t, inv.PCIDevices)
+}
+
+func TestParseMegaCli(t *testing.T) {
+	adapters := `
+Adapter #0
+
+==============================================================================
+                    Versions
+                ================
+Product Name    : PERC H710P Mini
+Serial No       : 29E00MF
+FW Package Build: 21.3.5-0002
+`
+	drives := `
+
+Adapter 0 -- Virtual Drive Information:
+Virtual Drive: 0 (Target Id: 0)
+Name                :
+RAID Level          : Primary-1, Secondary-0, RAID Level Qualifier-0
+Size                : 278.875 GB
+State               : Optimal
+Virtual Drive: 1 (Target Id: 1)
+Name                :data
+RAID Level          : Primary-1, Secondary-3, RAID Level Qualifier-0
+Size                : 3.637 TB
+State               : Optimal
+
+Exit Code: 0x00
+`
+	controllers := parseMegaCli(adapters, drives)
+	require.Len(t, controllers, 1)
+	assert.Equal(t, models.RAIDController{
+		Tool:     "megacli",
+		Model:    "PERC H710P Mini",
+		Serial:   "29E00MF",
+		Firmware: "21.3.5-0002",
+		Status:   "Optimal",
+		VirtualDrives: []models.RAIDVirtualDrive{
+			{ID: "0", RAIDLevel: "RAID1", Size: "278.875 GB", State: "Optimal"},
+			{ID: "1", Name: "data", RAIDLevel: "RAID10", Size: "3.637 TB", State: "Optimal"},
+		},
+	}, controllers[0])
+}
@@ -0,0 +1,105 @@
+package hooks
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/utils"
+)
+
+// ParseAptPreInstall parses the version 2 protocol apt writes to a
+// DPkg::Pre-Install-Pkgs hook on stdin:
+//
+//	VERSION 2
+//	<configuration space, one item per line>
+//	<blank line>
+//	curl 7.81.0-1ubuntu1.14 < 7.81.0-1ubuntu1.15 /var/cache/apt/archives/curl_....deb
+//	htop - < 3.0.5-7build2 /var/cache/apt/archives/htop_....deb
+//	nano 6.2-1 < - **REMOVE**
+//
+// A package appears once per dpkg step (unpack, configure); it is reported once.
+func ParseAptPreInstall(r io.Reader) ([]models.PackageTransactionItem, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	if !scanner.Scan() {
+		return nil, errors.New("empty hook input")
+	}
+	if strings.TrimSpace(scanner.Text()) != "VERSION 2" {
+		return nil, errors.New("unsupported hook protocol, expected VERSION 2")
+	}
+	// Skip the configuration dump
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			break
+		}
+	}
+
+	var items []models.PackageTransactionItem
+	seen := make(map[string]bool)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || seen[fields[0]] {
+			continue
+		}
+		seen[fields[0]] = true
+		items = append(items, aptItem(fields[0], fields[1], fields[2], fields[3], fields[4]))
+	}
+	return items, scanner.Err()
+}
+
+func aptItem(name, oldVersion, direction, newVersion, action string) models.PackageTransactionItem {
+	item := models.PackageTransactionItem{Name: name}
+	if oldVersion != "-" {
+		item.FromVersion = oldVersion
+	}
+	if newVersion != "-" && action != "**REMOVE**" {
+		item.ToVersion = newVersion
+	}
+	switch {
+	case item.ToVersion == "":
+		item.Action = "remove"
+	case item.FromVersion == "":
+		item.Action = "install"
+	case direction == "<":
+		item.Action = "upgrade"
+	case direction == ">":
+		item.Action = "downgrade"
+	default:
+		item.Action = "reinstall"
+	}
+	return item
+}
+
+// SavePending stores an apt transaction between the pre-install and post-invoke
+// hooks, which run as separate processes
+func SavePending(path string, tx *models.PackageTransaction) error {
+	data, err := json.Marshal(tx)
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(path, data, 0600)
+}
+
+// TakePending loads and removes the pending apt transaction. Returns nil when
+// there is none, which is normal: apt runs Post-Invoke after every dpkg call.
+func TakePending(path string) (*models.PackageTransaction, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = os.Remove(path)
+	var tx models.PackageTransaction
+	if err := json.Unmarshal(data, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
@@ -0,0 +1,99 @@
+// Package hooks receives package manager transaction events from the apt and dnf
+// hooks over a local unix socket
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultSocketPath is where serve listens for hook notifications
+const DefaultSocketPath = "/run/patchmon/hooks.sock"
+
+// SocketPath returns where serve for a configuration profile listens; ""
+// is the default config
+func SocketPath(profile string) string {
+	if profile == "" {
+		return DefaultSocketPath
+	}
+	return filepath.Join(filepath.Dir(DefaultSocketPath), "hooks-"+profile+".sock")
+}
+
+// maxMessageSize bounds a single notification; a full dist-upgrade of a few
+// thousand packages is well under this
+const maxMessageSize = 4 << 20
+
+// Listen accepts one JSON-encoded transaction per connection and passes it to
+// handle until ctx is cancelled. The socket is root-only: hooks run as root and
+// nothing else should be able to inject patch history.
+func Listen(ctx context.Context, logger *logrus.Logger, path string, handle func(*models.PackageTransaction)) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	// Remove a stale socket left by a previous run
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		_ = ln.Close()
+		return fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+	}()
+
+	logger.WithField("socket", path).Debug("Listening for package manager hook notifications")
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("hook socket accept failed: %w", err)
+		}
+		go func(c net.Conn) {
+			defer func() {
+				_ = c.Close()
+			}()
+			_ = c.SetReadDeadline(time.Now().Add(10 * time.Second))
+			var tx models.PackageTransaction
+			if err := json.NewDecoder(io.LimitReader(c, maxMessageSize)).Decode(&tx); err != nil {
+				logger.WithError(err).Debug("Ignoring malformed hook notification")
+				return
+			}
+			handle(&tx)
+		}(conn)
+	}
+}
+
+// Notify sends a transaction to the serve process. It fails fast when serve is
+// not running so package manager operations are never held up.
+func Notify(path string, tx *models.PackageTransaction) error {
+	conn, err := net.DialTimeout("unix", path, 2*time.Second)
+	if err != nil {
+		return fmt.Errorf("agent is not listening on %s: %w", path, err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	_ = conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	return json.NewEncoder(conn).Encode(tx)
+}
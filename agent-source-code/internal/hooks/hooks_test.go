@@ -0,0 +1,84 @@
+package hooks
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAptPreInstall(t *testing.T) {
+	input := `VERSION 2
+APT::Architecture=amd64
+Dir=/
+
+curl 7.81.0-1ubuntu1.14 < 7.81.0-1ubuntu1.15 /var/cache/apt/archives/curl_7.81.0-1ubuntu1.15_amd64.deb
+htop - < 3.0.5-7build2 /var/cache/apt/archives/htop_3.0.5-7build2_amd64.deb
+nano 6.2-1 < - **REMOVE**
+vim 2:8.2.3995-1ubuntu2.16 > 2:8.2.3995-1ubuntu2.15 /var/cache/apt/archives/vim.deb
+curl 7.81.0-1ubuntu1.14 < 7.81.0-1ubuntu1.15 **CONFIGURE**
+`
+	items, err := ParseAptPreInstall(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, items, 4)
+
+	assert.Equal(t, models.PackageTransactionItem{Name: "curl", Action: "upgrade", FromVersion: "7.81.0-1ubuntu1.14", ToVersion: "7.81.0-1ubuntu1.15"}, items[0])
+	assert.Equal(t, models.PackageTransactionItem{Name: "htop", Action: "install", ToVersion: "3.0.5-7build2"}, items[1])
+	assert.Equal(t, models.PackageTransactionItem{Name: "nano", Action: "remove", FromVersion: "6.2-1"}, items[2])
+	assert.Equal(t, "downgrade", items[3].Action)
+
+	_, err = ParseAptPreInstall(strings.NewReader("VERSION 3\n\n"))
+	assert.Error(t, err)
+}
+
+func TestPendingRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pending.json")
+
+	tx, err := TakePending(path)
+	require.NoError(t, err)
+	assert.Nil(t, tx)
+
+	require.NoError(t, SavePending(path, &models.PackageTransaction{Manager: "apt", Packages: []models.PackageTransactionItem{{Name: "curl"}}}))
+	tx, err = TakePending(path)
+	require.NoError(t, err)
+	require.NotNil(t, tx)
+	assert.Equal(t, "curl", tx.Packages[0].Name)
+
+	// Consumed: the next Post-Invoke finds nothing
+	tx, err = TakePending(path)
+	require.NoError(t, err)
+	assert.Nil(t, tx)
+}
+
+func TestListenAndNotify(t *testing.T) {
+	// Keep the path short: unix socket paths are limited to ~100 bytes
+	path := filepath.Join(t.TempDir(), "h.sock")
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan *models.PackageTransaction, 1)
+	go func() {
+		_ = Listen(ctx, logger, path, func(tx *models.PackageTransaction) { received <- tx })
+	}()
+
+	require.Eventually(t, func() bool {
+		return Notify(path, &models.PackageTransaction{Manager: "dnf"}) == nil
+	}, 2*time.Second, 20*time.Millisecond)
+
+	select {
+	case tx := <-received:
+		assert.Equal(t, "dnf", tx.Manager)
+	case <-time.After(2 * time.Second):
+		t.Fatal("notification not received")
+	}
+}
@@ -0,0 +1,127 @@
+package hooks
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	aptConfPath      = "/etc/apt/apt.conf.d/99patchmon-agent"
+	dnfPluginConf    = "/etc/dnf/plugins/patchmon.conf"
+	dnfPluginGlob    = "/usr/lib/python3*/site-packages/dnf-plugins"
+	dnfPluginFile    = "patchmon.py"
+	hookFileMarker   = "Installed by patchmon-agent"
+	agentPathPattern = "@AGENT@"
+)
+
+const aptConfTemplate = `// ` + hookFileMarker + `: report apt transactions to the running agent.
+// Remove with: patchmon-agent hooks uninstall
+DPkg::Pre-Install-Pkgs { "@AGENT@ hooks apt-pre || true"; };
+DPkg::Tools::Options::@AGENT@::Version "2";
+DPkg::Post-Invoke { "@AGENT@ hooks apt-post || true"; };
+`
+
+const dnfPluginTemplate = `# ` + hookFileMarker + `: report dnf transactions to the running agent.
+# Remove with: patchmon-agent hooks uninstall
+import json
+import subprocess
+import sys
+
+import dnf
+
+
+class PatchMon(dnf.Plugin):
+    name = "patchmon"
+
+    def transaction(self):
+        try:
+            removed = {p.name: p for p in self.base.transaction.remove_set}
+            packages = []
+            for pkg in self.base.transaction.install_set:
+                old = removed.pop(pkg.name, None)
+                item = {"name": pkg.name, "toVersion": pkg.evr}
+                if old is None:
+                    item["action"] = "install"
+                else:
+                    item["fromVersion"] = old.evr
+                    cmp = pkg.evr_cmp(old)
+                    item["action"] = "upgrade" if cmp > 0 else "downgrade" if cmp < 0 else "reinstall"
+                packages.append(item)
+            for name, old in removed.items():
+                packages.append({"name": name, "action": "remove", "fromVersion": old.evr})
+            body = {"manager": "dnf", "command": " ".join(sys.argv), "packages": packages}
+            subprocess.run(["@AGENT@", "hooks", "dnf"], input=json.dumps(body).encode(),
+                           timeout=10, stdout=subprocess.DEVNULL, stderr=subprocess.DEVNULL)
+        except Exception:
+            # Never let reporting break a package transaction
+            pass
+`
+
+const dnfPluginConfContent = "# " + hookFileMarker + "\n[main]\nenabled=1\n"
+
+// Install writes the apt and dnf hooks for whichever package managers are
+// present, pointing them at agentPath. Returns the files written.
+func Install(agentPath string) ([]string, error) {
+	var written []string
+
+	if dirExists(filepath.Dir(aptConfPath)) {
+		content := strings.ReplaceAll(aptConfTemplate, agentPathPattern, agentPath)
+		if err := os.WriteFile(aptConfPath, []byte(content), 0644); err != nil {
+			return written, fmt.Errorf("failed to write apt hook: %w", err)
+		}
+		written = append(written, aptConfPath)
+	}
+
+	pluginDirs, _ := filepath.Glob(dnfPluginGlob)
+	if len(pluginDirs) > 0 && dirExists(filepath.Dir(dnfPluginConf)) {
+		content := strings.ReplaceAll(dnfPluginTemplate, agentPathPattern, agentPath)
+		for _, dir := range pluginDirs {
+			path := filepath.Join(dir, dnfPluginFile)
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				return written, fmt.Errorf("failed to write dnf plugin: %w", err)
+			}
+			written = append(written, path)
+		}
+		if err := os.WriteFile(dnfPluginConf, []byte(dnfPluginConfContent), 0644); err != nil {
+			return written, fmt.Errorf("failed to write dnf plugin config: %w", err)
+		}
+		written = append(written, dnfPluginConf)
+	}
+
+	if len(written) == 0 {
+		return nil, errors.New("no supported package manager found (apt, or dnf with python plugins)")
+	}
+	return written, nil
+}
+
+// Uninstall removes hook files written by Install. Files without the marker are
+// left alone in case an unrelated file shares the name. Returns the files removed.
+func Uninstall() ([]string, error) {
+	candidates := []string{aptConfPath, dnfPluginConf}
+	if pluginDirs, _ := filepath.Glob(dnfPluginGlob); len(pluginDirs) > 0 {
+		for _, dir := range pluginDirs {
+			candidates = append(candidates, filepath.Join(dir, dnfPluginFile))
+		}
+	}
+
+	var removed []string
+	for _, path := range candidates {
+		data, err := os.ReadFile(path)
+		if err != nil || !strings.Contains(string(data), hookFileMarker) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
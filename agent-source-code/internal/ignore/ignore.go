@@ -0,0 +1,106 @@
+// Package ignore provides config-driven ignore patterns for report contents
+package ignore
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// regexPrefix marks a pattern as a regular expression instead of a glob
+const regexPrefix = "regex:"
+
+// Matcher matches names against a set of glob and regex patterns
+type Matcher struct {
+	globs   []string
+	regexes []*regexp.Regexp
+}
+
+// Compile builds a Matcher. Patterns are shell globs ("linux-headers-*") unless
+// prefixed with "regex:" ("regex:^lib.*-dev$"). Invalid patterns are skipped and
+// returned as errors so a typo in config.yml never blocks reporting.
+func Compile(patterns []string) (*Matcher, []error) {
+	m := &Matcher{}
+	var errs []error
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if expr, ok := strings.CutPrefix(p, regexPrefix); ok {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid ignore regex %q: %w", expr, err))
+				continue
+			}
+			m.regexes = append(m.regexes, re)
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid ignore glob %q: %w", p, err))
+			continue
+		}
+		m.globs = append(m.globs, p)
+	}
+	return m, errs
+}
+
+// Empty reports whether the matcher has no patterns
+func (m *Matcher) Empty() bool {
+	return m == nil || (len(m.globs) == 0 && len(m.regexes) == 0)
+}
+
+// Match reports whether any of the values matches any pattern
+func (m *Matcher) Match(values ...string) bool {
+	if m.Empty() {
+		return false
+	}
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		for _, g := range m.globs {
+			if ok, _ := path.Match(g, v); ok {
+				return true
+			}
+		}
+		for _, re := range m.regexes {
+			if re.MatchString(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FilterPackages returns the packages whose name does not match, and the number removed
+func (m *Matcher) FilterPackages(pkgs []models.Package) ([]models.Package, int) {
+	if m.Empty() {
+		return pkgs, 0
+	}
+	kept := pkgs[:0]
+	for _, p := range pkgs {
+		if !m.Match(p.Name) {
+			kept = append(kept, p)
+		}
+	}
+	return kept, len(pkgs) - len(kept)
+}
+
+// FilterRepositories returns the repositories whose name or URL does not match,
+// and the number removed
+func (m *Matcher) FilterRepositories(repos []models.Repository) ([]models.Repository, int) {
+	if m.Empty() {
+		return repos, 0
+	}
+	kept := repos[:0]
+	for _, r := range repos {
+		if !m.Match(r.Name, r.URL) {
+			kept = append(kept, r)
+		}
+	}
+	return kept, len(repos) - len(kept)
+}
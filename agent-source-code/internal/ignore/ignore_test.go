@@ -0,0 +1,42 @@
+package ignore
+
+import (
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatcher(t *testing.T) {
+	m, errs := Compile([]string{"linux-headers-*", "regex:^acme-.*-meta$", "  ", "regex:(", "[bad"})
+	assert.Len(t, errs, 2)
+
+	assert.True(t, m.Match("linux-headers-6.8.0-45"))
+	assert.True(t, m.Match("acme-base-meta"))
+	assert.False(t, m.Match("linux-image-6.8.0-45"))
+	assert.False(t, m.Match("acme-base"))
+	assert.True(t, m.Match("", "acme-web-meta"))
+}
+
+func TestFilter(t *testing.T) {
+	m, _ := Compile([]string{"*-dbgsym", "regex:vendor\\.example\\.com"})
+
+	pkgs, removed := m.FilterPackages([]models.Package{
+		{Name: "bash"}, {Name: "bash-dbgsym"}, {Name: "curl"},
+	})
+	assert.Equal(t, 1, removed)
+	assert.Equal(t, []models.Package{{Name: "bash"}, {Name: "curl"}}, pkgs)
+
+	repos, removed := m.FilterRepositories([]models.Repository{
+		{Name: "ubuntu-main", URL: "http://archive.ubuntu.com/ubuntu"},
+		{Name: "junk", URL: "https://vendor.example.com/apt"},
+	})
+	assert.Equal(t, 1, removed)
+	assert.Len(t, repos, 1)
+
+	var empty *Matcher
+	pkgs, removed = empty.FilterPackages([]models.Package{{Name: "bash"}})
+	assert.Equal(t, 0, removed)
+	assert.Len(t, pkgs, 1)
+}
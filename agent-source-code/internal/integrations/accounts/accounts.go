@@ -0,0 +1,211 @@
+// Package accounts summarises local user accounts and sudo rights for
+// security baseline reviews: accounts with login shells, admins, empty or
+// locked passwords and SSH authorized_keys per user.
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IntegrationName is the config/integration key for the account summary
+const IntegrationName = "user-accounts"
+
+// adminGroups grant sudo on common distributions (sudo on Debian/Ubuntu,
+// wheel on RHEL/Arch/FreeBSD, admin on older Ubuntu)
+var adminGroups = []string{"sudo", "wheel", "admin"}
+
+// Integration implements the Integration interface for user accounts
+type Integration struct {
+	logger *logrus.Logger
+	// root prefixes every path read, for tests
+	root string
+}
+
+// New creates a new user account integration
+func New(logger *logrus.Logger) *Integration {
+	return &Integration{logger: logger, root: "/"}
+}
+
+// Name returns the integration name
+func (a *Integration) Name() string {
+	return IntegrationName
+}
+
+// Priority returns the collection priority
+func (a *Integration) Priority() int {
+	return 40
+}
+
+// SupportsRealtime indicates the account summary is batch-only
+func (a *Integration) SupportsRealtime() bool {
+	return false
+}
+
+// IsAvailable reports whether there is a passwd database to read
+func (a *Integration) IsAvailable() bool {
+	if runtime.GOOS == "windows" {
+		return false
+	}
+	_, err := os.Stat(a.path("/etc/passwd"))
+	return err == nil
+}
+
+func (a *Integration) path(p string) string {
+	return filepath.Join(a.root, p)
+}
+
+// hostPath undoes path, for paths reported to the server
+func (a *Integration) hostPath(p string) string {
+	rel, err := filepath.Rel(a.root, p)
+	if err != nil {
+		return p
+	}
+	return "/" + filepath.ToSlash(rel)
+}
+
+// Collect reads the passwd, shadow and group databases and sudoers
+func (a *Integration) Collect(_ context.Context) (*models.IntegrationData, error) {
+	startTime := time.Now()
+
+	data, err := a.collect()
+	if err != nil {
+		return nil, err
+	}
+
+	admins := 0
+	for _, acct := range data.Accounts {
+		if acct.Admin {
+			admins++
+		}
+	}
+	a.logger.WithFields(logrus.Fields{
+		"accounts": len(data.Accounts),
+		"admins":   admins,
+		"rules":    len(data.SudoRules),
+	}).Info("Collected user accounts")
+
+	return &models.IntegrationData{
+		Name:          a.Name(),
+		Enabled:       true,
+		Data:          data,
+		CollectedAt:   utils.GetCurrentTimeUTC(),
+		ExecutionTime: time.Since(startTime).Seconds(),
+	}, nil
+}
+
+func (a *Integration) collect() (*models.UserAccountsData, error) {
+	entries, err := readPasswd(a.path("/etc/passwd"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passwd: %w", err)
+	}
+	data := &models.UserAccountsData{
+		Accounts:      make([]models.UserAccount, 0),
+		TotalAccounts: len(entries),
+	}
+
+	passwords, err := a.readPasswords()
+	if err != nil {
+		data.Warnings = append(data.Warnings, "password status unavailable: "+err.Error())
+	}
+
+	groups, err := readGroups(a.path("/etc/group"))
+	if err != nil {
+		data.Warnings = append(data.Warnings, "group database unavailable: "+err.Error())
+	}
+	gidNames := make(map[int]string, len(groups))
+	for _, g := range groups {
+		gidNames[g.gid] = g.name
+	}
+	for _, g := range groups {
+		if slices.Contains(adminGroups, g.name) {
+			data.AdminGroups = append(data.AdminGroups, g.name)
+		}
+	}
+
+	rules, warnings := readSudoers(a.sudoersPaths())
+	for i := range rules {
+		rules[i].Source = a.hostPath(rules[i].Source)
+	}
+	data.SudoRules = rules
+	data.Warnings = append(data.Warnings, warnings...)
+
+	firstUID := firstRegularUID(a.path("/etc/login.defs"))
+	for _, e := range entries {
+		acct := models.UserAccount{
+			Name:           e.name,
+			UID:            e.uid,
+			GID:            e.gid,
+			Home:           e.home,
+			Shell:          e.shell,
+			LoginShell:     isLoginShell(e.shell),
+			System:         e.uid < firstUID && e.uid != 0,
+			PasswordStatus: models.PasswordUnknown,
+		}
+		if status, ok := passwords[e.name]; ok {
+			acct.PasswordStatus = status
+		} else if passwords != nil {
+			acct.PasswordStatus = models.PasswordDisabled
+		}
+
+		if name, ok := gidNames[e.gid]; ok {
+			acct.Groups = append(acct.Groups, name)
+		}
+		for _, g := range groups {
+			if slices.Contains(g.members, e.name) && !slices.Contains(acct.Groups, g.name) {
+				acct.Groups = append(acct.Groups, g.name)
+			}
+		}
+		sort.Strings(acct.Groups)
+
+		if e.uid == 0 {
+			acct.AdminVia = append(acct.AdminVia, "uid 0")
+		}
+		for _, g := range acct.Groups {
+			if slices.Contains(adminGroups, g) {
+				acct.AdminVia = append(acct.AdminVia, "group "+g)
+			}
+		}
+		if sudoersNames(rules, e.name, acct.Groups) {
+			acct.AdminVia = append(acct.AdminVia, "sudoers")
+		}
+		acct.Admin = len(acct.AdminVia) > 0
+
+		if e.home != "" && e.home != "/" && e.home != "/nonexistent" {
+			acct.AuthorizedKeys = countAuthorizedKeys(a.path(e.home))
+		}
+
+		if acct.LoginShell || acct.Admin || acct.AuthorizedKeys > 0 || acct.PasswordStatus == models.PasswordEmpty {
+			data.Accounts = append(data.Accounts, acct)
+		}
+	}
+	return data, nil
+}
+
+// readPasswords returns the password status per user from /etc/shadow, or
+// /etc/master.passwd on the BSDs
+func (a *Integration) readPasswords() (map[string]string, error) {
+	if runtime.GOOS == "freebsd" {
+		return readShadow(a.path("/etc/master.passwd"), 1, bsdPasswordStatus)
+	}
+	return readShadow(a.path("/etc/shadow"), 1, shadowPasswordStatus)
+}
+
+// sudoersPaths lists the sudoers file and drop-in directory for this OS
+func (a *Integration) sudoersPaths() []string {
+	if runtime.GOOS == "freebsd" {
+		return []string{a.path("/usr/local/etc/sudoers"), a.path("/usr/local/etc/sudoers.d")}
+	}
+	return []string{a.path("/etc/sudoers"), a.path("/etc/sudoers.d")}
+}
@@ -0,0 +1,183 @@
+package accounts
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, root, path, content string) {
+	t.Helper()
+	full := filepath.Join(root, path)
+	require.NoError(t, os.MkdirAll(filepath.Dir(full), 0o755))
+	require.NoError(t, os.WriteFile(full, []byte(content), 0o600))
+}
+
+func testRoot(t *testing.T) string {
+	root := t.TempDir()
+	writeFile(t, root, "/etc/passwd", `root:x:0:0:root:/root:/bin/bash
+daemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin
+backup:x:34:34:backup:/var/backups:/bin/sh
+alice:x:1000:1000:Alice:/home/alice:/bin/bash
+bob:x:1001:1001:Bob:/home/bob:/bin/zsh
+deploy:x:1002:1002::/home/deploy:/usr/sbin/nologin
++::::::
+`)
+	writeFile(t, root, "/etc/shadow", `root:!:19000:0:99999:7:::
+daemon:*:19000:0:99999:7:::
+backup::19000:0:99999:7:::
+alice:$6$salt$hash:19000:0:99999:7:::
+bob:!$6$salt$hash:19000:0:99999:7:::
+deploy:!!:19000:0:99999:7:::
+`)
+	writeFile(t, root, "/etc/group", `root:x:0:
+sudo:x:27:alice
+alice:x:1000:
+bob:x:1001:
+deploy:x:1002:
+ops:x:2000:bob,deploy
+`)
+	writeFile(t, root, "/etc/sudoers", `Defaults	env_reset
+Cmnd_Alias RESTART = /bin/systemctl restart *
+root	ALL=(ALL:ALL) ALL
+%sudo	ALL=(ALL:ALL) ALL
+@includedir /etc/sudoers.d
+`)
+	writeFile(t, root, "/etc/sudoers.d/deploy", "deploy ALL=(root) NOPASSWD: /bin/systemctl restart app # app restarts\n")
+	writeFile(t, root, "/etc/sudoers.d/README", "# nothing here\n")
+	writeFile(t, root, "/etc/sudoers.d/old.bak", "bob ALL=(ALL) ALL\n")
+	writeFile(t, root, "/home/alice/.ssh/authorized_keys", "# work laptop\nssh-ed25519 AAAA alice@laptop\n\nssh-rsa AAAA alice@old\n")
+	writeFile(t, root, "/home/deploy/.ssh/authorized_keys2", "ssh-ed25519 AAAA ci\n")
+	return root
+}
+
+func testIntegration(root string) *Integration {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return &Integration{logger: logger, root: root}
+}
+
+func TestCollect(t *testing.T) {
+	if runtime.GOOS == "freebsd" {
+		t.Skip("fixture uses the Linux shadow layout")
+	}
+	integ := testIntegration(testRoot(t))
+	require.True(t, integ.IsAvailable())
+
+	result, err := integ.Collect(context.Background())
+	require.NoError(t, err)
+	data := result.Data.(*models.UserAccountsData)
+
+	assert.Equal(t, 6, data.TotalAccounts)
+	assert.Equal(t, []string{"sudo"}, data.AdminGroups)
+	require.Len(t, data.SudoRules, 3)
+	assert.Equal(t, models.SudoRule{
+		Principal: "deploy",
+		Spec:      "ALL=(root) NOPASSWD: /bin/systemctl restart app",
+		NoPasswd:  true,
+		Source:    "/etc/sudoers.d/deploy",
+	}, data.SudoRules[2])
+
+	byName := make(map[string]models.UserAccount)
+	for _, acct := range data.Accounts {
+		byName[acct.Name] = acct
+	}
+	assert.NotContains(t, byName, "daemon", "no login shell, keys or admin rights")
+
+	root := byName["root"]
+	assert.Equal(t, []string{"uid 0", "sudoers"}, root.AdminVia)
+	assert.Equal(t, models.PasswordDisabled, root.PasswordStatus)
+	assert.False(t, root.System)
+
+	alice := byName["alice"]
+	assert.True(t, alice.Admin)
+	assert.Equal(t, []string{"group sudo", "sudoers"}, alice.AdminVia)
+	assert.Equal(t, []string{"alice", "sudo"}, alice.Groups)
+	assert.Equal(t, models.PasswordSet, alice.PasswordStatus)
+	assert.Equal(t, 2, alice.AuthorizedKeys)
+
+	bob := byName["bob"]
+	assert.False(t, bob.Admin, "rules in skipped sudoers.d files don't count")
+	assert.Equal(t, models.PasswordLocked, bob.PasswordStatus)
+
+	deploy := byName["deploy"]
+	assert.False(t, deploy.LoginShell)
+	assert.Equal(t, []string{"sudoers"}, deploy.AdminVia)
+	assert.Equal(t, 1, deploy.AuthorizedKeys)
+
+	backup := byName["backup"]
+	assert.True(t, backup.System)
+	assert.Equal(t, models.PasswordEmpty, backup.PasswordStatus)
+}
+
+func TestCollectWithoutShadow(t *testing.T) {
+	root := testRoot(t)
+	require.NoError(t, os.Remove(filepath.Join(root, "etc/shadow")))
+
+	data, err := testIntegration(root).collect()
+	require.NoError(t, err)
+	require.NotEmpty(t, data.Accounts)
+	for _, acct := range data.Accounts {
+		assert.Equal(t, models.PasswordUnknown, acct.PasswordStatus)
+	}
+	assert.NotEmpty(t, data.Warnings)
+}
+
+func TestCollectWithoutPasswd(t *testing.T) {
+	integ := testIntegration(t.TempDir())
+	assert.False(t, integ.IsAvailable())
+	_, err := integ.collect()
+	assert.Error(t, err)
+}
+
+func TestPasswordStatus(t *testing.T) {
+	for hash, want := range map[string]string{
+		"":              models.PasswordEmpty,
+		"*":             models.PasswordDisabled,
+		"!!":            models.PasswordDisabled,
+		"!$6$x$y":       models.PasswordLocked,
+		"$y$j9T$abc$de": models.PasswordSet,
+	} {
+		assert.Equal(t, want, shadowPasswordStatus(hash), "shadow %q", hash)
+	}
+	for hash, want := range map[string]string{
+		"":                models.PasswordEmpty,
+		"*":               models.PasswordDisabled,
+		"*LOCKED*$6$x$y":  models.PasswordLocked,
+		"$6$rounds$abcde": models.PasswordSet,
+	} {
+		assert.Equal(t, want, bsdPasswordStatus(hash), "master.passwd %q", hash)
+	}
+}
+
+func TestIsLoginShell(t *testing.T) {
+	assert.True(t, isLoginShell("/bin/bash"))
+	assert.True(t, isLoginShell(""))
+	assert.False(t, isLoginShell("/usr/sbin/nologin"))
+	assert.False(t, isLoginShell("/bin/false"))
+	assert.False(t, isLoginShell("/bin/sync"))
+}
+
+func TestFirstRegularUID(t *testing.T) {
+	root := t.TempDir()
+	assert.Equal(t, defaultFirstUID, firstRegularUID(filepath.Join(root, "login.defs")))
+
+	writeFile(t, root, "login.defs", "# comment\nUID_MIN\t\t\t  500\nUID_MAX 60000\n")
+	assert.Equal(t, 500, firstRegularUID(filepath.Join(root, "login.defs")))
+}
+
+func TestSudoersNames(t *testing.T) {
+	rules := []models.SudoRule{{Principal: "carol,%admins"}}
+	assert.True(t, sudoersNames(rules, "carol", nil))
+	assert.True(t, sudoersNames(rules, "dave", []string{"admins"}))
+	assert.False(t, sudoersNames(rules, "dave", []string{"users"}))
+}
@@ -0,0 +1,284 @@
+package accounts
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// defaultFirstUID is the first regular user UID when login.defs doesn't say
+const defaultFirstUID = 1000
+
+type passwdEntry struct {
+	name  string
+	uid   int
+	gid   int
+	home  string
+	shell string
+}
+
+type groupEntry struct {
+	name    string
+	gid     int
+	members []string
+}
+
+// readColonFile returns the colon-separated fields of each line of path,
+// skipping blanks and comments
+func readColonFile(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var rows [][]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rows = append(rows, strings.Split(line, ":"))
+	}
+	return rows, scanner.Err()
+}
+
+func readPasswd(path string) ([]passwdEntry, error) {
+	rows, err := readColonFile(path)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]passwdEntry, 0, len(rows))
+	for _, f := range rows {
+		// NIS compat lines (+, -) carry no local account
+		if len(f) < 7 || strings.HasPrefix(f[0], "+") || strings.HasPrefix(f[0], "-") {
+			continue
+		}
+		uid, err1 := strconv.Atoi(f[2])
+		gid, err2 := strconv.Atoi(f[3])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		entries = append(entries, passwdEntry{name: f[0], uid: uid, gid: gid, home: f[5], shell: f[6]})
+	}
+	return entries, nil
+}
+
+func readGroups(path string) ([]groupEntry, error) {
+	rows, err := readColonFile(path)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]groupEntry, 0, len(rows))
+	for _, f := range rows {
+		if len(f) < 4 {
+			continue
+		}
+		gid, err := strconv.Atoi(f[2])
+		if err != nil {
+			continue
+		}
+		g := groupEntry{name: f[0], gid: gid}
+		for _, m := range strings.Split(f[3], ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				g.members = append(g.members, m)
+			}
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// readShadow maps each user to the status of the password hash in field
+func readShadow(path string, field int, status func(string) string) (map[string]string, error) {
+	rows, err := readColonFile(path)
+	if err != nil {
+		return nil, err
+	}
+	passwords := make(map[string]string, len(rows))
+	for _, f := range rows {
+		if len(f) <= field {
+			continue
+		}
+		passwords[f[0]] = status(f[field])
+	}
+	return passwords, nil
+}
+
+// shadowPasswordStatus classifies a Linux shadow hash
+func shadowPasswordStatus(hash string) string {
+	switch {
+	case hash == "":
+		return models.PasswordEmpty
+	case hash == "*" || hash == "!" || hash == "!!" || hash == "!*" || hash == "*LK*":
+		return models.PasswordDisabled
+	case strings.HasPrefix(hash, "!"):
+		return models.PasswordLocked
+	}
+	return models.PasswordSet
+}
+
+// bsdPasswordStatus classifies a master.passwd hash
+func bsdPasswordStatus(hash string) string {
+	switch {
+	case hash == "":
+		return models.PasswordEmpty
+	case strings.HasPrefix(hash, "*LOCKED*"):
+		return models.PasswordLocked
+	case strings.HasPrefix(hash, "*"):
+		return models.PasswordDisabled
+	}
+	return models.PasswordSet
+}
+
+// isLoginShell reports whether shell lets the account log in interactively
+func isLoginShell(shell string) bool {
+	if shell == "" {
+		// login falls back to /bin/sh
+		return true
+	}
+	switch filepath.Base(shell) {
+	case "nologin", "false", "sync", "shutdown", "halt":
+		return false
+	}
+	return true
+}
+
+// firstRegularUID reads UID_MIN from login.defs
+func firstRegularUID(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return defaultFirstUID
+	}
+	defer func() { _ = f.Close() }()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "UID_MIN" {
+			if v, err := strconv.Atoi(fields[1]); err == nil {
+				return v
+			}
+		}
+	}
+	return defaultFirstUID
+}
+
+// countAuthorizedKeys counts key lines in the user's authorized_keys files
+func countAuthorizedKeys(home string) int {
+	count := 0
+	for _, name := range []string{"authorized_keys", "authorized_keys2"} {
+		f, err := os.Open(filepath.Join(home, ".ssh", name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				count++
+			}
+		}
+		_ = f.Close()
+	}
+	return count
+}
+
+// sudoersTags are sudoers keywords that start lines which aren't user
+// specifications
+var sudoersTags = []string{"Defaults", "User_Alias", "Runas_Alias", "Host_Alias", "Cmnd_Alias", "Cmd_Alias"}
+
+// readSudoers collects user specifications from sudoers files. A directory is
+// read like sudo's @includedir: files with a dot or ending in ~ are skipped.
+func readSudoers(paths []string) ([]models.SudoRule, []string) {
+	var rules []models.SudoRule
+	var warnings []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				warnings = append(warnings, "sudoers unreadable: "+err.Error())
+			}
+			continue
+		}
+		files := []string{path}
+		if info.IsDir() {
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				warnings = append(warnings, "sudoers unreadable: "+err.Error())
+				continue
+			}
+			files = files[:0]
+			for _, e := range entries {
+				if e.IsDir() || strings.Contains(e.Name(), ".") || strings.HasSuffix(e.Name(), "~") {
+					continue
+				}
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+		for _, file := range files {
+			fileRules, err := parseSudoersFile(file)
+			if err != nil {
+				warnings = append(warnings, "sudoers unreadable: "+err.Error())
+				continue
+			}
+			rules = append(rules, fileRules...)
+		}
+	}
+	return rules, warnings
+}
+
+func parseSudoersFile(path string) ([]models.SudoRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []models.SudoRule
+	// Join continuation lines before parsing
+	text := strings.ReplaceAll(string(data), "\\\n", " ")
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		// #include and #includedir look like comments but aren't rules either
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "@") {
+			continue
+		}
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		i := strings.IndexAny(line, " \t")
+		if i < 0 {
+			continue
+		}
+		principal, spec := line[:i], strings.TrimSpace(line[i:])
+		if slices.ContainsFunc(sudoersTags, func(tag string) bool { return strings.HasPrefix(principal, tag) }) {
+			continue
+		}
+		rules = append(rules, models.SudoRule{
+			Principal: principal,
+			Spec:      spec,
+			NoPasswd:  strings.Contains(spec, "NOPASSWD:"),
+			Source:    path,
+		})
+	}
+	return rules, nil
+}
+
+// sudoersNames reports whether a sudoers rule names the user directly or
+// through one of their groups
+func sudoersNames(rules []models.SudoRule, user string, groups []string) bool {
+	for _, r := range rules {
+		for _, p := range strings.Split(r.Principal, ",") {
+			p = strings.TrimSpace(p)
+			if p == user || (strings.HasPrefix(p, "%") && slices.Contains(groups, strings.TrimPrefix(p, "%"))) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,372 @@
+package compliance
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// cklViewerVersion is the STIG Viewer release whose checklist layout is written
+const cklViewerVersion = "2.17"
+
+// CKLAsset describes the host a checklist is for
+type CKLAsset struct {
+	HostName string
+	HostIP   string
+	HostMAC  string
+	HostFQDN string
+	Role     string // None, Workstation, Member Server or Domain Controller
+}
+
+// Checklist is a DISA STIG Viewer checklist (.ckl)
+type Checklist struct {
+	XMLName xml.Name  `xml:"CHECKLIST"`
+	Asset   cklAsset  `xml:"ASSET"`
+	STIGs   []cklSTIG `xml:"STIGS>iSTIG"`
+}
+
+type cklAsset struct {
+	Role          string `xml:"ROLE"`
+	AssetType     string `xml:"ASSET_TYPE"`
+	Marking       string `xml:"MARKING"`
+	HostName      string `xml:"HOST_NAME"`
+	HostIP        string `xml:"HOST_IP"`
+	HostMAC       string `xml:"HOST_MAC"`
+	HostFQDN      string `xml:"HOST_FQDN"`
+	TargetComment string `xml:"TARGET_COMMENT"`
+	TechArea      string `xml:"TECH_AREA"`
+	TargetKey     string `xml:"TARGET_KEY"`
+	WebOrDatabase bool   `xml:"WEB_OR_DATABASE"`
+	WebDBSite     string `xml:"WEB_DB_SITE"`
+	WebDBInstance string `xml:"WEB_DB_INSTANCE"`
+}
+
+type cklSTIG struct {
+	Info  []cklSIData `xml:"STIG_INFO>SI_DATA"`
+	Vulns []cklVuln   `xml:"VULN"`
+}
+
+type cklSIData struct {
+	Name string `xml:"SID_NAME"`
+	Data string `xml:"SID_DATA,omitempty"`
+}
+
+type cklVuln struct {
+	Data                  []cklSTIGData `xml:"STIG_DATA"`
+	Status                string        `xml:"STATUS"`
+	FindingDetails        string        `xml:"FINDING_DETAILS"`
+	Comments              string        `xml:"COMMENTS"`
+	SeverityOverride      string        `xml:"SEVERITY_OVERRIDE"`
+	SeverityJustification string        `xml:"SEVERITY_JUSTIFICATION"`
+}
+
+type cklSTIGData struct {
+	Attribute string `xml:"VULN_ATTRIBUTE"`
+	Data      string `xml:"ATTRIBUTE_DATA"`
+}
+
+// XCCDF results as written by oscap xccdf eval --results: the benchmark with
+// its rules, followed by the TestResult
+type xccdfBenchmark struct {
+	ID          string            `xml:"id,attr"`
+	Title       xccdfText         `xml:"title"`
+	Description xccdfText         `xml:"description"`
+	Version     string            `xml:"version"`
+	PlainText   []xccdfPlainText  `xml:"plain-text"`
+	Groups      []xccdfGroup      `xml:"Group"`
+	Rules       []xccdfRule       `xml:"Rule"`
+	TestResults []xccdfTestResult `xml:"TestResult"`
+}
+
+type xccdfPlainText struct {
+	ID   string `xml:"id,attr"`
+	Text string `xml:",chardata"`
+}
+
+type xccdfGroup struct {
+	ID     string       `xml:"id,attr"`
+	Title  xccdfText    `xml:"title"`
+	Groups []xccdfGroup `xml:"Group"`
+	Rules  []xccdfRule  `xml:"Rule"`
+}
+
+type xccdfRule struct {
+	ID           string     `xml:"id,attr"`
+	Severity     string     `xml:"severity,attr"`
+	Weight       string     `xml:"weight,attr"`
+	Version      string     `xml:"version"`
+	Title        xccdfText  `xml:"title"`
+	Description  xccdfText  `xml:"description"`
+	FixText      xccdfText  `xml:"fixtext"`
+	CheckContent xccdfText  `xml:"check>check-content"`
+	References   []xccdfRef `xml:"reference"`
+	Idents       []xccdfRef `xml:"ident"`
+}
+
+type xccdfRef struct {
+	Value string `xml:",chardata"`
+}
+
+type xccdfTestResult struct {
+	Profile struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"profile"`
+	Results []xccdfRuleResult `xml:"rule-result"`
+}
+
+type xccdfRuleResult struct {
+	IDRef    string   `xml:"idref,attr"`
+	Result   string   `xml:"result"`
+	Messages []string `xml:"message"`
+}
+
+// xccdfText keeps an element's markup; descriptions contain XHTML
+type xccdfText struct {
+	Inner string `xml:",innerxml"`
+}
+
+// String returns the element's text without markup
+func (t xccdfText) String() string {
+	var b strings.Builder
+	dec := xml.NewDecoder(strings.NewReader("<t>" + t.Inner + "</t>"))
+	dec.Strict = false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		if cd, ok := tok.(xml.CharData); ok {
+			b.Write(cd)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+var (
+	vulnNumPattern = regexp.MustCompile(`^V-\d+$`)
+	// STIG IDs such as RHEL-08-010010 or UBTU-20-010000
+	stigIDPattern = regexp.MustCompile(`^[A-Z0-9]+-\d{2}-\d{6}$`)
+)
+
+// cklRule is a rule with the group it sits in
+type cklRule struct {
+	rule  xccdfRule
+	group xccdfGroup
+}
+
+// BuildCKL turns XCCDF results into a STIG Viewer checklist. STIG IDs, CCIs
+// and SRGs come from the rule metadata: DISA benchmarks carry them as group
+// and rule IDs, SCAP Security Guide content as rule references.
+func BuildCKL(xccdfResults []byte, asset CKLAsset) (*Checklist, error) {
+	var bench xccdfBenchmark
+	if err := xml.Unmarshal(xccdfResults, &bench); err != nil {
+		return nil, fmt.Errorf("failed to parse XCCDF results: %w", err)
+	}
+	if len(bench.TestResults) == 0 {
+		return nil, fmt.Errorf("XCCDF document has no TestResult")
+	}
+	result := bench.TestResults[len(bench.TestResults)-1]
+
+	rules := make(map[string]cklRule)
+	var collect func(g xccdfGroup)
+	collect = func(g xccdfGroup) {
+		for _, r := range g.Rules {
+			rules[r.ID] = cklRule{rule: r, group: g}
+		}
+		for _, sub := range g.Groups {
+			collect(sub)
+		}
+	}
+	collect(xccdfGroup{Groups: bench.Groups, Rules: bench.Rules})
+
+	uuid := newCKLUUID()
+	stigRef := bench.Title.String()
+	if bench.Version != "" {
+		stigRef += " :: Version " + bench.Version
+	}
+	releaseInfo := ""
+	for _, pt := range bench.PlainText {
+		if pt.ID == "release-info" {
+			releaseInfo = strings.TrimSpace(pt.Text)
+		}
+	}
+
+	stig := cklSTIG{Info: []cklSIData{
+		{Name: "version", Data: bench.Version},
+		{Name: "classification", Data: "UNCLASSIFIED"},
+		{Name: "customname"},
+		{Name: "stigid", Data: strings.TrimPrefix(bench.ID, "xccdf_org.ssgproject.content_benchmark_")},
+		{Name: "description", Data: bench.Description.String()},
+		{Name: "filename", Data: "xccdf-results.xml"},
+		{Name: "releaseinfo", Data: releaseInfo},
+		{Name: "title", Data: bench.Title.String()},
+		{Name: "uuid", Data: uuid},
+		{Name: "notice", Data: "terms-of-use"},
+		{Name: "source", Data: result.Profile.IDRef},
+	}}
+
+	for _, rr := range result.Results {
+		status, ok := cklStatus(rr.Result)
+		if !ok {
+			continue
+		}
+		r, found := rules[rr.IDRef]
+		if !found {
+			r = cklRule{rule: xccdfRule{ID: rr.IDRef}}
+		}
+		stig.Vulns = append(stig.Vulns, cklVulnFor(r, rr, status, stigRef, uuid))
+	}
+
+	role := asset.Role
+	if role == "" {
+		role = "None"
+	}
+	return &Checklist{
+		Asset: cklAsset{
+			Role:      role,
+			AssetType: "Computing",
+			Marking:   "CUI",
+			HostName:  asset.HostName,
+			HostIP:    asset.HostIP,
+			HostMAC:   asset.HostMAC,
+			HostFQDN:  asset.HostFQDN,
+		},
+		STIGs: []cklSTIG{stig},
+	}, nil
+}
+
+func cklVulnFor(r cklRule, rr xccdfRuleResult, status, stigRef, uuid string) cklVuln {
+	var vulnNum, ruleVer, srg string
+	var ccis []string
+	if vulnNumPattern.MatchString(r.group.ID) || strings.HasPrefix(r.group.ID, "xccdf_mil.disa.stig_group_") {
+		vulnNum = strings.TrimPrefix(r.group.ID, "xccdf_mil.disa.stig_group_")
+		srg = r.group.Title.String()
+	}
+	ruleVer = r.rule.Version
+	for _, ref := range append(append([]xccdfRef{}, r.rule.References...), r.rule.Idents...) {
+		v := strings.TrimSpace(ref.Value)
+		switch {
+		case strings.HasPrefix(v, "CCI-"):
+			ccis = append(ccis, v)
+		case strings.HasPrefix(v, "SRG-") && srg == "":
+			srg = v
+		case vulnNumPattern.MatchString(v) && vulnNum == "":
+			vulnNum = v
+		case stigIDPattern.MatchString(v) && ruleVer == "":
+			ruleVer = v
+		}
+	}
+	ruleID := strings.TrimPrefix(r.rule.ID, "xccdf_mil.disa.stig_rule_")
+	if vulnNum == "" {
+		vulnNum = ruleVer
+	}
+	if vulnNum == "" {
+		vulnNum = ruleID
+	}
+	title := r.rule.Title.String()
+	if srg == "" {
+		srg = title
+	}
+	weight := r.rule.Weight
+	if weight == "" {
+		weight = "10.0"
+	}
+
+	data := []cklSTIGData{
+		{"Vuln_Num", vulnNum},
+		{"Severity", cklSeverity(r.rule.Severity)},
+		{"Group_Title", srg},
+		{"Rule_ID", ruleID},
+		{"Rule_Ver", ruleVer},
+		{"Rule_Title", title},
+		{"Vuln_Discuss", r.rule.Description.String()},
+		{"IA_Controls", ""},
+		{"Check_Content", r.rule.CheckContent.String()},
+		{"Fix_Text", r.rule.FixText.String()},
+		{"False_Positives", ""},
+		{"False_Negatives", ""},
+		{"Documentable", "false"},
+		{"Mitigations", ""},
+		{"Potential_Impact", ""},
+		{"Third_Party_Tools", ""},
+		{"Mitigation_Control", ""},
+		{"Responsibility", ""},
+		{"Security_Override_Guidance", ""},
+		{"Check_Content_Ref", ""},
+		{"Weight", weight},
+		{"Class", "Unclass"},
+		{"STIGRef", stigRef},
+		{"TargetKey", ""},
+		{"STIG_UUID", uuid},
+	}
+	for _, cci := range ccis {
+		data = append(data, cklSTIGData{"CCI_REF", cci})
+	}
+
+	details := "OpenSCAP result: " + rr.Result
+	for _, msg := range rr.Messages {
+		if msg = strings.TrimSpace(msg); msg != "" {
+			details += "\n" + msg
+		}
+	}
+	return cklVuln{Data: data, Status: status, FindingDetails: details}
+}
+
+// cklStatus maps an XCCDF result to a checklist status. Rules the profile
+// didn't select are left out.
+func cklStatus(result string) (string, bool) {
+	switch strings.TrimSpace(result) {
+	case "pass", "fixed":
+		return "NotAFinding", true
+	case "fail":
+		return "Open", true
+	case "notapplicable":
+		return "Not_Applicable", true
+	case "notselected":
+		return "", false
+	default: // error, unknown, notchecked, informational
+		return "Not_Reviewed", true
+	}
+}
+
+// cklSeverity maps XCCDF severities to the three STIG categories
+func cklSeverity(severity string) string {
+	switch severity {
+	case "high", "medium":
+		return severity
+	default:
+		return "low"
+	}
+}
+
+// WriteCKL writes a checklist as STIG Viewer saves it
+func WriteCKL(w io.Writer, ckl *Checklist) error {
+	data, err := xml.MarshalIndent(ckl, "", "\t")
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString("<!--DISA STIG Viewer :: " + cklViewerVersion + "-->\n")
+	buf.Write(data)
+	buf.WriteString("\n")
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// newCKLUUID returns a random version 4 UUID
+func newCKLUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "00000000-0000-4000-8000-000000000000"
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
@@ -0,0 +1,123 @@
+package compliance
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testXCCDFResults = `<?xml version="1.0" encoding="UTF-8"?>
+<Benchmark xmlns="http://checklists.nist.gov/xccdf/1.2" xmlns:html="http://www.w3.org/1999/xhtml" id="xccdf_org.ssgproject.content_benchmark_RHEL-8">
+  <title>Guide to the Secure Configuration of Red Hat Enterprise Linux 8</title>
+  <version>0.1.72</version>
+  <Group id="xccdf_org.ssgproject.content_group_system">
+    <Group id="xccdf_org.ssgproject.content_group_accounts">
+      <Rule id="xccdf_org.ssgproject.content_rule_accounts_tmout" severity="medium">
+        <title>Set Interactive Session Timeout</title>
+        <description>Setting the <html:code>TMOUT</html:code> option &amp; more.</description>
+        <reference href="https://public.cyber.mil/stigs/cci/">CCI-000057</reference>
+        <reference href="https://public.cyber.mil/stigs/cci/">CCI-001133</reference>
+        <reference href="https://public.cyber.mil/stigs/srg-stig-tools/">SRG-OS-000163-GPOS-00072</reference>
+        <reference href="https://public.cyber.mil/stigs/downloads/">RHEL-08-020070</reference>
+      </Rule>
+      <Rule id="xccdf_org.ssgproject.content_rule_no_empty_passwords" severity="high">
+        <title>Prevent Login to Accounts With Empty Password</title>
+      </Rule>
+      <Rule id="xccdf_org.ssgproject.content_rule_unselected" severity="low">
+        <title>Not in the profile</title>
+      </Rule>
+    </Group>
+  </Group>
+  <Group id="V-230221">
+    <title>SRG-OS-000480-GPOS-00227</title>
+    <Rule id="SV-230221r743913_rule" severity="high" weight="10.0">
+      <version>RHEL-08-010000</version>
+      <title>RHEL 8 must be a vendor-supported release.</title>
+      <check system="C-32890r567410_chk"><check-content>Verify the version.</check-content></check>
+      <fixtext>Upgrade to a supported version.</fixtext>
+      <ident system="http://cyber.mil/cci">CCI-000366</ident>
+    </Rule>
+  </Group>
+  <TestResult id="xccdf_org.open-scap_testresult_stig">
+    <profile idref="xccdf_org.ssgproject.content_profile_stig"/>
+    <rule-result idref="xccdf_org.ssgproject.content_rule_accounts_tmout"><result>fail</result><message>TMOUT is not set</message></rule-result>
+    <rule-result idref="xccdf_org.ssgproject.content_rule_no_empty_passwords"><result>pass</result></rule-result>
+    <rule-result idref="xccdf_org.ssgproject.content_rule_unselected"><result>notselected</result></rule-result>
+    <rule-result idref="SV-230221r743913_rule"><result>notapplicable</result></rule-result>
+    <rule-result idref="xccdf_org.ssgproject.content_rule_missing"><result>notchecked</result></rule-result>
+  </TestResult>
+</Benchmark>`
+
+// vulnData returns a VULN's STIG_DATA as a map, with CCI_REFs joined
+func vulnData(v cklVuln) map[string]string {
+	data := make(map[string]string)
+	for _, d := range v.Data {
+		if d.Attribute == "CCI_REF" && data["CCI_REF"] != "" {
+			data["CCI_REF"] += "," + d.Data
+			continue
+		}
+		data[d.Attribute] = d.Data
+	}
+	return data
+}
+
+func TestBuildCKL(t *testing.T) {
+	ckl, err := BuildCKL([]byte(testXCCDFResults), CKLAsset{HostName: "web01", HostIP: "10.0.0.5"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "None", ckl.Asset.Role)
+	assert.Equal(t, "web01", ckl.Asset.HostName)
+	require.Len(t, ckl.STIGs, 1)
+	vulns := ckl.STIGs[0].Vulns
+	require.Len(t, vulns, 4, "notselected rules are left out")
+
+	tmout := vulnData(vulns[0])
+	assert.Equal(t, "Open", vulns[0].Status)
+	assert.Equal(t, "RHEL-08-020070", tmout["Vuln_Num"])
+	assert.Equal(t, "RHEL-08-020070", tmout["Rule_Ver"])
+	assert.Equal(t, "SRG-OS-000163-GPOS-00072", tmout["Group_Title"])
+	assert.Equal(t, "medium", tmout["Severity"])
+	assert.Equal(t, "CCI-000057,CCI-001133", tmout["CCI_REF"])
+	assert.Equal(t, "Setting the TMOUT option & more.", tmout["Vuln_Discuss"])
+	assert.Contains(t, vulns[0].FindingDetails, "TMOUT is not set")
+
+	assert.Equal(t, "NotAFinding", vulns[1].Status)
+	assert.Equal(t, "high", vulnData(vulns[1])["Severity"])
+
+	disa := vulnData(vulns[2])
+	assert.Equal(t, "Not_Applicable", vulns[2].Status)
+	assert.Equal(t, "V-230221", disa["Vuln_Num"])
+	assert.Equal(t, "SV-230221r743913_rule", disa["Rule_ID"])
+	assert.Equal(t, "RHEL-08-010000", disa["Rule_Ver"])
+	assert.Equal(t, "SRG-OS-000480-GPOS-00227", disa["Group_Title"])
+	assert.Equal(t, "Verify the version.", disa["Check_Content"])
+	assert.Equal(t, "Upgrade to a supported version.", disa["Fix_Text"])
+	assert.Equal(t, "CCI-000366", disa["CCI_REF"])
+
+	assert.Equal(t, "Not_Reviewed", vulns[3].Status)
+	assert.Equal(t, "low", vulnData(vulns[3])["Severity"])
+}
+
+func TestWriteCKL(t *testing.T) {
+	ckl, err := BuildCKL([]byte(testXCCDFResults), CKLAsset{HostName: "web01"})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, WriteCKL(&buf, ckl))
+
+	out := buf.String()
+	assert.True(t, strings.HasPrefix(out, xml.Header+"<!--DISA STIG Viewer"))
+	assert.Contains(t, out, "<STIGS>\n\t\t<iSTIG>")
+
+	var parsed Checklist
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &parsed))
+	assert.Equal(t, ckl.STIGs[0].Vulns, parsed.STIGs[0].Vulns)
+}
+
+func TestBuildCKLRequiresTestResult(t *testing.T) {
+	_, err := BuildCKL([]byte(`<Benchmark id="b"><title>t</title></Benchmark>`), CKLAsset{})
+	assert.Error(t, err)
+}
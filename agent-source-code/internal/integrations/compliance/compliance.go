@@ -2,18 +2,60 @@
 package compliance
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"sync"
 	"time"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/packages"
+	"patchmon-agent/internal/tracing"
 	"patchmon-agent/internal/utils"
-	"patchmon-agent/pkg/models"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const integrationName = "compliance"
 
+var (
+	cacheRefreshMu sync.RWMutex
+	cacheRefresh   = packages.CacheRefreshConfig{Mode: "always"}
+)
+
+// SetPackageCacheRefresh applies the agent's package_cache_refresh settings to
+// the package installs scanners perform, so "never" also stops the apt-get update
+// before installing OpenSCAP and dnf/zypper metadata refreshes.
+func SetPackageCacheRefresh(cfg packages.CacheRefreshConfig) {
+	cacheRefreshMu.Lock()
+	defer cacheRefreshMu.Unlock()
+	cacheRefresh = cfg
+}
+
+func packageCacheRefresh() packages.CacheRefreshConfig {
+	cacheRefreshMu.RLock()
+	defer cacheRefreshMu.RUnlock()
+	return cacheRefresh
+}
+
+// dnfCacheArgs keeps dnf/yum on their cached metadata when the cache must not be refreshed
+func dnfCacheArgs() []string {
+	if packageCacheRefresh().Mode == "never" {
+		return []string{"--setopt=metadata_expire=-1"}
+	}
+	return nil
+}
+
+// zypperCacheArgs are zypper's global options for the same
+func zypperCacheArgs() []string {
+	if packageCacheRefresh().Mode == "never" {
+		return []string{"--no-refresh"}
+	}
+	return nil
+}
+
 // ScannerOptionsGetter returns openscap and docker bench enabled flags for scheduled scans.
 // When set, used when CollectWithOptions is called with options=nil.
 type ScannerOptionsGetter func() (openscapEnabled, dockerBenchEnabled bool)
@@ -42,6 +84,12 @@ func (c *Integration) SetScannerOptionsGetter(getter ScannerOptionsGetter) {
 	c.scannerOptionsGetter = getter
 }
 
+// SetProgressFunc sets the function told about each rule an OpenSCAP scan
+// evaluates
+func (c *Integration) SetProgressFunc(fn ProgressFunc) {
+	c.openscap.SetProgressFunc(fn)
+}
+
 // SetDockerIntegrationEnabled sets whether Docker integration is enabled
 // Docker Bench scans will only run if this is true AND Docker is available
 func (c *Integration) SetDockerIntegrationEnabled(enabled bool) {
@@ -132,17 +180,22 @@ func (c *Integration) CollectWithOptions(ctx context.Context, options *models.Co
 		var scan *models.ComplianceScan
 		var err error
 
-		if options != nil && options.EnableRemediation {
+		remediate := options != nil && options.EnableRemediation
+		scanCtx, span := tracing.Start(ctx, "openscap.scan",
+			attribute.String("compliance.profile", profileID),
+			attribute.Bool("compliance.remediation", remediate))
+		if remediate {
 			c.logger.Info("Running OpenSCAP CIS benchmark scan with remediation enabled...")
-			scan, err = c.openscap.RunScanWithOptions(ctx, options)
+			scan, err = c.openscap.RunScanWithOptions(scanCtx, options)
 		} else {
 			c.logger.Info("Running OpenSCAP CIS benchmark scan...")
 			scanProfileID := "level1_server"
 			if profileID != "" {
 				scanProfileID = profileID
 			}
-			scan, err = c.openscap.RunScan(ctx, scanProfileID)
+			scan, err = c.openscap.RunScan(scanCtx, scanProfileID)
 		}
+		tracing.End(span, err)
 
 		if err != nil {
 			c.logger.WithError(err).Warn("OpenSCAP scan failed")
@@ -174,7 +227,9 @@ func (c *Integration) CollectWithOptions(ctx context.Context, options *models.Co
 	runDockerBench := dockerBenchEffectivelyAvailable && dockerBenchScanEnabled && (isDockerBenchOnly || profileID == "" || profileID == "all")
 	if runDockerBench {
 		c.logger.Info("Running Docker Bench for Security scan...")
-		scan, err := c.dockerBench.RunScan(ctx)
+		scanCtx, span := tracing.Start(ctx, "docker_bench.scan")
+		scan, err := c.dockerBench.RunScan(scanCtx)
+		tracing.End(span, err)
 		if err != nil {
 			c.logger.WithError(err).Warn("Docker Bench scan failed")
 			// Add failed scan result with truncated error message
@@ -214,6 +269,24 @@ func (c *Integration) CollectWithOptions(ctx context.Context, options *models.Co
 	}, nil
 }
 
+// ExportCKL runs an OpenSCAP scan of a profile and writes the result to w as
+// a STIG Viewer checklist
+func (c *Integration) ExportCKL(ctx context.Context, profileID string, asset CKLAsset, w io.Writer) (*models.ComplianceScan, error) {
+	if c.openscap == nil || !c.openscap.IsAvailable() {
+		return nil, fmt.Errorf("OpenSCAP is not available")
+	}
+	var results bytes.Buffer
+	scan, err := c.openscap.RunScanWithResults(ctx, &models.ComplianceScanOptions{ProfileID: profileID}, &results)
+	if err != nil {
+		return nil, err
+	}
+	ckl, err := BuildCKL(results.Bytes(), asset)
+	if err != nil {
+		return nil, err
+	}
+	return scan, WriteCKL(w, ckl)
+}
+
 // UpgradeSSGContent upgrades the SCAP Security Guide content packages (legacy GitHub fallback).
 func (c *Integration) UpgradeSSGContent() error {
 	if c.openscap == nil {
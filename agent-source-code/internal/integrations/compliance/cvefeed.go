@@ -0,0 +1,497 @@
+package compliance
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner"
+	"patchmon-agent/internal/packages"
+	"patchmon-agent/internal/utils"
+)
+
+// DefaultCVEFeedURL is Red Hat's OVAL v2 directory. Mirrors use the same
+// layout: RHEL<major>/rhel-<major>.oval.xml.bz2.
+const DefaultCVEFeedURL = "https://security.access.redhat.com/data/oval/v2/"
+
+// DefaultCVEFeedMaxAge is how long a downloaded feed is used before the mirror
+// is asked for a newer one
+const DefaultCVEFeedMaxAge = 24 * time.Hour
+
+// cveFeedStateFile records where each cached feed came from and how old it is
+const cveFeedStateFile = "feeds.json"
+
+// CVEFeedSettings configures the local CVE feed cache used by oscap-docker
+// scans
+type CVEFeedSettings struct {
+	Dir    string        // cache directory; empty leaves downloads to oscap-docker
+	URL    string        // http(s):// or file:// mirror, or a local directory
+	MaxAge time.Duration // age at which the mirror is checked again
+}
+
+var (
+	cveFeedSettings atomic.Value // CVEFeedSettings
+	// cveFeedMu serialises feed downloads and the state file
+	cveFeedMu sync.Mutex
+)
+
+// SetCVEFeed configures the CVE feed cache
+func SetCVEFeed(settings CVEFeedSettings) {
+	cveFeedSettings.Store(settings)
+}
+
+func cveFeed() CVEFeedSettings {
+	settings, _ := cveFeedSettings.Load().(CVEFeedSettings)
+	if settings.URL == "" {
+		settings.URL = DefaultCVEFeedURL
+	}
+	if settings.MaxAge <= 0 {
+		settings.MaxAge = DefaultCVEFeedMaxAge
+	}
+	return settings
+}
+
+// cveFeedInfo is the cached copy of one feed
+type cveFeedInfo struct {
+	URL          string    `json:"url"`
+	LastModified time.Time `json:"last_modified"`
+	CheckedAt    time.Time `json:"checked_at"`
+}
+
+type cveFeedState struct {
+	Feeds map[string]cveFeedInfo `json:"feeds"` // file name -> info
+}
+
+func loadCVEFeedState(dir string) *cveFeedState {
+	state := &cveFeedState{Feeds: make(map[string]cveFeedInfo)}
+	data, err := os.ReadFile(filepath.Join(dir, cveFeedStateFile))
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(data, state); err != nil || state.Feeds == nil {
+		return &cveFeedState{Feeds: make(map[string]cveFeedInfo)}
+	}
+	return state
+}
+
+func (st *cveFeedState) save(dir string) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(filepath.Join(dir, cveFeedStateFile), data, 0600)
+}
+
+// CVEFeedUpdated returns when the newest cached feed was last changed
+// upstream, or zero if no feed is cached
+func CVEFeedUpdated() time.Time {
+	settings := cveFeed()
+	if settings.Dir == "" {
+		return time.Time{}
+	}
+	cveFeedMu.Lock()
+	defer cveFeedMu.Unlock()
+	var newest time.Time
+	for _, info := range loadCVEFeedState(settings.Dir).Feeds {
+		if info.LastModified.After(newest) {
+			newest = info.LastModified
+		}
+	}
+	return newest
+}
+
+// CVEFeedStatuses lists the cached feeds and their age
+func CVEFeedStatuses() []models.CVEFeedStatus {
+	settings := cveFeed()
+	if settings.Dir == "" {
+		return nil
+	}
+	cveFeedMu.Lock()
+	state := loadCVEFeedState(settings.Dir)
+	cveFeedMu.Unlock()
+	statuses := make([]models.CVEFeedStatus, 0, len(state.Feeds))
+	for name, info := range state.Feeds {
+		statuses = append(statuses, models.CVEFeedStatus{Name: name, LastModified: info.LastModified, CheckedAt: info.CheckedAt})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+func cveFeedName(major int) string {
+	return fmt.Sprintf("rhel-%d.oval.xml", major)
+}
+
+func cveFeedURL(base string, major int) string {
+	if strings.HasPrefix(base, "/") {
+		base = "file://" + base
+	}
+	return strings.TrimSuffix(base, "/") + "/" + CVEFeedPath(major)
+}
+
+// refreshCVEFeeds brings every cached feed up to date, so results cached
+// before a feed changed are recognised as stale
+func (s *OscapDockerScanner) refreshCVEFeeds(ctx context.Context) {
+	settings := cveFeed()
+	if settings.Dir == "" {
+		return
+	}
+	cveFeedMu.Lock()
+	state := loadCVEFeedState(settings.Dir)
+	cveFeedMu.Unlock()
+	for name := range state.Feeds {
+		var major int
+		if _, err := fmt.Sscanf(name, "rhel-%d.oval.xml", &major); err != nil {
+			continue
+		}
+		if _, _, err := s.ensureCVEFeed(ctx, major); err != nil {
+			s.logger.WithError(err).WithField("feed", name).Warn("Failed to refresh CVE feed")
+		}
+	}
+}
+
+// ensureCVEFeed returns the cached feed for a RHEL major version, downloading
+// it when missing or older than MaxAge. A feed that can't be refreshed is
+// still used, so scans keep working offline.
+func (s *OscapDockerScanner) ensureCVEFeed(ctx context.Context, major int) (string, time.Time, error) {
+	settings := cveFeed()
+	cveFeedMu.Lock()
+	defer cveFeedMu.Unlock()
+
+	if err := os.MkdirAll(settings.Dir, 0700); err != nil {
+		return "", time.Time{}, err
+	}
+	name := cveFeedName(major)
+	path := filepath.Join(settings.Dir, name)
+	url := cveFeedURL(settings.URL, major)
+	state := loadCVEFeedState(settings.Dir)
+	info, cached := state.Feeds[name]
+	if _, err := os.Stat(path); err != nil || info.URL != url {
+		cached = false
+	}
+	if cached && time.Since(info.CheckedAt) < settings.MaxAge {
+		return path, info.LastModified, nil
+	}
+
+	var since time.Time
+	if cached {
+		since = info.LastModified
+	}
+	lastModified, err := downloadCVEFeed(ctx, url, path, since)
+	if err != nil {
+		if cached {
+			s.logger.WithError(err).WithField("feed", name).Warn("Failed to check for a newer CVE feed, using the cached one")
+			return path, info.LastModified, nil
+		}
+		return "", time.Time{}, err
+	}
+	if lastModified.IsZero() {
+		lastModified = info.LastModified
+	} else {
+		s.logger.WithField("feed", name).Info("Downloaded CVE feed")
+	}
+	state.Feeds[name] = cveFeedInfo{URL: url, LastModified: lastModified, CheckedAt: time.Now()}
+	if err := state.save(settings.Dir); err != nil {
+		s.logger.WithError(err).Debug("Failed to save CVE feed state")
+	}
+	return path, lastModified, nil
+}
+
+// downloadCVEFeed fetches a bzip2-compressed feed into path, decompressed. It
+// returns the feed's Last-Modified time, or zero if the mirror reports it
+// unchanged since the given time.
+func downloadCVEFeed(ctx context.Context, url, path string, since time.Time) (time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !since.IsZero() {
+		req.Header.Set("If-Modified-Since", since.UTC().Format(http.TimeFormat))
+	}
+	resp, err := cveFeedClient().Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return time.Time{}, nil
+	case http.StatusOK:
+	default:
+		return time.Time{}, fmt.Errorf("HTTP error: %s", resp.Status)
+	}
+
+	if err := utils.WriteFileAtomicFrom(path, bzip2.NewReader(resp.Body), 0600); err != nil {
+		return time.Time{}, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil {
+		lastModified = time.Now()
+	}
+	return lastModified, nil
+}
+
+// cveFeedClient fetches feeds over http(s) and from file:// mirrors
+func cveFeedClient() *http.Client {
+	transport := &http.Transport{Proxy: packages.ProxyFunc, DialContext: utils.NewDialer(30 * time.Second).DialContext}
+	transport.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+	return &http.Client{Timeout: 15 * time.Minute, Transport: transport}
+}
+
+// CVEFeedPath is where the feed for a RHEL major version sits in a mirror
+func CVEFeedPath(major int) string {
+	return fmt.Sprintf("RHEL%d/rhel-%d.oval.xml.bz2", major, major)
+}
+
+// DownloadCVEFeedArchive copies the compressed feed for a RHEL major version
+// from the configured mirror to w unchanged, for a mirror kept elsewhere
+func DownloadCVEFeedArchive(ctx context.Context, major int, w io.Writer) error {
+	url := cveFeedURL(cveFeed().URL, major)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := cveFeedClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: HTTP error: %s", url, resp.Status)
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return nil
+}
+
+// rhelMajor returns the RHEL major version os-release describes, or 0 for
+// anything that isn't RHEL or a rebuild of it
+func rhelMajor(osRelease []byte) int {
+	var id, idLike, version string
+	scanner := bufio.NewScanner(bytes.NewReader(osRelease))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"'`)
+		switch key {
+		case "ID":
+			id = value
+		case "ID_LIKE":
+			idLike = value
+		case "VERSION_ID":
+			version = value
+		}
+	}
+	switch id {
+	case "rhel", "centos", "rocky", "almalinux", "ol":
+	default:
+		if !strings.Contains(" "+idLike+" ", " rhel ") {
+			return 0
+		}
+	}
+	major, _, _ := strings.Cut(version, ".")
+	n, err := strconv.Atoi(major)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// targetOSRelease reads /etc/os-release from an image or container without
+// running it
+func targetOSRelease(ctx context.Context, runner cmdrunner.Runner, kind, target string) ([]byte, error) {
+	source := target
+	if kind == "image" {
+		out, err := runner.Output(ctx, "docker", "create", target, "true")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create container from %s: %w", target, err)
+		}
+		id := strings.TrimSpace(string(out))
+		defer func() { _, _ = runner.Output(context.Background(), "docker", "rm", id) }()
+		source = id
+	}
+	out, err := runner.Output(ctx, "docker", "cp", "-L", source+":/etc/os-release", "-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read os-release: %w", err)
+	}
+	tr := tar.NewReader(bytes.NewReader(out))
+	if _, err := tr.Next(); err != nil {
+		return nil, fmt.Errorf("failed to read os-release: %w", err)
+	}
+	return io.ReadAll(io.LimitReader(tr, 64*1024))
+}
+
+// scanWithCVEFeed evaluates a RHEL-based image or container against the
+// cached feed with oscap-docker's generic image/container mode. It returns
+// nil without an error when the feed cache is off or the target isn't RHEL.
+func (s *OscapDockerScanner) scanWithCVEFeed(ctx context.Context, kind, target string) (*models.ComplianceScan, error) {
+	if cveFeed().Dir == "" {
+		return nil, nil
+	}
+	osRelease, err := targetOSRelease(ctx, s.runner, kind, target)
+	if err != nil {
+		return nil, err
+	}
+	major := rhelMajor(osRelease)
+	if major == 0 {
+		return nil, nil
+	}
+	feed, _, err := s.ensureCVEFeed(ctx, major)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := os.CreateTemp("", "patchmon-oval-results-*.xml")
+	if err != nil {
+		return nil, err
+	}
+	resultsPath := results.Name()
+	_ = results.Close()
+	defer func() { _ = os.Remove(resultsPath) }()
+
+	if output, err := cmdrunner.CombinedOutput(ctx, s.runner, oscapDockerBinary, kind, target, "oval", "eval", "--results", resultsPath, feed); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("oscap-docker %s oval eval failed: %w: %s", kind, err, truncateString(strings.TrimSpace(string(output)), 300))
+	}
+	f, err := os.Open(resultsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return parseOVALResults(f)
+}
+
+// ovalDefinition is the part of an OVAL patch definition a CVE result needs
+type ovalDefinition struct {
+	ID       string `xml:"id,attr"`
+	Class    string `xml:"class,attr"`
+	Metadata struct {
+		Title      string `xml:"title"`
+		References []struct {
+			RefID  string `xml:"ref_id,attr"`
+			Source string `xml:"source,attr"`
+		} `xml:"reference"`
+		Advisory struct {
+			Severity string `xml:"severity"`
+			CVEs     []struct {
+				ID     string `xml:",chardata"`
+				Impact string `xml:"impact,attr"`
+				CVSS3  string `xml:"cvss3,attr"`
+				CVSS2  string `xml:"cvss2,attr"`
+			} `xml:"cve"`
+		} `xml:"advisory"`
+	} `xml:"metadata"`
+}
+
+// parseOVALResults turns an oscap oval eval --results document into CVE
+// results: one failing result per CVE of every patch definition that
+// evaluated true
+func parseOVALResults(r io.Reader) (*models.ComplianceScan, error) {
+	defs := make(map[string]*ovalDefinition)
+	var affected []string
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse OVAL results: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "definition" {
+			continue
+		}
+		var definitionID, result string
+		for _, a := range start.Attr {
+			switch a.Name.Local {
+			case "definition_id":
+				definitionID = a.Value
+			case "result":
+				result = a.Value
+			}
+		}
+		if definitionID != "" {
+			// A result in results/system/definitions
+			if result == "true" {
+				affected = append(affected, definitionID)
+			}
+			continue
+		}
+		def := &ovalDefinition{}
+		if err := dec.DecodeElement(def, &start); err != nil {
+			return nil, fmt.Errorf("failed to parse OVAL definition: %w", err)
+		}
+		if def.Class == "patch" || def.Class == "vulnerability" {
+			defs[def.ID] = def
+		}
+	}
+
+	scan := &models.ComplianceScan{
+		ProfileType: "oscap-docker",
+		Results:     make([]models.ComplianceResult, 0),
+	}
+	seen := make(map[string]bool)
+	for _, id := range affected {
+		def, ok := defs[id]
+		if !ok {
+			continue
+		}
+		add := func(result models.ComplianceResult) {
+			if result.RuleID == "" || seen[result.RuleID] {
+				return
+			}
+			seen[result.RuleID] = true
+			result.Title = def.Metadata.Title
+			result.Status = "fail"
+			result.Section = "Container Vulnerabilities"
+			scan.Results = append(scan.Results, result)
+			scan.Failed++
+			scan.TotalRules++
+		}
+		for _, cve := range def.Metadata.Advisory.CVEs {
+			result := models.ComplianceResult{RuleID: strings.TrimSpace(cve.ID)}
+			score, vector, ok := parseCVSS(cve.CVSS3)
+			if !ok {
+				score, vector, ok = parseCVSS(cve.CVSS2)
+			}
+			switch {
+			case ok:
+				result.CVSSScore, result.CVSSVector = score, vector
+				result.Severity = cvssSeverity(score)
+			case cve.Impact != "":
+				result.Severity = normalizeCVESeverity(cve.Impact)
+			default:
+				result.Severity = normalizeCVESeverity(def.Metadata.Advisory.Severity)
+			}
+			add(result)
+		}
+		for _, ref := range def.Metadata.References {
+			if ref.Source == "CVE" {
+				add(models.ComplianceResult{RuleID: ref.RefID, Severity: normalizeCVESeverity(def.Metadata.Advisory.Severity)})
+			}
+		}
+	}
+	scoreImageScan(scan)
+	return scan, nil
+}
@@ -0,0 +1,118 @@
+package compliance
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testOVALResults = `<?xml version="1.0" encoding="UTF-8"?>
+<oval_results xmlns="http://oval.mitre.org/XMLSchema/oval-results-5">
+  <oval_definitions xmlns="http://oval.mitre.org/XMLSchema/oval-definitions-5">
+    <definitions>
+      <definition class="patch" id="oval:com.redhat.rhsa:def:20240001" version="1">
+        <metadata>
+          <title>RHSA-2024:0001: openssl security update (Important)</title>
+          <reference ref_id="RHSA-2024:0001" source="RHSA"/>
+          <reference ref_id="CVE-2023-0001" source="CVE"/>
+          <reference ref_id="CVE-2023-0002" source="CVE"/>
+          <advisory from="secalert@redhat.com">
+            <severity>Important</severity>
+            <cve impact="moderate">CVE-2023-0001</cve>
+            <cve cvss3="9.8/CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H">CVE-2023-0002</cve>
+          </advisory>
+        </metadata>
+        <criteria operator="AND"><criterion test_ref="oval:com.redhat.rhsa:tst:20240001001"/></criteria>
+      </definition>
+      <definition class="patch" id="oval:com.redhat.rhsa:def:20240002" version="1">
+        <metadata>
+          <title>RHSA-2024:0002: curl security update (Low)</title>
+          <reference ref_id="CVE-2023-0003" source="CVE"/>
+          <advisory><severity>Low</severity></advisory>
+        </metadata>
+      </definition>
+      <definition class="inventory" id="oval:com.redhat.rhba:def:1" version="1">
+        <metadata><title>Red Hat Enterprise Linux 9 is installed</title></metadata>
+      </definition>
+    </definitions>
+  </oval_definitions>
+  <results>
+    <system>
+      <definitions>
+        <definition definition_id="oval:com.redhat.rhsa:def:20240001" result="true" version="1"/>
+        <definition definition_id="oval:com.redhat.rhsa:def:20240002" result="false" version="1"/>
+        <definition definition_id="oval:com.redhat.rhba:def:1" result="true" version="1"/>
+      </definitions>
+    </system>
+  </results>
+</oval_results>`
+
+func TestParseOVALResults(t *testing.T) {
+	scan, err := parseOVALResults(strings.NewReader(testOVALResults))
+	require.NoError(t, err)
+
+	require.Len(t, scan.Results, 2, "only CVEs of patch definitions that evaluated true")
+	assert.Equal(t, "CVE-2023-0001", scan.Results[0].RuleID)
+	assert.Equal(t, "medium", scan.Results[0].Severity, "the CVE's own impact wins over the advisory's")
+	assert.Zero(t, scan.Results[0].CVSSScore)
+	assert.Equal(t, "CVE-2023-0002", scan.Results[1].RuleID)
+	assert.Equal(t, "critical", scan.Results[1].Severity, "a CVSS score decides the severity")
+	assert.Equal(t, 9.8, scan.Results[1].CVSSScore)
+	assert.Equal(t, "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", scan.Results[1].CVSSVector)
+	assert.Equal(t, "RHSA-2024:0001: openssl security update (Important)", scan.Results[1].Title)
+	assert.Equal(t, 2, scan.Failed)
+	// 9.8 costs 9.6 points, a medium CVE without a score 2.5
+	assert.Equal(t, 87.9, scan.Score)
+}
+
+func TestRHELMajor(t *testing.T) {
+	assert.Equal(t, 9, rhelMajor([]byte("NAME=\"Rocky Linux\"\nID=\"rocky\"\nID_LIKE=\"rhel centos fedora\"\nVERSION_ID=\"9.4\"\n")))
+	assert.Equal(t, 8, rhelMajor([]byte("ID=\"rhel\"\nVERSION_ID=\"8.10\"\n")))
+	assert.Equal(t, 9, rhelMajor([]byte("ID=\"eurolinux\"\nID_LIKE=\"rhel fedora centos\"\nVERSION_ID=\"9\"\n")))
+	assert.Equal(t, 0, rhelMajor([]byte("ID=fedora\nVERSION_ID=40\n")))
+	assert.Equal(t, 0, rhelMajor([]byte("ID=debian\nVERSION_ID=\"12\"\n")))
+}
+
+// testFeedBZ2 is "<oval_definitions/>\n", bzip2-compressed
+const testFeedBZ2 = "QlpoOTFBWSZTWZ+tGTgAAAFbgAAQAACABQAApyWNACAAIo2iDBqFMABNBSCDzzwbqzoqg+LuSKcKEhP1oycA"
+
+func TestEnsureCVEFeedFromDirectoryMirror(t *testing.T) {
+	mirror := t.TempDir()
+	data, err := base64.StdEncoding.DecodeString(testFeedBZ2)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(mirror, "RHEL9"), 0755))
+	feedFile := filepath.Join(mirror, "RHEL9", "rhel-9.oval.xml.bz2")
+	require.NoError(t, os.WriteFile(feedFile, data, 0644))
+	published := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(feedFile, published, published))
+
+	dir := t.TempDir()
+	SetCVEFeed(CVEFeedSettings{Dir: dir, URL: mirror})
+	defer SetCVEFeed(CVEFeedSettings{})
+	s := &OscapDockerScanner{logger: logrus.New()}
+
+	path, updated, err := s.ensureCVEFeed(context.Background(), 9)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "rhel-9.oval.xml"), path)
+	assert.True(t, published.Equal(updated), "the feed is dated by the mirror's Last-Modified")
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "<oval_definitions/>\n", string(content))
+	assert.True(t, published.Equal(CVEFeedUpdated()))
+
+	// With the mirror gone, a feed past MaxAge is still used
+	require.NoError(t, os.RemoveAll(mirror))
+	SetCVEFeed(CVEFeedSettings{Dir: dir, URL: mirror, MaxAge: time.Nanosecond})
+	path, updated, err = s.ensureCVEFeed(context.Background(), 9)
+	require.NoError(t, err)
+	assert.FileExists(t, path)
+	assert.True(t, published.Equal(updated))
+}
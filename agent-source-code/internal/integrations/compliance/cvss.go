@@ -0,0 +1,77 @@
+package compliance
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// cvssLinePattern finds a base score such as "CVSS: 7.5", "CVSSv3 9.8" or
+// "cvss3=5.3/CVSS:3.1/AV:N/..." in scanner output
+var cvssLinePattern = regexp.MustCompile(`(?i)cvss(?:v?[23](?:\.[01])?)?(?:\s*base)?(?:\s*score)?\s*[:=]?\s*"?(\d{1,2}(?:\.\d)?)\b`)
+
+// cvssVectorPattern matches a vector string, whose "CVSS:3.1" prefix would
+// otherwise read as a score
+var cvssVectorPattern = regexp.MustCompile(`(?i)cvss:\d\.\d(?:/[a-z]+:[a-z]+)+`)
+
+// parseCVSS splits a Red Hat OVAL cvss3/cvss2 attribute, "7.5/CVSS:3.1/AV:N/...",
+// into its base score and vector
+func parseCVSS(attr string) (float64, string, bool) {
+	scoreText, vector, _ := strings.Cut(strings.TrimSpace(attr), "/")
+	score, err := strconv.ParseFloat(scoreText, 64)
+	// Written so that NaN, which ParseFloat accepts, fails too
+	if err != nil || !(score >= 0 && score <= 10) {
+		return 0, "", false
+	}
+	return score, vector, true
+}
+
+// cvssFromLine returns the first plausible CVSS base score in a line of
+// scanner output
+func cvssFromLine(line string) (float64, bool) {
+	m := cvssLinePattern.FindStringSubmatch(cvssVectorPattern.ReplaceAllString(line, ""))
+	if m == nil {
+		return 0, false
+	}
+	score, err := strconv.ParseFloat(m[1], 64)
+	if err != nil || score > 10 {
+		return 0, false
+	}
+	return score, true
+}
+
+// cvssSeverity rates a base score with the CVSS v3 qualitative scale
+func cvssSeverity(score float64) string {
+	switch {
+	case score >= 9:
+		return "critical"
+	case score >= 7:
+		return "high"
+	case score >= 4:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// severityCVSS stands in for the score of a CVE that has only a severity
+func severityCVSS(severity string) float64 {
+	switch severity {
+	case "critical":
+		return 9.5
+	case "high":
+		return 7.5
+	case "low":
+		return 2.5
+	default:
+		return 5
+	}
+}
+
+// cvssPenalty is how many points a CVE takes off an image's score. Squaring
+// the base score keeps a handful of low findings from outweighing a single
+// critical one: 10.0 costs 10 points, 7.5 about 5.6, 5.0 2.5 and 2.0 0.4.
+func cvssPenalty(score float64) float64 {
+	return math.Round(score*score) / 10
+}
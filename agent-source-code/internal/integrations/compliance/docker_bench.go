@@ -2,38 +2,64 @@ package compliance
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner"
 	"patchmon-agent/internal/logutil"
-	"patchmon-agent/pkg/models"
 
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/moby/moby/client"
 	"github.com/sirupsen/logrus"
 )
 
 const (
 	dockerBinary = "docker"
-	// Docker Bench for Security image
 	// Using jauderho's maintained image - the official docker/docker-bench-security is deprecated
 	// and uses an ancient Docker client (API 1.38) incompatible with modern Docker daemons (API 1.44+)
-	dockerBenchImage = "jauderho/docker-bench-security:latest"
+	defaultDockerBenchRepo = "jauderho/docker-bench-security"
 )
 
+// defaultDockerBenchDigest pins the default image. Release builds set it with
+// -ldflags "-X patchmon-agent/internal/integrations/compliance.defaultDockerBenchDigest=sha256:..."
+// to the digest resolved when the release is cut, so every agent of a release
+// runs the same image and a new release moves it forward.
+var defaultDockerBenchDigest string
+
+// DefaultDockerBenchImage is used when docker_bench_image is not set
+var DefaultDockerBenchImage = defaultDockerBenchImage(defaultDockerBenchDigest)
+
+// defaultDockerBenchImage pins the default repository to digest. Without one
+// (development builds) it falls back to :latest, which the scan still runs by
+// the digest first pulled; see imageCommand.
+func defaultDockerBenchImage(digest string) string {
+	if !dockerDigestPattern.MatchString(digest) {
+		return defaultDockerBenchRepo + ":latest"
+	}
+	return defaultDockerBenchRepo + "@" + digest
+}
+
+var dockerDigestPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
 // DockerBenchScanner handles Docker Bench for Security scanning
 type DockerBenchScanner struct {
 	logger    *logrus.Logger
 	available bool
+	runner    cmdrunner.StreamRunner
 }
 
 // NewDockerBenchScanner creates a new Docker Bench scanner
 func NewDockerBenchScanner(logger *logrus.Logger) *DockerBenchScanner {
 	s := &DockerBenchScanner{
 		logger: logger,
+		runner: cmdrunner.Default,
 	}
 	s.checkAvailability()
 	return s
@@ -46,8 +72,9 @@ func (s *DockerBenchScanner) IsAvailable() bool {
 
 // checkAvailability checks if Docker is available for running Docker Bench
 func (s *DockerBenchScanner) checkAvailability() {
-	// Check if docker binary exists
-	_, err := exec.LookPath(dockerBinary)
+	// Check if docker binary exists; docker run and pull go through the CLI
+	// so they pick up its registry credentials
+	_, err := s.runner.LookPath(dockerBinary)
 	if err != nil {
 		s.logger.Debug("Docker binary not found")
 		s.available = false
@@ -55,9 +82,8 @@ func (s *DockerBenchScanner) checkAvailability() {
 	}
 
 	// Check if Docker daemon is running
-	cmd := exec.Command(dockerBinary, "info")
-	if err := cmd.Run(); err != nil {
-		s.logger.Debug("Docker daemon not responding")
+	if err := pingDocker(context.Background()); err != nil {
+		s.logger.WithError(err).Debug("Docker daemon not responding")
 		s.available = false
 		return
 	}
@@ -74,23 +100,95 @@ func (s *DockerBenchScanner) RunScan(ctx context.Context) (*models.ComplianceSca
 
 	startTime := time.Now()
 
-	s.logger.WithField("image", dockerBenchImage).Info("Pulling Docker Bench for Security image...")
+	var cmd benchCommand
+	var err error
+	if script := DockerBenchScript(); script != "" {
+		logDir, tmpErr := os.MkdirTemp("", "patchmon-docker-bench-")
+		if tmpErr != nil {
+			return nil, fmt.Errorf("failed to create log directory: %w", tmpErr)
+		}
+		defer func() { _ = os.RemoveAll(logDir) }()
+		cmd, err = s.scriptCommand(script, logDir)
+	} else {
+		cmd, err = s.imageCommand(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = s.runner.Run(ctx, &buf, cmd.dir, cmd.name, cmd.args...)
+	output := buf.Bytes()
 
-	// Pull the latest Docker Bench image
-	pullCmd := exec.CommandContext(ctx, dockerBinary, "pull", dockerBenchImage)
-	if output, err := pullCmd.CombinedOutput(); err != nil {
-		s.logger.WithError(err).WithField("output", string(output)).Warn("Failed to pull Docker Bench image, attempting to use existing image")
+	outputStr := string(output)
+	outputLen := len(outputStr)
 
-		// Check if image exists locally
-		checkCmd := exec.CommandContext(ctx, dockerBinary, "images", "-q", dockerBenchImage)
-		checkOutput, checkErr := checkCmd.Output()
-		if checkErr != nil || strings.TrimSpace(string(checkOutput)) == "" {
-			return nil, fmt.Errorf("docker bench image not available and pull failed: %w", err)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("scan cancelled: %w", ctx.Err())
 		}
-		s.logger.Info("Using existing Docker Bench image")
+		// Docker Bench may exit non-zero on failures, parse output anyway
+		s.logger.WithError(err).WithField("output_length", outputLen).Debug("Docker Bench exited with error, parsing output")
+	}
+
+	// Log output for debugging if it's short (likely an error)
+	if outputLen == 0 {
+		s.logger.Warn("Docker Bench produced no output - container may have failed to start")
+	} else if outputLen < 500 {
+		s.logger.WithField("output", logutil.Sanitize(outputStr)).Debug("Docker Bench produced short output")
 	} else {
+		s.logger.WithField("output_length", outputLen).Debug("Docker Bench output captured")
+	}
+
+	// Parse the output
+	scan := s.parseOutput(outputStr)
+	scan.StartedAt = startTime
+	now := time.Now()
+	scan.CompletedAt = &now
+	scan.Status = "completed"
+
+	// Log warning if no results were parsed
+	if scan.TotalRules == 0 && outputLen > 0 {
+		// Log first 500 chars to help debug parsing issues
+		preview := outputStr
+		if len(preview) > 500 {
+			preview = preview[:500] + "..."
+		}
+		s.logger.WithField("output_preview", logutil.Sanitize(preview)).Warn("Docker Bench output received but no rules parsed - check output format")
+	}
+
+	return scan, nil
+}
+
+// benchCommand is how Docker Bench is run: the program, its arguments and the
+// directory to run it in
+type benchCommand struct {
+	dir  string
+	name string
+	args []string
+}
+
+// imageCommand prepares a Docker Bench run from the container image, pulling
+// it first unless auto_install_tools is off
+func (s *DockerBenchScanner) imageCommand(ctx context.Context) (benchCommand, error) {
+	// A tag is only pulled when the image is missing, and the scan runs by the
+	// digest that was pulled. A tag like :latest therefore stays on the content
+	// it first resolved to until the image is changed or removed.
+	image := DockerBenchImage()
+	switch {
+	case s.imagePresent(ctx, image):
+		s.logger.WithField("image", image).Debug("Docker Bench image already present")
+	case !AutoInstallTools():
+		return benchCommand{}, s.missingImage(image)
+	default:
+		s.logger.WithField("image", image).Info("Pulling Docker Bench for Security image...")
+		if output, err := cmdrunner.CombinedOutput(ctx, s.runner, dockerBinary, "pull", image); err != nil {
+			s.logger.WithError(err).WithField("output", logutil.Sanitize(string(output))).Warn("Failed to pull Docker Bench image")
+			return benchCommand{}, fmt.Errorf("docker bench image not available and pull failed: %w", err)
+		}
 		s.logger.Info("Docker Bench image pulled successfully")
 	}
+	runRef := s.pinnedRef(ctx, image)
+	s.logger.WithField("image", runRef).Info("Using Docker Bench image")
 
 	// Run Docker Bench
 	// NOTE: These elevated privileges are necessary for Docker Bench to inspect host configuration.
@@ -110,18 +208,18 @@ func (s *DockerBenchScanner) RunScan(ctx context.Context) (*models.ComplianceSca
 		"/docker.sock", // Sometimes mounted here in containers
 	}
 
-	// Check DOCKER_HOST environment variable first
-	if dockerHost := os.Getenv("DOCKER_HOST"); dockerHost != "" {
-		if strings.HasPrefix(dockerHost, "unix://") {
-			socketPath := strings.TrimPrefix(dockerHost, "unix://")
+	// Prefer the socket the Docker client resolves (DOCKER_HOST or the default)
+	if cli, err := dockerAPI(); err == nil {
+		if socketPath := daemonSocket(cli.DaemonHost()); socketPath != "" {
 			if _, err := os.Stat(socketPath); err == nil {
 				dockerSocket = socketPath
-				s.logger.WithField("socket", dockerSocket).Debug("Using Docker socket from DOCKER_HOST")
+				s.logger.WithField("socket", dockerSocket).Debug("Using Docker socket from the Docker client")
 			}
 		}
+		_ = cli.Close()
 	}
 
-	// If not found via env, check common paths
+	// If the client's host isn't a local socket, check common paths
 	if dockerSocket == "" {
 		for _, path := range socketPaths {
 			if _, err := os.Stat(path); err == nil {
@@ -133,13 +231,13 @@ func (s *DockerBenchScanner) RunScan(ctx context.Context) (*models.ComplianceSca
 	}
 
 	if dockerSocket == "" {
-		return nil, fmt.Errorf("docker socket not found at any known location")
+		return benchCommand{}, fmt.Errorf("docker socket not found at any known location")
 	}
 
 	// Verify socket is accessible
 	socketInfo, err := os.Stat(dockerSocket)
 	if err != nil {
-		return nil, fmt.Errorf("docker socket not accessible: %w", err)
+		return benchCommand{}, fmt.Errorf("docker socket not accessible: %w", err)
 	}
 	s.logger.WithFields(logrus.Fields{
 		"socket": dockerSocket,
@@ -176,51 +274,26 @@ func (s *DockerBenchScanner) RunScan(ctx context.Context) (*models.ComplianceSca
 	}
 
 	// -b: disable colors, -p: print remediation measures
-	args = append(args, "--label", "docker_bench_security", dockerBenchImage, "-b", "-p")
+	args = append(args, "--label", "docker_bench_security", runRef, "-b", "-p")
 
 	s.logger.WithField("command", "docker "+strings.Join(args, " ")).Info("Running Docker Bench for Security...")
 
-	cmd := exec.CommandContext(ctx, dockerBinary, args...)
-	output, err := cmd.CombinedOutput()
-
-	outputStr := string(output)
-	outputLen := len(outputStr)
-
-	if err != nil {
-		if ctx.Err() != nil {
-			return nil, fmt.Errorf("scan cancelled: %w", ctx.Err())
-		}
-		// Docker Bench may exit non-zero on failures, parse output anyway
-		s.logger.WithError(err).WithField("output_length", outputLen).Debug("Docker Bench exited with error, parsing output")
-	}
+	return benchCommand{name: dockerBinary, args: args}, nil
+}
 
-	// Log output for debugging if it's short (likely an error)
-	if outputLen == 0 {
-		s.logger.Warn("Docker Bench produced no output - container may have failed to start")
-	} else if outputLen < 500 {
-		s.logger.WithField("output", logutil.Sanitize(outputStr)).Debug("Docker Bench produced short output")
-	} else {
-		s.logger.WithField("output_length", outputLen).Debug("Docker Bench output captured")
+// scriptCommand prepares a run of a locally installed docker-bench-security.sh.
+// The script sources its tests relative to its own directory; its log goes to
+// logDir so the install location can stay read-only.
+func (s *DockerBenchScanner) scriptCommand(script, logDir string) (benchCommand, error) {
+	if _, err := os.Stat(script); err != nil {
+		return benchCommand{}, fmt.Errorf("docker_bench_script not usable: %w", err)
 	}
 
-	// Parse the output
-	scan := s.parseOutput(outputStr)
-	scan.StartedAt = startTime
-	now := time.Now()
-	scan.CompletedAt = &now
-	scan.Status = "completed"
-
-	// Log warning if no results were parsed
-	if scan.TotalRules == 0 && outputLen > 0 {
-		// Log first 500 chars to help debug parsing issues
-		preview := outputStr
-		if len(preview) > 500 {
-			preview = preview[:500] + "..."
-		}
-		s.logger.WithField("output_preview", logutil.Sanitize(preview)).Warn("Docker Bench output received but no rules parsed - check output format")
-	}
+	// -b: disable colors, -p: print remediation measures
+	args := []string{"-b", "-p", "-l", filepath.Join(logDir, "docker-bench-security.log")}
+	s.logger.WithField("script", script).Info("Running Docker Bench for Security from local script...")
 
-	return scan, nil
+	return benchCommand{dir: filepath.Dir(script), name: script, args: args}, nil
 }
 
 // parseOutput parses Docker Bench output
@@ -435,7 +508,8 @@ func (s *DockerBenchScanner) getSectionFromID(ruleID string, currentSection stri
 	return currentSection
 }
 
-// EnsureInstalled pre-pulls the Docker Bench image if Docker is available
+// EnsureInstalled pre-pulls the Docker Bench image if Docker is available, or
+// checks the local script when docker_bench_script is set
 func (s *DockerBenchScanner) EnsureInstalled() error {
 	// Re-check availability
 	s.checkAvailability()
@@ -444,13 +518,29 @@ func (s *DockerBenchScanner) EnsureInstalled() error {
 		return fmt.Errorf("docker is not available - Docker Bench requires Docker to run")
 	}
 
-	s.logger.Info("Pre-pulling Docker Bench for Security image...")
+	if script := DockerBenchScript(); script != "" {
+		if _, err := os.Stat(script); err != nil {
+			return fmt.Errorf("docker_bench_script not usable: %w", err)
+		}
+		s.logger.WithField("script", script).Debug("Using local Docker Bench script, no image to pull")
+		return nil
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
-	pullCmd := exec.CommandContext(ctx, dockerBinary, "pull", dockerBenchImage)
-	output, err := pullCmd.CombinedOutput()
+	image := DockerBenchImage()
+	if !AutoInstallTools() {
+		if !s.imagePresent(ctx, image) {
+			return s.missingImage(image)
+		}
+		s.logger.Debug("Docker Bench image present; not pulling (auto_install_tools is off)")
+		return nil
+	}
+
+	s.logger.WithField("image", image).Info("Pre-pulling Docker Bench for Security image...")
+
+	output, err := cmdrunner.CombinedOutput(ctx, s.runner, dockerBinary, "pull", image)
 	if err != nil {
 		s.logger.WithError(err).WithField("output", string(output)).Warn("Failed to pull Docker Bench image")
 		return fmt.Errorf("failed to pull Docker Bench image: %w", err)
@@ -460,28 +550,117 @@ func (s *DockerBenchScanner) EnsureInstalled() error {
 	return nil
 }
 
+// imagePresent reports whether image is already on the host
+func (s *DockerBenchScanner) imagePresent(ctx context.Context, image string) bool {
+	_, err := inspectDockerImage(ctx, image)
+	return err == nil
+}
+
+// ImageDigest returns the local Docker Bench image as repo@sha256:digest, or
+// "" when Docker Bench runs from a script or the image isn't pulled
+func (s *DockerBenchScanner) ImageDigest(ctx context.Context) string {
+	if !s.available || DockerBenchScript() != "" {
+		return ""
+	}
+	image := DockerBenchImage()
+	if !s.imagePresent(ctx, image) {
+		return ""
+	}
+	if ref := s.pinnedRef(ctx, image); strings.Contains(ref, "@sha256:") {
+		return ref
+	}
+	return ""
+}
+
+// pinnedRef returns image as repo@sha256:digest so the scan runs exactly the
+// content that was pulled, even if the tag is re-pointed locally. Falls back to
+// image when no digest is known (e.g. a locally built image).
+func (s *DockerBenchScanner) pinnedRef(ctx context.Context, image string) string {
+	if strings.Contains(image, "@sha256:") {
+		return image
+	}
+	info, err := inspectDockerImage(ctx, image)
+	if err != nil {
+		return image
+	}
+	return pickRepoDigest(image, info.RepoDigests)
+}
+
+// pickRepoDigest returns the repo digest belonging to image's repository
+func pickRepoDigest(image string, digests []string) string {
+	repo := imageRepository(image)
+	for _, d := range digests {
+		d = strings.TrimSpace(d)
+		if name, _, ok := strings.Cut(d, "@"); ok && name == repo {
+			return d
+		}
+	}
+	return image
+}
+
+// imageRepository strips the tag and digest from an image reference. A colon
+// before the last slash belongs to a registry port, not a tag.
+func imageRepository(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
+// SaveDockerImage writes image to path with docker save, returning docker's
+// output on failure
+func SaveDockerImage(ctx context.Context, image, path string) (string, error) {
+	output, err := cmdrunner.CombinedOutput(ctx, cmdrunner.Default, dockerBinary, "save", "-o", path, image)
+	return logutil.Sanitize(string(output)), err
+}
+
+// LoadDockerImage loads an image written by SaveDockerImage
+func LoadDockerImage(ctx context.Context, path string) (string, error) {
+	output, err := cmdrunner.CombinedOutput(ctx, cmdrunner.Default, dockerBinary, "load", "-i", path)
+	return logutil.Sanitize(string(output)), err
+}
+
+func (s *DockerBenchScanner) missingImage(image string) error {
+	return &ToolsMissingError{
+		Tool:     "docker-bench",
+		Packages: []string{image},
+		Command:  "docker pull " + image,
+	}
+}
+
 // Cleanup removes the Docker Bench image to free up space
 func (s *DockerBenchScanner) Cleanup() error {
 	if !s.available {
 		s.logger.Debug("Docker not available, nothing to clean up")
 		return nil
 	}
+	if DockerBenchScript() != "" {
+		s.logger.Debug("Docker Bench runs from a local script, no image to remove")
+		return nil
+	}
 
 	s.logger.Info("Removing Docker Bench for Security image...")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	// Remove the image
-	removeCmd := exec.CommandContext(ctx, dockerBinary, "rmi", dockerBenchImage)
-	output, err := removeCmd.CombinedOutput()
+	cli, err := dockerAPI()
 	if err != nil {
+		return err
+	}
+	defer func() { _ = cli.Close() }()
+
+	// Remove the image
+	if _, err := cli.ImageRemove(ctx, DockerBenchImage(), client.ImageRemoveOptions{}); err != nil {
 		// Image might not exist, which is fine
-		if strings.Contains(string(output), "No such image") {
+		if cerrdefs.IsNotFound(err) {
 			s.logger.Debug("Docker Bench image already removed")
 			return nil
 		}
-		s.logger.WithError(err).WithField("output", string(output)).Warn("Failed to remove Docker Bench image")
+		s.logger.WithError(err).Warn("Failed to remove Docker Bench image")
 		return fmt.Errorf("failed to remove Docker Bench image: %w", err)
 	}
 
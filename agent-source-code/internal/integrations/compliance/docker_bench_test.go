@@ -0,0 +1,142 @@
+package compliance
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageRepository(t *testing.T) {
+	for image, want := range map[string]string{
+		"jauderho/docker-bench-security":                                        "jauderho/docker-bench-security",
+		"jauderho/docker-bench-security:latest":                                 "jauderho/docker-bench-security",
+		"jauderho/docker-bench-security@sha256:" + strings.Repeat("ab", 32):     "jauderho/docker-bench-security",
+		"jauderho/docker-bench-security:1.6@sha256:" + strings.Repeat("ab", 32): "jauderho/docker-bench-security",
+		"registry.internal:5000/security/docker-bench":                          "registry.internal:5000/security/docker-bench",
+		"registry.internal:5000/security/docker-bench:1.6.1":                    "registry.internal:5000/security/docker-bench",
+	} {
+		assert.Equal(t, want, imageRepository(image), image)
+	}
+}
+
+func TestPickRepoDigest(t *testing.T) {
+	ours := "jauderho/docker-bench-security@sha256:" + strings.Repeat("ab", 32)
+	mirror := "registry.internal:5000/docker-bench-security@sha256:" + strings.Repeat("cd", 32)
+
+	assert.Equal(t, ours, pickRepoDigest("jauderho/docker-bench-security:latest", []string{mirror, " " + ours}))
+	assert.Equal(t, mirror, pickRepoDigest("registry.internal:5000/docker-bench-security:1.6", []string{ours, mirror}))
+	// A digest of another repository never stands in for the image
+	assert.Equal(t, "jauderho/docker-bench-security:latest", pickRepoDigest("jauderho/docker-bench-security:latest", []string{mirror}))
+	assert.Equal(t, "local/bench:dev", pickRepoDigest("local/bench:dev", nil))
+}
+
+func TestPinnedRefKeepsDigestReferences(t *testing.T) {
+	// A digest reference is run as given, without asking Docker
+	pinned := "jauderho/docker-bench-security@sha256:" + strings.Repeat("ab", 32)
+	s := &DockerBenchScanner{}
+	assert.Equal(t, pinned, s.pinnedRef(context.Background(), pinned))
+}
+
+func TestDefaultDockerBenchImage(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("ab", 32)
+	assert.Equal(t, "jauderho/docker-bench-security@"+digest, defaultDockerBenchImage(digest))
+	assert.Equal(t, "jauderho/docker-bench-security:latest", defaultDockerBenchImage(""))
+	assert.Equal(t, "jauderho/docker-bench-security:latest", defaultDockerBenchImage("sha256:short"))
+
+	defer SetDockerBenchImage("")
+	SetDockerBenchImage("registry.internal/bench@" + digest)
+	assert.Equal(t, "registry.internal/bench@"+digest, DockerBenchImage())
+	SetDockerBenchImage("")
+	assert.Equal(t, DefaultDockerBenchImage, DockerBenchImage())
+}
+
+// benchOutput is the Docker Bench output recorded in testdata/docker-bench
+func benchOutput(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "docker-bench", "docker-bench-1.6.0.txt"))
+	require.NoError(t, err)
+	f, err := cmdrunner.ParseFixture(data)
+	require.NoError(t, err)
+	return f.Stdout
+}
+
+func checkBenchScan(t *testing.T, scan *models.ComplianceScan) {
+	t.Helper()
+	assert.Equal(t, 9, scan.TotalRules)
+	assert.Equal(t, 3, scan.Passed)
+	assert.Equal(t, 3, scan.Warnings)
+	assert.Equal(t, 3, scan.Skipped)
+	assert.InDelta(t, 50.0, scan.Score, 0.01)
+
+	results := make(map[string]models.ComplianceResult)
+	for _, r := range scan.Results {
+		results[r.RuleID] = r
+	}
+	partition := results["1.1.1"]
+	assert.Equal(t, "warn", partition.Status)
+	assert.Equal(t, "Host Configuration", partition.Section)
+	// Remediation continues over the indented lines that follow it
+	assert.True(t, strings.HasPrefix(partition.Remediation, "For new installations"))
+	assert.True(t, strings.HasSuffix(partition.Remediation, "create a new partition."))
+
+	assert.Equal(t, "skip", results["2.1"].Status)
+	assert.Equal(t, "Docker Daemon Configuration", results["2.2"].Section)
+	assert.Equal(t, "Running in privileged mode: web-01; Running in privileged mode: cadvisor", results["5.5"].Finding)
+	assert.Equal(t, "pass", results["5.6"].Status)
+}
+
+func TestParseOutputFixture(t *testing.T) {
+	s := &DockerBenchScanner{logger: logrus.New()}
+	s.logger.SetOutput(io.Discard)
+	checkBenchScan(t, s.parseOutput(string(benchOutput(t))))
+}
+
+// scriptRunner plays back Docker Bench output for any command, remembering the
+// one it was asked to run
+type scriptRunner struct {
+	output    []byte
+	dir, name string
+}
+
+func (r *scriptRunner) Output(context.Context, string, ...string) ([]byte, error) {
+	return r.output, nil
+}
+
+func (r *scriptRunner) Run(_ context.Context, w io.Writer, dir, name string, _ ...string) error {
+	r.dir, r.name = dir, name
+	_, _ = io.Copy(w, bytes.NewReader(r.output))
+	// Docker Bench exits non-zero when checks warn
+	return &cmdrunner.ExitError{Code: 1}
+}
+
+func (r *scriptRunner) LookPath(name string) (string, error) { return "/usr/bin/" + name, nil }
+
+func TestRunScanWithScript(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "docker-bench-security.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\n"), 0o755))
+	SetDockerBenchScript(script)
+	defer SetDockerBenchScript("")
+
+	runner := &scriptRunner{output: benchOutput(t)}
+	s := &DockerBenchScanner{logger: logrus.New(), available: true, runner: runner}
+	s.logger.SetOutput(io.Discard)
+
+	scan, err := s.RunScan(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, script, runner.name)
+	// The script sources its checks relative to its own directory
+	assert.Equal(t, filepath.Dir(script), runner.dir)
+	assert.Equal(t, "completed", scan.Status)
+	checkBenchScan(t, scan)
+}
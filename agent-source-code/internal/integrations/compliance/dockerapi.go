@@ -0,0 +1,85 @@
+package compliance
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/moby/moby/api/types/image"
+	"github.com/moby/moby/client"
+)
+
+// dockerPingTimeout bounds the daemon check done when a scanner is created
+const dockerPingTimeout = 10 * time.Second
+
+// dockerAPI opens a client for the daemon the docker CLI would talk to, from
+// DOCKER_HOST or the default socket. The caller closes it.
+func dockerAPI() (*client.Client, error) {
+	cli, err := client.New(client.FromEnv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	return cli, nil
+}
+
+// pingDocker reports why the Docker daemon can't be reached, or nil when it
+// answers
+func pingDocker(ctx context.Context) error {
+	cli, err := dockerAPI()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = cli.Close() }()
+
+	ctx, cancel := context.WithTimeout(ctx, dockerPingTimeout)
+	defer cancel()
+	if _, err := cli.Ping(ctx, client.PingOptions{}); err != nil {
+		return fmt.Errorf("docker daemon at %s not responding: %w", cli.DaemonHost(), err)
+	}
+	return nil
+}
+
+// inspectDockerImage returns the local image's metadata
+func inspectDockerImage(ctx context.Context, ref string) (image.InspectResponse, error) {
+	cli, err := dockerAPI()
+	if err != nil {
+		return image.InspectResponse{}, err
+	}
+	defer func() { _ = cli.Close() }()
+
+	res, err := cli.ImageInspect(ctx, ref)
+	if err != nil {
+		return image.InspectResponse{}, err
+	}
+	return res.InspectResponse, nil
+}
+
+// daemonSocket returns the unix socket path in a DOCKER_HOST style address,
+// or "" for other transports
+func daemonSocket(host string) string {
+	path, ok := strings.CutPrefix(host, "unix://")
+	if !ok {
+		return ""
+	}
+	return path
+}
+
+// taggedImages returns the tagged local images, once per image ID
+func taggedImages(summaries []image.Summary) []dockerImage {
+	var images []dockerImage
+	seen := make(map[string]bool)
+	for _, s := range summaries {
+		if seen[s.ID] {
+			continue
+		}
+		for _, tag := range s.RepoTags {
+			if tag != "" && tag != "<none>:<none>" {
+				seen[s.ID] = true
+				images = append(images, dockerImage{id: s.ID, ref: tag})
+				break
+			}
+		}
+	}
+	return images
+}
@@ -0,0 +1,141 @@
+package compliance
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// The fuzz targets feed scanner output through the parsers. Every result must
+// name its rule and carry a status the server knows, and any CVSS score must
+// be on the 0-10 scale.
+
+var fuzzStatuses = map[string]bool{
+	"pass": true, "fail": true, "warn": true, "skip": true,
+	"notapplicable": true, "error": true, "waived": true,
+}
+
+func fuzzLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	return logger
+}
+
+func checkFuzzedScan(t *testing.T, scan *models.ComplianceScan) {
+	t.Helper()
+	if scan == nil {
+		return
+	}
+	for _, r := range scan.Results {
+		require.NotEmpty(t, r.RuleID, "result without a rule: %+v", r)
+		require.True(t, fuzzStatuses[r.Status], "unknown status %q", r.Status)
+		require.True(t, r.CVSSScore >= 0 && r.CVSSScore <= 10, "CVSS score %v", r.CVSSScore)
+	}
+}
+
+func FuzzOVALResults(f *testing.F) {
+	f.Add(testOVALResults)
+	f.Add(`<oval_results><results><system><definitions><definition definition_id="x" result="true"/></definitions></system></results></oval_results>`)
+	f.Fuzz(func(t *testing.T, doc string) {
+		scan, err := parseOVALResults(strings.NewReader(doc))
+		if err == nil {
+			checkFuzzedScan(t, scan)
+		}
+	})
+}
+
+func FuzzParseCVSS(f *testing.F) {
+	f.Add("9.8/CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H")
+	f.Add("NaN/")
+	f.Add("-0")
+	f.Fuzz(func(t *testing.T, attr string) {
+		if score, _, ok := parseCVSS(attr); ok {
+			require.True(t, score >= 0 && score <= 10, "score %v from %q", score, attr)
+		}
+	})
+}
+
+func FuzzCVSSFromLine(f *testing.F) {
+	f.Add("CVE-2024-0001 - High - CVSS: 7.5 - CVSS:3.1/AV:N/AC:L")
+	f.Add("score 10.0 of 10")
+	f.Fuzz(func(t *testing.T, line string) {
+		if score, ok := cvssFromLine(line); ok {
+			require.True(t, score >= 0 && score <= 10, "score %v from %q", score, line)
+		}
+	})
+}
+
+func FuzzDockerBenchOutput(f *testing.F) {
+	f.Add(`[INFO] 1 - Host Configuration
+[PASS] 1.1.1 - Ensure a separate partition for containers has been created
+[WARN] 1.1.2 - Ensure only trusted users are allowed to control Docker daemon
+[WARN]      * Users: alice
+        * Remediation: Remove untrusted users from the docker group
+          and restart the daemon
+[NOTE] 4.5 - Ensure Content trust for Docker is Enabled
+`)
+	f.Add("[PASS] 1.1 - \n[WARN] 2.2 - x\n  * \n")
+	s := &DockerBenchScanner{logger: fuzzLogger()}
+	f.Fuzz(func(t *testing.T, output string) {
+		checkFuzzedScan(t, s.parseOutput(output))
+	})
+}
+
+func FuzzImageCveOutput(f *testing.F) {
+	f.Add("CVE-2024-0001 - Low - CVSS: 9.1 - libfoo\nCVE-2021-44228 - Critical - log4j\n")
+	f.Add("CVE-9999-1 CVSS:3.1/AV:N 11.0\n")
+	s := &OscapDockerScanner{logger: fuzzLogger()}
+	f.Fuzz(func(t *testing.T, output string) {
+		checkFuzzedScan(t, s.parseImageCveOutput(output, "nginx:1.27"))
+		checkFuzzedScan(t, s.parseContainerCveOutput(output, "web"))
+	})
+}
+
+func FuzzOscapOutput(f *testing.F) {
+	f.Add("Title\tEnsure /tmp is a separate partition\nRule\txccdf_org.ssgproject.content_rule_partition_for_tmp\nResult\tfail\n/tmp: not mounted\n")
+	f.Add("x\txccdf_org.ssgproject.content_rule_\tfail\tdetail\n")
+	s := &OpenSCAPScanner{logger: fuzzLogger()}
+	f.Fuzz(func(t *testing.T, output string) {
+		for rule := range s.parseOscapOutput(output) {
+			require.True(t, strings.HasPrefix(rule, "xccdf_org.ssgproject.content_rule_"), "rule %q", rule)
+		}
+	})
+}
+
+func FuzzActualExpected(f *testing.F) {
+	f.Add("expected '0600' but found '0644'")
+	f.Add("PermitRootLogin is set to yes, should be no")
+	f.Add("value=")
+	s := &OpenSCAPScanner{logger: fuzzLogger()}
+	f.Fuzz(func(t *testing.T, finding string) {
+		actual, expected := s.parseActualExpected(finding, "")
+		require.LessOrEqual(t, len(actual), len(finding))
+		require.LessOrEqual(t, len(expected), len(finding))
+	})
+}
+
+func FuzzRuleMetadata(f *testing.F) {
+	f.Add(`<xccdf-1.2:Rule id="xccdf_org.ssgproject.content_rule_a" severity="high"><xccdf-1.2:title>A</xccdf-1.2:title><xccdf-1.2:description>d</xccdf-1.2:description></xccdf-1.2:Rule>`)
+	f.Add(`<Rule id="x"><Rule id="y">`)
+	s := &OpenSCAPScanner{logger: fuzzLogger()}
+	f.Fuzz(func(t *testing.T, content string) {
+		for id := range s.extractRuleMetadata(content) {
+			require.NotEmpty(t, id)
+		}
+	})
+}
+
+func FuzzProfileRuleCount(f *testing.F) {
+	f.Add(testDatastream, "xccdf_test_profile_server")
+	f.Add("<Benchmark><Profile", "x")
+	f.Fuzz(func(t *testing.T, doc, profile string) {
+		n, err := profileRuleCount(strings.NewReader(doc), profile)
+		if err == nil {
+			require.GreaterOrEqual(t, n, 0)
+		}
+	})
+}
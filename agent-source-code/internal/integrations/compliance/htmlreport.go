@@ -0,0 +1,114 @@
+package compliance
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"patchmon-agent/internal/cmdrunner"
+)
+
+// maxHTMLReports bounds how many HTML reports are kept on disk
+const maxHTMLReports = 20
+
+// ssgProfilePrefix is left out of report names
+const ssgProfilePrefix = "xccdf_org.ssgproject.content_profile_"
+
+var htmlReportDir atomic.Value // string
+
+var (
+	reportIDPattern  = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	reportNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+)
+
+// SetHTMLReportDir sets where OpenSCAP scans store their HTML report. Empty
+// turns report generation off.
+func SetHTMLReportDir(dir string) {
+	htmlReportDir.Store(dir)
+}
+
+// HTMLReportDir returns where HTML reports are stored, or "" if they aren't
+func HTMLReportDir() string {
+	dir, _ := htmlReportDir.Load().(string)
+	return dir
+}
+
+// HTMLReportPath returns the file of a stored report
+func HTMLReportPath(id string) (string, error) {
+	dir := HTMLReportDir()
+	if dir == "" {
+		return "", fmt.Errorf("HTML reports are not enabled")
+	}
+	if !reportIDPattern.MatchString(id) {
+		return "", fmt.Errorf("invalid report ID %q", id)
+	}
+	return filepath.Join(dir, id+".html"), nil
+}
+
+// htmlReportID names a report after its profile and when it was generated
+func htmlReportID(profileName string, at time.Time) string {
+	name := reportNameUnsafe.ReplaceAllString(strings.TrimPrefix(profileName, ssgProfilePrefix), "_")
+	if name == "" {
+		name = "scan"
+	}
+	return name + "-" + at.UTC().Format("20060102T150405Z")
+}
+
+// generateHTMLReport renders XCCDF results with oscap xccdf generate report
+// and returns the stored report's ID
+func (s *OpenSCAPScanner) generateHTMLReport(ctx context.Context, resultsPath, profileName string) (string, error) {
+	dir := HTMLReportDir()
+	if dir == "" {
+		return "", nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	id := htmlReportID(profileName, time.Now())
+	path, err := HTMLReportPath(id)
+	if err != nil {
+		return "", err
+	}
+	if output, err := cmdrunner.CombinedOutput(ctx, s.runner, oscapBinary, "xccdf", "generate", "report", "--output", path, resultsPath); err != nil {
+		_ = os.Remove(path)
+		return "", fmt.Errorf("oscap xccdf generate report failed: %w: %s", err, truncateString(strings.TrimSpace(string(output)), 300))
+	}
+	pruneHTMLReports(dir, maxHTMLReports)
+	return id, nil
+}
+
+// pruneHTMLReports removes all but the newest keep reports
+func pruneHTMLReports(dir string, keep int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	type report struct {
+		path    string
+		modTime time.Time
+	}
+	var reports []report
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".html" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		reports = append(reports, report{filepath.Join(dir, e.Name()), info.ModTime()})
+	}
+	if len(reports) <= keep {
+		return
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].modTime.After(reports[j].modTime) })
+	for _, r := range reports[keep:] {
+		_ = os.Remove(r.path)
+	}
+}
@@ -0,0 +1,54 @@
+package compliance
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTMLReportID(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	assert.Equal(t, "cis_level1_server-20260301T123000Z", htmlReportID("xccdf_org.ssgproject.content_profile_cis_level1_server", at))
+	assert.Equal(t, "level1_server-20260301T123000Z", htmlReportID("level1_server", at))
+	assert.Equal(t, "scan-20260301T123000Z", htmlReportID("", at))
+	assert.Equal(t, "a_b-20260301T123000Z", htmlReportID("a/../b", at))
+}
+
+func TestHTMLReportPath(t *testing.T) {
+	dir := t.TempDir()
+	SetHTMLReportDir(dir)
+	defer SetHTMLReportDir("")
+
+	path, err := HTMLReportPath("stig-20260301T123000Z")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "stig-20260301T123000Z.html"), path)
+
+	_, err = HTMLReportPath("../../etc/passwd")
+	assert.Error(t, err)
+}
+
+func TestPruneHTMLReports(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("r%d.html", i))
+		require.NoError(t, os.WriteFile(path, []byte("<html/>"), 0600))
+		require.NoError(t, os.Chtimes(path, base, base.Add(time.Duration(i)*time.Minute)))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0600))
+
+	pruneHTMLReports(dir, 2)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.ElementsMatch(t, []string{"r3.html", "r4.html", "notes.txt"}, names)
+}
@@ -0,0 +1,70 @@
+package compliance
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/utils"
+)
+
+// imageCacheEntry is the last CVE scan of one image ID
+type imageCacheEntry struct {
+	Scan      *models.ComplianceScan `json:"scan"`
+	ScannedAt time.Time              `json:"scanned_at"`
+}
+
+// imageScanCache maps image ID -> last scan. Image IDs are digests of the
+// image content, so an image that changes gets a new entry.
+type imageScanCache struct {
+	Entries map[string]imageCacheEntry `json:"entries"`
+}
+
+// loadImageScanCache reads the cache file; any error yields an empty cache
+func loadImageScanCache(path string) *imageScanCache {
+	c := &imageScanCache{Entries: make(map[string]imageCacheEntry)}
+	if path == "" {
+		return c
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	if err := json.Unmarshal(data, c); err != nil || c.Entries == nil {
+		return &imageScanCache{Entries: make(map[string]imageCacheEntry)}
+	}
+	return c
+}
+
+// get returns a copy of an image's cached scan, or nil if there is none, it
+// is older than ttl or the CVE feed has changed since
+func (c *imageScanCache) get(imageID string, ttl time.Duration, feedUpdated time.Time) *models.ComplianceScan {
+	e, ok := c.Entries[imageID]
+	if !ok || e.Scan == nil || time.Since(e.ScannedAt) >= ttl || feedUpdated.After(e.ScannedAt) {
+		return nil
+	}
+	scan := *e.Scan
+	return &scan
+}
+
+func (c *imageScanCache) put(imageID string, scan *models.ComplianceScan) {
+	c.Entries[imageID] = imageCacheEntry{Scan: scan, ScannedAt: time.Now()}
+}
+
+// save drops scans older than ttl and writes what is left to path
+func (c *imageScanCache) save(path string, ttl time.Duration) error {
+	if path == "" {
+		return nil
+	}
+	for id, e := range c.Entries {
+		if time.Since(e.ScannedAt) >= ttl {
+			delete(c.Entries, id)
+		}
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(path, data, 0600)
+}
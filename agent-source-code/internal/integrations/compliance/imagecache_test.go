@@ -0,0 +1,34 @@
+package compliance
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageScanCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "image_cve_cache.json")
+	cache := loadImageScanCache(path)
+	cache.put("sha256:fresh", &models.ComplianceScan{ProfileName: "Docker Image CVE Scan: app:v1", Failed: 3})
+	cache.Entries["sha256:old"] = imageCacheEntry{
+		Scan:      &models.ComplianceScan{},
+		ScannedAt: time.Now().Add(-48 * time.Hour),
+	}
+	require.NoError(t, cache.save(path, 24*time.Hour))
+
+	cache = loadImageScanCache(path)
+	assert.NotContains(t, cache.Entries, "sha256:old", "expired entries are pruned on save")
+
+	scan := cache.get("sha256:fresh", 24*time.Hour, time.Time{})
+	require.NotNil(t, scan)
+	assert.Equal(t, 3, scan.Failed)
+	scan.ProfileName = "Docker Image CVE Scan: app:latest"
+	assert.Equal(t, "Docker Image CVE Scan: app:v1", cache.Entries["sha256:fresh"].Scan.ProfileName, "get returns a copy")
+
+	assert.Nil(t, cache.get("sha256:other", 24*time.Hour, time.Time{}))
+	assert.Nil(t, cache.get("sha256:fresh", 24*time.Hour, time.Now().Add(time.Minute)), "a newer feed invalidates the result")
+}
@@ -8,15 +8,17 @@ import (
 	"io"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner"
 	"patchmon-agent/internal/logutil"
-	"patchmon-agent/pkg/models"
+	"patchmon-agent/internal/packages"
+	"patchmon-agent/internal/utils"
 
 	"github.com/sirupsen/logrus"
 )
@@ -42,6 +44,16 @@ var profileMappings = map[string]map[string]string{
 		"sles":      "xccdf_org.ssgproject.content_profile_cis",
 		"opensuse":  "xccdf_org.ssgproject.content_profile_cis",
 	},
+	"stig": {
+		"ubuntu":    "xccdf_org.ssgproject.content_profile_stig",
+		"rhel":      "xccdf_org.ssgproject.content_profile_stig",
+		"centos":    "xccdf_org.ssgproject.content_profile_stig",
+		"rocky":     "xccdf_org.ssgproject.content_profile_stig",
+		"alma":      "xccdf_org.ssgproject.content_profile_stig",
+		"almalinux": "xccdf_org.ssgproject.content_profile_stig",
+		"ol":        "xccdf_org.ssgproject.content_profile_stig",
+		"sles":      "xccdf_org.ssgproject.content_profile_stig",
+	},
 	"level2_server": {
 		"ubuntu":    "xccdf_org.ssgproject.content_profile_cis_level2_server",
 		"debian":    "xccdf_org.ssgproject.content_profile_cis_level2_server",
@@ -61,12 +73,15 @@ type OpenSCAPScanner struct {
 	idLike    string // Stores ID_LIKE from /etc/os-release for base distribution detection
 	available bool
 	version   string
+	progress  ProgressFunc // reports rules evaluated during a scan
+	runner    cmdrunner.StreamRunner
 }
 
 // NewOpenSCAPScanner creates a new OpenSCAP scanner
 func NewOpenSCAPScanner(logger *logrus.Logger) *OpenSCAPScanner {
 	s := &OpenSCAPScanner{
 		logger: logger,
+		runner: cmdrunner.Default,
 	}
 	s.osInfo = s.detectOS()
 	s.checkAvailability()
@@ -103,20 +118,19 @@ func (s *OpenSCAPScanner) GetContentPackageVersion() string {
 	}
 
 	// Fall back to package manager version
-	var cmd *exec.Cmd
+	var name string
+	var args []string
 
 	switch s.osInfo.Family {
 	case "debian":
-		cmd = exec.Command("dpkg-query", "-W", "-f=${Version}", "ssg-base")
-	case "rhel":
-		cmd = exec.Command("rpm", "-q", "--qf", "%{VERSION}-%{RELEASE}", "scap-security-guide")
-	case "suse":
-		cmd = exec.Command("rpm", "-q", "--qf", "%{VERSION}-%{RELEASE}", "scap-security-guide")
+		name, args = "dpkg-query", []string{"-W", "-f=${Version}", "ssg-base"}
+	case "rhel", "suse":
+		name, args = "rpm", []string{"-q", "--qf", "%{VERSION}-%{RELEASE}", "scap-security-guide"}
 	default:
 		return ""
 	}
 
-	output, err := cmd.Output()
+	output, err := s.runner.Output(context.Background(), name, args...)
 	if err != nil {
 		return ""
 	}
@@ -135,8 +149,7 @@ func (s *OpenSCAPScanner) DiscoverProfiles() []models.ScanProfileInfo {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, oscapBinary, "info", "--profiles", contentFile)
-	output, err := cmd.Output()
+	output, err := s.runner.Output(ctx, oscapBinary, "info", "--profiles", contentFile)
 	if err != nil {
 		s.logger.WithError(err).Debug("Failed to get profiles from oscap info, using defaults")
 		return s.getDefaultProfiles()
@@ -276,12 +289,19 @@ func (s *OpenSCAPScanner) GetScannerDetails() *models.ComplianceScannerDetails {
 		contentPackage = fmt.Sprintf("SSG %s (server)", githubVersion)
 	}
 
+	var contentUpdatedAt *time.Time
+	if info, err := os.Stat(contentFile); contentFile != "" && err == nil {
+		modTime := info.ModTime()
+		contentUpdatedAt = &modTime
+	}
+
 	return &models.ComplianceScannerDetails{
 		OpenSCAPVersion:   s.version,
 		OpenSCAPAvailable: s.available,
 		ContentFile:       filepath.Base(contentFile),
 		ContentPackage:    contentPackage,
 		SSGVersion:        contentVersion,
+		ContentUpdatedAt:  contentUpdatedAt,
 		AvailableProfiles: profiles,
 		OSName:            s.osInfo.Name,
 		OSVersion:         s.osInfo.Version,
@@ -291,33 +311,44 @@ func (s *OpenSCAPScanner) GetScannerDetails() *models.ComplianceScannerDetails {
 	}
 }
 
+// aptGet runs apt-get without prompts from debconf or needrestart
+func (s *OpenSCAPScanner) aptGet(ctx context.Context, args ...string) ([]byte, error) {
+	envArgs := []string{"DEBIAN_FRONTEND=noninteractive", "NEEDRESTART_MODE=a", "NEEDRESTART_SUSPEND=1", "apt-get"}
+	return cmdrunner.CombinedOutput(ctx, s.runner, "env", append(envArgs, args...)...)
+}
+
 // EnsureInstalled installs OpenSCAP and SCAP content if not present
 // Also upgrades existing packages to ensure latest content is available
 func (s *OpenSCAPScanner) EnsureInstalled() error {
+	if !AutoInstallTools() {
+		s.checkAvailability()
+		if !s.available {
+			return s.missingOpenSCAP()
+		}
+		s.logger.Info("OpenSCAP already installed; not upgrading packages (auto_install_tools is off)")
+		s.checkContentCompatibility()
+		return nil
+	}
+
 	s.logger.Info("Ensuring OpenSCAP is installed with latest SCAP content...")
 
 	// Create context with timeout for package operations
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
-	// Environment for non-interactive apt operations
-	nonInteractiveEnv := append(os.Environ(),
-		"DEBIAN_FRONTEND=noninteractive",
-		"NEEDRESTART_MODE=a",
-		"NEEDRESTART_SUSPEND=1",
-	)
-
 	switch s.osInfo.Family {
 	case "debian":
 		// Ubuntu/Debian - always update and upgrade to get latest content
 		s.logger.Info("Installing/upgrading OpenSCAP on Debian-based system...")
 
-		// Update package cache first (with timeout)
-		updateCmd := exec.CommandContext(ctx, "apt-get", "update", "-qq")
-		updateCmd.Env = nonInteractiveEnv
-		if err := updateCmd.Run(); err != nil {
-			// Ignore errors on update - non-critical
-			_ = err
+		// Update package cache first (with timeout), unless package_cache_refresh forbids it
+		if cacheCfg := packageCacheRefresh(); cacheCfg.ShouldRefresh(packages.APTCacheStale) {
+			if _, err := s.aptGet(ctx, "update", "-qq"); err != nil {
+				// Ignore errors on update - non-critical
+				_ = err
+			}
+		} else {
+			s.logger.WithField("mode", cacheCfg.Mode).Info("Skipping apt-get update before install (package_cache_refresh)")
 		}
 
 		// Build package list - openscap-common is required for Ubuntu 24.04+
@@ -333,9 +364,7 @@ func (s *OpenSCAPScanner) EnsureInstalled() error {
 		installArgs := append([]string{"install", "-y", "-qq",
 			"-o", "Dpkg::Options::=--force-confdef",
 			"-o", "Dpkg::Options::=--force-confold"}, packages...)
-		installCmd := exec.CommandContext(ctx, "apt-get", installArgs...)
-		installCmd.Env = nonInteractiveEnv
-		output, err := installCmd.CombinedOutput()
+		output, err := s.aptGet(ctx, installArgs...)
 		if err != nil {
 			if ctx.Err() == context.DeadlineExceeded {
 				s.logger.Warn("OpenSCAP installation timed out after 5 minutes")
@@ -355,9 +384,7 @@ func (s *OpenSCAPScanner) EnsureInstalled() error {
 		ssgArgs := append([]string{"install", "-y", "-qq",
 			"-o", "Dpkg::Options::=--force-confdef",
 			"-o", "Dpkg::Options::=--force-confold"}, ssgPackages...)
-		ssgCmd := exec.CommandContext(ctx, "apt-get", ssgArgs...)
-		ssgCmd.Env = nonInteractiveEnv
-		ssgOutput, ssgErr := ssgCmd.CombinedOutput()
+		ssgOutput, ssgErr := s.aptGet(ctx, ssgArgs...)
 		if ssgErr != nil {
 			s.logger.WithField("output", logutil.Sanitize(string(ssgOutput))).Warn("SSG content packages not available or failed to install. CIS scanning may have limited functionality.")
 		} else {
@@ -368,11 +395,9 @@ func (s *OpenSCAPScanner) EnsureInstalled() error {
 			if s.osInfo.Name == "debian" {
 				upgradePkgs = append(upgradePkgs, "ssg-debian")
 			}
-			upgradeCmd := exec.CommandContext(ctx, "apt-get", append([]string{"install", "--only-upgrade", "-y", "-qq",
-			    "-o", "Dpkg::Options::=--force-confdef",
-    			"-o", "Dpkg::Options::=--force-confold"}, upgradePkgs...)...)
-			upgradeCmd.Env = nonInteractiveEnv
-			upgradeOutput, upgradeErr := upgradeCmd.CombinedOutput()
+			upgradeOutput, upgradeErr := s.aptGet(ctx, append([]string{"install", "--only-upgrade", "-y", "-qq",
+				"-o", "Dpkg::Options::=--force-confdef",
+				"-o", "Dpkg::Options::=--force-confold"}, upgradePkgs...)...)
 			if upgradeErr != nil {
 				s.logger.WithField("output", logutil.Sanitize(string(upgradeOutput))).Debug("Package upgrade returned non-zero (may already be latest)")
 			} else {
@@ -383,13 +408,12 @@ func (s *OpenSCAPScanner) EnsureInstalled() error {
 	case "rhel":
 		// RHEL/CentOS/Rocky/Alma/Fedora
 		s.logger.Info("Installing/upgrading OpenSCAP on RHEL-based system...")
-		var installCmd *exec.Cmd
-		if _, err := exec.LookPath("dnf"); err == nil {
-			installCmd = exec.CommandContext(ctx, "dnf", "install", "-y", "-q", "openscap-scanner", "scap-security-guide")
-		} else {
-			installCmd = exec.CommandContext(ctx, "yum", "install", "-y", "-q", "openscap-scanner", "scap-security-guide")
+		installer := "yum"
+		if _, err := s.runner.LookPath("dnf"); err == nil {
+			installer = "dnf"
 		}
-		output, err := installCmd.CombinedOutput()
+		installArgs := append(append([]string{"install", "-y", "-q"}, dnfCacheArgs()...), "openscap-scanner", "scap-security-guide")
+		output, err := cmdrunner.CombinedOutput(ctx, s.runner, installer, installArgs...)
 		if err != nil {
 			if ctx.Err() == context.DeadlineExceeded {
 				s.logger.Warn("OpenSCAP installation timed out after 5 minutes")
@@ -406,8 +430,8 @@ func (s *OpenSCAPScanner) EnsureInstalled() error {
 	case "suse":
 		// SLES/openSUSE
 		s.logger.Info("Installing/upgrading OpenSCAP on SUSE-based system...")
-		installCmd := exec.CommandContext(ctx, "zypper", "--non-interactive", "install", "openscap-utils", "scap-security-guide")
-		output, err := installCmd.CombinedOutput()
+		zypperArgs := append(append([]string{"--non-interactive"}, zypperCacheArgs()...), "install", "openscap-utils", "scap-security-guide")
+		output, err := cmdrunner.CombinedOutput(ctx, s.runner, "zypper", zypperArgs...)
 		if err != nil {
 			if ctx.Err() == context.DeadlineExceeded {
 				s.logger.Warn("OpenSCAP installation timed out after 5 minutes")
@@ -782,8 +806,10 @@ func (s *OpenSCAPScanner) downloadFile(ctx context.Context, url, destPath string
 		return err
 	}
 
+	// Route through the same proxy as the package manager when none is set in the environment
 	client := &http.Client{
-		Timeout: 5 * time.Minute,
+		Timeout:   5 * time.Minute,
+		Transport: &http.Transport{Proxy: packages.ProxyFunc, DialContext: utils.NewDialer(30 * time.Second).DialContext},
 	}
 
 	resp, err := client.Do(req)
@@ -924,7 +950,7 @@ func (s *OpenSCAPScanner) getInstalledSSGVersion() string {
 // checkAvailability checks if OpenSCAP is installed and has content
 func (s *OpenSCAPScanner) checkAvailability() {
 	// Check if oscap binary exists
-	path, err := exec.LookPath(oscapBinary)
+	path, err := s.runner.LookPath(oscapBinary)
 	if err != nil {
 		s.logger.Debug("OpenSCAP binary not found")
 		s.available = false
@@ -933,8 +959,7 @@ func (s *OpenSCAPScanner) checkAvailability() {
 	s.logger.WithField("path", path).Debug("Found OpenSCAP binary")
 
 	// Get version
-	cmd := exec.Command(oscapBinary, "--version")
-	output, err := cmd.Output()
+	output, err := s.runner.Output(context.Background(), oscapBinary, "--version")
 	if err != nil {
 		s.logger.WithError(err).Debug("Failed to get OpenSCAP version")
 		s.available = false
@@ -1062,8 +1087,19 @@ func (s *OpenSCAPScanner) getContentOSName() string {
 	return s.osInfo.Name
 }
 
-// getContentFile returns the appropriate SCAP content file for this OS
+// getContentFile returns the appropriate SCAP content file for this OS,
+// preferring content imported with content import over the installed SSG
 func (s *OpenSCAPScanner) getContentFile() string {
+	for _, dir := range ssgContentDirs() {
+		if path := s.contentFileIn(dir); path != "" {
+			return path
+		}
+	}
+	return ""
+}
+
+// contentFileIn looks for this OS's SCAP content file in dir
+func (s *OpenSCAPScanner) contentFileIn(dir string) string {
 	if s.osInfo.Name == "" {
 		return ""
 	}
@@ -1080,14 +1116,14 @@ func (s *OpenSCAPScanner) getContentFile() string {
 
 	// Check each pattern
 	for _, pattern := range patterns {
-		path := filepath.Join(scapContentDir, pattern)
+		path := filepath.Join(dir, pattern)
 		if _, err := os.Stat(path); err == nil {
 			return path
 		}
 	}
 
 	// Try to find any matching file; when multiple exist, prefer the one that matches OS version
-	matches, err := filepath.Glob(filepath.Join(scapContentDir, fmt.Sprintf("ssg-%s*-ds.xml", contentOSName)))
+	matches, err := filepath.Glob(filepath.Join(dir, fmt.Sprintf("ssg-%s*-ds.xml", contentOSName)))
 	if err == nil && len(matches) > 0 {
 		return s.bestContentMatch(matches, contentOSName)
 	}
@@ -1100,12 +1136,12 @@ func (s *OpenSCAPScanner) getContentFile() string {
 			fmt.Sprintf("ssg-%s-ds.xml", s.osInfo.Name),
 		}
 		for _, pattern := range patterns {
-			path := filepath.Join(scapContentDir, pattern)
+			path := filepath.Join(dir, pattern)
 			if _, err := os.Stat(path); err == nil {
 				return path
 			}
 		}
-		matches, err := filepath.Glob(filepath.Join(scapContentDir, fmt.Sprintf("ssg-%s*-ds.xml", s.osInfo.Name)))
+		matches, err := filepath.Glob(filepath.Join(dir, fmt.Sprintf("ssg-%s*-ds.xml", s.osInfo.Name)))
 		if err == nil && len(matches) > 0 {
 			return s.bestContentMatch(matches, s.osInfo.Name)
 		}
@@ -1219,8 +1255,7 @@ func (s *OpenSCAPScanner) getProfileIDFromContent(contentFile string, preferredI
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
-	cmd := exec.CommandContext(ctx, oscapBinary, "info", "--profiles", contentFile)
-	output, err := cmd.Output()
+	output, err := s.runner.Output(ctx, oscapBinary, "info", "--profiles", contentFile)
 	if err != nil {
 		s.logger.WithError(err).Debug("Could not get profiles from content, using preferred ID")
 		return preferredID
@@ -1305,8 +1340,23 @@ func (s *OpenSCAPScanner) RunScan(ctx context.Context, profileName string) (*mod
 	})
 }
 
+// SetProgressFunc sets the function told about each rule a scan evaluates
+func (s *OpenSCAPScanner) SetProgressFunc(fn ProgressFunc) {
+	s.progress = fn
+}
+
 // RunScanWithOptions executes an OpenSCAP scan with configurable options
 func (s *OpenSCAPScanner) RunScanWithOptions(ctx context.Context, options *models.ComplianceScanOptions) (*models.ComplianceScan, error) {
+	return s.runScan(ctx, options, nil)
+}
+
+// RunScanWithResults runs a scan like RunScanWithOptions and also copies
+// oscap's XCCDF results document to results
+func (s *OpenSCAPScanner) RunScanWithResults(ctx context.Context, options *models.ComplianceScanOptions, results io.Writer) (*models.ComplianceScan, error) {
+	return s.runScan(ctx, options, results)
+}
+
+func (s *OpenSCAPScanner) runScan(ctx context.Context, options *models.ComplianceScanOptions, results io.Writer) (*models.ComplianceScan, error) {
 	if !s.available {
 		return nil, fmt.Errorf("OpenSCAP is not available")
 	}
@@ -1396,8 +1446,21 @@ func (s *OpenSCAPScanner) RunScanWithOptions(ctx context.Context, options *model
 		"remediation": options.EnableRemediation,
 	}).Info("Starting OpenSCAP scan (this may take several minutes)...")
 
+	// Rule count for real progress; a tailoring file can change the selection
+	totalRules := 0
+	switch {
+	case options.RuleID != "":
+		totalRules = 1
+	case options.TailoringFile == "":
+		if n, err := countProfileRules(contentFile, profileID); err != nil {
+			s.logger.WithError(err).Debug("Could not count profile rules, progress will not show a percentage")
+		} else {
+			totalRules = n
+		}
+	}
+
 	// Run oscap with progress logging
-	cmd := exec.CommandContext(ctx, oscapBinary, args...)
+	outputWriter := &ruleProgressWriter{total: totalRules, progress: s.progress}
 
 	// Start a goroutine to log progress every 30 seconds
 	done := make(chan struct{})
@@ -1416,8 +1479,9 @@ func (s *OpenSCAPScanner) RunScanWithOptions(ctx context.Context, options *model
 		}
 	}()
 
-	output, err := cmd.CombinedOutput()
+	err = s.runner.Run(ctx, outputWriter, "", oscapBinary, args...)
 	close(done)
+	output := outputWriter.Bytes()
 
 	elapsed := time.Since(startTime)
 	s.logger.WithFields(logrus.Fields{
@@ -1442,15 +1506,15 @@ func (s *OpenSCAPScanner) RunScanWithOptions(ctx context.Context, options *model
 		if ctx.Err() != nil {
 			return nil, fmt.Errorf("scan cancelled or timed out: %w", ctx.Err())
 		}
-		if exitErr, ok := err.(*exec.ExitError); ok {
+		if code, ok := cmdrunner.ExitCode(err); ok {
 			// Exit code 1 or 2 means there were rule failures - this is normal
-			if exitErr.ExitCode() != 2 && exitErr.ExitCode() != 1 {
+			if code != 2 && code != 1 {
 				// Truncate output for error message (keep first 500 chars)
 				outputStr := string(output)
 				if len(outputStr) > 500 {
 					outputStr = outputStr[:500] + "... (truncated)"
 				}
-				return nil, fmt.Errorf("oscap execution failed (exit code %d): %s", exitErr.ExitCode(), outputStr)
+				return nil, fmt.Errorf("oscap execution failed (exit code %d): %s", code, outputStr)
 			}
 		} else {
 			// Other errors (like signal killed)
@@ -1484,6 +1548,24 @@ func (s *OpenSCAPScanner) RunScanWithOptions(ctx context.Context, options *model
 		return nil, fmt.Errorf("failed to parse results: %w", err)
 	}
 
+	if results != nil {
+		f, err := os.Open(resultsPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read results: %w", err)
+		}
+		_, err = io.Copy(results, f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy results: %w", err)
+		}
+	}
+
+	if id, err := s.generateHTMLReport(ctx, resultsPath, options.ProfileID); err != nil {
+		s.logger.WithError(err).Warn("Failed to generate HTML report")
+	} else {
+		scan.HTMLReport = id
+	}
+
 	// Log summary of parsed results for debugging
 	s.logger.WithFields(logrus.Fields{
 		"total_rules":    scan.TotalRules,
@@ -1518,8 +1600,7 @@ func (s *OpenSCAPScanner) GenerateRemediationScript(ctx context.Context, results
 
 	s.logger.WithField("output", outputPath).Debug("Generating remediation script")
 
-	cmd := exec.CommandContext(ctx, oscapBinary, args...)
-	output, err := cmd.CombinedOutput()
+	output, err := cmdrunner.CombinedOutput(ctx, s.runner, oscapBinary, args...)
 	if err != nil {
 		// Truncate output for error message
 		outputStr := string(output)
@@ -1552,18 +1633,17 @@ func (s *OpenSCAPScanner) RunOfflineRemediation(ctx context.Context, resultsPath
 
 	s.logger.WithField("results", resultsPath).Info("Running offline remediation")
 
-	cmd := exec.CommandContext(ctx, oscapBinary, args...)
-	output, err := cmd.CombinedOutput()
+	output, err := cmdrunner.CombinedOutput(ctx, s.runner, oscapBinary, args...)
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
+		if code, ok := cmdrunner.ExitCode(err); ok {
 			// Non-zero exit is expected if some remediations fail
-			if exitErr.ExitCode() > 2 {
+			if code > 2 {
 				// Truncate output for error message
 				outputStr := string(output)
 				if len(outputStr) > 500 {
 					outputStr = outputStr[:500] + "... (truncated)"
 				}
-				return fmt.Errorf("remediation failed (exit code %d): %s", exitErr.ExitCode(), outputStr)
+				return fmt.Errorf("remediation failed (exit code %d): %s", code, outputStr)
 			}
 		} else {
 			return fmt.Errorf("remediation execution failed: %w", err)
@@ -2122,36 +2202,28 @@ func (s *OpenSCAPScanner) Cleanup() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
 	defer cancel()
 
-	// Environment for non-interactive apt operations
-	nonInteractiveEnv := append(os.Environ(),
-		"DEBIAN_FRONTEND=noninteractive",
-		"NEEDRESTART_MODE=a",
-		"NEEDRESTART_SUSPEND=1",
-	)
-
-	var removeCmd *exec.Cmd
+	var output []byte
+	var err error
 
 	switch s.osInfo.Family {
 	case "debian":
-		removeCmd = exec.CommandContext(ctx, "apt-get", "remove", "-y", "-qq",
+		output, err = s.aptGet(ctx, "remove", "-y", "-qq",
 			"-o", "Dpkg::Options::=--force-confdef",
 			"-o", "Dpkg::Options::=--force-confold",
 			"openscap-scanner", "ssg-debderived", "ssg-base")
-		removeCmd.Env = nonInteractiveEnv
 	case "rhel":
-		if _, err := exec.LookPath("dnf"); err == nil {
-			removeCmd = exec.CommandContext(ctx, "dnf", "remove", "-y", "-q", "openscap-scanner", "scap-security-guide")
-		} else {
-			removeCmd = exec.CommandContext(ctx, "yum", "remove", "-y", "-q", "openscap-scanner", "scap-security-guide")
+		remover := "yum"
+		if _, lookErr := s.runner.LookPath("dnf"); lookErr == nil {
+			remover = "dnf"
 		}
+		output, err = cmdrunner.CombinedOutput(ctx, s.runner, remover, "remove", "-y", "-q", "openscap-scanner", "scap-security-guide")
 	case "suse":
-		removeCmd = exec.CommandContext(ctx, "zypper", "--non-interactive", "remove", "openscap-utils", "scap-security-guide")
+		output, err = cmdrunner.CombinedOutput(ctx, s.runner, "zypper", "--non-interactive", "remove", "openscap-utils", "scap-security-guide")
 	default:
 		s.logger.Debug("Unknown OS family, skipping package removal")
 		return nil
 	}
 
-	output, err := removeCmd.CombinedOutput()
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			s.logger.Warn("OpenSCAP removal timed out after 3 minutes")
@@ -0,0 +1,124 @@
+package compliance
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner"
+	"patchmon-agent/internal/cmdrunner/cmdrunnertest"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hostScanner returns an OpenSCAP scanner that replays the commands recorded
+// in testdata/hosts/<host> and finds its content in testdata/content
+func hostScanner(t *testing.T, host string, osInfo models.ComplianceOSInfo) (*OpenSCAPScanner, *cmdrunnertest.Replay) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	SetImportedSSGDir(filepath.Join("testdata", "content"))
+	t.Cleanup(func() { SetImportedSSGDir("") })
+
+	runner := cmdrunnertest.Load(t, filepath.Join("testdata", "hosts", host))
+	return &OpenSCAPScanner{logger: logger, osInfo: osInfo, runner: runner}, runner
+}
+
+func TestOpenSCAPUbuntuHost(t *testing.T) {
+	s, runner := hostScanner(t, "ubuntu-24.04", models.ComplianceOSInfo{Family: "debian", Name: "ubuntu", Version: "24.04"})
+
+	s.checkAvailability()
+	assert.True(t, s.IsAvailable())
+	assert.Equal(t, "OpenSCAP command line tool (oscap) 1.3.9", s.GetVersion())
+	assert.Equal(t, filepath.Join("testdata", "content", "ssg-ubuntu2404-ds.xml"), s.GetContentFilePath())
+	assert.Equal(t, "0.1.72-1", s.GetContentPackageVersion())
+
+	profiles := s.DiscoverProfiles()
+	require.Len(t, profiles, 4)
+	assert.Equal(t, models.ScanProfileInfo{
+		ID:       "cis_level1_server",
+		Name:     "CIS Ubuntu 24.04 LTS Server Benchmark Level 1",
+		Type:     "openscap",
+		XCCDFId:  "xccdf_org.ssgproject.content_profile_cis_level1_server",
+		Category: "cis",
+	}, profiles[0])
+	assert.Equal(t, "stig", profiles[2].Category)
+	assert.Equal(t, "standard", profiles[3].Category)
+
+	manager, _ := s.openscapPackages()
+	assert.Equal(t, "apt-get", manager)
+	assert.Empty(t, runner.Missed())
+}
+
+func TestOpenSCAPRockyHost(t *testing.T) {
+	s, runner := hostScanner(t, "rocky-9", models.ComplianceOSInfo{Family: "rhel", Name: "rocky", Version: "9"})
+
+	s.checkAvailability()
+	assert.Equal(t, "OpenSCAP command line tool (oscap) 1.3.10", s.GetVersion())
+	// No rocky content under testdata, so the scanner is not usable
+	assert.False(t, s.IsAvailable())
+	assert.Equal(t, "0.1.73-1.el9_4", s.GetContentPackageVersion())
+
+	manager, pkgs := s.openscapPackages()
+	assert.Equal(t, "dnf", manager)
+	assert.Equal(t, []string{"openscap-scanner", "scap-security-guide"}, pkgs)
+	assert.Empty(t, runner.Missed())
+}
+
+func TestOpenSCAPMissingBinary(t *testing.T) {
+	s := &OpenSCAPScanner{logger: logrus.New(), runner: cmdrunnertest.New()}
+	s.logger.SetOutput(io.Discard)
+	s.checkAvailability()
+	assert.False(t, s.IsAvailable())
+	assert.Empty(t, s.GetVersion())
+}
+
+func TestParseResultsFixture(t *testing.T) {
+	s, _ := hostScanner(t, "ubuntu-24.04", models.ComplianceOSInfo{Family: "debian", Name: "ubuntu", Version: "24.04"})
+
+	// The output oscap printed alongside the results file
+	data, err := os.ReadFile(filepath.Join("testdata", "results", "ubuntu-24.04-cis_level1_server.txt"))
+	require.NoError(t, err)
+	run, err := cmdrunner.ParseFixture(data)
+	require.NoError(t, err)
+	assert.Equal(t, 2, run.ExitCode)
+
+	scan, err := s.parseResults(
+		filepath.Join("testdata", "results", "ubuntu-24.04-cis_level1_server.xml"),
+		s.GetContentFilePath(),
+		"cis_level1_server",
+		string(run.Stdout),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, scan.TotalRules)
+	assert.Equal(t, 2, scan.Failed)
+	assert.Equal(t, 1, scan.Passed)
+	assert.Equal(t, 1, scan.NotApplicable)
+	assert.Equal(t, 1, scan.Skipped)
+	assert.InDelta(t, 100.0/3, scan.Score, 0.01)
+
+	results := make(map[string]models.ComplianceResult)
+	for _, r := range scan.Results {
+		results[r.RuleID] = r
+	}
+
+	rootLogin := results["xccdf_org.ssgproject.content_rule_sshd_disable_root_login"]
+	assert.Equal(t, "fail", rootLogin.Status)
+	assert.Equal(t, "Disable SSH Root Login", rootLogin.Title)
+	assert.Equal(t, "medium", rootLogin.Severity)
+	assert.Contains(t, rootLogin.Finding, "PermitRootLogin is set to yes")
+
+	// Without a message in the results file the finding comes from oscap's output
+	idle := results["xccdf_org.ssgproject.content_rule_sshd_set_idle_timeout"]
+	assert.Equal(t, "fail", idle.Status)
+	assert.Equal(t, "low", idle.Severity)
+	assert.Contains(t, idle.Finding, "ClientAliveInterval not set")
+
+	assert.Equal(t, "pass", results["xccdf_org.ssgproject.content_rule_package_telnetd_removed"].Status)
+	assert.Equal(t, "skip", results["xccdf_org.ssgproject.content_rule_aide_build_database"].Status)
+}
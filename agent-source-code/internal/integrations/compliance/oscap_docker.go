@@ -1,17 +1,19 @@
 package compliance
 
 import (
-	"bufio"
 	"context"
 	"fmt"
-	"os/exec"
+	"math"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner"
 	"patchmon-agent/internal/logutil"
-	"patchmon-agent/pkg/models"
 
+	"github.com/moby/moby/client"
 	"github.com/sirupsen/logrus"
 )
 
@@ -23,12 +25,14 @@ const (
 type OscapDockerScanner struct {
 	logger    *logrus.Logger
 	available bool
+	runner    cmdrunner.StreamRunner
 }
 
 // NewOscapDockerScanner creates a new oscap-docker scanner
 func NewOscapDockerScanner(logger *logrus.Logger) *OscapDockerScanner {
 	s := &OscapDockerScanner{
 		logger: logger,
+		runner: cmdrunner.Default,
 	}
 	s.checkAvailability()
 	return s
@@ -42,7 +46,7 @@ func (s *OscapDockerScanner) IsAvailable() bool {
 // checkAvailability checks if oscap-docker tool is available
 func (s *OscapDockerScanner) checkAvailability() {
 	// Check if oscap-docker binary exists
-	path, err := exec.LookPath(oscapDockerBinary)
+	path, err := s.runner.LookPath(oscapDockerBinary)
 	if err != nil {
 		s.logger.Debug("oscap-docker binary not found")
 		s.available = false
@@ -51,18 +55,9 @@ func (s *OscapDockerScanner) checkAvailability() {
 
 	s.logger.WithField("path", path).Debug("oscap-docker binary found")
 
-	// Check if docker is also available (required for oscap-docker)
-	_, err = exec.LookPath("docker")
-	if err != nil {
-		s.logger.Debug("Docker binary not found - oscap-docker requires Docker")
-		s.available = false
-		return
-	}
-
-	// Check if Docker daemon is running
-	cmd := exec.Command("docker", "info")
-	if err := cmd.Run(); err != nil {
-		s.logger.Debug("Docker daemon not responding - oscap-docker requires Docker")
+	// oscap-docker talks to the daemon itself, so only the daemon is needed
+	if err := pingDocker(context.Background()); err != nil {
+		s.logger.WithError(err).Debug("Docker not available - oscap-docker requires Docker")
 		s.available = false
 		return
 	}
@@ -85,29 +80,22 @@ func (s *OscapDockerScanner) ScanImage(ctx context.Context, imageName string) (*
 
 	s.logger.WithField("image", imageName).Info("Scanning Docker image for CVEs...")
 
-	// Run oscap-docker image-cve
-	// This will:
-	// 1. Attach to the Docker image
-	// 2. Determine OS variant/version
-	// 3. Download applicable CVE stream (OVAL data)
-	// 4. Run vulnerability scan
-	cmd := exec.CommandContext(ctx, oscapDockerBinary, "image-cve", imageName)
-	output, err := cmd.CombinedOutput()
-
+	scan, err := s.scanWithCVEFeed(ctx, "image", imageName)
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("scan cancelled: %w", ctx.Err())
+	}
 	if err != nil {
-		if ctx.Err() != nil {
-			return nil, fmt.Errorf("scan cancelled: %w", ctx.Err())
-		}
-		// oscap-docker exits non-zero when vulnerabilities are found
-		// Check if we got any output to parse
-		if len(output) == 0 {
-			return nil, fmt.Errorf("oscap-docker failed: %w", err)
+		s.logger.WithError(err).WithField("image", imageName).Warn("Local CVE feed scan failed, falling back to oscap-docker image-cve")
+	}
+	if scan == nil {
+		output, err := s.runCVECommand(ctx, "image-cve", imageName)
+		if err != nil {
+			return nil, err
 		}
-		s.logger.WithError(err).Debug("oscap-docker exited with error, parsing output for results")
+		scan = s.parseImageCveOutput(output, imageName)
 	}
-
-	// Parse the output
-	scan := s.parseImageCveOutput(string(output), imageName)
+	applyCVEWaivers(scan, imageName)
+	scan.ProfileName = imageScanProfileName(imageName)
 	scan.StartedAt = startTime
 	now := time.Now()
 	scan.CompletedAt = &now
@@ -116,12 +104,53 @@ func (s *OscapDockerScanner) ScanImage(ctx context.Context, imageName string) (*
 	s.logger.WithFields(logrus.Fields{
 		"image":           imageName,
 		"vulnerabilities": scan.Failed,
+		"waived":          scan.Waived,
 		"total_cves":      scan.TotalRules,
 	}).Info("Docker image CVE scan completed")
 
 	return scan, nil
 }
 
+// containerImage returns the image a container was created from, or "" if
+// the container can't be inspected
+func containerImage(ctx context.Context, containerName string) string {
+	cli, err := dockerAPI()
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = cli.Close() }()
+
+	res, err := cli.ContainerInspect(ctx, containerName, client.ContainerInspectOptions{})
+	if err != nil || res.Container.Config == nil {
+		return ""
+	}
+	return res.Container.Config.Image
+}
+
+// runCVECommand runs oscap-docker image-cve or container-cve and returns its
+// output
+func (s *OscapDockerScanner) runCVECommand(ctx context.Context, command, target string) (string, error) {
+	// This will:
+	// 1. Attach to the Docker image or container
+	// 2. Determine OS variant/version
+	// 3. Download applicable CVE stream (OVAL data)
+	// 4. Run vulnerability scan
+	output, err := cmdrunner.CombinedOutput(ctx, s.runner, oscapDockerBinary, command, target)
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("scan cancelled: %w", ctx.Err())
+		}
+		// oscap-docker exits non-zero when vulnerabilities are found
+		// Check if we got any output to parse
+		if len(output) == 0 {
+			return "", fmt.Errorf("oscap-docker failed: %w", err)
+		}
+		s.logger.WithError(err).Debug("oscap-docker exited with error, parsing output for results")
+	}
+	return string(output), nil
+}
+
 // ScanContainer scans a running container for CVEs
 func (s *OscapDockerScanner) ScanContainer(ctx context.Context, containerName string) (*models.ComplianceScan, error) {
 	if !s.available {
@@ -136,22 +165,22 @@ func (s *OscapDockerScanner) ScanContainer(ctx context.Context, containerName st
 
 	s.logger.WithField("container", containerName).Info("Scanning Docker container for CVEs...")
 
-	// Run oscap-docker container-cve
-	cmd := exec.CommandContext(ctx, oscapDockerBinary, "container-cve", containerName)
-	output, err := cmd.CombinedOutput()
-
+	scan, err := s.scanWithCVEFeed(ctx, "container", containerName)
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("scan cancelled: %w", ctx.Err())
+	}
 	if err != nil {
-		if ctx.Err() != nil {
-			return nil, fmt.Errorf("scan cancelled: %w", ctx.Err())
-		}
-		if len(output) == 0 {
-			return nil, fmt.Errorf("oscap-docker failed: %w", err)
+		s.logger.WithError(err).WithField("container", containerName).Warn("Local CVE feed scan failed, falling back to oscap-docker container-cve")
+	}
+	if scan == nil {
+		output, err := s.runCVECommand(ctx, "container-cve", containerName)
+		if err != nil {
+			return nil, err
 		}
-		s.logger.WithError(err).Debug("oscap-docker exited with error, parsing output for results")
+		scan = s.parseContainerCveOutput(output, containerName)
 	}
-
-	// Parse the output
-	scan := s.parseContainerCveOutput(string(output), containerName)
+	applyCVEWaivers(scan, containerName, containerImage(ctx, containerName))
+	scan.ProfileName = fmt.Sprintf("Docker Container CVE Scan: %s", containerName)
 	scan.StartedAt = startTime
 	now := time.Now()
 	scan.CompletedAt = &now
@@ -160,49 +189,184 @@ func (s *OscapDockerScanner) ScanContainer(ctx context.Context, containerName st
 	s.logger.WithFields(logrus.Fields{
 		"container":       containerName,
 		"vulnerabilities": scan.Failed,
+		"waived":          scan.Waived,
 		"total_cves":      scan.TotalRules,
 	}).Info("Docker container CVE scan completed")
 
 	return scan, nil
 }
 
-// ScanAllImages scans all Docker images on the system
-func (s *OscapDockerScanner) ScanAllImages(ctx context.Context) ([]*models.ComplianceScan, error) {
+// ImageScanOptions controls ScanAllImages
+type ImageScanOptions struct {
+	Concurrency  int           // images scanned at once; values below 1 mean 1
+	ImageTimeout time.Duration // limit per image; 0 leaves only ctx's deadline
+	// Skip reports whether an image, by ID, was scanned recently enough to
+	// leave out. Tags of the same image are scanned once regardless.
+	Skip func(imageID string) bool
+	// CachePath keeps results by image ID for CacheTTL. A cached result is
+	// returned instead of rescanning unless it predates FeedUpdated or the
+	// newest cached CVE feed. Empty disables the cache.
+	CachePath   string
+	CacheTTL    time.Duration
+	FeedUpdated time.Time
+	// Scanned is called for each image in the results, scanned or cached
+	Scanned func(imageID string)
+	// Progress is called as each image finishes, successfully or not
+	Progress func(done, total int, image string)
+}
+
+// dockerImage is one local image and the tag it is scanned by
+type dockerImage struct {
+	id  string
+	ref string
+}
+
+// ScanAllImages scans all local Docker images for CVEs, several at a time.
+// An image that fails to scan is logged and left out.
+func (s *OscapDockerScanner) ScanAllImages(ctx context.Context, opts ImageScanOptions) ([]*models.ComplianceScan, error) {
 	if !s.available {
 		return nil, fmt.Errorf("oscap-docker is not available")
 	}
 
-	// Get list of all images
-	cmd := exec.CommandContext(ctx, "docker", "images", "--format", "{{.Repository}}:{{.Tag}}")
-	output, err := cmd.Output()
+	images, err := s.listImages(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list Docker images: %w", err)
+		return nil, err
+	}
+	if opts.Skip != nil {
+		pending := images[:0]
+		for _, img := range images {
+			if !opts.Skip(img.id) {
+				pending = append(pending, img)
+			}
+		}
+		if skipped := len(images) - len(pending); skipped > 0 {
+			s.logger.WithField("skipped", skipped).Info("Skipping Docker images scanned recently")
+		}
+		images = pending
 	}
 
-	var scans []*models.ComplianceScan
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	s.refreshCVEFeeds(ctx)
+	feedUpdated := CVEFeedUpdated()
+	if opts.FeedUpdated.After(feedUpdated) {
+		feedUpdated = opts.FeedUpdated
+	}
 
-	for scanner.Scan() {
-		imageName := strings.TrimSpace(scanner.Text())
-		if imageName == "" || imageName == "<none>:<none>" {
+	scans := make([]*models.ComplianceScan, len(images))
+	useCache := opts.CachePath != "" && opts.CacheTTL > 0
+	cache := &imageScanCache{Entries: make(map[string]imageCacheEntry)}
+	if useCache {
+		cache = loadImageScanCache(opts.CachePath)
+	}
+	var toScan []int
+	for i, img := range images {
+		if scan := cache.get(img.id, opts.CacheTTL, feedUpdated); scan != nil {
+			applyCVEWaivers(scan, img.ref)
+			scan.ProfileName = imageScanProfileName(img.ref)
+			scans[i] = scan
+			if opts.Scanned != nil {
+				opts.Scanned(img.id)
+			}
 			continue
 		}
+		toScan = append(toScan, i)
+	}
 
-		scan, err := s.ScanImage(ctx, imageName)
-		if err != nil {
-			s.logger.WithError(err).WithField("image", imageName).Warn("Failed to scan image, skipping")
-			continue
+	workers := max(1, min(opts.Concurrency, len(toScan)))
+	s.logger.WithFields(logrus.Fields{
+		"images":  len(toScan),
+		"cached":  len(images) - len(toScan),
+		"workers": workers,
+	}).Info("Scanning Docker images for CVEs")
+
+	queue := make(chan int)
+	var mu sync.Mutex // serialises the callbacks
+	done := 0
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				img := images[i]
+				scan, err := s.scanImageWithTimeout(ctx, img.ref, opts.ImageTimeout)
+				mu.Lock()
+				if err != nil {
+					s.logger.WithError(err).WithField("image", img.ref).Warn("Failed to scan image, skipping")
+				} else {
+					scans[i] = scan
+					cache.put(img.id, scan)
+					if opts.Scanned != nil {
+						opts.Scanned(img.id)
+					}
+				}
+				done++
+				if opts.Progress != nil {
+					opts.Progress(done, len(toScan), img.ref)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for _, i := range toScan {
+		select {
+		case queue <- i:
+		case <-ctx.Done():
+			break feed
 		}
-		scans = append(scans, scan)
 	}
+	close(queue)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("scan cancelled: %w", ctx.Err())
+	}
+	if useCache {
+		if err := cache.save(opts.CachePath, opts.CacheTTL); err != nil {
+			s.logger.WithError(err).Warn("Failed to save image scan cache")
+		}
+	}
+
+	result := make([]*models.ComplianceScan, 0, len(scans))
+	for _, scan := range scans {
+		if scan != nil {
+			result = append(result, scan)
+		}
+	}
+	return result, nil
+}
+
+func (s *OscapDockerScanner) scanImageWithTimeout(ctx context.Context, imageName string, timeout time.Duration) (*models.ComplianceScan, error) {
+	if timeout <= 0 {
+		return s.ScanImage(ctx, imageName)
+	}
+	imageCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return s.ScanImage(imageCtx, imageName)
+}
+
+// listImages returns the local images, once per image ID
+func (s *OscapDockerScanner) listImages(ctx context.Context) ([]dockerImage, error) {
+	cli, err := dockerAPI()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = cli.Close() }()
+
+	res, err := cli.ImageList(ctx, client.ImageListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Docker images: %w", err)
+	}
+	return taggedImages(res.Items), nil
+}
 
-	return scans, nil
+func imageScanProfileName(imageName string) string {
+	return fmt.Sprintf("Docker Image CVE Scan: %s", imageName)
 }
 
 // parseImageCveOutput parses oscap-docker image-cve output
 func (s *OscapDockerScanner) parseImageCveOutput(output string, imageName string) *models.ComplianceScan {
 	scan := &models.ComplianceScan{
-		ProfileName: fmt.Sprintf("Docker Image CVE Scan: %s", imageName),
+		ProfileName: imageScanProfileName(imageName),
 		ProfileType: "oscap-docker",
 		Results:     make([]models.ComplianceResult, 0),
 	}
@@ -236,32 +400,50 @@ func (s *OscapDockerScanner) parseImageCveOutput(output string, imageName string
 			}
 			seenCVEs[cveID] = true
 
-			// Determine severity
-			severity := "medium" // default
-			severityMatch := severityPattern.FindStringSubmatch(line)
-			if len(severityMatch) > 0 {
-				severity = strings.ToLower(severityMatch[1])
-				// Normalize severity names
-				switch severity {
-				case "important":
-					severity = "high"
-				case "moderate":
-					severity = "medium"
+			// Determine severity, from the CVSS score when the line has one
+			result := models.ComplianceResult{
+				RuleID:  cveID,
+				Title:   line,
+				Status:  "fail", // CVEs found are failures
+				Section: "Container Vulnerabilities",
+			}
+			if score, ok := cvssFromLine(line); ok {
+				result.CVSSScore = score
+				result.Severity = cvssSeverity(score)
+			} else {
+				severity := ""
+				severityMatch := severityPattern.FindStringSubmatch(line)
+				if len(severityMatch) > 0 {
+					severity = severityMatch[1]
 				}
+				result.Severity = normalizeCVESeverity(severity)
 			}
-
-			scan.Results = append(scan.Results, models.ComplianceResult{
-				RuleID:   cveID,
-				Title:    line,
-				Status:   "fail", // CVEs found are failures
-				Severity: severity,
-				Section:  "Container Vulnerabilities",
-			})
+			scan.Results = append(scan.Results, result)
 			scan.Failed++
 			scan.TotalRules++
 		}
 	}
 
+	scoreImageScan(scan)
+	return scan
+}
+
+// normalizeCVESeverity maps Red Hat impact ratings onto the severities used
+// for compliance results, defaulting to medium
+func normalizeCVESeverity(severity string) string {
+	switch severity = strings.ToLower(strings.TrimSpace(severity)); severity {
+	case "critical", "high", "medium", "low":
+		return severity
+	case "important":
+		return "high"
+	default:
+		return "medium"
+	}
+}
+
+// scoreImageScan scores a CVE scan by the severity of what it found, or marks
+// it passed when it found nothing
+func scoreImageScan(scan *models.ComplianceScan) {
 	// If no CVEs found, mark as passed
 	if scan.TotalRules == 0 {
 		scan.Passed = 1
@@ -274,33 +456,22 @@ func (s *OscapDockerScanner) parseImageCveOutput(output string, imageName string
 			Section: "Container Vulnerabilities",
 		})
 	} else {
-		// Calculate score based on severity
-		// Critical = 10 points, High = 5 points, Medium = 2 points, Low = 1 point
-		totalPenalty := 0
+		// Each CVE costs points by its CVSS base score, or by a typical score
+		// for its severity when it has none. Waived CVEs cost nothing. Max
+		// penalty of 100 points.
+		totalPenalty := 0.0
 		for _, result := range scan.Results {
-			switch result.Severity {
-			case "critical":
-				totalPenalty += 10
-			case "high":
-				totalPenalty += 5
-			case "medium":
-				totalPenalty += 2
-			case "low":
-				totalPenalty++
+			if result.Status != "fail" {
+				continue
 			}
+			score := result.CVSSScore
+			if score == 0 {
+				score = severityCVSS(result.Severity)
+			}
+			totalPenalty += cvssPenalty(score)
 		}
-		// Score decreases with more/worse vulnerabilities
-		// Max penalty of 100 points
-		if totalPenalty > 100 {
-			totalPenalty = 100
-		}
-		scan.Score = float64(100 - totalPenalty)
-		if scan.Score < 0 {
-			scan.Score = 0
-		}
+		scan.Score = math.Round((100-min(totalPenalty, 100))*10) / 10
 	}
-
-	return scan
 }
 
 // parseContainerCveOutput parses oscap-docker container-cve output
@@ -317,8 +488,7 @@ func (s *OscapDockerScanner) GetVersion() string {
 		return ""
 	}
 
-	cmd := exec.Command(oscapDockerBinary, "--version")
-	output, err := cmd.Output()
+	output, err := s.runner.Output(context.Background(), oscapDockerBinary, "--version")
 	if err != nil {
 		return ""
 	}
@@ -343,31 +513,35 @@ func (s *OscapDockerScanner) EnsureInstalled() error {
 	defer cancel()
 
 	// Try different package managers with appropriate packages
-	if _, err := exec.LookPath("apt-get"); err == nil {
+	if _, err := s.runner.LookPath("apt-get"); err == nil {
 		// Debian/Ubuntu - oscap-docker requires the 'atomic' package which is NOT available on Ubuntu
 		// oscap-docker is primarily a Red Hat/Fedora tool that depends on atomic
 		// See: https://answers.launchpad.net/ubuntu/+source/openscap/+question/242354
 		s.logger.Warn("oscap-docker is not supported on Ubuntu/Debian - it requires the 'atomic' package which is only available on RHEL/Fedora")
 		return fmt.Errorf("oscap-docker is not available on Ubuntu/Debian (requires 'atomic' package)")
-	} else if _, err := exec.LookPath("dnf"); err == nil {
+	} else if _, err := s.runner.LookPath("dnf"); err == nil {
 		// RHEL 8+/Fedora - oscap-docker is available via openscap-containers
 		s.logger.Info("Installing openscap-containers for RHEL/Fedora...")
-		installCmd := exec.CommandContext(ctx, "dnf", "install", "-y", "openscap-containers")
-		output, err := installCmd.CombinedOutput()
+		if !AutoInstallTools() {
+			return &ToolsMissingError{Tool: "oscap-docker", Packages: []string{"openscap-containers"}, Command: "dnf install openscap-containers"}
+		}
+		output, err := cmdrunner.CombinedOutput(ctx, s.runner, "dnf", append(append([]string{"install", "-y"}, dnfCacheArgs()...), "openscap-containers")...)
 		if err != nil {
 			s.logger.WithError(err).WithField("output", logutil.Sanitize(string(output))).Warn("Failed to install openscap-containers")
 			return fmt.Errorf("failed to install openscap-containers: %w", err)
 		}
-	} else if _, err := exec.LookPath("yum"); err == nil {
+	} else if _, err := s.runner.LookPath("yum"); err == nil {
 		// RHEL 7/CentOS 7
 		s.logger.Info("Installing openscap-containers for CentOS/RHEL 7...")
-		installCmd := exec.CommandContext(ctx, "yum", "install", "-y", "openscap-containers")
-		output, err := installCmd.CombinedOutput()
+		if !AutoInstallTools() {
+			return &ToolsMissingError{Tool: "oscap-docker", Packages: []string{"openscap-containers"}, Command: "yum install openscap-containers"}
+		}
+		output, err := cmdrunner.CombinedOutput(ctx, s.runner, "yum", append(append([]string{"install", "-y"}, dnfCacheArgs()...), "openscap-containers")...)
 		if err != nil {
 			s.logger.WithError(err).WithField("output", logutil.Sanitize(string(output))).Warn("Failed to install openscap-containers")
 			return fmt.Errorf("failed to install openscap-containers: %w", err)
 		}
-	} else if _, err := exec.LookPath("apk"); err == nil {
+	} else if _, err := s.runner.LookPath("apk"); err == nil {
 		// Alpine - oscap-docker is not available
 		s.logger.Warn("oscap-docker is not available on Alpine Linux")
 		return fmt.Errorf("oscap-docker is not available on Alpine Linux")
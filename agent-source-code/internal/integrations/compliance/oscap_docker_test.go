@@ -0,0 +1,41 @@
+package compliance
+
+import (
+	"testing"
+
+	"github.com/moby/moby/api/types/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaggedImages(t *testing.T) {
+	summaries := []image.Summary{
+		{ID: "sha256:aaa", RepoTags: []string{"nginx:1.27", "nginx:latest"}},
+		{ID: "sha256:bbb", RepoTags: []string{"<none>:<none>"}},
+		{ID: "sha256:ddd"},
+		{ID: "sha256:ccc", RepoTags: []string{"registry.example.com/app:v2"}},
+		{ID: "sha256:aaa", RepoTags: []string{"nginx:stable"}},
+	}
+
+	assert.Equal(t, []dockerImage{
+		{id: "sha256:aaa", ref: "nginx:1.27"},
+		{id: "sha256:ccc", ref: "registry.example.com/app:v2"},
+	}, taggedImages(summaries), "untagged images are dropped and each image ID is scanned once")
+}
+
+func TestParseImageCveOutputCVSS(t *testing.T) {
+	s := &OscapDockerScanner{}
+	scan := s.parseImageCveOutput("CVE-2024-0001 - Low - CVSS: 9.1 - libfoo\n"+
+		"CVE-2024-0002 - moderate - cvss3=4.3/CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:U/C:N/I:L/A:N\n"+
+		"CVE-2024-0003 - Important - vector CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N\n", "app:v1")
+
+	require.Len(t, scan.Results, 3)
+	assert.Equal(t, 9.1, scan.Results[0].CVSSScore)
+	assert.Equal(t, "critical", scan.Results[0].Severity, "the score overrides the word on the line")
+	assert.Equal(t, 4.3, scan.Results[1].CVSSScore)
+	assert.Equal(t, "medium", scan.Results[1].Severity)
+	assert.Zero(t, scan.Results[2].CVSSScore, "a bare vector has no base score")
+	assert.Equal(t, "high", scan.Results[2].Severity)
+	// 8.3 + 1.8 + 5.6 (typical high) points
+	assert.Equal(t, 84.3, scan.Score)
+}
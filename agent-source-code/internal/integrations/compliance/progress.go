@@ -0,0 +1,160 @@
+package compliance
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ProgressFunc receives how many rules of an OpenSCAP scan have been
+// evaluated. total is 0 when the rule count of the profile is unknown.
+type ProgressFunc func(evaluated, total int)
+
+// ruleProgressWriter collects oscap's output and reports each evaluated rule.
+// oscap prints a "Result <status>" line per rule as it finishes.
+type ruleProgressWriter struct {
+	mu        sync.Mutex
+	output    bytes.Buffer
+	partial   []byte
+	evaluated int
+	total     int
+	progress  ProgressFunc
+}
+
+func (w *ruleProgressWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.output.Write(p)
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(w.partial[:i])
+		w.partial = w.partial[i+1:]
+		if !isResultLine(line) {
+			continue
+		}
+		w.evaluated++
+		// Remediation prints its own result lines after the evaluation
+		if w.total > 0 && w.evaluated > w.total {
+			continue
+		}
+		if w.progress != nil {
+			w.progress(w.evaluated, w.total)
+		}
+	}
+	return len(p), nil
+}
+
+// Bytes returns everything oscap wrote
+func (w *ruleProgressWriter) Bytes() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.output.Bytes()
+}
+
+// isResultLine matches oscap's "Result\r\tpass" style lines
+func isResultLine(line string) bool {
+	fields := strings.Fields(line)
+	return len(fields) == 2 && fields[0] == "Result"
+}
+
+// countProfileRules returns how many rules a profile in a SCAP datastream
+// selects, following the profiles it extends. It returns 0 if the profile
+// isn't found.
+func countProfileRules(contentFile, profileID string) (int, error) {
+	f, err := os.Open(contentFile)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return profileRuleCount(f, profileID)
+}
+
+// xccdfProfile is the part of an XCCDF Profile needed to count its rules
+type xccdfProfile struct {
+	extends string
+	selects map[string]bool
+}
+
+func profileRuleCount(r io.Reader, profileID string) (int, error) {
+	rules := make(map[string]bool) // rule ID -> selected by default
+	profiles := make(map[string]*xccdfProfile)
+	var current *xccdfProfile
+
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse SCAP content: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "Rule":
+				rules[xmlAttr(t, "id")] = xmlAttr(t, "selected") != "false"
+			case "Profile":
+				current = &xccdfProfile{extends: xmlAttr(t, "extends"), selects: make(map[string]bool)}
+				profiles[xmlAttr(t, "id")] = current
+			case "select":
+				if current != nil {
+					current.selects[xmlAttr(t, "idref")] = xmlAttr(t, "selected") == "true"
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "Profile" {
+				current = nil
+			}
+		}
+	}
+
+	profile, ok := profiles[profileID]
+	if !ok {
+		return 0, nil
+	}
+	// Apply the extends chain from the base profile up
+	var chain []*xccdfProfile
+	seen := make(map[string]bool)
+	for id := profileID; profile != nil && !seen[id]; {
+		seen[id] = true
+		chain = append(chain, profile)
+		id = profile.extends
+		profile = profiles[id]
+	}
+	selected := make(map[string]bool, len(rules))
+	for id, def := range rules {
+		selected[id] = def
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		for idref, on := range chain[i].selects {
+			if _, isRule := rules[idref]; isRule {
+				selected[idref] = on
+			}
+		}
+	}
+	count := 0
+	for _, on := range selected {
+		if on {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func xmlAttr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
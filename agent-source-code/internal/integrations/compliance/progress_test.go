@@ -0,0 +1,80 @@
+package compliance
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDatastream = `<?xml version="1.0"?>
+<ds:data-stream-collection xmlns:ds="http://scap.nist.gov/schema/scap/source/1.2" xmlns:xccdf-1.2="http://checklists.nist.gov/xccdf/1.2">
+  <ds:component>
+    <xccdf-1.2:Benchmark id="xccdf_test_benchmark">
+      <xccdf-1.2:Profile id="xccdf_test_profile_base">
+        <xccdf-1.2:select idref="xccdf_test_rule_a" selected="true"/>
+        <xccdf-1.2:select idref="xccdf_test_rule_b" selected="true"/>
+        <xccdf-1.2:select idref="xccdf_test_group_g" selected="true"/>
+      </xccdf-1.2:Profile>
+      <xccdf-1.2:Profile id="xccdf_test_profile_server" extends="xccdf_test_profile_base">
+        <xccdf-1.2:select idref="xccdf_test_rule_b" selected="false"/>
+        <xccdf-1.2:select idref="xccdf_test_rule_c" selected="true"/>
+        <xccdf-1.2:select idref="xccdf_test_rule_d" selected="true"/>
+      </xccdf-1.2:Profile>
+      <xccdf-1.2:Group id="xccdf_test_group_g">
+        <xccdf-1.2:Rule id="xccdf_test_rule_a" selected="false"/>
+        <xccdf-1.2:Rule id="xccdf_test_rule_b" selected="false"/>
+        <xccdf-1.2:Rule id="xccdf_test_rule_c" selected="false"/>
+        <xccdf-1.2:Rule id="xccdf_test_rule_d" selected="false"/>
+        <xccdf-1.2:Rule id="xccdf_test_rule_e"/>
+      </xccdf-1.2:Group>
+    </xccdf-1.2:Benchmark>
+  </ds:component>
+</ds:data-stream-collection>`
+
+func TestProfileRuleCount(t *testing.T) {
+	tests := []struct {
+		profile string
+		want    int
+	}{
+		// a, b and e, which is selected by default
+		{"xccdf_test_profile_base", 3},
+		// base minus b, plus c and d
+		{"xccdf_test_profile_server", 4},
+		{"xccdf_test_profile_missing", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			got, err := profileRuleCount(strings.NewReader(testDatastream), tt.profile)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := profileRuleCount(strings.NewReader("<Benchmark><Profile"), "x")
+	assert.Error(t, err)
+}
+
+func TestRuleProgressWriter(t *testing.T) {
+	var calls [][2]int
+	w := &ruleProgressWriter{total: 2, progress: func(evaluated, total int) {
+		calls = append(calls, [2]int{evaluated, total})
+	}}
+
+	// Lines can arrive split across writes
+	chunks := []string{
+		"Title\r\tEnsure a\nRule\r\txccdf_test_rule_a\nRes",
+		"ult\r\tpass\n\nTitle\r\tEnsure b\nRule\r\txccdf_test_rule_b\nResult\r\tfail\n",
+		// Remediation output past the rule count is not reported
+		"Result\r\tfixed\n",
+	}
+	for _, c := range chunks {
+		n, err := w.Write([]byte(c))
+		require.NoError(t, err)
+		assert.Equal(t, len(c), n)
+	}
+
+	assert.Equal(t, [][2]int{{1, 2}, {2, 2}}, calls)
+	assert.Equal(t, strings.Join(chunks, ""), string(w.Bytes()))
+}
@@ -0,0 +1,145 @@
+package compliance
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+var autoInstall atomic.Bool
+
+func init() {
+	autoInstall.Store(true)
+}
+
+// SetAutoInstallTools controls whether scanners may install packages and pull
+// images themselves. When false, EnsureInstalled only checks and returns a
+// *ToolsMissingError naming what an administrator has to install.
+func SetAutoInstallTools(enabled bool) {
+	autoInstall.Store(enabled)
+}
+
+// AutoInstallTools reports whether scanners may install their own tools
+func AutoInstallTools() bool {
+	return autoInstall.Load()
+}
+
+var dockerBenchScript atomic.Value // string
+
+// SetDockerBenchScript makes Docker Bench run a locally installed
+// docker-bench-security.sh instead of pulling the container image. Empty
+// restores the image.
+func SetDockerBenchScript(path string) {
+	dockerBenchScript.Store(path)
+}
+
+// DockerBenchScript returns the configured local Docker Bench script, if any
+func DockerBenchScript() string {
+	path, _ := dockerBenchScript.Load().(string)
+	return path
+}
+
+var dockerBenchImage atomic.Value // string
+
+// SetDockerBenchImage sets the Docker Bench image reference, optionally pinned
+// by digest (repo@sha256:...). Empty restores DefaultDockerBenchImage.
+func SetDockerBenchImage(ref string) {
+	dockerBenchImage.Store(ref)
+}
+
+// DockerBenchImage returns the Docker Bench image reference to run
+func DockerBenchImage() string {
+	if ref, _ := dockerBenchImage.Load().(string); ref != "" {
+		return ref
+	}
+	return DefaultDockerBenchImage
+}
+
+var importedSSGDir atomic.Value // string
+
+// SetImportedSSGDir adds a directory of SSG datastreams, such as one brought
+// in by content import, that is searched before the installed SSG content.
+// Empty searches the installed content only.
+func SetImportedSSGDir(dir string) {
+	importedSSGDir.Store(dir)
+}
+
+// ssgContentDirs lists the directories searched for SSG datastreams, in order
+func ssgContentDirs() []string {
+	if dir, _ := importedSSGDir.Load().(string); dir != "" {
+		return []string{dir, scapContentDir}
+	}
+	return []string{scapContentDir}
+}
+
+// SSGDatastreams lists the SSG datastream files available to OpenSCAP, taking
+// an imported file over an installed one of the same name
+func SSGDatastreams() []string {
+	seen := make(map[string]bool)
+	var files []string
+	for _, dir := range ssgContentDirs() {
+		matches, _ := filepath.Glob(filepath.Join(dir, "ssg-*-ds.xml"))
+		for _, m := range matches {
+			if name := filepath.Base(m); !seen[name] {
+				seen[name] = true
+				files = append(files, m)
+			}
+		}
+	}
+	return files
+}
+
+// ToolsMissingError is returned instead of installing when auto-install is off
+type ToolsMissingError struct {
+	Tool     string   // openscap, docker-bench, oscap-docker
+	Packages []string // exact package or image names
+	Command  string   // command an administrator can run
+}
+
+func (e *ToolsMissingError) Error() string {
+	return fmt.Sprintf("%s tools missing, manual install required: %s", e.Tool, e.Command)
+}
+
+// AsToolsMissing returns the *ToolsMissingError in err's chain, if any
+func AsToolsMissing(err error) (*ToolsMissingError, bool) {
+	var missing *ToolsMissingError
+	ok := errors.As(err, &missing)
+	return missing, ok
+}
+
+// openscapPackages returns the package manager and the packages EnsureInstalled
+// would install for this OS family
+func (s *OpenSCAPScanner) openscapPackages() (string, []string) {
+	switch s.osInfo.Family {
+	case "debian":
+		pkgs := []string{"openscap-scanner", "openscap-common", "ssg-debderived", "ssg-base"}
+		if s.osInfo.Name == "debian" {
+			pkgs = append(pkgs, "ssg-debian")
+		}
+		return "apt-get", pkgs
+	case "rhel":
+		if _, err := s.runner.LookPath("dnf"); err == nil {
+			return "dnf", []string{"openscap-scanner", "scap-security-guide"}
+		}
+		return "yum", []string{"openscap-scanner", "scap-security-guide"}
+	case "suse":
+		return "zypper", []string{"openscap-utils", "scap-security-guide"}
+	default:
+		return "", nil
+	}
+}
+
+// missingOpenSCAP describes the manual install for this host
+func (s *OpenSCAPScanner) missingOpenSCAP() error {
+	manager, pkgs := s.openscapPackages()
+	if manager == "" {
+		return fmt.Errorf("OpenSCAP is not installed and auto_install_tools is off; unsupported OS family: %s (OS: %s)", s.osInfo.Family, s.osInfo.Name)
+	}
+	return &ToolsMissingError{
+		Tool:     "openscap",
+		Packages: pkgs,
+		Command:  manager + " install " + strings.Join(pkgs, " "),
+	}
+}
@@ -0,0 +1,108 @@
+package compliance
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/ignore"
+)
+
+// cveWaiver is a compiled models.ImageCVEWaiver
+type cveWaiver struct {
+	cve           string
+	images        *ignore.Matcher // nil matches every image
+	expires       time.Time       // zero never expires
+	justification string
+}
+
+var cveWaivers atomic.Value // []cveWaiver
+
+// SetCVEWaivers sets the CVEs accepted in image and container scans. Waivers
+// with an invalid date or image pattern are left out and returned as errors.
+func SetCVEWaivers(waivers []models.ImageCVEWaiver) []error {
+	var compiled []cveWaiver
+	var errs []error
+	for _, w := range waivers {
+		cve := strings.ToUpper(strings.TrimSpace(w.CVE))
+		if cve == "" {
+			errs = append(errs, fmt.Errorf("CVE waiver without a cve"))
+			continue
+		}
+		c := cveWaiver{cve: cve, justification: strings.TrimSpace(w.Justification)}
+		if w.Expires != "" {
+			day, err := time.Parse(time.DateOnly, strings.TrimSpace(w.Expires))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("CVE waiver for %s: invalid expires %q, want YYYY-MM-DD", cve, w.Expires))
+				continue
+			}
+			c.expires = day.AddDate(0, 0, 1)
+		}
+		if len(w.Images) > 0 {
+			matcher, patternErrs := ignore.Compile(w.Images)
+			if len(patternErrs) > 0 {
+				for _, err := range patternErrs {
+					errs = append(errs, fmt.Errorf("CVE waiver for %s: %w", cve, err))
+				}
+				continue
+			}
+			c.images = matcher
+		}
+		compiled = append(compiled, c)
+	}
+	cveWaivers.Store(compiled)
+	return errs
+}
+
+// findCVEWaiver returns the waiver in force for a CVE in an image
+func findCVEWaiver(cve string, images []string, now time.Time) (cveWaiver, bool) {
+	waivers, _ := cveWaivers.Load().([]cveWaiver)
+	var values []string
+	for _, image := range images {
+		values = append(values, image, imageRepository(image))
+	}
+	for _, w := range waivers {
+		if w.cve != cve || (!w.expires.IsZero() && !now.Before(w.expires)) {
+			continue
+		}
+		if w.images == nil || w.images.Match(values...) {
+			return w, true
+		}
+	}
+	return cveWaiver{}, false
+}
+
+// applyCVEWaivers marks the CVEs of a scan that a waiver accepts as waived and
+// rescores it. Earlier waivers are undone first, so results reused from the
+// image scan cache pick up waivers that were added or have expired since.
+func applyCVEWaivers(scan *models.ComplianceScan, images ...string) {
+	now := time.Now()
+	for i := range scan.Results {
+		r := &scan.Results[i]
+		if r.Status == "waived" {
+			r.Status = "fail"
+			r.Justification = ""
+			r.WaiverExpires = nil
+			scan.Waived--
+			scan.Failed++
+		}
+		if r.Status != "fail" {
+			continue
+		}
+		w, ok := findCVEWaiver(r.RuleID, images, now)
+		if !ok {
+			continue
+		}
+		r.Status = "waived"
+		r.Justification = w.justification
+		if !w.expires.IsZero() {
+			expires := w.expires
+			r.WaiverExpires = &expires
+		}
+		scan.Failed--
+		scan.Waived++
+	}
+	scoreImageScan(scan)
+}
@@ -0,0 +1,45 @@
+package compliance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyCVEWaivers(t *testing.T) {
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(time.DateOnly)
+	errs := SetCVEWaivers([]models.ImageCVEWaiver{
+		{CVE: "cve-2024-0001", Images: []string{"registry.example.com/app"}, Expires: tomorrow, Justification: "Not reachable: TLS is terminated at the proxy"},
+		{CVE: "CVE-2024-0002", Expires: "2020-01-31", Justification: "Expired"},
+		{CVE: "CVE-2024-0003", Expires: "next week"},
+	})
+	defer SetCVEWaivers(nil)
+	require.Len(t, errs, 1, "the waiver with an invalid date is rejected")
+
+	scan := (&OscapDockerScanner{}).parseImageCveOutput("CVE-2024-0001 - Critical\nCVE-2024-0002 - High\n", "registry.example.com/app:v2")
+	applyCVEWaivers(scan, "registry.example.com/app:v2")
+
+	assert.Equal(t, "waived", scan.Results[0].Status)
+	assert.Equal(t, "Not reachable: TLS is terminated at the proxy", scan.Results[0].Justification)
+	require.NotNil(t, scan.Results[0].WaiverExpires)
+	assert.Equal(t, tomorrow, scan.Results[0].WaiverExpires.AddDate(0, 0, -1).Format(time.DateOnly), "waived through the expiry date")
+	assert.Equal(t, "fail", scan.Results[1].Status, "expired waivers no longer apply")
+	assert.Equal(t, 1, scan.Failed)
+	assert.Equal(t, 1, scan.Waived)
+	assert.Equal(t, 94.4, scan.Score, "only the high CVE costs points")
+
+	// A waiver that stops matching is undone when the scan is reused
+	SetCVEWaivers(nil)
+	applyCVEWaivers(scan, "registry.example.com/app:v2")
+	assert.Equal(t, "fail", scan.Results[0].Status)
+	assert.Empty(t, scan.Results[0].Justification)
+	assert.Equal(t, 2, scan.Failed)
+	assert.Zero(t, scan.Waived)
+
+	SetCVEWaivers([]models.ImageCVEWaiver{{CVE: "CVE-2024-0001", Images: []string{"registry.example.com/app"}}})
+	applyCVEWaivers(scan, "docker.io/library/app:v2")
+	assert.Equal(t, "fail", scan.Results[0].Status, "other images keep the finding")
+}
@@ -7,7 +7,7 @@ import (
 	"strings"
 	"time"
 
-	"patchmon-agent/pkg/models"
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 
 	"github.com/moby/moby/client"
 )
@@ -81,6 +81,18 @@ func (d *Integration) collectContainers(ctx context.Context) ([]models.DockerCon
 			NetworkMode:     c.HostConfig.NetworkMode,
 		}
 
+		// The list only summarizes the host config; the security context
+		// needs a full inspect
+		inspect, err := d.client.ContainerInspect(ctx, c.ID, client.ContainerInspectOptions{})
+		if err != nil {
+			d.logger.WithError(err).WithField("container", name).Debug("Failed to inspect container")
+		} else {
+			container.Security = containerSecurity(inspect.Container)
+			if status == "running" {
+				container.ImagePin = d.imagePin(ctx, inspect.Container)
+			}
+		}
+
 		result = append(result, container)
 	}
 
@@ -3,37 +3,64 @@ package docker
 import (
 	"context"
 	"fmt"
+	"net/netip"
 	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/tracing"
 	"patchmon-agent/internal/utils"
-	"patchmon-agent/pkg/models"
 
 	"github.com/moby/moby/api/types/container"
 	"github.com/moby/moby/client"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
 	dockerSocketPath = "/var/run/docker.sock"
 	integrationName  = "docker"
+
+	// podmanSocketPath is the rootful Podman API socket; rootless users get
+	// one each under their runtime directory (podman.socket units)
+	podmanSocketPath     = "/run/podman/podman.sock"
+	rootlessPodmanSocket = "/run/user/*/podman/podman.sock"
+
+	runtimeDocker = "docker"
+	runtimePodman = "podman"
 )
 
-// Integration implements the Integration interface for Docker
+// Integration implements the Integration interface for Docker. It also
+// inventories Podman through its Docker-compatible API: each Podman engine
+// found (rootful and one per rootless user) is an Integration of its own in
+// engines, and its containers, images, volumes and networks are merged into
+// the same data, tagged with the engine.
 type Integration struct {
 	client         *client.Client
 	logger         *logrus.Logger
 	monitoring     bool
 	monitoringMu   sync.RWMutex
 	stopMonitoring context.CancelFunc
+
+	socket  string // API socket; empty for DOCKER_HOST or the default
+	engine  models.EngineRef
+	engines []*Integration
+
+	digestCachePath string
+	digestMaxAge    time.Duration
+	digests         *digestCache // Set during Collect when the registry check is on
 }
 
 // New creates a new Docker integration
 func New(logger *logrus.Logger) *Integration {
 	return &Integration{
 		logger: logger,
+		engine: models.EngineRef{Runtime: runtimeDocker},
 	}
 }
 
@@ -52,16 +79,40 @@ func (d *Integration) SupportsRealtime() bool {
 	return true
 }
 
-// IsAvailable checks if Docker is available on this system
+// IsAvailable checks if Docker or Podman is available on this system
 func (d *Integration) IsAvailable() bool {
-	// Check if Docker socket exists
-	if _, err := os.Stat(dockerSocketPath); os.IsNotExist(err) {
-		d.logger.Debug("Docker socket not found")
+	dockerAvailable := d.connect()
+	for _, e := range d.engines {
+		_ = e.Close()
+	}
+	d.engines = nil
+	for _, sock := range findPodmanSockets("/") {
+		if dockerAvailable && sameFile(sock.path, d.socketPath()) {
+			continue // podman-docker links the Docker socket to Podman's
+		}
+		e := &Integration{logger: d.logger, socket: sock.path, engine: models.EngineRef{Runtime: runtimePodman, RootlessUser: sock.user}}
+		if e.connect() {
+			d.engines = append(d.engines, e)
+		}
+	}
+	return dockerAvailable || len(d.engines) > 0
+}
+
+// connect pings this engine's daemon and keeps the client when it answers
+func (d *Integration) connect() bool {
+	// Check if the socket exists
+	if _, err := os.Stat(d.socketPath()); os.IsNotExist(err) {
+		d.logger.WithField("socket", d.socketPath()).Debug("Container engine socket not found")
 		return false
 	}
+	if d.socket == "" {
+		if target, err := filepath.EvalSymlinks(dockerSocketPath); err == nil && strings.Contains(target, "podman") {
+			d.engine.Runtime = runtimePodman
+		}
+	}
 
 	// Try to create a Docker client and ping the daemon
-	cli, err := client.New(client.FromEnv)
+	cli, err := d.newClient()
 	if err != nil {
 		d.logger.WithError(err).Debug("Failed to create Docker client")
 		return false
@@ -89,11 +140,92 @@ func (d *Integration) IsAvailable() bool {
 	return true
 }
 
+// socketPath returns the path of the engine's API socket
+func (d *Integration) socketPath() string {
+	if d.socket != "" {
+		return d.socket
+	}
+	return dockerSocketPath
+}
+
+// newClient creates an API client for the engine
+func (d *Integration) newClient() (*client.Client, error) {
+	if d.socket != "" {
+		return client.New(client.FromEnv, client.WithHost("unix://"+d.socket))
+	}
+	return client.New(client.FromEnv)
+}
+
+// podmanSocket is a Podman API socket and, for a rootless engine, its owner
+type podmanSocket struct {
+	path string
+	user string
+}
+
+// findPodmanSockets lists the Podman API sockets under root: the rootful
+// one, then each rootless user's
+func findPodmanSockets(root string) []podmanSocket {
+	var sockets []podmanSocket
+	if _, err := os.Stat(filepath.Join(root, podmanSocketPath)); err == nil {
+		sockets = append(sockets, podmanSocket{path: filepath.Join(root, podmanSocketPath)})
+	}
+	matches, _ := filepath.Glob(filepath.Join(root, rootlessPodmanSocket))
+	for _, path := range matches {
+		// /run/user/<uid>/podman/podman.sock
+		uid := filepath.Base(filepath.Dir(filepath.Dir(path)))
+		name := uid
+		if u, err := user.LookupId(uid); err == nil {
+			name = u.Username
+		}
+		sockets = append(sockets, podmanSocket{path: path, user: name})
+	}
+	return sockets
+}
+
+// EngineSocket returns the API socket of the engine an image or container was
+// inventoried from: the rootful or the owner's rootless Podman socket, or ""
+// for Docker, which is reached through DOCKER_HOST or the default socket
+func EngineSocket(engine models.EngineRef) string {
+	return engineSocket("/", engine)
+}
+
+func engineSocket(root string, engine models.EngineRef) string {
+	if engine.Runtime != runtimePodman {
+		return ""
+	}
+	for _, sock := range findPodmanSockets(root) {
+		if sock.user == engine.RootlessUser {
+			return sock.path
+		}
+	}
+	// podman-docker serves Podman on the Docker socket
+	return ""
+}
+
+// sameFile reports whether two paths resolve to the same file
+func sameFile(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	return err == nil && os.SameFile(ai, bi)
+}
+
+// connected returns the engines with a client: Docker first, then Podman's
+func (d *Integration) connected() []*Integration {
+	var engines []*Integration
+	if d.client != nil {
+		engines = append(engines, d)
+	}
+	return append(engines, d.engines...)
+}
+
 // Collect gathers Docker data
 func (d *Integration) Collect(ctx context.Context) (*models.IntegrationData, error) {
 	startTime := time.Now()
 
-	if d.client == nil {
+	if len(d.connected()) == 0 {
 		if !d.IsAvailable() {
 			return nil, fmt.Errorf("docker is not available")
 		}
@@ -110,48 +242,19 @@ func (d *Integration) Collect(ctx context.Context) (*models.IntegrationData, err
 		Updates:    make([]models.DockerImageUpdate, 0),
 	}
 
-	// Collect containers
-	containers, err := d.collectContainers(ctx)
-	if err != nil {
-		d.logger.WithError(err).Warn("Failed to collect containers")
-	} else {
-		dockerData.Containers = containers
-		d.logger.WithField("count", len(containers)).Info("Collected containers")
+	var digests *digestCache
+	if d.digestCachePath != "" && d.digestMaxAge > 0 {
+		digests = loadDigestCache(d.digestCachePath, d.digestMaxAge)
 	}
-
-	// Collect images
-	images, err := d.collectImages(ctx)
-	if err != nil {
-		d.logger.WithError(err).Warn("Failed to collect images")
-	} else {
-		dockerData.Images = images
-		d.logger.WithField("count", len(images)).Info("Collected images")
+	for _, e := range d.connected() {
+		e.digests = digests
+		e.collectEngine(ctx, dockerData)
+		e.digests = nil
 	}
-
-	// Collect volumes
-	volumes, err := d.collectVolumes(ctx)
-	if err != nil {
-		d.logger.WithError(err).Warn("Failed to collect volumes")
-	} else {
-		dockerData.Volumes = volumes
-		d.logger.WithField("count", len(volumes)).Info("Collected volumes")
-	}
-
-	// Collect networks
-	networks, err := d.collectNetworks(ctx)
-	if err != nil {
-		d.logger.WithError(err).Warn("Failed to collect networks")
-	} else {
-		dockerData.Networks = networks
-		d.logger.WithField("count", len(networks)).Info("Collected networks")
-	}
-
-	// Collect daemon info
-	daemonInfo, err := d.collectDaemonInfo(ctx)
-	if err != nil {
-		d.logger.WithError(err).Warn("Failed to collect daemon info")
-	} else {
-		dockerData.DaemonInfo = daemonInfo
+	if digests != nil {
+		if err := digests.save(d.digestCachePath); err != nil {
+			d.logger.WithError(err).Debug("Failed to save registry digest cache")
+		}
 	}
 
 	// Check for updates (optional, can be slow)
@@ -175,14 +278,99 @@ func (d *Integration) Collect(ctx context.Context) (*models.IntegrationData, err
 	}, nil
 }
 
-// Close closes the Docker client
+// Close closes the Docker client and those of the Podman engines
 func (d *Integration) Close() error {
+	for _, e := range d.engines {
+		_ = e.Close()
+	}
 	if d.client != nil {
 		return d.client.Close()
 	}
 	return nil
 }
 
+// collectEngine adds the containers, images, volumes, networks and daemon
+// info of this engine to data, tagged with the engine
+func (d *Integration) collectEngine(ctx context.Context, data *models.DockerData) {
+	ctx, span := tracing.Start(ctx, "docker.engine",
+		attribute.String("docker.runtime", d.engine.Runtime),
+		attribute.String("docker.rootless_user", d.engine.RootlessUser))
+	defer span.End()
+	logger := d.logger.WithField("runtime", d.engine.Runtime)
+	if d.engine.RootlessUser != "" {
+		logger = logger.WithField("rootless_user", d.engine.RootlessUser)
+	}
+
+	// Collect containers
+	containers, err := collectSpan(ctx, "docker.containers", d.collectContainers)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to collect containers")
+	} else {
+		for i := range containers {
+			containers[i].EngineRef = d.engine
+		}
+		data.Containers = append(data.Containers, containers...)
+		logger.WithField("count", len(containers)).Info("Collected containers")
+	}
+
+	// Collect images
+	images, err := collectSpan(ctx, "docker.images", d.collectImages)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to collect images")
+	} else {
+		for i := range images {
+			images[i].EngineRef = d.engine
+		}
+		data.Images = append(data.Images, images...)
+		logger.WithField("count", len(images)).Info("Collected images")
+	}
+
+	// Collect volumes
+	volumes, err := collectSpan(ctx, "docker.volumes", d.collectVolumes)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to collect volumes")
+	} else {
+		for i := range volumes {
+			volumes[i].EngineRef = d.engine
+		}
+		data.Volumes = append(data.Volumes, volumes...)
+		logger.WithField("count", len(volumes)).Info("Collected volumes")
+	}
+
+	// Collect networks
+	networks, err := collectSpan(ctx, "docker.networks", d.collectNetworks)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to collect networks")
+	} else {
+		for i := range networks {
+			networks[i].EngineRef = d.engine
+		}
+		data.Networks = append(data.Networks, networks...)
+		logger.WithField("count", len(networks)).Info("Collected networks")
+	}
+
+	// Collect daemon info
+	daemonInfo, err := d.collectDaemonInfo(ctx)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to collect daemon info")
+	} else {
+		daemonInfo.EngineRef = d.engine
+		if data.DaemonInfo == nil {
+			data.DaemonInfo = daemonInfo
+		}
+		data.Engines = append(data.Engines, *daemonInfo)
+	}
+}
+
+// collectSpan runs one collection step of an engine under its own span
+func collectSpan[T any](ctx context.Context, name string, collect func(context.Context) ([]T, error)) ([]T, error) {
+	ctx, span := tracing.Start(ctx, name)
+	items, err := collect(ctx)
+	span.SetAttributes(attribute.Int("docker.count", len(items)))
+	tracing.End(span, err)
+	return items, err
+}
+
 // collectDaemonInfo collects Docker daemon information
 func (d *Integration) collectDaemonInfo(ctx context.Context) (*models.DockerDaemonInfo, error) {
 	infoResult, err := d.client.Info(ctx, client.InfoOptions{})
@@ -331,7 +519,11 @@ func convertPorts(ports []container.PortSummary) map[string]string {
 	for _, port := range ports {
 		if port.PublicPort > 0 {
 			key := fmt.Sprintf("%d/%s", port.PrivatePort, port.Type)
-			value := fmt.Sprintf("%s:%d", port.IP, port.PublicPort)
+			// AddrPort brackets IPv6 bindings ("[::]:8080")
+			value := strconv.Itoa(int(port.PublicPort))
+			if port.IP.IsValid() {
+				value = netip.AddrPortFrom(port.IP, port.PublicPort).String()
+			}
 			portMap[key] = value
 		}
 	}
@@ -0,0 +1,38 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindPodmanSockets(t *testing.T) {
+	root := t.TempDir()
+	for _, path := range []string{
+		"run/podman/podman.sock",
+		"run/user/0/podman/podman.sock",
+		"run/user/424242/podman/podman.sock",
+		"run/user/1000/bus", // A user without podman.socket
+	} {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []podmanSocket{
+		{path: filepath.Join(root, "run/podman/podman.sock")},
+		{path: filepath.Join(root, "run/user/0/podman/podman.sock"), user: "root"},
+		{path: filepath.Join(root, "run/user/424242/podman/podman.sock"), user: "424242"}, // No such user
+	}
+	if got := findPodmanSockets(root); !reflect.DeepEqual(got, want) {
+		t.Errorf("findPodmanSockets() = %+v, want %+v", got, want)
+	}
+	if got := findPodmanSockets(t.TempDir()); len(got) != 0 {
+		t.Errorf("findPodmanSockets() without Podman = %+v, want none", got)
+	}
+}
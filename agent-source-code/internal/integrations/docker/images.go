@@ -6,7 +6,7 @@ import (
 	"strings"
 	"time"
 
-	"patchmon-agent/pkg/models"
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 
 	"github.com/moby/moby/client"
 )
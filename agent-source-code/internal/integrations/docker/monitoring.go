@@ -8,7 +8,7 @@ import (
 	"os"
 	"time"
 
-	"patchmon-agent/pkg/models"
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 
 	"github.com/moby/moby/api/types/events"
 	"github.com/moby/moby/client"
@@ -36,8 +36,11 @@ func (d *Integration) StartMonitoring(ctx context.Context, eventChan chan<- inte
 	d.monitoring = true
 	d.monitoringMu.Unlock()
 
-	if d.client == nil {
+	if len(d.connected()) == 0 {
 		if !d.IsAvailable() {
+			d.monitoringMu.Lock()
+			d.monitoring = false
+			d.monitoringMu.Unlock()
 			return fmt.Errorf("docker is not available")
 		}
 	}
@@ -48,8 +51,15 @@ func (d *Integration) StartMonitoring(ctx context.Context, eventChan chan<- inte
 
 	d.logger.Info("Starting Docker event monitoring...")
 
-	// Start the monitoring loop in a goroutine with reconnection logic
-	go d.monitoringLoop(monitorCtx, eventChan)
+	// Start a monitoring loop per engine in a goroutine with reconnection
+	// logic. The Podman engines stop with this context.
+	if d.client != nil {
+		go d.monitoringLoop(monitorCtx, eventChan)
+	}
+	for _, e := range d.engines {
+		e.monitoring = true
+		go e.monitoringLoop(monitorCtx, eventChan)
+	}
 
 	return nil
 }
@@ -304,9 +314,11 @@ func (d *Integration) handleContainerEvent(event events.Message) interface{} {
 		Image:       image,
 		Status:      status,
 		Timestamp:   time.Unix(event.Time, 0),
+		EngineRef:   d.engine,
 	}
 
 	d.logger.WithFields(logrus.Fields{
+		"runtime":      d.engine.Runtime,
 		"type":         eventType,
 		"container_id": containerID[:12], // Short ID
 		"name":         containerName,
@@ -340,7 +352,7 @@ func mapActionToStatus(action string) string {
 // Requires multiple consecutive successful pings to ensure Docker is stable
 func (d *Integration) waitForDockerReady(ctx context.Context) bool {
 	// Check if socket exists first (fast check)
-	if _, err := os.Stat(dockerSocketPath); os.IsNotExist(err) {
+	if _, err := os.Stat(d.socketPath()); os.IsNotExist(err) {
 		d.logger.Debug("Docker socket not found, waiting...")
 		// Wait for socket to appear
 		ticker := time.NewTicker(dockerPingInterval)
@@ -350,7 +362,7 @@ func (d *Integration) waitForDockerReady(ctx context.Context) bool {
 			case <-ctx.Done():
 				return false
 			case <-ticker.C:
-				if _, err := os.Stat(dockerSocketPath); err == nil {
+				if _, err := os.Stat(d.socketPath()); err == nil {
 					// Socket exists, break out of for loop to try ping
 					goto pingCheck
 				}
@@ -389,7 +401,7 @@ func (d *Integration) verifyDockerStable(ctx context.Context) bool {
 	if d.client != nil {
 		cli = d.client
 	} else {
-		cli, err = client.New(client.FromEnv)
+		cli, err = d.newClient()
 		if err != nil {
 			return false
 		}
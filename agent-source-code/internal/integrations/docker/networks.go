@@ -4,7 +4,7 @@ import (
 	"context"
 	"fmt"
 
-	"patchmon-agent/pkg/models"
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 
 	"github.com/moby/moby/client"
 )
@@ -0,0 +1,182 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/utils"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/client"
+)
+
+// registryLookupTimeout bounds asking the registry about one tag
+const registryLookupTimeout = 15 * time.Second
+
+// imageIDPattern matches a container created from an image ID rather than a name
+var imageIDPattern = regexp.MustCompile(`^(sha256:)?[0-9a-f]{12,64}$`)
+
+// SetDigestCheck turns on asking registries what running containers' tags
+// point to. Answers are cached in cachePath for maxAge; zero turns the
+// check off.
+func (d *Integration) SetDigestCheck(cachePath string, maxAge time.Duration) {
+	d.digestCachePath = cachePath
+	d.digestMaxAge = maxAge
+}
+
+// digestLookup is one registry answer for a tag
+type digestLookup struct {
+	Digest    string    `json:"digest,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// digestCache maps a tag reference to what the registry last said about it.
+// Failed lookups are cached too, so a private registry the daemon can't log
+// in to isn't asked on every report.
+type digestCache struct {
+	Entries map[string]digestLookup `json:"entries"`
+	maxAge  time.Duration
+}
+
+// loadDigestCache reads the cache file; any error yields an empty cache
+func loadDigestCache(path string, maxAge time.Duration) *digestCache {
+	c := &digestCache{Entries: make(map[string]digestLookup), maxAge: maxAge}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	if err := json.Unmarshal(data, c); err != nil || c.Entries == nil {
+		c.Entries = make(map[string]digestLookup)
+	}
+	return c
+}
+
+// save writes the digests still younger than maxAge
+func (c *digestCache) save(path string) error {
+	for ref, e := range c.Entries {
+		if time.Since(e.CheckedAt) > c.maxAge {
+			delete(c.Entries, ref)
+		}
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(path, data, 0600)
+}
+
+// lookup returns the cached answer for ref, or asks the registry through fetch
+func (c *digestCache) lookup(ref string, fetch func() (string, error)) digestLookup {
+	if e, ok := c.Entries[ref]; ok && time.Since(e.CheckedAt) < c.maxAge {
+		return e
+	}
+	e := digestLookup{CheckedAt: time.Now().UTC()}
+	digest, err := fetch()
+	if err != nil {
+		e.Error = err.Error()
+	} else {
+		e.Digest = digest
+	}
+	c.Entries[ref] = e
+	return e
+}
+
+// imagePin describes how a running container's image was referenced and,
+// when the registry check is on, compares a tag with the registry
+func (d *Integration) imagePin(ctx context.Context, c container.InspectResponse) *models.DockerImagePin {
+	if c.Config == nil {
+		return nil
+	}
+	var repoDigests []string
+	if img, err := d.client.ImageInspect(ctx, c.Image); err == nil {
+		repoDigests = img.RepoDigests
+	}
+	var fetch func(ref string) (string, error)
+	if d.digests != nil {
+		fetch = func(ref string) (string, error) {
+			lookupCtx, cancel := context.WithTimeout(ctx, registryLookupTimeout)
+			defer cancel()
+			res, err := d.client.DistributionInspect(lookupCtx, ref, client.DistributionInspectOptions{})
+			if err != nil {
+				return "", err
+			}
+			return res.Descriptor.Digest.String(), nil
+		}
+	}
+	return comparePin(c.Config.Image, c.Image, repoDigests, d.digests, fetch)
+}
+
+// comparePin builds the pin for a container created from ref that runs image
+// imageID, whose registry digests are repoDigests (repo@sha256:...). fetch
+// asks the registry for a tag's digest; with a nil cache the registry isn't
+// asked.
+func comparePin(ref, imageID string, repoDigests []string, cache *digestCache, fetch func(ref string) (string, error)) *models.DockerImagePin {
+	pin := &models.DockerImagePin{Reference: ref, PinnedBy: models.PinnedByTag}
+	if _, digest, ok := strings.Cut(ref, "@"); ok {
+		pin.PinnedBy = models.PinnedByDigest
+		pin.LocalDigest = digest
+		return pin
+	}
+	if imageIDPattern.MatchString(ref) && strings.HasPrefix(strings.TrimPrefix(imageID, "sha256:"), strings.TrimPrefix(ref, "sha256:")) {
+		pin.PinnedBy = models.PinnedByID
+		return pin
+	}
+
+	repository, tag := parseImageName(ref)
+	repo := familiarRepository(repository)
+	var local []string
+	for _, rd := range repoDigests {
+		if name, digest, ok := strings.Cut(rd, "@"); ok && familiarRepository(name) == repo {
+			local = append(local, digest)
+		}
+	}
+	if len(local) == 0 {
+		pin.Status = models.PinLocal
+		return pin
+	}
+	pin.LocalDigest = local[0]
+	if cache == nil || fetch == nil {
+		return pin
+	}
+
+	key := repo + ":" + tag
+	answer := cache.lookup(key, func() (string, error) { return fetch(ref) })
+	checkedAt := answer.CheckedAt
+	pin.CheckedAt = &checkedAt
+	if answer.Error != "" {
+		pin.Status = models.PinUnknown
+		pin.Error = answer.Error
+		return pin
+	}
+	pin.RegistryDigest = answer.Digest
+	pin.Status = models.PinOutdated
+	for _, digest := range local {
+		if digest == answer.Digest {
+			pin.Status = models.PinCurrent
+			pin.LocalDigest = digest
+		}
+	}
+	return pin
+}
+
+// familiarRepository drops the implied Docker Hub registry and library
+// namespace, so docker.io/library/nginx (as Podman records it) and nginx
+// (as Docker does) compare equal
+func familiarRepository(repository string) string {
+	for _, registry := range []string{"docker.io/", "index.docker.io/", "registry-1.docker.io/"} {
+		if rest, ok := strings.CutPrefix(repository, registry); ok {
+			repository = rest
+			break
+		}
+	}
+	if rest, ok := strings.CutPrefix(repository, "library/"); ok && !strings.Contains(rest, "/") {
+		return rest
+	}
+	return repository
+}
@@ -0,0 +1,111 @@
+package docker
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+const (
+	imageID     = "sha256:4f5e8a1b2c9d0e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f"
+	localDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	newDigest   = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+)
+
+func TestComparePin(t *testing.T) {
+	registry := map[string]string{
+		"nginx":                      newDigest,
+		"ghcr.io/example/web:1":      localDigest,
+		"docker.io/library/redis:7":  localDigest,
+		"registry.internal/app:prod": "",
+	}
+	fetch := func(ref string) (string, error) {
+		digest, ok := registry[ref]
+		if !ok || digest == "" {
+			return "", errors.New("unauthorized")
+		}
+		return digest, nil
+	}
+
+	for _, tc := range []struct {
+		ref         string
+		repoDigests []string
+		pinnedBy    string
+		status      string
+		registry    string
+	}{
+		{"nginx@" + localDigest, []string{"nginx@" + localDigest}, models.PinnedByDigest, "", ""},
+		{"nginx:1.27@" + localDigest, nil, models.PinnedByDigest, "", ""},
+		{"4f5e8a1b2c9d", nil, models.PinnedByID, "", ""},
+		{"nginx", []string{"nginx@" + localDigest}, models.PinnedByTag, models.PinOutdated, newDigest},
+		{"ghcr.io/example/web:1", []string{"ghcr.io/example/web@" + localDigest}, models.PinnedByTag, models.PinCurrent, localDigest},
+		// Podman records Docker Hub images with their full name
+		{"docker.io/library/redis:7", []string{"docker.io/library/redis@" + localDigest}, models.PinnedByTag, models.PinCurrent, localDigest},
+		{"myapp:dev", nil, models.PinnedByTag, models.PinLocal, ""},
+		{"myapp:dev", []string{"nginx@" + localDigest}, models.PinnedByTag, models.PinLocal, ""},
+		{"registry.internal/app:prod", []string{"registry.internal/app@" + localDigest}, models.PinnedByTag, models.PinUnknown, ""},
+	} {
+		cache := &digestCache{Entries: map[string]digestLookup{}, maxAge: time.Hour}
+		pin := comparePin(tc.ref, imageID, tc.repoDigests, cache, fetch)
+		if pin.PinnedBy != tc.pinnedBy || pin.Status != tc.status || pin.RegistryDigest != tc.registry {
+			t.Errorf("comparePin(%q) = %+v", tc.ref, pin)
+		}
+		if tc.status == models.PinUnknown && pin.Error == "" {
+			t.Errorf("comparePin(%q) has no error", tc.ref)
+		}
+	}
+
+	// Without the registry check a tag only gets its local digest
+	pin := comparePin("nginx:latest", imageID, []string{"nginx@" + localDigest}, nil, nil)
+	if pin.Status != "" || pin.LocalDigest != localDigest || pin.CheckedAt != nil {
+		t.Errorf("unchecked pin = %+v", pin)
+	}
+}
+
+func TestDigestCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "digests.json")
+	cache := loadDigestCache(path, time.Hour)
+	calls := 0
+	fetch := func() (string, error) {
+		calls++
+		return "", errors.New("no basic auth credentials")
+	}
+
+	// Failures are cached like answers
+	cache.lookup("registry.internal/app:prod", fetch)
+	cache.lookup("registry.internal/app:prod", fetch)
+	if calls != 1 {
+		t.Fatalf("registry asked %d times, want 1", calls)
+	}
+	cache.Entries["nginx:latest"] = digestLookup{Digest: newDigest, CheckedAt: time.Now().Add(-2 * time.Hour)}
+	if err := cache.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := loadDigestCache(path, time.Hour)
+	if _, ok := reloaded.Entries["nginx:latest"]; ok {
+		t.Error("expired entry was saved")
+	}
+	if e := reloaded.lookup("registry.internal/app:prod", fetch); calls != 1 || e.Error == "" {
+		t.Errorf("cached failure not reused: %+v", e)
+	}
+}
+
+func TestFamiliarRepository(t *testing.T) {
+	for in, want := range map[string]string{
+		"nginx":                         "nginx",
+		"docker.io/library/nginx":       "nginx",
+		"index.docker.io/library/nginx": "nginx",
+		"library/nginx":                 "nginx",
+		"docker.io/grafana/grafana":     "grafana/grafana",
+		"ghcr.io/library/tool":          "ghcr.io/library/tool",
+		"localhost:5000/app":            "localhost:5000/app",
+	} {
+		if got := familiarRepository(in); got != want {
+			t.Errorf("familiarRepository(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/image"
+	"github.com/moby/moby/api/types/network"
+	"github.com/moby/moby/client"
+)
+
+// DefaultPruneContainerAge is how long ago a stopped container must have been
+// created to be pruned when neither the command nor the config say
+const DefaultPruneContainerAge = 7 * 24 * time.Hour
+
+// predefinedNetworks are created by the engine and can't be removed
+var predefinedNetworks = map[string]bool{
+	"bridge": true,
+	"host":   true,
+	"none":   true,
+	"podman": true,
+}
+
+// pruneSet is what a prune removes from one engine
+type pruneSet struct {
+	containers []models.DockerPruneItem
+	images     []models.DockerPruneItem
+	networks   []models.DockerPruneItem
+}
+
+// Prune removes, from every engine, stopped containers created before
+// containerAge ago, then networks no remaining container uses, then dangling
+// images. A dry run lists them and the space they take up instead. Errors
+// from one engine or step are recorded in the result and the rest carry on.
+func (d *Integration) Prune(ctx context.Context, containerAge time.Duration, dryRun bool) *models.DockerPruneResult {
+	result := &models.DockerPruneResult{
+		DryRun:           dryRun,
+		ContainerAgeDays: int(containerAge / (24 * time.Hour)),
+		Containers:       make([]models.DockerPruneItem, 0),
+		Images:           make([]models.DockerPruneItem, 0),
+		Networks:         make([]models.DockerPruneItem, 0),
+		StartedAt:        time.Now().UTC(),
+	}
+	for _, e := range d.connected() {
+		set, err := e.pruneCandidates(ctx, time.Now().Add(-containerAge))
+		if err != nil {
+			result.Errors = append(result.Errors, e.errorf("%v", err))
+			continue
+		}
+		if dryRun {
+			result.Containers = append(result.Containers, set.containers...)
+			result.Images = append(result.Images, set.images...)
+			result.Networks = append(result.Networks, set.networks...)
+			for _, items := range [][]models.DockerPruneItem{set.containers, set.images} {
+				for _, item := range items {
+					result.ReclaimedBytes += item.SizeBytes
+				}
+			}
+			continue
+		}
+		e.prune(ctx, containerAge, set, result)
+	}
+	result.CompletedAt = time.Now().UTC()
+	return result
+}
+
+// errorf formats an error message prefixed with the engine, when it isn't
+// the Docker daemon
+func (d *Integration) errorf(format string, args ...any) string {
+	msg := fmt.Sprintf(format, args...)
+	switch {
+	case d.engine.RootlessUser != "":
+		return fmt.Sprintf("%s (%s): %s", d.engine.Runtime, d.engine.RootlessUser, msg)
+	case d.engine.Runtime != runtimeDocker:
+		return d.engine.Runtime + ": " + msg
+	}
+	return msg
+}
+
+// pruneCandidates lists what a prune of this engine would remove
+func (d *Integration) pruneCandidates(ctx context.Context, cutoff time.Time) (pruneSet, error) {
+	containers, err := d.client.ContainerList(ctx, client.ContainerListOptions{All: true, Size: true})
+	if err != nil {
+		return pruneSet{}, fmt.Errorf("failed to list containers: %w", err)
+	}
+	images, err := d.client.ImageList(ctx, client.ImageListOptions{Filters: make(client.Filters).Add("dangling", "true")})
+	if err != nil {
+		return pruneSet{}, fmt.Errorf("failed to list images: %w", err)
+	}
+	networks, err := d.client.NetworkList(ctx, client.NetworkListOptions{})
+	if err != nil {
+		return pruneSet{}, fmt.Errorf("failed to list networks: %w", err)
+	}
+	set := selectPrunable(containers.Items, images.Items, networks.Items, cutoff)
+	for _, items := range [][]models.DockerPruneItem{set.containers, set.images, set.networks} {
+		for i := range items {
+			items[i].EngineRef = d.engine
+		}
+	}
+	return set, nil
+}
+
+// selectPrunable picks the stopped containers created before cutoff, the
+// networks and dangling images nothing but those containers uses
+func selectPrunable(containers []container.Summary, images []image.Summary, networks []network.Summary, cutoff time.Time) pruneSet {
+	set := pruneSet{
+		containers: make([]models.DockerPruneItem, 0),
+		images:     make([]models.DockerPruneItem, 0),
+		networks:   make([]models.DockerPruneItem, 0),
+	}
+	usedImages := make(map[string]bool)
+	usedNetworks := make(map[string]bool)
+	for _, c := range containers {
+		stopped := c.State == container.StateExited || c.State == container.StateCreated || c.State == container.StateDead
+		if stopped && time.Unix(c.Created, 0).Before(cutoff) {
+			name := ""
+			if len(c.Names) > 0 {
+				name = strings.TrimPrefix(c.Names[0], "/")
+			}
+			set.containers = append(set.containers, models.DockerPruneItem{ID: c.ID, Name: name, SizeBytes: c.SizeRw})
+			continue
+		}
+		usedImages[c.ImageID] = true
+		if c.NetworkSettings != nil {
+			for name, ep := range c.NetworkSettings.Networks {
+				usedNetworks[name] = true
+				if ep != nil {
+					usedNetworks[ep.NetworkID] = true
+				}
+			}
+		}
+	}
+	for _, img := range images {
+		if !usedImages[img.ID] {
+			set.images = append(set.images, models.DockerPruneItem{ID: img.ID, SizeBytes: img.Size})
+		}
+	}
+	for _, n := range networks {
+		if predefinedNetworks[n.Name] || n.Scope == "swarm" || n.Ingress || usedNetworks[n.Name] || usedNetworks[n.ID] {
+			continue
+		}
+		set.networks = append(set.networks, models.DockerPruneItem{ID: n.ID, Name: n.Name})
+	}
+	return set
+}
+
+// prune runs the engine's own prunes, containers first so the networks and
+// images they held are freed, and adds what was removed to result
+func (d *Integration) prune(ctx context.Context, containerAge time.Duration, set pruneSet, result *models.DockerPruneResult) {
+	listed := make(map[string]models.DockerPruneItem)
+	for _, items := range [][]models.DockerPruneItem{set.containers, set.images, set.networks} {
+		for _, item := range items {
+			listed[item.ID] = item
+			if item.Name != "" {
+				listed[item.Name] = item
+			}
+		}
+	}
+	removed := func(key string) models.DockerPruneItem {
+		if item, ok := listed[key]; ok {
+			return item
+		}
+		return models.DockerPruneItem{ID: key, EngineRef: d.engine}
+	}
+
+	containers, err := d.client.ContainerPrune(ctx, client.ContainerPruneOptions{
+		Filters: make(client.Filters).Add("until", containerAge.String()),
+	})
+	if err != nil {
+		result.Errors = append(result.Errors, d.errorf("container prune failed: %v", err))
+	} else {
+		for _, id := range containers.Report.ContainersDeleted {
+			result.Containers = append(result.Containers, removed(id))
+		}
+		result.ReclaimedBytes += int64(containers.Report.SpaceReclaimed)
+	}
+
+	networks, err := d.client.NetworkPrune(ctx, client.NetworkPruneOptions{})
+	if err != nil {
+		result.Errors = append(result.Errors, d.errorf("network prune failed: %v", err))
+	} else {
+		for _, name := range networks.Report.NetworksDeleted {
+			result.Networks = append(result.Networks, removed(name))
+		}
+	}
+
+	images, err := d.client.ImagePrune(ctx, client.ImagePruneOptions{
+		Filters: make(client.Filters).Add("dangling", "true"),
+	})
+	if err != nil {
+		result.Errors = append(result.Errors, d.errorf("image prune failed: %v", err))
+	} else {
+		for _, deleted := range images.Report.ImagesDeleted {
+			// Each deleted layer has an entry too; only report the images
+			if item, ok := listed[deleted.Deleted]; ok {
+				result.Images = append(result.Images, item)
+			}
+		}
+		result.ReclaimedBytes += int64(images.Report.SpaceReclaimed)
+	}
+}
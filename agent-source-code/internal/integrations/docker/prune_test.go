@@ -0,0 +1,59 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/image"
+	"github.com/moby/moby/api/types/network"
+)
+
+func TestSelectPrunable(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-30 * 24 * time.Hour).Unix()
+	recent := now.Add(-time.Hour).Unix()
+	containers := []container.Summary{
+		{ID: "c-old-exited", Names: []string{"/batch-job"}, State: container.StateExited, Created: old, SizeRw: 1000, ImageID: "img-dangling-used",
+			NetworkSettings: &container.NetworkSettingsSummary{Networks: map[string]*network.EndpointSettings{"jobs": {NetworkID: "n-jobs"}}}},
+		{ID: "c-recent-exited", Names: []string{"/migrate"}, State: container.StateExited, Created: recent, ImageID: "img-dangling-kept"},
+		{ID: "c-running", Names: []string{"/web"}, State: container.StateRunning, Created: old, ImageID: "img-web",
+			NetworkSettings: &container.NetworkSettingsSummary{Networks: map[string]*network.EndpointSettings{"frontend": {NetworkID: "n-frontend"}}}},
+		{ID: "c-old-created", Names: []string{"/never-started"}, State: container.StateCreated, Created: old, SizeRw: 0},
+	}
+	images := []image.Summary{
+		{ID: "img-dangling-used", Size: 5000},
+		{ID: "img-dangling-kept", Size: 7000},
+		{ID: "img-dangling-free", Size: 9000},
+	}
+	networks := []network.Summary{
+		{Network: network.Network{ID: "n-bridge", Name: "bridge"}},
+		{Network: network.Network{ID: "n-frontend", Name: "frontend"}},
+		{Network: network.Network{ID: "n-jobs", Name: "jobs"}},
+		{Network: network.Network{ID: "n-old", Name: "old_default"}},
+		{Network: network.Network{ID: "n-ingress", Name: "ingress", Scope: "swarm", Ingress: true}},
+	}
+
+	got := selectPrunable(containers, images, networks, now.Add(-7*24*time.Hour))
+	want := pruneSet{
+		containers: []models.DockerPruneItem{
+			{ID: "c-old-exited", Name: "batch-job", SizeBytes: 1000},
+			{ID: "c-old-created", Name: "never-started"},
+		},
+		// Images and networks of the containers being pruned go with them
+		images: []models.DockerPruneItem{
+			{ID: "img-dangling-used", SizeBytes: 5000},
+			{ID: "img-dangling-free", SizeBytes: 9000},
+		},
+		networks: []models.DockerPruneItem{
+			{ID: "n-jobs", Name: "jobs"},
+			{ID: "n-old", Name: "old_default"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("selectPrunable() =\n%+v\nwant\n%+v", got, want)
+	}
+}
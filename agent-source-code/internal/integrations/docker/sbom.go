@@ -0,0 +1,149 @@
+package docker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SBOMFormat is the SBOM format produced and uploaded
+const SBOMFormat = "cyclonedx-json"
+
+// SBOMTimeout bounds generation for a single image; large images with many
+// layers can take a while to catalogue
+const SBOMTimeout = 10 * time.Minute
+
+// SBOMGenerator produces CycloneDX SBOMs for local images using syft or trivy
+type SBOMGenerator struct {
+	logger *logrus.Logger
+	tool   string
+}
+
+// NewSBOMGenerator detects an SBOM tool. Returns nil when neither syft nor trivy
+// is installed.
+func NewSBOMGenerator(logger *logrus.Logger) *SBOMGenerator {
+	for _, tool := range []string{"syft", "trivy"} {
+		if path, err := exec.LookPath(tool); err == nil {
+			logger.WithFields(logrus.Fields{"tool": tool, "path": path}).Debug("Found SBOM tool")
+			return &SBOMGenerator{logger: logger, tool: tool}
+		}
+	}
+	return nil
+}
+
+// Tool returns the name of the tool in use
+func (g *SBOMGenerator) Tool() string {
+	return g.tool
+}
+
+// Generate returns a gzip-compressed CycloneDX JSON SBOM for the given image
+// reference (repository:tag or image ID), read from the engine that holds it
+func (g *SBOMGenerator) Generate(ctx context.Context, imageRef string, engine models.EngineRef) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, SBOMTimeout)
+	defer cancel()
+
+	cmd, err := sbomCommand(ctx, g.tool, imageRef, EngineSocket(engine))
+	if err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s failed for %s: %w (%s)", g.tool, imageRef, err, truncate(stderr.String(), 200))
+	}
+	if !json.Valid(out) {
+		return nil, fmt.Errorf("%s produced invalid JSON for %s", g.tool, imageRef)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(out); err != nil {
+		return nil, fmt.Errorf("failed to compress SBOM: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress SBOM: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// sbomCommand builds the tool's command for imageRef. Both tools read the
+// image from the engine's Docker-compatible API rather than pulling it; socket
+// selects a Podman engine, and empty leaves DOCKER_HOST or the default.
+func sbomCommand(ctx context.Context, tool, imageRef, socket string) (*exec.Cmd, error) {
+	switch tool {
+	case "syft":
+		cmd := utils.CommandContext(ctx, "syft", "docker:"+imageRef, "-o", "cyclonedx-json", "-q")
+		if socket != "" {
+			cmd.Env = append(cmd.Env, "DOCKER_HOST=unix://"+socket)
+		}
+		return cmd, nil
+	case "trivy":
+		args := []string{"image", "--quiet", "--format", "cyclonedx", "--image-src", "docker"}
+		if socket != "" {
+			args = append(args, "--docker-host", "unix://"+socket)
+		}
+		return utils.CommandContext(ctx, "trivy", append(args, imageRef)...), nil
+	default:
+		return nil, fmt.Errorf("unsupported SBOM tool: %s", tool)
+	}
+}
+
+// SyftVersion returns the installed syft version, or "" if syft isn't installed
+func SyftVersion(ctx context.Context) string {
+	out, err := utils.CommandContext(ctx, "syft", "version", "-o", "json").Output()
+	if err != nil {
+		return ""
+	}
+	var v struct {
+		Version string `json:"version"`
+	}
+	if json.Unmarshal(out, &v) != nil {
+		return ""
+	}
+	return v.Version
+}
+
+// TrivyVersion returns the installed trivy version and when its vulnerability
+// database was built, nil if it has none. The version is "" if trivy isn't
+// installed.
+func TrivyVersion(ctx context.Context) (string, *time.Time) {
+	out, err := utils.CommandContext(ctx, "trivy", "version", "--format", "json").Output()
+	if err != nil {
+		return "", nil
+	}
+	return parseTrivyVersion(out)
+}
+
+func parseTrivyVersion(out []byte) (string, *time.Time) {
+	var v struct {
+		Version         string `json:"Version"`
+		VulnerabilityDB *struct {
+			UpdatedAt time.Time `json:"UpdatedAt"`
+		} `json:"VulnerabilityDB"`
+	}
+	if json.Unmarshal(out, &v) != nil {
+		return "", nil
+	}
+	if v.VulnerabilityDB == nil || v.VulnerabilityDB.UpdatedAt.IsZero() {
+		return v.Version, nil
+	}
+	return v.Version, &v.VulnerabilityDB.UpdatedAt
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
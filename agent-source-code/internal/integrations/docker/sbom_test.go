@@ -0,0 +1,91 @@
+package docker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+func TestParseTrivyVersion(t *testing.T) {
+	version, dbUpdated := parseTrivyVersion([]byte(`{"Version":"0.52.2","VulnerabilityDB":{"Version":2,"NextUpdate":"2024-06-20T12:11:53Z","UpdatedAt":"2024-06-20T06:11:53Z","DownloadedAt":"2024-06-20T09:46:02Z"}}`))
+	if version != "0.52.2" {
+		t.Errorf("version = %q, want 0.52.2", version)
+	}
+	if dbUpdated == nil || dbUpdated.Format("2006-01-02T15:04:05Z07:00") != "2024-06-20T06:11:53Z" {
+		t.Errorf("db updated = %v, want 2024-06-20T06:11:53Z", dbUpdated)
+	}
+
+	// Without a downloaded database trivy leaves VulnerabilityDB out
+	version, dbUpdated = parseTrivyVersion([]byte(`{"Version":"0.52.2"}`))
+	if version != "0.52.2" || dbUpdated != nil {
+		t.Errorf("got %q, %v; want 0.52.2 and no database", version, dbUpdated)
+	}
+}
+
+func TestEngineSocket(t *testing.T) {
+	root := t.TempDir()
+	for _, p := range []string{"run/podman/podman.sock", "run/user/0/podman/podman.sock"} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(p)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, p), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		engine models.EngineRef
+		want   string
+	}{
+		{models.EngineRef{Runtime: "docker"}, ""},
+		{models.EngineRef{Runtime: "podman"}, filepath.Join(root, "run/podman/podman.sock")},
+		{models.EngineRef{Runtime: "podman", RootlessUser: "root"}, filepath.Join(root, "run/user/0/podman/podman.sock")},
+		// The socket went away since the inventory; fall back to the default
+		{models.EngineRef{Runtime: "podman", RootlessUser: "alice"}, ""},
+	}
+	for _, tt := range tests {
+		if got := engineSocket(root, tt.engine); got != tt.want {
+			t.Errorf("engineSocket(%+v) = %q, want %q", tt.engine, got, tt.want)
+		}
+	}
+}
+
+func TestSBOMCommand(t *testing.T) {
+	ctx := context.Background()
+	sock := "/run/user/1000/podman/podman.sock"
+
+	cmd, err := sbomCommand(ctx, "trivy", "nginx:1.27", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"image", "--quiet", "--format", "cyclonedx", "--image-src", "docker", "--docker-host", "unix://" + sock, "nginx:1.27"}
+	if !reflect.DeepEqual(cmd.Args[1:], want) {
+		t.Errorf("trivy args = %v, want %v", cmd.Args[1:], want)
+	}
+
+	cmd, err = sbomCommand(ctx, "syft", "nginx:1.27", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(cmd.Env, "DOCKER_HOST=unix://"+sock) {
+		t.Errorf("syft should be pointed at the Podman socket, env has no DOCKER_HOST for it")
+	}
+
+	// Docker images leave DOCKER_HOST as the agent has it
+	cmd, _ = sbomCommand(ctx, "syft", "nginx:1.27", "")
+	if slices.Contains(cmd.Env, "DOCKER_HOST=unix://"+sock) {
+		t.Errorf("expected no Podman socket for a Docker image")
+	}
+	cmd, _ = sbomCommand(ctx, "trivy", "nginx:1.27", "")
+	if slices.Contains(cmd.Args, "--docker-host") {
+		t.Errorf("expected no --docker-host for a Docker image, got %v", cmd.Args)
+	}
+	if _, err := sbomCommand(ctx, "grype", "nginx:1.27", ""); err == nil {
+		t.Error("expected an error for an unsupported tool")
+	}
+}
@@ -0,0 +1,84 @@
+package docker
+
+import (
+	"path"
+	"strings"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/mount"
+)
+
+// sensitiveHostPaths are the host paths a bind mount of which, or of
+// anything under them, is reported. They are the directories of Docker
+// Bench check 5.5 plus the runtime sockets and state that hand over the
+// daemon itself.
+var sensitiveHostPaths = []string{
+	"/boot",
+	"/dev",
+	"/etc",
+	"/lib",
+	"/proc",
+	"/root",
+	"/sys",
+	"/usr",
+	"/var/lib/docker",
+	"/var/run/docker.sock",
+	"/run/docker.sock",
+	"/run/containerd",
+	"/var/run/containerd",
+}
+
+// containerSecurity extracts the security context of an inspected container
+func containerSecurity(c container.InspectResponse) *models.ContainerSecurity {
+	sec := &models.ContainerSecurity{RunsAsRoot: true}
+	if c.Config != nil {
+		sec.User = c.Config.User
+		sec.RunsAsRoot = isRootUser(c.Config.User)
+	}
+	if hc := c.HostConfig; hc != nil {
+		sec.Privileged = hc.Privileged
+		sec.CapAdd = hc.CapAdd
+		sec.CapDrop = hc.CapDrop
+		sec.HostNetwork = hc.NetworkMode.IsHost()
+		sec.HostPID = hc.PidMode.IsHost()
+		sec.HostIPC = hc.IpcMode.IsHost()
+		sec.ReadOnlyRootfs = hc.ReadonlyRootfs
+		sec.SecurityOptions = hc.SecurityOpt
+	}
+	for _, m := range c.Mounts {
+		if m.Type == mount.TypeBind && isSensitiveHostPath(m.Source) {
+			sec.SensitiveMounts = append(sec.SensitiveMounts, models.SensitiveMount{
+				Source:      m.Source,
+				Destination: m.Destination,
+				ReadOnly:    !m.RW,
+			})
+		}
+	}
+	return sec
+}
+
+// isRootUser reports whether a container's user (name, UID, or either with
+// a group) is root. An unset user runs as the image default, which Docker
+// resolves to root when the image sets none; the container config already
+// carries the image's USER when it sets one.
+func isRootUser(user string) bool {
+	name, _, _ := strings.Cut(user, ":")
+	return name == "" || name == "root" || name == "0"
+}
+
+// isSensitiveHostPath reports whether a bind-mounted host path is the host
+// root or under one of sensitiveHostPaths
+func isSensitiveHostPath(source string) bool {
+	source = path.Clean(source)
+	if source == "/" {
+		return true
+	}
+	for _, p := range sensitiveHostPaths {
+		if source == p || strings.HasPrefix(source, p+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/mount"
+)
+
+func TestContainerSecurity(t *testing.T) {
+	sec := containerSecurity(container.InspectResponse{
+		Config: &container.Config{User: "0:0"},
+		HostConfig: &container.HostConfig{
+			Privileged:  true,
+			CapAdd:      []string{"CAP_NET_ADMIN"},
+			NetworkMode: "host",
+			PidMode:     "host",
+			SecurityOpt: []string{"seccomp=unconfined"},
+		},
+		Mounts: []container.MountPoint{
+			{Type: mount.TypeBind, Source: "/var/run/docker.sock", Destination: "/var/run/docker.sock", RW: true},
+			{Type: mount.TypeBind, Source: "/etc/ssl/certs", Destination: "/certs"},
+			{Type: mount.TypeBind, Source: "/srv/app", Destination: "/app", RW: true},
+			{Type: mount.TypeBind, Source: "/etcd", Destination: "/data", RW: true},
+			{Type: mount.TypeVolume, Source: "/var/lib/docker/volumes/db/_data", Destination: "/db", RW: true},
+		},
+	})
+	want := &models.ContainerSecurity{
+		Privileged:      true,
+		CapAdd:          []string{"CAP_NET_ADMIN"},
+		HostNetwork:     true,
+		HostPID:         true,
+		User:            "0:0",
+		RunsAsRoot:      true,
+		SecurityOptions: []string{"seccomp=unconfined"},
+		SensitiveMounts: []models.SensitiveMount{
+			{Source: "/var/run/docker.sock", Destination: "/var/run/docker.sock"},
+			{Source: "/etc/ssl/certs", Destination: "/certs", ReadOnly: true},
+		},
+	}
+	if !reflect.DeepEqual(sec, want) {
+		t.Errorf("containerSecurity() = %+v, want %+v", sec, want)
+	}
+}
+
+func TestIsRootUser(t *testing.T) {
+	for user, want := range map[string]bool{
+		"":          true,
+		"root":      true,
+		"0":         true,
+		"root:adm":  true,
+		"1000":      false,
+		"nginx":     false,
+		"1000:0":    false,
+		"rootless":  false,
+		"65534:100": false,
+	} {
+		if got := isRootUser(user); got != want {
+			t.Errorf("isRootUser(%q) = %v, want %v", user, got, want)
+		}
+	}
+}
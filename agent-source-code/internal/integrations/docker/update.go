@@ -0,0 +1,386 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	cerrdefs "github.com/containerd/errdefs"
+	dockerspec "github.com/moby/docker-image-spec/specs-go/v1"
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/mount"
+	"github.com/moby/moby/api/types/network"
+	"github.com/moby/moby/client"
+)
+
+const (
+	// DefaultUpdateHealthTimeout is how long a recreated container has to
+	// become healthy when the policy doesn't say
+	DefaultUpdateHealthTimeout = 120 * time.Second
+
+	// rollbackSuffix is appended to the old container's name while the new
+	// one is tried
+	rollbackSuffix = "-patchmon-rollback"
+
+	// healthSettle is how long a container without a healthcheck must stay
+	// running to count as healthy
+	healthSettle = 10 * time.Second
+
+	// rollbackTimeout bounds restoring the old container, which runs even
+	// when the update's context is done
+	rollbackTimeout = 2 * time.Minute
+)
+
+// UpdatePolicy is the allowlist of containers UpdateContainer may recreate
+type UpdatePolicy struct {
+	Containers    []string // Container names; path.Match globs allowed
+	Labels        []string // "key" or "key=value"
+	HealthTimeout time.Duration
+}
+
+// Allows reports whether the container with name and labels is on the
+// allowlist
+func (p UpdatePolicy) Allows(name string, labels map[string]string) bool {
+	for _, pattern := range p.Containers {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	for _, label := range p.Labels {
+		key, want, hasValue := strings.Cut(label, "=")
+		if value, ok := labels[key]; ok && (!hasValue || value == want) {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateContainer pulls the image of the named container and, when the pull
+// brought a new one, recreates the container from it with the same
+// configuration. The old container is kept, renamed and stopped, until the
+// new one is healthy, and is put back if it isn't. Errors are reported in
+// the result's Status and Error.
+func (d *Integration) UpdateContainer(ctx context.Context, name string, policy UpdatePolicy) *models.DockerContainerUpdateResult {
+	result := &models.DockerContainerUpdateResult{
+		ContainerName: name,
+		Status:        models.ContainerUpdateFailed,
+		StartedAt:     time.Now().UTC(),
+	}
+	defer func() { result.CompletedAt = time.Now().UTC() }()
+
+	engine, old, err := d.findContainer(ctx, name)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.EngineRef = engine.engine
+	result.ContainerName = strings.TrimPrefix(old.Name, "/")
+	result.OldContainer = old.ID
+	result.OldImageID = old.Image
+	result.Image = old.Config.Image
+
+	if !policy.Allows(result.ContainerName, old.Config.Labels) {
+		result.Error = "container is not in the docker_update allowlist"
+		return result
+	}
+	if strings.Contains(result.Image, "@") || strings.HasPrefix(result.Image, "sha256:") {
+		result.Error = fmt.Sprintf("container image %s is pinned to a digest or ID, there is no newer image to pull", result.Image)
+		return result
+	}
+
+	logger := d.logger.WithField("container", result.ContainerName).WithField("image", result.Image)
+	logger.Info("Pulling image for container update...")
+	newImage, err := engine.pull(ctx, result.Image)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.NewImageID = newImage.ID
+	if newImage.ID == old.Image {
+		logger.Info("Container image is up to date")
+		result.Status = models.ContainerUpdateUpToDate
+		return result
+	}
+	oldImage, err := engine.client.ImageInspect(ctx, old.Image)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to inspect the container's current image: %v", err)
+		return result
+	}
+
+	opts, extraNetworks := recreateConfig(old, oldImage.Config)
+	newID, err := engine.recreate(ctx, old, opts, extraNetworks, policy.HealthTimeout)
+	result.NewContainer = newID
+	if err == nil {
+		logger.WithField("new_image_id", newImage.ID).Info("Container updated")
+		result.Status = models.ContainerUpdateUpdated
+		return result
+	}
+
+	result.Error = err.Error()
+	logger.WithError(err).Warn("Container update failed, rolling back")
+	rbCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
+	if err := engine.rollback(rbCtx, old, newID); err != nil {
+		logger.WithError(err).Error("Rollback of container update failed")
+		result.RollbackError = err.Error()
+		return result
+	}
+	result.Status = models.ContainerUpdateRolledBack
+	return result
+}
+
+// findContainer looks the container up by name or ID in each engine
+func (d *Integration) findContainer(ctx context.Context, name string) (*Integration, container.InspectResponse, error) {
+	for _, e := range d.connected() {
+		res, err := e.client.ContainerInspect(ctx, name, client.ContainerInspectOptions{})
+		if cerrdefs.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, container.InspectResponse{}, fmt.Errorf("failed to inspect container: %w", err)
+		}
+		if res.Container.Config == nil || res.Container.HostConfig == nil {
+			return nil, container.InspectResponse{}, fmt.Errorf("container %s has no configuration", name)
+		}
+		return e, res.Container, nil
+	}
+	return nil, container.InspectResponse{}, fmt.Errorf("container %s not found", name)
+}
+
+// pull pulls ref and returns the image it now names
+func (d *Integration) pull(ctx context.Context, ref string) (client.ImageInspectResult, error) {
+	resp, err := d.client.ImagePull(ctx, ref, client.ImagePullOptions{})
+	if err != nil {
+		return client.ImageInspectResult{}, fmt.Errorf("failed to pull %s: %w", ref, err)
+	}
+	err = resp.Wait(ctx)
+	_ = resp.Close()
+	if err != nil {
+		return client.ImageInspectResult{}, fmt.Errorf("failed to pull %s: %w", ref, err)
+	}
+	img, err := d.client.ImageInspect(ctx, ref)
+	if err != nil {
+		return client.ImageInspectResult{}, fmt.Errorf("failed to inspect pulled image: %w", err)
+	}
+	return img, nil
+}
+
+// recreate moves the old container aside and creates, starts and waits on
+// its replacement. It returns the new container's ID, if one was created,
+// and the old container is left renamed and stopped either way.
+func (d *Integration) recreate(ctx context.Context, old container.InspectResponse, opts client.ContainerCreateOptions, extraNetworks map[string]*network.EndpointSettings, healthTimeout time.Duration) (string, error) {
+	if _, err := d.client.ContainerRename(ctx, old.ID, client.ContainerRenameOptions{NewName: opts.Name + rollbackSuffix}); err != nil {
+		return "", fmt.Errorf("failed to rename the old container: %w", err)
+	}
+	if old.State != nil && old.State.Running {
+		if _, err := d.client.ContainerStop(ctx, old.ID, client.ContainerStopOptions{}); err != nil {
+			return "", fmt.Errorf("failed to stop the old container: %w", err)
+		}
+	}
+
+	created, err := d.client.ContainerCreate(ctx, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to create the new container: %w", err)
+	}
+	// Only one network can be given at create time
+	for _, name := range sortedKeys(extraNetworks) {
+		if _, err := d.client.NetworkConnect(ctx, name, client.NetworkConnectOptions{Container: created.ID, EndpointConfig: extraNetworks[name]}); err != nil {
+			return created.ID, fmt.Errorf("failed to connect the new container to network %s: %w", name, err)
+		}
+	}
+	if _, err := d.client.ContainerStart(ctx, created.ID, client.ContainerStartOptions{}); err != nil {
+		return created.ID, fmt.Errorf("failed to start the new container: %w", err)
+	}
+	if healthTimeout <= 0 {
+		healthTimeout = DefaultUpdateHealthTimeout
+	}
+	if err := d.waitHealthy(ctx, created.ID, healthTimeout); err != nil {
+		return created.ID, err
+	}
+
+	if _, err := d.client.ContainerRemove(ctx, old.ID, client.ContainerRemoveOptions{}); err != nil {
+		d.logger.WithError(err).WithField("container", opts.Name+rollbackSuffix).Warn("Failed to remove the old container after the update")
+	}
+	return created.ID, nil
+}
+
+// waitHealthy waits for the container's healthcheck to pass or, without
+// one, for it to stay running for healthSettle
+func (d *Integration) waitHealthy(ctx context.Context, id string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	started := time.Now()
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		res, err := d.client.ContainerInspect(ctx, id, client.ContainerInspectOptions{})
+		if err != nil && ctx.Err() == nil {
+			return fmt.Errorf("failed to inspect the new container: %w", err)
+		}
+		if state := res.Container.State; err == nil && state != nil {
+			switch {
+			case !state.Running || state.Restarting:
+				return fmt.Errorf("the new container exited with code %d", state.ExitCode)
+			case state.Health != nil && state.Health.Status == container.Healthy:
+				return nil
+			case state.Health != nil && state.Health.Status == container.Unhealthy:
+				return errors.New("the new container is unhealthy")
+			case state.Health == nil && time.Since(started) >= healthSettle:
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("the new container did not become healthy within %s", timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// rollback removes the new container, if any, and gives the old one back
+// its name and state
+func (d *Integration) rollback(ctx context.Context, old container.InspectResponse, newID string) error {
+	if newID != "" {
+		if _, err := d.client.ContainerRemove(ctx, newID, client.ContainerRemoveOptions{Force: true}); err != nil {
+			return fmt.Errorf("failed to remove the new container: %w", err)
+		}
+	}
+	if _, err := d.client.ContainerRename(ctx, old.ID, client.ContainerRenameOptions{NewName: strings.TrimPrefix(old.Name, "/")}); err != nil {
+		return fmt.Errorf("failed to rename the old container back: %w", err)
+	}
+	if old.State != nil && old.State.Running {
+		if _, err := d.client.ContainerStart(ctx, old.ID, client.ContainerStartOptions{}); err != nil {
+			return fmt.Errorf("failed to restart the old container: %w", err)
+		}
+	}
+	return nil
+}
+
+// recreateConfig builds the create options for a copy of old. Settings old
+// inherited from its image are left out so the new image's apply, and
+// anonymous volumes are mounted again by name so their data is kept. The
+// networks beyond the first are returned to be connected after create.
+func recreateConfig(old container.InspectResponse, image *dockerspec.DockerOCIImageConfig) (client.ContainerCreateOptions, map[string]*network.EndpointSettings) {
+	cfg := *old.Config
+	hostCfg := *old.HostConfig
+	if image == nil {
+		image = &dockerspec.DockerOCIImageConfig{}
+	}
+
+	cfg.Env = slices.DeleteFunc(slices.Clone(cfg.Env), func(env string) bool {
+		return slices.Contains(image.Env, env)
+	})
+	cfg.Labels = make(map[string]string, len(old.Config.Labels))
+	for key, value := range old.Config.Labels {
+		if imageValue, ok := image.Labels[key]; !ok || imageValue != value {
+			cfg.Labels[key] = value
+		}
+	}
+	cfg.Volumes = make(map[string]struct{}, len(old.Config.Volumes))
+	for dest := range old.Config.Volumes {
+		if _, ok := image.Volumes[dest]; !ok {
+			cfg.Volumes[dest] = struct{}{}
+		}
+	}
+	if slices.Equal(cfg.Entrypoint, image.Entrypoint) {
+		cfg.Entrypoint = nil
+		if slices.Equal(cfg.Cmd, image.Cmd) {
+			cfg.Cmd = nil
+		}
+	}
+	if cfg.WorkingDir == image.WorkingDir {
+		cfg.WorkingDir = ""
+	}
+	if cfg.User == image.User {
+		cfg.User = ""
+	}
+	if reflect.DeepEqual(cfg.Healthcheck, image.Healthcheck) {
+		cfg.Healthcheck = nil
+	}
+	if cfg.StopSignal == image.StopSignal {
+		cfg.StopSignal = ""
+	}
+
+	shortID := old.ID
+	if len(shortID) > 12 {
+		shortID = shortID[:12]
+	}
+	mode := hostCfg.NetworkMode
+	if cfg.Hostname == shortID || mode.IsHost() || mode.IsContainer() {
+		cfg.Hostname = ""
+	}
+
+	// Anonymous volumes would otherwise be created afresh, empty
+	mounted := make(map[string]bool)
+	for _, bind := range hostCfg.Binds {
+		if parts := strings.Split(bind, ":"); len(parts) >= 2 {
+			mounted[parts[1]] = true
+		}
+	}
+	for _, m := range hostCfg.Mounts {
+		mounted[m.Target] = true
+	}
+	hostCfg.Binds = slices.Clone(hostCfg.Binds)
+	for _, m := range old.Mounts {
+		if m.Type != mount.TypeVolume || m.Name == "" || mounted[m.Destination] {
+			continue
+		}
+		bind := m.Name + ":" + m.Destination
+		if !m.RW {
+			bind += ":ro"
+		}
+		hostCfg.Binds = append(hostCfg.Binds, bind)
+	}
+
+	endpoints := make(map[string]*network.EndpointSettings)
+	extra := make(map[string]*network.EndpointSettings)
+	if old.NetworkSettings != nil && !mode.IsContainer() {
+		primary := string(mode)
+		if _, ok := old.NetworkSettings.Networks[primary]; !ok && len(old.NetworkSettings.Networks) > 0 && !mode.IsHost() && !mode.IsNone() {
+			// Podman's default bridge network is called podman
+			primary = sortedKeys(old.NetworkSettings.Networks)[0]
+			hostCfg.NetworkMode = container.NetworkMode(primary)
+		}
+		for name, ep := range old.NetworkSettings.Networks {
+			if ep == nil {
+				continue
+			}
+			settings := &network.EndpointSettings{
+				IPAMConfig: ep.IPAMConfig,
+				Links:      ep.Links,
+				Aliases:    slices.DeleteFunc(slices.Clone(ep.Aliases), func(alias string) bool { return alias == shortID }),
+				DriverOpts: ep.DriverOpts,
+				GwPriority: ep.GwPriority,
+			}
+			if name == primary {
+				endpoints[name] = settings
+			} else {
+				extra[name] = settings
+			}
+		}
+	}
+
+	return client.ContainerCreateOptions{
+		Config:           &cfg,
+		HostConfig:       &hostCfg,
+		NetworkingConfig: &network.NetworkingConfig{EndpointsConfig: endpoints},
+		Name:             strings.TrimPrefix(old.Name, "/"),
+	}, extra
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
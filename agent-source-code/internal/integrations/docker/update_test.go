@@ -0,0 +1,135 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+
+	dockerspec "github.com/moby/docker-image-spec/specs-go/v1"
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/mount"
+	"github.com/moby/moby/api/types/network"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestUpdatePolicyAllows(t *testing.T) {
+	policy := UpdatePolicy{
+		Containers: []string{"nginx", "app-*"},
+		Labels:     []string{"patchmon.update=true", "com.example.autoupdate"},
+	}
+	for _, tc := range []struct {
+		name   string
+		labels map[string]string
+		want   bool
+	}{
+		{"nginx", nil, true},
+		{"nginx-2", nil, false},
+		{"app-web", nil, true},
+		{"db", map[string]string{"patchmon.update": "true"}, true},
+		{"db", map[string]string{"patchmon.update": "false"}, false},
+		{"db", map[string]string{"com.example.autoupdate": ""}, true},
+		{"db", map[string]string{"other": "true"}, false},
+	} {
+		if got := policy.Allows(tc.name, tc.labels); got != tc.want {
+			t.Errorf("Allows(%q, %v) = %v, want %v", tc.name, tc.labels, got, tc.want)
+		}
+	}
+	if (UpdatePolicy{}).Allows("nginx", nil) {
+		t.Error("an empty policy allowed a container")
+	}
+}
+
+func TestRecreateConfig(t *testing.T) {
+	old := container.InspectResponse{
+		ID:   "3f4e8a1b2c9d0e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f",
+		Name: "/app-web",
+		Config: &container.Config{
+			Hostname:    "3f4e8a1b2c9d",
+			Image:       "ghcr.io/example/web:1",
+			Env:         []string{"PATH=/usr/local/bin:/usr/bin", "APP_VERSION=1.0", "DB_HOST=db"},
+			Cmd:         []string{"serve"},
+			Entrypoint:  []string{"/entrypoint.sh"},
+			WorkingDir:  "/app",
+			User:        "app",
+			Labels:      map[string]string{"org.opencontainers.image.version": "1.0", "patchmon.update": "true"},
+			Volumes:     map[string]struct{}{"/data": {}, "/cache": {}},
+			Healthcheck: &container.HealthConfig{Test: []string{"CMD", "true"}},
+		},
+		HostConfig: &container.HostConfig{
+			Binds:       []string{"/srv/app/config:/app/config:ro"},
+			NetworkMode: "frontend",
+		},
+		Mounts: []container.MountPoint{
+			{Type: mount.TypeBind, Source: "/srv/app/config", Destination: "/app/config"},
+			{Type: mount.TypeVolume, Name: "9c1d7e0f", Destination: "/data", RW: true},
+			{Type: mount.TypeVolume, Name: "cache", Destination: "/cache"},
+		},
+		NetworkSettings: &container.NetworkSettings{
+			Networks: map[string]*network.EndpointSettings{
+				"frontend": {Aliases: []string{"web", "3f4e8a1b2c9d"}, NetworkID: "n1", EndpointID: "e1"},
+				"backend":  {Aliases: []string{"3f4e8a1b2c9d"}},
+			},
+		},
+	}
+	image := &dockerspec.DockerOCIImageConfig{
+		ImageConfig: ocispec.ImageConfig{
+			User:       "app",
+			Env:        []string{"PATH=/usr/local/bin:/usr/bin", "APP_VERSION=1.0"},
+			Entrypoint: []string{"/entrypoint.sh"},
+			Cmd:        []string{"serve"},
+			WorkingDir: "/app",
+			Labels:     map[string]string{"org.opencontainers.image.version": "1.0"},
+			Volumes:    map[string]struct{}{"/data": {}},
+		},
+		DockerOCIImageConfigExt: dockerspec.DockerOCIImageConfigExt{
+			Healthcheck: &dockerspec.HealthcheckConfig{Test: []string{"CMD", "true"}},
+		},
+	}
+
+	opts, extra := recreateConfig(old, image)
+	if opts.Name != "app-web" {
+		t.Errorf("Name = %q, want app-web", opts.Name)
+	}
+	want := &container.Config{
+		Image:   "ghcr.io/example/web:1",
+		Env:     []string{"DB_HOST=db"},
+		Labels:  map[string]string{"patchmon.update": "true"},
+		Volumes: map[string]struct{}{"/cache": {}},
+	}
+	if !reflect.DeepEqual(opts.Config, want) {
+		t.Errorf("Config = %+v, want %+v", opts.Config, want)
+	}
+	wantBinds := []string{"/srv/app/config:/app/config:ro", "9c1d7e0f:/data", "cache:/cache:ro"}
+	if !reflect.DeepEqual(opts.HostConfig.Binds, wantBinds) {
+		t.Errorf("Binds = %v, want %v", opts.HostConfig.Binds, wantBinds)
+	}
+	wantEndpoints := map[string]*network.EndpointSettings{"frontend": {Aliases: []string{"web"}}}
+	if !reflect.DeepEqual(opts.NetworkingConfig.EndpointsConfig, wantEndpoints) {
+		t.Errorf("EndpointsConfig = %+v, want %+v", opts.NetworkingConfig.EndpointsConfig, wantEndpoints)
+	}
+	wantExtra := map[string]*network.EndpointSettings{"backend": {Aliases: []string{}}}
+	if !reflect.DeepEqual(extra, wantExtra) {
+		t.Errorf("extra networks = %+v, want %+v", extra, wantExtra)
+	}
+	if len(old.Config.Env) != 3 || len(old.HostConfig.Binds) != 1 {
+		t.Error("recreateConfig modified the old container's config")
+	}
+}
+
+func TestRecreateConfigPodmanNetwork(t *testing.T) {
+	old := container.InspectResponse{
+		ID:         "0123456789abcdef",
+		Name:       "/web",
+		Config:     &container.Config{Hostname: "web.example.com"},
+		HostConfig: &container.HostConfig{NetworkMode: "bridge"},
+		NetworkSettings: &container.NetworkSettings{
+			Networks: map[string]*network.EndpointSettings{"podman": {}},
+		},
+	}
+	opts, extra := recreateConfig(old, nil)
+	if opts.HostConfig.NetworkMode != "podman" || len(extra) != 0 {
+		t.Errorf("NetworkMode = %q with extra networks %v, want podman and none", opts.HostConfig.NetworkMode, extra)
+	}
+	if opts.Config.Hostname != "web.example.com" {
+		t.Errorf("a hostname set by the user was dropped: %q", opts.Config.Hostname)
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"time"
 
-	"patchmon-agent/pkg/models"
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 
 	"github.com/moby/moby/client"
 )
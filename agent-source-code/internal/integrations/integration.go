@@ -3,7 +3,9 @@ package integrations
 
 import (
 	"context"
-	"patchmon-agent/pkg/models"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/secrets"
 )
 
 // Integration defines the interface that all integrations must implement
@@ -36,3 +38,10 @@ type RealtimeIntegration interface {
 	// StopMonitoring stops real-time monitoring
 	StopMonitoring() error
 }
+
+// SecretsConsumer is implemented by integrations that use secrets distributed
+// by the server (registry credentials, broker passwords). The manager hands
+// each one a scope limited to its own secrets.
+type SecretsConsumer interface {
+	SetSecrets(scope *secrets.Scope)
+}
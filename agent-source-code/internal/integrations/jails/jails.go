@@ -0,0 +1,207 @@
+// Package jails inventories the FreeBSD jails on a host and the pkg updates
+// pending inside each of them
+package jails
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner"
+	"patchmon-agent/internal/packages"
+	"patchmon-agent/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IntegrationName is the config/integration key for the jail inventory
+const IntegrationName = "jails"
+
+// jlsParams are the jail parameters listed, in the order parseJLS reads them
+var jlsParams = []string{"jid", "name", "host.hostname", "path", "osrelease", "ip4.addr", "ip6.addr"}
+
+// Integration implements the Integration interface for FreeBSD jails
+type Integration struct {
+	logger *logrus.Logger
+	runner cmdrunner.Runner
+	// root prefixes the jail paths read on the host, for tests
+	root string
+}
+
+// New creates a new jail integration
+func New(logger *logrus.Logger) *Integration {
+	return &Integration{logger: logger, runner: cmdrunner.Default, root: "/"}
+}
+
+// Name returns the integration name
+func (j *Integration) Name() string {
+	return IntegrationName
+}
+
+// Priority returns the collection priority
+func (j *Integration) Priority() int {
+	return 50
+}
+
+// SupportsRealtime indicates the jail inventory is batch-only
+func (j *Integration) SupportsRealtime() bool {
+	return false
+}
+
+// IsAvailable reports whether the host is FreeBSD with jls installed
+func (j *Integration) IsAvailable() bool {
+	if runtime.GOOS != "freebsd" {
+		return false
+	}
+	_, err := j.runner.LookPath("jls")
+	return err == nil
+}
+
+// Collect lists the running jails and the packages pending update in each
+func (j *Integration) Collect(ctx context.Context) (*models.IntegrationData, error) {
+	startTime := time.Now()
+
+	out, err := j.runner.Output(ctx, "jls", append([]string{"-q"}, jlsParams...)...)
+	if err != nil {
+		return nil, fmt.Errorf("jls failed: %w", err)
+	}
+
+	data := &models.JailsData{Jails: parseJLS(string(out))}
+	pkgManager := packages.NewFreeBSDManager(j.logger).WithRunner(j.runner)
+	for i := range data.Jails {
+		if err := ctx.Err(); err != nil {
+			data.Warnings = append(data.Warnings, fmt.Sprintf("stopped before jail %s: %v", data.Jails[i].Name, err))
+			break
+		}
+		jail := &data.Jails[i]
+		jail.UserlandVersion = j.userlandVersion(jail.Path)
+		j.collectPackages(pkgManager.InJail(strconv.Itoa(jail.JID)), jail)
+	}
+
+	j.logger.WithField("jails", len(data.Jails)).Info("Collected FreeBSD jails")
+
+	return &models.IntegrationData{
+		Name:          j.Name(),
+		Enabled:       true,
+		Data:          data,
+		CollectedAt:   utils.GetCurrentTimeUTC(),
+		ExecutionTime: time.Since(startTime).Seconds(),
+	}, nil
+}
+
+func (j *Integration) collectPackages(m *packages.FreeBSDManager, jail *models.Jail) {
+	jail.Updates = make([]models.Package, 0)
+	pkgs, err := m.GetPackages()
+	if err != nil {
+		j.logger.WithError(err).WithField("jail", jail.Name).Warn("Failed to get packages inside jail")
+		jail.Error = err.Error()
+		return
+	}
+	jail.PackageCount = len(pkgs)
+	for _, p := range pkgs {
+		if !p.NeedsUpdate {
+			continue
+		}
+		jail.Updates = append(jail.Updates, p)
+		if p.IsSecurityUpdate {
+			jail.SecurityUpdates++
+		}
+	}
+}
+
+// userlandVersion reads USERLAND_VERSION from the jail's freebsd-version
+// script, which is what freebsd-version -u prints inside the jail. Thin jails
+// sharing the host's base system report the host's version.
+func (j *Integration) userlandVersion(jailPath string) string {
+	f, err := os.Open(filepath.Join(j.root, jailPath, "bin", "freebsd-version"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "USERLAND_VERSION="); ok {
+			return strings.Trim(value, `"'`)
+		}
+	}
+	return ""
+}
+
+// parseJLS parses jls -q output for jlsParams, one jail per line
+func parseJLS(output string) []models.Jail {
+	jails := make([]models.Jail, 0)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := splitQuoted(scanner.Text())
+		if len(fields) != len(jlsParams) {
+			continue
+		}
+		jid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		jails = append(jails, models.Jail{
+			JID:       jid,
+			Name:      fields[1],
+			Hostname:  fields[2],
+			Path:      fields[3],
+			OSRelease: fields[4],
+			IPv4:      splitAddrs(fields[5]),
+			IPv6:      splitAddrs(fields[6]),
+		})
+	}
+	return jails
+}
+
+// splitAddrs splits a comma-separated ip4.addr or ip6.addr value
+func splitAddrs(value string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(value, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" && addr != "-" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// splitQuoted splits a jls -q line on spaces. jls -q double-quotes values
+// that are empty or contain spaces or quotes, escaping quotes and
+// backslashes inside them.
+func splitQuoted(line string) []string {
+	var fields []string
+	var field strings.Builder
+	inField, quoted, escaped := false, false, false
+	for _, r := range line {
+		switch {
+		case escaped:
+			field.WriteRune(r)
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+			inField = true
+		case !quoted && (r == ' ' || r == '\t'):
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
@@ -0,0 +1,62 @@
+package jails
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner/cmdrunnertest"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitQuoted(t *testing.T) {
+	assert.Equal(t, []string{"3", "build", "", "/jails/a b", `say "hi"`},
+		splitQuoted(`3 build "" "/jails/a b" "say \"hi\""`))
+	assert.Equal(t, []string{"1", "www"}, splitQuoted("1  www "))
+}
+
+func TestCollect(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	replay := cmdrunnertest.Load(t, filepath.Join("testdata", "commands"))
+	j := New(logger)
+	j.runner = replay
+	j.root = filepath.Join("testdata", "root")
+
+	result, err := j.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, replay.Missed())
+	data := result.Data.(*models.JailsData)
+	require.Len(t, data.Jails, 3)
+
+	www := data.Jails[0]
+	assert.Equal(t, 1, www.JID)
+	assert.Equal(t, "www.example.org", www.Hostname)
+	assert.Equal(t, []string{"10.0.0.10", "10.0.0.11"}, www.IPv4)
+	assert.Empty(t, www.IPv6)
+	assert.Equal(t, "14.1-RELEASE-p5", www.OSRelease)
+	assert.Equal(t, "14.1-RELEASE-p3", www.UserlandVersion, "the jail's base system lags the host kernel")
+	assert.Equal(t, 3, www.PackageCount)
+	require.Len(t, www.Updates, 2)
+	assert.Equal(t, 1, www.SecurityUpdates)
+	for _, p := range www.Updates {
+		assert.Equal(t, p.Name == "nginx", p.IsSecurityUpdate, p.Name)
+	}
+
+	db := data.Jails[1]
+	assert.Equal(t, []string{"fd00::20"}, db.IPv6)
+	assert.Equal(t, 2, db.PackageCount)
+	assert.Empty(t, db.Updates)
+	assert.Empty(t, db.Error)
+
+	build := data.Jails[2]
+	assert.Equal(t, "/usr/local/jails/build jail", build.Path)
+	assert.Empty(t, build.Hostname)
+	assert.Empty(t, build.UserlandVersion)
+	assert.NotEmpty(t, build.Error, "pkg is not bootstrapped in the jail")
+}
@@ -0,0 +1,322 @@
+// Package kubernetes reports the state of the Kubernetes node the agent runs
+// on: kubelet and container runtime versions, the node's conditions and the
+// Kubernetes packages installed, so nodes can be patched with the cluster in
+// mind
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner"
+	"patchmon-agent/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IntegrationName is the config/integration key for the Kubernetes node
+const IntegrationName = "kubernetes"
+
+// commandTimeout bounds each command, kubectl's API call included
+const commandTimeout = 30 * time.Second
+
+// roleLabelPrefix is the prefix of the labels naming a node's roles
+const roleLabelPrefix = "node-role.kubernetes.io/"
+
+// distributions are the Kubernetes distributions detected, in order, by
+// their binary. k3s and RKE2 embed the kubelet; kubeadm clusters run the
+// upstream one.
+var distributions = []struct {
+	name, binary string
+}{
+	{"k3s", "k3s"},
+	{"rke2", "rke2"},
+	{"kubeadm", "kubelet"},
+}
+
+// kubeconfigs are the kubelet's own credentials per distribution. They can
+// read the node's Node object without any extra RBAC.
+var kubeconfigs = map[string]string{
+	"k3s":     "/var/lib/rancher/k3s/agent/kubelet.kubeconfig",
+	"rke2":    "/var/lib/rancher/rke2/agent/kubelet.kubeconfig",
+	"kubeadm": "/etc/kubernetes/kubelet.conf",
+}
+
+// rke2Kubectl is where RKE2 installs kubectl, off PATH
+const rke2Kubectl = "/var/lib/rancher/rke2/bin/kubectl"
+
+// nodePackages are the Kubernetes and container runtime packages reported,
+// by their Debian and RPM names
+var nodePackages = []string{
+	"kubelet", "kubeadm", "kubectl", "kubernetes-cni", "cri-tools",
+	"containerd", "containerd.io", "runc", "cri-o",
+}
+
+// Integration implements the Integration interface for Kubernetes nodes
+type Integration struct {
+	logger   *logrus.Logger
+	runner   cmdrunner.Runner
+	root     string
+	hostname func() (string, error)
+}
+
+// New creates a new Kubernetes node integration
+func New(logger *logrus.Logger) *Integration {
+	return &Integration{logger: logger, runner: cmdrunner.Default, root: "/", hostname: os.Hostname}
+}
+
+// Name returns the integration name
+func (k *Integration) Name() string {
+	return IntegrationName
+}
+
+// Priority returns the collection priority
+func (k *Integration) Priority() int {
+	return 50
+}
+
+// SupportsRealtime indicates node state is batch-only
+func (k *Integration) SupportsRealtime() bool {
+	return false
+}
+
+// IsAvailable reports whether this host is a Kubernetes node
+func (k *Integration) IsAvailable() bool {
+	return runtime.GOOS == "linux" && k.distribution() != ""
+}
+
+// distribution returns the Kubernetes distribution installed, or ""
+func (k *Integration) distribution() string {
+	for _, d := range distributions {
+		if k.hasCommand(d.binary) {
+			return d.name
+		}
+	}
+	return ""
+}
+
+// Collect reads the node's versions, its Node object and its packages
+func (k *Integration) Collect(ctx context.Context) (*models.IntegrationData, error) {
+	startTime := time.Now()
+
+	dist := k.distribution()
+	if dist == "" {
+		return nil, fmt.Errorf("no Kubernetes distribution found")
+	}
+	hostname, err := k.hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hostname: %w", err)
+	}
+	// The kubelet registers the node under its lowercased hostname unless
+	// --hostname-override says otherwise
+	data := &models.KubernetesData{
+		NodeName:     strings.ToLower(hostname),
+		Distribution: dist,
+		Packages:     make([]models.Package, 0),
+	}
+
+	if version, err := k.kubeletVersion(ctx, dist); err != nil {
+		data.Warnings = append(data.Warnings, err.Error())
+	} else {
+		data.KubeletVersion = version
+	}
+	if k.hasCommand("containerd") {
+		if out, err := k.output(ctx, "containerd", "--version"); err != nil {
+			data.Warnings = append(data.Warnings, fmt.Sprintf("containerd --version failed: %v", err))
+		} else {
+			data.ContainerdVersion = parseContainerdVersion(string(out))
+		}
+	}
+
+	if err := k.addNodeStatus(ctx, dist, data); err != nil {
+		data.Warnings = append(data.Warnings, err.Error())
+	}
+
+	if pkgs, err := k.installedPackages(ctx); err != nil {
+		data.Warnings = append(data.Warnings, err.Error())
+	} else {
+		data.Packages = pkgs
+	}
+
+	k.logger.WithFields(logrus.Fields{
+		"node":         data.NodeName,
+		"distribution": dist,
+		"kubelet":      data.KubeletVersion,
+	}).Info("Collected Kubernetes node data")
+
+	return &models.IntegrationData{
+		Name:          k.Name(),
+		Enabled:       true,
+		Data:          data,
+		CollectedAt:   utils.GetCurrentTimeUTC(),
+		ExecutionTime: time.Since(startTime).Seconds(),
+	}, nil
+}
+
+func (k *Integration) output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	return k.runner.Output(ctx, name, args...)
+}
+
+// kubeletVersion returns the version of the installed kubelet:
+// "Kubernetes v1.30.2" from kubelet, "k3s version v1.30.2+k3s1 (...)" from
+// k3s and RKE2
+func (k *Integration) kubeletVersion(ctx context.Context, dist string) (string, error) {
+	binary := "kubelet"
+	if dist != "kubeadm" {
+		binary = dist
+	}
+	out, err := k.output(ctx, binary, "--version")
+	if err != nil {
+		return "", fmt.Errorf("%s --version failed: %w", binary, err)
+	}
+	line, _, _ := strings.Cut(string(out), "\n")
+	for _, field := range strings.Fields(line) {
+		if strings.HasPrefix(field, "v") {
+			return field, nil
+		}
+	}
+	return "", fmt.Errorf("unrecognized %s version %q", binary, line)
+}
+
+// parseContainerdVersion returns the version from containerd --version, e.g.
+// "containerd containerd.io 1.7.19 2bf793ef..." or "containerd
+// github.com/containerd/containerd v1.7.13 7c3aca7..."
+func parseContainerdVersion(out string) string {
+	fields := strings.Fields(out)
+	if len(fields) < 3 {
+		return ""
+	}
+	return strings.TrimPrefix(fields[2], "v")
+}
+
+// node is the part of a Node object reported
+type node struct {
+	Metadata struct {
+		Labels map[string]string `json:"labels"`
+	} `json:"metadata"`
+	Spec struct {
+		Unschedulable bool `json:"unschedulable"`
+	} `json:"spec"`
+	Status struct {
+		Conditions []struct {
+			Type               string `json:"type"`
+			Status             string `json:"status"`
+			Reason             string `json:"reason"`
+			Message            string `json:"message"`
+			LastTransitionTime string `json:"lastTransitionTime"`
+		} `json:"conditions"`
+		NodeInfo struct {
+			KubeletVersion          string `json:"kubeletVersion"`
+			ContainerRuntimeVersion string `json:"containerRuntimeVersion"`
+			KernelVersion           string `json:"kernelVersion"`
+			OSImage                 string `json:"osImage"`
+		} `json:"nodeInfo"`
+	} `json:"status"`
+}
+
+// addNodeStatus reads the node's Node object with kubectl and the kubelet's
+// kubeconfig
+func (k *Integration) addNodeStatus(ctx context.Context, dist string, data *models.KubernetesData) error {
+	kubeconfig := filepath.Join(k.root, kubeconfigs[dist])
+	if _, err := os.Stat(kubeconfig); err != nil {
+		return fmt.Errorf("kubelet kubeconfig not readable, node status not collected: %w", err)
+	}
+	kubectl := "kubectl"
+	if !k.hasCommand(kubectl) {
+		if !k.hasCommand(rke2Kubectl) {
+			return fmt.Errorf("kubectl not found, node status not collected")
+		}
+		kubectl = rke2Kubectl
+	}
+
+	out, err := k.output(ctx, kubectl, "--kubeconfig", kubeconfig, "get", "node", data.NodeName, "--output", "json")
+	if err != nil {
+		return fmt.Errorf("kubectl get node %s failed: %w", data.NodeName, err)
+	}
+	var n node
+	if err := json.Unmarshal(out, &n); err != nil {
+		return fmt.Errorf("kubectl get node returned invalid JSON: %w", err)
+	}
+
+	info := n.Status.NodeInfo
+	data.NodeKubeletVersion = info.KubeletVersion
+	data.ContainerRuntime = info.ContainerRuntimeVersion
+	data.KernelVersion = info.KernelVersion
+	data.OSImage = info.OSImage
+	data.Unschedulable = n.Spec.Unschedulable
+	for label := range n.Metadata.Labels {
+		if role, ok := strings.CutPrefix(label, roleLabelPrefix); ok && role != "" {
+			data.Roles = append(data.Roles, role)
+		}
+	}
+	sort.Strings(data.Roles)
+	for _, c := range n.Status.Conditions {
+		data.Conditions = append(data.Conditions, models.KubernetesNodeCondition(c))
+	}
+	return nil
+}
+
+// installedPackages lists the installed nodePackages with dpkg or rpm
+func (k *Integration) installedPackages(ctx context.Context) ([]models.Package, error) {
+	pkgs := make([]models.Package, 0)
+	switch {
+	case k.hasCommand("dpkg-query"):
+		// dpkg-query exits 1 when any name is unknown and still lists the
+		// rest; removed packages keep an entry with their configuration
+		out, err := k.output(ctx, "dpkg-query", append([]string{"-W", "-f=${db:Status-Abbrev}\t${Package}\t${Version}\n"}, nodePackages...)...)
+		if _, ok := cmdrunner.ExitCode(err); err != nil && !ok {
+			return pkgs, fmt.Errorf("dpkg-query failed: %w", err)
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			fields := strings.Split(line, "\t")
+			if len(fields) == 3 && strings.HasPrefix(fields[0], "ii") {
+				pkgs = append(pkgs, models.Package{Name: fields[1], CurrentVersion: fields[2]})
+			}
+		}
+	case k.hasCommand("rpm"):
+		// rpm prints "package x is not installed" lines and exits non-zero
+		// for names it doesn't have
+		out, err := k.output(ctx, "rpm", append([]string{"-q", "--qf", "%{NAME}\t%{VERSION}-%{RELEASE}\n"}, nodePackages...)...)
+		if _, ok := cmdrunner.ExitCode(err); err != nil && !ok {
+			return pkgs, fmt.Errorf("rpm failed: %w", err)
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			if name, version, ok := strings.Cut(line, "\t"); ok {
+				pkgs = append(pkgs, models.Package{Name: name, CurrentVersion: version})
+			}
+		}
+	}
+	return pkgs, nil
+}
+
+func (k *Integration) hasCommand(name string) bool {
+	_, err := k.runner.LookPath(name)
+	return err == nil
+}
+
+// MarkPackageUpdates fills in the available version of each node package
+// from the host's package report, so the node's patch state can be shown
+// without matching the two on the server
+func MarkPackageUpdates(pkgs, hostPkgs []models.Package) {
+	byName := make(map[string]models.Package, len(hostPkgs))
+	for _, p := range hostPkgs {
+		byName[p.Name] = p
+	}
+	for i := range pkgs {
+		if host, ok := byName[pkgs[i].Name]; ok {
+			pkgs[i].AvailableVersion = host.AvailableVersion
+			pkgs[i].NeedsUpdate = host.NeedsUpdate
+			pkgs[i].IsSecurityUpdate = host.IsSecurityUpdate
+		}
+	}
+}
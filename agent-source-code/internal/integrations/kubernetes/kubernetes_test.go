@@ -0,0 +1,66 @@
+package kubernetes
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner/cmdrunnertest"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollect(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	replay := cmdrunnertest.Load(t, filepath.Join("testdata", "commands"))
+	k := New(logger)
+	k.runner = replay
+	k.root = filepath.Join("testdata", "root")
+	k.hostname = func() (string, error) { return "Worker-1", nil }
+
+	result, err := k.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, replay.Missed())
+	data := result.Data.(*models.KubernetesData)
+	assert.Empty(t, data.Warnings)
+	assert.Equal(t, "worker-1", data.NodeName)
+	assert.Equal(t, "kubeadm", data.Distribution)
+	assert.Equal(t, "v1.30.2", data.KubeletVersion)
+	assert.Equal(t, "v1.30.1", data.NodeKubeletVersion, "the kubelet hasn't been restarted since its upgrade")
+	assert.Equal(t, "1.7.19", data.ContainerdVersion)
+	assert.Equal(t, "containerd://1.7.19", data.ContainerRuntime)
+	assert.Equal(t, []string{"ingress", "worker"}, data.Roles)
+	assert.True(t, data.Unschedulable)
+	require.Len(t, data.Conditions, 3)
+	assert.Equal(t, models.KubernetesNodeCondition{
+		Type:               "DiskPressure",
+		Status:             "True",
+		Reason:             "KubeletHasDiskPressure",
+		Message:            "kubelet has disk pressure",
+		LastTransitionTime: "2024-07-01T09:40:02Z",
+	}, data.Conditions[1])
+
+	var names []string
+	for _, p := range data.Packages {
+		names = append(names, p.Name)
+	}
+	assert.Equal(t, []string{"kubelet", "kubeadm", "kubectl", "kubernetes-cni", "cri-tools", "containerd.io"}, names, "removed packages are left out")
+
+	MarkPackageUpdates(data.Packages, []models.Package{
+		{Name: "kubelet", CurrentVersion: "1.30.2-1.1", AvailableVersion: "1.30.3-1.1", NeedsUpdate: true},
+		{Name: "containerd.io", CurrentVersion: "1.7.19-1"},
+	})
+	assert.True(t, data.Packages[0].NeedsUpdate)
+	assert.Equal(t, "1.30.3-1.1", data.Packages[0].AvailableVersion)
+	assert.False(t, data.Packages[5].NeedsUpdate)
+}
+
+func TestParseContainerdVersion(t *testing.T) {
+	assert.Equal(t, "1.7.13", parseContainerdVersion("containerd github.com/containerd/containerd v1.7.13 7c3aca7a610df76212171d200ca3811ff6096eb8\n"))
+	assert.Empty(t, parseContainerdVersion(""))
+}
@@ -0,0 +1,180 @@
+// Package langpkg inventories globally installed language-ecosystem packages
+// (pip/pipx, npm -g, gem) and flags known-vulnerable versions via OSV.
+package langpkg
+
+import (
+	"context"
+	"os/exec"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IntegrationName is the config/integration key for language package scanning
+const IntegrationName = "language-packages"
+
+// commandTimeout bounds each inventory command; npm in particular can be slow
+const commandTimeout = 60 * time.Second
+
+// Integration implements the Integration interface for language packages
+type Integration struct {
+	logger    *logrus.Logger
+	cachePath string
+}
+
+// New creates a new language package integration. cachePath is where OSV
+// lookups are cached between runs.
+func New(logger *logrus.Logger, cachePath string) *Integration {
+	return &Integration{
+		logger:    logger,
+		cachePath: cachePath,
+	}
+}
+
+// Name returns the integration name
+func (l *Integration) Name() string {
+	return IntegrationName
+}
+
+// Priority returns the collection priority
+func (l *Integration) Priority() int {
+	return 30
+}
+
+// SupportsRealtime indicates language package scanning is batch-only
+func (l *Integration) SupportsRealtime() bool {
+	return false
+}
+
+// IsAvailable checks whether any supported package tool is installed
+func (l *Integration) IsAvailable() bool {
+	for _, tool := range []string{"pip3", "pip", "pipx", "npm", "gem"} {
+		if _, err := exec.LookPath(tool); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Collect inventories packages from each available tool and enriches them with OSV results
+func (l *Integration) Collect(ctx context.Context) (*models.IntegrationData, error) {
+	startTime := time.Now()
+
+	data := &models.LanguagePackagesData{
+		Packages: make([]models.LanguagePackage, 0),
+	}
+
+	collectors := []struct {
+		source string
+		fn     func(context.Context) ([]models.LanguagePackage, error)
+	}{
+		{"pip", l.collectPip},
+		{"pipx", l.collectPipx},
+		{"npm", l.collectNpm},
+		{"gem", l.collectGem},
+	}
+	for _, c := range collectors {
+		pkgs, err := c.fn(ctx)
+		if err != nil {
+			l.logger.WithError(err).WithField("source", c.source).Debug("Language package inventory failed")
+			continue
+		}
+		if len(pkgs) > 0 {
+			data.Sources = append(data.Sources, c.source)
+		}
+		data.Packages = append(data.Packages, pkgs...)
+	}
+
+	if len(data.Packages) > 0 {
+		cache := loadOSVCache(l.cachePath)
+		if err := newOSVClient().annotate(ctx, data.Packages, cache); err != nil {
+			data.OSVError = err.Error()
+			l.logger.WithError(err).Warn("OSV vulnerability lookup failed, reporting inventory only")
+		} else {
+			data.OSVChecked = true
+		}
+		if err := cache.save(l.cachePath); err != nil {
+			l.logger.WithError(err).Debug("Failed to save OSV cache")
+		}
+	}
+
+	vulnerable := 0
+	for _, p := range data.Packages {
+		if len(p.Vulnerabilities) > 0 {
+			vulnerable++
+		}
+	}
+	l.logger.WithFields(logrus.Fields{
+		"packages":   len(data.Packages),
+		"vulnerable": vulnerable,
+	}).Info("Collected language packages")
+
+	return &models.IntegrationData{
+		Name:          l.Name(),
+		Enabled:       true,
+		Data:          data,
+		CollectedAt:   utils.GetCurrentTimeUTC(),
+		ExecutionTime: time.Since(startTime).Seconds(),
+	}, nil
+}
+
+// run executes a tool with a timeout and a C locale, returning stdout
+func run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	cmd := utils.CommandContext(ctx, name, args...)
+	cmd.Env = append(cmd.Env, "PIP_DISABLE_PIP_VERSION_CHECK=1", "NO_COLOR=1")
+	return cmd.Output()
+}
+
+func (l *Integration) collectPip(ctx context.Context) ([]models.LanguagePackage, error) {
+	// python3 -m pip targets the system interpreter; bare pip3 may be a venv shim
+	var out []byte
+	var err error
+	if _, lookErr := exec.LookPath("python3"); lookErr == nil {
+		out, err = run(ctx, "python3", "-m", "pip", "list", "--format=json")
+	} else {
+		out, err = run(ctx, "pip3", "list", "--format=json")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parsePipList(out)
+}
+
+func (l *Integration) collectPipx(ctx context.Context) ([]models.LanguagePackage, error) {
+	if _, err := exec.LookPath("pipx"); err != nil {
+		return nil, nil
+	}
+	out, err := run(ctx, "pipx", "list", "--json")
+	if err != nil {
+		return nil, err
+	}
+	return parsePipxList(out)
+}
+
+func (l *Integration) collectNpm(ctx context.Context) ([]models.LanguagePackage, error) {
+	if _, err := exec.LookPath("npm"); err != nil {
+		return nil, nil
+	}
+	// npm ls exits 1 on peer dependency problems but still prints valid JSON
+	out, err := run(ctx, "npm", "ls", "-g", "--depth=0", "--json")
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return parseNpmList(out)
+}
+
+func (l *Integration) collectGem(ctx context.Context) ([]models.LanguagePackage, error) {
+	if _, err := exec.LookPath("gem"); err != nil {
+		return nil, nil
+	}
+	out, err := run(ctx, "gem", "list", "--local")
+	if err != nil {
+		return nil, err
+	}
+	return parseGemList(string(out)), nil
+}
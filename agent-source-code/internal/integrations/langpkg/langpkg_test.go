@@ -0,0 +1,104 @@
+package langpkg
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePipList(t *testing.T) {
+	out := []byte(`[{"name": "requests", "version": "2.25.1"}, {"name": "urllib3", "version": "1.26.5"}, {"name": "", "version": "1"}]`)
+	pkgs, err := parsePipList(out)
+	require.NoError(t, err)
+	assert.Len(t, pkgs, 2)
+	assert.Equal(t, models.LanguagePackage{Ecosystem: "PyPI", Source: "pip", Name: "requests", Version: "2.25.1"}, pkgs[0])
+
+	_, err = parsePipList([]byte("not json"))
+	assert.Error(t, err)
+}
+
+func TestParsePipxList(t *testing.T) {
+	out := []byte(`{"venvs": {"black": {"metadata": {"main_package": {"package": "black", "package_version": "23.1.0"}}}}}`)
+	pkgs, err := parsePipxList(out)
+	require.NoError(t, err)
+	require.Len(t, pkgs, 1)
+	assert.Equal(t, "pipx", pkgs[0].Source)
+	assert.Equal(t, "23.1.0", pkgs[0].Version)
+}
+
+func TestParseNpmList(t *testing.T) {
+	out := []byte(`{"name": "lib", "dependencies": {"npm": {"version": "9.6.7"}, "corepack": {"version": "0.18.0"}}}`)
+	pkgs, err := parseNpmList(out)
+	require.NoError(t, err)
+	require.Len(t, pkgs, 2)
+	assert.Equal(t, "corepack", pkgs[0].Name)
+	assert.Equal(t, "npm", pkgs[1].Ecosystem)
+}
+
+func TestParseGemList(t *testing.T) {
+	out := `
+*** LOCAL GEMS ***
+
+bundler (default: 2.3.7)
+json (default: 2.6.1, 2.5.0)
+nokogiri (1.15.4 x86_64-linux)
+rake (13.0.6)
+`
+	pkgs := parseGemList(out)
+	require.Len(t, pkgs, 5)
+	assert.Equal(t, "bundler", pkgs[0].Name)
+	assert.Equal(t, "2.3.7", pkgs[0].Version)
+	assert.Equal(t, "2.5.0", pkgs[2].Version)
+	assert.Equal(t, "1.15.4", pkgs[3].Version)
+	assert.Equal(t, "RubyGems", pkgs[4].Ecosystem)
+}
+
+func TestOSVAnnotateUsesCache(t *testing.T) {
+	queries := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Queries []osvQuery `json:"queries"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		queries += len(req.Queries)
+
+		resp := osvBatchResponse{}
+		for _, q := range req.Queries {
+			entry := osvBatchResult{}
+			if q.Package.Name == "urllib3" {
+				entry.Vulns = append(entry.Vulns, osvVuln{ID: "GHSA-q2q7-5pp4-w6pg"})
+			}
+			resp.Results = append(resp.Results, entry)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	client := &osvClient{http: srv.Client(), url: srv.URL}
+	cachePath := filepath.Join(t.TempDir(), "osv.json")
+
+	pkgs := []models.LanguagePackage{
+		{Ecosystem: "PyPI", Name: "requests", Version: "2.31.0"},
+		{Ecosystem: "PyPI", Name: "urllib3", Version: "1.26.5"},
+	}
+	cache := loadOSVCache(cachePath)
+	require.NoError(t, client.annotate(context.Background(), pkgs, cache))
+	require.NoError(t, cache.save(cachePath))
+	assert.Empty(t, pkgs[0].Vulnerabilities)
+	assert.Equal(t, []string{"GHSA-q2q7-5pp4-w6pg"}, pkgs[1].Vulnerabilities)
+	assert.Equal(t, 2, queries)
+
+	// Second run is served from the cache
+	again := []models.LanguagePackage{{Ecosystem: "PyPI", Name: "urllib3", Version: "1.26.5"}}
+	require.NoError(t, client.annotate(context.Background(), again, loadOSVCache(cachePath)))
+	assert.Equal(t, []string{"GHSA-q2q7-5pp4-w6pg"}, again[0].Vulnerabilities)
+	assert.Equal(t, 2, queries)
+}
@@ -0,0 +1,179 @@
+package langpkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/utils"
+)
+
+const (
+	osvQueryBatchURL = "https://api.osv.dev/v1/querybatch"
+	// osvBatchSize is the maximum number of queries the OSV batch API accepts
+	osvBatchSize = 1000
+	// osvCacheTTL is how long a package@version result is trusted. New advisories
+	// are published daily, so a day keeps lookups cheap without going stale.
+	osvCacheTTL = 24 * time.Hour
+)
+
+// osvCacheEntry is a cached OSV result for one ecosystem/name/version
+type osvCacheEntry struct {
+	Vulns     []string  `json:"vulns"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// osvCache maps cacheKey -> result
+type osvCache struct {
+	Entries map[string]osvCacheEntry `json:"entries"`
+}
+
+func cacheKey(p models.LanguagePackage) string {
+	return p.Ecosystem + "/" + p.Name + "@" + p.Version
+}
+
+// loadOSVCache reads the cache file; any error yields an empty cache
+func loadOSVCache(path string) *osvCache {
+	c := &osvCache{Entries: make(map[string]osvCacheEntry)}
+	if path == "" {
+		return c
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	if err := json.Unmarshal(data, c); err != nil || c.Entries == nil {
+		return &osvCache{Entries: make(map[string]osvCacheEntry)}
+	}
+	return c
+}
+
+// save forgets answers older than osvCacheTTL before writing the cache
+func (c *osvCache) save(path string) error {
+	if path == "" {
+		return nil
+	}
+	for k, e := range c.Entries {
+		if time.Since(e.CheckedAt) > osvCacheTTL {
+			delete(c.Entries, k)
+		}
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(path, data, 0600)
+}
+
+// osvClient queries the OSV batch API
+type osvClient struct {
+	http *http.Client
+	url  string
+}
+
+func newOSVClient() *osvClient {
+	return &osvClient{
+		http: &http.Client{Timeout: 30 * time.Second},
+		url:  osvQueryBatchURL,
+	}
+}
+
+type osvQuery struct {
+	Package struct {
+		Name      string `json:"name"`
+		Ecosystem string `json:"ecosystem"`
+	} `json:"package"`
+	Version string `json:"version"`
+}
+
+type osvVuln struct {
+	ID string `json:"id"`
+}
+
+type osvBatchResult struct {
+	Vulns []osvVuln `json:"vulns"`
+}
+
+type osvBatchResponse struct {
+	Results []osvBatchResult `json:"results"`
+}
+
+// annotate fills Vulnerabilities on each package, using the cache where fresh
+// and querying OSV in batches for the rest
+func (o *osvClient) annotate(ctx context.Context, pkgs []models.LanguagePackage, cache *osvCache) error {
+	var pending []int
+	for i := range pkgs {
+		if e, ok := cache.Entries[cacheKey(pkgs[i])]; ok && time.Since(e.CheckedAt) < osvCacheTTL {
+			pkgs[i].Vulnerabilities = e.Vulns
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	for start := 0; start < len(pending); start += osvBatchSize {
+		end := min(start+osvBatchSize, len(pending))
+		batch := pending[start:end]
+
+		queries := make([]osvQuery, len(batch))
+		for j, idx := range batch {
+			queries[j].Package.Name = pkgs[idx].Name
+			queries[j].Package.Ecosystem = pkgs[idx].Ecosystem
+			queries[j].Version = pkgs[idx].Version
+		}
+
+		resp, err := o.queryBatch(ctx, queries)
+		if err != nil {
+			return err
+		}
+		if len(resp.Results) != len(batch) {
+			return fmt.Errorf("OSV returned %d results for %d queries", len(resp.Results), len(batch))
+		}
+
+		now := time.Now()
+		for j, idx := range batch {
+			var ids []string
+			for _, v := range resp.Results[j].Vulns {
+				ids = append(ids, v.ID)
+			}
+			pkgs[idx].Vulnerabilities = ids
+			cache.Entries[cacheKey(pkgs[idx])] = osvCacheEntry{Vulns: ids, CheckedAt: now}
+		}
+	}
+	return nil
+}
+
+func (o *osvClient) queryBatch(ctx context.Context, queries []osvQuery) (*osvBatchResponse, error) {
+	body, err := json.Marshal(map[string]interface{}{"queries": queries})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("OSV request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return nil, fmt.Errorf("OSV request failed with status %d: %s", resp.StatusCode, snippet)
+	}
+
+	var out osvBatchResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 32<<20)).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode OSV response: %w", err)
+	}
+	return &out, nil
+}
@@ -0,0 +1,145 @@
+package langpkg
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// OSV ecosystem identifiers
+const (
+	ecosystemPyPI     = "PyPI"
+	ecosystemNpm      = "npm"
+	ecosystemRubyGems = "RubyGems"
+)
+
+// parsePipList parses `pip list --format=json`
+func parsePipList(out []byte) ([]models.LanguagePackage, error) {
+	var entries []struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(out, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse pip output: %w", err)
+	}
+	pkgs := make([]models.LanguagePackage, 0, len(entries))
+	for _, e := range entries {
+		if e.Name == "" || e.Version == "" {
+			continue
+		}
+		pkgs = append(pkgs, models.LanguagePackage{
+			Ecosystem: ecosystemPyPI,
+			Source:    "pip",
+			Name:      e.Name,
+			Version:   e.Version,
+		})
+	}
+	return pkgs, nil
+}
+
+// parsePipxList parses `pipx list --json`, reporting each venv's main package
+func parsePipxList(out []byte) ([]models.LanguagePackage, error) {
+	var doc struct {
+		Venvs map[string]struct {
+			Metadata struct {
+				MainPackage struct {
+					Package        string `json:"package"`
+					PackageVersion string `json:"package_version"`
+				} `json:"main_package"`
+			} `json:"metadata"`
+		} `json:"venvs"`
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse pipx output: %w", err)
+	}
+	pkgs := make([]models.LanguagePackage, 0, len(doc.Venvs))
+	for venv, v := range doc.Venvs {
+		mp := v.Metadata.MainPackage
+		name := mp.Package
+		if name == "" {
+			name = venv
+		}
+		if mp.PackageVersion == "" {
+			continue
+		}
+		pkgs = append(pkgs, models.LanguagePackage{
+			Ecosystem: ecosystemPyPI,
+			Source:    "pipx",
+			Name:      name,
+			Version:   mp.PackageVersion,
+		})
+	}
+	sortPackages(pkgs)
+	return pkgs, nil
+}
+
+// parseNpmList parses `npm ls -g --depth=0 --json`
+func parseNpmList(out []byte) ([]models.LanguagePackage, error) {
+	var doc struct {
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse npm output: %w", err)
+	}
+	pkgs := make([]models.LanguagePackage, 0, len(doc.Dependencies))
+	for name, dep := range doc.Dependencies {
+		if dep.Version == "" {
+			continue
+		}
+		pkgs = append(pkgs, models.LanguagePackage{
+			Ecosystem: ecosystemNpm,
+			Source:    "npm",
+			Name:      name,
+			Version:   dep.Version,
+		})
+	}
+	sortPackages(pkgs)
+	return pkgs, nil
+}
+
+// parseGemList parses `gem list --local`. Lines look like:
+//
+//	rake (13.0.6, 12.3.3)
+//	bundler (default: 2.3.7)
+//	json (default: 2.6.1, 2.5.0)
+//
+// Every installed version is reported since each can be loaded.
+func parseGemList(out string) []models.LanguagePackage {
+	var pkgs []models.LanguagePackage
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		open := strings.Index(line, " (")
+		if open <= 0 || !strings.HasSuffix(line, ")") {
+			continue
+		}
+		name := line[:open]
+		for _, v := range strings.Split(line[open+2:len(line)-1], ",") {
+			v = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(v), "default:"))
+			// Platform-specific gems: "1.15.4 x86_64-linux"
+			v, _, _ = strings.Cut(v, " ")
+			if v == "" {
+				continue
+			}
+			pkgs = append(pkgs, models.LanguagePackage{
+				Ecosystem: ecosystemRubyGems,
+				Source:    "gem",
+				Name:      name,
+				Version:   v,
+			})
+		}
+	}
+	return pkgs
+}
+
+func sortPackages(pkgs []models.LanguagePackage) {
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].Name < pkgs[j].Name
+	})
+}
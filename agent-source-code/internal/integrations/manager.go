@@ -7,10 +7,13 @@ import (
 	"sync"
 	"time"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/secrets"
+	"patchmon-agent/internal/tracing"
 	"patchmon-agent/internal/utils"
-	"patchmon-agent/pkg/models"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Manager orchestrates integration discovery and data collection
@@ -19,6 +22,7 @@ type Manager struct {
 	logger           *logrus.Logger
 	mu               sync.RWMutex
 	isEnabledChecker func(string) bool // Optional function to check if integration is enabled
+	secrets          *secrets.Store    // Optional keystore for SecretsConsumer integrations
 }
 
 // NewManager creates a new integration manager
@@ -36,10 +40,21 @@ func (m *Manager) SetEnabledChecker(checker func(string) bool) {
 	m.isEnabledChecker = checker
 }
 
+// SetSecretStore gives integrations registered from now on access to their
+// own secrets in store
+func (m *Manager) SetSecretStore(store *secrets.Store) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.secrets = store
+}
+
 // Register adds an integration to the manager
 func (m *Manager) Register(integration Integration) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if consumer, ok := integration.(SecretsConsumer); ok && m.secrets != nil {
+		consumer.SetSecrets(m.secrets.For(integration.Name()))
+	}
 	m.integrations = append(m.integrations, integration)
 	m.logger.WithField("integration", integration.Name()).Debug("Registered integration")
 }
@@ -102,7 +117,9 @@ func (m *Manager) CollectAll(ctx context.Context) map[string]*models.Integration
 			m.logger.WithField("integration", name).Debug("Starting collection")
 			startTime := time.Now()
 
+			ctx, span := tracing.Start(ctx, "integration.collect", attribute.String("integration.name", name))
 			data, err := integ.Collect(ctx)
+			tracing.End(span, err)
 			if err != nil {
 				m.logger.WithFields(logrus.Fields{
 					"integration": name,
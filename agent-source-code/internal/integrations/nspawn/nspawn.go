@@ -0,0 +1,240 @@
+// Package nspawn inventories the machines registered with systemd-machined,
+// normally systemd-nspawn containers, and the updates pending inside them
+package nspawn
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner"
+	"patchmon-agent/internal/packages"
+	"patchmon-agent/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IntegrationName is the config/integration key for the nspawn machine inventory
+const IntegrationName = "nspawn"
+
+// machineCommandTimeout bounds each command run inside a machine, so a
+// machine whose service manager never answers can't stall the others
+const machineCommandTimeout = 2 * time.Minute
+
+// Integration implements the Integration interface for systemd-nspawn machines
+type Integration struct {
+	logger *logrus.Logger
+	runner cmdrunner.Runner
+	// root prefixes the /proc paths read on the host, for tests
+	root string
+}
+
+// New creates a new nspawn machine integration
+func New(logger *logrus.Logger) *Integration {
+	return &Integration{logger: logger, runner: cmdrunner.Default, root: "/"}
+}
+
+// Name returns the integration name
+func (n *Integration) Name() string {
+	return IntegrationName
+}
+
+// Priority returns the collection priority
+func (n *Integration) Priority() int {
+	return 50
+}
+
+// SupportsRealtime indicates the machine inventory is batch-only
+func (n *Integration) SupportsRealtime() bool {
+	return false
+}
+
+// IsAvailable reports whether machinectl is installed
+func (n *Integration) IsAvailable() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	_, err := n.runner.LookPath("machinectl")
+	return err == nil
+}
+
+// Collect lists the registered machines and, for containers, the packages
+// pending update inside them
+func (n *Integration) Collect(ctx context.Context) (*models.IntegrationData, error) {
+	startTime := time.Now()
+
+	out, err := n.runner.Output(ctx, "machinectl", "list", "--no-legend", "--no-pager")
+	if err != nil {
+		return nil, fmt.Errorf("machinectl list failed: %w", err)
+	}
+
+	data := &models.NspawnData{Machines: make([]models.NspawnMachine, 0)}
+	for _, name := range parseMachineList(string(out)) {
+		if err := ctx.Err(); err != nil {
+			data.Warnings = append(data.Warnings, fmt.Sprintf("stopped before machine %s: %v", name, err))
+			break
+		}
+		machine, leader, err := n.showMachine(ctx, name)
+		if err != nil {
+			data.Warnings = append(data.Warnings, err.Error())
+			continue
+		}
+		if leader != "" {
+			n.readOSRelease(leader, &machine)
+		}
+		if machine.Class == "container" {
+			n.collectPackages(name, &machine)
+		}
+		data.Machines = append(data.Machines, machine)
+	}
+
+	n.logger.WithField("machines", len(data.Machines)).Info("Collected nspawn machines")
+
+	return &models.IntegrationData{
+		Name:          n.Name(),
+		Enabled:       true,
+		Data:          data,
+		CollectedAt:   utils.GetCurrentTimeUTC(),
+		ExecutionTime: time.Since(startTime).Seconds(),
+	}, nil
+}
+
+// parseMachineList returns the machine names from machinectl list
+// --no-legend. Only the first column is read: later columns differ between
+// systemd versions, and long address lists wrap onto lines of their own.
+func parseMachineList(output string) []string {
+	var names []string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		names = append(names, strings.Fields(line)[0])
+	}
+	return names
+}
+
+// showMachine reads the machine's properties from machinectl show, returning
+// the PID of its leader process too
+func (n *Integration) showMachine(ctx context.Context, name string) (models.NspawnMachine, string, error) {
+	machine := models.NspawnMachine{Name: name, Updates: make([]models.Package, 0)}
+	out, err := n.runner.Output(ctx, "machinectl", "show", "--property=Class,Service,State,Leader,RootDirectory", name)
+	if err != nil {
+		return machine, "", fmt.Errorf("machinectl show %s failed: %w", name, err)
+	}
+
+	var leader string
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Class":
+			machine.Class = value
+		case "Service":
+			machine.Service = value
+		case "State":
+			machine.State = value
+		case "Leader":
+			leader = value
+		case "RootDirectory":
+			machine.RootDirectory = value
+		}
+	}
+	return machine, leader, nil
+}
+
+// readOSRelease fills the machine's OS from its os-release, read through the
+// leader's root so it works whatever runs inside the machine. Needs root.
+func (n *Integration) readOSRelease(leader string, machine *models.NspawnMachine) {
+	for _, p := range []string{"etc/os-release", "usr/lib/os-release"} {
+		f, err := os.Open(filepath.Join(n.root, "proc", leader, "root", p))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+			if !ok {
+				continue
+			}
+			value = strings.Trim(value, `"'`)
+			switch key {
+			case "ID":
+				machine.OSID = value
+			case "VERSION_ID":
+				machine.OSVersion = value
+			case "PRETTY_NAME":
+				machine.OSPrettyName = value
+			}
+		}
+		_ = f.Close()
+		return
+	}
+}
+
+// collectPackages runs the package collectors inside the container. Package
+// indexes are not refreshed there; updates are those the container's own
+// index already knows of.
+func (n *Integration) collectPackages(name string, machine *models.NspawnMachine) {
+	pkgManager := packages.New(n.logger, packages.CacheRefreshConfig{Mode: "never"}).
+		WithRunner(machineRunner{machine: name, runner: n.runner})
+
+	machine.PackageManager = pkgManager.DetectPackageManager()
+	if machine.PackageManager == "unknown" {
+		machine.PackageManager = ""
+		machine.Error = "no supported package manager found, or commands can't be run in the machine (systemd-run --machine needs systemd inside it)"
+		return
+	}
+	pkgs, err := pkgManager.GetPackages()
+	if err != nil {
+		n.logger.WithError(err).WithField("machine", name).Warn("Failed to get packages inside machine")
+		machine.Error = err.Error()
+		return
+	}
+	machine.PackageCount = len(pkgs)
+	for _, p := range pkgs {
+		if !p.NeedsUpdate {
+			continue
+		}
+		machine.Updates = append(machine.Updates, p)
+		if p.IsSecurityUpdate {
+			machine.SecurityUpdates++
+		}
+	}
+}
+
+// machineRunner runs commands inside a machine with systemd-run, so the
+// package collectors work against the machine's own package manager
+type machineRunner struct {
+	machine string
+	runner  cmdrunner.Runner
+}
+
+// Output runs the command in the machine and waits for it, passing its
+// output and exit status through
+func (m machineRunner) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, machineCommandTimeout)
+	defer cancel()
+	runArgs := []string{"--machine=" + m.machine, "--quiet", "--wait", "--pipe", "--collect", "--setenv=LC_ALL=C", "--", name}
+	return m.runner.Output(ctx, "systemd-run", append(runArgs, args...)...)
+}
+
+// LookPath finds name on the PATH inside the machine
+func (m machineRunner) LookPath(name string) (string, error) {
+	out, err := m.Output(context.Background(), "/bin/sh", "-c", `command -v "$1"`, "sh", name)
+	path := strings.TrimSpace(string(out))
+	if err != nil || path == "" {
+		return "", fmt.Errorf("%s not found in machine %s", name, m.machine)
+	}
+	return path, nil
+}
@@ -0,0 +1,54 @@
+package nspawn
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner/cmdrunnertest"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollect(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	replay := cmdrunnertest.Load(t, filepath.Join("testdata", "commands"))
+	n := New(logger)
+	n.runner = replay
+	n.root = filepath.Join("testdata", "root")
+
+	result, err := n.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, replay.Missed())
+	data := result.Data.(*models.NspawnData)
+	require.Len(t, data.Machines, 3, "the wrapped address line is not a machine")
+
+	web := data.Machines[0]
+	assert.Equal(t, "container", web.Class)
+	assert.Equal(t, "/var/lib/machines/web", web.RootDirectory)
+	assert.Equal(t, "alpine", web.OSID)
+	assert.Equal(t, "3.20.3", web.OSVersion)
+	assert.Equal(t, "apk", web.PackageManager)
+	assert.Equal(t, 11, web.PackageCount)
+	assert.Empty(t, web.Error)
+	var names []string
+	for _, p := range web.Updates {
+		names = append(names, p.Name)
+	}
+	assert.Contains(t, names, "curl")
+
+	build := data.Machines[1]
+	assert.Equal(t, "Debian GNU/Linux 12 (bookworm)", build.OSPrettyName, "read from usr/lib/os-release")
+	assert.Empty(t, build.PackageManager)
+	assert.NotEmpty(t, build.Error, "systemd-run can't reach the container")
+
+	win := data.Machines[2]
+	assert.Equal(t, "vm", win.Class)
+	assert.Empty(t, win.OSID)
+	assert.Empty(t, win.Error, "packages are not collected from VMs")
+}
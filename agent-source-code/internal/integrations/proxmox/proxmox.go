@@ -0,0 +1,253 @@
+// Package proxmox inventories a Proxmox VE node: its QEMU and LXC guests, the
+// cluster it belongs to and the pending updates from the Proxmox repositories
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner"
+	"patchmon-agent/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IntegrationName is the config/integration key for the Proxmox VE inventory
+const IntegrationName = "proxmox"
+
+// proxmoxOrigin is the apt origin of packages from the Proxmox repositories
+// (enterprise, no-subscription and test alike)
+const proxmoxOrigin = "Proxmox"
+
+// Integration implements the Integration interface for Proxmox VE
+type Integration struct {
+	logger *logrus.Logger
+	runner cmdrunner.Runner
+}
+
+// New creates a new Proxmox VE integration
+func New(logger *logrus.Logger) *Integration {
+	return &Integration{logger: logger, runner: cmdrunner.Default}
+}
+
+// Name returns the integration name
+func (p *Integration) Name() string {
+	return IntegrationName
+}
+
+// Priority returns the collection priority
+func (p *Integration) Priority() int {
+	return 50
+}
+
+// SupportsRealtime indicates the Proxmox inventory is batch-only
+func (p *Integration) SupportsRealtime() bool {
+	return false
+}
+
+// IsAvailable reports whether this is a Proxmox VE node: pvesh is installed
+func (p *Integration) IsAvailable() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	_, err := p.runner.LookPath("pvesh")
+	return err == nil
+}
+
+// Collect reads the node's version, cluster status, guests and pending
+// Proxmox updates through pvesh, the local API client. It needs root.
+func (p *Integration) Collect(ctx context.Context) (*models.IntegrationData, error) {
+	startTime := time.Now()
+
+	var version struct {
+		Version string `json:"version"`
+	}
+	if err := p.get(ctx, &version, "/version"); err != nil {
+		return nil, err
+	}
+	data := &models.ProxmoxData{
+		PVEVersion: version.Version,
+		Guests:     make([]models.ProxmoxGuest, 0),
+		Updates:    make([]models.Package, 0),
+	}
+
+	var status []clusterStatusEntry
+	if err := p.get(ctx, &status, "/cluster/status"); err != nil {
+		return nil, err
+	}
+	data.Node, data.Cluster = parseClusterStatus(status)
+	if data.Node == "" {
+		// The node list is unavailable while pmxcfs is down
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("cannot tell which Proxmox node this is: %w", err)
+		}
+		data.Node, _, _ = strings.Cut(hostname, ".")
+		data.Warnings = append(data.Warnings, "cluster status did not list the local node; using the hostname")
+	}
+
+	var resources []clusterResource
+	if err := p.get(ctx, &resources, "/cluster/resources", "--type", "vm"); err != nil {
+		data.Warnings = append(data.Warnings, err.Error())
+	} else {
+		data.Guests = localGuests(resources, data.Node)
+	}
+
+	var updates []aptUpdate
+	if err := p.get(ctx, &updates, "/nodes/"+data.Node+"/apt/update"); err != nil {
+		data.Warnings = append(data.Warnings, err.Error())
+	} else {
+		data.Updates = proxmoxUpdates(updates)
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"node":    data.Node,
+		"guests":  len(data.Guests),
+		"updates": len(data.Updates),
+	}).Info("Collected Proxmox VE inventory")
+
+	return &models.IntegrationData{
+		Name:          p.Name(),
+		Enabled:       true,
+		Data:          data,
+		CollectedAt:   utils.GetCurrentTimeUTC(),
+		ExecutionTime: time.Since(startTime).Seconds(),
+	}, nil
+}
+
+// get reads an API path with pvesh into v
+func (p *Integration) get(ctx context.Context, v any, path string, args ...string) error {
+	argv := append([]string{"get", path}, args...)
+	out, err := p.runner.Output(ctx, "pvesh", append(argv, "--output-format", "json")...)
+	if err != nil {
+		return fmt.Errorf("pvesh get %s failed: %w", path, err)
+	}
+	if err := json.Unmarshal(out, v); err != nil {
+		return fmt.Errorf("pvesh get %s returned invalid JSON: %w", path, err)
+	}
+	return nil
+}
+
+// clusterStatusEntry is one entry of /cluster/status: the cluster itself, or
+// one of its nodes. A standalone node lists only itself.
+type clusterStatusEntry struct {
+	Type    string `json:"type"` // cluster or node
+	Name    string `json:"name"`
+	NodeID  int    `json:"nodeid"`
+	IP      string `json:"ip"`
+	Online  int    `json:"online"`
+	Local   int    `json:"local"`
+	Quorate int    `json:"quorate"`
+	Version int    `json:"version"`
+}
+
+// parseClusterStatus returns the local node's name and, when the node is in a
+// cluster, the cluster
+func parseClusterStatus(entries []clusterStatusEntry) (string, *models.ProxmoxCluster) {
+	var local string
+	var cluster *models.ProxmoxCluster
+	var nodes []models.ProxmoxClusterNode
+	for _, e := range entries {
+		switch e.Type {
+		case "cluster":
+			cluster = &models.ProxmoxCluster{Name: e.Name, Quorate: e.Quorate == 1, Version: e.Version}
+		case "node":
+			if e.Local == 1 {
+				local = e.Name
+			}
+			nodes = append(nodes, models.ProxmoxClusterNode{
+				Name:   e.Name,
+				NodeID: e.NodeID,
+				IP:     e.IP,
+				Online: e.Online == 1,
+				Local:  e.Local == 1,
+			})
+		}
+	}
+	if cluster != nil {
+		cluster.Nodes = nodes
+	}
+	return local, cluster
+}
+
+// clusterResource is a guest from /cluster/resources --type vm
+type clusterResource struct {
+	VMID     int     `json:"vmid"`
+	Name     string  `json:"name"`
+	Node     string  `json:"node"`
+	Type     string  `json:"type"`
+	Status   string  `json:"status"`
+	Template int     `json:"template"`
+	MaxCPU   float64 `json:"maxcpu"`
+	MaxMem   int64   `json:"maxmem"`
+	MaxDisk  int64   `json:"maxdisk"`
+	Uptime   int64   `json:"uptime"`
+	Tags     string  `json:"tags"`
+	HAState  string  `json:"hastate"`
+	Lock     string  `json:"lock"`
+}
+
+// localGuests returns the guests running on node. Other nodes of the cluster
+// report their own, so a guest is counted once wherever it migrates.
+func localGuests(resources []clusterResource, node string) []models.ProxmoxGuest {
+	guests := make([]models.ProxmoxGuest, 0)
+	for _, r := range resources {
+		if r.Node != node {
+			continue
+		}
+		guest := models.ProxmoxGuest{
+			VMID:          r.VMID,
+			Name:          r.Name,
+			Type:          r.Type,
+			Status:        r.Status,
+			Template:      r.Template == 1,
+			CPUs:          r.MaxCPU,
+			MemoryBytes:   r.MaxMem,
+			DiskBytes:     r.MaxDisk,
+			UptimeSeconds: r.Uptime,
+			HAState:       r.HAState,
+			Lock:          r.Lock,
+		}
+		// Tags are separated by semicolons, or by commas and spaces in
+		// older configs
+		if r.Tags != "" {
+			guest.Tags = strings.FieldsFunc(r.Tags, func(c rune) bool { return c == ';' || c == ',' || c == ' ' })
+		}
+		guests = append(guests, guest)
+	}
+	return guests
+}
+
+// aptUpdate is an entry of /nodes/{node}/apt/update, the updates the node's
+// daily pveupdate run found
+type aptUpdate struct {
+	Package    string `json:"Package"`
+	Version    string `json:"Version"`
+	OldVersion string `json:"OldVersion"`
+	Origin     string `json:"Origin"`
+}
+
+// proxmoxUpdates keeps the updates from the Proxmox repositories. The rest
+// (Debian's) are already in the host's package report.
+func proxmoxUpdates(updates []aptUpdate) []models.Package {
+	pkgs := make([]models.Package, 0)
+	for _, u := range updates {
+		if u.Origin != proxmoxOrigin {
+			continue
+		}
+		pkgs = append(pkgs, models.Package{
+			Name:             u.Package,
+			CurrentVersion:   u.OldVersion,
+			AvailableVersion: u.Version,
+			NeedsUpdate:      true,
+			SourceRepository: u.Origin,
+		})
+	}
+	return pkgs
+}
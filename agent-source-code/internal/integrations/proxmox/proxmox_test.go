@@ -0,0 +1,70 @@
+package proxmox
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner/cmdrunnertest"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollect(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	replay := cmdrunnertest.Load(t, filepath.Join("testdata", "commands"))
+	p := New(logger)
+	p.runner = replay
+
+	result, err := p.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, replay.Missed())
+	data := result.Data.(*models.ProxmoxData)
+	assert.Equal(t, "pve1", data.Node)
+	assert.Equal(t, "8.2.4", data.PVEVersion)
+	assert.Empty(t, data.Warnings)
+
+	require.NotNil(t, data.Cluster)
+	assert.Equal(t, "lab", data.Cluster.Name)
+	assert.True(t, data.Cluster.Quorate)
+	assert.Equal(t, 7, data.Cluster.Version)
+	require.Len(t, data.Cluster.Nodes, 3)
+	assert.True(t, data.Cluster.Nodes[0].Local)
+	assert.False(t, data.Cluster.Nodes[2].Online)
+
+	require.Len(t, data.Guests, 3, "db01 runs on another node")
+	assert.Equal(t, models.ProxmoxGuest{
+		VMID:          100,
+		Name:          "web01",
+		Type:          "qemu",
+		Status:        "running",
+		CPUs:          4,
+		MemoryBytes:   8589934592,
+		DiskBytes:     34359738368,
+		UptimeSeconds: 86400,
+		Tags:          []string{"prod", "web"},
+		HAState:       "started",
+	}, data.Guests[0])
+	assert.Equal(t, "lxc", data.Guests[1].Type)
+	assert.Equal(t, 0.5, data.Guests[1].CPUs)
+	assert.Equal(t, "backup", data.Guests[1].Lock)
+	assert.True(t, data.Guests[2].Template)
+
+	assert.Equal(t, []models.Package{
+		{Name: "pve-manager", CurrentVersion: "8.2.2", AvailableVersion: "8.2.4", NeedsUpdate: true, SourceRepository: "Proxmox"},
+		{Name: "proxmox-kernel-6.8", CurrentVersion: "6.8.8-2", AvailableVersion: "6.8.12-1", NeedsUpdate: true, SourceRepository: "Proxmox"},
+	}, data.Updates, "Debian updates are in the host's package report")
+}
+
+func TestParseClusterStatusStandalone(t *testing.T) {
+	node, cluster := parseClusterStatus([]clusterStatusEntry{
+		{Type: "node", Name: "pve", NodeID: 0, Online: 1, Local: 1},
+	})
+	assert.Equal(t, "pve", node)
+	assert.Nil(t, cluster)
+}
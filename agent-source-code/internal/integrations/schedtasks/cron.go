@@ -0,0 +1,163 @@
+package schedtasks
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// periodicDirs are run-parts directories and the schedule they run on
+var periodicDirs = []struct{ dir, schedule string }{
+	{"/etc/cron.hourly", "hourly"},
+	{"/etc/cron.daily", "daily"},
+	{"/etc/cron.weekly", "weekly"},
+	{"/etc/cron.monthly", "monthly"},
+}
+
+// userCrontabDirs hold per-user crontabs: Debian, RHEL, SUSE, FreeBSD
+var userCrontabDirs = []string{"/var/spool/cron/crontabs", "/var/spool/cron", "/var/spool/cron/tabs", "/var/cron/tabs"}
+
+func (s *Integration) collectCron(data *models.ScheduledTasksData) {
+	s.readCrontab(data, s.path("/etc/crontab"), models.TaskSourceCrontab, "")
+
+	if entries, err := os.ReadDir(s.path("/etc/cron.d")); err == nil {
+		for _, e := range entries {
+			if e.IsDir() || skipCronFile(e.Name()) {
+				continue
+			}
+			s.readCrontab(data, filepath.Join(s.path("/etc/cron.d"), e.Name()), models.TaskSourceCronD, "")
+		}
+	}
+
+	for _, p := range periodicDirs {
+		entries, err := os.ReadDir(s.path(p.dir))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() || skipCronFile(e.Name()) {
+				continue
+			}
+			file := filepath.Join(s.path(p.dir), e.Name())
+			info, err := os.Stat(file)
+			if err != nil {
+				continue
+			}
+			data.Tasks = append(data.Tasks, models.ScheduledTask{
+				Source:   models.TaskSourceCronPeriodic,
+				File:     s.hostPath(file),
+				User:     "root",
+				Schedule: p.schedule,
+				Command:  s.hostPath(file),
+				// run-parts skips scripts that aren't executable
+				Enabled:    info.Mode().Perm()&0o111 != 0,
+				ModifiedAt: modTime(info),
+			})
+		}
+	}
+
+	for _, dir := range userCrontabDirs {
+		entries, err := os.ReadDir(s.path(dir))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			// /var/spool/cron also holds the crontabs/ and tabs/ directories
+			if e.IsDir() || skipCronFile(e.Name()) {
+				continue
+			}
+			s.readCrontab(data, filepath.Join(s.path(dir), e.Name()), models.TaskSourceUserCrontab, e.Name())
+		}
+	}
+}
+
+// skipCronFile mirrors the names cron and run-parts ignore: dotfiles, editor
+// backups and package manager leftovers
+func skipCronFile(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") ||
+		strings.HasSuffix(name, ".dpkg-old") || strings.HasSuffix(name, ".dpkg-dist") ||
+		strings.HasSuffix(name, ".rpmsave") || strings.HasSuffix(name, ".rpmnew") ||
+		name == "placeholder"
+}
+
+// readCrontab adds the jobs of one crontab file. System crontabs have a user
+// field after the schedule; user crontabs run as owner.
+func (s *Integration) readCrontab(data *models.ScheduledTasksData, path, source, owner string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			data.Warnings = append(data.Warnings, "crontab unreadable: "+err.Error())
+		}
+		return
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		data.Warnings = append(data.Warnings, "crontab unreadable: "+err.Error())
+		return
+	}
+	for _, job := range parseCrontab(content, owner == "") {
+		if owner != "" {
+			job.User = owner
+		}
+		job.Source = source
+		job.File = s.hostPath(path)
+		job.Enabled = true
+		job.ModifiedAt = modTime(info)
+		data.Tasks = append(data.Tasks, job)
+	}
+}
+
+// parseCrontab returns the jobs in a crontab. Environment assignments and
+// comments are skipped.
+func parseCrontab(content []byte, hasUser bool) []models.ScheduledTask {
+	var jobs []models.ScheduledTask
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || isEnvAssignment(line) {
+			continue
+		}
+		fields := strings.Fields(line)
+		scheduleFields := 5
+		if strings.HasPrefix(fields[0], "@") {
+			scheduleFields = 1
+		}
+		commandAt := scheduleFields
+		if hasUser {
+			commandAt++
+		}
+		if len(fields) <= commandAt {
+			continue
+		}
+		job := models.ScheduledTask{
+			Schedule: strings.Join(fields[:scheduleFields], " "),
+			Command:  strings.Join(fields[commandAt:], " "),
+		}
+		if hasUser {
+			job.User = fields[scheduleFields]
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// isEnvAssignment reports whether a crontab line sets a variable (SHELL=,
+// MAILTO=, PATH=) rather than scheduling a job
+func isEnvAssignment(line string) bool {
+	eq := strings.IndexByte(line, '=')
+	if eq <= 0 {
+		return false
+	}
+	name := strings.TrimSpace(line[:eq])
+	return !strings.ContainsAny(name, " \t*/,")
+}
+
+func modTime(info os.FileInfo) *time.Time {
+	t := info.ModTime().UTC()
+	return &t
+}
@@ -0,0 +1,99 @@
+// Package schedtasks inventories cron jobs and systemd timers, so forgotten
+// jobs and unexpected persistence show up centrally
+package schedtasks
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IntegrationName is the config/integration key for the scheduled task inventory
+const IntegrationName = "scheduled-tasks"
+
+// Integration implements the Integration interface for scheduled tasks
+type Integration struct {
+	logger *logrus.Logger
+	// root prefixes every path read, for tests
+	root string
+	// systemctl runs systemctl with the given arguments
+	systemctl func(ctx context.Context, args ...string) ([]byte, error)
+}
+
+// New creates a new scheduled task integration
+func New(logger *logrus.Logger) *Integration {
+	return &Integration{logger: logger, root: "/", systemctl: runSystemctl}
+}
+
+func runSystemctl(ctx context.Context, args ...string) ([]byte, error) {
+	return utils.CommandContext(ctx, "systemctl", args...).Output()
+}
+
+// Name returns the integration name
+func (s *Integration) Name() string {
+	return IntegrationName
+}
+
+// Priority returns the collection priority
+func (s *Integration) Priority() int {
+	return 50
+}
+
+// SupportsRealtime indicates the task inventory is batch-only
+func (s *Integration) SupportsRealtime() bool {
+	return false
+}
+
+// IsAvailable reports whether the host has cron or systemd
+func (s *Integration) IsAvailable() bool {
+	if runtime.GOOS == "windows" {
+		return false
+	}
+	for _, p := range []string{"/etc/crontab", "/etc/cron.d", "/run/systemd/system"} {
+		if _, err := os.Stat(s.path(p)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Integration) path(p string) string {
+	return filepath.Join(s.root, p)
+}
+
+// hostPath undoes path, for paths reported to the server
+func (s *Integration) hostPath(p string) string {
+	rel, err := filepath.Rel(s.root, p)
+	if err != nil {
+		return p
+	}
+	return "/" + filepath.ToSlash(rel)
+}
+
+// Collect reads cron tables and lists systemd timers
+func (s *Integration) Collect(ctx context.Context) (*models.IntegrationData, error) {
+	startTime := time.Now()
+
+	data := &models.ScheduledTasksData{Tasks: make([]models.ScheduledTask, 0)}
+	s.collectCron(data)
+	if _, err := os.Stat(s.path("/run/systemd/system")); err == nil {
+		s.collectTimers(ctx, data)
+	}
+
+	s.logger.WithField("tasks", len(data.Tasks)).Info("Collected scheduled tasks")
+
+	return &models.IntegrationData{
+		Name:          s.Name(),
+		Enabled:       true,
+		Data:          data,
+		CollectedAt:   utils.GetCurrentTimeUTC(),
+		ExecutionTime: time.Since(startTime).Seconds(),
+	}, nil
+}
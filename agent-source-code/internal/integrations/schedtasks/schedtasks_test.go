@@ -0,0 +1,172 @@
+package schedtasks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, root, path, content string, mode os.FileMode) {
+	t.Helper()
+	full := filepath.Join(root, path)
+	require.NoError(t, os.MkdirAll(filepath.Dir(full), 0o755))
+	require.NoError(t, os.WriteFile(full, []byte(content), mode))
+}
+
+const timerShow = `Id=apt-daily.timer
+Triggers=apt-daily.service
+TimersCalendar={ OnCalendar=*-*-* 06,18:00:00 ; next_elapse=Sat 2026-10-17 18:00:00 UTC }
+NextElapseUSecRealtime=Sat 2026-10-17 18:00:00 UTC
+LastTriggerUSec=Sat 2026-10-17 06:00:04 UTC
+ActiveState=active
+FragmentPath=/usr/lib/systemd/system/apt-daily.timer
+
+Id=backup.timer
+Triggers=backup.service
+TimersMonotonic={ OnBootSec=15min ; next_elapse=n/a }
+TimersMonotonic={ OnUnitActiveSec=1d ; next_elapse=n/a }
+NextElapseUSecRealtime=
+LastTriggerUSec=n/a
+ActiveState=inactive
+FragmentPath=/etc/systemd/system/backup.timer
+`
+
+const serviceShow = `Id=apt-daily.service
+ExecStart={ path=/usr/lib/apt/apt.systemd.daily ; argv[]=/usr/lib/apt/apt.systemd.daily update ; ignore_errors=no ; start_time=[n/a] ; stop_time=[n/a] ; pid=0 ; code=(null) ; status=0/0 }
+User=
+
+Id=backup.service
+ExecStart={ path=/usr/local/bin/backup ; argv[]=/usr/local/bin/backup --all ; ignore_errors=no }
+User=backup
+`
+
+func fakeSystemctl(_ context.Context, args ...string) ([]byte, error) {
+	switch {
+	case args[0] == "list-units":
+		return []byte("apt-daily.timer loaded active waiting Daily apt download activities\nbackup.timer loaded inactive dead Nightly backup\n"), nil
+	case args[0] == "show" && strings.HasSuffix(args[len(args)-1], ".timer"):
+		return []byte(timerShow), nil
+	case args[0] == "show":
+		return []byte(serviceShow), nil
+	}
+	return nil, errors.New("unexpected systemctl call")
+}
+
+func testIntegration(t *testing.T) (*Integration, string) {
+	root := t.TempDir()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return &Integration{logger: logger, root: root, systemctl: fakeSystemctl}, root
+}
+
+func TestCollect(t *testing.T) {
+	integ, root := testIntegration(t)
+	writeFile(t, root, "/etc/crontab", `SHELL=/bin/sh
+PATH=/usr/local/sbin:/usr/local/bin:/sbin:/bin:/usr/sbin:/usr/bin
+# m h dom mon dow user	command
+17 *	* * *	root	cd / && run-parts --report /etc/cron.hourly
+`, 0o644)
+	writeFile(t, root, "/etc/cron.d/certbot", "0 */12 * * * root test -x /usr/bin/certbot && certbot -q renew\n", 0o644)
+	writeFile(t, root, "/etc/cron.d/.placeholder", "# DO NOT EDIT OR REMOVE\n", 0o644)
+	writeFile(t, root, "/etc/cron.d/old.dpkg-old", "* * * * * root /bin/old\n", 0o644)
+	writeFile(t, root, "/etc/cron.daily/logrotate", "#!/bin/sh\n", 0o755)
+	writeFile(t, root, "/etc/cron.weekly/disabled", "#!/bin/sh\n", 0o644)
+	writeFile(t, root, "/var/spool/cron/crontabs/alice", "MAILTO=alice@example.com\n@reboot /home/alice/bin/tunnel.sh\n*/5 * * * * curl -s http://example.com/ping\n", 0o600)
+	writeFile(t, root, "/run/systemd/system/.keep", "", 0o644)
+
+	require.True(t, integ.IsAvailable())
+	result, err := integ.Collect(context.Background())
+	require.NoError(t, err)
+	data := result.Data.(*models.ScheduledTasksData)
+	assert.Empty(t, data.Warnings)
+
+	bySource := make(map[string][]models.ScheduledTask)
+	for _, task := range data.Tasks {
+		bySource[task.Source] = append(bySource[task.Source], task)
+	}
+
+	require.Len(t, bySource[models.TaskSourceCrontab], 1)
+	crontab := bySource[models.TaskSourceCrontab][0]
+	assert.Equal(t, "17 * * * *", crontab.Schedule)
+	assert.Equal(t, "root", crontab.User)
+	assert.Equal(t, "cd / && run-parts --report /etc/cron.hourly", crontab.Command)
+	assert.Equal(t, "/etc/crontab", crontab.File)
+	assert.NotNil(t, crontab.ModifiedAt)
+
+	require.Len(t, bySource[models.TaskSourceCronD], 1)
+	assert.Equal(t, "/etc/cron.d/certbot", bySource[models.TaskSourceCronD][0].File)
+
+	periodic := bySource[models.TaskSourceCronPeriodic]
+	require.Len(t, periodic, 2)
+	assert.Equal(t, "daily", periodic[0].Schedule)
+	assert.True(t, periodic[0].Enabled)
+	assert.Equal(t, "weekly", periodic[1].Schedule)
+	assert.False(t, periodic[1].Enabled, "run-parts skips non-executable scripts")
+
+	user := bySource[models.TaskSourceUserCrontab]
+	require.Len(t, user, 2)
+	assert.Equal(t, models.ScheduledTask{Source: models.TaskSourceUserCrontab, File: "/var/spool/cron/crontabs/alice", User: "alice", Schedule: "@reboot", Command: "/home/alice/bin/tunnel.sh", Enabled: true, ModifiedAt: user[0].ModifiedAt}, user[0])
+	assert.Equal(t, "*/5 * * * *", user[1].Schedule)
+
+	timers := bySource[models.TaskSourceSystemdTimer]
+	require.Len(t, timers, 2)
+	apt := timers[0]
+	assert.Equal(t, "apt-daily.timer", apt.Unit)
+	assert.Equal(t, "OnCalendar=*-*-* 06,18:00:00", apt.Schedule)
+	assert.Equal(t, "/usr/lib/apt/apt.systemd.daily update", apt.Command)
+	assert.Equal(t, "root", apt.User)
+	assert.True(t, apt.Enabled)
+	require.NotNil(t, apt.LastRun)
+	assert.Equal(t, time.Date(2026, 10, 17, 6, 0, 4, 0, time.UTC), *apt.LastRun)
+
+	backup := timers[1]
+	assert.Equal(t, "OnBootSec=15min; OnUnitActiveSec=1d", backup.Schedule)
+	assert.Equal(t, "backup", backup.User)
+	assert.Equal(t, "/usr/local/bin/backup --all", backup.Command)
+	assert.False(t, backup.Enabled)
+	assert.Nil(t, backup.LastRun)
+	assert.Nil(t, backup.NextRun)
+}
+
+func TestCollectWithoutSystemd(t *testing.T) {
+	integ, root := testIntegration(t)
+	integ.systemctl = func(context.Context, ...string) ([]byte, error) {
+		t.Fatal("systemctl must not run without systemd")
+		return nil, nil
+	}
+	writeFile(t, root, "/etc/crontab", "0 3 * * * root /usr/sbin/periodic daily\n", 0o644)
+
+	result, err := integ.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, result.Data.(*models.ScheduledTasksData).Tasks, 1)
+}
+
+func TestSystemctlFailureIsAWarning(t *testing.T) {
+	integ, root := testIntegration(t)
+	integ.systemctl = func(context.Context, ...string) ([]byte, error) { return nil, errors.New("bus unavailable") }
+	writeFile(t, root, "/run/systemd/system/.keep", "", 0o644)
+
+	result, err := integ.Collect(context.Background())
+	require.NoError(t, err)
+	data := result.Data.(*models.ScheduledTasksData)
+	assert.Empty(t, data.Tasks)
+	assert.Equal(t, []string{"systemctl list-units failed: bus unavailable"}, data.Warnings)
+}
+
+func TestParseCrontab(t *testing.T) {
+	jobs := parseCrontab([]byte("FOO = bar\n  # comment\n\n*/10 * * * * /bin/true x=1\n@daily\n1 2 3\n"), false)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "*/10 * * * *", jobs[0].Schedule)
+	assert.Equal(t, "/bin/true x=1", jobs[0].Command)
+}
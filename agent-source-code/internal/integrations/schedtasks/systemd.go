@@ -0,0 +1,179 @@
+package schedtasks
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// systemctlTimeout bounds each systemctl call
+const systemctlTimeout = 30 * time.Second
+
+// systemdTimeLayout is how systemctl show prints timestamps
+const systemdTimeLayout = "Mon 2006-01-02 15:04:05 MST"
+
+func (s *Integration) collectTimers(ctx context.Context, data *models.ScheduledTasksData) {
+	ctx, cancel := context.WithTimeout(ctx, systemctlTimeout)
+	defer cancel()
+
+	out, err := s.systemctl(ctx, "list-units", "--type=timer", "--all", "--no-legend", "--plain", "--no-pager")
+	if err != nil {
+		data.Warnings = append(data.Warnings, "systemctl list-units failed: "+err.Error())
+		return
+	}
+	var timers []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && strings.HasSuffix(fields[0], ".timer") {
+			timers = append(timers, fields[0])
+		}
+	}
+	if len(timers) == 0 {
+		return
+	}
+
+	args := append([]string{"show", "-p", "Id,TimersCalendar,TimersMonotonic,NextElapseUSecRealtime,LastTriggerUSec,Triggers,ActiveState,FragmentPath"}, timers...)
+	out, err = s.systemctl(ctx, args...)
+	if err != nil {
+		data.Warnings = append(data.Warnings, "systemctl show failed: "+err.Error())
+		return
+	}
+	units := parseShow(out)
+
+	var services []string
+	for _, u := range units {
+		if svc := first(u["Triggers"]); svc != "" {
+			services = append(services, svc)
+		}
+	}
+	commands := make(map[string]map[string][]string)
+	if len(services) > 0 {
+		args := append([]string{"show", "-p", "Id,ExecStart,User"}, services...)
+		if out, err := s.systemctl(ctx, args...); err == nil {
+			for _, u := range parseShow(out) {
+				commands[property(u, "Id")] = u
+			}
+		} else {
+			data.Warnings = append(data.Warnings, "systemctl show failed: "+err.Error())
+		}
+	}
+
+	for _, u := range units {
+		task := models.ScheduledTask{
+			Source:   models.TaskSourceSystemdTimer,
+			Unit:     property(u, "Id"),
+			File:     property(u, "FragmentPath"),
+			Schedule: timerSchedule(u),
+			Enabled:  property(u, "ActiveState") == "active",
+			LastRun:  parseSystemdTime(property(u, "LastTriggerUSec")),
+			NextRun:  parseSystemdTime(property(u, "NextElapseUSecRealtime")),
+		}
+		if svc, ok := commands[first(u["Triggers"])]; ok {
+			var argv []string
+			for _, start := range svc["ExecStart"] {
+				if a := showField(start, "argv[]"); a != "" {
+					argv = append(argv, a)
+				}
+			}
+			task.Command = strings.Join(argv, "; ")
+			task.User = property(svc, "User")
+		}
+		if task.Command == "" {
+			task.Command = first(u["Triggers"])
+		}
+		if task.User == "" {
+			task.User = "root"
+		}
+		data.Tasks = append(data.Tasks, task)
+	}
+}
+
+// parseShow splits `systemctl show` output into one property map per unit.
+// Properties such as TimersCalendar can repeat, so values are lists.
+func parseShow(out []byte) []map[string][]string {
+	var units []map[string][]string
+	current := make(map[string][]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(current) > 0 {
+				units = append(units, current)
+				current = make(map[string][]string)
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || value == "" {
+			continue
+		}
+		current[key] = append(current[key], value)
+	}
+	if len(current) > 0 {
+		units = append(units, current)
+	}
+	return units
+}
+
+// property returns the first value of a property
+func property(u map[string][]string, key string) string {
+	if values := u[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// first returns the first word of a property, for unit lists such as Triggers
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	if fields := strings.Fields(values[0]); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// timerSchedule joins a timer's OnCalendar and monotonic (OnBootSec,
+// OnUnitActiveSec, ...) settings
+func timerSchedule(u map[string][]string) string {
+	var parts []string
+	for _, v := range append(append([]string{}, u["TimersCalendar"]...), u["TimersMonotonic"]...) {
+		// "{ OnCalendar=*-*-* 06:00:00 ; next_elapse=... }"
+		v = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(v, "{"), "}"))
+		setting, _, _ := strings.Cut(v, " ; ")
+		if setting = strings.TrimSpace(setting); setting != "" {
+			parts = append(parts, setting)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// showField extracts name=value from a structured property such as
+// "{ path=/bin/true ; argv[]=/bin/true -x ; ignore_errors=no }"
+func showField(value, name string) string {
+	value = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(value, "{"), "}"))
+	for _, part := range strings.Split(value, " ; ") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(part), "="); ok && k == name {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// parseSystemdTime reads a timestamp printed in the local zone; "n/a" and
+// empty values mean never
+func parseSystemdTime(value string) *time.Time {
+	if value == "" || value == "n/a" {
+		return nil
+	}
+	t, err := time.ParseInLocation(systemdTimeLayout, value, time.Local)
+	if err != nil {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
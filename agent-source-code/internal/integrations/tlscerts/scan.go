@@ -0,0 +1,210 @@
+package tlscerts
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+const (
+	// maxFileSize skips files too big to be a certificate or chain
+	maxFileSize = 1 << 20
+	// maxFiles bounds a scan of a misconfigured path such as /
+	maxFiles = 5000
+	// maxDepth is how far below a configured directory the scan goes
+	maxDepth = 6
+	// maxCertsPerFile is the most certificates a file holds before it's taken
+	// for a CA trust bundle and skipped
+	maxCertsPerFile = 16
+)
+
+// certExtensions are the file extensions read as certificates
+var certExtensions = []string{".pem", ".crt", ".cer", ".cert", ".der"}
+
+type scanner struct {
+	now      time.Time
+	files    int
+	scanned  []string
+	warnings []string
+	byPrint  map[string]*models.TLSCertificate
+	order    []string
+}
+
+func newScanner(now time.Time) *scanner {
+	return &scanner{now: now, byPrint: make(map[string]*models.TLSCertificate)}
+}
+
+func (s *scanner) warn(format string, args ...interface{}) {
+	s.warnings = append(s.warnings, fmt.Sprintf(format, args...))
+}
+
+// certificates returns the certificates found, each once
+func (s *scanner) certificates() []models.TLSCertificate {
+	certs := make([]models.TLSCertificate, 0, len(s.order))
+	for _, fp := range s.order {
+		certs = append(certs, *s.byPrint[fp])
+	}
+	return certs
+}
+
+// scanPath reads a certificate file, or the certificate files below a directory
+func (s *scanner) scanPath(root string) {
+	info, err := os.Stat(root)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			s.warn("%s: %v", root, err)
+		}
+		return
+	}
+	s.scanned = append(s.scanned, root)
+	if !info.IsDir() {
+		s.scanFile(root)
+		return
+	}
+
+	rootDepth := strings.Count(filepath.Clean(root), string(filepath.Separator))
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			s.warn("%s: %v", path, err)
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if strings.Count(path, string(filepath.Separator))-rootDepth >= maxDepth {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !isCertFile(d.Name()) {
+			return nil
+		}
+		if s.files >= maxFiles {
+			s.warn("stopped after %d files under %s", maxFiles, root)
+			return fs.SkipAll
+		}
+		s.scanFile(path)
+		return nil
+	})
+	if err != nil {
+		s.warn("%s: %v", root, err)
+	}
+}
+
+// isCertFile picks files by extension. Private keys are never opened.
+func isCertFile(name string) bool {
+	lower := strings.ToLower(name)
+	if strings.Contains(lower, "privkey") || strings.HasSuffix(lower, ".key") {
+		return false
+	}
+	return slices.Contains(certExtensions, filepath.Ext(lower))
+}
+
+func (s *scanner) scanFile(path string) {
+	// Stat follows symlinks, as Let's Encrypt live/ directories are made of them
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > maxFileSize {
+		return
+	}
+	s.files++
+	data, err := os.ReadFile(path)
+	if err != nil {
+		s.warn("%s: %v", path, err)
+		return
+	}
+
+	certs := parseCertificates(data)
+	if len(certs) > maxCertsPerFile {
+		return
+	}
+	for _, cert := range certs {
+		s.add(path, cert)
+	}
+}
+
+// parseCertificates decodes every CERTIFICATE block of a PEM file, or a
+// single DER certificate
+func parseCertificates(data []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+	if !bytes.Contains(data, []byte("-----BEGIN")) {
+		if cert, err := x509.ParseCertificate(data); err == nil {
+			certs = append(certs, cert)
+		}
+		return certs
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs
+		}
+		if block.Type != "CERTIFICATE" && block.Type != "TRUSTED CERTIFICATE" {
+			continue
+		}
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			certs = append(certs, cert)
+		}
+	}
+}
+
+func (s *scanner) add(path string, cert *x509.Certificate) {
+	sum := sha256.Sum256(cert.Raw)
+	fp := hex.EncodeToString(sum[:])
+	if existing, ok := s.byPrint[fp]; ok {
+		if !slices.Contains(existing.Paths, path) {
+			existing.Paths = append(existing.Paths, path)
+		}
+		return
+	}
+	s.byPrint[fp] = describe(cert, fp, path, s.now)
+	s.order = append(s.order, fp)
+}
+
+func describe(cert *x509.Certificate, fingerprint, path string, now time.Time) *models.TLSCertificate {
+	c := &models.TLSCertificate{
+		Paths:              []string{path},
+		Subject:            cert.Subject.String(),
+		CommonName:         cert.Subject.CommonName,
+		Issuer:             cert.Issuer.String(),
+		SerialNumber:       cert.SerialNumber.Text(16),
+		NotBefore:          cert.NotBefore.UTC(),
+		NotAfter:           cert.NotAfter.UTC(),
+		DaysUntilExpiry:    int(math.Floor(cert.NotAfter.Sub(now).Hours() / 24)),
+		Expired:            now.After(cert.NotAfter),
+		IsCA:               cert.IsCA,
+		KeyAlgorithm:       cert.PublicKeyAlgorithm.String(),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		FingerprintSHA256:  fingerprint,
+	}
+	c.SANs = append(c.SANs, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		c.SANs = append(c.SANs, ip.String())
+	}
+	c.SANs = append(c.SANs, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		c.SANs = append(c.SANs, uri.String())
+	}
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		c.KeyBits = key.N.BitLen()
+	case *ecdsa.PublicKey:
+		c.KeyBits = key.Curve.Params().BitSize
+	}
+	c.SelfSigned = bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+	return c
+}
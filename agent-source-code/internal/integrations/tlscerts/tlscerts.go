@@ -0,0 +1,133 @@
+// Package tlscerts inventories X.509 certificates on disk so expiring
+// certificates show up next to pending patches
+package tlscerts
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IntegrationName is the config/integration key for the certificate inventory
+const IntegrationName = "tls-certificates"
+
+// expiringSoon is how far ahead a certificate expiry is worth a log line
+const expiringSoon = 30 * 24 * time.Hour
+
+// DefaultPaths are the certificate directories of common web servers and ACME
+// clients, used when tls_cert_paths is not set
+func DefaultPaths() []string {
+	if runtime.GOOS == "freebsd" {
+		return []string{
+			"/usr/local/etc/letsencrypt/live",
+			"/usr/local/etc/nginx",
+			"/usr/local/etc/apache24",
+			"/usr/local/etc/haproxy",
+		}
+	}
+	return []string{
+		"/etc/letsencrypt/live",
+		"/etc/nginx",
+		"/etc/apache2",
+		"/etc/httpd",
+		"/etc/haproxy",
+		"/etc/pki/tls/certs",
+	}
+}
+
+// Integration implements the Integration interface for TLS certificates
+type Integration struct {
+	logger *logrus.Logger
+	paths  []string
+	now    func() time.Time
+}
+
+// New creates a new certificate integration scanning paths, or DefaultPaths
+// when paths is empty
+func New(logger *logrus.Logger, paths []string) *Integration {
+	if len(paths) == 0 {
+		paths = DefaultPaths()
+	}
+	return &Integration{logger: logger, paths: paths, now: time.Now}
+}
+
+// Name returns the integration name
+func (t *Integration) Name() string {
+	return IntegrationName
+}
+
+// Priority returns the collection priority
+func (t *Integration) Priority() int {
+	return 45
+}
+
+// SupportsRealtime indicates the certificate inventory is batch-only
+func (t *Integration) SupportsRealtime() bool {
+	return false
+}
+
+// IsAvailable reports whether any of the configured paths exists
+func (t *Integration) IsAvailable() bool {
+	for _, p := range t.paths {
+		if _, err := os.Stat(p); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Collect scans the configured paths for certificates
+func (t *Integration) Collect(ctx context.Context) (*models.IntegrationData, error) {
+	startTime := time.Now()
+
+	s := newScanner(t.now())
+	for _, p := range t.paths {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		s.scanPath(p)
+	}
+
+	data := &models.TLSCertificatesData{
+		Certificates: s.certificates(),
+		ScannedPaths: s.scanned,
+		Warnings:     s.warnings,
+	}
+	if data.ScannedPaths == nil {
+		data.ScannedPaths = []string{}
+	}
+	sort.SliceStable(data.Certificates, func(i, j int) bool {
+		return data.Certificates[i].NotAfter.Before(data.Certificates[j].NotAfter)
+	})
+
+	expiring := 0
+	for _, cert := range data.Certificates {
+		if cert.NotAfter.Sub(t.now()) < expiringSoon {
+			expiring++
+			t.logger.WithFields(logrus.Fields{
+				"subject":   cert.Subject,
+				"not_after": cert.NotAfter.Format(time.RFC3339),
+				"path":      cert.Paths[0],
+			}).Warn("TLS certificate expired or expiring within 30 days")
+		}
+	}
+	t.logger.WithFields(logrus.Fields{
+		"certificates": len(data.Certificates),
+		"expiring":     expiring,
+	}).Info("Collected TLS certificates")
+
+	return &models.IntegrationData{
+		Name:          t.Name(),
+		Enabled:       true,
+		Data:          data,
+		CollectedAt:   utils.GetCurrentTimeUTC(),
+		ExecutionTime: time.Since(startTime).Seconds(),
+	}, nil
+}
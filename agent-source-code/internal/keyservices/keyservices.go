@@ -0,0 +1,247 @@
+// Package keyservices finds the web servers, databases and caches on a host
+// and reports their versions and pending updates
+package keyservices
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// versionTimeout bounds each `<binary> --version` call
+const versionTimeout = 5 * time.Second
+
+// sbinDirs are searched for service binaries PATH doesn't cover
+var sbinDirs = []string{"/usr/sbin", "/usr/local/sbin", "/usr/local/bin", "/sbin"}
+
+// definition describes how to recognise one service
+type definition struct {
+	name string
+	// packages matches the server package names across distributions
+	packages *regexp.Regexp
+	// processes are the process names of a running server
+	processes []string
+	// binary and versionArgs print the version for installs outside the
+	// package manager; versionRe extracts it
+	binary      string
+	versionArgs []string
+	versionRe   *regexp.Regexp
+}
+
+var definitions = []definition{
+	{
+		name:        "nginx",
+		packages:    regexp.MustCompile(`^nginx(-core|-full|-light|-extras|-mainline)?$`),
+		processes:   []string{"nginx"},
+		binary:      "nginx",
+		versionArgs: []string{"-v"},
+		versionRe:   regexp.MustCompile(`nginx/(\S+)`),
+	},
+	{
+		name:        "apache",
+		packages:    regexp.MustCompile(`^(apache2|httpd|apache24)$`),
+		processes:   []string{"apache2", "httpd"},
+		binary:      "httpd",
+		versionArgs: []string{"-v"},
+		versionRe:   regexp.MustCompile(`Apache/(\S+)`),
+	},
+	{
+		// Debian's bare "postgresql" is a metapackage and RHEL's is the client
+		name:        "postgresql",
+		packages:    regexp.MustCompile(`^postgresql(-?\d+(\.\d+)?(-server)?|-server)$`),
+		processes:   []string{"postgres", "postmaster"},
+		binary:      "postgres",
+		versionArgs: []string{"--version"},
+		versionRe:   regexp.MustCompile(`\(PostgreSQL\) (\S+)`),
+	},
+	{
+		name:        "mysql",
+		packages:    regexp.MustCompile(`^(mysql-server(-\d+\.\d+)?|mysql-community-server|mysql\d+-server|percona-server-server)$`),
+		processes:   []string{"mysqld"},
+		binary:      "mysqld",
+		versionArgs: []string{"--version"},
+		versionRe:   regexp.MustCompile(`Ver (\S+)`),
+	},
+	{
+		// MariaDB before 10.5 runs as mysqld
+		name:        "mariadb",
+		packages:    regexp.MustCompile(`^(mariadb|mariadb-server(-\d+\.\d+)?|mariadb\d+-server)$`),
+		processes:   []string{"mariadbd", "mysqld"},
+		binary:      "mariadbd",
+		versionArgs: []string{"--version"},
+		versionRe:   regexp.MustCompile(`Ver (\S+)`),
+	},
+	{
+		name:        "redis",
+		packages:    regexp.MustCompile(`^(redis|redis-server|redis\d+)$`),
+		processes:   []string{"redis-server"},
+		binary:      "redis-server",
+		versionArgs: []string{"--version"},
+		versionRe:   regexp.MustCompile(`v=(\S+)`),
+	},
+}
+
+// Manager detects key services
+type Manager struct {
+	logger *logrus.Logger
+}
+
+// New creates a new key service manager
+func New(logger *logrus.Logger) *Manager {
+	return &Manager{
+		logger: logger,
+	}
+}
+
+// Detect returns the key services found among packages and running processes
+func (m *Manager) Detect(packages []models.Package) []models.KeyService {
+	running, err := runningProcesses()
+	if err != nil {
+		m.logger.WithError(err).Debug("Failed to list processes, reporting key services from packages only")
+	}
+	services := detect(packages, running, binaryVersion)
+	for _, svc := range services {
+		m.logger.WithFields(logrus.Fields{
+			"service":         svc.Name,
+			"version":         svc.Version,
+			"running":         svc.Running,
+			"security_update": svc.SecurityUpdate,
+		}).Debug("Key service")
+	}
+	return services
+}
+
+// detect matches packages against the definitions, then falls back to the
+// binary's own version for running servers no package accounts for
+func detect(packages []models.Package, running map[string]bool, version func(definition) string) []models.KeyService {
+	var services []models.KeyService
+	claimed := make(map[string]bool)
+	for _, def := range definitions {
+		isRunning := slices.ContainsFunc(def.processes, func(p string) bool { return running[p] })
+		found := false
+		for _, pkg := range packages {
+			if !def.packages.MatchString(pkg.Name) {
+				continue
+			}
+			found = true
+			services = append(services, models.KeyService{
+				Name:             def.name,
+				Version:          pkg.CurrentVersion,
+				Running:          isRunning,
+				Package:          pkg.Name,
+				AvailableVersion: pkg.AvailableVersion,
+				NeedsUpdate:      pkg.NeedsUpdate,
+				SecurityUpdate:   pkg.IsSecurityUpdate,
+				Source:           "package",
+			})
+		}
+		if found {
+			for _, p := range def.processes {
+				claimed[p] = true
+			}
+		}
+	}
+
+	for _, def := range definitions {
+		if slices.ContainsFunc(services, func(s models.KeyService) bool { return s.Name == def.name }) {
+			continue
+		}
+		// mysqld may be MariaDB's, already reported from its package
+		if !slices.ContainsFunc(def.processes, func(p string) bool { return running[p] && !claimed[p] }) {
+			continue
+		}
+		for _, p := range def.processes {
+			claimed[p] = true
+		}
+		services = append(services, models.KeyService{
+			Name:    def.name,
+			Version: version(def),
+			Running: true,
+			Source:  "binary",
+		})
+	}
+	return services
+}
+
+// runningProcesses returns the names of running processes
+func runningProcesses() (map[string]bool, error) {
+	if runtime.GOOS == "windows" {
+		return nil, nil
+	}
+	if runtime.GOOS == "linux" {
+		return procComms("/proc")
+	}
+	out, err := utils.Command("ps", "-axo", "comm=").Output()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if name := strings.TrimSpace(scanner.Text()); name != "" {
+			names[filepath.Base(name)] = true
+		}
+	}
+	return names, nil
+}
+
+// procComms reads the command name of every process under a procfs root
+func procComms(root string) (map[string]bool, error) {
+	comms, err := filepath.Glob(filepath.Join(root, "[0-9]*", "comm"))
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for _, path := range comms {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			// The process exited between the glob and the read
+			continue
+		}
+		names[strings.TrimSpace(string(data))] = true
+	}
+	return names, nil
+}
+
+// binaryVersion runs the service binary to ask for its version
+func binaryVersion(def definition) string {
+	path, err := exec.LookPath(def.binary)
+	if err != nil {
+		for _, dir := range sbinDirs {
+			candidate := filepath.Join(dir, def.binary)
+			if _, statErr := os.Stat(candidate); statErr == nil {
+				path, err = candidate, nil
+				break
+			}
+		}
+	}
+	if err != nil {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), versionTimeout)
+	defer cancel()
+	// nginx -v writes to stderr
+	out, _ := utils.CommandContext(ctx, path, def.versionArgs...).CombinedOutput()
+	return parseVersion(def, out)
+}
+
+func parseVersion(def definition, out []byte) string {
+	if match := def.versionRe.FindSubmatch(out); match != nil {
+		return string(match[1])
+	}
+	return ""
+}
@@ -0,0 +1,103 @@
+package keyservices
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func noBinary(definition) string { return "" }
+
+func TestDetectFromPackages(t *testing.T) {
+	packages := []models.Package{
+		{Name: "nginx", CurrentVersion: "1.24.0-2ubuntu7", AvailableVersion: "1.24.0-2ubuntu7.1", NeedsUpdate: true, IsSecurityUpdate: true},
+		{Name: "nginx-common", CurrentVersion: "1.24.0-2ubuntu7"},
+		{Name: "postgresql", CurrentVersion: "16+257"},
+		{Name: "postgresql-16", CurrentVersion: "16.2-1"},
+		{Name: "postgresql-client-16", CurrentVersion: "16.2-1"},
+		{Name: "redis-tools", CurrentVersion: "5:7.0.15-1"},
+		{Name: "curl", CurrentVersion: "8.5.0"},
+	}
+	services := detect(packages, map[string]bool{"nginx": true}, noBinary)
+	require.Len(t, services, 2)
+
+	assert.Equal(t, models.KeyService{
+		Name:             "nginx",
+		Version:          "1.24.0-2ubuntu7",
+		Running:          true,
+		Package:          "nginx",
+		AvailableVersion: "1.24.0-2ubuntu7.1",
+		NeedsUpdate:      true,
+		SecurityUpdate:   true,
+		Source:           "package",
+	}, services[0])
+	assert.Equal(t, "postgresql", services[1].Name)
+	assert.Equal(t, "postgresql-16", services[1].Package)
+	assert.False(t, services[1].Running)
+}
+
+func TestDetectPackageNamesAcrossDistributions(t *testing.T) {
+	for pkg, want := range map[string]string{
+		"httpd":                  "apache",
+		"apache24":               "apache",
+		"postgresql-server":      "postgresql",
+		"postgresql16-server":    "postgresql",
+		"mysql-community-server": "mysql",
+		"mysql-server-8.0":       "mysql",
+		"mariadb-server":         "mariadb",
+		"mariadb106-server":      "mariadb",
+		"redis":                  "redis",
+		"redis-server":           "redis",
+	} {
+		services := detect([]models.Package{{Name: pkg}}, nil, noBinary)
+		require.Len(t, services, 1, pkg)
+		assert.Equal(t, want, services[0].Name, pkg)
+	}
+	assert.Empty(t, detect([]models.Package{{Name: "mysql-client"}, {Name: "postgresql"}}, nil, noBinary))
+}
+
+func TestDetectRunningBinary(t *testing.T) {
+	version := func(def definition) string {
+		return parseVersion(def, []byte("Redis server v=7.2.4 sha=00000000:0 malloc=jemalloc-5.3.0 bits=64 build=abc"))
+	}
+	services := detect(nil, map[string]bool{"redis-server": true}, version)
+	require.Len(t, services, 1)
+	assert.Equal(t, models.KeyService{Name: "redis", Version: "7.2.4", Running: true, Source: "binary"}, services[0])
+}
+
+func TestMySQLProcessOfMariaDBPackage(t *testing.T) {
+	services := detect([]models.Package{{Name: "mariadb-server-10.3", CurrentVersion: "1:10.3.39"}}, map[string]bool{"mysqld": true}, noBinary)
+	require.Len(t, services, 1, "mysqld belongs to the MariaDB package, not a separate MySQL")
+	assert.Equal(t, "mariadb", services[0].Name)
+	assert.True(t, services[0].Running)
+}
+
+func TestParseVersion(t *testing.T) {
+	byName := make(map[string]definition)
+	for _, def := range definitions {
+		byName[def.name] = def
+	}
+	assert.Equal(t, "1.24.0", parseVersion(byName["nginx"], []byte("nginx version: nginx/1.24.0 (Ubuntu)\n")))
+	assert.Equal(t, "2.4.58", parseVersion(byName["apache"], []byte("Server version: Apache/2.4.58 (Unix)\nServer built:   Feb  1 2024\n")))
+	assert.Equal(t, "16.2", parseVersion(byName["postgresql"], []byte("postgres (PostgreSQL) 16.2\n")))
+	assert.Equal(t, "8.0.36", parseVersion(byName["mysql"], []byte("/usr/sbin/mysqld  Ver 8.0.36 for Linux on x86_64 (MySQL Community Server - GPL)\n")))
+	assert.Empty(t, parseVersion(byName["mysql"], []byte("command not found")))
+}
+
+func TestProcComms(t *testing.T) {
+	root := t.TempDir()
+	for pid, comm := range map[string]string{"1": "systemd", "812": "nginx", "9001": "postgres"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, pid), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, pid, "comm"), []byte(comm+"\n"), 0o644))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "self"), 0o755))
+
+	names, err := procComms(root)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"systemd": true, "nginx": true, "postgres": true}, names)
+}
@@ -0,0 +1,52 @@
+package localapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// FetchSummary queries a running serve process's local API, as configured by
+// local_api_listen, for the current status summary
+func FetchSummary(ctx context.Context, listen, token string) (*Summary, error) {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	baseURL := "http://" + listen
+	if path, ok := socketPath(listen); ok {
+		httpClient.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}
+		baseURL = "http://localhost"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/v1/status", nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("local API request failed (is serve running?): %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return nil, fmt.Errorf("local API request failed with status %d: %s", resp.StatusCode, body)
+	}
+
+	var sum Summary
+	if err := json.NewDecoder(resp.Body).Decode(&sum); err != nil {
+		return nil, fmt.Errorf("invalid local API response: %w", err)
+	}
+	return &sum, nil
+}
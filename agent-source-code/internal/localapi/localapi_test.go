@@ -0,0 +1,129 @@
+package localapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testState() *State {
+	state := NewState()
+	state.SetReport(&models.ReportPayload{
+		Hostname:    "web-01",
+		NeedsReboot: true,
+		Packages: []models.Package{
+			{Name: "curl", NeedsUpdate: true, IsSecurityUpdate: true},
+			{Name: "htop", NeedsUpdate: true},
+			{Name: "bash"},
+		},
+	})
+	done := time.Now()
+	state.SetComplianceScans([]models.ComplianceScan{
+		{ProfileName: "cis-l1", ProfileType: "openscap", Status: "completed", Score: 82.5, CompletedAt: &done},
+		{ProfileName: "docker", ProfileType: "docker-bench", Status: "completed", Score: 64},
+		{ProfileName: "stig", ProfileType: "openscap", Status: "failed"},
+	})
+	return state
+}
+
+func TestSummary(t *testing.T) {
+	sum := testState().Summary()
+	assert.Equal(t, "web-01", sum.Hostname)
+	assert.Equal(t, 3, sum.TotalPackages)
+	assert.Equal(t, 2, sum.PendingUpdates)
+	assert.Equal(t, 1, sum.SecurityUpdates)
+	assert.True(t, sum.RebootRequired)
+	require.NotNil(t, sum.ComplianceScore)
+	assert.Equal(t, 64.0, *sum.ComplianceScore, "failed scans don't count, lowest completed score wins")
+
+	empty := NewState().Summary()
+	assert.Nil(t, empty.CollectedAt)
+	assert.Nil(t, empty.ComplianceScore)
+}
+
+func TestHandlerRequiresToken(t *testing.T) {
+	logger := logrus.New()
+	srv := NewServer(logger, testState(), func() models.AgentHealth { return models.AgentHealth{} }, "s3cret")
+	h := srv.Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/updates", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/updates", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var pkgs []models.Package
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &pkgs))
+	assert.Len(t, pkgs, 2)
+
+	// Read-only: other methods are rejected
+	req = httptest.NewRequest(http.MethodPost, "/v1/status", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestListenRejectsNonLoopback(t *testing.T) {
+	_, err := Listen("0.0.0.0:9641", "token", "")
+	assert.Error(t, err)
+
+	_, err = Listen("127.0.0.1:0", "", "")
+	assert.Error(t, err, "TCP requires a token")
+
+	ln, err := Listen("127.0.0.1:0", "token", "")
+	require.NoError(t, err)
+	_ = ln.Close()
+}
+
+func TestListenUnixGroup(t *testing.T) {
+	// Socket paths are limited to about 100 bytes, which t.TempDir can exceed
+	dir, err := os.MkdirTemp("", "localapi")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	socket := filepath.Join(dir, "run", "api.sock")
+
+	ln, err := Listen("unix:"+socket, "", strconv.Itoa(os.Getgid()))
+	require.NoError(t, err)
+	_ = ln.Close()
+	info, err := os.Stat(filepath.Join(dir, "run"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o750), info.Mode().Perm())
+
+	_, err = Listen("unix:"+socket, "", "no-such-group-patchmon")
+	assert.ErrorContains(t, err, "no-such-group-patchmon")
+}
+
+func TestFormatInflux(t *testing.T) {
+	score := 82.5
+	sum := Summary{Hostname: "web 01,eu", PendingUpdates: 3, SecurityUpdates: 1, TotalPackages: 420, RebootRequired: true, ComplianceScore: &score}
+	got := FormatInflux(sum, time.Unix(1700000000, 0))
+	assert.Equal(t, `patchmon,host=web\ 01\,eu pending_updates=3i,security_updates=1i,total_packages=420i,reboot_required=true,compliance_score=82.5 1700000000000000000`+"\n", got)
+
+	sum.ComplianceScore = nil
+	assert.NotContains(t, FormatInflux(sum, time.Now()), "compliance_score")
+}
+
+func TestNetdataValues(t *testing.T) {
+	score := 82.5
+	out := NetdataValues(Summary{PendingUpdates: 3, SecurityUpdates: 1, ComplianceScore: &score})
+	assert.Contains(t, out, "SET pending = 3\n")
+	assert.Contains(t, out, "SET required = 0\n")
+	assert.Contains(t, out, "SET score = 8250\n")
+	assert.Contains(t, NetdataCharts(60), "CHART patchmon.updates")
+}
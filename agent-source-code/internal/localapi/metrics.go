@@ -0,0 +1,67 @@
+package localapi
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// influxTagEscaper escapes tag values per the InfluxDB line protocol
+var influxTagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// FormatInflux renders the summary as one InfluxDB line protocol point, as
+// consumed by Telegraf's exec and http inputs (data_format = "influx")
+func FormatInflux(sum Summary, ts time.Time) string {
+	fields := []string{
+		fmt.Sprintf("pending_updates=%di", sum.PendingUpdates),
+		fmt.Sprintf("security_updates=%di", sum.SecurityUpdates),
+		fmt.Sprintf("total_packages=%di", sum.TotalPackages),
+		fmt.Sprintf("reboot_required=%t", sum.RebootRequired),
+	}
+	if sum.ComplianceScore != nil {
+		fields = append(fields, fmt.Sprintf("compliance_score=%g", *sum.ComplianceScore))
+	}
+	measurement := "patchmon"
+	// Empty tag values are invalid line protocol
+	if sum.Hostname != "" {
+		measurement += ",host=" + influxTagEscaper.Replace(sum.Hostname)
+	}
+	return fmt.Sprintf("%s %s %d\n", measurement, strings.Join(fields, ","), ts.UnixNano())
+}
+
+// NetdataCharts defines the charts for the Netdata external plugin protocol.
+// It is written once at plugin start.
+func NetdataCharts(updateEvery int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CHART patchmon.updates '' 'Pending package updates' 'packages' updates patchmon.updates line 100000 %d\n", updateEvery)
+	b.WriteString("DIMENSION pending '' absolute 1 1\n")
+	b.WriteString("DIMENSION security '' absolute 1 1\n")
+	fmt.Fprintf(&b, "CHART patchmon.reboot '' 'Reboot required' 'boolean' reboot patchmon.reboot line 100001 %d\n", updateEvery)
+	b.WriteString("DIMENSION required '' absolute 1 1\n")
+	fmt.Fprintf(&b, "CHART patchmon.compliance '' 'Lowest compliance score' 'percentage' compliance patchmon.compliance line 100002 %d\n", updateEvery)
+	// Scores carry two decimals; Netdata stores integers, so scale by 100
+	b.WriteString("DIMENSION score '' absolute 1 100\n")
+	return b.String()
+}
+
+// NetdataValues renders one update for the charts from NetdataCharts
+func NetdataValues(sum Summary) string {
+	var b strings.Builder
+	b.WriteString("BEGIN patchmon.updates\n")
+	fmt.Fprintf(&b, "SET pending = %d\n", sum.PendingUpdates)
+	fmt.Fprintf(&b, "SET security = %d\n", sum.SecurityUpdates)
+	b.WriteString("END\n")
+	reboot := 0
+	if sum.RebootRequired {
+		reboot = 1
+	}
+	b.WriteString("BEGIN patchmon.reboot\n")
+	fmt.Fprintf(&b, "SET required = %d\n", reboot)
+	b.WriteString("END\n")
+	if sum.ComplianceScore != nil {
+		b.WriteString("BEGIN patchmon.compliance\n")
+		fmt.Fprintf(&b, "SET score = %d\n", int64(*sum.ComplianceScore*100))
+		b.WriteString("END\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,133 @@
+package localapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultSocketPath is used when local_api_listen is "unix" with no path
+const DefaultSocketPath = "/run/patchmon/api.sock"
+
+// Server is the read-only local HTTP API
+type Server struct {
+	logger *logrus.Logger
+	state  *State
+	health func() models.AgentHealth
+	token  string
+}
+
+// NewServer creates a server. token is required for TCP listeners and optional
+// for unix sockets, where file permissions already restrict access.
+func NewServer(logger *logrus.Logger, state *State, health func() models.AgentHealth, token string) *Server {
+	return &Server{logger: logger, state: state, health: health, token: token}
+}
+
+// Handler returns the API routes
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/status", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, s.state.Summary())
+	})
+	mux.HandleFunc("GET /v1/packages", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, s.state.Packages(r.URL.Query().Get("updates") == "true"))
+	})
+	mux.HandleFunc("GET /v1/updates", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, s.state.Packages(true))
+	})
+	mux.HandleFunc("GET /v1/compliance", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, s.state.Compliance())
+	})
+	mux.HandleFunc("GET /v1/health", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, s.health())
+	})
+	// InfluxDB line protocol for Telegraf's http input
+	mux.HandleFunc("GET /v1/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = io.WriteString(w, FormatInflux(s.state.Summary(), time.Now()))
+	})
+	return s.authenticate(mux)
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Listen parses a listen spec: "unix", "unix:/path/to.sock" or a loopback
+// "127.0.0.1:port" / "[::1]:port". Non-loopback addresses are refused since the
+// API is meant for this host only. A socket is given to group when set.
+func Listen(spec, token, group string) (net.Listener, error) {
+	if path, ok := socketPath(spec); ok {
+		// A non-root collector reads it via local_api_group
+		return utils.ListenUnix(path, group)
+	}
+
+	host, _, err := net.SplitHostPort(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid local_api_listen %q: %w", spec, err)
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return nil, fmt.Errorf("local_api_listen %q must be a loopback address or unix socket", spec)
+	}
+	if token == "" {
+		return nil, errors.New("local_api_token is required when the local API listens on TCP")
+	}
+	return net.Listen("tcp", spec)
+}
+
+// socketPath returns the unix socket path for a "unix" or "unix:<path>" spec
+func socketPath(spec string) (string, bool) {
+	if spec != "unix" && !strings.HasPrefix(spec, "unix:") {
+		return "", false
+	}
+	if path := strings.TrimPrefix(spec, "unix:"); path != "" && path != "unix" {
+		return path, true
+	}
+	return DefaultSocketPath, true
+}
+
+// Serve runs the API on ln until ctx is cancelled
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	s.logger.WithField("address", ln.Addr().String()).Info("Local API listening")
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Package localapi serves a read-only view of the agent's latest data to other
+// tooling on the same host (MOTD generators, CMDB collectors) over a unix socket
+// or a token-protected loopback port
+package localapi
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// ComplianceSummary is one compliance scan without its per-rule results
+type ComplianceSummary struct {
+	ProfileName string     `json:"profileName"`
+	ProfileType string     `json:"profileType"`
+	Status      string     `json:"status"`
+	Score       float64    `json:"score"`
+	Passed      int        `json:"passed"`
+	Failed      int        `json:"failed"`
+	Warnings    int        `json:"warnings"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+// Summary is the headline status of the host
+type Summary struct {
+	Hostname        string     `json:"hostname"`
+	OSType          string     `json:"osType"`
+	OSVersion       string     `json:"osVersion"`
+	AgentVersion    string     `json:"agentVersion"`
+	CollectedAt     *time.Time `json:"collectedAt,omitempty"`
+	TotalPackages   int        `json:"totalPackages"`
+	PendingUpdates  int        `json:"pendingUpdates"`
+	SecurityUpdates int        `json:"securityUpdates"`
+	RebootRequired  bool       `json:"rebootRequired"`
+	RebootReason    string     `json:"rebootReason,omitempty"`
+	// ComplianceScore is the lowest score across the latest scans, nil if none ran
+	ComplianceScore *float64 `json:"complianceScore,omitempty"`
+}
+
+// State holds the latest collected data. Safe for concurrent use.
+type State struct {
+	mu          sync.RWMutex
+	report      *models.ReportPayload
+	collectedAt time.Time
+	compliance  map[string]ComplianceSummary // keyed by profile type + name
+}
+
+// NewState returns an empty state
+func NewState() *State {
+	return &State{compliance: make(map[string]ComplianceSummary)}
+}
+
+// SetReport records the latest report payload
+func (s *State) SetReport(report *models.ReportPayload) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report = report
+	s.collectedAt = time.Now().UTC()
+}
+
+// SetComplianceScans records the latest result per profile
+func (s *State) SetComplianceScans(scans []models.ComplianceScan) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, scan := range scans {
+		s.compliance[scan.ProfileType+"/"+scan.ProfileName] = ComplianceSummary{
+			ProfileName: scan.ProfileName,
+			ProfileType: scan.ProfileType,
+			Status:      scan.Status,
+			Score:       scan.Score,
+			Passed:      scan.Passed,
+			Failed:      scan.Failed,
+			Warnings:    scan.Warnings,
+			CompletedAt: scan.CompletedAt,
+		}
+	}
+}
+
+// Summary returns the headline status
+func (s *State) Summary() Summary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var sum Summary
+	if r := s.report; r != nil {
+		collectedAt := s.collectedAt
+		sum = Summary{
+			Hostname:       r.Hostname,
+			OSType:         r.OSType,
+			OSVersion:      r.OSVersion,
+			AgentVersion:   r.AgentVersion,
+			CollectedAt:    &collectedAt,
+			TotalPackages:  len(r.Packages),
+			RebootRequired: r.NeedsReboot,
+			RebootReason:   r.RebootReason,
+		}
+		for _, p := range r.Packages {
+			if p.NeedsUpdate {
+				sum.PendingUpdates++
+				if p.IsSecurityUpdate {
+					sum.SecurityUpdates++
+				}
+			}
+		}
+	}
+	for _, c := range s.compliance {
+		if c.Status != "completed" {
+			continue
+		}
+		if sum.ComplianceScore == nil || c.Score < *sum.ComplianceScore {
+			score := c.Score
+			sum.ComplianceScore = &score
+		}
+	}
+	return sum
+}
+
+// Packages returns the latest package list, optionally only those with updates
+func (s *State) Packages(updatesOnly bool) []models.Package {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := []models.Package{}
+	if s.report == nil {
+		return out
+	}
+	for _, p := range s.report.Packages {
+		if !updatesOnly || p.NeedsUpdate {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Compliance returns the latest result per compliance profile
+func (s *State) Compliance() []ComplianceSummary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]ComplianceSummary, 0, len(s.compliance))
+	for _, c := range s.compliance {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].ProfileType != out[j].ProfileType {
+			return out[i].ProfileType < out[j].ProfileType
+		}
+		return out[i].ProfileName < out[j].ProfileName
+	})
+	return out
+}
@@ -0,0 +1,147 @@
+package maintenance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSpec is a parsed five-field cron expression. Each field is a bit set of
+// the values it matches.
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	// Like cron, when both day fields are restricted a day matching either
+	// one matches
+	domStar, dowStar bool
+}
+
+var (
+	monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	dayNames   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// parseCron parses "minute hour day-of-month month day-of-week". Fields take
+// *, numbers, ranges (1-5), steps (*/15, 8-18/2), lists (1,15) and, for
+// months and weekdays, names (jan, mon-fri).
+func parseCron(expr string) (*cronSpec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q: want 5 fields (minute hour day-of-month month day-of-week), got %d", expr, len(fields))
+	}
+	spec := &cronSpec{domStar: fields[2] == "*", dowStar: fields[4] == "*"}
+	var err error
+	for _, f := range []struct {
+		dst      *uint64
+		field    string
+		min, max int
+		names    []string
+		nameBase int
+	}{
+		{&spec.minute, fields[0], 0, 59, nil, 0},
+		{&spec.hour, fields[1], 0, 23, nil, 0},
+		{&spec.dom, fields[2], 1, 31, nil, 0},
+		{&spec.month, fields[3], 1, 12, monthNames, 1},
+		{&spec.dow, fields[4], 0, 7, dayNames, 0},
+	} {
+		if *f.dst, err = parseCronField(f.field, f.min, f.max, f.names, f.nameBase); err != nil {
+			return nil, fmt.Errorf("cron %q: %w", expr, err)
+		}
+	}
+	// 7 is Sunday too
+	if spec.dow&(1<<7) != 0 {
+		spec.dow |= 1
+	}
+	return spec, nil
+}
+
+func parseCronField(field string, min, max int, names []string, nameBase int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = cronValue(from, min, max, names, nameBase); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = cronValue(to, min, max, names, nameBase); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// 5/15 means from 5 to the end in steps of 15
+				hi = max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("bad range %q", rangePart)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func cronValue(s string, min, max int, names []string, nameBase int) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(s, name) {
+			return i + nameBase, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("%q is not between %d and %d", s, min, max)
+	}
+	return v, nil
+}
+
+// matches reports whether the minute t starts matches the expression
+func (c *cronSpec) matches(t time.Time) bool {
+	return c.minute&(1<<t.Minute()) != 0 &&
+		c.hour&(1<<t.Hour()) != 0 &&
+		c.month&(1<<int(t.Month())) != 0 &&
+		c.dayMatches(t)
+}
+
+func (c *cronSpec) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first minute after t the expression matches, looking no
+// further than limit
+func (c *cronSpec) next(t, limit time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for !t.After(limit) {
+		y, mo, d := t.Date()
+		switch {
+		case c.month&(1<<int(mo)) == 0:
+			t = time.Date(y, mo+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(y, mo, d+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(y, mo, d, t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,211 @@
+// Package maintenance decides whether a maintenance window from config.yml
+// is open: a weekly time range or a cron schedule with a duration
+package maintenance
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+const (
+	// maxDuration bounds how long a cron window stays open
+	maxDuration = 7 * 24 * time.Hour
+	// horizon is how far ahead Next looks for a window
+	horizon = 366 * 24 * time.Hour
+)
+
+// Schedule is the set of configured windows. A nil Schedule is always open.
+type Schedule struct {
+	loc     *time.Location
+	windows []window
+}
+
+// window is one configured window: a weekly range when cron is nil
+type window struct {
+	name       string
+	days       [7]bool
+	start, end int // minutes after midnight
+	cron       *cronSpec
+	duration   time.Duration
+}
+
+// New compiles cfg. It returns nil when no windows are configured, and an
+// error for an invalid one so a typo can't let disruptive commands run at
+// any time.
+func New(cfg *models.MaintenanceConfig) (*Schedule, error) {
+	if cfg == nil || len(cfg.Windows) == 0 {
+		return nil, nil
+	}
+	s := &Schedule{loc: time.Local}
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance timezone %q: %w", cfg.Timezone, err)
+		}
+		s.loc = loc
+	}
+	for i, w := range cfg.Windows {
+		parsed, err := parseWindow(w)
+		if err != nil {
+			name := w.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			return nil, fmt.Errorf("maintenance window %s: %w", name, err)
+		}
+		s.windows = append(s.windows, parsed)
+	}
+	return s, nil
+}
+
+func parseWindow(w models.MaintenanceWindow) (window, error) {
+	out := window{name: w.Name}
+	if w.Cron != "" {
+		if w.Start != "" || w.End != "" || len(w.Days) > 0 {
+			return out, errors.New("cron can't be combined with days, start or end")
+		}
+		spec, err := parseCron(w.Cron)
+		if err != nil {
+			return out, err
+		}
+		d, err := time.ParseDuration(w.Duration)
+		if err != nil || d < time.Minute || d > maxDuration {
+			return out, fmt.Errorf("duration %q must be between 1m and %s", w.Duration, maxDuration)
+		}
+		out.cron, out.duration = spec, d
+		return out, nil
+	}
+
+	if w.Duration != "" {
+		return out, errors.New("duration only applies to cron windows, use start and end")
+	}
+	var err error
+	if out.start, err = parseClock(w.Start); err != nil {
+		return out, fmt.Errorf("start: %w", err)
+	}
+	if out.end, err = parseClock(w.End); err != nil {
+		return out, fmt.Errorf("end: %w", err)
+	}
+	if out.start == out.end {
+		return out, errors.New("start and end are the same")
+	}
+	if len(w.Days) == 0 {
+		out.days = [7]bool{true, true, true, true, true, true, true}
+	}
+	for _, d := range w.Days {
+		if err := addDays(&out.days, d); err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+// parseClock parses HH:MM into minutes after midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// addDays marks a day (mon) or a range of days (mon-fri, fri-mon)
+func addDays(days *[7]bool, spec string) error {
+	from, to, isRange := strings.Cut(spec, "-")
+	first, err := weekday(from)
+	if err != nil {
+		return err
+	}
+	last := first
+	if isRange {
+		if last, err = weekday(to); err != nil {
+			return err
+		}
+	}
+	for d := first; ; d = (d + 1) % 7 {
+		days[d] = true
+		if d == last {
+			return nil
+		}
+	}
+}
+
+// weekday accepts day names or their first three letters
+func weekday(s string) (time.Weekday, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if len(s) >= 3 && strings.HasPrefix(strings.ToLower(d.String()), s) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("%q is not a day of the week", s)
+}
+
+// Open reports whether a window is open at t, and which
+func (s *Schedule) Open(t time.Time) (bool, string) {
+	if s == nil {
+		return true, ""
+	}
+	t = t.In(s.loc)
+	for _, w := range s.windows {
+		if w.open(t) {
+			return true, w.name
+		}
+	}
+	return false, ""
+}
+
+// Next returns when the next window opens after t. ok is false when none
+// opens within a year.
+func (s *Schedule) Next(t time.Time) (next time.Time, ok bool) {
+	if s == nil {
+		return t, true
+	}
+	t = t.In(s.loc)
+	limit := t.Add(horizon)
+	for _, w := range s.windows {
+		if opens, found := w.next(t, limit); found && (!ok || opens.Before(next)) {
+			next, ok = opens, true
+		}
+	}
+	return next, ok
+}
+
+func (w window) open(t time.Time) bool {
+	if w.cron != nil {
+		// Open if the schedule matched within the last duration
+		for m := t.Truncate(time.Minute); t.Sub(m) < w.duration; m = m.Add(-time.Minute) {
+			if w.cron.matches(m) {
+				return true
+			}
+		}
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	today := t.Weekday()
+	if w.start < w.end {
+		return w.days[today] && now >= w.start && now < w.end
+	}
+	// The window runs past midnight: it's open late on a listed day and
+	// early the day after one
+	yesterday := (today + 6) % 7
+	return (w.days[today] && now >= w.start) || (w.days[yesterday] && now < w.end)
+}
+
+func (w window) next(t, limit time.Time) (time.Time, bool) {
+	if w.cron != nil {
+		return w.cron.next(t, limit)
+	}
+	y, m, d := t.Date()
+	for offset := 0; offset <= 7; offset++ {
+		opens := time.Date(y, m, d+offset, w.start/60, w.start%60, 0, 0, t.Location())
+		if w.days[opens.Weekday()] && opens.After(t) {
+			return opens, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,111 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustSchedule(t *testing.T, windows ...models.MaintenanceWindow) *Schedule {
+	t.Helper()
+	s, err := New(&models.MaintenanceConfig{Windows: windows, Timezone: "Europe/Berlin"})
+	require.NoError(t, err)
+	return s
+}
+
+func berlin(t *testing.T, value string) time.Time {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	ts, err := time.ParseInLocation("2006-01-02 15:04", value, loc)
+	require.NoError(t, err)
+	return ts
+}
+
+func TestWeeklyWindow(t *testing.T) {
+	s := mustSchedule(t, models.MaintenanceWindow{Name: "weeknights", Days: []string{"mon-fri"}, Start: "22:00", End: "04:00"})
+
+	for _, tc := range []struct {
+		at   string
+		open bool
+	}{
+		{"2026-10-12 21:59", false}, // Monday
+		{"2026-10-12 22:00", true},
+		{"2026-10-13 03:59", true}, // Tuesday morning, from Monday night
+		{"2026-10-13 04:00", false},
+		{"2026-10-17 02:00", true}, // Saturday morning, from Friday night
+		{"2026-10-17 22:30", false},
+		{"2026-10-19 01:00", false}, // Monday morning, Sunday isn't listed
+	} {
+		open, name := s.Open(berlin(t, tc.at))
+		assert.Equal(t, tc.open, open, tc.at)
+		if open {
+			assert.Equal(t, "weeknights", name)
+		}
+	}
+
+	next, ok := s.Next(berlin(t, "2026-10-17 12:00"))
+	require.True(t, ok)
+	assert.Equal(t, berlin(t, "2026-10-19 22:00"), next, "the weekend is skipped")
+}
+
+func TestCronWindow(t *testing.T) {
+	s := mustSchedule(t, models.MaintenanceWindow{Cron: "30 2 1-7 * sun", Duration: "3h"})
+
+	// Day-of-month and day-of-week both restricted: either matches, as in cron
+	assert.False(t, must(s.Open(berlin(t, "2026-10-02 02:29"))))
+	assert.True(t, must(s.Open(berlin(t, "2026-10-02 02:30"))))
+	assert.True(t, must(s.Open(berlin(t, "2026-10-02 05:29"))))
+	assert.False(t, must(s.Open(berlin(t, "2026-10-02 05:30"))))
+	assert.True(t, must(s.Open(berlin(t, "2026-10-18 04:00"))), "Sunday")
+
+	next, ok := s.Next(berlin(t, "2026-10-08 12:00"))
+	require.True(t, ok)
+	assert.Equal(t, berlin(t, "2026-10-11 02:30"), next)
+}
+
+func TestNextAcrossMonths(t *testing.T) {
+	s := mustSchedule(t, models.MaintenanceWindow{Cron: "0 3 29 feb *", Duration: "1h"})
+	next, ok := s.Next(berlin(t, "2027-03-05 00:00"))
+	require.True(t, ok, "found within a year")
+	assert.Equal(t, berlin(t, "2028-02-29 03:00"), next)
+
+	// The next 29 February after 2028 is more than a year away
+	_, ok = s.Next(berlin(t, "2028-03-01 00:00"))
+	assert.False(t, ok)
+}
+
+func TestNilScheduleIsOpen(t *testing.T) {
+	s, err := New(&models.MaintenanceConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, s)
+	assert.True(t, must(s.Open(time.Now())))
+}
+
+func TestInvalidWindows(t *testing.T) {
+	for _, w := range []models.MaintenanceWindow{
+		{Start: "22:00"},
+		{Start: "22:00", End: "22:00"},
+		{Days: []string{"mo"}, Start: "01:00", End: "02:00"},
+		{Start: "25:00", End: "02:00"},
+		{Cron: "0 3 * *", Duration: "1h"},
+		{Cron: "0 3 * * *"},
+		{Cron: "0 3 * * *", Duration: "30d"},
+		{Cron: "61 3 * * *", Duration: "1h"},
+		{Cron: "0 3 * * *", Duration: "1h", Days: []string{"mon"}},
+		{Start: "01:00", End: "02:00", Duration: "1h"},
+	} {
+		_, err := New(&models.MaintenanceConfig{Windows: []models.MaintenanceWindow{w}})
+		assert.Error(t, err, "%+v", w)
+	}
+	_, err := New(&models.MaintenanceConfig{Timezone: "Mars/Olympus", Windows: []models.MaintenanceWindow{{Start: "01:00", End: "02:00"}}})
+	assert.Error(t, err)
+}
+
+func must(open bool, _ string) bool {
+	return open
+}
@@ -0,0 +1,204 @@
+// Package metrics keeps the counters, gauges and summaries serve exposes on
+// metrics_listen, and renders them in the Prometheus text exposition format
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the Prometheus text exposition format served by Handler
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Registry holds metric families in the order they were registered
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+}
+
+type family struct {
+	name   string
+	help   string
+	kind   string // counter, gauge or summary
+	labels []string
+	series map[string]*series // keyed by the joined label values
+}
+
+type series struct {
+	values []string
+	value  float64 // counter and gauge
+	sum    float64 // summary
+	count  uint64  // summary
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(name, help, kind string, labels []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.families {
+		if f.name == name {
+			panic("metrics: " + name + " registered twice")
+		}
+	}
+	f := &family{name: name, help: help, kind: kind, labels: labels, series: make(map[string]*series)}
+	r.families = append(r.families, f)
+	return f
+}
+
+// update runs fn on the series for values, creating it on first use. The
+// number of values must match the family's labels.
+func (r *Registry) update(f *family, values []string, fn func(*series)) {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		f.series[key] = s
+	}
+	fn(s)
+}
+
+// Counter is a value that only goes up
+type Counter struct {
+	r *Registry
+	f *family
+}
+
+// Counter registers a counter with the given label names
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r: r, f: r.register(name, help, "counter", labels)}
+}
+
+// Inc adds one to the series with the given label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v, which must not be negative, to the series with the given label values
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic("metrics: counter " + c.f.name + " decreased")
+	}
+	c.r.update(c.f, values, func(s *series) { s.value += v })
+}
+
+// Gauge is a value that is set to the current state
+type Gauge struct {
+	r *Registry
+	f *family
+}
+
+// Gauge registers a gauge with the given label names
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r: r, f: r.register(name, help, "gauge", labels)}
+}
+
+// Set sets the series with the given label values to v
+func (g *Gauge) Set(v float64, values ...string) {
+	g.r.update(g.f, values, func(s *series) { s.value = v })
+}
+
+// Summary counts observations and their total, without quantiles
+type Summary struct {
+	r *Registry
+	f *family
+}
+
+// Summary registers a summary with the given label names
+func (r *Registry) Summary(name, help string, labels ...string) *Summary {
+	return &Summary{r: r, f: r.register(name, help, "summary", labels)}
+}
+
+// Observe records one observation of v
+func (s *Summary) Observe(v float64, values ...string) {
+	s.r.update(s.f, values, func(ser *series) {
+		ser.sum += v
+		ser.count++
+	})
+}
+
+// Write renders every family with at least one series
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	for _, f := range r.families {
+		if len(f.series) == 0 {
+			continue
+		}
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, helpEscaper.Replace(f.help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.kind)
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := f.series[key]
+			labels := formatLabels(f.labels, s.values)
+			if f.kind == "summary" {
+				fmt.Fprintf(bw, "%s_sum%s %s\n", f.name, labels, formatValue(s.sum))
+				fmt.Fprintf(bw, "%s_count%s %d\n", f.name, labels, s.count)
+				continue
+			}
+			fmt.Fprintf(bw, "%s%s %s\n", f.name, labels, formatValue(s.value))
+		}
+	}
+	return bw.Flush()
+}
+
+// Handler serves the registry. collect, if set, runs before each scrape to
+// set gauges that are read from the agent's state rather than updated as
+// things happen.
+func (r *Registry) Handler(collect func()) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if collect != nil {
+			collect()
+		}
+		w.Header().Set("Content-Type", ContentType)
+		_ = r.Write(w)
+	})
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + labelEscaper.Replace(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	r := NewRegistry()
+	reports := r.Counter("reports_total", "Reports sent.", "result")
+	pending := r.Gauge("pending", "Pending updates.")
+	scans := r.Summary("scan_seconds", "Scan time.", "scan")
+	r.Gauge("unused", "Never set.")
+
+	reports.Inc("success")
+	reports.Inc("success")
+	reports.Inc("failure")
+	pending.Set(12)
+	scans.Observe(1.5, "openscap")
+	scans.Observe(2, "openscap")
+
+	var buf bytes.Buffer
+	require.NoError(t, r.Write(&buf))
+	assert.Equal(t, `# HELP reports_total Reports sent.
+# TYPE reports_total counter
+reports_total{result="failure"} 1
+reports_total{result="success"} 2
+# HELP pending Pending updates.
+# TYPE pending gauge
+pending 12
+# HELP scan_seconds Scan time.
+# TYPE scan_seconds summary
+scan_seconds_sum{scan="openscap"} 3.5
+scan_seconds_count{scan="openscap"} 2
+`, buf.String())
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	connects := r.Counter("connects_total", "Connects\\by server.", "server")
+	connects.Inc("msp \"eu\"\\1\n")
+
+	var buf bytes.Buffer
+	require.NoError(t, r.Write(&buf))
+	assert.Contains(t, buf.String(), `# HELP connects_total Connects\\by server.`)
+	assert.Contains(t, buf.String(), `connects_total{server="msp \"eu\"\\1\n"} 1`)
+}
+
+func TestMisuse(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("c", "C.", "result")
+	assert.Panics(t, func() { r.Gauge("c", "Again.") })
+	assert.Panics(t, func() { c.Inc() })
+	assert.Panics(t, func() { c.Add(-1, "success") })
+}
+
+func TestHandlerCollects(t *testing.T) {
+	r := NewRegistry()
+	g := r.Gauge("pending", "Pending updates.")
+	calls := 0
+	srv := httptest.NewServer(r.Handler(func() {
+		calls++
+		g.Set(float64(calls))
+	}))
+	defer srv.Close()
+
+	for want := 1; want <= 2; want++ {
+		resp, err := http.Get(srv.URL)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, ContentType, resp.Header.Get("Content-Type"))
+		assert.Contains(t, string(body), fmt.Sprintf("pending %d\n", want))
+	}
+}
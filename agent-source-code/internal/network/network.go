@@ -13,8 +13,9 @@ import (
 
 	"github.com/sirupsen/logrus"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 	"patchmon-agent/internal/constants"
-	"patchmon-agent/pkg/models"
+	"patchmon-agent/internal/utils"
 )
 
 // Manager handles network information collection using standard library and file parsing
@@ -149,10 +150,10 @@ func (m *Manager) getGatewayViaNetstat(ipv6 bool) string {
 
 	if ipv6 {
 		// Get IPv6 default gateway
-		cmd = exec.Command("netstat", "-rn", "-f", "inet6")
+		cmd = utils.Command("netstat", "-rn", "-f", "inet6")
 	} else {
 		// Get IPv4 default gateway
-		cmd = exec.Command("netstat", "-rn", "-f", "inet")
+		cmd = utils.Command("netstat", "-rn", "-f", "inet")
 	}
 
 	output, err := cmd.Output()
@@ -391,7 +392,7 @@ func (m *Manager) gatewayTablesByInterface() (ipv4, ipv6 map[string]string) {
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
-		out, err := exec.Command("ip", "route", "show").Output()
+		out, err := utils.Command("ip", "route", "show").Output()
 		if err != nil {
 			return
 		}
@@ -399,7 +400,7 @@ func (m *Manager) gatewayTablesByInterface() (ipv4, ipv6 map[string]string) {
 	}()
 	go func() {
 		defer wg.Done()
-		out, err := exec.Command("ip", "-6", "route", "show").Output()
+		out, err := utils.Command("ip", "-6", "route", "show").Output()
 		if err != nil {
 			return
 		}
@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"sync"
+	"time"
+)
+
+// CrashLoopDetector counts container exits and reports a crash loop when one
+// container exits too often within a window. Each loop is reported once per
+// window.
+type CrashLoopDetector struct {
+	restarts int
+	window   time.Duration
+
+	mu       sync.Mutex
+	exits    map[string][]time.Time
+	reported map[string]time.Time
+}
+
+// NewCrashLoopDetector uses the defaults for values <= 0
+func NewCrashLoopDetector(restarts int, window time.Duration) *CrashLoopDetector {
+	if restarts <= 0 {
+		restarts = DefaultCrashLoopRestarts
+	}
+	if window <= 0 {
+		window = DefaultCrashLoopWindow
+	}
+	return &CrashLoopDetector{
+		restarts: restarts,
+		window:   window,
+		exits:    map[string][]time.Time{},
+		reported: map[string]time.Time{},
+	}
+}
+
+// Exited records an exit of container id at t and reports whether it is now
+// crash-looping and hasn't been reported within the window
+func (d *CrashLoopDetector) Exited(id string, t time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cutoff := t.Add(-d.window)
+	recent := d.exits[id][:0]
+	for _, at := range d.exits[id] {
+		if at.After(cutoff) {
+			recent = append(recent, at)
+		}
+	}
+	recent = append(recent, t)
+	d.exits[id] = recent
+
+	if len(recent) < d.restarts {
+		return false
+	}
+	if last, ok := d.reported[id]; ok && t.Sub(last) < d.window {
+		return false
+	}
+	d.reported[id] = t
+	return true
+}
+
+// Window returns the detection window
+func (d *CrashLoopDetector) Window() time.Duration {
+	return d.window
+}
+
+// Restarts returns the exit count that makes a crash loop
+func (d *CrashLoopDetector) Restarts() int {
+	return d.restarts
+}
@@ -0,0 +1,157 @@
+// Package notify sends local notifications for significant agent events to
+// webhooks, ntfy, Gotify and local commands, so small sites get alerted
+// without a separate alerting stack.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/redact"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Event types
+const (
+	EventReportFailed       = "report_failed"
+	EventReportRecovered    = "report_recovered"
+	EventRebootRequired     = "reboot_required"
+	EventComplianceScoreLow = "compliance_score_low"
+	EventContainerCrashLoop = "container_crash_loop"
+	// EventComplianceRegression fires when rules that passed start failing
+	EventComplianceRegression = "compliance_regression"
+)
+
+// Defaults for thresholds left unset in NotificationsConfig
+const (
+	DefaultReportFailureThreshold = 3
+	DefaultCrashLoopRestarts      = 3
+	DefaultCrashLoopWindow        = 10 * time.Minute
+)
+
+// sendTimeout bounds each delivery so a dead endpoint can't stall the agent
+const sendTimeout = 15 * time.Second
+
+// Event is one notification
+type Event struct {
+	Type     string            `json:"event"`
+	Hostname string            `json:"hostname"`
+	Title    string            `json:"title"`
+	Message  string            `json:"message"`
+	Time     time.Time         `json:"time"`
+	Details  map[string]string `json:"details,omitempty"`
+}
+
+// target delivers events to one destination
+type target interface {
+	send(ctx context.Context, ev Event) error
+	String() string
+}
+
+type route struct {
+	target target
+	events []string
+}
+
+func (r route) wants(eventType string) bool {
+	return len(r.events) == 0 || slices.Contains(r.events, eventType)
+}
+
+// Notifier fans events out to the configured targets
+type Notifier struct {
+	routes   []route
+	redactor *redact.Redactor
+	logger   *logrus.Logger
+}
+
+// New builds a notifier from config. A nil config gives a notifier with no
+// targets, which drops every event.
+func New(cfg *models.NotificationsConfig, logger *logrus.Logger) *Notifier {
+	n := &Notifier{logger: logger}
+	if cfg == nil {
+		return n
+	}
+	for _, w := range cfg.Webhooks {
+		if w.URL == "" {
+			continue
+		}
+		n.routes = append(n.routes, route{target: newWebhook(w), events: w.Events})
+	}
+	for _, t := range cfg.Ntfy {
+		if t.Topic == "" {
+			logger.Warn("Ignoring ntfy notification target without a topic")
+			continue
+		}
+		n.routes = append(n.routes, route{target: newNtfy(t), events: t.Events})
+	}
+	for _, t := range cfg.Gotify {
+		if t.Server == "" || t.Token == "" {
+			logger.Warn("Ignoring Gotify notification target without a server and token")
+			continue
+		}
+		n.routes = append(n.routes, route{target: newGotify(t), events: t.Events})
+	}
+	for _, e := range cfg.Exec {
+		if len(e.Command) == 0 {
+			continue
+		}
+		n.routes = append(n.routes, route{target: &execTarget{argv: e.Command}, events: e.Events})
+	}
+	return n
+}
+
+// SetRedactor masks every event with r before it reaches a target
+func (n *Notifier) SetRedactor(r *redact.Redactor) {
+	n.redactor = r
+}
+
+// Enabled reports whether any target is configured
+func (n *Notifier) Enabled() bool {
+	return len(n.routes) > 0
+}
+
+// Send delivers ev to every target that wants it, in parallel, and waits for
+// them. Failures are logged; an unreachable endpoint never fails the caller.
+func (n *Notifier) Send(ctx context.Context, ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	if err := n.redactor.Value(&ev); err != nil {
+		n.logger.WithError(err).WithField("event", ev.Type).Warn("Dropping notification")
+		return
+	}
+	var wg sync.WaitGroup
+	for _, r := range n.routes {
+		if !r.wants(ev.Type) {
+			continue
+		}
+		wg.Add(1)
+		go func(t target) {
+			defer wg.Done()
+			sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+			defer cancel()
+			if err := t.send(sendCtx, ev); err != nil {
+				n.logger.WithError(err).WithFields(logrus.Fields{
+					"event":  ev.Type,
+					"target": t.String(),
+				}).Warn("Failed to send notification")
+				return
+			}
+			n.logger.WithFields(logrus.Fields{
+				"event":  ev.Type,
+				"target": t.String(),
+			}).Debug("Notification sent")
+		}(r.target)
+	}
+	wg.Wait()
+}
+
+// text is the one-line form used by chat-style targets
+func (ev Event) text() string {
+	return fmt.Sprintf("%s: %s", ev.Title, ev.Message)
+}
@@ -0,0 +1,158 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/redact"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func quietLogger() *logrus.Logger {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	return l
+}
+
+// recorder collects request bodies posted to it
+type recorder struct {
+	mu      sync.Mutex
+	bodies  []map[string]interface{}
+	headers []http.Header
+}
+
+func (r *recorder) server(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		r.mu.Lock()
+		r.bodies = append(r.bodies, body)
+		r.headers = append(r.headers, req.Header.Clone())
+		r.mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestNilConfigIsDisabled(t *testing.T) {
+	n := New(nil, quietLogger())
+	assert.False(t, n.Enabled())
+	n.Send(context.Background(), Event{Type: EventReportFailed})
+}
+
+func TestWebhookFormatsAndFiltering(t *testing.T) {
+	var jsonHook, slackHook recorder
+	jsonSrv := jsonHook.server(t)
+	slackSrv := slackHook.server(t)
+
+	n := New(&models.NotificationsConfig{
+		Webhooks: []models.WebhookTarget{
+			{URL: jsonSrv.URL, Headers: map[string]string{"Authorization": "Bearer x"}},
+			{URL: slackSrv.URL, Format: "slack", Events: []string{EventRebootRequired}},
+		},
+	}, quietLogger())
+	require.True(t, n.Enabled())
+
+	n.Send(context.Background(), Event{Type: EventReportFailed, Hostname: "web1", Title: "down", Message: "no reports"})
+	n.Send(context.Background(), Event{Type: EventRebootRequired, Hostname: "web1", Title: "Reboot required", Message: "kernel"})
+
+	require.Len(t, jsonHook.bodies, 2)
+	assert.Equal(t, EventReportFailed, jsonHook.bodies[0]["event"])
+	assert.Equal(t, "web1", jsonHook.bodies[0]["hostname"])
+	assert.NotEmpty(t, jsonHook.bodies[0]["time"])
+	assert.Equal(t, "Bearer x", jsonHook.headers[0].Get("Authorization"))
+
+	require.Len(t, slackHook.bodies, 1, "slack target only wants reboot_required")
+	assert.Equal(t, "Reboot required: kernel", slackHook.bodies[0]["text"])
+}
+
+func TestEventsAreRedacted(t *testing.T) {
+	var hook, slack recorder
+	hookSrv, slackSrv := hook.server(t), slack.server(t)
+	n := New(&models.NotificationsConfig{
+		Webhooks: []models.WebhookTarget{{URL: hookSrv.URL}, {URL: slackSrv.URL, Format: "slack"}},
+	}, quietLogger())
+	r, err := redact.New(&models.RedactionConfig{Fields: []string{"error"}, IPRanges: []string{"10.0.0.0/8"}})
+	require.NoError(t, err)
+	n.SetRedactor(r)
+
+	n.Send(context.Background(), Event{
+		Type:    EventReportFailed,
+		Title:   "down",
+		Message: "dial tcp 10.1.2.3:443: refused",
+		Details: map[string]string{"error": "token abc rejected"},
+	})
+
+	require.Len(t, hook.bodies, 1)
+	assert.NotContains(t, hook.bodies[0]["message"], "10.1.2.3")
+	assert.Equal(t, map[string]interface{}{"error": redact.DefaultReplacement}, hook.bodies[0]["details"])
+	require.Len(t, slack.bodies, 1)
+	assert.NotContains(t, slack.bodies[0]["text"], "10.1.2.3")
+}
+
+func TestWebhookNon2xxIsError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	hook := newWebhook(models.WebhookTarget{URL: srv.URL})
+	assert.Error(t, hook.send(context.Background(), Event{Type: EventReportFailed}))
+}
+
+func TestRedactURL(t *testing.T) {
+	assert.Equal(t, "https://hooks.slack.com/...", redactURL("https://hooks.slack.com/services/T000/B000/secret"))
+	assert.Equal(t, "webhook", redactURL("not a url"))
+}
+
+func TestExecTarget(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses /bin/sh")
+	}
+	out := filepath.Join(t.TempDir(), "event")
+	n := New(&models.NotificationsConfig{
+		Exec: []models.ExecTarget{{Command: []string{"/bin/sh", "-c", `cat > "$1"; echo "$PATCHMON_EVENT" >> "$1"`, "sh", out}}},
+	}, quietLogger())
+
+	n.Send(context.Background(), Event{Type: EventContainerCrashLoop, Title: "t", Message: "m"})
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"event":"container_crash_loop"`)
+	assert.Contains(t, string(data), "}container_crash_loop\n", "PATCHMON_EVENT is set")
+}
+
+func TestCrashLoopDetector(t *testing.T) {
+	d := NewCrashLoopDetector(3, time.Minute)
+	start := time.Now()
+
+	assert.False(t, d.Exited("a", start))
+	assert.False(t, d.Exited("a", start.Add(10*time.Second)))
+	assert.False(t, d.Exited("b", start.Add(15*time.Second)), "exits are counted per container")
+	assert.True(t, d.Exited("a", start.Add(20*time.Second)))
+	assert.False(t, d.Exited("a", start.Add(30*time.Second)), "reported once per window")
+
+	// Exits spread wider than the window never add up to a loop
+	assert.False(t, d.Exited("c", start))
+	assert.False(t, d.Exited("c", start.Add(2*time.Minute)))
+	assert.False(t, d.Exited("c", start.Add(4*time.Minute)))
+}
+
+func TestCrashLoopDetectorDefaults(t *testing.T) {
+	d := NewCrashLoopDetector(0, 0)
+	assert.Equal(t, DefaultCrashLoopRestarts, d.Restarts())
+	assert.Equal(t, DefaultCrashLoopWindow, d.Window())
+}
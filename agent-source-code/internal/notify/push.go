@@ -0,0 +1,93 @@
+package notify
+
+import (
+	"context"
+	"strings"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// DefaultNtfyServer is used when an ntfy target doesn't name a server
+const DefaultNtfyServer = "https://ntfy.sh"
+
+// urgent reports whether an event means something is broken now rather than
+// something to look at, and should page at a higher priority
+func urgent(eventType string) bool {
+	return eventType == EventReportFailed || eventType == EventContainerCrashLoop
+}
+
+// ntfy publishes with ntfy's JSON API: POST to the server root with the topic
+// in the body
+type ntfy struct {
+	server   string
+	topic    string
+	token    string
+	priority int
+}
+
+func newNtfy(cfg models.NtfyTarget) *ntfy {
+	server := strings.TrimRight(cfg.Server, "/")
+	if server == "" {
+		server = DefaultNtfyServer
+	}
+	return &ntfy{server: server, topic: cfg.Topic, token: cfg.Token, priority: cfg.Priority}
+}
+
+func (t *ntfy) String() string {
+	return "ntfy " + redactURL(t.server)
+}
+
+func (t *ntfy) send(ctx context.Context, ev Event) error {
+	priority := t.priority
+	if priority == 0 {
+		priority = 3
+		if urgent(ev.Type) {
+			priority = 4
+		}
+	}
+	var headers map[string]string
+	if t.token != "" {
+		headers = map[string]string{"Authorization": "Bearer " + t.token}
+	}
+	return postJSON(ctx, t.server, headers, map[string]interface{}{
+		"topic":    t.topic,
+		"title":    ev.Title,
+		"message":  ev.Message,
+		"priority": priority,
+		"tags":     []string{"patchmon", ev.Type},
+	})
+}
+
+// gotify pushes to a Gotify server's /message endpoint with an application
+// token
+type gotify struct {
+	server   string
+	token    string
+	priority int
+}
+
+func newGotify(cfg models.GotifyTarget) *gotify {
+	return &gotify{server: strings.TrimRight(cfg.Server, "/"), token: cfg.Token, priority: cfg.Priority}
+}
+
+func (t *gotify) String() string {
+	return "gotify " + redactURL(t.server)
+}
+
+func (t *gotify) send(ctx context.Context, ev Event) error {
+	priority := t.priority
+	if priority == 0 {
+		priority = 5
+		if urgent(ev.Type) {
+			priority = 8
+		}
+	}
+	return postJSON(ctx, t.server+"/message", map[string]string{"X-Gotify-Key": t.token}, map[string]interface{}{
+		"title":    ev.Title,
+		"message":  ev.Message,
+		"priority": priority,
+		"extras": map[string]interface{}{
+			"patchmon::event": map[string]string{"type": ev.Type, "hostname": ev.Hostname},
+		},
+	})
+}
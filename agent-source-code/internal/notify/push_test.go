@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"context"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNtfy(t *testing.T) {
+	var rec recorder
+	srv := rec.server(t)
+
+	n := New(&models.NotificationsConfig{
+		Ntfy: []models.NtfyTarget{{Server: srv.URL + "/", Topic: "homelab", Token: "tk_abc"}},
+	}, quietLogger())
+
+	n.Send(context.Background(), Event{Type: EventReportFailed, Title: "PatchMon agent can't report", Message: "web1 failed"})
+	n.Send(context.Background(), Event{Type: EventRebootRequired, Title: "Reboot required", Message: "web1 needs a reboot"})
+
+	require.Len(t, rec.bodies, 2)
+	assert.Equal(t, "homelab", rec.bodies[0]["topic"])
+	assert.Equal(t, "PatchMon agent can't report", rec.bodies[0]["title"])
+	assert.Equal(t, float64(4), rec.bodies[0]["priority"], "failures are sent at high priority")
+	assert.Equal(t, float64(3), rec.bodies[1]["priority"])
+	assert.Equal(t, "Bearer tk_abc", rec.headers[0].Get("Authorization"))
+}
+
+func TestNtfyDefaultServer(t *testing.T) {
+	assert.Equal(t, DefaultNtfyServer, newNtfy(models.NtfyTarget{Topic: "x"}).server)
+}
+
+func TestGotify(t *testing.T) {
+	var rec recorder
+	srv := rec.server(t)
+
+	n := New(&models.NotificationsConfig{
+		Gotify: []models.GotifyTarget{{Server: srv.URL, Token: "AppToken", Priority: 2}},
+	}, quietLogger())
+
+	n.Send(context.Background(), Event{Type: EventContainerCrashLoop, Hostname: "docker1", Title: "Container crash-looping", Message: "api exited"})
+
+	require.Len(t, rec.bodies, 1)
+	assert.Equal(t, "AppToken", rec.headers[0].Get("X-Gotify-Key"))
+	assert.Equal(t, "api exited", rec.bodies[0]["message"])
+	assert.Equal(t, float64(2), rec.bodies[0]["priority"], "configured priority wins")
+}
+
+func TestIncompletePushTargetsAreIgnored(t *testing.T) {
+	n := New(&models.NotificationsConfig{
+		Ntfy:   []models.NtfyTarget{{Server: "https://ntfy.example.com"}},
+		Gotify: []models.GotifyTarget{{Server: "https://gotify.example.com"}},
+	}, quietLogger())
+	assert.False(t, n.Enabled())
+}
@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// httpClient is shared by the HTTP-based targets
+var httpClient = &http.Client{
+	Transport: &http.Transport{Proxy: http.ProxyFromEnvironment},
+}
+
+// postJSON posts body and treats any non-2xx status as an error
+func postJSON(ctx context.Context, endpoint string, headers map[string]string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// redactURL hides the path and query, which often carry webhook tokens
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "webhook"
+	}
+	return u.Scheme + "://" + u.Host + "/..."
+}
+
+type webhook struct {
+	url     string
+	format  string
+	headers map[string]string
+}
+
+func newWebhook(cfg models.WebhookTarget) *webhook {
+	return &webhook{url: cfg.URL, format: cfg.Format, headers: cfg.Headers}
+}
+
+func (w *webhook) String() string {
+	return "webhook " + redactURL(w.url)
+}
+
+func (w *webhook) send(ctx context.Context, ev Event) error {
+	var body interface{} = ev
+	if w.format == "slack" {
+		// Slack, Mattermost and Rocket.Chat incoming webhooks
+		body = map[string]string{"text": ev.text()}
+	}
+	return postJSON(ctx, w.url, w.headers, body)
+}
+
+// execTarget runs a command with the event as JSON on stdin and in
+// PATCHMON_EVENT_* environment variables
+type execTarget struct {
+	argv []string
+}
+
+func (e *execTarget) String() string {
+	return "exec " + e.argv[0]
+}
+
+func (e *execTarget) send(ctx context.Context, ev Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, e.argv[0], e.argv[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Env = append(os.Environ(),
+		"PATCHMON_EVENT="+ev.Type,
+		"PATCHMON_EVENT_HOSTNAME="+ev.Hostname,
+		"PATCHMON_EVENT_TITLE="+ev.Title,
+		"PATCHMON_EVENT_MESSAGE="+ev.Message,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if len(out) > 200 {
+			out = out[:200]
+		}
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
@@ -3,41 +3,53 @@ package packages
 
 import (
 	"bufio"
-	"os"
-	"os/exec"
+	"context"
 	"regexp"
+	"slices"
 	"strings"
 
-	"patchmon-agent/pkg/models"
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner"
 
 	"github.com/sirupsen/logrus"
 )
 
 // APKManager handles APK package information collection
 type APKManager struct {
-	logger *logrus.Logger
+	logger       *logrus.Logger
+	runner       cmdrunner.Runner
+	cacheRefresh CacheRefreshConfig
 }
 
 // NewAPKManager creates a new APK package manager
-func NewAPKManager(logger *logrus.Logger) *APKManager {
+func NewAPKManager(logger *logrus.Logger, cacheRefresh CacheRefreshConfig) *APKManager {
 	return &APKManager{
-		logger: logger,
+		logger:       logger,
+		runner:       cmdrunner.Default,
+		cacheRefresh: cacheRefresh,
 	}
 }
 
+// apkIndexStale reports whether the downloaded APKINDEX files are older than maxAgeMinutes
+func apkIndexStale(maxAgeMinutes int) bool {
+	return cacheOlderThan([]string{"/var/cache/apk", "/etc/apk/cache"}, maxAgeMinutes)
+}
+
 // GetPackages gets package information for APK-based systems
 func (m *APKManager) GetPackages() []models.Package {
-	// Update package index
-	m.logger.Debug("Updating package index...")
-	updateCmd := exec.Command("apk", "update", "-q")
-	if err := updateCmd.Run(); err != nil {
-		m.logger.WithError(err).Warn("Failed to update package index")
+	// Update package index unless the cache refresh mode forbids it
+	if m.cacheRefresh.ShouldRefresh(apkIndexStale) {
+		m.logger.WithField("mode", m.cacheRefresh.Mode).Debug("Updating package index...")
+		if _, err := m.runner.Output(context.Background(), "apk", "update", "-q"); err != nil {
+			m.logger.WithError(err).Warn("Failed to update package index")
+		}
+	} else {
+		m.logger.WithField("mode", m.cacheRefresh.Mode).Debug("Skipping package index update")
 	}
 
 	// Get installed packages
 	m.logger.Debug("Getting installed packages...")
-	installedCmd := exec.Command("apk", "list", "--installed")
-	installedOutput, err := installedCmd.Output()
+	installedOutput, err := m.runner.Output(context.Background(), "apk", "list", "--installed")
 	var installedPackages map[string]models.Package
 	if err != nil {
 		m.logger.WithError(err).Warn("Failed to get installed packages")
@@ -50,8 +62,7 @@ func (m *APKManager) GetPackages() []models.Package {
 
 	// Get upgradable packages (must run after apk update)
 	m.logger.Debug("Getting upgradable packages...")
-	upgradableCmd := exec.Command("apk", "-u", "list")
-	upgradableOutput, err := upgradableCmd.Output()
+	upgradableOutput, err := m.runner.Output(context.Background(), "apk", "-u", "list")
 	var upgradablePackages []models.Package
 	if err != nil {
 		m.logger.WithError(err).Warn("Failed to get upgradable packages")
@@ -84,6 +95,8 @@ func (m *APKManager) enrichWithRepoAttribution(packages []models.Package) {
 	for i := range packages {
 		names[i] = packages[i].Name
 	}
+	// Sorted so the batches, and the commands run, are the same every time
+	slices.Sort(names)
 
 	pkgIdx := make(map[string][]int, len(packages))
 	for i, p := range packages {
@@ -101,9 +114,7 @@ func (m *APKManager) enrichWithRepoAttribution(packages []models.Package) {
 		batch := names[start:end]
 
 		args := append([]string{"policy"}, batch...)
-		cmd := exec.Command("apk", args...)
-		cmd.Env = append(os.Environ(), "LANG=C")
-		output, err := cmd.Output()
+		output, err := m.runner.Output(context.Background(), "apk", args...)
 		if err != nil {
 			m.logger.WithError(err).Warn("apk policy failed, skipping repo attribution for batch")
 			continue
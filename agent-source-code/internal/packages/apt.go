@@ -2,15 +2,14 @@ package packages
 
 import (
 	"bufio"
-	"os"
-	"os/exec"
+	"context"
 	"runtime"
 	"slices"
 	"strings"
 	"sync"
-	"time"
 
-	"patchmon-agent/pkg/models"
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner"
 
 	"github.com/sirupsen/logrus"
 )
@@ -18,6 +17,7 @@ import (
 // APTManager handles APT package information collection
 type APTManager struct {
 	logger       *logrus.Logger
+	runner       cmdrunner.Runner
 	cacheRefresh CacheRefreshConfig
 }
 
@@ -25,6 +25,7 @@ type APTManager struct {
 func NewAPTManager(logger *logrus.Logger, cacheRefresh CacheRefreshConfig) *APTManager {
 	return &APTManager{
 		logger:       logger,
+		runner:       cmdrunner.Default,
 		cacheRefresh: cacheRefresh,
 	}
 }
@@ -32,11 +33,11 @@ func NewAPTManager(logger *logrus.Logger, cacheRefresh CacheRefreshConfig) *APTM
 // detectPackageManager detects whether to use apt or apt-get
 func (m *APTManager) detectPackageManager() string {
 	// Prefer /usr/bin/apt (upstream binary) to avoid wrapper scripts (like on Linux Mint)
-	if _, err := exec.LookPath("/usr/bin/apt"); err == nil {
+	if _, err := m.runner.LookPath("/usr/bin/apt"); err == nil {
 		return "/usr/bin/apt"
 	}
 	// Fallback to checking for "apt" in PATH
-	if _, err := exec.LookPath("apt"); err == nil {
+	if _, err := m.runner.LookPath("apt"); err == nil {
 		return "apt"
 	}
 	// As a last resort, try "apt-get"
@@ -49,12 +50,9 @@ func (m *APTManager) GetPackages() []models.Package {
 	packageManager := m.detectPackageManager()
 
 	// Conditionally refresh the package cache based on configuration
-	shouldRefresh := m.cacheRefresh.Mode == "always" ||
-		(m.cacheRefresh.Mode == "if_stale" && m.isCacheStale(m.cacheRefresh.MaxAge))
-	if shouldRefresh {
+	if m.cacheRefresh.ShouldRefresh(APTCacheStale) {
 		m.logger.WithField("mode", m.cacheRefresh.Mode).Debug("Refreshing package cache")
-		updateCmd := exec.Command(packageManager, "update", "-qq")
-		if err := updateCmd.Run(); err != nil {
+		if _, err := m.runner.Output(context.Background(), packageManager, "update", "-qq"); err != nil {
 			m.logger.WithError(err).WithField("manager", packageManager).Warn("Failed to update package lists")
 		}
 	} else {
@@ -67,17 +65,17 @@ func (m *APTManager) GetPackages() []models.Package {
 	var (
 		installedPackages  map[string]models.Package
 		upgradablePackages []models.Package
+		withPhased         []models.Package
+		phasedErr          error
 	)
 
 	var wg sync.WaitGroup
-	wg.Add(2)
+	wg.Add(3)
 
 	go func() {
 		defer wg.Done()
 		m.logger.Debug("Getting installed packages...")
-		installedCmd := exec.Command("dpkg-query", "-W", "-f", "${Package} ${Version} ${Description}\n")
-		installedCmd.Env = append(os.Environ(), "LANG=C")
-		out, err := installedCmd.Output()
+		out, err := m.runner.Output(context.Background(), "dpkg-query", "-W", "-f", "${Package} ${Version} ${Description}\n")
 		if err != nil {
 			m.logger.WithError(err).Warn("Failed to get installed packages")
 			installedPackages = make(map[string]models.Package)
@@ -90,9 +88,7 @@ func (m *APTManager) GetPackages() []models.Package {
 	go func() {
 		defer wg.Done()
 		m.logger.Debug("Getting upgradable packages...")
-		upgradeCmd := exec.Command(packageManager, "-s", "-o", "Debug::NoLocking=1", "upgrade")
-		upgradeCmd.Env = append(os.Environ(), "LANG=C")
-		out, err := upgradeCmd.Output()
+		out, err := m.runner.Output(context.Background(), packageManager, "-s", "-o", "Debug::NoLocking=1", "upgrade")
 		if err != nil {
 			m.logger.WithError(err).Warn("Failed to get upgrade simulation")
 			upgradablePackages = []models.Package{}
@@ -102,8 +98,27 @@ func (m *APTManager) GetPackages() []models.Package {
 		m.logger.WithField("count", len(upgradablePackages)).Debug("Found upgradable packages")
 	}()
 
+	// The same simulation with phased updates included; what it adds is
+	// held back by Ubuntu's phased rollout rather than pending on this host
+	go func() {
+		defer wg.Done()
+		var out []byte
+		out, phasedErr = m.runner.Output(context.Background(), packageManager, "-s", "-o", "Debug::NoLocking=1",
+			"-o", "APT::Get::Always-Include-Phased-Updates=true", "upgrade")
+		if phasedErr == nil {
+			withPhased = m.parseAPTUpgrade(string(out))
+		}
+	}()
+
 	wg.Wait()
 
+	if phasedErr != nil {
+		m.logger.WithError(phasedErr).Debug("Failed to simulate upgrade with phased updates")
+	} else if phased := phasedUpdates(upgradablePackages, withPhased); len(phased) > 0 {
+		m.logger.WithField("count", len(phased)).Debug("Found phased updates held back")
+		upgradablePackages = append(upgradablePackages, phased...)
+	}
+
 	// Merge and deduplicate packages (pass full installed packages to preserve descriptions)
 	packages := CombinePackageData(installedPackages, upgradablePackages)
 
@@ -132,6 +147,8 @@ func (m *APTManager) enrichWithRepoAttribution(packages []models.Package) {
 	for i := range packages {
 		names[i] = packages[i].Name
 	}
+	// Sorted so the batches, and the commands run, are the same every time
+	slices.Sort(names)
 
 	// Build lookup: name -> index in packages slice (multiple entries possible for same name)
 	pkgIdx := make(map[string][]int, len(packages))
@@ -173,7 +190,6 @@ func (m *APTManager) enrichWithRepoAttribution(packages []models.Package) {
 	for w := 0; w < workers; w++ {
 		go func() {
 			defer wg.Done()
-			env := append(os.Environ(), "LANG=C")
 			for br := range workCh {
 				// Per-batch recover: a parser panic takes out the batch, not
 				// the worker. resultCh still gets a value per batch so the
@@ -187,9 +203,7 @@ func (m *APTManager) enrichWithRepoAttribution(packages []models.Package) {
 					}()
 					batch := names[br.start:br.end]
 					args := append([]string{"policy"}, batch...)
-					cmd := exec.Command("apt-cache", args...)
-					cmd.Env = env
-					output, err := cmd.Output()
+					output, err := m.runner.Output(context.Background(), "apt-cache", args...)
 					if err != nil {
 						m.logger.WithError(err).Warn("apt-cache policy failed, skipping repo attribution for batch")
 						resultCh <- nil
@@ -347,22 +361,6 @@ func (m *APTManager) parseAptCachePolicy(output string, repoMap map[string]strin
 	finalizePkg()
 }
 
-// isCacheStale checks if the APT package cache is older than maxAgeMinutes.
-func (m *APTManager) isCacheStale(maxAgeMinutes int) bool {
-	// Check standard cache file locations
-	paths := []string{"/var/cache/apt/pkgcache.bin", "/var/lib/apt/lists"}
-	for _, path := range paths {
-		info, err := os.Stat(path)
-		if err != nil {
-			continue
-		}
-		age := time.Since(info.ModTime())
-		return age > time.Duration(maxAgeMinutes)*time.Minute
-	}
-	// If we can't determine age, assume stale
-	return true
-}
-
 // parseAPTUpgrade parses apt/apt-get upgrade simulation output
 func (m *APTManager) parseAPTUpgrade(output string) []models.Package {
 	var packages []models.Package
@@ -432,6 +430,26 @@ func (m *APTManager) parseAPTUpgrade(output string) []models.Package {
 	return packages
 }
 
+// phasedUpdates returns the upgrades in withPhased that apt doesn't plan to
+// install, marked as phased and not needing an update
+func phasedUpdates(pending, withPhased []models.Package) []models.Package {
+	planned := make(map[string]bool, len(pending))
+	for _, pkg := range pending {
+		planned[pkg.Name] = true
+	}
+	var phased []models.Package
+	for _, pkg := range withPhased {
+		if planned[pkg.Name] {
+			continue
+		}
+		pkg.NeedsUpdate = false
+		pkg.IsSecurityUpdate = false
+		pkg.PhasedUpdate = true
+		phased = append(phased, pkg)
+	}
+	return phased
+}
+
 // parseInstalledPackages parses dpkg-query output and returns a map of package name to version
 func (m *APTManager) parseInstalledPackages(output string) map[string]models.Package {
 	installedPackages := make(map[string]models.Package)
@@ -457,20 +475,15 @@ func (m *APTManager) parseInstalledPackages(output string) map[string]models.Pac
 		}
 
 		// New package line: Package Version Description
-		// We use SplitN with 3 parts. Description is the rest.
-		parts := strings.SplitN(trimmedLine, " ", 3)
-		if len(parts) < 2 {
+		// Description is the rest; an empty one leaves a double space
+		packageName, rest, _ := strings.Cut(trimmedLine, " ")
+		version, description, _ := strings.Cut(strings.TrimLeft(rest, " "), " ")
+		if packageName == "" || version == "" || strings.ContainsAny(packageName+version, "\t\r\f") {
 			m.logger.WithField("line", line).Debug("Skipping malformed installed package line")
 			currentPkg = nil
 			continue
 		}
-
-		packageName := parts[0]
-		version := parts[1]
-		description := ""
-		if len(parts) == 3 {
-			description = parts[2]
-		}
+		description = strings.TrimSpace(description)
 
 		pkg := models.Package{
 			Name:           packageName,
@@ -3,7 +3,7 @@ package packages
 import (
 	"testing"
 
-	"patchmon-agent/pkg/models"
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -102,3 +102,21 @@ func TestAPTManager_parseAPTUpgrade(t *testing.T) {
 		})
 	}
 }
+
+func TestPhasedUpdates(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	manager := NewAPTManager(logger, CacheRefreshConfig{Mode: "never"})
+
+	pending := manager.parseAPTUpgrade(`Inst libssl3 [3.0.2-0ubuntu1.15] (3.0.2-0ubuntu1.16 Ubuntu:22.04/jammy-security [amd64])`)
+	withPhased := manager.parseAPTUpgrade(`Inst libssl3 [3.0.2-0ubuntu1.15] (3.0.2-0ubuntu1.16 Ubuntu:22.04/jammy-security [amd64])
+Inst systemd [249.11-0ubuntu3.12] (249.11-0ubuntu3.13 Ubuntu:22.04/jammy-updates [amd64])`)
+
+	assert.Equal(t, []models.Package{{
+		Name:             "systemd",
+		CurrentVersion:   "249.11-0ubuntu3.12",
+		AvailableVersion: "249.11-0ubuntu3.13",
+		PhasedUpdate:     true,
+	}}, phasedUpdates(pending, withPhased))
+	assert.Empty(t, phasedUpdates(withPhased, withPhased), "nothing is phased when apt installs everything")
+}
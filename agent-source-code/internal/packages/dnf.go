@@ -2,12 +2,13 @@ package packages
 
 import (
 	"bufio"
-	"os"
-	"os/exec"
+	"context"
 	"slices"
 	"strings"
+	"unicode"
 
-	"patchmon-agent/pkg/models"
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner"
 
 	"github.com/sirupsen/logrus"
 )
@@ -15,12 +16,14 @@ import (
 // DNFManager handles dnf/yum package information collection
 type DNFManager struct {
 	logger *logrus.Logger
+	runner cmdrunner.Runner
 }
 
 // NewDNFManager creates a new DNF package manager
 func NewDNFManager(logger *logrus.Logger) *DNFManager {
 	return &DNFManager{
 		logger: logger,
+		runner: cmdrunner.Default,
 	}
 }
 
@@ -28,7 +31,7 @@ func NewDNFManager(logger *logrus.Logger) *DNFManager {
 func (m *DNFManager) detectPackageManager() string {
 	// Prefer dnf over yum for modern RHEL-based systems
 	packageManager := "dnf"
-	if _, err := exec.LookPath("dnf"); err != nil {
+	if _, err := m.runner.LookPath("dnf"); err != nil {
 		// Fall back to yum if dnf is not available (legacy systems)
 		packageManager = "yum"
 	}
@@ -49,31 +52,11 @@ func (m *DNFManager) GetPackages() []models.Package {
 	// 4. Fedora's cache issue (if any) is resolved by using proper update checks
 
 	// Get installed packages
-	// Note: yum (CentOS 7 / legacy) uses positional argument syntax: "yum list installed"
-	// while dnf uses flag syntax: "dnf list --installed"
 	m.logger.Debug("Getting installed packages...")
-	var listCmd *exec.Cmd
-	if packageManager == "yum" {
-		listCmd = exec.Command(packageManager, "list", "installed")
-	} else {
-		listCmd = exec.Command(packageManager, "list", "--installed")
-	}
-	// OPTIMIZATION: Set minimal environment to reduce overhead
-	listCmd.Env = append(os.Environ(), "LANG=C")
-	installedOutput, err := listCmd.Output()
-	var installedPackages map[string]models.Package
-	if err != nil {
-		m.logger.WithError(err).Warn("Failed to get installed packages")
-		installedPackages = make(map[string]models.Package)
-	} else {
-		m.logger.WithField("outputSize", len(installedOutput)).Debug("Received output from list installed command")
-		m.logger.Debug("Parsing installed packages...")
-		installedPackages = m.parseInstalledPackages(string(installedOutput))
-		m.logger.WithField("count", len(installedPackages)).Info("Found installed packages")
-
-		if len(installedPackages) == 0 {
-			m.logger.Warn("No installed packages found - this may indicate a parsing issue")
-		}
+	installedPackages := m.getInstalledPackages(packageManager)
+	m.logger.WithField("count", len(installedPackages)).Info("Found installed packages")
+	if len(installedPackages) == 0 {
+		m.logger.Warn("No installed packages found - this may indicate a parsing issue")
 	}
 
 	// Get security updates first to identify which packages are security updates
@@ -83,8 +66,7 @@ func (m *DNFManager) GetPackages() []models.Package {
 
 	// Get upgradable packages
 	m.logger.Debug("Getting upgradable packages...")
-	checkCmd := exec.Command(packageManager, "check-update")
-	checkOutput, _ := checkCmd.Output() // This command returns exit code 100 when updates are available
+	checkOutput, _ := m.runner.Output(context.Background(), packageManager, "check-update") // This command returns exit code 100 when updates are available
 
 	var upgradablePackages []models.Package
 	if len(checkOutput) > 0 {
@@ -125,21 +107,21 @@ func (m *DNFManager) enrichWithRepoAttribution(packages []models.Package) {
 
 	packageManager := m.detectPackageManager()
 
-	var cmd *exec.Cmd
+	var name string
+	var args []string
 	if packageManager == "dnf" {
-		cmd = exec.Command("dnf", "repoquery", "--installed", "--cacheonly", "--qf", "%{name}\t%{from_repo}")
+		name, args = "dnf", []string{"repoquery", "--installed", "--cacheonly", "--qf", "%{name}\t%{from_repo}"}
 	} else {
 		// yum: try repoquery from yum-utils
-		if _, err := exec.LookPath("repoquery"); err == nil {
-			cmd = exec.Command("repoquery", "--installed", "--qf", "%{name}\t%{ui_from_repo}")
+		if _, err := m.runner.LookPath("repoquery"); err == nil {
+			name, args = "repoquery", []string{"--installed", "--qf", "%{name}\t%{ui_from_repo}"}
 		} else {
 			// Try yum repoquery (available on some systems)
-			cmd = exec.Command("yum", "repoquery", "--installed", "--qf", "%{name}\t%{ui_from_repo}")
+			name, args = "yum", []string{"repoquery", "--installed", "--qf", "%{name}\t%{ui_from_repo}"}
 		}
 	}
-	cmd.Env = append(os.Environ(), "LANG=C")
 
-	output, err := cmd.Output()
+	output, err := m.runner.Output(context.Background(), name, args...)
 	if err != nil {
 		m.logger.WithError(err).Warn("repoquery failed, skipping repo attribution")
 		return
@@ -197,12 +179,10 @@ func (m *DNFManager) getSecurityPackages(packageManager string) map[string]bool
 	securityPackages := make(map[string]bool)
 
 	// Try dnf updateinfo list security (works for dnf)
-	updateInfoCmd := exec.Command(packageManager, "updateinfo", "list", "security")
-	updateInfoOutput, err := updateInfoCmd.Output()
+	updateInfoOutput, err := m.runner.Output(context.Background(), packageManager, "updateinfo", "list", "security")
 	if err != nil {
 		// Fall back to "sec" if "security" doesn't work
-		updateInfoCmd = exec.Command(packageManager, "updateinfo", "list", "sec")
-		updateInfoOutput, err = updateInfoCmd.Output()
+		updateInfoOutput, err = m.runner.Output(context.Background(), packageManager, "updateinfo", "list", "sec")
 	}
 
 	if err != nil {
@@ -229,11 +209,12 @@ func (m *DNFManager) getSecurityPackages(packageManager string) map[string]bool
 		}
 
 		// Skip lines that don't start with advisory IDs
-		// Common advisory ID prefixes: RHSA (Red Hat), ALSA (AlmaLinux), ELSA (Oracle/Enterprise Linux), CESA (CentOS)
+		// Common advisory ID prefixes: RHSA (Red Hat), ALSA (AlmaLinux), RLSA (Rocky), ELSA (Oracle/Enterprise Linux), CESA (CentOS)
 		// This filters out header lines like "expiration"
 		advisoryID := fields[0]
 		isAdvisory := strings.HasPrefix(advisoryID, "RHSA") ||
 			strings.HasPrefix(advisoryID, "ALSA") ||
+			strings.HasPrefix(advisoryID, "RLSA") ||
 			strings.HasPrefix(advisoryID, "ELSA") ||
 			strings.HasPrefix(advisoryID, "CESA")
 
@@ -259,21 +240,27 @@ func (m *DNFManager) getSecurityPackages(packageManager string) map[string]bool
 	return securityPackages
 }
 
+// rpmArches are the architecture suffixes of name.arch package strings
+var rpmArches = map[string]bool{
+	"x86_64": true, "i686": true, "i386": true, "noarch": true, "aarch64": true,
+	"arm64": true, "ppc64le": true, "s390x": true, "armv7hl": true,
+}
+
+// stripRPMArch removes a known architecture suffix, "bash.x86_64" -> "bash"
+func stripRPMArch(name string) string {
+	if idx := strings.LastIndex(name, "."); idx > 0 && rpmArches[name[idx+1:]] {
+		return name[:idx]
+	}
+	return name
+}
+
 // extractBasePackageName extracts the base package name from a package string
 // Handles formats like:
 // - package-name-version-release.arch (from updateinfo)
 // - package-name.arch (from check-update)
 func (m *DNFManager) extractBasePackageName(packageString string) string {
 	// Remove architecture suffix first (e.g., .x86_64, .noarch)
-	baseName := packageString
-	if idx := strings.LastIndex(packageString, "."); idx > 0 {
-		archSuffix := packageString[idx+1:]
-		// Check if it's a known architecture
-		if archSuffix == "x86_64" || archSuffix == "i686" || archSuffix == "i386" ||
-			archSuffix == "noarch" || archSuffix == "aarch64" || archSuffix == "arm64" {
-			baseName = packageString[:idx]
-		}
-	}
+	baseName := stripRPMArch(packageString)
 
 	// If the base name contains a version pattern (starts with a digit after a dash),
 	// extract just the package name part
@@ -328,15 +315,10 @@ func (m *DNFManager) parseUpgradablePackages(output string, packageManager strin
 		// or if packageName is "package.x86_64" but installed has "package"
 		if currentVersion == "" {
 			// Try to find by removing architecture suffix from packageName (if present)
-			basePackageName := packageName
-			if idx := strings.LastIndex(packageName, "."); idx > 0 {
-				archSuffix := packageName[idx+1:]
-				if archSuffix == "x86_64" || archSuffix == "i686" || archSuffix == "i386" ||
-					archSuffix == "noarch" || archSuffix == "aarch64" || archSuffix == "arm64" {
-					basePackageName = packageName[:idx]
-					if p, ok := installedPackages[basePackageName]; ok {
-						currentVersion = p.CurrentVersion
-					}
+			basePackageName := stripRPMArch(packageName)
+			if basePackageName != packageName {
+				if p, ok := installedPackages[basePackageName]; ok {
+					currentVersion = p.CurrentVersion
 				}
 			}
 
@@ -344,15 +326,7 @@ func (m *DNFManager) parseUpgradablePackages(output string, packageManager strin
 			if currentVersion == "" {
 				for installedName, p := range installedPackages {
 					// Remove architecture suffix if present (e.g., .x86_64, .noarch, .i686)
-					baseName := installedName
-					if idx := strings.LastIndex(installedName, "."); idx > 0 {
-						// Check if the part after the last dot looks like an architecture
-						archSuffix := installedName[idx+1:]
-						if archSuffix == "x86_64" || archSuffix == "i686" || archSuffix == "i386" ||
-							archSuffix == "noarch" || archSuffix == "aarch64" || archSuffix == "arm64" {
-							baseName = installedName[:idx]
-						}
-					}
+					baseName := stripRPMArch(installedName)
 
 					// Compare base names (handles both cases: package vs package.x86_64)
 					if baseName == basePackageName || baseName == packageName {
@@ -366,13 +340,11 @@ func (m *DNFManager) parseUpgradablePackages(output string, packageManager strin
 		// If still not found in installed packages, try to get it with a command as fallback
 		if currentVersion == "" {
 			// yum (CentOS 7 / legacy) requires positional argument; dnf accepts --installed flag
-			var getCurrentCmd *exec.Cmd
+			listArgs := []string{"list", "--installed", packageName}
 			if packageManager == "yum" {
-				getCurrentCmd = exec.Command(packageManager, "list", "installed", packageName)
-			} else {
-				getCurrentCmd = exec.Command(packageManager, "list", "--installed", packageName)
+				listArgs = []string{"list", "installed", packageName}
 			}
-			getCurrentOutput, err := getCurrentCmd.Output()
+			getCurrentOutput, err := m.runner.Output(context.Background(), packageManager, listArgs...)
 			if err == nil {
 				for _, currentLine := range strings.Split(string(getCurrentOutput), "\n") {
 					if strings.Contains(currentLine, packageName) && !strings.Contains(currentLine, "Installed") && !strings.Contains(currentLine, "Available") {
@@ -412,6 +384,60 @@ func (m *DNFManager) parseUpgradablePackages(output string, packageManager strin
 	return packages
 }
 
+// rpmQueryFormat prints one installed package per line, with the epoch only
+// when it is set, matching the versions dnf list and check-update print
+const rpmQueryFormat = "%{NAME}\t%|EPOCH?{%{EPOCH}:}:{}|%{VERSION}-%{RELEASE}\n"
+
+// getInstalledPackages lists installed packages with rpm's query format,
+// which has no headers or column wrapping to get wrong, and falls back to
+// dnf/yum list where rpm is unavailable
+func (m *DNFManager) getInstalledPackages(packageManager string) map[string]models.Package {
+	if output, err := m.runner.Output(context.Background(), "rpm", "-qa", "--qf", rpmQueryFormat); err == nil {
+		if installed := m.parseRPMQuery(string(output)); len(installed) > 0 {
+			return installed
+		}
+	} else {
+		m.logger.WithError(err).Debug("rpm query failed, falling back to list installed")
+	}
+
+	// Note: yum (CentOS 7 / legacy) uses positional argument syntax: "yum list installed"
+	// while dnf uses flag syntax: "dnf list --installed"
+	listArgs := []string{"list", "--installed"}
+	if packageManager == "yum" {
+		listArgs = []string{"list", "installed"}
+	}
+	installedOutput, err := m.runner.Output(context.Background(), packageManager, listArgs...)
+	if err != nil {
+		m.logger.WithError(err).Warn("Failed to get installed packages")
+		return make(map[string]models.Package)
+	}
+	m.logger.WithField("outputSize", len(installedOutput)).Debug("Received output from list installed command")
+	return m.parseInstalledPackages(string(installedOutput))
+}
+
+// parseRPMQuery parses rpm -qa output in rpmQueryFormat
+func (m *DNFManager) parseRPMQuery(output string) map[string]models.Package {
+	installedPackages := make(map[string]models.Package)
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		name, version, ok := strings.Cut(scanner.Text(), "\t")
+		version = strings.TrimSpace(version)
+		// gpg-pubkey entries are imported signing keys, not packages
+		if !ok || name == "" || version == "" || name == "gpg-pubkey" ||
+			strings.ContainsFunc(name, unicode.IsSpace) || strings.ContainsFunc(version, unicode.IsSpace) {
+			continue
+		}
+		installedPackages[name] = models.Package{
+			Name:           name,
+			CurrentVersion: version,
+			NeedsUpdate:    false,
+		}
+	}
+
+	return installedPackages
+}
+
 // parseInstalledPackages parses dnf/yum list installed output.
 // On CentOS 7 / legacy yum, long package names are wrapped: the name appears alone
 // on one line and the version + repo follow on the next indented line. We handle
@@ -435,7 +461,8 @@ func (m *DNFManager) parseInstalledPackages(output string) map[string]models.Pac
 
 		// Normal single-line format: "name.arch  version  repo"
 		if len(parts) >= 3 {
-			packageName := strings.Split(parts[0], ".")[0] // strip arch suffix
+			// Only the arch is stripped; names like python3.11 have dots of their own
+			packageName := stripRPMArch(parts[0])
 			version := parts[1]
 			installedPackages[packageName] = models.Package{
 				Name:           packageName,
@@ -451,7 +478,7 @@ func (m *DNFManager) parseInstalledPackages(output string) map[string]models.Pac
 		// and the trimmed text has no spaces (single token).
 		if len(parts) == 1 && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
 			// Looks like a bare package name line - remember it
-			pendingName = strings.Split(parts[0], ".")[0]
+			pendingName = stripRPMArch(parts[0])
 			continue
 		}
 
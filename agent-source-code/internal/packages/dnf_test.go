@@ -1,7 +1,7 @@
 package packages
 
 import (
-	"patchmon-agent/pkg/models"
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 	"testing"
 
 	"github.com/sirupsen/logrus"
@@ -152,3 +152,21 @@ func TestDNFManager_extractBasePackageName(t *testing.T) {
 		})
 	}
 }
+
+func TestDNFManager_parseRPMQuery(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	manager := NewDNFManager(logger)
+
+	input := "vim-enhanced\t2:8.2.2637-20.el9_1\n" +
+		"python3.11\t3.11.9-7.el9\n" +
+		"gpg-pubkey\t5a6340b3-6229229e\n" +
+		"\t1.0-1\n" +
+		"broken\n"
+
+	expected := map[string]models.Package{
+		"vim-enhanced": {Name: "vim-enhanced", CurrentVersion: "2:8.2.2637-20.el9_1"},
+		"python3.11":   {Name: "python3.11", CurrentVersion: "3.11.9-7.el9"},
+	}
+	assert.Equal(t, expected, manager.parseRPMQuery(input))
+}
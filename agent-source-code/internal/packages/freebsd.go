@@ -2,12 +2,14 @@ package packages
 
 import (
 	"bufio"
+	"context"
+	"fmt"
 	"os"
-	"os/exec"
 	"regexp"
 	"strings"
 
-	"patchmon-agent/pkg/models"
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner"
 
 	"github.com/sirupsen/logrus"
 )
@@ -15,15 +17,32 @@ import (
 // FreeBSDManager handles FreeBSD package information collection
 type FreeBSDManager struct {
 	logger *logrus.Logger
+	runner cmdrunner.Runner
+	jail   string // JID or name passed to pkg -j; empty for the host
 }
 
 // NewFreeBSDManager creates a new FreeBSD package manager
 func NewFreeBSDManager(logger *logrus.Logger) *FreeBSDManager {
 	return &FreeBSDManager{
 		logger: logger,
+		runner: cmdrunner.Default,
 	}
 }
 
+// WithRunner runs pkg and freebsd-update through r instead of executing them
+func (m *FreeBSDManager) WithRunner(r cmdrunner.Runner) *FreeBSDManager {
+	m.runner = r
+	return m
+}
+
+// InJail returns a copy of the manager that collects the packages installed
+// in the given jail (JID or name) with pkg -j
+func (m *FreeBSDManager) InJail(jail string) *FreeBSDManager {
+	jailed := *m
+	jailed.jail = jail
+	return &jailed
+}
+
 // GetPackages gets package information for FreeBSD systems
 // Collects from: pkg (binary packages), freebsd-update (base system), and pkg audit (security)
 func (m *FreeBSDManager) GetPackages() ([]models.Package, error) {
@@ -32,15 +51,21 @@ func (m *FreeBSDManager) GetPackages() ([]models.Package, error) {
 	// 1. Get pkg binary packages (primary)
 	pkgPackages, err := m.getPkgPackages()
 	if err != nil {
+		// A jail has nothing to report but its pkg packages
+		if m.jail != "" {
+			return nil, err
+		}
 		m.logger.WithError(err).Warn("Failed to get pkg packages")
 	} else {
 		allPackages = append(allPackages, pkgPackages...)
 	}
 
-	// 2. Get freebsd-update base system updates
-	basePackage := m.getFreeBSDUpdates()
-	if basePackage != nil {
-		allPackages = append(allPackages, *basePackage)
+	// 2. Get freebsd-update base system updates. A jail's base system is
+	// updated from the host (freebsd-update -b), so it is not checked here.
+	if m.jail == "" {
+		if basePackage := m.getFreeBSDUpdates(); basePackage != nil {
+			allPackages = append(allPackages, *basePackage)
+		}
 	}
 
 	// 3. Get security audit information and mark vulnerable packages
@@ -51,7 +76,7 @@ func (m *FreeBSDManager) GetPackages() ([]models.Package, error) {
 
 // getPkgPath returns the path to the pkg binary (works when PATH is minimal, e.g. under rc.d)
 func (m *FreeBSDManager) getPkgPath() string {
-	if path, err := exec.LookPath("pkg"); err == nil {
+	if path, err := m.runner.LookPath("pkg"); err == nil {
 		return path
 	}
 	for _, p := range []string{"/usr/sbin/pkg", "/usr/local/sbin/pkg"} {
@@ -62,14 +87,19 @@ func (m *FreeBSDManager) getPkgPath() string {
 	return "pkg"
 }
 
+// pkg runs a pkg subcommand on the host or, for a jailed manager, inside the jail
+func (m *FreeBSDManager) pkg(args ...string) ([]byte, error) {
+	if m.jail != "" {
+		args = append([]string{"-j", m.jail}, args...)
+	}
+	return m.runner.Output(context.Background(), m.getPkgPath(), args...)
+}
+
 // getPkgPackages gets installed and upgradable packages from pkg
 func (m *FreeBSDManager) getPkgPackages() ([]models.Package, error) {
-	pkgPath := m.getPkgPath()
-
 	// Get installed packages with repo info: pkg query -a '%n\t%v\t%R'
 	m.logger.Debug("Getting installed packages with pkg query...")
-	queryCmd := exec.Command(pkgPath, "query", "-a", "%n\t%v\t%R")
-	queryOutput, err := queryCmd.Output()
+	queryOutput, err := m.pkg("query", "-a", "%n\t%v\t%R")
 
 	installedPackages := make(map[string]string)
 	repoByName := make(map[string]string)
@@ -77,13 +107,11 @@ func (m *FreeBSDManager) getPkgPackages() ([]models.Package, error) {
 	if err != nil {
 		m.logger.WithError(err).Warn("Failed to get installed packages via pkg query, falling back to pkg info")
 		// Fallback to pkg info
-		infoCmd := exec.Command(pkgPath, "info")
-		infoOutput, infoErr := infoCmd.Output()
+		infoOutput, infoErr := m.pkg("info")
 		if infoErr != nil {
-			m.logger.WithError(infoErr).Warn("Failed to get installed packages")
-		} else {
-			installedPackages = m.parseInstalledPackagesLegacy(string(infoOutput))
+			return nil, fmt.Errorf("failed to get installed packages: %w", infoErr)
 		}
+		installedPackages = m.parseInstalledPackagesLegacy(string(infoOutput))
 	} else {
 		installedPackages, repoByName = m.parsePkgQuery(string(queryOutput))
 		m.logger.WithField("count", len(installedPackages)).Debug("Found installed packages")
@@ -91,14 +119,13 @@ func (m *FreeBSDManager) getPkgPackages() ([]models.Package, error) {
 
 	// Get upgradable packages: pkg upgrade -n
 	m.logger.Debug("Checking for package upgrades...")
-	upgradeCmd := exec.Command(pkgPath, "upgrade", "-n")
-	upgradeOutput, err := upgradeCmd.Output()
+	upgradeOutput, err := m.pkg("upgrade", "-n")
 
 	var upgradablePackages []models.Package
 	if err != nil {
 		// Exit code 1 can mean no upgrades available or error, check output
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			m.logger.WithField("exit_code", exitErr.ExitCode()).Debug("pkg upgrade -n returned non-zero")
+		if code, ok := cmdrunner.ExitCode(err); ok {
+			m.logger.WithField("exit_code", code).Debug("pkg upgrade -n returned non-zero")
 			// Try to parse output anyway in case there's useful info
 			if len(upgradeOutput) > 0 {
 				upgradablePackages = m.parseUpgradeOutput(string(upgradeOutput), installedPackages)
@@ -260,23 +287,20 @@ func (m *FreeBSDManager) parseUpgradeOutput(output string, _ map[string]string)
 
 // markSecurityVulnerabilities uses pkg audit to mark packages with known vulnerabilities
 func (m *FreeBSDManager) markSecurityVulnerabilities(packages []models.Package) {
-	pkgPath := m.getPkgPath()
 	// Run pkg audit (fetch vulnerability database if needed)
 	m.logger.Debug("Running pkg audit to check for vulnerabilities...")
 
 	// First update the vulnerability database
-	fetchCmd := exec.Command(pkgPath, "audit", "-F")
-	if err := fetchCmd.Run(); err != nil {
+	if _, err := m.pkg("audit", "-F"); err != nil {
 		m.logger.WithError(err).Debug("Failed to fetch vulnerability database (may require root)")
 	}
 
-	// Run the audit
-	auditCmd := exec.Command(pkgPath, "audit")
-	auditOutput, err := auditCmd.CombinedOutput()
+	// Run the audit; -q prints just the vulnerable packages' name-version
+	auditOutput, err := m.pkg("audit", "-q")
 
 	if err != nil {
 		// pkg audit returns non-zero if vulnerabilities found, which is expected
-		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		if code, ok := cmdrunner.ExitCode(err); ok && code == 1 {
 			// Exit code 1 means vulnerabilities were found, this is normal
 			m.logger.Debug("pkg audit found vulnerabilities")
 		} else {
@@ -303,8 +327,9 @@ func (m *FreeBSDManager) markSecurityVulnerabilities(packages []models.Package)
 	m.logger.WithField("vulnerable_count", len(vulnerablePackages)).Debug("Identified vulnerable packages")
 }
 
-// parseAuditOutput parses pkg audit output to get list of vulnerable packages
-// Example output:
+// parseAuditOutput parses pkg audit output to get list of vulnerable packages.
+// pkg audit -q prints one name-version per line; the full report, which older
+// pkg versions print regardless, looks like:
 // curl-8.9.1 is vulnerable:
 //
 //	curl -- multiple vulnerabilities
@@ -323,6 +348,12 @@ func (m *FreeBSDManager) parseAuditOutput(output string) map[string]bool {
 		if len(matches) >= 2 {
 			packageName := matches[1]
 			vulnerablePackages[packageName] = true
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) == 1 && line == fields[0] {
+			if packageName, version := m.extractPackageNameAndVersion(fields[0]); packageName != "" && version != "" {
+				vulnerablePackages[packageName] = true
+			}
 		}
 	}
 
@@ -336,13 +367,12 @@ func (m *FreeBSDManager) getFreeBSDUpdates() *models.Package {
 
 	// Run freebsd-update fetch (requires root, will fail gracefully otherwise)
 	// We use fetch with --not-running-from-cron to avoid emails
-	cmd := exec.Command("freebsd-update", "fetch", "--not-running-from-cron")
-	output, err := cmd.CombinedOutput()
+	output, err := m.runner.Output(context.Background(), "freebsd-update", "fetch", "--not-running-from-cron")
 
 	if err != nil {
 		// freebsd-update requires root privileges
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			m.logger.WithField("exit_code", exitErr.ExitCode()).Debug("freebsd-update failed (may require root)")
+		if code, ok := cmdrunner.ExitCode(err); ok {
+			m.logger.WithField("exit_code", code).Debug("freebsd-update failed (may require root)")
 		}
 		return nil
 	}
@@ -363,8 +393,7 @@ func (m *FreeBSDManager) getFreeBSDUpdates() *models.Package {
 		m.logger.Debug("FreeBSD base system updates available")
 
 		// Get current FreeBSD version
-		versionCmd := exec.Command("freebsd-version")
-		versionOutput, err := versionCmd.Output()
+		versionOutput, err := m.runner.Output(context.Background(), "freebsd-version")
 		currentVersion := "Unknown"
 		if err == nil {
 			currentVersion = strings.TrimSpace(string(versionOutput))
@@ -162,6 +162,22 @@ openssl-3.0.14,1 is vulnerable:
 	}
 }
 
+func TestParseAuditOutputQuiet(t *testing.T) {
+	logger := logrus.New()
+	manager := NewFreeBSDManager(logger)
+
+	result := manager.parseAuditOutput("curl-8.9.1\nopenssl-3.0.14,1\npy311-urllib3-1.26.18,1\n")
+
+	for _, pkg := range []string{"curl", "openssl", "py311-urllib3"} {
+		if !result[pkg] {
+			t.Errorf("Expected %s to be vulnerable", pkg)
+		}
+	}
+	if len(result) != 3 {
+		t.Errorf("Expected 3 vulnerable packages, got %d", len(result))
+	}
+}
+
 func TestParseUpgradeOutputEmpty(t *testing.T) {
 	logger := logrus.New()
 	manager := NewFreeBSDManager(logger)
@@ -0,0 +1,280 @@
+package packages
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The fuzz targets feed package manager output through the parsers. Besides
+// not panicking, a parser must never report a package without a name or
+// version, or a name with whitespace in it; the server rejects those.
+// Run one with e.g. go test -fuzz=FuzzAPTUpgrade ./internal/packages
+
+func fuzzLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func checkFuzzedPackage(t *testing.T, pkg models.Package) {
+	t.Helper()
+	if pkg.Name == "" || strings.ContainsFunc(pkg.Name, isSpace) {
+		t.Fatalf("bad package name %q", pkg.Name)
+	}
+	if pkg.CurrentVersion == "" || strings.ContainsFunc(pkg.CurrentVersion, isSpace) {
+		t.Fatalf("%s: bad current version %q", pkg.Name, pkg.CurrentVersion)
+	}
+	if pkg.NeedsUpdate && (pkg.AvailableVersion == "" || strings.ContainsFunc(pkg.AvailableVersion, isSpace)) {
+		t.Fatalf("%s: bad available version %q", pkg.Name, pkg.AvailableVersion)
+	}
+}
+
+// isSpace matches \s in the parsers' regular expressions
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f'
+}
+
+func FuzzAPTInstalled(f *testing.F) {
+	f.Add("vim 2:8.2.3995-1ubuntu2.17 Vi IMproved - enhanced vi editor\n")
+	f.Add("libc6 2.35-0ubuntu3.8 GNU C Library\n shared libraries\nbash 5.1-6ubuntu1.1 GNU Bourne Again SHell\n")
+	f.Add("broken\n\n  \n")
+	m := NewAPTManager(fuzzLogger(), CacheRefreshConfig{Mode: "never"})
+	f.Fuzz(func(t *testing.T, output string) {
+		for _, pkg := range m.parseInstalledPackages(output) {
+			checkFuzzedPackage(t, pkg)
+		}
+	})
+}
+
+func FuzzAPTUpgrade(f *testing.F) {
+	f.Add("Inst libssl3 [3.0.2-0ubuntu1.15] (3.0.2-0ubuntu1.16 Ubuntu:22.04/jammy-security [amd64])\n")
+	f.Add("Inst tzdata [2024a-0ubuntu0.22.04] (2024a-0ubuntu0.22.04.1 Ubuntu:22.04/jammy-updates [all])\nConf tzdata (2024a)\n")
+	f.Add("Inst foo [1.0 beta] (2.0 Debian:12/stable [amd64])\n")
+	f.Add("Inst [ (\n")
+	m := NewAPTManager(fuzzLogger(), CacheRefreshConfig{Mode: "never"})
+	f.Fuzz(func(t *testing.T, output string) {
+		for _, pkg := range m.parseAPTUpgrade(output) {
+			if pkg.Name == "" || pkg.AvailableVersion == "" {
+				t.Fatalf("incomplete upgrade %+v", pkg)
+			}
+		}
+	})
+}
+
+func FuzzAPTCachePolicy(f *testing.F) {
+	f.Add(`curl:
+  Installed: 7.81.0-1ubuntu1.15
+  Candidate: 7.81.0-1ubuntu1.16
+  Version table:
+     7.81.0-1ubuntu1.16 500
+        500 http://archive.ubuntu.com/ubuntu jammy-updates/main amd64 Packages
+ *** 7.81.0-1ubuntu1.15 100
+        100 /var/lib/dpkg/status
+`)
+	f.Add("x:\n  Version table:\n        500\n")
+	m := NewAPTManager(fuzzLogger(), CacheRefreshConfig{Mode: "never"})
+	f.Fuzz(func(t *testing.T, output string) {
+		repos := make(map[string]string)
+		m.parseAptCachePolicy(output, repos)
+		for name, repo := range repos {
+			if name == "" || repo == "" {
+				t.Fatalf("bad repo mapping %q -> %q", name, repo)
+			}
+		}
+	})
+}
+
+func FuzzAPKInstalled(f *testing.F) {
+	f.Add("alpine-base-3.22.2-r0 x86_64 {alpine-base} (MIT) [installed]\nzzz-doc-0.2.0-r0 x86_64 {zzz} (MIT) [installed]\n")
+	f.Add("-1 [installed]\nfoo- [installed]\n")
+	m := NewAPKManager(fuzzLogger(), CacheRefreshConfig{Mode: "never"})
+	f.Fuzz(func(t *testing.T, output string) {
+		for _, pkg := range m.parseInstalledPackages(output) {
+			checkFuzzedPackage(t, pkg)
+		}
+	})
+}
+
+func FuzzAPKUpgradable(f *testing.F) {
+	f.Add("alpine-conf-3.20.0-r1 x86_64 {alpine-conf} (MIT) [upgradable from: alpine-conf-3.20.0-r0]\n")
+	f.Add("a-1 x [upgradable from: ]\n")
+	m := NewAPKManager(fuzzLogger(), CacheRefreshConfig{Mode: "never"})
+	f.Fuzz(func(t *testing.T, output string) {
+		for _, pkg := range m.parseUpgradablePackages(output, nil) {
+			checkFuzzedPackage(t, pkg)
+		}
+	})
+}
+
+func FuzzAPKPolicy(f *testing.F) {
+	f.Add(`busybox policy:
+  1.36.1-r29:
+    lib/apk/db/installed
+    https://dl-cdn.alpinelinux.org/alpine/v3.22/main/x86_64/APKINDEX.tar.gz
+`)
+	f.Add("x policy:\n/x86_64\n")
+	m := NewAPKManager(fuzzLogger(), CacheRefreshConfig{Mode: "never"})
+	f.Fuzz(func(t *testing.T, output string) {
+		repos := make(map[string]string)
+		m.parseApkPolicy(output, repos)
+		for name, repo := range repos {
+			if repo == "" {
+				t.Fatalf("empty repo for %q", name)
+			}
+		}
+	})
+}
+
+func FuzzDNFInstalled(f *testing.F) {
+	f.Add(`Installed Packages
+bash.x86_64                    5.1.8-9.el9                   @baseos
+python3-very-long-package-name-that-wraps.noarch
+                               1.2.3-1.el9                   @appstream
+`)
+	f.Add(".x86_64 1 2\n")
+	m := NewDNFManager(fuzzLogger())
+	f.Fuzz(func(t *testing.T, output string) {
+		for _, pkg := range m.parseInstalledPackages(output) {
+			checkFuzzedPackage(t, pkg)
+		}
+	})
+}
+
+func FuzzRPMQuery(f *testing.F) {
+	f.Add("vim-enhanced\t2:8.2.2637-20.el9_1\ngpg-pubkey\t5a6340b3-6229229e\n")
+	f.Add("\t\n \t \n")
+	m := NewDNFManager(fuzzLogger())
+	f.Fuzz(func(t *testing.T, output string) {
+		for _, pkg := range m.parseRPMQuery(output) {
+			checkFuzzedPackage(t, pkg)
+		}
+	})
+}
+
+func FuzzDNFUpgradable(f *testing.F) {
+	f.Add("Last metadata expiration check: 0:12:03 ago.\n\nbash.x86_64  5.1.8-10.el9  baseos\nkernel.x86_64  5.14.0-500.el9  baseos\n")
+	f.Add("Obsoleting Packages\ngrub2-tools.x86_64  1:2.06-80.el9  baseos\n    grub2-tools.x86_64  1:2.06-70.el9  @baseos\n")
+	m := NewDNFManager(fuzzLogger())
+	installed := map[string]models.Package{
+		"bash":   {Name: "bash", CurrentVersion: "5.1.8-9.el9"},
+		"kernel": {Name: "kernel", CurrentVersion: "5.14.0-427.el9"},
+	}
+	f.Fuzz(func(t *testing.T, output string) {
+		// Lines naming other packages would ask the package manager; a
+		// missing one keeps the target from running anything
+		for _, pkg := range m.parseUpgradablePackages(output, "patchmon-fuzz-missing", installed, map[string]bool{"bash": true}) {
+			checkFuzzedPackage(t, pkg)
+		}
+	})
+}
+
+func FuzzDNFBasePackageName(f *testing.F) {
+	f.Add("glibc-common-2.34-168.el9_6.19.x86_64")
+	f.Add("kernel.noarch")
+	f.Add("-1.x86_64")
+	m := NewDNFManager(fuzzLogger())
+	f.Fuzz(func(t *testing.T, s string) {
+		if name := m.extractBasePackageName(s); len(name) > len(s) {
+			t.Fatalf("base name %q longer than %q", name, s)
+		}
+	})
+}
+
+func FuzzFreeBSDPkgQuery(f *testing.F) {
+	f.Add("curl\t8.9.1\tFreeBSD\nbash\t5.2.26\tunknown-repository\n")
+	f.Add("\t\t\n")
+	m := NewFreeBSDManager(fuzzLogger())
+	f.Fuzz(func(t *testing.T, output string) {
+		installed, repos := m.parsePkgQuery(output)
+		for name, version := range installed {
+			if name == "" || version == "" || repos[name] == "" {
+				t.Fatalf("bad package %q %q in %q", name, version, repos[name])
+			}
+		}
+	})
+}
+
+func FuzzFreeBSDInstalledLegacy(f *testing.F) {
+	f.Add("bash-5.3.9                     GNU Project's Bourne Again SHell\nlibX11-1.8.12,1                X11 library\n")
+	f.Add("-1\nx-\n")
+	m := NewFreeBSDManager(fuzzLogger())
+	f.Fuzz(func(t *testing.T, output string) {
+		for name, version := range m.parseInstalledPackagesLegacy(output) {
+			if name == "" || version == "" {
+				t.Fatalf("bad package %q %q", name, version)
+			}
+		}
+	})
+}
+
+func FuzzFreeBSDUpgrade(f *testing.F) {
+	f.Add(`The following 2 package(s) will be affected (of 0 checked):
+
+Installed packages to be UPGRADED:
+	curl: 8.9.1 -> 8.10.0
+	git: 2.46.0 -> 2.46.1
+
+Number of packages to be upgraded: 2
+`)
+	m := NewFreeBSDManager(fuzzLogger())
+	f.Fuzz(func(t *testing.T, output string) {
+		for _, pkg := range m.parseUpgradeOutput(output, nil) {
+			checkFuzzedPackage(t, pkg)
+		}
+	})
+}
+
+func FuzzFreeBSDAudit(f *testing.F) {
+	f.Add("curl-8.9.1 is vulnerable:\n  curl -- multiple vulnerabilities\n  CVE: CVE-2024-6197\n\n1 problem(s) in 1 installed package(s) found.\n")
+	f.Add("py311-urllib3-1.26.18,1 is vulnerable:\n")
+	m := NewFreeBSDManager(fuzzLogger())
+	f.Fuzz(func(t *testing.T, output string) {
+		for name := range m.parseAuditOutput(output) {
+			if name == "" {
+				t.Fatal("empty vulnerable package name")
+			}
+		}
+	})
+}
+
+func FuzzPacmanCheckUpdate(f *testing.F) {
+	f.Add("linux 6.9.7.arch1-1 -> 6.9.8.arch1-1\nopenssl 3.3.0-1 -> 3.3.1-1\n")
+	m := NewPacmanManager(fuzzLogger())
+	f.Fuzz(func(t *testing.T, output string) {
+		for _, pkg := range m.parseCheckUpdate(output) {
+			checkFuzzedPackage(t, pkg)
+		}
+	})
+}
+
+func FuzzWingetTable(f *testing.F) {
+	f.Add("Name               Id                       Version      Available    Source\n---------------------------------------------------------------------------\nGit                Git.Git                  2.44.0       2.45.1       winget\nPowerShell 7       Microsoft.PowerShell     7.4.1.0                   winget\n")
+	f.Add("Name Id Version\n\u2588\u2592\u2591\nx\n")
+	m := NewWindowsManager(fuzzLogger())
+	f.Fuzz(func(t *testing.T, output string) {
+		m.parseWingetTable(output)
+	})
+}
+
+func FuzzProxyConfigs(f *testing.F) {
+	f.Add("HTTPS_PROXY='http://proxy.example.com:3128'\n")
+	f.Add("[main]\nproxy=http://proxy:3128\nproxy_username=u\nproxy_password=p\n")
+	f.Add("PROXY_ENABLED=\"yes\"\nHTTP_PROXY=\"proxy:8080\"\n")
+	f.Fuzz(func(t *testing.T, input string) {
+		for _, u := range []*url.URL{
+			parseAptConfigShell(input),
+			parseRepoConfProxy(strings.NewReader(input)),
+			parseSysconfigProxy(strings.NewReader(input)),
+		} {
+			if u != nil && u.String() == "" {
+				t.Fatal("empty proxy URL")
+			}
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package packages
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"time"
+
+	"patchmon-agent/internal/utils"
+)
+
+const (
+	// countHistorySize is how many recent package counts are kept
+	countHistorySize = 10
+	// countHistoryMinSamples is the minimum history needed before drops are judged
+	countHistoryMinSamples = 3
+	// countDropMinBaseline avoids flagging tiny systems where halving is normal noise
+	countDropMinBaseline = 50
+)
+
+// CountSample is one recorded package count
+type CountSample struct {
+	Count     int    `json:"count"`
+	Timestamp string `json:"timestamp"`
+}
+
+// CountHistory tracks recent package counts so an implausible drop (for example
+// a broken dpkg database listing 12 packages instead of 1800) can be flagged
+// before it overwrites good data on the server.
+type CountHistory struct {
+	Samples []CountSample `json:"samples"`
+}
+
+// LoadCountHistory reads the history file. A missing file yields an empty history.
+func LoadCountHistory(path string) (*CountHistory, error) {
+	h := &CountHistory{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return h, fmt.Errorf("failed to read package count history: %w", err)
+	}
+	if err := json.Unmarshal(data, h); err != nil {
+		// A corrupt history file must not block reporting; start over
+		return &CountHistory{}, fmt.Errorf("failed to parse package count history: %w", err)
+	}
+	return h, nil
+}
+
+// Baseline returns the median of the recorded counts, or 0 with too few samples.
+// The median lets a genuine large uninstall become the new normal once it has
+// been seen in the majority of recent reports.
+func (h *CountHistory) Baseline() int {
+	if len(h.Samples) < countHistoryMinSamples {
+		return 0
+	}
+	counts := make([]int, len(h.Samples))
+	for i, s := range h.Samples {
+		counts[i] = s.Count
+	}
+	slices.Sort(counts)
+	return counts[len(counts)/2]
+}
+
+// CheckDrop reports whether current is suspiciously low compared to the
+// baseline (less than half of it). Returns the baseline used.
+func (h *CountHistory) CheckDrop(current int) (bool, int) {
+	baseline := h.Baseline()
+	if baseline < countDropMinBaseline {
+		return false, baseline
+	}
+	return current*2 < baseline, baseline
+}
+
+// Record appends a sample, keeping only the most recent countHistorySize entries
+func (h *CountHistory) Record(count int, at time.Time) {
+	h.Samples = append(h.Samples, CountSample{Count: count, Timestamp: at.UTC().Format(time.RFC3339)})
+	if len(h.Samples) > countHistorySize {
+		h.Samples = h.Samples[len(h.Samples)-countHistorySize:]
+	}
+}
+
+// Save writes the history atomically with owner-only permissions
+func (h *CountHistory) Save(path string) error {
+	return saveState(path, "package count history", h)
+}
+
+// saveState writes v as JSON atomically with owner-only permissions
+func saveState(path, what string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", what, err)
+	}
+	if err := utils.WriteFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to save %s: %w", what, err)
+	}
+	return nil
+}
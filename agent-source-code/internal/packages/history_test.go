@@ -0,0 +1,64 @@
+package packages
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountHistoryCheckDrop(t *testing.T) {
+	h := &CountHistory{}
+	now := time.Now()
+
+	// Not enough history yet
+	suspect, _ := h.CheckDrop(12)
+	assert.False(t, suspect)
+
+	for _, c := range []int{1800, 1805, 1798} {
+		h.Record(c, now)
+	}
+
+	suspect, baseline := h.CheckDrop(12)
+	assert.True(t, suspect)
+	assert.Equal(t, 1800, baseline)
+
+	suspect, _ = h.CheckDrop(1500)
+	assert.False(t, suspect, "moderate drops are normal churn")
+
+	// A drop that persists becomes the new baseline
+	for range 4 {
+		h.Record(900, now)
+	}
+	suspect, _ = h.CheckDrop(900)
+	assert.False(t, suspect)
+}
+
+func TestCountHistorySmallSystems(t *testing.T) {
+	h := &CountHistory{}
+	for _, c := range []int{40, 40, 40} {
+		h.Record(c, time.Now())
+	}
+	suspect, _ := h.CheckDrop(5)
+	assert.False(t, suspect)
+}
+
+func TestCountHistoryPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+
+	h, err := LoadCountHistory(path)
+	require.NoError(t, err)
+	assert.Empty(t, h.Samples)
+
+	for i := range countHistorySize + 5 {
+		h.Record(100+i, time.Now())
+	}
+	require.NoError(t, h.Save(path))
+
+	loaded, err := LoadCountHistory(path)
+	require.NoError(t, err)
+	assert.Len(t, loaded.Samples, countHistorySize)
+	assert.Equal(t, 100+countHistorySize+4, loaded.Samples[countHistorySize-1].Count)
+}
@@ -0,0 +1,149 @@
+package packages
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner/cmdrunnertest"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The host tests replay command output captured on real machines (recorded
+// with PATCHMON_RECORD_COMMANDS) through the full collection path of each
+// package manager.
+
+// collectHost runs package collection against testdata/hosts/<host> and
+// returns the packages by name
+func collectHost(t *testing.T, host, wantManager string) (map[string]models.Package, *cmdrunnertest.Replay) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	replay := cmdrunnertest.Load(t, filepath.Join("testdata", "hosts", host))
+	m := New(logger, CacheRefreshConfig{Mode: "never"}).WithRunner(replay)
+
+	require.Equal(t, wantManager, m.DetectPackageManager())
+	pkgs, err := m.GetPackages()
+	require.NoError(t, err)
+
+	byName := make(map[string]models.Package, len(pkgs))
+	for _, p := range pkgs {
+		require.NotContains(t, byName, p.Name, "package reported twice")
+		byName[p.Name] = p
+	}
+	return byName, replay
+}
+
+func TestHostUbuntu2404(t *testing.T) {
+	pkgs, replay := collectHost(t, "ubuntu-24.04", "apt")
+	assert.Empty(t, replay.Missed())
+	assert.Len(t, pkgs, 12)
+
+	curl := pkgs["curl"]
+	assert.True(t, curl.NeedsUpdate)
+	assert.True(t, curl.IsSecurityUpdate)
+	assert.Equal(t, "8.5.0-2ubuntu10.4", curl.CurrentVersion)
+	assert.Equal(t, "8.5.0-2ubuntu10.5", curl.AvailableVersion)
+	assert.Equal(t, "http://archive.ubuntu.com/ubuntu noble-updates/main", curl.SourceRepository)
+	assert.Equal(t, "1:9.6p1-3ubuntu13.7", pkgs["openssh-server"].AvailableVersion)
+
+	tzdata := pkgs["tzdata"]
+	assert.True(t, tzdata.NeedsUpdate)
+	assert.False(t, tzdata.IsSecurityUpdate)
+
+	snapd := pkgs["snapd"]
+	assert.True(t, snapd.PhasedUpdate, "snapd is held back by phasing")
+	assert.False(t, snapd.NeedsUpdate)
+	assert.Equal(t, "2.65.3+24.04", snapd.AvailableVersion)
+
+	bash := pkgs["bash"]
+	assert.False(t, bash.NeedsUpdate)
+	assert.Equal(t, "5.2.21-2ubuntu4", bash.CurrentVersion)
+	assert.Equal(t, "http://archive.ubuntu.com/ubuntu noble/main", bash.SourceRepository)
+	assert.Contains(t, bash.Description, "GNU Bourne Again SHell\nBash is an sh-compatible")
+	assert.Equal(t, "https://download.docker.com/linux/ubuntu noble/stable", pkgs["docker-ce"].SourceRepository)
+}
+
+func TestHostRocky9(t *testing.T) {
+	pkgs, replay := collectHost(t, "rocky-9", "dnf")
+	assert.Empty(t, replay.Missed())
+
+	upgradable := map[string]models.Package{}
+	for name, p := range pkgs {
+		if p.NeedsUpdate {
+			upgradable[name] = p
+		}
+	}
+	assert.Len(t, upgradable, 6)
+
+	openssl := upgradable["openssl.x86_64"]
+	assert.Equal(t, "1:3.0.7-27.el9", openssl.CurrentVersion)
+	assert.Equal(t, "1:3.0.7-28.el9_4", openssl.AvailableVersion)
+	assert.True(t, openssl.IsSecurityUpdate, "Rocky advisories are RLSA-")
+	assert.True(t, upgradable["libcurl.x86_64"].IsSecurityUpdate)
+	assert.False(t, upgradable["tzdata.noarch"].IsSecurityUpdate)
+	assert.Equal(t, "3.11.7-1.el9_4.1", upgradable["python3.11.x86_64"].CurrentVersion)
+
+	assert.NotContains(t, pkgs, "gpg-pubkey")
+	assert.Equal(t, "2:8.2.2637-20.el9_1", pkgs["vim-enhanced"].CurrentVersion)
+	assert.Equal(t, "appstream", pkgs["vim-enhanced"].SourceRepository)
+}
+
+func TestHostAlpine320(t *testing.T) {
+	pkgs, replay := collectHost(t, "alpine-3.20", "apk")
+	assert.Empty(t, replay.Missed())
+	assert.Len(t, pkgs, 11)
+
+	curl := pkgs["curl"]
+	assert.True(t, curl.NeedsUpdate)
+	assert.Equal(t, "8.9.1-r2", curl.CurrentVersion)
+	assert.Equal(t, "8.11.0-r2", curl.AvailableVersion)
+	assert.Equal(t, "main", curl.SourceRepository)
+	assert.True(t, pkgs["libssl3"].NeedsUpdate)
+
+	assert.False(t, pkgs["busybox"].NeedsUpdate)
+	assert.Equal(t, "1.36.1-r29", pkgs["busybox"].CurrentVersion)
+	assert.Equal(t, "20240705-r0", pkgs["ca-certificates-bundle"].CurrentVersion)
+	assert.Equal(t, "community", pkgs["py3-setuptools"].SourceRepository)
+}
+
+func TestHostArch(t *testing.T) {
+	pkgs, replay := collectHost(t, "arch", "pacman")
+	assert.Empty(t, replay.Missed())
+	assert.Len(t, pkgs, 11)
+
+	linux := pkgs["linux"]
+	assert.True(t, linux.NeedsUpdate)
+	assert.Equal(t, "6.11.3.arch1-1", linux.CurrentVersion)
+	assert.Equal(t, "6.11.5.arch1-1", linux.AvailableVersion)
+	assert.Equal(t, "core", linux.SourceRepository)
+
+	assert.False(t, pkgs["git"].NeedsUpdate)
+	assert.Equal(t, "2.46.2-1", pkgs["git"].CurrentVersion, "the installed version, not the sync database's")
+	assert.Equal(t, "foreign", pkgs["yay"].SourceRepository)
+	assert.NotContains(t, pkgs, "vim", "listed in the sync database but not installed")
+}
+
+func TestHostFreeBSD14(t *testing.T) {
+	pkgs, replay := collectHost(t, "freebsd-14", "pkg")
+	assert.Empty(t, replay.Missed())
+	assert.Len(t, pkgs, 9)
+
+	curl := pkgs["curl"]
+	assert.True(t, curl.NeedsUpdate)
+	assert.True(t, curl.IsSecurityUpdate)
+	assert.Equal(t, "8.11.0", curl.AvailableVersion)
+	assert.Equal(t, "FreeBSD", curl.SourceRepository)
+	assert.True(t, pkgs["sudo"].IsSecurityUpdate)
+	assert.False(t, pkgs["git"].IsSecurityUpdate)
+
+	assert.Equal(t, "local", pkgs["patchmon-agent"].SourceRepository)
+
+	base := pkgs["freebsd-base"]
+	assert.True(t, base.NeedsUpdate)
+	assert.Equal(t, "14.1-RELEASE-p5", base.CurrentVersion)
+}
@@ -1,13 +1,16 @@
 package packages
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"runtime"
 	"strings"
+	"time"
 
-	"patchmon-agent/pkg/models"
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner"
 
 	"github.com/sirupsen/logrus"
 )
@@ -18,9 +21,42 @@ type CacheRefreshConfig struct {
 	MaxAge int    // minutes, only used when Mode == "if_stale"
 }
 
+// ShouldRefresh reports whether the agent may refresh the package cache now.
+// stale is only consulted in "if_stale" mode. "never" leaves the cache entirely
+// to the host's own tooling (unattended-upgrades, dnf-makecache.timer, ...).
+func (c CacheRefreshConfig) ShouldRefresh(stale func(maxAgeMinutes int) bool) bool {
+	switch c.Mode {
+	case "never":
+		return false
+	case "if_stale":
+		return stale(c.MaxAge)
+	default:
+		return true
+	}
+}
+
+// cacheOlderThan reports whether the first existing path was modified more than
+// maxAgeMinutes ago. If none exist the cache is assumed stale.
+func cacheOlderThan(paths []string, maxAgeMinutes int) bool {
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		return time.Since(info.ModTime()) > time.Duration(maxAgeMinutes)*time.Minute
+	}
+	return true
+}
+
+// APTCacheStale reports whether the APT package lists are older than maxAgeMinutes
+func APTCacheStale(maxAgeMinutes int) bool {
+	return cacheOlderThan([]string{"/var/cache/apt/pkgcache.bin", "/var/lib/apt/lists"}, maxAgeMinutes)
+}
+
 // Manager handles package information collection
 type Manager struct {
 	logger         *logrus.Logger
+	runner         cmdrunner.Runner
 	aptManager     *APTManager
 	dnfManager     *DNFManager
 	apkManager     *APKManager
@@ -33,13 +69,14 @@ type Manager struct {
 func New(logger *logrus.Logger, cacheRefresh CacheRefreshConfig) *Manager {
 	aptManager := NewAPTManager(logger, cacheRefresh)
 	dnfManager := NewDNFManager(logger)
-	apkManager := NewAPKManager(logger)
+	apkManager := NewAPKManager(logger, cacheRefresh)
 	pacmanManager := NewPacmanManager(logger)
 	freebsdManager := NewFreeBSDManager(logger)
 	winManager := NewWindowsManager(logger)
 
 	return &Manager{
 		logger:         logger,
+		runner:         cmdrunner.Default,
 		aptManager:     aptManager,
 		dnfManager:     dnfManager,
 		apkManager:     apkManager,
@@ -49,6 +86,18 @@ func New(logger *logrus.Logger, cacheRefresh CacheRefreshConfig) *Manager {
 	}
 }
 
+// WithRunner makes the manager and every package manager collector run
+// commands through r, and returns m
+func (m *Manager) WithRunner(r cmdrunner.Runner) *Manager {
+	m.runner = r
+	m.aptManager.runner = r
+	m.dnfManager.runner = r
+	m.apkManager.runner = r
+	m.pacmanManager.runner = r
+	m.freebsdManager.runner = r
+	return m
+}
+
 // GetPackages gets package information based on detected package manager
 func (m *Manager) GetPackages() ([]models.Package, error) {
 	packageManager := m.DetectPackageManager()
@@ -90,8 +139,8 @@ func (m *Manager) DetectPackageManager() string {
 			}
 		}
 	}
-	if _, err := exec.LookPath("pkg"); err == nil {
-		if output, err := exec.Command("uname", "-s").Output(); err == nil {
+	if _, err := m.runner.LookPath("pkg"); err == nil {
+		if output, err := m.runner.Output(context.Background(), "uname", "-s"); err == nil {
 			if strings.TrimSpace(string(output)) == "FreeBSD" {
 				return "pkg"
 			}
@@ -99,28 +148,28 @@ func (m *Manager) DetectPackageManager() string {
 	}
 
 	// Check for APK (Alpine Linux)
-	if _, err := exec.LookPath("apk"); err == nil {
+	if _, err := m.runner.LookPath("apk"); err == nil {
 		return "apk"
 	}
 
 	// Check for APT
-	if _, err := exec.LookPath("apt"); err == nil {
+	if _, err := m.runner.LookPath("apt"); err == nil {
 		return "apt"
 	}
-	if _, err := exec.LookPath("apt-get"); err == nil {
+	if _, err := m.runner.LookPath("apt-get"); err == nil {
 		return "apt"
 	}
 
 	// Check for DNF/YUM
-	if _, err := exec.LookPath("dnf"); err == nil {
+	if _, err := m.runner.LookPath("dnf"); err == nil {
 		return "dnf"
 	}
-	if _, err := exec.LookPath("yum"); err == nil {
+	if _, err := m.runner.LookPath("yum"); err == nil {
 		return "yum"
 	}
 
 	// Check for Pacman
-	if _, err := exec.LookPath("pacman"); err == nil {
+	if _, err := m.runner.LookPath("pacman"); err == nil {
 		return "pacman"
 	}
 
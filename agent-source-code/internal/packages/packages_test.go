@@ -3,7 +3,7 @@ package packages
 import (
 	"testing"
 
-	"patchmon-agent/pkg/models"
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -2,12 +2,12 @@ package packages
 
 import (
 	"bufio"
-	"errors"
-	"os/exec"
+	"context"
 	"regexp"
 	"strings"
 
-	"patchmon-agent/pkg/models"
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner"
 
 	"github.com/sirupsen/logrus"
 )
@@ -23,21 +23,17 @@ type installedPkg struct {
 // PacmanManager handles pacman package information collection
 type PacmanManager struct {
 	logger *logrus.Logger
+	runner cmdrunner.Runner
 }
 
 // NewPacmanManager creates a new Pacman package manager
 func NewPacmanManager(logger *logrus.Logger) *PacmanManager {
 	return &PacmanManager{
 		logger: logger,
+		runner: cmdrunner.Default,
 	}
 }
 
-// indirections for testability
-var (
-	lookPath   = exec.LookPath
-	runCommand = exec.Command
-)
-
 // GetPackages gets package information for pacman-based systems
 func (m *PacmanManager) GetPackages() ([]models.Package, error) {
 	// Get installed packages with repo info from pacman -Sl
@@ -85,8 +81,7 @@ func (m *PacmanManager) GetPackages() ([]models.Package, error) {
 func (m *PacmanManager) parseInstalledFromSyncList() map[string]installedPkg {
 	installed := make(map[string]installedPkg)
 
-	cmd := runCommand("pacman", "-Sl")
-	output, err := cmd.Output()
+	output, err := m.runner.Output(context.Background(), "pacman", "-Sl")
 	if err != nil {
 		m.logger.WithError(err).Warn("pacman -Sl failed, falling back to pacman -Q")
 		return m.fallbackParseInstalled()
@@ -109,6 +104,11 @@ func (m *PacmanManager) parseInstalledFromSyncList() map[string]installedPkg {
 		repo := fields[0]
 		name := fields[1]
 		version := fields[2]
+		// "[installed: <local-version>]" means the sync database has a
+		// different version from the one installed
+		if fields[3] == "[installed:" && len(fields) > 4 {
+			version = strings.TrimSuffix(fields[4], "]")
+		}
 
 		installed[name] = installedPkg{
 			version: version,
@@ -124,8 +124,7 @@ func (m *PacmanManager) parseInstalledFromSyncList() map[string]installedPkg {
 func (m *PacmanManager) fallbackParseInstalled() map[string]installedPkg {
 	installed := make(map[string]installedPkg)
 
-	cmd := runCommand("pacman", "-Q")
-	output, err := cmd.Output()
+	output, err := m.runner.Output(context.Background(), "pacman", "-Q")
 	if err != nil {
 		m.logger.WithError(err).Error("Failed to get installed packages")
 		return installed
@@ -150,8 +149,7 @@ func (m *PacmanManager) fallbackParseInstalled() map[string]installedPkg {
 func (m *PacmanManager) getForeignPackages() map[string]installedPkg {
 	foreign := make(map[string]installedPkg)
 
-	cmd := runCommand("pacman", "-Qm")
-	output, err := cmd.Output()
+	output, err := m.runner.Output(context.Background(), "pacman", "-Qm")
 	if err != nil {
 		// pacman -Qm returns exit code 1 if no foreign packages exist
 		m.logger.WithError(err).Debug("pacman -Qm returned error (may have no foreign packages)")
@@ -177,20 +175,16 @@ func (m *PacmanManager) getForeignPackages() map[string]installedPkg {
 
 // getUpgradablePackages runs checkupdates and returns parsed packages.
 func (m *PacmanManager) getUpgradablePackages() ([]models.Package, error) {
-	if _, err := lookPath("checkupdates"); err != nil {
+	if _, err := m.runner.LookPath("checkupdates"); err != nil {
 		m.logger.WithError(err).Error("checkupdates not found (pacman-contrib not installed)")
 		return nil, err
 	}
 
-	upgradeCmd := runCommand("checkupdates")
-	upgradeOutput, err := upgradeCmd.Output()
+	upgradeOutput, err := m.runner.Output(context.Background(), "checkupdates")
 	if err != nil {
 		// 0 = success with output, 1 = unknown failure, 2 = no updates available.
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			if exitErr.ExitCode() == 2 {
-				return []models.Package{}, nil
-			}
+		if code, ok := cmdrunner.ExitCode(err); ok && code == 2 {
+			return []models.Package{}, nil
 		}
 		m.logger.WithError(err).Error("checkupdates failed")
 		return nil, err
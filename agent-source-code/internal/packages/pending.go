@@ -0,0 +1,65 @@
+package packages
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// PendingHistory remembers when the agent first saw each package's update
+// pending, so the server can report how long updates have been outstanding
+// without guessing from report timestamps
+type PendingHistory struct {
+	FirstSeen map[string]time.Time `json:"firstSeen"`
+}
+
+// LoadPendingHistory reads the pending update file. A missing file yields an
+// empty history.
+func LoadPendingHistory(path string) (*PendingHistory, error) {
+	h := &PendingHistory{FirstSeen: make(map[string]time.Time)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return h, fmt.Errorf("failed to read pending update history: %w", err)
+	}
+	if err := json.Unmarshal(data, h); err != nil {
+		// Starting over only makes updates look newer than they are
+		return &PendingHistory{FirstSeen: make(map[string]time.Time)}, fmt.Errorf("failed to parse pending update history: %w", err)
+	}
+	if h.FirstSeen == nil {
+		h.FirstSeen = make(map[string]time.Time)
+	}
+	return h, nil
+}
+
+// Apply sets PendingSince on each package needing an update and forgets
+// packages that no longer do. A package stays pending from when it was first
+// seen even if newer versions arrive before it is updated.
+func (h *PendingHistory) Apply(pkgs []models.Package, now time.Time) {
+	now = now.UTC().Truncate(time.Second)
+	pending := make(map[string]time.Time, len(h.FirstSeen))
+	for i := range pkgs {
+		if !pkgs[i].NeedsUpdate {
+			continue
+		}
+		since, ok := h.FirstSeen[pkgs[i].Name]
+		if !ok {
+			since = now
+		}
+		pending[pkgs[i].Name] = since
+		pkgs[i].PendingSince = &since
+	}
+	h.FirstSeen = pending
+}
+
+// Save writes the history atomically with owner-only permissions
+func (h *PendingHistory) Save(path string) error {
+	return saveState(path, "pending update history", h)
+}
@@ -0,0 +1,59 @@
+package packages
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPendingHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pending_updates.json")
+	day1 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	day8 := day1.Add(7 * 24 * time.Hour)
+
+	h, err := LoadPendingHistory(path)
+	require.NoError(t, err)
+	first := []models.Package{
+		{Name: "openssl", NeedsUpdate: true, AvailableVersion: "3.0.14"},
+		{Name: "bash"},
+	}
+	h.Apply(first, day1)
+	require.NotNil(t, first[0].PendingSince)
+	assert.Equal(t, day1, *first[0].PendingSince)
+	assert.Nil(t, first[1].PendingSince)
+	require.NoError(t, h.Save(path))
+
+	h, err = LoadPendingHistory(path)
+	require.NoError(t, err)
+	later := []models.Package{
+		{Name: "openssl", NeedsUpdate: true, AvailableVersion: "3.0.15"},
+		{Name: "curl", NeedsUpdate: true},
+	}
+	h.Apply(later, day8)
+	assert.Equal(t, day1, *later[0].PendingSince, "a newer version doesn't restart the clock")
+	assert.Equal(t, day8, *later[1].PendingSince)
+
+	// Updating the package forgets it, so a later update starts fresh
+	h.Apply([]models.Package{{Name: "openssl"}, {Name: "curl", NeedsUpdate: true}}, day8)
+	again := []models.Package{{Name: "openssl", NeedsUpdate: true}}
+	h.Apply(again, day8.Add(time.Hour))
+	assert.Equal(t, day8.Add(time.Hour), *again[0].PendingSince)
+}
+
+func TestLoadPendingHistoryCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pending_updates.json")
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0600))
+
+	h, err := LoadPendingHistory(path)
+	assert.Error(t, err)
+	require.NotNil(t, h)
+	pkgs := []models.Package{{Name: "curl", NeedsUpdate: true}}
+	h.Apply(pkgs, time.Now())
+	assert.NotNil(t, pkgs[0].PendingSince, "a corrupt file must not block reporting")
+}
@@ -0,0 +1,181 @@
+package packages
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"patchmon-agent/internal/utils"
+)
+
+var (
+	systemProxyOnce sync.Once
+	systemProxyURL  *url.URL
+)
+
+// SystemProxy returns the HTTP proxy the host's package manager is configured
+// to use, or nil. apt (Acquire::http(s)::Proxy), dnf/yum (proxy= in [main]) and
+// SUSE's /etc/sysconfig/proxy are checked. The result is cached for the life of
+// the process.
+func SystemProxy() *url.URL {
+	systemProxyOnce.Do(func() {
+		systemProxyURL = detectSystemProxy()
+	})
+	return systemProxyURL
+}
+
+// ProxyFunc is an http.Transport Proxy function for downloads the agent makes on
+// behalf of package operations (e.g. SSG content). Proxy environment variables
+// win; when none are set, as under systemd, the package manager's proxy is used
+// so the download takes the same route apt or dnf would.
+func ProxyFunc(req *http.Request) (*url.URL, error) {
+	if proxy, err := http.ProxyFromEnvironment(req); proxy != nil || err != nil {
+		return proxy, err
+	}
+	if hasProxyEnv() {
+		// NO_PROXY matched, or only a proxy for the other scheme is set
+		return nil, nil
+	}
+	return SystemProxy(), nil
+}
+
+func hasProxyEnv() bool {
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "NO_PROXY", "no_proxy"} {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func detectSystemProxy() *url.URL {
+	if _, err := exec.LookPath("apt-config"); err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		out, err := utils.CommandContext(ctx, "apt-config", "shell",
+			"HTTPS_PROXY", "Acquire::https::Proxy",
+			"HTTP_PROXY", "Acquire::http::Proxy").Output()
+		if err == nil {
+			if proxy := parseAptConfigShell(string(out)); proxy != nil {
+				return proxy
+			}
+		}
+	}
+	for _, path := range []string{"/etc/dnf/dnf.conf", "/etc/yum.conf"} {
+		if proxy := readProxyFile(path, parseRepoConfProxy); proxy != nil {
+			return proxy
+		}
+	}
+	return readProxyFile("/etc/sysconfig/proxy", parseSysconfigProxy)
+}
+
+func readProxyFile(path string, parse func(io.Reader) *url.URL) *url.URL {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+	return parse(f)
+}
+
+// parseAptConfigShell parses `apt-config shell` output (VAR='value' lines),
+// preferring the https proxy. "DIRECT" and "false" mean no proxy.
+func parseAptConfigShell(out string) *url.URL {
+	values := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		values[name] = strings.Trim(value, `'"`)
+	}
+	for _, name := range []string{"HTTPS_PROXY", "HTTP_PROXY"} {
+		if proxy := parseProxyURL(values[name]); proxy != nil {
+			return proxy
+		}
+	}
+	return nil
+}
+
+// parseRepoConfProxy reads proxy, proxy_username and proxy_password from the
+// [main] section of dnf.conf / yum.conf
+func parseRepoConfProxy(r io.Reader) *url.URL {
+	var proxy, user, password string
+	inMain := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			inMain = line == "[main]"
+			continue
+		}
+		if !inMain {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "proxy":
+			proxy = strings.TrimSpace(value)
+		case "proxy_username":
+			user = strings.TrimSpace(value)
+		case "proxy_password":
+			password = strings.TrimSpace(value)
+		}
+	}
+	u := parseProxyURL(proxy)
+	if u != nil && user != "" && u.User == nil {
+		u.User = url.UserPassword(user, password)
+	}
+	return u
+}
+
+// parseSysconfigProxy reads SUSE's /etc/sysconfig/proxy, which YaST and zypper use
+func parseSysconfigProxy(r io.Reader) *url.URL {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok || strings.HasPrefix(name, "#") {
+			continue
+		}
+		values[name] = strings.Trim(value, `'"`)
+	}
+	if values["PROXY_ENABLED"] != "yes" {
+		return nil
+	}
+	for _, name := range []string{"HTTPS_PROXY", "HTTP_PROXY"} {
+		if proxy := parseProxyURL(values[name]); proxy != nil {
+			return proxy
+		}
+	}
+	return nil
+}
+
+func parseProxyURL(value string) *url.URL {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(value) {
+	case "", "direct", "false", "_none_":
+		return nil
+	}
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	return u
+}
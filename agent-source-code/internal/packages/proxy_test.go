@@ -0,0 +1,56 @@
+package packages
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAptConfigShell(t *testing.T) {
+	proxy := parseAptConfigShell("HTTPS_PROXY='DIRECT'\nHTTP_PROXY='http://proxy.example:3142/'\n")
+	require.NotNil(t, proxy)
+	assert.Equal(t, "http://proxy.example:3142/", proxy.String())
+
+	assert.Nil(t, parseAptConfigShell(""))
+	assert.Nil(t, parseAptConfigShell("HTTP_PROXY='false'\n"))
+}
+
+func TestParseRepoConfProxy(t *testing.T) {
+	conf := `[main]
+gpgcheck=1
+proxy=http://squid.internal:3128
+proxy_username=agent
+proxy_password=secret
+
+[updates]
+proxy=http://other:8080
+`
+	proxy := parseRepoConfProxy(strings.NewReader(conf))
+	require.NotNil(t, proxy)
+	assert.Equal(t, "squid.internal:3128", proxy.Host)
+	assert.Equal(t, "agent", proxy.User.Username())
+
+	assert.Nil(t, parseRepoConfProxy(strings.NewReader("[main]\nproxy=_none_\n")))
+	assert.Nil(t, parseRepoConfProxy(strings.NewReader("[repo]\nproxy=http://other:8080\n")), "only [main] applies host-wide")
+}
+
+func TestParseSysconfigProxy(t *testing.T) {
+	conf := "PROXY_ENABLED=\"yes\"\nHTTP_PROXY=\"proxy.suse.lan:8080\"\n"
+	proxy := parseSysconfigProxy(strings.NewReader(conf))
+	require.NotNil(t, proxy)
+	assert.Equal(t, "http://proxy.suse.lan:8080", proxy.String())
+
+	assert.Nil(t, parseSysconfigProxy(strings.NewReader("PROXY_ENABLED=\"no\"\nHTTP_PROXY=\"proxy:8080\"\n")))
+}
+
+func TestCacheRefreshShouldRefresh(t *testing.T) {
+	stale := func(int) bool { return true }
+	fresh := func(int) bool { return false }
+
+	assert.True(t, CacheRefreshConfig{Mode: "always"}.ShouldRefresh(fresh))
+	assert.True(t, CacheRefreshConfig{Mode: "if_stale", MaxAge: 60}.ShouldRefresh(stale))
+	assert.False(t, CacheRefreshConfig{Mode: "if_stale", MaxAge: 60}.ShouldRefresh(fresh))
+	assert.False(t, CacheRefreshConfig{Mode: "never"}.ShouldRefresh(stale))
+}
@@ -0,0 +1,177 @@
+package packages
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// watchQuietPeriod waits for a burst of database writes (one apt run touches
+	// dpkg/status many times) to settle before reporting
+	watchQuietPeriod = 15 * time.Second
+	// watchMaxDelay caps how long a steady stream of writes can postpone a report
+	watchMaxDelay = time.Minute
+	// watchCooldown is the minimum gap between watch-triggered reports
+	watchCooldown = 2 * time.Minute
+)
+
+// WatchTarget is a package database path to watch. File targets match writes to
+// that file (the parent directory is watched, since dpkg and apk replace their
+// databases by rename). Dir targets match entries being created or removed.
+type WatchTarget struct {
+	Path string
+	Dir  bool
+}
+
+// candidateWatchTargets lists package databases across supported platforms
+var candidateWatchTargets = []WatchTarget{
+	{Path: "/var/lib/dpkg/status"},
+	{Path: "/var/lib/rpm/rpmdb.sqlite"},
+	{Path: "/var/lib/rpm/rpmdb.sqlite-wal"},
+	{Path: "/var/lib/rpm/Packages"},
+	{Path: "/var/lib/rpm/Packages.db"},
+	{Path: "/usr/lib/sysimage/rpm/rpmdb.sqlite"},
+	{Path: "/usr/lib/sysimage/rpm/rpmdb.sqlite-wal"},
+	{Path: "/usr/lib/sysimage/rpm/Packages.db"},
+	{Path: "/var/lib/pacman/local", Dir: true},
+	{Path: "/lib/apk/db/installed"},
+	{Path: "/var/db/pkg/local.sqlite"},
+}
+
+// DefaultWatchTargets returns the package databases present on this host
+func DefaultWatchTargets() []WatchTarget {
+	var targets []WatchTarget
+	for _, t := range candidateWatchTargets {
+		if _, err := os.Stat(t.Path); err == nil {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// Watcher reports package database changes, debounced so one transaction
+// produces one callback
+type Watcher struct {
+	logger   *logrus.Logger
+	targets  []WatchTarget
+	quiet    time.Duration
+	maxDelay time.Duration
+	cooldown time.Duration
+}
+
+// NewWatcher creates a watcher for the given targets
+func NewWatcher(logger *logrus.Logger, targets []WatchTarget) *Watcher {
+	return &Watcher{
+		logger:   logger,
+		targets:  targets,
+		quiet:    watchQuietPeriod,
+		maxDelay: watchMaxDelay,
+		cooldown: watchCooldown,
+	}
+}
+
+// Run watches until ctx is cancelled, calling onChange after each settled burst
+// of changes. It returns an error only if the watch could not be set up.
+func (w *Watcher) Run(ctx context.Context, onChange func()) error {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = fw.Close()
+	}()
+
+	files := make(map[string]bool)
+	dirs := make(map[string]bool)
+	watched := make(map[string]bool)
+	for _, t := range w.targets {
+		dir := t.Path
+		if t.Dir {
+			dirs[t.Path] = true
+		} else {
+			files[t.Path] = true
+			dir = filepath.Dir(t.Path)
+		}
+		if watched[dir] {
+			continue
+		}
+		if err := fw.Add(dir); err != nil {
+			w.logger.WithError(err).WithField("path", dir).Warn("Failed to watch package database")
+			continue
+		}
+		watched[dir] = true
+		w.logger.WithField("path", dir).Debug("Watching package database for changes")
+	}
+
+	var (
+		timer     *time.Timer
+		timerC    <-chan time.Time
+		firstSeen time.Time
+		lastFired time.Time
+	)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-fw.Events:
+			if !ok {
+				return nil
+			}
+			if !relevantEvent(ev, files, dirs) {
+				continue
+			}
+			now := time.Now()
+			if timer == nil {
+				firstSeen = now
+			}
+			delay := w.nextDelay(now, firstSeen, lastFired)
+			if timer == nil {
+				timer = time.NewTimer(delay)
+			} else {
+				timer.Reset(delay)
+			}
+			timerC = timer.C
+		case <-timerC:
+			timer, timerC = nil, nil
+			lastFired = time.Now()
+			w.logger.Info("Package database changed, triggering report")
+			onChange()
+		case err, ok := <-fw.Errors:
+			if !ok {
+				return nil
+			}
+			w.logger.WithError(err).Debug("Package database watcher error")
+		}
+	}
+}
+
+// nextDelay waits for the quiet period, but no longer than maxDelay after the
+// first event of a burst, and never sooner than cooldown after the last report
+func (w *Watcher) nextDelay(now, firstSeen, lastFired time.Time) time.Duration {
+	delay := min(w.quiet, w.maxDelay-now.Sub(firstSeen))
+	if cooldownLeft := w.cooldown - now.Sub(lastFired); cooldownLeft > delay {
+		delay = cooldownLeft
+	}
+	return max(delay, 0)
+}
+
+func relevantEvent(ev fsnotify.Event, files, dirs map[string]bool) bool {
+	if files[ev.Name] {
+		return ev.Has(fsnotify.Write) || ev.Has(fsnotify.Create) || ev.Has(fsnotify.Rename)
+	}
+	if dirs[filepath.Dir(ev.Name)] {
+		return ev.Has(fsnotify.Create) || ev.Has(fsnotify.Remove)
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package packages
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcherNextDelay(t *testing.T) {
+	w := &Watcher{quiet: 15 * time.Second, maxDelay: time.Minute, cooldown: 2 * time.Minute}
+	now := time.Now()
+	longAgo := now.Add(-time.Hour)
+
+	assert.Equal(t, 15*time.Second, w.nextDelay(now, now, longAgo))
+	// A steady stream of writes can't postpone past maxDelay
+	assert.Equal(t, 5*time.Second, w.nextDelay(now, now.Add(-55*time.Second), longAgo))
+	assert.Equal(t, time.Duration(0), w.nextDelay(now, now.Add(-2*time.Minute), longAgo))
+	// Cooldown after a recent report wins
+	assert.Equal(t, 90*time.Second, w.nextDelay(now, now, now.Add(-30*time.Second)))
+}
+
+func TestWatcherDebouncesBurst(t *testing.T) {
+	dir := t.TempDir()
+	status := filepath.Join(dir, "status")
+	require.NoError(t, os.WriteFile(status, []byte("a"), 0644))
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	w := NewWatcher(logger, []WatchTarget{{Path: status}})
+	w.quiet = 100 * time.Millisecond
+	w.maxDelay = time.Second
+	w.cooldown = 0
+
+	var calls atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = w.Run(ctx, func() { calls.Add(1) })
+	}()
+	time.Sleep(100 * time.Millisecond) // let the watch register
+
+	// Unrelated files in the same directory are ignored
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "status-old"), []byte("x"), 0644))
+	for i := 0; i < 5; i++ {
+		require.NoError(t, os.WriteFile(status, []byte{byte('b' + i)}, 0644))
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Eventually(t, func() bool { return calls.Load() == 1 }, 2*time.Second, 20*time.Millisecond)
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, int32(1), calls.Load())
+}
@@ -7,8 +7,8 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 	"patchmon-agent/internal/logutil"
-	"patchmon-agent/pkg/models"
 
 	"github.com/sirupsen/logrus"
 )
@@ -0,0 +1,291 @@
+package patching
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/packages"
+)
+
+// command is a package manager invocation. Exit codes in ok are success as
+// well as 0.
+type command struct {
+	args []string
+	ok   []int
+}
+
+// managerCommands are how a run drives one package manager
+type managerCommands struct {
+	installed      command
+	parseInstalled func(out string) map[string][]string
+	refresh        command
+	// stale reports whether the metadata is older than maxAgeMinutes, for
+	// the "if_stale" cache refresh mode
+	stale  func(maxAgeMinutes int) bool
+	update func(names []string) command
+	dryRun func(names []string) command
+	// parsePlan reads the dry run's output into the changes it would make
+	parsePlan func(out string, installed map[string][]string, names []string) []models.PackageTransactionItem
+}
+
+// aptOptions keep apt-get from prompting, keeping modified config files
+var aptOptions = []string{"-y", "-o", "Dpkg::Options::=--force-confdef", "-o", "Dpkg::Options::=--force-confold"}
+
+// commandsFor returns the commands for manager, run as binary
+func commandsFor(manager, binary string) managerCommands {
+	switch manager {
+	case "apt":
+		return managerCommands{
+			installed:      command{args: []string{"dpkg-query", "-W", "-f=${db:Status-Abbrev}\t${Package}\t${Version}\n"}},
+			parseInstalled: parseDpkgInstalled,
+			refresh:        command{args: []string{"apt-get", "update", "-qq"}},
+			stale:          packages.APTCacheStale,
+			update: func(names []string) command {
+				args := append([]string{"env", "DEBIAN_FRONTEND=noninteractive", "apt-get"}, aptOptions...)
+				if len(names) == 0 {
+					return command{args: append(args, "upgrade")}
+				}
+				return command{args: append(append(args, "install", "--only-upgrade"), names...)}
+			},
+			dryRun: func(names []string) command {
+				if len(names) == 0 {
+					return command{args: []string{"apt-get", "-s", "upgrade"}}
+				}
+				return command{args: append([]string{"apt-get", "-s", "install", "--only-upgrade"}, names...)}
+			},
+			parsePlan: parseAptSimulation,
+		}
+	case "zypper":
+		return managerCommands{
+			installed:      rpmInstalled,
+			parseInstalled: parseTabInstalled,
+			refresh:        command{args: []string{"zypper", "--non-interactive", "refresh"}},
+			stale:          selfRefreshing,
+			update: func(names []string) command {
+				// 102 and 103: updated, but a reboot or a zypper restart is needed
+				return command{args: append([]string{"zypper", "--non-interactive", "update"}, names...), ok: []int{102, 103}}
+			},
+			dryRun: func([]string) command {
+				return command{args: []string{"zypper", "--non-interactive", "list-updates"}}
+			},
+			parsePlan: parseZypperUpdates,
+		}
+	case "pkg":
+		return managerCommands{
+			installed:      command{args: []string{binary, "query", "-a", "%n\t%v"}},
+			parseInstalled: parseTabInstalled,
+			refresh:        command{args: []string{binary, "update", "-q"}},
+			stale:          selfRefreshing,
+			update: func(names []string) command {
+				return command{args: append([]string{binary, "upgrade", "-y"}, names...)}
+			},
+			dryRun: func(names []string) command {
+				// Exits 1 when there is something to upgrade
+				return command{args: append([]string{binary, "upgrade", "-n"}, names...), ok: []int{1}}
+			},
+			parsePlan: parsePkgUpgrade,
+		}
+	default: // dnf, yum
+		return managerCommands{
+			installed:      rpmInstalled,
+			parseInstalled: parseTabInstalled,
+			refresh:        command{args: []string{binary, "makecache", "-q"}},
+			stale:          selfRefreshing,
+			update: func(names []string) command {
+				return command{args: append([]string{binary, "-y", "upgrade"}, names...)}
+			},
+			dryRun: func(names []string) command {
+				// Exits 100 when updates are available
+				return command{args: append([]string{binary, "check-update", "-q"}, names...), ok: []int{100}}
+			},
+			parsePlan: parseCheckUpdate,
+		}
+	}
+}
+
+var rpmInstalled = command{args: []string{"rpm", "-qa", "--qf", "%{NAME}\t%{VERSION}-%{RELEASE}\n"}}
+
+// selfRefreshing is the stale check for package managers that fetch expired
+// metadata themselves, so "if_stale" leaves them to it
+func selfRefreshing(int) bool {
+	return false
+}
+
+// parseDpkgInstalled reads dpkg-query's status, name and version lines,
+// keeping installed packages
+func parseDpkgInstalled(out string) map[string][]string {
+	installed := make(map[string][]string)
+	for line := range strings.Lines(out) {
+		fields := strings.Split(strings.TrimRight(line, "\n"), "\t")
+		if len(fields) != 3 || len(fields[0]) < 2 || fields[0][1] != 'i' {
+			continue
+		}
+		addVersion(installed, fields[1], fields[2])
+	}
+	return installed
+}
+
+// parseTabInstalled reads name and version lines from rpm or pkg query
+func parseTabInstalled(out string) map[string][]string {
+	installed := make(map[string][]string)
+	for line := range strings.Lines(out) {
+		name, version, ok := strings.Cut(strings.TrimRight(line, "\n"), "\t")
+		if ok && name != "" {
+			addVersion(installed, name, version)
+		}
+	}
+	return installed
+}
+
+func addVersion(installed map[string][]string, name, version string) {
+	if !slices.Contains(installed[name], version) {
+		installed[name] = append(installed[name], version)
+		slices.Sort(installed[name])
+	}
+}
+
+// latest returns the last of a package's installed versions
+func latest(versions []string) string {
+	if len(versions) == 0 {
+		return ""
+	}
+	return versions[len(versions)-1]
+}
+
+var (
+	aptInstRe = regexp.MustCompile(`^Inst (\S+) (?:\[([^\]]+)\] )?\((\S+)`)
+	aptRemvRe = regexp.MustCompile(`^Remv (\S+) \[([^\]]+)\]`)
+)
+
+// parseAptSimulation reads the Inst and Remv lines of apt-get -s, which
+// include the dependencies the requested packages pull in
+func parseAptSimulation(out string, _ map[string][]string, _ []string) []models.PackageTransactionItem {
+	items := make([]models.PackageTransactionItem, 0)
+	for line := range strings.Lines(out) {
+		if m := aptInstRe.FindStringSubmatch(line); m != nil {
+			item := models.PackageTransactionItem{Name: stripArch(m[1]), Action: "install", FromVersion: m[2], ToVersion: m[3]}
+			if m[2] != "" {
+				item.Action = "upgrade"
+			}
+			items = append(items, item)
+		} else if m := aptRemvRe.FindStringSubmatch(line); m != nil {
+			items = append(items, models.PackageTransactionItem{Name: stripArch(m[1]), Action: "remove", FromVersion: m[2]})
+		}
+	}
+	return items
+}
+
+// stripArch drops the :arch apt adds to foreign-architecture packages
+func stripArch(name string) string {
+	name, _, _ = strings.Cut(name, ":")
+	return name
+}
+
+// parseCheckUpdate reads dnf or yum check-update: name.arch, version and
+// repository columns, a long name wrapping the rest onto the next line.
+// Only updates to installed packages are listed, not new dependencies.
+func parseCheckUpdate(out string, installed map[string][]string, _ []string) []models.PackageTransactionItem {
+	items := make([]models.PackageTransactionItem, 0)
+	var pending []string
+	for line := range strings.Lines(out) {
+		if strings.HasPrefix(line, "Obsoleting") {
+			break
+		}
+		fields := strings.Fields(line)
+		if len(pending) > 0 {
+			fields = append(pending, fields...)
+			pending = nil
+		}
+		switch {
+		case len(fields) == 1 && strings.Contains(fields[0], "."):
+			pending = fields
+			continue
+		case len(fields) != 3:
+			continue
+		}
+		dot := strings.LastIndex(fields[0], ".")
+		if dot <= 0 {
+			continue
+		}
+		name := fields[0][:dot]
+		version := fields[1]
+		// rpm reports versions without the epoch
+		if _, v, ok := strings.Cut(version, ":"); ok {
+			version = v
+		}
+		items = append(items, models.PackageTransactionItem{Name: name, Action: "upgrade", FromVersion: latest(installed[name]), ToVersion: version})
+	}
+	return items
+}
+
+// parseZypperUpdates reads zypper list-updates' table, keeping the requested
+// packages since zypper lists every update
+func parseZypperUpdates(out string, _ map[string][]string, names []string) []models.PackageTransactionItem {
+	items := make([]models.PackageTransactionItem, 0)
+	for line := range strings.Lines(out) {
+		cols := strings.Split(line, "|")
+		if len(cols) < 6 {
+			continue
+		}
+		name := strings.TrimSpace(cols[2])
+		if name == "" || name == "Name" || strings.HasPrefix(name, "-") {
+			continue
+		}
+		if len(names) > 0 && !slices.Contains(names, name) {
+			continue
+		}
+		items = append(items, models.PackageTransactionItem{
+			Name:        name,
+			Action:      "upgrade",
+			FromVersion: strings.TrimSpace(cols[3]),
+			ToVersion:   strings.TrimSpace(cols[4]),
+		})
+	}
+	return items
+}
+
+// parsePkgUpgrade reads the sections of pkg upgrade -n
+func parsePkgUpgrade(out string, _ map[string][]string, _ []string) []models.PackageTransactionItem {
+	items := make([]models.PackageTransactionItem, 0)
+	action := ""
+	for line := range strings.Lines(out) {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "New packages to be INSTALLED"):
+			action = "install"
+			continue
+		case strings.HasPrefix(trimmed, "Installed packages to be UPGRADED"):
+			action = "upgrade"
+			continue
+		case strings.HasPrefix(trimmed, "Installed packages to be DOWNGRADED"):
+			action = "downgrade"
+			continue
+		case strings.HasPrefix(trimmed, "Installed packages to be REMOVED"):
+			action = "remove"
+			continue
+		case trimmed == "" || !strings.HasPrefix(line, "\t"):
+			// A blank line or the next heading ends the section
+			action = ""
+			continue
+		}
+		if action == "" {
+			continue
+		}
+		name, version, ok := strings.Cut(trimmed, ": ")
+		if !ok {
+			continue
+		}
+		item := models.PackageTransactionItem{Name: name, Action: action}
+		if from, to, ok := strings.Cut(version, " -> "); ok {
+			item.FromVersion, item.ToVersion = from, to
+		} else if action == "remove" {
+			item.FromVersion = version
+		} else {
+			item.ToVersion = version
+		}
+		items = append(items, item)
+	}
+	return items
+}
@@ -0,0 +1,49 @@
+package patching
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/utils"
+)
+
+// logEntries is how many runs the transaction log keeps
+const logEntries = 200
+
+// AppendLog adds result to the transaction log at path, one JSON object per
+// line, dropping the oldest runs past logEntries. The output is left out:
+// the server gets it, and the log is a record of what changed.
+func AppendLog(path string, result *models.PackageUpdateResult) error {
+	entry := *result
+	entry.Output = ""
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	var lines [][]byte
+	if data, err := os.ReadFile(path); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, 1024*1024)
+		for scanner.Scan() {
+			if len(scanner.Bytes()) > 0 {
+				lines = append(lines, bytes.Clone(scanner.Bytes()))
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read package update log: %w", err)
+	}
+	lines = append(lines, line)
+	if len(lines) > logEntries {
+		lines = lines[len(lines)-logEntries:]
+	}
+
+	if err := utils.WriteFileAtomic(path, append(bytes.Join(lines, []byte("\n")), '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write package update log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+// Package patching applies the package updates the server selects with the
+// host's package manager (apt, dnf, yum, zypper or FreeBSD pkg). What a run
+// changed is worked out from the installed versions before and after it, so
+// dependencies are reported too and a failed run still shows what it did.
+package patching
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner"
+	"patchmon-agent/internal/packages"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxOutput is how much package manager output a result keeps. The end is
+// kept since that's where the errors are.
+const maxOutput = 64 * 1024
+
+// Request selects what a run updates
+type Request struct {
+	Packages []string // Packages to update; empty updates everything
+	DryRun   bool     // List what would change instead of changing it
+}
+
+// Patcher applies package updates
+type Patcher struct {
+	logger       *logrus.Logger
+	runner       cmdrunner.Runner
+	cacheRefresh packages.CacheRefreshConfig
+}
+
+// New creates a Patcher that refreshes package metadata before a run as
+// cacheRefresh allows
+func New(logger *logrus.Logger, cacheRefresh packages.CacheRefreshConfig) *Patcher {
+	return &Patcher{logger: logger, runner: cmdrunner.Default, cacheRefresh: cacheRefresh}
+}
+
+// detect returns the package manager updates are applied with (apt, dnf,
+// yum, zypper or pkg) and the binary to run, or "" when the host has none
+func (p *Patcher) detect(ctx context.Context) (manager, binary string) {
+	// Only FreeBSD's pkg, not other tools by that name. PATH may be minimal
+	// when the agent runs as an rc.d service.
+	for _, pkg := range []string{"pkg", "/usr/sbin/pkg", "/usr/local/sbin/pkg"} {
+		if _, err := p.runner.LookPath(pkg); err != nil {
+			continue
+		}
+		if out, err := p.runner.Output(ctx, "uname", "-s"); err == nil && strings.TrimSpace(string(out)) == "FreeBSD" {
+			return "pkg", pkg
+		}
+		break
+	}
+	for _, m := range []struct{ binary, manager string }{
+		{"apt-get", "apt"},
+		{"dnf", "dnf"},
+		{"yum", "yum"},
+		{"zypper", "zypper"},
+	} {
+		if _, err := p.runner.LookPath(m.binary); err == nil {
+			return m.manager, m.binary
+		}
+	}
+	return "", ""
+}
+
+// Apply runs req and returns its outcome, which records failures rather
+// than returning them
+func (p *Patcher) Apply(ctx context.Context, req Request) *models.PackageUpdateResult {
+	result := &models.PackageUpdateResult{
+		DryRun:    req.DryRun,
+		Requested: req.Packages,
+		Status:    models.PackageUpdateFailed,
+		Packages:  make([]models.PackageTransactionItem, 0),
+		StartedAt: time.Now().UTC(),
+	}
+	out := &tailBuffer{limit: maxOutput}
+	defer func() {
+		result.Output = out.String()
+		result.CompletedAt = time.Now().UTC()
+	}()
+
+	manager, binary := p.detect(ctx)
+	if manager == "" {
+		result.Error = "no supported package manager found (apt, dnf, yum, zypper or pkg)"
+		return result
+	}
+	result.Manager = manager
+	cmds := commandsFor(manager, binary)
+
+	before, err := p.installed(ctx, cmds)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if p.cacheRefresh.ShouldRefresh(cmds.stale) {
+		if _, err := p.run(ctx, out, cmds.refresh); err != nil {
+			result.Error = fmt.Sprintf("refreshing package metadata failed: %v", err)
+			return result
+		}
+	}
+
+	if req.DryRun {
+		stdout, err := p.run(ctx, out, cmds.dryRun(req.Packages))
+		if err != nil {
+			result.Error = fmt.Sprintf("dry run failed: %v", err)
+			return result
+		}
+		result.Packages = cmds.parsePlan(string(stdout), before, req.Packages)
+	} else {
+		_, runErr := p.run(ctx, out, cmds.update(req.Packages))
+		// Read the versions back even after a failure, which may have left
+		// some packages updated
+		after, err := p.installed(ctx, cmds)
+		if err == nil {
+			result.Packages = diffInstalled(before, after)
+		}
+		switch {
+		case runErr != nil:
+			result.Error = fmt.Sprintf("update failed: %v", runErr)
+			return result
+		case err != nil:
+			result.Error = err.Error()
+			return result
+		}
+	}
+
+	result.Unchanged = unchanged(req.Packages, result.Packages)
+	result.Status = models.PackageUpdateSucceeded
+	p.logger.WithFields(logrus.Fields{
+		"manager":  manager,
+		"dry_run":  req.DryRun,
+		"packages": len(result.Packages),
+	}).Info("Package update finished")
+	return result
+}
+
+// installed returns every installed package's versions, keyed by name.
+// rpm can have several versions of a package (kernels) installed at once.
+func (p *Patcher) installed(ctx context.Context, cmds managerCommands) (map[string][]string, error) {
+	out, err := p.runner.Output(ctx, cmds.installed.args[0], cmds.installed.args[1:]...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list installed packages: %w", err)
+	}
+	return cmds.parseInstalled(string(out)), nil
+}
+
+// run runs cmd, copying the command line and its output to out. Exit codes
+// in cmd.ok count as success.
+func (p *Patcher) run(ctx context.Context, out *tailBuffer, cmd command) ([]byte, error) {
+	fmt.Fprintf(out, "$ %s\n", strings.Join(cmd.args, " "))
+	p.logger.WithField("command", strings.Join(cmd.args, " ")).Debug("Running package manager")
+	stdout, err := p.runner.Output(ctx, cmd.args[0], cmd.args[1:]...)
+	out.Write(stdout)
+	if err == nil {
+		return stdout, nil
+	}
+	if stderr := commandStderr(err); len(stderr) > 0 {
+		out.Write(stderr)
+	}
+	if code, ok := cmdrunner.ExitCode(err); ok && slices.Contains(cmd.ok, code) {
+		return stdout, nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return stdout, ctxErr
+	}
+	return stdout, err
+}
+
+// diffInstalled lists the packages whose installed versions differ between
+// before and after
+func diffInstalled(before, after map[string][]string) []models.PackageTransactionItem {
+	names := make(map[string]bool)
+	for name := range before {
+		names[name] = true
+	}
+	for name := range after {
+		names[name] = true
+	}
+	items := make([]models.PackageTransactionItem, 0)
+	for _, name := range sortedNames(names) {
+		gone := without(before[name], after[name])
+		added := without(after[name], before[name])
+		// Pair removed versions with added ones as upgrades; what's left over
+		// was installed alongside (a new kernel) or removed outright
+		for len(gone) > 0 && len(added) > 0 {
+			items = append(items, models.PackageTransactionItem{Name: name, Action: "upgrade", FromVersion: gone[0], ToVersion: added[0]})
+			gone, added = gone[1:], added[1:]
+		}
+		for _, v := range added {
+			items = append(items, models.PackageTransactionItem{Name: name, Action: "install", ToVersion: v})
+		}
+		for _, v := range gone {
+			items = append(items, models.PackageTransactionItem{Name: name, Action: "remove", FromVersion: v})
+		}
+	}
+	return items
+}
+
+// unchanged returns the requested packages that items don't mention
+func unchanged(requested []string, items []models.PackageTransactionItem) []string {
+	changed := make(map[string]bool, len(items))
+	for _, item := range items {
+		changed[item.Name] = true
+	}
+	var names []string
+	for _, name := range requested {
+		if !changed[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// without returns the versions in a that aren't in b
+func without(a, b []string) []string {
+	var out []string
+	for _, v := range a {
+		if !slices.Contains(b, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func sortedNames(set map[string]bool) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// commandStderr returns what a failed command wrote to stderr
+func commandStderr(err error) []byte {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Stderr
+	}
+	return nil
+}
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	limit     int
+	buf       []byte
+	truncated bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.limit; over > 0 {
+		b.buf = append(b.buf[:0], b.buf[over:]...)
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	if b.truncated {
+		return "[earlier output truncated]\n" + string(b.buf)
+	}
+	return string(b.buf)
+}
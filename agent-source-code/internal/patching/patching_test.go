@@ -0,0 +1,186 @@
+package patching
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner/cmdrunnertest"
+	"patchmon-agent/internal/packages"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPatcher(replay *cmdrunnertest.Replay) *Patcher {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	p := New(logger, packages.CacheRefreshConfig{Mode: "always"})
+	p.runner = replay
+	return p
+}
+
+func TestApplyAptDryRun(t *testing.T) {
+	replay := cmdrunnertest.Load(t, filepath.Join("testdata", "apt"))
+	p := newTestPatcher(replay)
+
+	result := p.Apply(context.Background(), Request{Packages: []string{"openssl", "curl"}, DryRun: true})
+	assert.Empty(t, replay.Missed())
+	assert.Equal(t, models.PackageUpdateSucceeded, result.Status, result.Error)
+	assert.Equal(t, "apt", result.Manager)
+	assert.Equal(t, []models.PackageTransactionItem{
+		{Name: "libssl3", Action: "upgrade", FromVersion: "3.0.2-0ubuntu1.15", ToVersion: "3.0.2-0ubuntu1.16"},
+		{Name: "openssl", Action: "upgrade", FromVersion: "3.0.2-0ubuntu1.15", ToVersion: "3.0.2-0ubuntu1.16"},
+	}, result.Packages, "dependencies are included")
+	assert.Equal(t, []string{"curl"}, result.Unchanged)
+	assert.Contains(t, result.Output, "$ apt-get -s install --only-upgrade openssl curl\n")
+}
+
+func TestApplyDnfDryRun(t *testing.T) {
+	replay := cmdrunnertest.Load(t, filepath.Join("testdata", "dnf"))
+	p := newTestPatcher(replay)
+
+	result := p.Apply(context.Background(), Request{DryRun: true})
+	assert.Empty(t, replay.Missed())
+	assert.Equal(t, models.PackageUpdateSucceeded, result.Status, "check-update exits 100 when there are updates")
+	assert.Equal(t, "dnf", result.Manager)
+	assert.Equal(t, []models.PackageTransactionItem{
+		{Name: "kernel-core", Action: "upgrade", FromVersion: "5.14.0-427.13.1.el9_4", ToVersion: "5.14.0-427.16.1.el9_4"},
+		{Name: "openssl", Action: "upgrade", FromVersion: "3.0.7-27.el9", ToVersion: "3.0.7-28.el9_4"},
+		{Name: "openssl-libs", Action: "upgrade", FromVersion: "3.0.7-27.el9", ToVersion: "3.0.7-28.el9_4"},
+		{Name: "python3-dnf-plugin-versionlock", Action: "upgrade", FromVersion: "4.3.0-13.el9", ToVersion: "4.3.0-16.el9"},
+	}, result.Packages, "wrapped lines are joined and obsoletes left out")
+}
+
+// upgradedRunner answers the installed package query with after once the
+// update has run
+type upgradedRunner struct {
+	*cmdrunnertest.Replay
+	after   string
+	updated bool
+}
+
+func (r *upgradedRunner) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if name == "rpm" && r.updated {
+		return []byte(r.after), nil
+	}
+	if len(args) > 1 && args[1] == "upgrade" {
+		r.updated = true
+	}
+	return r.Replay.Output(ctx, name, args...)
+}
+
+func TestApplyDnfUpdate(t *testing.T) {
+	replay := cmdrunnertest.Load(t, filepath.Join("testdata", "dnf"))
+	p := newTestPatcher(replay)
+	p.runner = &upgradedRunner{Replay: replay, after: strings.Join([]string{
+		"kernel-core\t5.14.0-427.13.1.el9_4",
+		"kernel-core\t5.14.0-427.16.1.el9_4",
+		"openssl\t3.0.7-28.el9_4",
+		"openssl-libs\t3.0.7-28.el9_4",
+		"python3-dnf-plugin-versionlock\t4.3.0-13.el9",
+		"sudo\t1.9.5p2-10.el9_3",
+	}, "\n") + "\n"}
+
+	result := p.Apply(context.Background(), Request{Packages: []string{"openssl", "kernel-core"}})
+	assert.Empty(t, replay.Missed())
+	require.Equal(t, models.PackageUpdateSucceeded, result.Status, result.Error)
+	assert.Equal(t, []models.PackageTransactionItem{
+		{Name: "kernel-core", Action: "install", ToVersion: "5.14.0-427.16.1.el9_4"},
+		{Name: "openssl", Action: "upgrade", FromVersion: "3.0.7-27.el9", ToVersion: "3.0.7-28.el9_4"},
+		{Name: "openssl-libs", Action: "upgrade", FromVersion: "3.0.7-27.el9", ToVersion: "3.0.7-28.el9_4"},
+	}, result.Packages, "a new kernel is installed alongside the old one")
+	assert.Empty(t, result.Unchanged)
+	assert.Contains(t, result.Output, "Complete!")
+}
+
+func TestApplyNoPackageManager(t *testing.T) {
+	p := newTestPatcher(cmdrunnertest.New())
+	result := p.Apply(context.Background(), Request{DryRun: true})
+	assert.Equal(t, models.PackageUpdateFailed, result.Status)
+	assert.Contains(t, result.Error, "no supported package manager")
+}
+
+func TestDiffInstalled(t *testing.T) {
+	before := map[string][]string{
+		"bash":   {"5.1-6"},
+		"kernel": {"6.1.0-17", "6.1.0-18"},
+		"legacy": {"1.0"},
+	}
+	after := map[string][]string{
+		"bash":    {"5.1-7"},
+		"kernel":  {"6.1.0-18", "6.1.0-20"},
+		"libnew1": {"2.0"},
+	}
+	assert.Equal(t, []models.PackageTransactionItem{
+		{Name: "bash", Action: "upgrade", FromVersion: "5.1-6", ToVersion: "5.1-7"},
+		{Name: "kernel", Action: "upgrade", FromVersion: "6.1.0-17", ToVersion: "6.1.0-20"},
+		{Name: "legacy", Action: "remove", FromVersion: "1.0"},
+		{Name: "libnew1", Action: "install", ToVersion: "2.0"},
+	}, diffInstalled(before, after))
+}
+
+func TestParseZypperUpdates(t *testing.T) {
+	out := `Loading repository data...
+Reading installed packages...
+S  | Repository            | Name        | Current Version      | Available Version    | Arch
+---+-----------------------+-------------+----------------------+----------------------+-------
+v  | Update repository     | openssl-3   | 3.1.4-150600.5.7.1   | 3.1.4-150600.5.10.1  | x86_64
+v  | Update repository     | sudo        | 1.9.15p5-150600.3.3.1 | 1.9.15p5-150600.3.6.1 | x86_64
+`
+	assert.Equal(t, []models.PackageTransactionItem{
+		{Name: "sudo", Action: "upgrade", FromVersion: "1.9.15p5-150600.3.3.1", ToVersion: "1.9.15p5-150600.3.6.1"},
+	}, parseZypperUpdates(out, nil, []string{"sudo"}))
+	assert.Len(t, parseZypperUpdates(out, nil, nil), 2)
+}
+
+func TestParsePkgUpgrade(t *testing.T) {
+	out := "Updating FreeBSD repository catalogue...\n" +
+		"FreeBSD repository is up to date.\n" +
+		"Checking for upgrades (3 candidates): 100%\n" +
+		"The following 4 package(s) will be affected (of 0 checked):\n" +
+		"\n" +
+		"New packages to be INSTALLED:\n" +
+		"\tlibidn2: 2.3.4\n" +
+		"\n" +
+		"Installed packages to be UPGRADED:\n" +
+		"\tcurl: 8.4.0 -> 8.5.0\n" +
+		"\tsudo: 1.9.14p3 -> 1.9.15p2\n" +
+		"\n" +
+		"Installed packages to be REMOVED:\n" +
+		"\toldthing: 1.0\n" +
+		"\n" +
+		"Number of packages to be installed: 1\n"
+	assert.Equal(t, []models.PackageTransactionItem{
+		{Name: "libidn2", Action: "install", ToVersion: "2.3.4"},
+		{Name: "curl", Action: "upgrade", FromVersion: "8.4.0", ToVersion: "8.5.0"},
+		{Name: "sudo", Action: "upgrade", FromVersion: "1.9.14p3", ToVersion: "1.9.15p2"},
+		{Name: "oldthing", Action: "remove", FromVersion: "1.0"},
+	}, parsePkgUpgrade(out, nil, nil))
+}
+
+func TestAppendLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "package_updates.jsonl")
+	for i := 0; i < logEntries+5; i++ {
+		require.NoError(t, AppendLog(path, &models.PackageUpdateResult{
+			CommandID: strings.Repeat("x", i%3),
+			Status:    models.PackageUpdateSucceeded,
+			Output:    "long package manager output",
+		}))
+	}
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, logEntries)
+
+	var last models.PackageUpdateResult
+	require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &last))
+	assert.Equal(t, strings.Repeat("x", (logEntries+4)%3), last.CommandID)
+	assert.Empty(t, last.Output, "the output isn't logged")
+}
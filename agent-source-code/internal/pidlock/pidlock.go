@@ -0,0 +1,82 @@
+// Package pidlock ensures only one agent service runs per host by holding an
+// exclusive lock on a pidfile for the lifetime of the process
+package pidlock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrLocked is returned when another process holds the lock
+var ErrLocked = errors.New("another patchmon-agent serve process is already running")
+
+// Lock is a held pidfile lock. The OS releases it when the process exits, so a
+// crash never leaves a stale lock behind (unlike a plain pidfile).
+type Lock struct {
+	file *os.File
+}
+
+// Acquire takes the lock at path without blocking and writes the current PID
+// into it. If another process holds it the error wraps ErrLocked and names
+// that process's PID.
+func Acquire(path string) (*Lock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pidfile: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		_ = f.Close()
+		if holder := ReadPID(path); holder > 0 {
+			return nil, fmt.Errorf("%w (pid %d, lock %s)", ErrLocked, holder, path)
+		}
+		return nil, fmt.Errorf("%w (lock %s): %v", ErrLocked, path, err)
+	}
+
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &Lock{file: f}, nil
+}
+
+// Release drops the lock. Only needed for tests and orderly shutdown.
+func (l *Lock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	_ = unlockFile(l.file)
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// ReadPID returns the PID recorded in the pidfile, or 0 if unreadable
+func ReadPID(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// IsHeld reports whether some process currently holds the lock at path
+func IsHeld(path string) bool {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if err := lockFile(f); err != nil {
+		return true
+	}
+	_ = unlockFile(f)
+	return false
+}
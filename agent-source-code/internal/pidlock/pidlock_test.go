@@ -0,0 +1,34 @@
+package pidlock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireIsExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "patchmon-agent.pid")
+
+	lock, err := Acquire(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.Getpid(), ReadPID(path))
+	assert.True(t, IsHeld(path))
+
+	// flock locks belong to the open file description, so a second open in
+	// the same process conflicts just like a second process would
+	_, err = Acquire(path)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrLocked))
+	assert.Contains(t, err.Error(), "pid")
+
+	require.NoError(t, lock.Release())
+	assert.False(t, IsHeld(path))
+
+	again, err := Acquire(path)
+	require.NoError(t, err)
+	require.NoError(t, again.Release())
+}
@@ -0,0 +1,168 @@
+// Package redact masks configured fields, patterns and IP ranges in JSON
+// payloads before they leave the host
+package redact
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// DefaultReplacement replaces masked values unless configured otherwise
+const DefaultReplacement = "[redacted]"
+
+var (
+	ipv4Candidate = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	ipv6Candidate = regexp.MustCompile(`(?i)(?:[0-9a-f]{0,4}:){2,7}(?:(?:\d{1,3}\.){3}\d{1,3}|[0-9a-f]{0,4})`)
+)
+
+// Redactor masks values in JSON documents. A nil Redactor passes documents
+// through unchanged.
+type Redactor struct {
+	fields      map[string]bool
+	patterns    []*regexp.Regexp
+	ranges      []netip.Prefix
+	replacement string
+	hash        bool
+}
+
+// New compiles cfg. It returns nil when nothing is configured, and an error
+// for an invalid pattern or range so a typo can't silently send unmasked data.
+func New(cfg *models.RedactionConfig) (*Redactor, error) {
+	if cfg == nil || (len(cfg.Fields) == 0 && len(cfg.Patterns) == 0 && len(cfg.IPRanges) == 0) {
+		return nil, nil
+	}
+	r := &Redactor{
+		fields:      make(map[string]bool, len(cfg.Fields)),
+		replacement: cfg.Replacement,
+		hash:        cfg.Hash,
+	}
+	if r.replacement == "" {
+		r.replacement = DefaultReplacement
+	}
+	for _, f := range cfg.Fields {
+		r.fields[normalizeField(f)] = true
+	}
+	for _, p := range cfg.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("redaction pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	for _, cidr := range cfg.IPRanges {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("redaction ip range %q: %w", cidr, err)
+		}
+		r.ranges = append(r.ranges, prefix.Masked())
+	}
+	return r, nil
+}
+
+// normalizeField makes machine_id, machineId and machine-id the same field
+func normalizeField(name string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(name))
+}
+
+// JSON returns body with configured values masked. Only strings are masked,
+// so numbers and booleans keep the types the server expects.
+func (r *Redactor) JSON(body []byte) ([]byte, error) {
+	if r == nil || len(body) == 0 {
+		return body, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse payload for redaction: %w", err)
+	}
+	out, err := json.Marshal(r.walk(doc, false))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal redacted payload: %w", err)
+	}
+	return out, nil
+}
+
+// Value masks v, a pointer to a JSON-serialisable value, in place
+func (r *Redactor) Value(v interface{}) error {
+	if r == nil {
+		return nil
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if body, err = r.JSON(body); err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+func (r *Redactor) walk(v interface{}, masked bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = r.walk(child, masked || r.fields[normalizeField(k)])
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = r.walk(child, masked)
+		}
+		return v
+	case string:
+		if masked {
+			return r.mask(v)
+		}
+		return r.String(v)
+	}
+	return v
+}
+
+// String masks configured patterns and IP ranges in s
+func (r *Redactor) String(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	if len(r.ranges) > 0 {
+		// IPv6 first so IPv4-mapped addresses are matched whole
+		if strings.Contains(s, ":") {
+			s = ipv6Candidate.ReplaceAllStringFunc(s, r.maskAddr)
+		}
+		s = ipv4Candidate.ReplaceAllStringFunc(s, r.maskAddr)
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllStringFunc(s, r.mask)
+	}
+	return s
+}
+
+func (r *Redactor) maskAddr(candidate string) string {
+	addr, err := netip.ParseAddr(candidate)
+	if err != nil {
+		return candidate
+	}
+	addr = addr.Unmap()
+	for _, prefix := range r.ranges {
+		if prefix.Contains(addr) {
+			return r.mask(candidate)
+		}
+	}
+	return candidate
+}
+
+func (r *Redactor) mask(s string) string {
+	if !r.hash {
+		return r.replacement
+	}
+	sum := sha256.Sum256([]byte(s))
+	return "redacted-" + hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,100 @@
+package redact
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func redactJSON(t *testing.T, cfg *models.RedactionConfig, in string) map[string]interface{} {
+	t.Helper()
+	r, err := New(cfg)
+	require.NoError(t, err)
+	out, err := r.JSON([]byte(in))
+	require.NoError(t, err)
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(out, &got))
+	return got
+}
+
+func TestFieldsAreMaskedAtAnyDepth(t *testing.T) {
+	got := redactJSON(t, &models.RedactionConfig{Fields: []string{"gateway_ip", "user", "dns-servers"}}, `{
+		"gatewayIp": "10.0.0.1",
+		"dnsServers": ["10.0.0.2", "10.0.0.3"],
+		"cpuCores": 4,
+		"accounts": [{"name": "alice", "user": "alice", "uid": 1000}]
+	}`)
+	assert.Equal(t, DefaultReplacement, got["gatewayIp"])
+	assert.Equal(t, []interface{}{DefaultReplacement, DefaultReplacement}, got["dnsServers"])
+	assert.Equal(t, float64(4), got["cpuCores"])
+	account := got["accounts"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, DefaultReplacement, account["user"])
+	assert.Equal(t, "alice", account["name"])
+	assert.Equal(t, float64(1000), account["uid"], "numbers keep their type")
+}
+
+func TestPatternsAndIPRanges(t *testing.T) {
+	got := redactJSON(t, &models.RedactionConfig{
+		Patterns:    []string{`^customer-[a-z]+-db$`, `acme\.internal`},
+		IPRanges:    []string{"10.0.0.0/8", "fd00::/8"},
+		Replacement: "***",
+	}, `{
+		"name": "customer-globex-db",
+		"other": "nginx",
+		"host": "web1.acme.internal",
+		"ip": "10.1.2.3",
+		"public": "203.0.113.7",
+		"listen": "10.0.0.5:22 and [fd00::1]:443",
+		"mapped": "::ffff:10.9.9.9",
+		"version": "1.24.0.1"
+	}`)
+	assert.Equal(t, "***", got["name"])
+	assert.Equal(t, "nginx", got["other"])
+	assert.Equal(t, "web1.***", got["host"])
+	assert.Equal(t, "***", got["ip"])
+	assert.Equal(t, "203.0.113.7", got["public"])
+	assert.Equal(t, "***:22 and [***]:443", got["listen"])
+	assert.Equal(t, "***", got["mapped"])
+	assert.Equal(t, "1.24.0.1", got["version"], "not an address in range")
+}
+
+func TestHashIsStable(t *testing.T) {
+	cfg := &models.RedactionConfig{Fields: []string{"name"}, Hash: true}
+	got := redactJSON(t, cfg, `{"a": {"name": "db-1"}, "b": {"name": "db-1"}, "c": {"name": "db-2"}}`)
+	a := got["a"].(map[string]interface{})["name"].(string)
+	assert.Regexp(t, `^redacted-[0-9a-f]{12}$`, a)
+	assert.Equal(t, a, got["b"].(map[string]interface{})["name"])
+	assert.NotEqual(t, a, got["c"].(map[string]interface{})["name"])
+}
+
+func TestNothingConfigured(t *testing.T) {
+	r, err := New(&models.RedactionConfig{Replacement: "x"})
+	require.NoError(t, err)
+	assert.Nil(t, r)
+
+	body := []byte(`{"ip":"10.0.0.1"}`)
+	out, err := r.JSON(body)
+	require.NoError(t, err)
+	assert.Equal(t, body, out)
+	assert.NoError(t, r.Value(&struct{}{}))
+}
+
+func TestInvalidConfig(t *testing.T) {
+	_, err := New(&models.RedactionConfig{Patterns: []string{"("}})
+	assert.ErrorContains(t, err, "redaction pattern")
+	_, err = New(&models.RedactionConfig{IPRanges: []string{"10.0.0.0"}})
+	assert.ErrorContains(t, err, "redaction ip range")
+}
+
+func TestValue(t *testing.T) {
+	r, err := New(&models.RedactionConfig{Fields: []string{"hostname"}})
+	require.NoError(t, err)
+	info := &models.ImageSBOMInfo{ImageID: "sha256:abc", Hostname: "web-1"}
+	require.NoError(t, r.Value(info))
+	assert.Equal(t, DefaultReplacement, info.Hostname)
+	assert.Equal(t, "sha256:abc", info.ImageID)
+}
@@ -0,0 +1,195 @@
+// Package relay forwards PatchMon API and WebSocket traffic from agents that
+// cannot reach the server, for segmented networks where only one host may talk
+// to PatchMon. Agents keep their own credentials; the relay only moves bytes.
+package relay
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/client"
+	"patchmon-agent/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Server relays agent requests to the PatchMon server
+type Server struct {
+	logger      *logrus.Logger
+	upstream    *url.URL
+	proxy       *httputil.ReverseProxy
+	authHeaders *client.AuthHeaders
+}
+
+// New creates a relay for the server in patchmon_server. The relay's own
+// auth_headers are added to forwarded requests and its own client_tls
+// certificate is presented to the server, so agents behind it need no proxy
+// credentials or server certificate of their own.
+func New(logger *logrus.Logger, cfg *models.Config) (*Server, error) {
+	upstream, err := url.Parse(strings.TrimSpace(cfg.PatchmonServer))
+	if err != nil || (upstream.Scheme != "http" && upstream.Scheme != "https") || upstream.Host == "" {
+		return nil, fmt.Errorf("the relay needs patchmon_server set to an http(s) URL, got %q", cfg.PatchmonServer)
+	}
+	if cfg.RelayURL != "" {
+		return nil, errors.New("relay_url is set: a relay must reach the server directly")
+	}
+
+	skipVerify := cfg.SkipSSLVerify || client.IsSkipSSLVerifyEnvSet()
+	if skipVerify {
+		// Operator-gated insecure TLS for lab/air-gapped deployments.
+		logger.Warn("TLS verification disabled for relayed requests")
+	}
+	upstreamTLS, err := client.ServerTLSConfig(cfg, skipVerify)
+	if err != nil {
+		return nil, err
+	}
+	if upstreamTLS == nil {
+		upstreamTLS = &tls.Config{InsecureSkipVerify: skipVerify}
+	}
+	s := &Server{
+		logger:      logger,
+		upstream:    upstream,
+		authHeaders: client.NewAuthHeaders(cfg.AuthHeaders),
+	}
+	s.proxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(upstream)
+			pr.SetXForwarded()
+		},
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         utils.DialContext(30 * time.Second),
+			TLSClientConfig:     upstreamTLS,
+			MaxIdleConnsPerHost: 16,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+				s.authHeaders.Invalidate()
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			s.logger.WithError(err).WithField("path", r.URL.Path).Warn("Relay could not reach the PatchMon server")
+			http.Error(w, "relay could not reach the PatchMon server", http.StatusBadGateway)
+		},
+	}
+	return s, nil
+}
+
+// Handler returns the relay's routes. Only the API and the unauthenticated
+// health check are forwarded, so the relay can't be used to reach anything
+// else the server hosts.
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// TCP listeners ask for a certificate without requiring one, so that
+		// connectivity probes can complete a handshake; requests need one
+		if r.TLS != nil && len(r.TLS.VerifiedChains) == 0 {
+			http.Error(w, "client certificate required", http.StatusUnauthorized)
+			return
+		}
+		// Only clean paths are matched, so /api/../admin can't reach past
+		// the prefix after the server resolves it
+		if path.Clean(r.URL.Path) != r.URL.Path || (r.URL.Path != "/health" && !strings.HasPrefix(r.URL.Path, "/api/")) {
+			http.NotFound(w, r)
+			return
+		}
+		if err := s.authHeaders.Apply(r.Context(), r.Header); err != nil {
+			s.logger.WithError(err).Error("Relay auth_headers failed")
+			http.Error(w, "relay auth_headers failed", http.StatusBadGateway)
+			return
+		}
+		s.logger.WithFields(logrus.Fields{
+			"agent":  peerName(r),
+			"method": r.Method,
+			"path":   r.URL.Path,
+		}).Debug("Relaying request")
+		s.proxy.ServeHTTP(w, r)
+	})
+}
+
+// peerName identifies the agent behind a request for the log: the client
+// certificate's common name, or "unix" for the socket
+func peerName(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return "unix"
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName
+}
+
+// Listen opens relay.listen: "unix", "unix:/path.sock" or "host:port". A
+// socket is given to relay.group when set. TCP listeners serve
+// relay.cert_file and accept only agents whose client certificate was signed
+// by relay.ca_file.
+func Listen(rc *models.RelayConfig) (net.Listener, error) {
+	if path, ok := client.SocketPath(rc.Listen); ok {
+		// Agents in containers on this host reach it via relay.group
+		return utils.ListenUnix(path, rc.Group)
+	}
+
+	if _, _, err := net.SplitHostPort(rc.Listen); err != nil {
+		return nil, fmt.Errorf("invalid relay.listen %q: %w", rc.Listen, err)
+	}
+	if rc.CertFile == "" || rc.KeyFile == "" || rc.CAFile == "" {
+		return nil, errors.New("relay.listen on TCP needs relay.cert_file, relay.key_file and relay.ca_file")
+	}
+	// Fail now rather than at the first handshake
+	if _, err := tls.LoadX509KeyPair(rc.CertFile, rc.KeyFile); err != nil {
+		return nil, fmt.Errorf("failed to load relay certificate: %w", err)
+	}
+	pool, err := client.LoadCertPool(rc.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", rc.Listen)
+	if err != nil {
+		return nil, err
+	}
+	return tls.NewListener(ln, &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  pool,
+		// Reread on each handshake so a renewed certificate needs no restart
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(rc.CertFile, rc.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load relay certificate: %w", err)
+			}
+			return &cert, nil
+		},
+	}), nil
+}
+
+// Serve relays requests on ln until ctx is cancelled. There is no write
+// timeout: agents hold their WebSocket open through the relay.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	s.logger.WithFields(logrus.Fields{
+		"address":  ln.Addr().String(),
+		"upstream": s.upstream.Host,
+	}).Info("Relay listening")
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package relay
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/client"
+	"patchmon-agent/internal/config"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string
+}
+
+// newTestCA writes a self-signed CA to dir/ca.pem
+func newTestCA(t *testing.T, dir string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Relay Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	ca := &testCA{cert: cert, key: key, file: filepath.Join(dir, "ca.pem")}
+	require.NoError(t, os.WriteFile(ca.file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	return ca
+}
+
+// issue writes a certificate for cn, valid for 127.0.0.1, and its key to dir
+func (ca *testCA) issue(t *testing.T, dir, cn string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certFile, keyFile = filepath.Join(dir, cn+".pem"), filepath.Join(dir, cn+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+// startRelay runs a relay for upstream on rc.Listen until the test ends
+func startRelay(t *testing.T, upstream string, rc *models.RelayConfig) net.Listener {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	srv, err := New(logger, &models.Config{
+		PatchmonServer: upstream,
+		AuthHeaders:    &models.AuthHeadersConfig{Headers: map[string]string{"CF-Access-Client-Id": "bastion"}},
+	})
+	require.NoError(t, err)
+	ln, err := Listen(rc)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = srv.Serve(ctx, ln) }()
+	return ln
+}
+
+// enrollThrough sends an enroll request, which needs no credentials, with an
+// agent config adjusted by configure
+func enrollThrough(t *testing.T, configure func(*models.Config)) error {
+	t.Helper()
+	mgr := config.New()
+	mgr.GetConfig().PatchmonServer = "https://unreachable.invalid"
+	configure(mgr.GetConfig())
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	_, err := client.New(mgr, logger).Enroll(context.Background(), &models.EnrollRequest{Code: "ABC123"})
+	return err
+}
+
+func TestRelayMutualTLS(t *testing.T) {
+	var got *http.Request
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"apiId":"id","apiKey":"key"}`))
+	}))
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	ca := newTestCA(t, dir)
+	serverCert, serverKey := ca.issue(t, dir, "relay")
+	agentCert, agentKey := ca.issue(t, dir, "web-01")
+	ln := startRelay(t, upstream.URL, &models.RelayConfig{Listen: "127.0.0.1:0", CertFile: serverCert, KeyFile: serverKey, CAFile: ca.file})
+	relayURL := "https://" + ln.Addr().String()
+
+	err := enrollThrough(t, func(cfg *models.Config) {
+		cfg.RelayURL = relayURL
+		cfg.Relay = &models.RelayConfig{CertFile: agentCert, KeyFile: agentKey, CAFile: ca.file}
+	})
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "/api/v1/hosts/enroll", got.URL.Path)
+	assert.Equal(t, "bastion", got.Header.Get("CF-Access-Client-Id"), "the relay adds its own auth_headers")
+	assert.Equal(t, "127.0.0.1", got.Header.Get("X-Forwarded-For"))
+
+	err = enrollThrough(t, func(cfg *models.Config) { cfg.RelayURL = relayURL })
+	assert.ErrorContains(t, err, "relay.cert_file", "an agent without a client certificate is refused before sending")
+
+	// A client that skips the agent's checks still gets nothing through
+	pool, err := client.LoadCertPool(ca.file)
+	require.NoError(t, err)
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := anonymous.Get(relayURL + "/api/v1/hosts/enroll")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestRelayUnixSocket(t *testing.T) {
+	var paths []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		_, _ = w.Write([]byte(`{"apiId":"id","apiKey":"key"}`))
+	}))
+	defer upstream.Close()
+
+	// Socket paths are limited to about 100 bytes, which t.TempDir can exceed
+	dir, err := os.MkdirTemp("", "relay")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	socket := filepath.Join(dir, "run", "relay.sock")
+	startRelay(t, upstream.URL, &models.RelayConfig{Listen: "unix:" + socket, Group: strconv.Itoa(os.Getgid())})
+
+	info, err := os.Stat(socket)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o660), info.Mode().Perm())
+	info, err = os.Stat(filepath.Dir(socket))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o750), info.Mode().Perm())
+
+	require.NoError(t, enrollThrough(t, func(cfg *models.Config) { cfg.RelayURL = "unix:" + socket }))
+
+	cfg := &models.Config{RelayURL: "unix:" + socket}
+	c := &http.Client{Transport: &http.Transport{DialContext: client.RelayDialContext(cfg)}}
+	resp, err := c.Get(client.ServerURL(cfg) + "/admin")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "only the API is relayed")
+
+	// Dot segments and doubled slashes are refused rather than resolved
+	for _, p := range []string{"/api/../admin", "/api/v1/../../admin", "/api//v1/hosts/enroll", "/health/."} {
+		resp, err := c.Get(client.ServerURL(cfg) + p)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, p)
+	}
+	assert.Equal(t, []string{"/api/v1/hosts/enroll"}, paths)
+}
+
+func TestListenUnknownGroup(t *testing.T) {
+	dir, err := os.MkdirTemp("", "relay")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	_, err = Listen(&models.RelayConfig{Listen: "unix:" + filepath.Join(dir, "relay.sock"), Group: "no-such-group-patchmon"})
+	assert.ErrorContains(t, err, "no-such-group-patchmon")
+}
+
+func TestListenRequiresCertificates(t *testing.T) {
+	_, err := Listen(&models.RelayConfig{Listen: "127.0.0.1:0"})
+	assert.ErrorContains(t, err, "relay.ca_file")
+	_, err = New(logrus.New(), &models.Config{PatchmonServer: "https://patchmon.example.com", RelayURL: "unix"})
+	assert.Error(t, err, "a relay can't itself be relayed")
+	_, err = New(logrus.New(), &models.Config{})
+	assert.Error(t, err)
+}
@@ -7,8 +7,8 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 	"patchmon-agent/internal/constants"
-	"patchmon-agent/pkg/models"
 
 	"github.com/sirupsen/logrus"
 )
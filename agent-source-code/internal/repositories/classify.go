@@ -0,0 +1,305 @@
+package repositories
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/constants"
+)
+
+// sourcePattern maps a host (or host/path prefix) or repository ID token to a
+// classification
+type sourcePattern struct {
+	match  string
+	class  string
+	vendor string
+}
+
+// hostPatterns match the repository URL's host or one of its parent domains;
+// a path after the host must also start the URL's path
+var hostPatterns = []sourcePattern{
+	// Distributions
+	{"archive.ubuntu.com", constants.RepoClassDistro, "Ubuntu"},
+	{"security.ubuntu.com", constants.RepoClassDistro, "Ubuntu"},
+	{"ports.ubuntu.com", constants.RepoClassDistro, "Ubuntu"},
+	{"esm.ubuntu.com", constants.RepoClassDistro, "Ubuntu"},
+	{"deb.debian.org", constants.RepoClassDistro, "Debian"},
+	{"security.debian.org", constants.RepoClassDistro, "Debian"},
+	{"ftp.debian.org", constants.RepoClassDistro, "Debian"},
+	{"debian.org", constants.RepoClassDistro, "Debian"},
+	{"raspbian.org", constants.RepoClassDistro, "Raspbian"},
+	{"archive.raspberrypi.com", constants.RepoClassDistro, "Raspberry Pi"},
+	{"archive.raspberrypi.org", constants.RepoClassDistro, "Raspberry Pi"},
+	{"packages.linuxmint.com", constants.RepoClassDistro, "Linux Mint"},
+	{"download.proxmox.com", constants.RepoClassDistro, "Proxmox"},
+	{"enterprise.proxmox.com", constants.RepoClassDistro, "Proxmox"},
+	{"fedoraproject.org", constants.RepoClassDistro, "Fedora"},
+	{"dl.rockylinux.org", constants.RepoClassDistro, "Rocky Linux"},
+	{"repo.almalinux.org", constants.RepoClassDistro, "AlmaLinux"},
+	{"centos.org", constants.RepoClassDistro, "CentOS"},
+	{"cdn.redhat.com", constants.RepoClassDistro, "Red Hat"},
+	{"yum.oracle.com", constants.RepoClassDistro, "Oracle Linux"},
+	{"amazonlinux.com", constants.RepoClassDistro, "Amazon Linux"},
+	{"download.opensuse.org", constants.RepoClassDistro, "openSUSE"},
+	{"alpinelinux.org", constants.RepoClassDistro, "Alpine"},
+	{"archlinux.org", constants.RepoClassDistro, "Arch Linux"},
+	{"pkg.freebsd.org", constants.RepoClassDistro, "FreeBSD"},
+	{"update.microsoft.com", constants.RepoClassDistro, "Microsoft"},
+
+	// Vendors
+	{"download.docker.com", constants.RepoClassVendor, "Docker"},
+	{"grafana.com", constants.RepoClassVendor, "Grafana"},
+	{"postgresql.org", constants.RepoClassVendor, "PostgreSQL"},
+	{"packages.microsoft.com", constants.RepoClassVendor, "Microsoft"},
+	{"dl.google.com", constants.RepoClassVendor, "Google"},
+	{"packages.cloud.google.com", constants.RepoClassVendor, "Google Cloud"},
+	{"repo.mysql.com", constants.RepoClassVendor, "MySQL"},
+	{"mariadb.org", constants.RepoClassVendor, "MariaDB"},
+	{"mariadb.com", constants.RepoClassVendor, "MariaDB"},
+	{"repo.mongodb.org", constants.RepoClassVendor, "MongoDB"},
+	{"repo.percona.com", constants.RepoClassVendor, "Percona"},
+	{"nginx.org", constants.RepoClassVendor, "NGINX"},
+	{"nodesource.com", constants.RepoClassVendor, "NodeSource"},
+	{"releases.hashicorp.com", constants.RepoClassVendor, "HashiCorp"},
+	{"artifacts.elastic.co", constants.RepoClassVendor, "Elastic"},
+	{"repo.zabbix.com", constants.RepoClassVendor, "Zabbix"},
+	{"packages.gitlab.com", constants.RepoClassVendor, "GitLab"},
+	{"pkg.jenkins.io", constants.RepoClassVendor, "Jenkins"},
+	{"pkgs.k8s.io", constants.RepoClassVendor, "Kubernetes"},
+	{"apt.kubernetes.io", constants.RepoClassVendor, "Kubernetes"},
+	{"download.virtualbox.org", constants.RepoClassVendor, "Oracle VirtualBox"},
+	{"packages.sury.org", constants.RepoClassVendor, "Sury"},
+	{"ppa.launchpadcontent.net", constants.RepoClassVendor, "Launchpad PPA"},
+	{"ppa.launchpad.net", constants.RepoClassVendor, "Launchpad PPA"},
+	{"packagecloud.io", constants.RepoClassVendor, "Packagecloud"},
+	{"rpms.remirepo.net", constants.RepoClassVendor, "Remi"},
+	{"dl.fedoraproject.org/pub/epel", constants.RepoClassVendor, "EPEL"},
+	{"download.copr.fedorainfracloud.org", constants.RepoClassVendor, "Fedora COPR"},
+	{"repos.influxdata.com", constants.RepoClassVendor, "InfluxData"},
+	{"packages.redis.io", constants.RepoClassVendor, "Redis"},
+	{"deb.nodesource.com", constants.RepoClassVendor, "NodeSource"},
+	{"packages.wazuh.com", constants.RepoClassVendor, "Wazuh"},
+	{"repo.saltproject.io", constants.RepoClassVendor, "Salt Project"},
+	{"apt.puppet.com", constants.RepoClassVendor, "Puppet"},
+	{"yum.puppet.com", constants.RepoClassVendor, "Puppet"},
+	{"pkg.tailscale.com", constants.RepoClassVendor, "Tailscale"},
+}
+
+// repoIDPatterns are matched against repository IDs/names when there is no URL
+// to go on (dnf repo IDs, apk/pacman logical repo names, Windows sources). Each
+// must be a whole token of the ID, optionally followed by a version number, so
+// remi matches remi-php81 but not premium.
+var repoIDPatterns = []sourcePattern{
+	{"docker-ce", constants.RepoClassVendor, "Docker"},
+	{"grafana", constants.RepoClassVendor, "Grafana"},
+	{"pgdg", constants.RepoClassVendor, "PostgreSQL"},
+	{"epel", constants.RepoClassVendor, "EPEL"},
+	{"remi", constants.RepoClassVendor, "Remi"},
+	{"nodesource", constants.RepoClassVendor, "NodeSource"},
+	{"hashicorp", constants.RepoClassVendor, "HashiCorp"},
+	{"elastic", constants.RepoClassVendor, "Elastic"},
+	{"elasticsearch", constants.RepoClassVendor, "Elastic"},
+	{"mongodb", constants.RepoClassVendor, "MongoDB"},
+	{"mariadb", constants.RepoClassVendor, "MariaDB"},
+	{"mysql", constants.RepoClassVendor, "MySQL"},
+	{"nginx", constants.RepoClassVendor, "NGINX"},
+	{"zabbix", constants.RepoClassVendor, "Zabbix"},
+	{"gitlab", constants.RepoClassVendor, "GitLab"},
+	{"kubernetes", constants.RepoClassVendor, "Kubernetes"},
+	{"copr:", constants.RepoClassVendor, "Fedora COPR"},
+	{"winget", constants.RepoClassVendor, "WinGet"},
+	{"msstore", constants.RepoClassVendor, "Microsoft Store"},
+}
+
+// mirrorDirs are the directories distribution mirrors publish under
+// (mirror.example.edu/debian), checked when no host pattern matched
+var mirrorDirs = []struct{ dir, vendor string }{
+	{"ubuntu", "Ubuntu"}, {"ubuntu-ports", "Ubuntu"}, {"debian", "Debian"}, {"debian-security", "Debian"},
+	{"centos", "CentOS"}, {"rocky", "Rocky Linux"}, {"almalinux", "AlmaLinux"}, {"fedora", "Fedora"},
+	{"alpine", "Alpine"}, {"archlinux", "Arch Linux"},
+}
+
+// distroRepoIDs are well-known official repository IDs across distributions
+var distroRepoIDs = map[string]bool{
+	// dnf/yum
+	"baseos": true, "appstream": true, "extras": true, "crb": true, "powertools": true,
+	"base": true, "updates": true, "fedora": true, "updates-testing": true, "highavailability": true,
+	"resilientstorage": true, "nfv": true, "rt": true, "devel": true, "plus": true,
+	"amzn2-core": true, "amazonlinux": true, "ol8_baseos_latest": true, "ol9_baseos_latest": true,
+	"ol8_appstream": true, "ol9_appstream": true,
+	// apk
+	"main": true, "community": true, "testing": true,
+	// pacman
+	"core": true, "extra": true, "multilib": true, "core-testing": true, "extra-testing": true,
+	// FreeBSD
+	"freebsd": true, "freebsd-kmods": true, "freebsd-base": true,
+	// Windows
+	"microsoft update": true, "windows update": true,
+}
+
+// Classify classifies a repository from its URL and name. Returns the class
+// (see constants.RepoClass*) and, when known, the vendor or distribution name.
+func Classify(repoURL, name string) (class, vendor string) {
+	if class, vendor, ok := classifyURL(repoURL); ok {
+		return class, vendor
+	}
+	return classifyID(name)
+}
+
+// ClassifySource classifies a package's SourceRepository string, which depending
+// on the package manager is either "<url> <suite>/<component>" (apt) or a repo ID
+func ClassifySource(source string) (class, vendor string) {
+	if source == "" {
+		return "", ""
+	}
+	first, _, _ := strings.Cut(source, " ")
+	if class, vendor, ok := classifyURL(first); ok {
+		return class, vendor
+	}
+	return classifyID(source)
+}
+
+// ClassifyRepositories sets Classification and Vendor on each repository
+func ClassifyRepositories(repos []models.Repository) {
+	for i := range repos {
+		repos[i].Classification, repos[i].Vendor = Classify(repos[i].URL, repos[i].Name)
+	}
+}
+
+// TagPackages sets SourceClassification and SourceVendor on each package with a
+// known SourceRepository. Repository names from repos take precedence so a
+// package attributed to a classified repo ID inherits that repo's URL-based class.
+func TagPackages(pkgs []models.Package, repos []models.Repository) {
+	byName := make(map[string]models.Repository, len(repos))
+	for _, r := range repos {
+		byName[strings.ToLower(r.Name)] = r
+	}
+
+	for i := range pkgs {
+		source := pkgs[i].SourceRepository
+		if source == "" || source == "unknown" || source == "local" {
+			continue
+		}
+		if r, ok := byName[strings.ToLower(source)]; ok && r.Classification != "" {
+			pkgs[i].SourceClassification, pkgs[i].SourceVendor = r.Classification, r.Vendor
+			continue
+		}
+		pkgs[i].SourceClassification, pkgs[i].SourceVendor = ClassifySource(source)
+	}
+}
+
+func classifyURL(raw string) (class, vendor string, ok bool) {
+	if !strings.Contains(raw, "://") {
+		return "", "", false
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "", "", false
+	}
+	host := strings.ToLower(u.Hostname())
+	path := strings.ToLower(u.Path)
+
+	// Path-qualified patterns (EPEL lives under dl.fedoraproject.org) must be
+	// checked before the plain host suffixes that would otherwise claim them
+	for _, p := range hostPatterns {
+		if patternHost, patternPath, ok := strings.Cut(p.match, "/"); ok && hostMatches(host, patternHost) && pathUnder(path, "/"+patternPath) {
+			return p.class, p.vendor, true
+		}
+	}
+	for _, p := range hostPatterns {
+		if !strings.Contains(p.match, "/") && hostMatches(host, p.match) {
+			return p.class, p.vendor, true
+		}
+	}
+	if vendor, ok := mirrorVendor(host, path); ok {
+		return constants.RepoClassDistro, vendor, true
+	}
+	return constants.RepoClassCustom, "", true
+}
+
+// hostMatches reports whether host is domain or one of its subdomains
+func hostMatches(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// pathUnder reports whether path is dir or inside it
+func pathUnder(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// mirrorVendor recognises a distribution mirror: a host that looks like one
+// (mirror.example.edu, ftp.example.org, ubuntu.example.net) serving one of
+// mirrorDirs at the top of its path or under /pub or /mirror(s). A vendor's
+// own repo.example.com/debian is left as custom.
+func mirrorVendor(host, path string) (string, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) > 1 && (segments[0] == "pub" || segments[0] == "mirror" || segments[0] == "mirrors") {
+		segments = segments[1:]
+	}
+	for _, m := range mirrorDirs {
+		if segments[0] != m.dir {
+			continue
+		}
+		distro, _, _ := strings.Cut(m.dir, "-")
+		for _, label := range strings.Split(host, ".") {
+			if strings.Contains(label, "mirror") || strings.HasPrefix(label, "ftp") || strings.Contains(label, distro) {
+				return m.vendor, true
+			}
+		}
+	}
+	return "", false
+}
+
+func classifyID(name string) (class, vendor string) {
+	id := strings.ToLower(strings.TrimSpace(name))
+	if id == "" {
+		return constants.RepoClassCustom, ""
+	}
+	for _, p := range repoIDPatterns {
+		if containsToken(id, p.match) {
+			return p.class, p.vendor
+		}
+	}
+	if distroRepoIDs[id] {
+		return constants.RepoClassDistro, ""
+	}
+	// dnf IDs like "rhel-9-for-x86_64-baseos-rpms" or "ubi-9-appstream-rpms"
+	for _, suffix := range []string{"-baseos-rpms", "-appstream-rpms", "-supplementary-rpms", "-codeready-builder-rpms"} {
+		if strings.HasSuffix(id, suffix) {
+			return constants.RepoClassDistro, "Red Hat"
+		}
+	}
+	return constants.RepoClassCustom, ""
+}
+
+// containsToken reports whether tok occurs in id between non-alphanumeric
+// characters or the ends of id. A version number may follow a token ending in
+// a letter or digit, as in pgdg16 or mysql80-community.
+func containsToken(id, tok string) bool {
+	for i := 0; i+len(tok) <= len(id); i++ {
+		j := strings.Index(id[i:], tok)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(tok)
+		i = start
+		if start > 0 && isAlnum(id[start-1]) {
+			continue
+		}
+		if !isAlnum(tok[len(tok)-1]) {
+			return true
+		}
+		for end < len(id) && id[end] >= '0' && id[end] <= '9' {
+			end++
+		}
+		if end == len(id) || !isAlnum(id[end]) {
+			return true
+		}
+	}
+	return false
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/constants"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		repo   string
+		class  string
+		vendor string
+	}{
+		{"ubuntu archive", "http://archive.ubuntu.com/ubuntu", "archive-ubuntu-jammy", constants.RepoClassDistro, "Ubuntu"},
+		{"country mirror", "http://gb.archive.ubuntu.com/ubuntu", "", constants.RepoClassDistro, "Ubuntu"},
+		{"university mirror", "https://mirror.example.edu/debian", "", constants.RepoClassDistro, "Debian"},
+		{"docker", "https://download.docker.com/linux/ubuntu", "", constants.RepoClassVendor, "Docker"},
+		{"grafana", "https://apt.grafana.com", "", constants.RepoClassVendor, "Grafana"},
+		{"pgdg", "http://apt.postgresql.org/pub/repos/apt", "", constants.RepoClassVendor, "PostgreSQL"},
+		{"epel before fedora", "https://dl.fedoraproject.org/pub/epel/9/Everything/x86_64/", "epel", constants.RepoClassVendor, "EPEL"},
+		{"internal", "https://apt.corp.example.com/internal", "", constants.RepoClassCustom, ""},
+		{"dnf baseos id", "", "baseos", constants.RepoClassDistro, ""},
+		{"dnf rhel id", "", "rhel-9-for-x86_64-baseos-rpms", constants.RepoClassDistro, "Red Hat"},
+		{"dnf docker id", "", "docker-ce-stable", constants.RepoClassVendor, "Docker"},
+		{"alpine community", "", "community", constants.RepoClassDistro, ""},
+		{"unknown id", "", "acme-tools", constants.RepoClassCustom, ""},
+		{"mirror under pub", "https://ftp.example.org/pub/ubuntu", "", constants.RepoClassDistro, "Ubuntu"},
+		{"dnf remi id", "", "remi-php81", constants.RepoClassVendor, "Remi"},
+		{"dnf versioned pgdg id", "", "pgdg16", constants.RepoClassVendor, "PostgreSQL"},
+		{"dnf versioned mysql id", "", "mysql80-community", constants.RepoClassVendor, "MySQL"},
+		{"copr id", "", "copr:copr.fedorainfracloud.org:user:project", constants.RepoClassVendor, "Fedora COPR"},
+
+		// Near misses
+		{"remi inside a word", "", "acme-premium", constants.RepoClassCustom, ""},
+		{"epel inside a word", "", "kepler", constants.RepoClassCustom, ""},
+		{"nginx inside a word", "", "mynginxtools", constants.RepoClassCustom, ""},
+		{"vendor repo with a debian path", "https://repo.acme.example.com/debian", "", constants.RepoClassCustom, ""},
+		{"mirror path that only starts like a distro", "https://mirror.example.edu/debianish", "", constants.RepoClassCustom, ""},
+		{"distro dir deeper in the path", "https://mirror.example.edu/tools/debian", "", constants.RepoClassCustom, ""},
+		{"epel path on another host", "https://mirror.example.com/dl.fedoraproject.org/pub/epel/9/", "", constants.RepoClassCustom, ""},
+		{"look-alike host", "https://dl.fedoraproject.org.example.com/pub/epel/9/", "", constants.RepoClassCustom, ""},
+		{"epel prefix of another dir", "https://dl.fedoraproject.org/pub/epelx/", "", constants.RepoClassDistro, "Fedora"},
+		{"host suffix without a dot", "https://notdocker.com/linux", "", constants.RepoClassCustom, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class, vendor := Classify(tt.url, tt.repo)
+			assert.Equal(t, tt.class, class)
+			assert.Equal(t, tt.vendor, vendor)
+		})
+	}
+}
+
+func TestTagPackages(t *testing.T) {
+	repos := []models.Repository{
+		{Name: "internal-tools", URL: "https://download.docker.com/linux/centos/9/x86_64/stable"},
+	}
+	ClassifyRepositories(repos)
+
+	pkgs := []models.Package{
+		{Name: "bash", SourceRepository: "http://deb.debian.org/debian bookworm/main"},
+		{Name: "docker-ce", SourceRepository: "internal-tools"},
+		{Name: "local-thing", SourceRepository: "unknown"},
+		{Name: "orphan"},
+	}
+	TagPackages(pkgs, repos)
+
+	assert.Equal(t, constants.RepoClassDistro, pkgs[0].SourceClassification)
+	assert.Equal(t, "Debian", pkgs[0].SourceVendor)
+	assert.Equal(t, constants.RepoClassVendor, pkgs[1].SourceClassification, "repo ID resolves via the repo's URL")
+	assert.Equal(t, "Docker", pkgs[1].SourceVendor)
+	assert.Empty(t, pkgs[2].SourceClassification)
+	assert.Empty(t, pkgs[3].SourceClassification)
+}
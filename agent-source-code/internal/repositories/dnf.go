@@ -6,8 +6,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 	"patchmon-agent/internal/constants"
-	"patchmon-agent/pkg/models"
 
 	"github.com/sirupsen/logrus"
 )
@@ -179,6 +179,11 @@ func (d *DNFManager) parseRepoFile(filename string) ([]models.Repository, error)
 func (d *DNFManager) processRepoEntry(entry *repoEntry) []models.Repository {
 	var repositories []models.Repository
 
+	// A "[]" header has no repo ID for the server to key on
+	if entry.id == "" {
+		return repositories
+	}
+
 	// Check if repository is enabled (defaults to true per dnf.conf(5))
 	isEnabled := true
 	if entry.enabled != nil {
@@ -8,8 +8,9 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 	"patchmon-agent/internal/constants"
-	"patchmon-agent/pkg/models"
+	"patchmon-agent/internal/utils"
 
 	"github.com/sirupsen/logrus"
 )
@@ -69,13 +70,16 @@ func (m *FreeBSDManager) GetRepositories() ([]models.Repository, error) {
 // getPkgRepositories parses pkg -vv output to get repository information
 // This is the most reliable method as it shows resolved/active repositories
 func (m *FreeBSDManager) getPkgRepositories() ([]models.Repository, error) {
-	var repositories []models.Repository
-
-	cmd := exec.Command(m.getPkgPath(), "-vv")
-	output, err := cmd.Output()
+	output, err := utils.Command(m.getPkgPath(), "-vv").Output()
 	if err != nil {
 		return nil, err
 	}
+	return m.parsePkgVerbose(string(output)), nil
+}
+
+// parsePkgVerbose reads the repositories from pkg -vv output
+func (m *FreeBSDManager) parsePkgVerbose(output string) []models.Repository {
+	var repositories []models.Repository
 
 	// Parse the Repositories: section
 	// Format:
@@ -95,7 +99,7 @@ func (m *FreeBSDManager) getPkgRepositories() ([]models.Repository, error) {
 	urlRegex := regexp.MustCompile(`url\s*:\s*"([^"]+)"`)
 	enabledRegex := regexp.MustCompile(`enabled\s*:\s*(yes|no)`)
 
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	scanner := bufio.NewScanner(strings.NewReader(output))
 	for scanner.Scan() {
 		line := scanner.Text()
 
@@ -162,7 +166,7 @@ func (m *FreeBSDManager) getPkgRepositories() ([]models.Repository, error) {
 		repositories = append(repositories, *currentRepo)
 	}
 
-	return repositories, nil
+	return repositories
 }
 
 // parseConfigFiles parses pkg configuration files directly
@@ -225,7 +229,10 @@ func (m *FreeBSDManager) parseConfigFile(filename string) ([]models.Repository,
 			continue
 		}
 
-		// New repository block
+		// New repository block. The whole block may be on one line, as in
+		// the usual override "FreeBSD: { enabled: no }", so the rest of the
+		// line is parsed too.
+		header := false
 		if matches := repoNameRegex.FindStringSubmatch(line); len(matches) >= 2 {
 			if currentRepo != nil {
 				repositories = append(repositories, *currentRepo)
@@ -235,7 +242,7 @@ func (m *FreeBSDManager) parseConfigFile(filename string) ([]models.Repository,
 				RepoType:  constants.RepoTypeFreeBSD,
 				IsEnabled: true, // Default to enabled
 			}
-			continue
+			header = true
 		}
 
 		if currentRepo == nil {
@@ -257,7 +264,7 @@ func (m *FreeBSDManager) parseConfigFile(filename string) ([]models.Repository,
 		}
 
 		// End of block
-		if line == "}" {
+		if line == "}" || (header && strings.HasSuffix(line, "}")) {
 			if currentRepo != nil {
 				repositories = append(repositories, *currentRepo)
 				currentRepo = nil
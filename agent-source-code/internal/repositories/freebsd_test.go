@@ -1,6 +1,8 @@
 package repositories
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/sirupsen/logrus"
@@ -60,3 +62,28 @@ func TestExtractDistributionFromURL(t *testing.T) {
 		})
 	}
 }
+
+func TestParseConfigFileOneLineBlock(t *testing.T) {
+	manager := NewFreeBSDManager(logrus.New())
+	path := filepath.Join(t.TempDir(), "FreeBSD.conf")
+	conf := `FreeBSD: { enabled: no }
+local: { url: "pkg+https://pkg.example.com/FreeBSD:14:amd64/latest", enabled: yes }
+`
+	if err := os.WriteFile(path, []byte(conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repos, err := manager.parseConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 {
+		t.Fatalf("got %d repositories, want 2", len(repos))
+	}
+	if repos[0].Name != "FreeBSD" || repos[0].IsEnabled {
+		t.Errorf("FreeBSD repo should be disabled: %+v", repos[0])
+	}
+	if repos[1].URL != "https://pkg.example.com/FreeBSD:14:amd64/latest" || !repos[1].IsEnabled || repos[1].Distribution != "FreeBSD:14:amd64" {
+		t.Errorf("unexpected local repo: %+v", repos[1])
+	}
+}
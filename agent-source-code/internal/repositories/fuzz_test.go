@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/constants"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The fuzz targets feed repository configuration through the parsers. Every
+// repository reported must have a name and a URL, and no field may span lines.
+
+func fuzzLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// fuzzFile writes a fuzz input to a file for the parsers that take a path
+func fuzzFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func checkFuzzedRepos(t *testing.T, repos []models.Repository) {
+	t.Helper()
+	for _, repo := range repos {
+		if repo.Name == "" || repo.URL == "" {
+			t.Fatalf("incomplete repository %+v", repo)
+		}
+		for _, field := range []string{repo.Name, repo.URL, repo.Distribution, repo.Components, repo.RepoType} {
+			if strings.ContainsAny(field, "\r\n") {
+				t.Fatalf("repository field spans lines: %q", field)
+			}
+		}
+	}
+}
+
+func FuzzAPTSourcesList(f *testing.F) {
+	f.Add("deb http://archive.ubuntu.com/ubuntu jammy main restricted\n# comment\ndeb-src [arch=amd64 signed-by=/usr/share/keyrings/x.gpg] https://download.docker.com/linux/ubuntu jammy stable\n")
+	f.Add("deb [arch=amd64 http://example.com/ /\ndeb http://example.com/ ./ \n")
+	m := NewAPTManager(fuzzLogger())
+	f.Fuzz(func(t *testing.T, content string) {
+		repos, _ := m.parseSourcesList(fuzzFile(t, "sources.list", content))
+		checkFuzzedRepos(t, repos)
+	})
+}
+
+func FuzzAPTDEB822(f *testing.F) {
+	f.Add("Types: deb deb-src\nURIs: http://deb.debian.org/debian\nSuites: bookworm bookworm-updates\nComponents: main\nSigned-By: /usr/share/keyrings/debian-archive-keyring.gpg\n\nTypes: deb\nURIs: http://example.com/flat\nSuites: ./\nEnabled: no\n")
+	f.Add("X-Repolib-Name: My Repo\nURIs: https://ppa.launchpadcontent.net/x/y/ubuntu\nSuites: noble\nComponents: main\n")
+	m := NewAPTManager(fuzzLogger())
+	f.Fuzz(func(t *testing.T, content string) {
+		repos, _ := m.parseDEB822Sources(fuzzFile(t, "x.sources", content))
+		checkFuzzedRepos(t, repos)
+	})
+}
+
+func FuzzDNFRepoFile(f *testing.F) {
+	f.Add("[baseos]\nname=Rocky Linux $releasever - BaseOS\nmirrorlist=https://mirrors.rockylinux.org/mirrorlist?arch=$basearch&repo=BaseOS-$releasever\n#baseurl=http://dl.rockylinux.org/$contentdir/$releasever/BaseOS/$basearch/os/\nenabled=1\n\n[epel]\nbaseurl=https://dl.fedoraproject.org/pub/epel/9/Everything/x86_64/ https://mirror.example.com/epel/9/\nenabled=0\n")
+	f.Add("[]\nbaseurl=\n[x]\nmetalink=https://mirrors.fedoraproject.org/metalink?repo=epel-9\n")
+	m := NewDNFManager(fuzzLogger())
+	f.Fuzz(func(t *testing.T, content string) {
+		repos, _ := m.parseRepoFile(fuzzFile(t, "x.repo", content))
+		checkFuzzedRepos(t, repos)
+	})
+}
+
+func FuzzAPKRepoFile(f *testing.F) {
+	f.Add("http://dl-cdn.alpinelinux.org/alpine/v3.19/main\n@edge https://dl-cdn.alpinelinux.org/alpine/edge/testing\n#http://example.com/alpine/v3.19/community\n")
+	f.Add("https://\n@ http:///\n")
+	m := NewAPKManager(fuzzLogger())
+	f.Fuzz(func(t *testing.T, content string) {
+		repos, _ := m.parseRepoFile(fuzzFile(t, "repositories", content))
+		checkFuzzedRepos(t, repos)
+	})
+}
+
+func FuzzFreeBSDConfigFile(f *testing.F) {
+	f.Add("FreeBSD: {\n  url: \"pkg+https://pkg.FreeBSD.org/${ABI}/quarterly\",\n  mirror_type: \"srv\",\n  enabled: yes\n}\n")
+	f.Add("FreeBSD: { enabled: no }\n")
+	m := NewFreeBSDManager(fuzzLogger())
+	f.Fuzz(func(t *testing.T, content string) {
+		repos, _ := m.parseConfigFile(fuzzFile(t, "FreeBSD.conf", content))
+		for _, repo := range repos {
+			if repo.Name == "" || strings.ContainsAny(repo.Name+repo.URL, "\r\n") {
+				t.Fatalf("bad repository %+v", repo)
+			}
+		}
+	})
+}
+
+func FuzzFreeBSDPkgVerbose(f *testing.F) {
+	f.Add("Version                 : 1.21.3\nRepositories:\n  FreeBSD: { \n    url             : \"pkg+https://pkg.FreeBSD.org/FreeBSD:14:amd64/quarterly\",\n    enabled         : yes,\n    priority        : 0,\n  }\n  local: {\n    url             : \"file:///var/pkg\",\n    enabled         : no\n  }\n")
+	m := NewFreeBSDManager(fuzzLogger())
+	f.Fuzz(func(t *testing.T, output string) {
+		for _, repo := range m.parsePkgVerbose(output) {
+			if repo.Name == "" || strings.ContainsAny(repo.Name+repo.URL, "\r\n") {
+				t.Fatalf("bad repository %+v", repo)
+			}
+		}
+	})
+}
+
+func FuzzPacmanMirrorList(f *testing.F) {
+	f.Add("## Worldwide\nServer = https://geo.mirror.pkgbuild.com/$repo/os/$arch\n#Server = http://mirror.example.com/$repo/os/$arch\nserver=https://mirror.example.org/archlinux/$repo/os/$arch\n")
+	m := NewPacmanManager(fuzzLogger())
+	f.Fuzz(func(t *testing.T, content string) {
+		checkFuzzedRepos(t, m.parseMirrorList(fuzzFile(t, "mirrorlist", content), "core"))
+	})
+}
+
+func FuzzClassify(f *testing.F) {
+	f.Add("http://archive.ubuntu.com/ubuntu", "ubuntu-jammy")
+	f.Add("https://download.docker.com/linux/ubuntu", "docker")
+	f.Add("mirror+file:///etc/apt/mirrors.txt", "")
+	f.Add("%zz://", "x")
+	f.Fuzz(func(t *testing.T, repoURL, name string) {
+		class, _ := Classify(repoURL, name)
+		switch class {
+		case constants.RepoClassDistro, constants.RepoClassVendor, constants.RepoClassCustom:
+		default:
+			t.Fatalf("unknown class %q", class)
+		}
+	})
+}
@@ -6,7 +6,8 @@ import (
 	"runtime"
 	"strings"
 
-	"patchmon-agent/pkg/models"
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/utils"
 
 	"github.com/sirupsen/logrus"
 )
@@ -76,7 +77,7 @@ func (m *Manager) detectPackageManager() string {
 		}
 	}
 	if _, err := exec.LookPath("pkg"); err == nil {
-		if output, err := exec.Command("uname", "-s").Output(); err == nil {
+		if output, err := utils.Command("uname", "-s").Output(); err == nil {
 			if strings.TrimSpace(string(output)) == "FreeBSD" {
 				return "pkg"
 			}
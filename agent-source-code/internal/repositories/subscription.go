@@ -0,0 +1,279 @@
+package repositories
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/utils"
+)
+
+// subscriptionTimeout bounds each subscription tool run; subscription-manager
+// may call home and is slow when the entitlement server is unreachable
+const subscriptionTimeout = 60 * time.Second
+
+// rhsmConsumerCert exists once a host is registered with subscription-manager
+var rhsmConsumerCert = "/etc/pki/consumer/cert.pem"
+
+// gatedHosts serve repositories only to hosts with an active subscription
+var gatedHosts = []string{
+	"cdn.redhat.com",
+	"updates.suse.com",
+	"esm.ubuntu.com",
+}
+
+// GetSubscription reports the host's subscription-manager, SUSEConnect or
+// Ubuntu Pro state, or nil when none of them is installed
+func (m *Manager) GetSubscription() *models.SubscriptionStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), subscriptionTimeout)
+	defer cancel()
+
+	switch {
+	case lookPath("subscription-manager"):
+		return m.rhsmStatus(ctx)
+	case lookPath("SUSEConnect"):
+		return m.suseConnectStatus(ctx)
+	case lookPath("pro"):
+		return m.ubuntuProStatus(ctx, "pro")
+	case lookPath("ua"):
+		return m.ubuntuProStatus(ctx, "ua")
+	}
+	return nil
+}
+
+func lookPath(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+func (m *Manager) rhsmStatus(ctx context.Context) *models.SubscriptionStatus {
+	sub := &models.SubscriptionStatus{Provider: models.SubscriptionRHSM}
+	// Unregistered hosts are answered from the missing certificate;
+	// subscription-manager status would only say the same thing slowly
+	if _, err := os.Stat(rhsmConsumerCert); err != nil {
+		sub.Status = "Not registered"
+		return sub
+	}
+	sub.Registered = true
+
+	// status exits 1 for an invalid subscription, which still prints a status
+	out, err := utils.CommandContext(ctx, "subscription-manager", "status").Output()
+	if len(out) == 0 && err != nil {
+		m.logger.WithError(err).Debug("subscription-manager status failed")
+		sub.Error = fmt.Sprintf("subscription-manager status: %v", err)
+		return sub
+	}
+	var sca bool
+	sub.Status, sca = parseRHSMStatus(string(out))
+	sub.Entitled = sca || sub.Status == "Current"
+
+	if out, err := utils.CommandContext(ctx, "subscription-manager", "list", "--installed").Output(); err == nil {
+		sub.Products = parseRHSMInstalled(string(out))
+	} else {
+		m.logger.WithError(err).Debug("subscription-manager list --installed failed")
+	}
+	return sub
+}
+
+// parseRHSMStatus returns the Overall Status from subscription-manager status
+// and whether Simple Content Access is on. With SCA the overall status reads
+// "Disabled" yet every repository is available.
+func parseRHSMStatus(output string) (status string, sca bool) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if value, ok := strings.CutPrefix(line, "Overall Status:"); ok {
+			status = strings.TrimSpace(value)
+		}
+		if strings.Contains(line, "Simple Content Access") {
+			sca = true
+		}
+	}
+	return status, sca
+}
+
+// parseRHSMInstalled parses the product blocks of subscription-manager list
+// --installed
+func parseRHSMInstalled(output string) []models.SubscriptionProduct {
+	var products []models.SubscriptionProduct
+	var current *models.SubscriptionProduct
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "Product Name":
+			if value == "" {
+				current = nil
+				continue
+			}
+			products = append(products, models.SubscriptionProduct{Name: value})
+			current = &products[len(products)-1]
+		case "Status":
+			if current != nil {
+				current.Status = value
+			}
+		case "Ends":
+			if current != nil {
+				current.ExpiresAt = value
+			}
+		}
+	}
+	return products
+}
+
+func (m *Manager) suseConnectStatus(ctx context.Context) *models.SubscriptionStatus {
+	sub := &models.SubscriptionStatus{Provider: models.SubscriptionSUSEConnect}
+	out, err := utils.CommandContext(ctx, "SUSEConnect", "--status").Output()
+	if err != nil {
+		m.logger.WithError(err).Debug("SUSEConnect --status failed")
+		sub.Error = fmt.Sprintf("SUSEConnect --status: %v", err)
+		return sub
+	}
+	if err := parseSUSEConnectStatus(out, sub); err != nil {
+		sub.Error = err.Error()
+	}
+	return sub
+}
+
+// parseSUSEConnectStatus fills sub from SUSEConnect --status, a JSON list
+// with the base product first
+func parseSUSEConnectStatus(out []byte, sub *models.SubscriptionStatus) error {
+	var products []struct {
+		Identifier         string `json:"identifier"`
+		Version            string `json:"version"`
+		Status             string `json:"status"`
+		SubscriptionStatus string `json:"subscription_status"`
+		ExpiresAt          string `json:"expires_at"`
+	}
+	if err := json.Unmarshal(out, &products); err != nil {
+		return fmt.Errorf("failed to parse SUSEConnect --status: %w", err)
+	}
+	if len(products) == 0 {
+		return nil
+	}
+
+	sub.Status = products[0].Status
+	sub.Registered = products[0].Status == "Registered"
+	sub.Entitled = sub.Registered
+	for _, p := range products {
+		name := p.Identifier
+		if p.Version != "" {
+			name += " " + p.Version
+		}
+		status := p.Status
+		if p.SubscriptionStatus != "" {
+			status = p.SubscriptionStatus
+		}
+		sub.Products = append(sub.Products, models.SubscriptionProduct{Name: name, Status: status, ExpiresAt: p.ExpiresAt})
+		if p.Status == "Registered" && strings.EqualFold(p.SubscriptionStatus, "EXPIRED") {
+			sub.Entitled = false
+		}
+	}
+	return nil
+}
+
+func (m *Manager) ubuntuProStatus(ctx context.Context, tool string) *models.SubscriptionStatus {
+	sub := &models.SubscriptionStatus{Provider: models.SubscriptionUbuntuPro}
+	out, err := utils.CommandContext(ctx, tool, "status", "--format", "json").Output()
+	if err != nil && len(out) == 0 {
+		m.logger.WithError(err).Debug("Ubuntu Pro status failed")
+		sub.Error = fmt.Sprintf("%s status: %v", tool, err)
+		return sub
+	}
+	if err := parseUbuntuProStatus(out, sub); err != nil {
+		sub.Error = err.Error()
+	}
+	return sub
+}
+
+// parseUbuntuProStatus fills sub from pro status --format json
+func parseUbuntuProStatus(out []byte, sub *models.SubscriptionStatus) error {
+	var status struct {
+		Attached bool   `json:"attached"`
+		Expires  string `json:"expires"`
+		Services []struct {
+			Name   string `json:"name"`
+			Status string `json:"status"`
+		} `json:"services"`
+	}
+	if err := json.Unmarshal(out, &status); err != nil {
+		return fmt.Errorf("failed to parse Ubuntu Pro status: %w", err)
+	}
+
+	sub.Registered = status.Attached
+	sub.Entitled = status.Attached
+	sub.Status = "not attached"
+	if !status.Attached {
+		return nil
+	}
+	sub.Status = "attached"
+	for _, svc := range status.Services {
+		if svc.Status == "enabled" {
+			sub.Products = append(sub.Products, models.SubscriptionProduct{Name: svc.Name, Status: svc.Status, ExpiresAt: status.Expires})
+		}
+	}
+	return nil
+}
+
+// MarkEntitlementGated sets EntitlementGated on repositories served only to
+// subscribed hosts
+func MarkEntitlementGated(repos []models.Repository) {
+	for i := range repos {
+		repos[i].EntitlementGated = isGatedURL(repos[i].URL)
+	}
+}
+
+func isGatedURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, gated := range gatedHosts {
+		if host == gated || strings.HasSuffix(host, "."+gated) {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscriptionWarning explains why the update list can't be trusted when the
+// host lacks the subscription its repositories need, or returns "". RHEL and
+// SLES get their updates only through the subscription; on Ubuntu only the
+// Pro repositories that are configured are affected.
+func SubscriptionWarning(sub *models.SubscriptionStatus, repos []models.Repository) string {
+	if sub == nil || sub.Entitled || sub.Error != "" {
+		return ""
+	}
+
+	var gated []string
+	for _, r := range repos {
+		if r.EntitlementGated && r.IsEnabled {
+			gated = append(gated, r.Name)
+		}
+	}
+
+	switch sub.Provider {
+	case models.SubscriptionRHSM, models.SubscriptionSUSEConnect:
+		if !sub.Registered {
+			return fmt.Sprintf("host is not registered with %s; vendor updates, including security updates, are not being reported", sub.Provider)
+		}
+		return fmt.Sprintf("%s subscription status is %q; vendor updates, including security updates, may not be reported", sub.Provider, sub.Status)
+	case models.SubscriptionUbuntuPro:
+		if len(gated) > 0 {
+			return fmt.Sprintf("Ubuntu Pro is not attached but its repositories are configured (%s); their updates are not being reported", strings.Join(gated, ", "))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRHSMStatus(t *testing.T) {
+	status, sca := parseRHSMStatus(`+-------------------------------------------+
+   System Status Details
++-------------------------------------------+
+Overall Status: Current
+
+System Purpose Status: Not Specified
+`)
+	assert.Equal(t, "Current", status)
+	assert.False(t, sca)
+
+	status, sca = parseRHSMStatus(`Overall Status: Disabled
+Content Access Mode is set to Simple Content Access. This host has access to content, regardless of subscription status.
+`)
+	assert.Equal(t, "Disabled", status)
+	assert.True(t, sca, "SCA hosts are entitled despite the Disabled status")
+}
+
+func TestParseRHSMInstalled(t *testing.T) {
+	products := parseRHSMInstalled(`+-------------------------------------------+
+    Installed Product Status
++-------------------------------------------+
+Product Name:   Red Hat Enterprise Linux for x86_64
+Product ID:     479
+Version:        9.4
+Arch:           x86_64
+Status:         Subscribed
+Status Details:
+Starts:         01/01/2026
+Ends:           01/01/2027
+
+Product Name:   Red Hat CodeReady Linux Builder for x86_64
+Product ID:     486
+Status:         Not Subscribed
+`)
+	assert.Equal(t, []models.SubscriptionProduct{
+		{Name: "Red Hat Enterprise Linux for x86_64", Status: "Subscribed", ExpiresAt: "01/01/2027"},
+		{Name: "Red Hat CodeReady Linux Builder for x86_64", Status: "Not Subscribed"},
+	}, products)
+}
+
+func TestParseSUSEConnectStatus(t *testing.T) {
+	sub := &models.SubscriptionStatus{}
+	require.NoError(t, parseSUSEConnectStatus([]byte(`[
+		{"identifier":"SLES","version":"15.5","arch":"x86_64","status":"Registered","subscription_status":"ACTIVE","expires_at":"2027-01-01 00:00:00 UTC"},
+		{"identifier":"sle-module-basesystem","version":"15.5","arch":"x86_64","status":"Registered"}
+	]`), sub))
+	assert.True(t, sub.Registered)
+	assert.True(t, sub.Entitled)
+	assert.Equal(t, "Registered", sub.Status)
+	require.Len(t, sub.Products, 2)
+	assert.Equal(t, models.SubscriptionProduct{Name: "SLES 15.5", Status: "ACTIVE", ExpiresAt: "2027-01-01 00:00:00 UTC"}, sub.Products[0])
+
+	expired := &models.SubscriptionStatus{}
+	require.NoError(t, parseSUSEConnectStatus([]byte(`[{"identifier":"SLES","version":"15.5","status":"Registered","subscription_status":"EXPIRED"}]`), expired))
+	assert.True(t, expired.Registered)
+	assert.False(t, expired.Entitled)
+
+	unregistered := &models.SubscriptionStatus{}
+	require.NoError(t, parseSUSEConnectStatus([]byte(`[{"identifier":"SLES","version":"15.5","status":"Not Registered"}]`), unregistered))
+	assert.False(t, unregistered.Registered)
+	assert.False(t, unregistered.Entitled)
+}
+
+func TestParseUbuntuProStatus(t *testing.T) {
+	sub := &models.SubscriptionStatus{}
+	require.NoError(t, parseUbuntuProStatus([]byte(`{"attached": true, "expires": "2030-01-01T00:00:00+00:00",
+		"services": [{"name": "esm-infra", "entitled": "yes", "status": "enabled"},
+		             {"name": "fips", "entitled": "yes", "status": "disabled"}]}`), sub))
+	assert.True(t, sub.Entitled)
+	assert.Equal(t, "attached", sub.Status)
+	assert.Equal(t, []models.SubscriptionProduct{{Name: "esm-infra", Status: "enabled", ExpiresAt: "2030-01-01T00:00:00+00:00"}}, sub.Products)
+
+	detached := &models.SubscriptionStatus{}
+	require.NoError(t, parseUbuntuProStatus([]byte(`{"attached": false, "services": []}`), detached))
+	assert.False(t, detached.Entitled)
+	assert.Equal(t, "not attached", detached.Status)
+}
+
+func TestSubscriptionWarning(t *testing.T) {
+	repos := []models.Repository{
+		{Name: "rhel-9-for-x86_64-baseos-rpms", URL: "https://cdn.redhat.com/content/dist/rhel9/9/x86_64/baseos/os", IsEnabled: true},
+		{Name: "epel", URL: "https://dl.fedoraproject.org/pub/epel/9/Everything/x86_64/", IsEnabled: true},
+	}
+	MarkEntitlementGated(repos)
+	assert.True(t, repos[0].EntitlementGated)
+	assert.False(t, repos[1].EntitlementGated)
+
+	assert.Empty(t, SubscriptionWarning(nil, repos))
+	assert.Empty(t, SubscriptionWarning(&models.SubscriptionStatus{Provider: models.SubscriptionRHSM, Registered: true, Entitled: true}, repos))
+	assert.Contains(t, SubscriptionWarning(&models.SubscriptionStatus{Provider: models.SubscriptionRHSM}, nil), "not registered",
+		"an unregistered RHEL host has no vendor repositories at all")
+	assert.Contains(t, SubscriptionWarning(&models.SubscriptionStatus{Provider: models.SubscriptionRHSM, Registered: true, Status: "Invalid"}, repos), `"Invalid"`)
+
+	esm := []models.Repository{{Name: "esm-infra", URL: "https://esm.ubuntu.com/infra/ubuntu", IsEnabled: true}}
+	MarkEntitlementGated(esm)
+	detached := &models.SubscriptionStatus{Provider: models.SubscriptionUbuntuPro, Status: "not attached"}
+	assert.Empty(t, SubscriptionWarning(detached, nil), "Ubuntu without Pro repositories is fine unattached")
+	assert.Contains(t, SubscriptionWarning(detached, esm), "esm-infra")
+}
@@ -6,8 +6,8 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
 	"patchmon-agent/internal/constants"
-	"patchmon-agent/pkg/models"
 
 	"github.com/sirupsen/logrus"
 )
@@ -0,0 +1,82 @@
+// Package secrets holds integration secrets pushed by the PatchMon server.
+// The server encrypts each value to the agent's public key (a NaCl sealed box,
+// libsodium crypto_box_seal), so secrets never appear in config.yml or in
+// server-side logs in plaintext. Values stay sealed on disk and are opened only
+// when the owning integration asks for them.
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"patchmon-agent/internal/utils"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+)
+
+// Key is the agent's X25519 key pair
+type Key struct {
+	public  [32]byte
+	private [32]byte
+}
+
+// LoadOrCreateKey reads the agent key from path, generating and saving a new
+// one (owner-only permissions) if the file doesn't exist
+func LoadOrCreateKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return parseKey(strings.TrimSpace(string(data)))
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read agent key: %w", err)
+	}
+
+	public, private, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate agent key: %w", err)
+	}
+	k := &Key{public: *public, private: *private}
+	encoded := base64.StdEncoding.EncodeToString(k.private[:]) + "\n"
+	if err := utils.WriteFileAtomic(path, []byte(encoded), 0600); err != nil {
+		return nil, fmt.Errorf("failed to save agent key: %w", err)
+	}
+	return k, nil
+}
+
+func parseKey(encoded string) (*Key, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) != 32 {
+		return nil, fmt.Errorf("invalid agent key")
+	}
+	k := &Key{}
+	copy(k.private[:], raw)
+	public, err := curve25519.X25519(k.private[:], curve25519.Basepoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid agent key: %w", err)
+	}
+	copy(k.public[:], public)
+	return k, nil
+}
+
+// PublicKey returns the base64 public key the server encrypts secrets to
+func (k *Key) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(k.public[:])
+}
+
+// Open decrypts a base64 sealed box addressed to this key
+func (k *Key) Open(sealed string) ([]byte, error) {
+	ct, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("secret is not valid base64: %w", err)
+	}
+	plain, ok := box.OpenAnonymous(nil, ct, &k.public, &k.private)
+	if !ok {
+		return nil, errors.New("secret was not encrypted to this agent's key")
+	}
+	return plain, nil
+}
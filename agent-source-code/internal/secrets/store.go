@@ -0,0 +1,149 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+
+	"patchmon-agent/internal/utils"
+)
+
+// ErrNotFound is returned when an integration has no secret by that name
+var ErrNotFound = errors.New("secret not found")
+
+// validName limits integration and secret names to simple identifiers
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
+// Store is the local keystore: sealed secrets grouped by integration
+type Store struct {
+	path string
+	key  *Key
+
+	mu      sync.RWMutex
+	secrets map[string]map[string]string // integration -> name -> sealed value
+}
+
+// OpenStore loads the keystore at path. A missing file is an empty store.
+func OpenStore(path string, key *Key) (*Store, error) {
+	s := &Store{path: path, key: key, secrets: map[string]map[string]string{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets store: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets store: %w", err)
+	}
+	return s, nil
+}
+
+// Update applies a server push for one integration: set adds or replaces
+// sealed values, remove deletes names. Every value must open with the agent key
+// and every name must be valid, or nothing is changed.
+func (s *Store) Update(integration string, set map[string]string, remove []string) error {
+	if !validName.MatchString(integration) {
+		return fmt.Errorf("invalid integration name %q", integration)
+	}
+	for name, sealed := range set {
+		if !validName.MatchString(name) {
+			return fmt.Errorf("invalid secret name %q", name)
+		}
+		if _, err := s.key.Open(sealed); err != nil {
+			return fmt.Errorf("secret %s: %w", name, err)
+		}
+	}
+	for _, name := range remove {
+		if !validName.MatchString(name) {
+			return fmt.Errorf("invalid secret name %q", name)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := s.secrets[integration]
+	updated := make(map[string]string, len(previous)+len(set))
+	for name, sealed := range previous {
+		updated[name] = sealed
+	}
+	for name, sealed := range set {
+		updated[name] = sealed
+	}
+	for _, name := range remove {
+		delete(updated, name)
+	}
+	if len(updated) == 0 {
+		delete(s.secrets, integration)
+	} else {
+		s.secrets[integration] = updated
+	}
+	if err := s.save(); err != nil {
+		if previous == nil {
+			delete(s.secrets, integration)
+		} else {
+			s.secrets[integration] = previous
+		}
+		return err
+	}
+	return nil
+}
+
+// save writes the store; callers hold s.mu
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.secrets, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal secrets store: %w", err)
+	}
+	if err := utils.WriteFileAtomic(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to save secrets store: %w", err)
+	}
+	return nil
+}
+
+// Names lists the secret names held for each integration, without values
+func (s *Store) Names() map[string][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string][]string, len(s.secrets))
+	for integration, values := range s.secrets {
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		out[integration] = names
+	}
+	return out
+}
+
+// For returns the view of the store an integration is given. It can only read
+// that integration's secrets.
+func (s *Store) For(integration string) *Scope {
+	return &Scope{store: s, integration: integration}
+}
+
+// Scope is one integration's read-only access to its secrets
+type Scope struct {
+	store       *Store
+	integration string
+}
+
+// Get decrypts and returns the named secret
+func (sc *Scope) Get(name string) (string, error) {
+	sc.store.mu.RLock()
+	sealed, ok := sc.store.secrets[sc.integration][name]
+	sc.store.mu.RUnlock()
+	if !ok {
+		return "", ErrNotFound
+	}
+	plain, err := sc.store.key.Open(sealed)
+	if err != nil {
+		return "", fmt.Errorf("secret %s/%s: %w", sc.integration, name, err)
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,86 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/nacl/box"
+)
+
+// seal encrypts value to k the way the server does
+func seal(t *testing.T, k *Key, value string) string {
+	t.Helper()
+	ct, err := box.SealAnonymous(nil, []byte(value), &k.public, rand.Reader)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(ct)
+}
+
+func TestLoadOrCreateKeyPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent_key")
+	k1, err := LoadOrCreateKey(path)
+	require.NoError(t, err)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	if os.PathSeparator == '/' {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	k2, err := LoadOrCreateKey(path)
+	require.NoError(t, err)
+	assert.Equal(t, k1.PublicKey(), k2.PublicKey())
+}
+
+func TestStoreScopesSecretsByIntegration(t *testing.T) {
+	dir := t.TempDir()
+	key, err := LoadOrCreateKey(filepath.Join(dir, "agent_key"))
+	require.NoError(t, err)
+	store, err := OpenStore(filepath.Join(dir, "secrets.json"), key)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Update("docker", map[string]string{"registry_password": seal(t, key, "hunter2")}, nil))
+	require.NoError(t, store.Update("mqtt", map[string]string{"password": seal(t, key, "mqtt-pass")}, nil))
+
+	got, err := store.For("docker").Get("registry_password")
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", got)
+
+	_, err = store.For("docker").Get("password")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Values stay sealed on disk
+	data, err := os.ReadFile(filepath.Join(dir, "secrets.json"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "hunter2")
+
+	reopened, err := OpenStore(filepath.Join(dir, "secrets.json"), key)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"docker": {"registry_password"}, "mqtt": {"password"}}, reopened.Names())
+
+	require.NoError(t, reopened.Update("mqtt", nil, []string{"password"}))
+	assert.Equal(t, map[string][]string{"docker": {"registry_password"}}, reopened.Names())
+}
+
+func TestStoreRejectsForeignCiphertext(t *testing.T) {
+	dir := t.TempDir()
+	key, err := LoadOrCreateKey(filepath.Join(dir, "agent_key"))
+	require.NoError(t, err)
+	other, err := LoadOrCreateKey(filepath.Join(dir, "other_key"))
+	require.NoError(t, err)
+	store, err := OpenStore(filepath.Join(dir, "secrets.json"), key)
+	require.NoError(t, err)
+
+	err = store.Update("docker", map[string]string{
+		"good": seal(t, key, "a"),
+		"bad":  seal(t, other, "b"),
+	}, nil)
+	require.Error(t, err)
+	assert.Empty(t, store.Names(), "a failed update must not store anything")
+
+	assert.Error(t, store.Update("../etc", map[string]string{"x": seal(t, key, "a")}, nil))
+}
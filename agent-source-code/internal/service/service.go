@@ -0,0 +1,189 @@
+// Package service installs and manages the init system unit that runs
+// `patchmon-agent serve`
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// InitSystem identifies the service manager on this host
+type InitSystem string
+
+const (
+	Systemd InitSystem = "systemd"
+	OpenRC  InitSystem = "openrc"
+	RCd     InitSystem = "rc.d"
+	None    InitSystem = ""
+)
+
+const (
+	systemdUnitPath = "/etc/systemd/system/patchmon-agent.service"
+	openrcInitPath  = "/etc/init.d/patchmon-agent"
+	rcdScriptPath   = "/usr/local/etc/rc.d/patchmon_agent"
+	agentPathToken  = "@AGENT@"
+)
+
+// The unit files match the ones written by patchmon_install.sh
+const systemdUnit = `[Unit]
+Description=PatchMon Agent Service
+After=network.target
+Wants=network.target
+
+[Service]
+Type=simple
+User=root
+ExecStart=@AGENT@ serve
+Restart=always
+RestartSec=10
+WorkingDirectory=/etc/patchmon
+
+# Logging
+StandardOutput=journal
+StandardError=journal
+SyslogIdentifier=patchmon-agent
+
+[Install]
+WantedBy=multi-user.target
+`
+
+const openrcScript = `#!/sbin/openrc-run
+
+name="patchmon-agent"
+description="PatchMon Agent Service"
+command="@AGENT@"
+command_args="serve"
+command_user="root"
+pidfile="/var/run/patchmon-agent.pid"
+supervisor=supervise-daemon
+supervise_daemon_args="--chdir /etc/patchmon"
+respawn_delay=10
+respawn_max=5
+respawn_period=60
+
+depend() {
+    need net
+    after net
+}
+`
+
+const rcdScript = `#!/bin/sh
+# PROVIDE: patchmon_agent
+# REQUIRE: NETWORK
+# KEYWORD: nojail
+
+. /etc/rc.subr
+
+name="patchmon_agent"
+rcvar="${name}_enable"
+pidfile="/var/run/${name}.pid"
+
+start_cmd="${name}_start"
+stop_cmd="${name}_stop"
+status_cmd="${name}_status"
+
+patchmon_agent_start()
+{
+    echo "Starting ${name}."
+    /usr/sbin/daemon -f -P ${pidfile} -r @AGENT@ serve
+}
+
+patchmon_agent_stop()
+{
+    if [ -f ${pidfile} ]; then
+        echo "Stopping ${name}."
+        kill $(cat ${pidfile}) 2>/dev/null
+        rm -f ${pidfile}
+    else
+        echo "${name} is not running."
+    fi
+}
+
+patchmon_agent_status()
+{
+    if [ -f ${pidfile} ] && kill -0 $(cat ${pidfile}) 2>/dev/null; then
+        echo "${name} is running as pid $(cat ${pidfile})."
+    else
+        echo "${name} is not running."
+        return 1
+    fi
+}
+
+load_rc_config $name
+run_rc_command "$1"
+`
+
+// Detect returns the init system that should run the agent, or None
+func Detect() InitSystem {
+	if runtime.GOOS == "freebsd" {
+		return RCd
+	}
+	if _, err := exec.LookPath("systemctl"); err == nil {
+		if _, err := os.Stat("/run/systemd/system"); err == nil {
+			return Systemd
+		}
+	}
+	if _, err := exec.LookPath("rc-service"); err == nil {
+		return OpenRC
+	}
+	return None
+}
+
+// Install writes the service definition for init (unless one already exists,
+// so local customisations survive), enables it at boot and (re)starts it. It
+// returns the path of the service definition and whether it was written.
+func Install(ctx context.Context, init InitSystem, agentPath string) (string, bool, error) {
+	var path, content string
+	var mode os.FileMode = 0644
+	switch init {
+	case Systemd:
+		path, content = systemdUnitPath, systemdUnit
+	case OpenRC:
+		path, content, mode = openrcInitPath, openrcScript, 0755
+	case RCd:
+		path, content, mode = rcdScriptPath, rcdScript, 0755
+	default:
+		return "", false, errors.New("no supported init system found (systemd, OpenRC or FreeBSD rc.d)")
+	}
+
+	written := false
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile(path, []byte(strings.ReplaceAll(content, agentPathToken, agentPath)), mode); err != nil {
+			return path, false, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		written = true
+	} else if err != nil {
+		return path, false, err
+	}
+
+	var steps [][]string
+	switch init {
+	case Systemd:
+		steps = [][]string{
+			{"systemctl", "daemon-reload"},
+			{"systemctl", "enable", "patchmon-agent.service"},
+			{"systemctl", "restart", "patchmon-agent.service"},
+		}
+	case OpenRC:
+		steps = [][]string{
+			{"rc-update", "add", "patchmon-agent", "default"},
+			{"rc-service", "patchmon-agent", "restart"},
+		}
+	case RCd:
+		steps = [][]string{
+			{"sysrc", "patchmon_agent_enable=YES"},
+			{"service", "patchmon_agent", "restart"},
+		}
+	}
+	for _, args := range steps {
+		if out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput(); err != nil {
+			return path, written, fmt.Errorf("%s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+		}
+	}
+	return path, written, nil
+}
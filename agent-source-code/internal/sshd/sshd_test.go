@@ -0,0 +1,58 @@
+package sshd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sshdTOutput = `port 22
+port 2222
+addressfamily any
+listenaddress [::]:22
+listenaddress 0.0.0.0:22
+usepam yes
+logingracetime 120
+x11forwarding yes
+maxauthtries 6
+pubkeyauthentication yes
+kbdinteractiveauthentication no
+passwordauthentication yes
+permitemptypasswords no
+permitrootlogin without-password
+allowtcpforwarding yes
+allowusers alice bob@10.0.0.0/8
+ciphers chacha20-poly1305@openssh.com,aes256-gcm@openssh.com,aes128-cbc
+macs umac-128-etm@openssh.com,hmac-sha2-256-etm@openssh.com,hmac-sha1
+kexalgorithms sntrup761x25519-sha512@openssh.com,curve25519-sha256,diffie-hellman-group14-sha1
+hostkeyalgorithms ssh-ed25519-cert-v01@openssh.com,ssh-ed25519
+`
+
+func TestParse(t *testing.T) {
+	cfg := Parse([]byte(sshdTOutput))
+
+	assert.Equal(t, []int{22, 2222}, cfg.Ports)
+	assert.Equal(t, []string{"[::]:22", "0.0.0.0:22"}, cfg.ListenAddresses)
+	assert.Equal(t, "without-password", cfg.PermitRootLogin)
+	assert.True(t, cfg.PasswordAuthentication)
+	assert.False(t, cfg.KbdInteractiveAuthentication)
+	assert.True(t, cfg.PubkeyAuthentication)
+	assert.False(t, cfg.PermitEmptyPasswords)
+	assert.True(t, cfg.X11Forwarding)
+	assert.Equal(t, "yes", cfg.AllowTCPForwarding)
+	assert.Equal(t, 6, cfg.MaxAuthTries)
+	assert.Equal(t, 120, cfg.LoginGraceTime)
+	assert.Equal(t, []string{"alice", "bob@10.0.0.0/8"}, cfg.AllowUsers)
+	require.Len(t, cfg.Ciphers, 3)
+	assert.Equal(t, "hmac-sha2-256-etm@openssh.com", cfg.MACs[1])
+	assert.Len(t, cfg.HostKeyAlgorithms, 2)
+	assert.Equal(t, []string{"aes128-cbc", "diffie-hellman-group14-sha1"}, cfg.WeakAlgorithms)
+}
+
+func TestParseOldOpenSSH(t *testing.T) {
+	cfg := Parse([]byte("challengeresponseauthentication yes\nport 22\n"))
+	assert.True(t, cfg.KbdInteractiveAuthentication)
+	assert.NotNil(t, cfg.Ciphers, "lists marshal as [] rather than null")
+	assert.Empty(t, cfg.WeakAlgorithms)
+}
@@ -0,0 +1,63 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/host"
+)
+
+// bootIDPath holds the kernel's random per-boot UUID on Linux
+var bootIDPath = "/proc/sys/kernel/random/boot_id"
+
+// bootTimeIDPrefix marks a boot ID made up from the boot time, on systems
+// without a kernel boot ID
+const bootTimeIDPrefix = "boottime:"
+
+// bootTimeJitter is how far the boot time may move between reads on systems
+// that derive it from the uptime
+const bootTimeJitter = 2
+
+// GetBootID returns an identifier for the current boot and when it started.
+// Linux has a per-boot UUID; elsewhere the boot time stands in for it.
+func (d *Detector) GetBootID() (string, time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	bootSecs, err := host.BootTimeWithContext(ctx)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to get boot time: %w", err)
+	}
+	bootTime := time.Unix(int64(bootSecs), 0)
+
+	if data, err := os.ReadFile(bootIDPath); err == nil {
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id, bootTime, nil
+		}
+	}
+	return bootTimeIDPrefix + strconv.FormatUint(bootSecs, 10), bootTime, nil
+}
+
+// SameBoot reports whether two IDs from GetBootID name the same boot. IDs
+// made up from the boot time match when they are within a couple of seconds.
+func SameBoot(a, b string) bool {
+	if a == b {
+		return true
+	}
+	as, aok := strings.CutPrefix(a, bootTimeIDPrefix)
+	bs, bok := strings.CutPrefix(b, bootTimeIDPrefix)
+	if !aok || !bok {
+		return false
+	}
+	at, err1 := strconv.ParseInt(as, 10, 64)
+	bt, err2 := strconv.ParseInt(bs, 10, 64)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	diff := at - bt
+	return diff >= -bootTimeJitter && diff <= bootTimeJitter
+}
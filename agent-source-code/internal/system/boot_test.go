@@ -0,0 +1,16 @@
+package system
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSameBoot(t *testing.T) {
+	assert.True(t, SameBoot("6f1c2a5e-0d3b-4f5a-9c1e-2b7d8e9f0a1b", "6f1c2a5e-0d3b-4f5a-9c1e-2b7d8e9f0a1b"))
+	assert.False(t, SameBoot("6f1c2a5e-0d3b-4f5a-9c1e-2b7d8e9f0a1b", "0a9b8c7d-6e5f-4a3b-2c1d-0e9f8a7b6c5d"))
+	assert.True(t, SameBoot("boottime:1760000000", "boottime:1760000001"), "uptime-derived boot times wobble")
+	assert.False(t, SameBoot("boottime:1760000000", "boottime:1760000300"))
+	assert.False(t, SameBoot("boottime:1760000000", "6f1c2a5e-0d3b-4f5a-9c1e-2b7d8e9f0a1b"))
+	assert.False(t, SameBoot("boottime:x", "boottime:y"))
+}
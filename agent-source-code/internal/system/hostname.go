@@ -0,0 +1,110 @@
+package system
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"patchmon-agent/internal/utils"
+)
+
+// HostnameOptions controls how the reported hostname is derived
+type HostnameOptions struct {
+	// Override replaces the detected hostname entirely
+	Override string
+	// UseFQDN reports the fully qualified name instead of the short hostname
+	UseFQDN bool
+}
+
+// WithHostnameOptions applies hostname_override / use_fqdn to the detector
+func (d *Detector) WithHostnameOptions(opts HostnameOptions) *Detector {
+	d.hostnameOpts = opts
+	return d
+}
+
+// A resolved FQDN is reused for fqdnCacheTTL, so a report or a ping doesn't
+// wait on DNS each time. Not finding one is retried sooner, in case DNS was
+// just not up yet.
+const (
+	fqdnCacheTTL   = 10 * time.Minute
+	fqdnFailureTTL = time.Minute
+)
+
+type cachedFQDN struct {
+	fqdn    string
+	expires time.Time
+}
+
+var (
+	fqdnMu    sync.Mutex
+	fqdnCache = make(map[string]cachedFQDN)
+	// lookupFQDN is replaced in tests
+	lookupFQDN = (*Detector).lookupFQDN
+	fqdnNow    = time.Now
+)
+
+// resolveFQDN returns the fully qualified name for short, falling back to short
+// when no qualified name can be found
+func (d *Detector) resolveFQDN(short string) string {
+	if strings.Contains(short, ".") {
+		return short
+	}
+
+	fqdnMu.Lock()
+	defer fqdnMu.Unlock()
+	if e, ok := fqdnCache[short]; ok && fqdnNow().Before(e.expires) {
+		return e.fqdn
+	}
+	fqdn := lookupFQDN(d, short)
+	ttl := fqdnCacheTTL
+	if fqdn == "" {
+		d.logger.WithField("hostname", short).Debug("No fully qualified name found, using short hostname")
+		fqdn, ttl = short, fqdnFailureTTL
+	}
+	fqdnCache[short] = cachedFQDN{fqdn: fqdn, expires: fqdnNow().Add(ttl)}
+	return fqdn
+}
+
+// lookupFQDN asks, in order, `hostname -f`, a CNAME lookup and reverse DNS
+// of the host's own addresses for a qualified form of short. It returns ""
+// when none has one.
+func (d *Detector) lookupFQDN(short string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var candidates []string
+	if runtime.GOOS != "windows" {
+		if out, err := utils.CommandContext(ctx, "hostname", "-f").Output(); err == nil {
+			candidates = append(candidates, strings.TrimSpace(string(out)))
+		}
+	}
+	if cname, err := net.DefaultResolver.LookupCNAME(ctx, short); err == nil {
+		candidates = append(candidates, cname)
+	}
+	if addrs, err := net.DefaultResolver.LookupHost(ctx, short); err == nil {
+		for _, addr := range addrs {
+			if names, err := net.DefaultResolver.LookupAddr(ctx, addr); err == nil {
+				candidates = append(candidates, names...)
+			}
+		}
+	}
+
+	return pickFQDN(short, candidates)
+}
+
+// pickFQDN returns the first candidate that is a qualified form of short
+// (short.domain...). Unrelated reverse DNS names such as a cloud provider's
+// ip-10-0-0-1.ec2.internal for a renamed host are ignored.
+func pickFQDN(short string, candidates []string) string {
+	prefix := strings.ToLower(short) + "."
+	for _, c := range candidates {
+		c = strings.TrimSuffix(strings.TrimSpace(c), ".")
+		if strings.HasPrefix(strings.ToLower(c), prefix) && len(c) > len(prefix) {
+			return c
+		}
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package system
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPickFQDN(t *testing.T) {
+	assert.Equal(t, "web01.example.com", pickFQDN("web01", []string{"localhost", "ip-10-0-0-1.ec2.internal.", "web01.example.com."}))
+	assert.Equal(t, "WEB01.corp.local", pickFQDN("web01", []string{"WEB01.corp.local"}))
+	assert.Equal(t, "", pickFQDN("web01", []string{"web01", "web01.", "web011.example.com"}))
+	assert.Equal(t, "", pickFQDN("web01", nil))
+}
+
+func TestGetHostnameOverride(t *testing.T) {
+	d := New(logrus.New()).WithHostnameOptions(HostnameOptions{Override: "  db-eu-1  ", UseFQDN: true})
+	hostname, err := d.GetHostname()
+	assert.NoError(t, err)
+	assert.Equal(t, "db-eu-1", hostname)
+}
+
+func TestResolveFQDNCached(t *testing.T) {
+	now := time.Now()
+	answer, lookups := "web01.example.com", 0
+	origLookup, origNow := lookupFQDN, fqdnNow
+	t.Cleanup(func() {
+		lookupFQDN, fqdnNow = origLookup, origNow
+		fqdnCache = make(map[string]cachedFQDN)
+	})
+	lookupFQDN = func(*Detector, string) string { lookups++; return answer }
+	fqdnNow = func() time.Time { return now }
+	fqdnCache = make(map[string]cachedFQDN)
+
+	d := New(logrus.New())
+	assert.Equal(t, "web01.example.com", d.resolveFQDN("web01"))
+	assert.Equal(t, "web01.example.com", New(logrus.New()).resolveFQDN("web01"), "shared by detectors")
+	assert.Equal(t, 1, lookups)
+
+	answer = "web01.corp.local"
+	now = now.Add(fqdnCacheTTL)
+	assert.Equal(t, "web01.corp.local", d.resolveFQDN("web01"), "looked up again once expired")
+	assert.Equal(t, 2, lookups)
+
+	// Not finding one is retried sooner
+	answer = ""
+	now = now.Add(fqdnCacheTTL)
+	assert.Equal(t, "web01", d.resolveFQDN("web01"))
+	now = now.Add(fqdnFailureTTL)
+	answer = "web01.example.com"
+	assert.Equal(t, "web01.example.com", d.resolveFQDN("web01"))
+	assert.Equal(t, 4, lookups)
+
+	assert.Equal(t, "db.example.com", d.resolveFQDN("db.example.com"), "already qualified")
+	assert.Equal(t, 4, lookups)
+}
@@ -0,0 +1,66 @@
+package system
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"patchmon-agent/internal/cmdrunner/cmdrunnertest"
+	"patchmon-agent/internal/constants"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// hostDetector returns a Detector replaying the commands captured in
+// testdata/hosts/<host>
+func hostDetector(t *testing.T, host string) *Detector {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return New(logger).WithRunner(cmdrunnertest.Load(t, filepath.Join("testdata", "hosts", host)))
+}
+
+func TestHostUbuntuKernels(t *testing.T) {
+	d := hostDetector(t, "ubuntu-24.04")
+	assert.Equal(t, "6.8.0-45-generic", d.getRunningKernel())
+	assert.Equal(t, "6.8.0-48-generic", d.getLatestKernelFromDpkg(), "removed kernels and meta-packages are skipped")
+	assert.Empty(t, d.getLatestKernelFromRPM())
+
+	needed, reason := d.checkNeedsRestarting()
+	assert.False(t, needed, "needs-restarting is not installed: %s", reason)
+}
+
+func TestHostRockyKernels(t *testing.T) {
+	d := hostDetector(t, "rocky-9")
+	assert.Equal(t, "5.14.0-427.37.1.el9_4.x86_64", d.getRunningKernel())
+	assert.Equal(t, "5.14.0-427.40.1.el9_4.x86_64", d.getLatestKernelFromRPM())
+
+	needed, reason := d.checkNeedsRestarting()
+	assert.True(t, needed, "needs-restarting -r exits 1 when a reboot is needed")
+	assert.NotEmpty(t, reason)
+
+	assert.Equal(t, constants.SELinuxEnabled, d.getSELinuxStatus())
+}
+
+func TestHostFreeBSD(t *testing.T) {
+	d := hostDetector(t, "freebsd-14")
+	assert.True(t, d.isFreeBSD())
+	osType, osVersion, err := d.getFreeBSDInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "FreeBSD", osType)
+	assert.Equal(t, "14.1-RELEASE-p5", osVersion)
+	assert.Equal(t, constants.SELinuxDisabled, d.getSELinuxStatus())
+
+	assert.False(t, d.jailed())
+	needed, reason := d.checkFreeBSDRebootRequired()
+	assert.True(t, needed, "freebsd-update installed a new kernel")
+	assert.Contains(t, reason, "Installed kernel: 14.1-RELEASE-p6")
+}
+
+func TestHostFreeBSDJail(t *testing.T) {
+	d := hostDetector(t, "freebsd-14-jail")
+	assert.True(t, d.jailed())
+	needed, _ := d.checkFreeBSDRebootRequired()
+	assert.False(t, needed, "the jail's /boot/kernel is not what the host runs")
+}
@@ -2,14 +2,15 @@
 package system
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 
+	"patchmon-agent/internal/cmdrunner"
 	"patchmon-agent/internal/logutil"
 )
 
@@ -20,6 +21,9 @@ func (d *Detector) CheckRebootRequired() (bool, string) {
 	if runtime.GOOS == "windows" {
 		return d.checkWindowsRebootRequired()
 	}
+	if runtime.GOOS == "freebsd" {
+		return d.checkFreeBSDRebootRequired()
+	}
 
 	runningKernel := d.getRunningKernel()
 	latestKernel := d.getLatestInstalledKernel()
@@ -82,8 +86,7 @@ if ($reasons.Count -gt 0) {
   Write-Output "REBOOT_NOT_REQUIRED"
 }
 `
-	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", psScript)
-	output, err := cmd.Output()
+	output, err := d.runner.Output(context.Background(), "powershell", "-NoProfile", "-NonInteractive", "-Command", psScript)
 	if err != nil {
 		d.logger.WithError(err).Debug("Windows reboot check failed")
 		return false, ""
@@ -98,18 +101,50 @@ if ($reasons.Count -gt 0) {
 	return false, ""
 }
 
+// checkFreeBSDRebootRequired compares the installed kernel with the running
+// one. freebsd-update install puts the new kernel in place first and it only
+// runs after a reboot; userland-only patches leave the kernel version alone.
+// A jail runs its host's kernel, so it never needs a reboot of its own.
+func (d *Detector) checkFreeBSDRebootRequired() (bool, string) {
+	if d.jailed() {
+		d.logger.Debug("Running in a jail, reboot state belongs to the host")
+		return false, ""
+	}
+	running := d.freebsdVersion("-r")
+	installed := d.freebsdVersion("-k")
+	if running == "" || installed == "" || running == installed {
+		d.logger.Debug("No reboot required")
+		return false, ""
+	}
+	d.logger.WithFields(logutil.SanitizeMap(map[string]interface{}{
+		"running":   running,
+		"installed": installed,
+	})).Debug("Reboot required: kernel version mismatch")
+	return true, fmt.Sprintf("Kernel version mismatch | Running kernel: %s, Installed kernel: %s", running, installed)
+}
+
+// freebsdVersion returns freebsd-version's answer for one flag: -k for the
+// installed kernel, -r for the running one, -u for userland
+func (d *Detector) freebsdVersion(flag string) string {
+	out, err := d.runner.Output(context.Background(), "freebsd-version", flag)
+	if err != nil {
+		d.logger.WithError(err).WithField("flag", flag).Debug("freebsd-version failed")
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
 // checkNeedsRestarting checks using needs-restarting command (RHEL/Fedora)
 func (d *Detector) checkNeedsRestarting() (bool, string) {
 	// Check if needs-restarting command exists
-	if _, err := exec.LookPath("needs-restarting"); err != nil {
+	if _, err := d.runner.LookPath("needs-restarting"); err != nil {
 		d.logger.Debug("needs-restarting command not found, skipping check")
 		return false, ""
 	}
 
-	cmd := exec.Command("needs-restarting", "-r")
-	if err := cmd.Run(); err != nil {
+	if _, err := d.runner.Output(context.Background(), "needs-restarting", "-r"); err != nil {
 		// Exit code != 0 means reboot is needed
-		if _, ok := err.(*exec.ExitError); ok {
+		if _, ok := cmdrunner.ExitCode(err); ok {
 			return true, "needs-restarting indicates reboot needed"
 		}
 		d.logger.WithError(err).Debug("needs-restarting command failed")
@@ -120,8 +155,7 @@ func (d *Detector) checkNeedsRestarting() (bool, string) {
 
 // getRunningKernel gets the currently running kernel version
 func (d *Detector) getRunningKernel() string {
-	cmd := exec.Command("uname", "-r")
-	output, err := cmd.Output()
+	output, err := d.runner.Output(context.Background(), "uname", "-r")
 	if err != nil {
 		d.logger.WithError(err).Warn("Failed to get running kernel version")
 		return ""
@@ -136,6 +170,14 @@ func (d *Detector) GetLatestInstalledKernel() string {
 
 // getLatestInstalledKernel gets the latest installed kernel version
 func (d *Detector) getLatestInstalledKernel() string {
+	// FreeBSD: freebsd-version -k; a jail has no kernel of its own
+	if runtime.GOOS == "freebsd" {
+		if d.jailed() {
+			return ""
+		}
+		return d.freebsdVersion("-k")
+	}
+
 	// Try different methods based on common distro patterns
 
 	// Method 1: Debian/Ubuntu - check /boot for vmlinuz files
@@ -253,12 +295,11 @@ func parseKernelVersion(version string) []string {
 // getLatestKernelFromRPM queries RPM for installed kernel packages
 func (d *Detector) getLatestKernelFromRPM() string {
 	// Check if rpm command exists
-	if _, err := exec.LookPath("rpm"); err != nil {
+	if _, err := d.runner.LookPath("rpm"); err != nil {
 		return ""
 	}
 
-	cmd := exec.Command("rpm", "-q", "kernel", "--last")
-	output, err := cmd.Output()
+	output, err := d.runner.Output(context.Background(), "rpm", "-q", "kernel", "--last")
 	if err != nil {
 		d.logger.WithError(err).Debug("Failed to query RPM for kernel packages")
 		return ""
@@ -283,12 +324,11 @@ func (d *Detector) getLatestKernelFromRPM() string {
 // getLatestKernelFromDpkg queries dpkg for installed kernel packages
 func (d *Detector) getLatestKernelFromDpkg() string {
 	// Check if dpkg command exists
-	if _, err := exec.LookPath("dpkg"); err != nil {
+	if _, err := d.runner.LookPath("dpkg"); err != nil {
 		return ""
 	}
 
-	cmd := exec.Command("dpkg", "-l")
-	output, err := cmd.Output()
+	output, err := d.runner.Output(context.Background(), "dpkg", "-l")
 	if err != nil {
 		d.logger.WithError(err).Debug("Failed to query dpkg for kernel packages")
 		return ""
@@ -346,8 +386,7 @@ func (d *Detector) getLatestKernelFromDpkg() string {
 // resolveMetaPackage resolves a meta-package (like linux-image-virtual) to the actual kernel version
 func (d *Detector) resolveMetaPackage(metaPkg string) string {
 	// Use dpkg-query to get the dependencies
-	cmd := exec.Command("dpkg-query", "-W", "-f=${Depends}", metaPkg)
-	output, err := cmd.Output()
+	output, err := d.runner.Output(context.Background(), "dpkg-query", "-W", "-f=${Depends}", metaPkg)
 	if err != nil {
 		d.logger.WithError(err).Debug("Failed to query package dependencies")
 		return ""
@@ -0,0 +1,178 @@
+package system
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"patchmon-agent/internal/utils"
+)
+
+// pstoreDirs hold kernel logs saved by pstore at a crash: the live pstore
+// filesystem, and where systemd-pstore moves them at boot
+var pstoreDirs = []string{"/sys/fs/pstore", "/var/lib/systemd/pstore"}
+
+// kdumpDir is where kdump (RHEL) and kdump-tools (Debian) write crash dumps,
+// one directory per crash
+var kdumpDir = "/var/crash"
+
+// crashLogLimit bounds how much of a saved kernel log is read for the summary
+const crashLogLimit = 1 << 20
+
+// crashLineLimit bounds the crash summary sent to the server
+const crashLineLimit = 200
+
+// crashMarkers start the kernel log lines that say why it died
+var crashMarkers = []string{
+	"Kernel panic",
+	"BUG:",
+	"Oops",
+	"general protection fault",
+	"watchdog: BUG:",
+	"Hardware Error",
+	"Machine check",
+}
+
+// cleanShutdownMarkers appear in the journal's last lines when systemd shut
+// the previous boot down in an orderly way
+var cleanShutdownMarkers = []string{
+	"Journal stopped",
+	"System Reboot",
+	"System Power Off",
+	"System Halt",
+	"System is rebooting",
+	"System is powering down",
+}
+
+var dmesgTimestamp = regexp.MustCompile(`^(<\d+>)?\[\s*\d+\.\d+\]\s*`)
+
+// CheckPreviousShutdown looks for signs that the previous boot ended in a
+// crash or power loss: pstore or kdump records written since the given time,
+// or a journal for the previous boot with no orderly shutdown in it. It returns
+// the evidence found, if any, and the kernel's own explanation when a saved
+// log has one. Only Linux keeps these records.
+func (d *Detector) CheckPreviousShutdown(since time.Time) (evidence []string, summary string) {
+	if runtime.GOOS != "linux" {
+		return nil, ""
+	}
+
+	var logs []string
+	for _, dir := range pstoreDirs {
+		for _, path := range recentFiles(dir, since, func(string) bool { return true }) {
+			evidence = append(evidence, "pstore: "+path)
+			logs = append(logs, path)
+		}
+	}
+	for _, path := range recentFiles(kdumpDir, since, isKdumpFile) {
+		evidence = append(evidence, "kdump: "+path)
+		if !strings.HasPrefix(filepath.Base(path), "dump.") && filepath.Base(path) != "vmcore" {
+			logs = append(logs, path)
+		}
+	}
+	for _, path := range logs {
+		if summary = crashSummary(path); summary != "" {
+			break
+		}
+	}
+
+	if unclean, known := d.journalShutdown(); known && unclean {
+		evidence = append(evidence, "journal: previous boot has no orderly shutdown")
+	}
+	return evidence, summary
+}
+
+// recentFiles returns the files up to one directory below dir modified since
+// the given time and accepted by keep
+func recentFiles(dir string, since time.Time, keep func(path string) bool) []string {
+	var files []string
+	_ = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if rel, _ := filepath.Rel(dir, path); strings.Contains(rel, string(filepath.Separator)) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || !keep(path) {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil && !info.ModTime().Before(since) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files
+}
+
+// isKdumpFile matches kernel dumps and their logs, leaving out the userspace
+// crash reports apport also writes to /var/crash
+func isKdumpFile(path string) bool {
+	if filepath.Dir(path) == filepath.Clean(kdumpDir) {
+		return false
+	}
+	name := filepath.Base(path)
+	return strings.HasPrefix(name, "vmcore") || strings.HasPrefix(name, "dmesg") || strings.HasPrefix(name, "dump.")
+}
+
+// crashSummary returns the first line of a saved kernel log that says why the
+// kernel died
+func crashSummary(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = f.Close() }()
+	data, err := io.ReadAll(io.LimitReader(f, crashLogLimit))
+	if err != nil {
+		return ""
+	}
+	return crashLine(string(data))
+}
+
+// crashLine picks the panic, oops or machine check line out of a kernel log
+func crashLine(log string) string {
+	for line := range strings.Lines(log) {
+		line = strings.TrimSpace(dmesgTimestamp.ReplaceAllString(strings.TrimSpace(line), ""))
+		for _, marker := range crashMarkers {
+			if strings.HasPrefix(line, marker) {
+				if len(line) > crashLineLimit {
+					line = line[:crashLineLimit]
+				}
+				return line
+			}
+		}
+	}
+	return ""
+}
+
+// journalShutdown reports whether the journal's previous boot ended without
+// an orderly shutdown. known is false without a persistent journal.
+func (d *Detector) journalShutdown() (unclean, known bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	out, err := utils.CommandContext(ctx, "journalctl", "-b", "-1", "-n", "50", "-o", "cat", "--no-pager", "-q").Output()
+	if err != nil || len(strings.TrimSpace(string(out))) == 0 {
+		d.logger.WithError(err).Debug("Previous boot's journal unavailable")
+		return false, false
+	}
+	return !cleanShutdown(string(out)), true
+}
+
+// cleanShutdown reports whether the tail of a boot's journal shows systemd
+// shutting it down
+func cleanShutdown(tail string) bool {
+	for _, marker := range cleanShutdownMarkers {
+		if strings.Contains(tail, marker) {
+			return true
+		}
+	}
+	return false
+}
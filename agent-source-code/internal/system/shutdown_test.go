@@ -0,0 +1,54 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrashLine(t *testing.T) {
+	log := `<6>[    0.000000] Linux version 6.8.0-45-generic
+<4>[ 8123.402117] sysrq: Trigger a crash
+<0>[ 8123.402201] Kernel panic - not syncing: sysrq triggered crash
+<4>[ 8123.402250] CPU: 2 PID: 4711 Comm: bash
+`
+	assert.Equal(t, "Kernel panic - not syncing: sysrq triggered crash", crashLine(log))
+	assert.Equal(t, "BUG: kernel NULL pointer dereference, address: 0000000000000008",
+		crashLine("[  12.5] BUG: kernel NULL pointer dereference, address: 0000000000000008\n"))
+	assert.Empty(t, crashLine("[ 1.0] eth0: link up\n"))
+}
+
+func TestCleanShutdown(t *testing.T) {
+	assert.True(t, cleanShutdown("Stopped target Basic System.\nReached target reboot.target - System Reboot.\nShutting down.\nJournal stopped\n"))
+	assert.False(t, cleanShutdown("Started session-42.scope - Session 42 of User alice.\nnginx.service: Reloaded.\n"))
+}
+
+func TestRecentFiles(t *testing.T) {
+	dir := t.TempDir()
+	kdump := filepath.Join(dir, "127.0.0.1-2026-10-03-22:01:12")
+	require.NoError(t, os.MkdirAll(filepath.Join(kdump, "nested"), 0o755))
+	write := func(path string, age time.Duration) {
+		require.NoError(t, os.WriteFile(path, []byte("x"), 0o600))
+		mtime := time.Now().Add(-age)
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+	write(filepath.Join(kdump, "vmcore-dmesg.txt"), time.Minute)
+	write(filepath.Join(kdump, "vmcore"), time.Minute)
+	write(filepath.Join(kdump, "nested", "vmcore"), time.Minute)
+	write(filepath.Join(dir, "_usr_bin_python3.0.crash"), time.Minute)
+	old := filepath.Join(dir, "127.0.0.1-2025-01-01-00:00:00")
+	require.NoError(t, os.Mkdir(old, 0o755))
+	write(filepath.Join(old, "vmcore"), 90*24*time.Hour)
+
+	saved := kdumpDir
+	kdumpDir = dir
+	defer func() { kdumpDir = saved }()
+
+	files := recentFiles(dir, time.Now().Add(-time.Hour), isKdumpFile)
+	assert.ElementsMatch(t, []string{filepath.Join(kdump, "vmcore"), filepath.Join(kdump, "vmcore-dmesg.txt")}, files,
+		"old dumps, apport reports and deeper directories are left out")
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"net"
 	"os"
-	"os/exec"
 	"runtime"
 	"strings"
 	"time"
@@ -15,8 +14,9 @@ import (
 	"github.com/shirou/gopsutil/v4/load"
 	"github.com/sirupsen/logrus"
 
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"patchmon-agent/internal/cmdrunner"
 	"patchmon-agent/internal/constants"
-	"patchmon-agent/pkg/models"
 )
 
 // OSReleaseInfo holds parsed information from /etc/os-release
@@ -32,16 +32,26 @@ type OSReleaseInfo struct {
 
 // Detector handles system information detection
 type Detector struct {
-	logger *logrus.Logger
+	logger       *logrus.Logger
+	runner       cmdrunner.Runner
+	hostnameOpts HostnameOptions
+	machineID    string
 }
 
 // New creates a new system detector
 func New(logger *logrus.Logger) *Detector {
 	return &Detector{
 		logger: logger,
+		runner: cmdrunner.Default,
 	}
 }
 
+// WithRunner makes the detector run commands through r, and returns d
+func (d *Detector) WithRunner(r cmdrunner.Runner) *Detector {
+	d.runner = r
+	return d
+}
+
 // parseOSRelease parses /etc/os-release file and returns OS information
 func (d *Detector) parseOSRelease() (*OSReleaseInfo, error) {
 	file, err := os.Open("/etc/os-release")
@@ -99,14 +109,25 @@ func (d *Detector) parseOSRelease() (*OSReleaseInfo, error) {
 
 // isFreeBSD checks if running on FreeBSD using uname -s
 func (d *Detector) isFreeBSD() bool {
-	cmd := exec.Command("uname", "-s")
-	output, err := cmd.Output()
+	output, err := d.runner.Output(context.Background(), "uname", "-s")
 	if err != nil {
 		return false
 	}
 	return strings.TrimSpace(string(output)) == "FreeBSD"
 }
 
+// IsJailed reports whether the agent runs inside a FreeBSD jail. A jail
+// shares its host's kernel, so kernel and reboot state are the host's.
+func (d *Detector) IsJailed() bool {
+	return runtime.GOOS == "freebsd" && d.jailed()
+}
+
+// jailed reads security.jail.jailed, which is 1 inside a jail
+func (d *Detector) jailed() bool {
+	output, err := d.runner.Output(context.Background(), "sysctl", "-n", "security.jail.jailed")
+	return err == nil && strings.TrimSpace(string(output)) == "1"
+}
+
 // isPfSense checks if running on pfSense (FreeBSD-based firewall)
 func (d *Detector) isPfSense() bool {
 	// pfSense uses /cf/conf/config.xml for its config; vanilla FreeBSD does not
@@ -139,13 +160,11 @@ func (d *Detector) getFreeBSDInfo() (osType, osVersion string, err error) {
 	osType = "FreeBSD"
 
 	// Use freebsd-version for accurate version info
-	cmd := exec.Command("freebsd-version")
-	output, err := cmd.Output()
+	output, err := d.runner.Output(context.Background(), "freebsd-version")
 	if err != nil {
 		d.logger.WithError(err).Warn("Failed to get FreeBSD version, falling back to uname -r")
 		// Fallback to uname -r
-		cmd = exec.Command("uname", "-r")
-		output, err = cmd.Output()
+		output, err = d.runner.Output(context.Background(), "uname", "-r")
 		if err != nil {
 			return osType, "Unknown", nil
 		}
@@ -243,12 +262,14 @@ func (d *Detector) GetSystemInfo() models.SystemInfo {
 		SELinuxStatus: d.getSELinuxStatus(),
 		SystemUptime:  d.getSystemUptime(ctx),
 		LoadAverage:   d.getLoadAverage(ctx),
+		Jailed:        d.IsJailed(),
 	}
 
 	d.logger.WithFields(logrus.Fields{
 		"kernel":  info.KernelVersion,
 		"selinux": info.SELinuxStatus,
 		"uptime":  info.SystemUptime,
+		"jailed":  info.Jailed,
 	}).Debug("Collected kernel, SELinux, and uptime information")
 
 	return info
@@ -268,19 +289,32 @@ func (d *Detector) GetArchitecture() string {
 	return info.KernelArch
 }
 
-// GetHostname returns the system hostname
+// GetHostname returns the hostname reported to the server, honouring
+// hostname_override and use_fqdn
 func (d *Detector) GetHostname() (string, error) {
+	if override := strings.TrimSpace(d.hostnameOpts.Override); override != "" {
+		return override, nil
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	var hostname string
 	info, err := host.InfoWithContext(ctx)
 	if err != nil {
 		d.logger.WithError(err).Warn("Failed to get hostname")
 		// Fallback to os.Hostname
-		return os.Hostname()
+		if hostname, err = os.Hostname(); err != nil {
+			return "", err
+		}
+	} else {
+		hostname = info.Hostname
 	}
 
-	return info.Hostname, nil
+	if d.hostnameOpts.UseFQDN {
+		return d.resolveFQDN(hostname), nil
+	}
+	return hostname, nil
 }
 
 // GetIPAddress gets the primary IP address using network interfaces
@@ -336,18 +370,16 @@ func (d *Detector) getSELinuxStatus() string {
 	}
 
 	// Try getenforce command first
-	if cmd := exec.Command("getenforce"); cmd != nil {
-		if output, err := cmd.Output(); err == nil {
-			status := strings.ToLower(strings.TrimSpace(string(output)))
-			// Map "enforcing" to "enabled" for server validation
-			if status == constants.SELinuxEnforcing {
-				return constants.SELinuxEnabled
-			}
-			if status == constants.SELinuxPermissive {
-				return constants.SELinuxPermissive
-			}
-			return status
+	if output, err := d.runner.Output(context.Background(), "getenforce"); err == nil {
+		status := strings.ToLower(strings.TrimSpace(string(output)))
+		// Map "enforcing" to "enabled" for server validation
+		if status == constants.SELinuxEnforcing {
+			return constants.SELinuxEnabled
+		}
+		if status == constants.SELinuxPermissive {
+			return constants.SELinuxPermissive
 		}
+		return status
 	}
 
 	// Fallback to reading config file
@@ -406,8 +438,20 @@ func (d *Detector) getLoadAverage(ctx context.Context) []float64 {
 	return []float64{loadAvg.Load1, loadAvg.Load5, loadAvg.Load15}
 }
 
-// GetMachineID returns the system's machine ID using gopsutil
+// WithMachineID makes GetMachineID return id instead of the OS machine ID.
+// An empty id keeps the OS machine ID.
+func (d *Detector) WithMachineID(id string) *Detector {
+	d.machineID = id
+	return d
+}
+
+// GetMachineID returns the system's machine ID using gopsutil, unless one was
+// set with WithMachineID
 func (d *Detector) GetMachineID() string {
+	if d.machineID != "" {
+		return d.machineID
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
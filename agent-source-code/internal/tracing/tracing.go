@@ -0,0 +1,120 @@
+// Package tracing sets up OpenTelemetry spans for the report pipeline,
+// compliance scans and Docker collection. Until Setup installs an exporter
+// the global tracer provider is a no-op, so instrumented code costs nothing
+// when tracing is off.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName = "patchmon-agent"
+	// defaultTimeout bounds one export, so a dead collector can't hold up shutdown
+	defaultTimeout = 10 * time.Second
+	tracesPath     = "/v1/traces"
+)
+
+// Start starts a span as a child of the one in ctx, if any
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err, if any, on span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Enabled reports whether cfg or the standard OTEL_EXPORTER_OTLP_* variables
+// name a collector
+func Enabled(cfg *models.TracingConfig) bool {
+	if cfg != nil && cfg.Endpoint != "" {
+		return true
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup installs a tracer provider that exports spans to the collector named
+// by cfg, falling back to the OTEL_EXPORTER_OTLP_* variables. hostname and
+// version describe this agent in every trace. The returned func flushes
+// pending spans and must be called before the process exits.
+func Setup(ctx context.Context, cfg *models.TracingConfig, hostname, version string) (func(context.Context) error, error) {
+	if !Enabled(cfg) {
+		return func(context.Context) error { return nil }, nil
+	}
+	if cfg == nil {
+		cfg = &models.TracingConfig{}
+	}
+
+	ratio := 1.0
+	if cfg.SampleRatio != nil {
+		if *cfg.SampleRatio < 0 || *cfg.SampleRatio > 1 {
+			return nil, fmt.Errorf("tracing.sample_ratio must be between 0 and 1, got %g", *cfg.SampleRatio)
+		}
+		ratio = *cfg.SampleRatio
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithTimeout(defaultTimeout)}
+	if cfg.Endpoint != "" {
+		endpoint, err := exportURL(cfg.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	if cfg.Timeout > 0 {
+		opts = append(opts, otlptracehttp.WithTimeout(time.Duration(cfg.Timeout)*time.Second))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", tracerName),
+			attribute.String("service.version", version),
+			attribute.String("host.name", hostname),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// exportURL turns a collector base URL into the OTLP/HTTP traces URL; a URL
+// that already has a path is used as given
+func exportURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("tracing.endpoint must be an http(s) URL, e.g. http://otel-collector:4318")
+	}
+	if strings.Trim(u.Path, "/") == "" {
+		u.Path = tracesPath
+	}
+	return u.String(), nil
+}
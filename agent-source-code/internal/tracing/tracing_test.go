@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	ctx, report := Start(context.Background(), "report")
+	_, send := Start(ctx, "report.send")
+	End(send, errors.New("connection refused"))
+	End(report, nil)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "report.send", spans[0].Name())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+}
+
+func TestSetup(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+
+	assert.False(t, Enabled(nil))
+	shutdown, err := Setup(context.Background(), nil, "web-01", "1.5.0")
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = Setup(context.Background(), &models.TracingConfig{Endpoint: "otel-collector:4318"}, "web-01", "1.5.0")
+	assert.Error(t, err)
+	ratio := 2.0
+	_, err = Setup(context.Background(), &models.TracingConfig{Endpoint: "http://otel-collector:4318", SampleRatio: &ratio}, "web-01", "1.5.0")
+	assert.Error(t, err)
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+	assert.True(t, Enabled(nil))
+}
+
+func TestExportURL(t *testing.T) {
+	for in, want := range map[string]string{
+		"http://otel-collector:4318":             "http://otel-collector:4318/v1/traces",
+		"https://otel.example.com/":              "https://otel.example.com/v1/traces",
+		"https://otel.example.com/otlp/v1/trace": "https://otel.example.com/otlp/v1/trace",
+	} {
+		got, err := exportURL(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got)
+	}
+	for _, in := range []string{"otel-collector:4318", "grpc://otel:4317", "http://"} {
+		_, err := exportURL(in)
+		assert.Error(t, err, in)
+	}
+}
@@ -0,0 +1,33 @@
+// Package utils provides utility functions for common operations
+//
+//nolint:revive // utils is a common package name in Go projects
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ClockSkewWarnThreshold is the skew beyond which the agent warns loudly
+const ClockSkewWarnThreshold = 60 * time.Second
+
+// CalculateClockSkew estimates how far the local clock is from the server's,
+// using the server's HTTP Date header and the local send/receive times of the
+// request. Positive means the local clock is ahead of the server.
+//
+// The Date header has one-second resolution, so half a second is added to the
+// server time to centre the estimate; the result is accurate to roughly
+// ±(RTT/2 + 0.5s), which is plenty for spotting hosts with a broken clock.
+func CalculateClockSkew(serverDate string, sent, received time.Time) (time.Duration, error) {
+	if serverDate == "" {
+		return 0, fmt.Errorf("server did not send a Date header")
+	}
+	serverTime, err := http.ParseTime(serverDate)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Date header %q: %w", serverDate, err)
+	}
+	serverTime = serverTime.Add(500 * time.Millisecond)
+	midpoint := sent.Add(received.Sub(sent) / 2)
+	return midpoint.Sub(serverTime).Round(time.Second), nil
+}
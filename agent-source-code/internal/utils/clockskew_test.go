@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculateClockSkew(t *testing.T) {
+	server := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	date := server.Format(http.TimeFormat)
+	// The Date header is truncated to the second; the estimate assumes the
+	// server was half way through it
+	mid := server.Add(500 * time.Millisecond)
+
+	tests := []struct {
+		name     string
+		date     string
+		sent     time.Time
+		received time.Time
+		want     time.Duration
+		wantErr  string
+	}{
+		{name: "in sync", date: date, sent: mid.Add(-100 * time.Millisecond), received: mid.Add(100 * time.Millisecond), want: 0},
+		{name: "local clock ahead", date: date, sent: mid.Add(90 * time.Second), received: mid.Add(92 * time.Second), want: 91 * time.Second},
+		{name: "local clock behind", date: date, sent: mid.Add(-5 * time.Minute), received: mid.Add(-5*time.Minute + 2*time.Second), want: -5*time.Minute + time.Second},
+		{name: "slow round trip is centred", date: date, sent: mid.Add(-4 * time.Second), received: mid.Add(4 * time.Second), want: 0},
+		{name: "no Date header", date: "", wantErr: "did not send a Date header"},
+		{name: "unparsable Date header", date: "yesterday", wantErr: `invalid Date header "yesterday"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CalculateClockSkew(tt.date, tt.sent, tt.received)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
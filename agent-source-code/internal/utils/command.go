@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// CLocaleEnv returns the agent's environment with the locale forced to C, for
+// commands whose output is parsed. LANG=C alone is not enough: LC_ALL and the
+// LC_* categories override it, and LANGUAGE overrides message translations.
+func CLocaleEnv() []string {
+	env := make([]string, 0, len(os.Environ())+2)
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if name == "LANG" || name == "LANGUAGE" || strings.HasPrefix(name, "LC_") {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return append(env, "LANG=C", "LC_ALL=C")
+}
+
+// Command is exec.Command with the C locale; see CLocaleEnv
+func Command(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Env = CLocaleEnv()
+	return cmd
+}
+
+// CommandContext is exec.CommandContext with the C locale; see CLocaleEnv
+func CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = CLocaleEnv()
+	return cmd
+}
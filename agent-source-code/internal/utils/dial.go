@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// happyEyeballsDelay is how long the first address family gets before the
+// other is tried in parallel; RFC 8305's recommended Connection Attempt Delay
+const happyEyeballsDelay = 250 * time.Millisecond
+
+// NewDialer returns the dialer for the agent's outbound TCP connections. Names
+// with both AAAA and A records are dialed Happy Eyeballs style, IPv6 first and
+// IPv4 after happyEyeballsDelay, so a host with one broken address family
+// connects over the other without waiting out the timeout. IPv6-only and
+// IPv4-only hosts simply dial the addresses they can route.
+func NewDialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout:       timeout,
+		KeepAlive:     30 * time.Second,
+		FallbackDelay: happyEyeballsDelay,
+	}
+}
+
+// LookupFunc resolves a host name to IP addresses
+type LookupFunc func(ctx context.Context, host string) ([]string, error)
+
+// fallbackLookup resolves names the system resolver can't; see
+// SetFallbackLookup
+var fallbackLookup atomic.Pointer[LookupFunc]
+
+// SetFallbackLookup installs the resolver DialContext falls back to when the
+// system resolver fails, or removes it when f is nil
+func SetFallbackLookup(f LookupFunc) {
+	if f == nil {
+		fallbackLookup.Store(nil)
+		return
+	}
+	fallbackLookup.Store(&f)
+}
+
+// FallbackLookup returns the installed fallback resolver, or nil
+func FallbackLookup() LookupFunc {
+	if f := fallbackLookup.Load(); f != nil {
+		return *f
+	}
+	return nil
+}
+
+// DialContext returns a dial function for connections to the PatchMon
+// server: NewDialer, plus the fallback resolver when the system resolver
+// can't resolve the name
+func DialContext(timeout time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	d := NewDialer(timeout)
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := d.DialContext(ctx, network, addr)
+		var dnsErr *net.DNSError
+		lookup := FallbackLookup()
+		if err == nil || lookup == nil || !errors.As(err, &dnsErr) {
+			return conn, err
+		}
+		host, port, splitErr := net.SplitHostPort(addr)
+		if splitErr != nil {
+			return nil, err
+		}
+		addrs, lookupErr := lookup(ctx, host)
+		if lookupErr != nil {
+			return nil, fmt.Errorf("%w (fallback resolver: %v)", err, lookupErr)
+		}
+		for _, ip := range addrs {
+			conn, dialErr := d.DialContext(ctx, network, net.JoinHostPort(ip, port))
+			if dialErr == nil {
+				return conn, nil
+			}
+			err = dialErr
+		}
+		return nil, err
+	}
+}
@@ -41,3 +41,15 @@ func hashString(s string) uint64 {
 	h.Write([]byte(s))
 	return h.Sum64()
 }
+
+// CalculateScanOffset spreads a long-running periodic job, such as a weekly
+// compliance scan, across the whole interval rather than the first hour, so a
+// fleet on the same cadence doesn't run it at the same time. Like
+// CalculateReportOffset it is deterministic per api_id.
+func CalculateScanOffset(apiID string, interval time.Duration) time.Duration {
+	seconds := uint64(interval / time.Second)
+	if seconds == 0 {
+		return 0
+	}
+	return time.Duration(hashString(apiID)%seconds) * time.Second
+}
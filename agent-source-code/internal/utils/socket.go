@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// ListenUnix listens on a unix socket at path, replacing a stale one. The
+// socket is mode 0660 and its directory 0750; with group set (a name or GID)
+// both are given to that group so its members can connect without root.
+func ListenUnix(path, group string) (net.Listener, error) {
+	gid := -1
+	if group != "" {
+		var err error
+		if gid, err = lookupGID(group); err != nil {
+			return nil, err
+		}
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	if gid >= 0 {
+		for _, p := range []string{dir, path} {
+			if err := os.Chown(p, -1, gid); err != nil {
+				_ = ln.Close()
+				return nil, fmt.Errorf("failed to give %s to group %s: %w", p, group, err)
+			}
+		}
+	}
+	return ln, nil
+}
+
+// lookupGID resolves a group name or numeric GID
+func lookupGID(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, fmt.Errorf("unknown socket group %q: %w", group, err)
+	}
+	return strconv.Atoi(g.Gid)
+}
@@ -4,14 +4,14 @@
 package utils
 
 import (
-	"fmt"
 	"net"
 	"time"
 )
 
 // TCPPing performs a simple TCP connection test to the specified host and port
 func TCPPing(host, port string) bool {
-	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%s", host, port), 5*time.Second)
+	// JoinHostPort brackets IPv6 literals
+	conn, err := NewDialer(5*time.Second).Dial("tcp", net.JoinHostPort(host, port))
 	if err != nil {
 		return false
 	}
@@ -0,0 +1,60 @@
+package models
+
+// Password states of a local account
+const (
+	PasswordSet      = "set"
+	PasswordEmpty    = "empty"    // Anyone can log in without a password
+	PasswordLocked   = "locked"   // Hash present but locked (passwd -l / pw lock)
+	PasswordDisabled = "disabled" // No usable password (*, !, !!)
+	PasswordUnknown  = "unknown"  // Shadow file not readable
+)
+
+// UserAccount is a local account worth a look in a security review: one with
+// a login shell, admin rights, an empty password or SSH keys
+type UserAccount struct {
+	Name           string   `json:"name"`
+	UID            int      `json:"uid"`
+	GID            int      `json:"gid"`
+	Home           string   `json:"home"`
+	Shell          string   `json:"shell"`
+	LoginShell     bool     `json:"login_shell"`
+	System         bool     `json:"system"` // UID below the distribution's first regular user
+	Groups         []string `json:"groups,omitempty"`
+	Admin          bool     `json:"admin"`               // Root, a member of sudo/wheel/admin, or named in sudoers
+	AdminVia       []string `json:"admin_via,omitempty"` // How admin rights are granted, e.g. "uid 0", "group wheel", "sudoers"
+	PasswordStatus string   `json:"password_status"`     // set, empty, locked, disabled or unknown
+	AuthorizedKeys int      `json:"authorized_keys"`     // Keys in ~/.ssh/authorized_keys and authorized_keys2
+}
+
+// SudoRule is one user or group specification from sudoers
+type SudoRule struct {
+	Principal string `json:"principal"` // user, %group or alias the rule applies to
+	Spec      string `json:"spec"`      // the rest of the line, e.g. "ALL=(ALL:ALL) ALL"
+	NoPasswd  bool   `json:"nopasswd"`
+	Source    string `json:"source"` // file the rule is in
+}
+
+// UserAccountsData is the account and sudo summary for a host
+type UserAccountsData struct {
+	Accounts      []UserAccount `json:"accounts"`
+	TotalAccounts int           `json:"total_accounts"` // All entries in the passwd database, including ones not listed
+	AdminGroups   []string      `json:"admin_groups,omitempty"`
+	SudoRules     []SudoRule    `json:"sudo_rules,omitempty"`
+	Warnings      []string      `json:"warnings,omitempty"` // e.g. unreadable shadow or sudoers files
+}
+
+// UserAccountsPayload is sent to the server with user account data
+type UserAccountsPayload struct {
+	UserAccountsData
+	SchemaVersion int `json:"schema_version,omitempty"`
+
+	Hostname     string `json:"hostname"`
+	MachineID    string `json:"machine_id"`
+	AgentVersion string `json:"agent_version"`
+}
+
+// UserAccountsResponse is the server response to a user account upload
+type UserAccountsResponse struct {
+	Message          string `json:"message"`
+	AccountsReceived int    `json:"accounts_received"`
+}
@@ -0,0 +1,11 @@
+package models
+
+// AuthHeadersConfig adds headers to every request the agent makes to the
+// PatchMon server, REST and WebSocket alike, for reverse proxies that
+// authenticate clients before PatchMon sees them (Cloudflare Access service
+// tokens, oauth2-proxy bearer tokens)
+type AuthHeadersConfig struct {
+	Headers      map[string]string `yaml:"headers,omitempty" mapstructure:"headers"`             // Fixed headers, name to value
+	Command      []string          `yaml:"command,omitempty" mapstructure:"command"`             // argv printing "Name: value" lines, for short-lived tokens; no shell is involved
+	CacheSeconds int               `yaml:"cache_seconds,omitempty" mapstructure:"cache_seconds"` // How long the command's headers are reused (default 300)
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// BootInfo is sent with the first report after the host rebooted, so the
+// server can close out its reboot tracking without waiting for the next
+// scheduled report
+type BootInfo struct {
+	BootID         string    `json:"bootId"`
+	PreviousBootID string    `json:"previousBootId,omitempty"` // Empty when the agent had not recorded a boot before
+	BootTime       time.Time `json:"bootTime"`
+	// LastSeen is when the agent last ran during the previous boot, roughly
+	// when the host went down
+	LastSeen *time.Time `json:"lastSeen,omitempty"`
+	// DowntimeSeconds is from LastSeen to BootTime; left out when LastSeen is
+	// unknown
+	DowntimeSeconds int64 `json:"downtimeSeconds,omitempty"`
+	// RebootRequiredCleared is set when the last report before the reboot
+	// said a reboot was needed and this one no longer does
+	RebootRequiredCleared bool `json:"rebootRequiredCleared,omitempty"`
+	// UncleanShutdown is set when the previous boot ended in a crash or power
+	// loss rather than a shutdown or reboot
+	UncleanShutdown bool     `json:"uncleanShutdown,omitempty"`
+	CrashEvidence   []string `json:"crashEvidence,omitempty"` // e.g. "pstore: /var/lib/systemd/pstore/1760000000/dmesg-efi-176000000001001"
+	CrashSummary    string   `json:"crashSummary,omitempty"`  // The kernel's panic or oops line from a saved log
+}
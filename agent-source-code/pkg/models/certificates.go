@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// TLSCertificate is an X.509 certificate found on disk. A certificate stored
+// in several files (a Let's Encrypt live symlink and its archive copy, say)
+// is reported once with all its paths.
+type TLSCertificate struct {
+	Paths              []string  `json:"paths"`
+	Subject            string    `json:"subject"`
+	CommonName         string    `json:"common_name,omitempty"`
+	SANs               []string  `json:"sans,omitempty"` // DNS names, IP addresses, emails and URIs
+	Issuer             string    `json:"issuer"`
+	SerialNumber       string    `json:"serial_number"` // Hex
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	DaysUntilExpiry    int       `json:"days_until_expiry"` // Negative once expired
+	Expired            bool      `json:"expired"`
+	SelfSigned         bool      `json:"self_signed"`
+	IsCA               bool      `json:"is_ca"`
+	KeyAlgorithm       string    `json:"key_algorithm"` // RSA, ECDSA, Ed25519
+	KeyBits            int       `json:"key_bits,omitempty"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	FingerprintSHA256  string    `json:"fingerprint_sha256"`
+}
+
+// TLSCertificatesData is the certificate inventory for a host
+type TLSCertificatesData struct {
+	Certificates []TLSCertificate `json:"certificates"`
+	ScannedPaths []string         `json:"scanned_paths"`      // Configured paths that exist
+	Warnings     []string         `json:"warnings,omitempty"` // Unreadable files, scan limits hit
+}
+
+// TLSCertificatesPayload is sent to the server with certificate inventory data
+type TLSCertificatesPayload struct {
+	TLSCertificatesData
+	SchemaVersion int `json:"schema_version,omitempty"`
+
+	Hostname     string `json:"hostname"`
+	MachineID    string `json:"machine_id"`
+	AgentVersion string `json:"agent_version"`
+}
+
+// TLSCertificatesResponse is the server response to a certificate upload
+type TLSCertificatesResponse struct {
+	Message              string `json:"message"`
+	CertificatesReceived int    `json:"certificates_received"`
+}
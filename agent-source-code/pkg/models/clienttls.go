@@ -0,0 +1,9 @@
+package models
+
+// ClientTLSConfig is the client certificate the agent presents to a PatchMon
+// server that requires mutual TLS, alongside its API credentials
+type ClientTLSConfig struct {
+	CertFile string `yaml:"cert_file" mapstructure:"cert_file"`       // PEM client certificate, reread at each handshake so renewals apply without a restart
+	KeyFile  string `yaml:"key_file" mapstructure:"key_file"`         // PEM private key for cert_file
+	CAFile   string `yaml:"ca_file,omitempty" mapstructure:"ca_file"` // PEM CA bundle that signed the server's certificate (default system roots)
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Command reply message types. A server that sends a command_id with a
+// WebSocket command gets one command_ack or command_nack for it, and a
+// command_result when an accepted command finishes. A command held for a
+// maintenance window gets command_deferred first.
+const (
+	CommandAck      = "command_ack"
+	CommandNack     = "command_nack"
+	CommandResult   = "command_result"
+	CommandDeferred = "command_deferred"
+)
+
+// Reasons a command is rejected with command_nack
+const (
+	NackInvalid = "invalid"         // failed validation
+	NackRefused = "refused"         // observer mode, an allow_* flag or a disabled proxy
+	NackSkipped = "skipped"         // the agent is paused
+	NackUnknown = "unknown_command" // the agent doesn't know the command type
+)
+
+// CommandReply acknowledges, rejects or completes a server command
+type CommandReply struct {
+	Type      string `json:"type"` // command_ack, command_nack, command_result, command_deferred
+	CommandID string `json:"command_id"`
+	// Command is the type of the command being answered, e.g. compliance_scan
+	Command string `json:"command"`
+	// Reason is set on command_nack
+	Reason string `json:"reason,omitempty"`
+	// Outcome is set on command_result: success, failed or cancelled
+	Outcome string `json:"outcome,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// RunAfter is set on command_deferred: when the maintenance window opens
+	RunAfter  *time.Time `json:"run_after,omitempty"`
+	Timestamp time.Time  `json:"timestamp"`
+}
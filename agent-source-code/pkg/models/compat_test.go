@@ -0,0 +1,124 @@
+package models_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models/internal/schemagen"
+)
+
+// decodeStrict decodes a fixture, failing on fields the models no longer have
+func decodeStrict(t *testing.T, name string, v any) []byte {
+	t.Helper()
+	data := readFile(t, filepath.Join("testdata", "compat", name))
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		t.Fatalf("%s no longer decodes: %v", name, err)
+	}
+	return data
+}
+
+// assertSameJSON compares JSON documents ignoring formatting and key order
+func assertSameJSON(t *testing.T, name string, want []byte, got any) {
+	t.Helper()
+	gotData, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var w, g any
+	if err := json.Unmarshal(want, &w); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(gotData, &g); err != nil {
+		t.Fatal(err)
+	}
+	wantNorm, _ := json.Marshal(w)
+	gotNorm, _ := json.Marshal(g)
+	if !bytes.Equal(wantNorm, gotNorm) {
+		t.Errorf("%s changed on the wire:\nwant %s\ngot  %s", name, wantNorm, gotNorm)
+	}
+}
+
+// The fixtures are payloads as each schema version puts them on the wire.
+// Decoding them and rendering them back for the same schema must give the
+// same JSON, so a renamed tag or a field leaking into an older schema fails
+// here rather than on a server.
+func TestPayloadFixturesRoundTrip(t *testing.T) {
+	var reportV1 models.ReportPayload
+	data := decodeStrict(t, "report-v1.json", &reportV1)
+	assertSameJSON(t, "report-v1.json", data, reportV1.ForSchema(1))
+
+	var reportV2 models.ReportPayload
+	data = decodeStrict(t, "report-v2.json", &reportV2)
+	assertSameJSON(t, "report-v2.json", data, reportV2.ForSchema(2))
+
+	// A current report rendered down for schema 1 matches what schema 1 agents sent
+	assertSameJSON(t, "report-v1.json", readFile(t, filepath.Join("testdata", "compat", "report-v1.json")), reportV2.ForSchema(1))
+
+	var ping models.PingRequest
+	data = decodeStrict(t, "ping-request-v2.json", &ping)
+	assertSameJSON(t, "ping-request-v2.json", data, ping.ForSchema(2))
+	if ping.ForSchema(1) != nil {
+		t.Error("schema 1 pings have no body")
+	}
+
+	var langpkg models.LanguagePackagesPayload
+	data = decodeStrict(t, "language-packages-v2.json", &langpkg)
+	assertSameJSON(t, "language-packages-v2.json", data, langpkg.ForSchema(2))
+}
+
+func TestClampSchemaVersion(t *testing.T) {
+	for in, want := range map[int]int{0: models.SchemaVersion, 1: 1, models.SchemaVersion + 1: models.SchemaVersion, -3: models.SchemaVersion} {
+		if got := models.ClampSchemaVersion(in); got != want {
+			t.Errorf("ClampSchemaVersion(%d) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+// TestJSONSchemasUpToDate fails when a model changed without running
+// go generate
+func TestJSONSchemasUpToDate(t *testing.T) {
+	docs, err := schemagen.ParseDocs(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, root := range schemagen.Roots {
+		want, err := schemagen.Generate(root, docs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := readFile(t, filepath.Join("jsonschema", root.Name+".schema.json"))
+		if !bytes.Equal(got, want) {
+			t.Errorf("jsonschema/%s.schema.json is stale; run go generate in pkg/models", root.Name)
+		}
+	}
+}
+
+// TestJSONSchemasAreValidJSON checks every generated schema names its root
+// type and parses
+func TestJSONSchemasAreValidJSON(t *testing.T) {
+	for _, root := range schemagen.Roots {
+		data := readFile(t, filepath.Join("jsonschema", root.Name+".schema.json"))
+		var schema map[string]any
+		if err := json.Unmarshal(data, &schema); err != nil {
+			t.Fatalf("%s: %v", root.Name, err)
+		}
+		if schema["type"] != "object" || schema["title"] == "" {
+			t.Errorf("%s: unexpected root %v", root.Name, schema["title"])
+		}
+	}
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -1,4 +1,3 @@
-// Package models provides data models used throughout the agent
 package models
 
 import "time"
@@ -17,7 +16,7 @@ type ComplianceRule struct {
 type ComplianceResult struct {
 	RuleID      string `json:"rule_ref"` // Backend expects rule_ref, not rule_id
 	Title       string `json:"title"`
-	Status      string `json:"status"` // pass, fail, warn, skip, notapplicable, error
+	Status      string `json:"status"` // pass, fail, warn, skip, notapplicable, error, waived
 	Finding     string `json:"finding,omitempty"`
 	Actual      string `json:"actual,omitempty"`   // Actual value found on the system
 	Expected    string `json:"expected,omitempty"` // Expected/required value
@@ -25,6 +24,24 @@ type ComplianceResult struct {
 	Description string `json:"description,omitempty"`
 	Severity    string `json:"severity,omitempty"`
 	Remediation string `json:"remediation,omitempty"`
+	// CVSSScore and CVSSVector are set on CVE results whose source provides
+	// a CVSS base score (v3 preferred)
+	CVSSScore  float64 `json:"cvss_score,omitempty"`
+	CVSSVector string  `json:"cvss_vector,omitempty"`
+	// Justification and WaiverExpires explain a waived CVE
+	Justification string     `json:"justification,omitempty"`
+	WaiverExpires *time.Time `json:"waiver_expires,omitempty"`
+}
+
+// ImageCVEWaiver accepts a CVE in matching Docker images. Waived findings are
+// reported with status "waived" and don't count against the scan score.
+type ImageCVEWaiver struct {
+	CVE string `yaml:"cve" mapstructure:"cve"`
+	// Images are globs, or "regex:<expr>", matched against the image
+	// reference and its repository; empty matches every image
+	Images        []string `yaml:"images,omitempty" mapstructure:"images"`
+	Expires       string   `yaml:"expires,omitempty" mapstructure:"expires"` // YYYY-MM-DD, waived through that day (UTC); empty never expires
+	Justification string   `yaml:"justification" mapstructure:"justification"`
 }
 
 // ComplianceScan represents results of a compliance scan
@@ -39,12 +56,32 @@ type ComplianceScan struct {
 	Warnings           int                `json:"warnings"`
 	Skipped            int                `json:"skipped"`
 	NotApplicable      int                `json:"not_applicable"`
+	Waived             int                `json:"waived,omitempty"`
 	StartedAt          time.Time          `json:"started_at"`
 	CompletedAt        *time.Time         `json:"completed_at,omitempty"`
 	Results            []ComplianceResult `json:"results"`
 	Error              string             `json:"error,omitempty"`
 	RemediationApplied bool               `json:"remediation_applied,omitempty"`
 	RemediationCount   int                `json:"remediation_count,omitempty"` // Number of rules remediated
+	// Diff compares the scan with the previous uploaded scan of the same
+	// profile; nil for the first scan of a profile
+	Diff *ComplianceScanDiff `json:"diff,omitempty"`
+	// HTMLReport is the ID of the oscap HTML report stored for the scan,
+	// uploaded separately when upload_html_report is on
+	HTMLReport string `json:"html_report,omitempty"`
+}
+
+// ComplianceScanDiff is how a scan changed since the previous scan of the
+// same profile, so regressions stand out from the full result list
+type ComplianceScanDiff struct {
+	PreviousCompletedAt *time.Time `json:"previous_completed_at,omitempty"`
+	PreviousScore       float64    `json:"previous_score"`
+	ScoreChange         float64    `json:"score_change"`
+	// NewlyFailing are rules that fail now but did not fail before,
+	// including rules the previous scan didn't have
+	NewlyFailing []string `json:"newly_failing,omitempty"`
+	// NewlyPassing are rules that pass now but failed before
+	NewlyPassing []string `json:"newly_passing,omitempty"`
 }
 
 // ComplianceData represents all compliance-related data
@@ -73,12 +110,32 @@ type ComplianceScannerInfo struct {
 // CompliancePayload represents the payload sent to the compliance endpoint
 type CompliancePayload struct {
 	ComplianceData
+	SchemaVersion int `json:"schema_version,omitempty"`
+
 	Hostname     string `json:"hostname"`
 	MachineID    string `json:"machine_id"`
 	AgentVersion string `json:"agent_version"`
 	ScanType     string `json:"scan_type,omitempty"`
 }
 
+// ComplianceCKLInfo identifies an uploaded STIG Viewer checklist. The .ckl
+// itself is sent as the gzip-compressed request body.
+type ComplianceCKLInfo struct {
+	ProfileID string `json:"profile_id"`
+	CommandID string `json:"command_id,omitempty"`
+	Hostname  string `json:"hostname"`
+	MachineID string `json:"machine_id"`
+}
+
+// ComplianceHTMLReportInfo identifies an uploaded OpenSCAP HTML report. The
+// report itself is sent as the gzip-compressed request body.
+type ComplianceHTMLReportInfo struct {
+	ReportID    string `json:"report_id"`
+	ProfileName string `json:"profile_name"`
+	Hostname    string `json:"hostname"`
+	MachineID   string `json:"machine_id"`
+}
+
 // ComplianceResponse represents the response from the compliance endpoint
 type ComplianceResponse struct {
 	Message       string `json:"message"`
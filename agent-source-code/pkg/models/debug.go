@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// DebugSummary counts what a long-running serve process holds on to, for
+// diagnosing slow leaks
+type DebugSummary struct {
+	At                 time.Time `json:"at"`
+	Goroutines         int       `json:"goroutines"`
+	WebSocketConnected bool      `json:"webSocketConnected"`
+	SSHSessions        int       `json:"sshSessions"`
+	RDPSessions        int       `json:"rdpSessions"`
+	Jobs               int       `json:"jobs"` // Jobs still tracked, finished ones included
+	// Queues are serve's internal channels by name
+	Queues    map[string]QueueDepth `json:"queues"`
+	Resources *AgentResources       `json:"resources,omitempty"`
+}
+
+// QueueDepth is how full one channel is
+type QueueDepth struct {
+	Len int `json:"len"`
+	Cap int `json:"cap"`
+}
+
+// DebugDumpReply answers a debug_dump WebSocket message. The goroutine stacks
+// stay on the host, in File.
+type DebugDumpReply struct {
+	Type      string       `json:"type"` // debug_dump
+	CommandID string       `json:"command_id,omitempty"`
+	Summary   DebugSummary `json:"summary"`
+	File      string       `json:"file,omitempty"`
+	Error     string       `json:"error,omitempty"` // Set when the dump file couldn't be written
+}
@@ -0,0 +1,19 @@
+// Package models defines the payloads the PatchMon agent exchanges with the
+// PatchMon server: reports, integration uploads (Docker, compliance, language
+// packages, SBOMs), pings and the server's responses.
+//
+// It is a separate Go module,
+//
+//	go get github.com/PatchMon/PatchMon/agent-source-code/pkg/models
+//
+// with no dependencies outside the standard library, so tools that produce or
+// consume PatchMon payloads can share these types with the agent. Releases are
+// tagged agent-source-code/pkg/models/vX.Y.Z. Fields are only added within a
+// major version; renaming or removing a JSON field needs a new payload schema
+// version (see SchemaVersion) and a new major module version.
+//
+// JSON Schemas for the payloads are generated into the jsonschema directory
+// with go generate.
+package models
+
+//go:generate go run ./internal/schemagen/gen -out jsonschema
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// DockerPruneConfig opts the host in to docker_prune. Dry runs are allowed
+// without it since they change nothing.
+type DockerPruneConfig struct {
+	Enabled          bool `yaml:"enabled" mapstructure:"enabled"`
+	ContainerAgeDays int  `yaml:"container_age_days,omitempty" mapstructure:"container_age_days"` // Stopped containers created this many days ago or earlier are removed when the command doesn't say (default 7)
+}
+
+// DockerPruneItem is a container, image or network removed by docker_prune,
+// or that would be on a dry run
+type DockerPruneItem struct {
+	ID        string `json:"id"`
+	Name      string `json:"name,omitempty"` // Container or network name; empty for dangling images
+	SizeBytes int64  `json:"size_bytes,omitempty"`
+	EngineRef
+}
+
+// DockerPruneResult is the outcome of a docker_prune command
+type DockerPruneResult struct {
+	CommandID        string            `json:"command_id,omitempty"`
+	DryRun           bool              `json:"dry_run"`
+	ContainerAgeDays int               `json:"container_age_days"`
+	Containers       []DockerPruneItem `json:"containers"`
+	Images           []DockerPruneItem `json:"images"`   // Dangling images
+	Networks         []DockerPruneItem `json:"networks"` // Networks no container uses
+	// ReclaimedBytes is the space freed, or on a dry run the space the
+	// containers' writable layers and the images take up
+	ReclaimedBytes int64     `json:"reclaimed_bytes"`
+	Errors         []string  `json:"errors,omitempty"`
+	StartedAt      time.Time `json:"started_at"`
+	CompletedAt    time.Time `json:"completed_at"`
+}
+
+// DockerPrunePayload is sent to the server with the outcome of a prune
+type DockerPrunePayload struct {
+	DockerPruneResult
+	SchemaVersion int `json:"schema_version,omitempty"`
+
+	Hostname     string `json:"hostname"`
+	MachineID    string `json:"machine_id"`
+	AgentVersion string `json:"agent_version"`
+}
+
+// DockerPruneResponse is the server response to a prune result
+type DockerPruneResponse struct {
+	Message string `json:"message"`
+}
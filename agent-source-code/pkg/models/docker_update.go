@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// DockerUpdateConfig is the allowlist of containers the server may update
+// with docker_update_container. Updates are off until it names some.
+type DockerUpdateConfig struct {
+	Containers    []string `yaml:"containers,omitempty" mapstructure:"containers"`         // Container names; globs allowed
+	Labels        []string `yaml:"labels,omitempty" mapstructure:"labels"`                 // "key" or "key=value"; a container with any of them may be updated
+	HealthTimeout int      `yaml:"health_timeout,omitempty" mapstructure:"health_timeout"` // Seconds the new container has to become healthy (default 120)
+}
+
+// Outcomes of a container update
+const (
+	ContainerUpdateUpdated    = "updated"     // Recreated from the new image and healthy
+	ContainerUpdateUpToDate   = "up_to_date"  // The pull brought no new image
+	ContainerUpdateRolledBack = "rolled_back" // The new container failed; the old one is back
+	ContainerUpdateFailed     = "failed"      // Failed before the old container was touched, or the rollback failed too
+)
+
+// DockerContainerUpdateResult is the outcome of a docker_update_container
+// command
+type DockerContainerUpdateResult struct {
+	CommandID     string    `json:"command_id,omitempty"`
+	ContainerName string    `json:"container_name"`
+	Image         string    `json:"image"` // The reference pulled, as the container was created with
+	OldImageID    string    `json:"old_image_id,omitempty"`
+	NewImageID    string    `json:"new_image_id,omitempty"`
+	OldContainer  string    `json:"old_container_id,omitempty"`
+	NewContainer  string    `json:"new_container_id,omitempty"`
+	Status        string    `json:"status"`
+	Error         string    `json:"error,omitempty"`
+	RollbackError string    `json:"rollback_error,omitempty"`
+	StartedAt     time.Time `json:"started_at"`
+	CompletedAt   time.Time `json:"completed_at"`
+	EngineRef
+}
+
+// DockerContainerUpdatePayload is sent to the server with the outcome of a
+// container update
+type DockerContainerUpdatePayload struct {
+	DockerContainerUpdateResult
+	SchemaVersion int `json:"schema_version,omitempty"`
+
+	Hostname     string `json:"hostname"`
+	MachineID    string `json:"machine_id"`
+	AgentVersion string `json:"agent_version"`
+}
+
+// DockerContainerUpdateResponse is the server response to a container update
+// result
+type DockerContainerUpdateResponse struct {
+	Message string `json:"message"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// EnrollRequest exchanges a short-lived enrollment code, displayed in the
+// server UI, for the host's API credentials. The code is single use; the
+// server rejects it once used or expired.
+type EnrollRequest struct {
+	Code           string `json:"code"`
+	Hostname       string `json:"hostname"`
+	MachineID      string `json:"machineId"`
+	OSType         string `json:"osType,omitempty"`
+	OSVersion      string `json:"osVersion,omitempty"`
+	Architecture   string `json:"architecture,omitempty"`
+	AgentVersion   string `json:"agentVersion"`
+	AgentPublicKey string `json:"agentPublicKey,omitempty"` // X25519 key for server-pushed secrets (base64)
+}
+
+// EnrollResponse carries the credentials issued for an enrollment code
+type EnrollResponse struct {
+	APIID  string `json:"apiId"`
+	APIKey string `json:"apiKey"`
+}
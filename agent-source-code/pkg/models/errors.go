@@ -0,0 +1,42 @@
+package models
+
+// ErrorCode identifies why the server refused a request, so the agent can act
+// on it without matching message text
+type ErrorCode string
+
+// Error codes sent by the server in ErrorResponse.Code
+const (
+	// ErrInvalidAPIKey: the API ID or key was rejected. The host has to be
+	// re-registered with new credentials.
+	ErrInvalidAPIKey ErrorCode = "ERR_INVALID_API_KEY"
+	// ErrMachineIDConflict: the machine ID is registered to another host
+	ErrMachineIDConflict ErrorCode = "ERR_MACHINE_ID_CONFLICT"
+	// ErrPayloadTooLarge: the body exceeded the server's limit
+	ErrPayloadTooLarge ErrorCode = "ERR_PAYLOAD_TOO_LARGE"
+	// ErrRateLimited: too many requests; wait RetryAfter before sending again
+	ErrRateLimited ErrorCode = "ERR_RATE_LIMITED"
+	// ErrServerUnavailable: the server is overloaded or in maintenance; wait
+	// RetryAfter before sending again
+	ErrServerUnavailable ErrorCode = "ERR_SERVER_UNAVAILABLE"
+	// ErrSchemaUnsupported: the server can't read this payload schema version
+	ErrSchemaUnsupported ErrorCode = "ERR_SCHEMA_UNSUPPORTED"
+	// ErrValidation: the payload was malformed; sending it again won't help
+	ErrValidation ErrorCode = "ERR_VALIDATION"
+	// ErrSimulationDisabled: the server doesn't enroll simulated hosts; an
+	// administrator has to allow it first
+	ErrSimulationDisabled ErrorCode = "ERR_SIMULATION_DISABLED"
+)
+
+// ErrorResponse is the body the server sends with a failed request. Older
+// servers send only error and message.
+type ErrorResponse struct {
+	Code    ErrorCode `json:"code,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Message string    `json:"message,omitempty"`
+	// RetryAfter is how many seconds to wait before the next request, for
+	// ERR_RATE_LIMITED and ERR_SERVER_UNAVAILABLE
+	RetryAfter int `json:"retryAfter,omitempty"`
+	// ExistingHostname is the host already holding the machine ID, for
+	// ERR_MACHINE_ID_CONFLICT
+	ExistingHostname string `json:"existingHostname,omitempty"`
+}
@@ -0,0 +1,3 @@
+module github.com/PatchMon/PatchMon/agent-source-code/pkg/models
+
+go 1.22
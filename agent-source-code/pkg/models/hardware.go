@@ -0,0 +1,114 @@
+package models
+
+// HardwareInventory is the deep hardware collection run on demand by the
+// hardware_inventory command. The regular report only carries HardwareInfo.
+// Sections the host has no tool or data for are left empty.
+type HardwareInventory struct {
+	DMI             *DMIInfo         `json:"dmi,omitempty"`
+	MemoryModules   []MemoryModule   `json:"memory_modules,omitempty"`
+	PCIDevices      []PCIDevice      `json:"pci_devices,omitempty"`
+	USBDevices      []USBDevice      `json:"usb_devices,omitempty"`
+	RAIDControllers []RAIDController `json:"raid_controllers,omitempty"`
+	// Incomplete is set when the time budget ran out before every section
+	// was collected; Warnings names the sections that were skipped
+	Incomplete bool     `json:"incomplete"`
+	DurationMs int64    `json:"duration_ms"`
+	Warnings   []string `json:"warnings,omitempty"`
+}
+
+// DMIInfo holds the SMBIOS system, baseboard, BIOS and chassis fields
+// reported by dmidecode
+type DMIInfo struct {
+	SystemManufacturer string `json:"system_manufacturer,omitempty"`
+	SystemProduct      string `json:"system_product,omitempty"`
+	SystemVersion      string `json:"system_version,omitempty"`
+	SystemSerial       string `json:"system_serial,omitempty"`
+	SystemUUID         string `json:"system_uuid,omitempty"`
+	SystemSKU          string `json:"system_sku,omitempty"`
+	BIOSVendor         string `json:"bios_vendor,omitempty"`
+	BIOSVersion        string `json:"bios_version,omitempty"`
+	BIOSDate           string `json:"bios_date,omitempty"`
+	BoardManufacturer  string `json:"board_manufacturer,omitempty"`
+	BoardProduct       string `json:"board_product,omitempty"`
+	BoardSerial        string `json:"board_serial,omitempty"`
+	ChassisType        string `json:"chassis_type,omitempty"`
+	ChassisSerial      string `json:"chassis_serial,omitempty"`
+	ChassisAssetTag    string `json:"chassis_asset_tag,omitempty"`
+}
+
+// MemoryModule is one populated DIMM slot (SMBIOS type 17)
+type MemoryModule struct {
+	Locator      string `json:"locator"` // Slot label, e.g. DIMM_A1
+	Size         string `json:"size"`    // As dmidecode prints it, e.g. "16 GB"
+	Type         string `json:"type,omitempty"`
+	Speed        string `json:"speed,omitempty"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	PartNumber   string `json:"part_number,omitempty"`
+	SerialNumber string `json:"serial_number,omitempty"`
+}
+
+// PCIDevice is a device on the PCI bus. Parent is the slot of the bridge it
+// sits behind, so the server can rebuild the device tree.
+type PCIDevice struct {
+	Slot     string `json:"slot"` // Domain:bus:device.function, e.g. 0000:01:00.0
+	Parent   string `json:"parent,omitempty"`
+	ClassID  string `json:"class_id"` // e.g. 0x020000
+	VendorID string `json:"vendor_id"`
+	DeviceID string `json:"device_id"`
+	// Names from lspci, when it is installed
+	Class  string `json:"class,omitempty"`
+	Vendor string `json:"vendor,omitempty"`
+	Device string `json:"device,omitempty"`
+	Driver string `json:"driver,omitempty"` // Kernel driver bound to the device
+}
+
+// USBDevice is a USB device or hub. Parent is the path of the hub it is
+// plugged into; root hubs have none.
+type USBDevice struct {
+	Path         string `json:"path"` // Kernel device path, e.g. 1-1.2, or usb1 for a root hub
+	Parent       string `json:"parent,omitempty"`
+	VendorID     string `json:"vendor_id"`
+	ProductID    string `json:"product_id"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Product      string `json:"product,omitempty"`
+	Serial       string `json:"serial,omitempty"`
+	SpeedMbps    string `json:"speed_mbps,omitempty"`
+}
+
+// RAIDController is a hardware RAID controller reported by storcli or MegaCli
+type RAIDController struct {
+	Tool          string             `json:"tool"` // storcli or megacli
+	Index         int                `json:"index"`
+	Model         string             `json:"model,omitempty"`
+	Serial        string             `json:"serial,omitempty"`
+	Firmware      string             `json:"firmware,omitempty"`
+	Status        string             `json:"status,omitempty"` // Optimal, or Degraded when any virtual drive is not optimal
+	VirtualDrives []RAIDVirtualDrive `json:"virtual_drives,omitempty"`
+}
+
+// RAIDVirtualDrive is a logical drive on a RAID controller
+type RAIDVirtualDrive struct {
+	ID        string `json:"id"` // storcli's DG/VD, or MegaCli's virtual drive number
+	Name      string `json:"name,omitempty"`
+	RAIDLevel string `json:"raid_level,omitempty"`
+	Size      string `json:"size,omitempty"`
+	State     string `json:"state"`
+}
+
+// HardwareInventoryPayload is uploaded in answer to a hardware_inventory
+// command
+type HardwareInventoryPayload struct {
+	HardwareInventory
+	SchemaVersion int `json:"schema_version,omitempty"`
+
+	CommandID    string `json:"command_id,omitempty"`
+	Hostname     string `json:"hostname"`
+	MachineID    string `json:"machine_id"`
+	AgentVersion string `json:"agent_version"`
+}
+
+// HardwareInventoryResponse is the server response to a hardware inventory
+// upload
+type HardwareInventoryResponse struct {
+	Message string `json:"message"`
+}
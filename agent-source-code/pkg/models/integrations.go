@@ -29,6 +29,69 @@ type DockerContainer struct {
 	Labels          map[string]string `json:"labels,omitempty"`
 	NetworkMode     string            `json:"network_mode,omitempty"`
 	RestartCount    int               `json:"restart_count,omitempty"`
+	// Security is unset when the container couldn't be inspected
+	Security *ContainerSecurity `json:"security,omitempty"`
+	// ImagePin is set on running containers
+	ImagePin *DockerImagePin `json:"image_pin,omitempty"`
+	EngineRef
+}
+
+// How a container's image reference was pinned, and how a tag compares with
+// the registry
+const (
+	PinnedByTag    = "tag"
+	PinnedByDigest = "digest"
+	PinnedByID     = "id"
+
+	PinCurrent  = "current"  // The tag still points to the image the container runs
+	PinOutdated = "outdated" // The tag has moved on in the registry
+	PinLocal    = "local"    // The image has no registry digest: built or loaded locally
+	PinUnknown  = "unknown"  // The registry couldn't be asked; see Error
+)
+
+// DockerImagePin tells whether a running container was started from a tag or
+// a pinned digest and, for a tag, whether the image it runs is still what the
+// tag points to in the registry
+type DockerImagePin struct {
+	Reference      string     `json:"reference"`                 // Image as the container was created, e.g. nginx:latest
+	PinnedBy       string     `json:"pinned_by"`                 // tag, digest or id
+	LocalDigest    string     `json:"local_digest,omitempty"`    // Registry digest of the image the container runs
+	RegistryDigest string     `json:"registry_digest,omitempty"` // What the tag points to in the registry
+	Status         string     `json:"status,omitempty"`          // current, outdated, local or unknown; unset for digests, IDs and when the check is off
+	Error          string     `json:"error,omitempty"`
+	CheckedAt      *time.Time `json:"checked_at,omitempty"` // When the registry was asked; lookups are cached
+}
+
+// EngineRef names the container engine an item was found on. Podman keeps a
+// separate store per rootless user, so the same name or ID can appear once
+// per engine.
+type EngineRef struct {
+	Runtime      string `json:"runtime,omitempty"`       // docker or podman
+	RootlessUser string `json:"rootless_user,omitempty"` // Owner of a rootless Podman engine
+}
+
+// ContainerSecurity is the runtime security context of a container, for
+// per-container policy checks
+type ContainerSecurity struct {
+	Privileged      bool             `json:"privileged"`
+	CapAdd          []string         `json:"cap_add,omitempty"`
+	CapDrop         []string         `json:"cap_drop,omitempty"`
+	HostNetwork     bool             `json:"host_network"`
+	HostPID         bool             `json:"host_pid"`
+	HostIPC         bool             `json:"host_ipc"`
+	ReadOnlyRootfs  bool             `json:"read_only_rootfs"`
+	User            string           `json:"user,omitempty"` // As configured; empty means the image default of root
+	RunsAsRoot      bool             `json:"runs_as_root"`
+	SecurityOptions []string         `json:"security_options,omitempty"` // e.g. seccomp=unconfined, no-new-privileges
+	SensitiveMounts []SensitiveMount `json:"sensitive_mounts,omitempty"`
+}
+
+// SensitiveMount is a bind mount of a host path that gives a container
+// control over the host, such as /etc or the Docker socket
+type SensitiveMount struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	ReadOnly    bool   `json:"read_only"`
 }
 
 // DockerImage represents a Docker image
@@ -41,6 +104,7 @@ type DockerImage struct {
 	CreatedAt  *time.Time        `json:"created_at,omitempty"`
 	Digest     string            `json:"digest,omitempty"`
 	Labels     map[string]string `json:"labels,omitempty"`
+	EngineRef
 }
 
 // DockerImageUpdate represents an available update for a Docker image
@@ -66,6 +130,7 @@ type DockerVolume struct {
 	CreatedAt  *time.Time        `json:"created_at,omitempty"`
 	SizeBytes  *int64            `json:"size_bytes,omitempty"` // Usage size if available
 	RefCount   int               `json:"ref_count,omitempty"`  // Number of containers using this volume
+	EngineRef
 }
 
 // DockerNetwork represents a Docker network
@@ -83,6 +148,7 @@ type DockerNetwork struct {
 	IPAM           *DockerIPAM       `json:"ipam,omitempty"` // IP Address Management config
 	CreatedAt      *time.Time        `json:"created_at,omitempty"`
 	ContainerCount int               `json:"container_count,omitempty"` // Number of containers attached
+	EngineRef
 }
 
 // DockerIPAM represents IP Address Management configuration
@@ -107,7 +173,10 @@ type DockerData struct {
 	Volumes    []DockerVolume      `json:"volumes,omitempty"`
 	Networks   []DockerNetwork     `json:"networks,omitempty"`
 	Updates    []DockerImageUpdate `json:"updates"`
-	DaemonInfo *DockerDaemonInfo   `json:"daemon_info,omitempty"`
+	// DaemonInfo is the first engine's; Engines lists them all when the host
+	// runs Docker and Podman, or several rootless Podman users
+	DaemonInfo *DockerDaemonInfo  `json:"daemon_info,omitempty"`
+	Engines    []DockerDaemonInfo `json:"engines,omitempty"`
 }
 
 // DockerDaemonInfo represents Docker daemon information
@@ -119,6 +188,7 @@ type DockerDaemonInfo struct {
 	KernelVersion string `json:"kernel_version"`
 	TotalMemory   int64  `json:"total_memory"`
 	NCPU          int    `json:"ncpu"`
+	EngineRef
 }
 
 // DockerStatusEvent represents a real-time container status change
@@ -129,11 +199,14 @@ type DockerStatusEvent struct {
 	Image       string    `json:"image"`
 	Status      string    `json:"status"`
 	Timestamp   time.Time `json:"timestamp"`
+	EngineRef
 }
 
 // DockerPayload represents the payload sent to the Docker endpoint
 type DockerPayload struct {
 	DockerData
+	SchemaVersion int `json:"schema_version,omitempty"`
+
 	APIID        string `json:"-"` // Sent via header
 	APIKey       string `json:"-"` // Sent via header
 	Hostname     string `json:"hostname"`
@@ -150,3 +223,51 @@ type DockerResponse struct {
 	NetworksReceived   int    `json:"networks_received"`
 	UpdatesFound       int    `json:"updates_found"`
 }
+
+// LanguagePackage is a globally installed language-ecosystem package (pip, npm, gem)
+type LanguagePackage struct {
+	Ecosystem       string   `json:"ecosystem"` // OSV ecosystem: PyPI, npm, RubyGems
+	Source          string   `json:"source"`    // pip, pipx, npm, gem
+	Name            string   `json:"name"`
+	Version         string   `json:"version"`
+	Vulnerabilities []string `json:"vulnerabilities,omitempty"` // OSV advisory IDs affecting this version
+}
+
+// LanguagePackagesData is the language package inventory for a host
+type LanguagePackagesData struct {
+	Packages   []LanguagePackage `json:"packages"`
+	Sources    []string          `json:"sources,omitempty"`
+	OSVChecked bool              `json:"osv_checked"`
+	OSVError   string            `json:"osv_error,omitempty"`
+}
+
+// LanguagePackagesPayload is sent to the server with language package data
+type LanguagePackagesPayload struct {
+	LanguagePackagesData
+	SchemaVersion int `json:"schema_version,omitempty"`
+
+	Hostname     string `json:"hostname"`
+	MachineID    string `json:"machine_id"`
+	AgentVersion string `json:"agent_version"`
+}
+
+// LanguagePackagesResponse is the server response to a language package upload
+type LanguagePackagesResponse struct {
+	Message          string `json:"message"`
+	PackagesReceived int    `json:"packages_received"`
+}
+
+// ImageSBOMInfo identifies the image an uploaded SBOM describes. The SBOM itself
+// is sent as the gzip-compressed request body.
+type ImageSBOMInfo struct {
+	SchemaVersion int `json:"schema_version,omitempty"`
+
+	ImageID    string `json:"image_id"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	Digest     string `json:"digest,omitempty"`
+	Format     string `json:"format"` // cyclonedx-json
+	Tool       string `json:"tool"`   // syft or trivy
+	Hostname   string `json:"hostname"`
+	MachineID  string `json:"machine_id"`
+}
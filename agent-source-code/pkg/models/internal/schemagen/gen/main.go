@@ -0,0 +1,34 @@
+// Command gen writes a JSON Schema file per payload model. Run it through
+// go generate in pkg/models.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models/internal/schemagen"
+)
+
+func main() {
+	out := flag.String("out", "jsonschema", "output directory")
+	flag.Parse()
+
+	docs, err := schemagen.ParseDocs(".")
+	if err != nil {
+		log.Fatalf("failed to read doc comments: %v", err)
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		log.Fatal(err)
+	}
+	for _, root := range schemagen.Roots {
+		data, err := schemagen.Generate(root, docs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(*out, root.Name+".schema.json"), data, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
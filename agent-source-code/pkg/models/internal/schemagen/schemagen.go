@@ -0,0 +1,263 @@
+// Package schemagen generates JSON Schemas for the payload models from their
+// Go types, taking descriptions from the doc comments in the source.
+package schemagen
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/PatchMon/PatchMon/agent-source-code/pkg/models"
+)
+
+// Root is a top-level payload a schema file is generated for
+type Root struct {
+	Name  string // file name without .schema.json
+	Value any
+}
+
+// Roots are the payloads sent or received over the API
+var Roots = []Root{
+	{"report", models.ReportPayload{}},
+	{"update-response", models.UpdateResponse{}},
+	{"ping-request", models.PingRequest{}},
+	{"ping-response", models.PingResponse{}},
+	{"hostname-change", models.HostnameChangeEvent{}},
+	{"package-transaction", models.PackageTransactionPayload{}},
+	{"package-update", models.PackageUpdatePayload{}},
+	{"docker", models.DockerPayload{}},
+	{"docker-response", models.DockerResponse{}},
+	{"docker-status-event", models.DockerStatusEvent{}},
+	{"docker-container-update", models.DockerContainerUpdatePayload{}},
+	{"docker-container-update-response", models.DockerContainerUpdateResponse{}},
+	{"docker-prune", models.DockerPrunePayload{}},
+	{"docker-prune-response", models.DockerPruneResponse{}},
+	{"image-sbom-info", models.ImageSBOMInfo{}},
+	{"compliance", models.CompliancePayload{}},
+	{"compliance-response", models.ComplianceResponse{}},
+	{"language-packages", models.LanguagePackagesPayload{}},
+	{"language-packages-response", models.LanguagePackagesResponse{}},
+	{"user-accounts", models.UserAccountsPayload{}},
+	{"user-accounts-response", models.UserAccountsResponse{}},
+	{"tls-certificates", models.TLSCertificatesPayload{}},
+	{"tls-certificates-response", models.TLSCertificatesResponse{}},
+	{"scheduled-tasks", models.ScheduledTasksPayload{}},
+	{"scheduled-tasks-response", models.ScheduledTasksResponse{}},
+	{"jails", models.JailsPayload{}},
+	{"jails-response", models.JailsResponse{}},
+	{"nspawn", models.NspawnPayload{}},
+	{"nspawn-response", models.NspawnResponse{}},
+	{"proxmox", models.ProxmoxPayload{}},
+	{"proxmox-response", models.ProxmoxResponse{}},
+	{"kubernetes", models.KubernetesPayload{}},
+	{"kubernetes-response", models.KubernetesResponse{}},
+	{"hardware-inventory", models.HardwareInventoryPayload{}},
+	{"hardware-inventory-response", models.HardwareInventoryResponse{}},
+	{"simulated-hosts", models.SimulatedHostsRequest{}},
+	{"simulated-hosts-response", models.SimulatedHostsResponse{}},
+	{"enroll", models.EnrollRequest{}},
+	{"enroll-response", models.EnrollResponse{}},
+	{"error-response", models.ErrorResponse{}},
+	{"command-reply", models.CommandReply{}},
+	{"job-status", models.JobStatusReply{}},
+	{"debug-dump", models.DebugDumpReply{}},
+}
+
+// Docs maps "Type" and "Type.Field" to their doc comments
+type Docs map[string]string
+
+// ParseDocs reads the doc comments of the types declared in dir
+func ParseDocs(dir string) (Docs, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	docs := Docs{}
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range file.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+				docs.addTypes(gen)
+			}
+		}
+	}
+	return docs, nil
+}
+
+func (d Docs) addTypes(gen *ast.GenDecl) {
+	for _, spec := range gen.Specs {
+		ts := spec.(*ast.TypeSpec)
+		doc := ts.Doc
+		if doc == nil && len(gen.Specs) == 1 {
+			doc = gen.Doc
+		}
+		if text := commentText(doc); text != "" {
+			d[ts.Name.Name] = text
+		}
+		st, ok := ts.Type.(*ast.StructType)
+		if !ok {
+			continue
+		}
+		for _, field := range st.Fields.List {
+			text := commentText(field.Doc)
+			if text == "" {
+				text = commentText(field.Comment)
+			}
+			if text == "" {
+				continue
+			}
+			for _, name := range field.Names {
+				d[ts.Name.Name+"."+name.Name] = text
+			}
+		}
+	}
+}
+
+func commentText(g *ast.CommentGroup) string {
+	if g == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(g.Text()), " ")
+}
+
+type generator struct {
+	docs Docs
+	defs map[string]map[string]any
+}
+
+// Generate returns the JSON Schema (draft 2020-12) for root. Named struct
+// types other than the root go into $defs.
+func Generate(root Root, docs Docs) ([]byte, error) {
+	g := &generator{docs: docs, defs: map[string]map[string]any{}}
+	t := reflect.TypeOf(root.Value)
+	schema := g.object(t)
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["$id"] = "https://github.com/PatchMon/PatchMon/agent-source-code/pkg/models/jsonschema/" + root.Name + ".schema.json"
+	schema["title"] = t.Name()
+	schema["x-schema-version"] = models.SchemaVersion
+	if len(g.defs) > 0 {
+		schema["$defs"] = g.defs
+	}
+	out, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", root.Name, err)
+	}
+	return append(out, '\n'), nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema returns the schema for a value of type t
+func (g *generator) schema(t reflect.Type) map[string]any {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		if _, ok := g.defs[t.Name()]; !ok {
+			g.defs[t.Name()] = nil // placeholder for recursive types
+			g.defs[t.Name()] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	}
+	return map[string]any{}
+}
+
+// object returns the schema for struct t, flattening embedded structs the way
+// encoding/json does
+func (g *generator) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	g.fields(t, properties, &required)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	if doc := g.docs[t.Name()]; doc != "" {
+		schema["description"] = doc
+	}
+	return schema
+}
+
+func (g *generator) fields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			g.fields(f.Type, properties, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := g.schema(f.Type)
+		omitEmpty := strings.Contains(opts, "omitempty")
+		if !omitEmpty && nullable(f.Type) {
+			prop = map[string]any{"anyOf": []any{prop, map[string]any{"type": "null"}}}
+		}
+		if doc := g.docs[t.Name()+"."+f.Name]; doc != "" {
+			if _, isRef := prop["$ref"]; isRef {
+				prop = map[string]any{"allOf": []any{prop}}
+			}
+			prop["description"] = doc
+		}
+		properties[name] = prop
+		if !omitEmpty {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// nullable reports whether encoding/json can write null for a zero t
+func nullable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Interface:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Uint8
+	}
+	return false
+}
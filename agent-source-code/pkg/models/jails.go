@@ -0,0 +1,40 @@
+package models
+
+// Jail is a FreeBSD jail running on the host, reported as a child of the
+// host the way Docker containers are
+type Jail struct {
+	JID             int       `json:"jid"`
+	Name            string    `json:"name"`
+	Hostname        string    `json:"hostname,omitempty"`
+	Path            string    `json:"path"`                       // Root directory on the host
+	IPv4            []string  `json:"ipv4,omitempty"`             // ip4.addr
+	IPv6            []string  `json:"ipv6,omitempty"`             // ip6.addr
+	OSRelease       string    `json:"os_release,omitempty"`       // Kernel release the jail reports (osrelease)
+	UserlandVersion string    `json:"userland_version,omitempty"` // Version of the jail's base system, from its freebsd-version
+	PackageCount    int       `json:"package_count"`
+	Updates         []Package `json:"updates"` // Installed pkg packages with an update available
+	SecurityUpdates int       `json:"security_updates"`
+	Error           string    `json:"error,omitempty"` // Set when pkg could not be run inside the jail
+}
+
+// JailsData is the jail inventory for a FreeBSD host
+type JailsData struct {
+	Jails    []Jail   `json:"jails"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// JailsPayload is sent to the server with jail data
+type JailsPayload struct {
+	JailsData
+	SchemaVersion int `json:"schema_version,omitempty"`
+
+	Hostname     string `json:"hostname"`
+	MachineID    string `json:"machine_id"`
+	AgentVersion string `json:"agent_version"`
+}
+
+// JailsResponse is the server response to a jail upload
+type JailsResponse struct {
+	Message       string `json:"message"`
+	JailsReceived int    `json:"jails_received"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Job states
+const (
+	JobQueued    = "queued"
+	JobWaiting   = "waiting" // Held until a maintenance window opens
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// Job is an asynchronous server command (a scan, remediation, update, batch,
+// ...) tracked by the agent from the moment it is received until it ends
+type Job struct {
+	// ID is the command's command_id, or one the agent generated if the
+	// server sent none
+	ID         string     `json:"id"`
+	Command    string     `json:"command"`
+	State      string     `json:"state"` // queued, waiting, running, succeeded, failed, cancelled
+	QueuedAt   time.Time  `json:"queuedAt"`
+	RunAfter   *time.Time `json:"runAfter,omitempty"` // When a waiting job's maintenance window opens
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// JobStatusReply answers a job_status WebSocket message
+type JobStatusReply struct {
+	Type      string `json:"type"` // job_status
+	CommandID string `json:"command_id,omitempty"`
+	// Jobs holds the requested job, or every job the agent still tracks
+	Jobs []Job `json:"jobs"`
+}
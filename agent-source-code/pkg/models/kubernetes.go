@@ -0,0 +1,50 @@
+package models
+
+// KubernetesNodeCondition is one of the conditions the kubelet reports on its
+// Node object (Ready, MemoryPressure, DiskPressure, PIDPressure, ...)
+type KubernetesNodeCondition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"` // True, False or Unknown
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	LastTransitionTime string `json:"last_transition_time,omitempty"`
+}
+
+// KubernetesData is the state of the Kubernetes node this agent runs on
+type KubernetesData struct {
+	NodeName     string `json:"node_name"`
+	Distribution string `json:"distribution"` // kubeadm, k3s or rke2
+	// KubeletVersion is the version of the kubelet binary, which can differ
+	// from the one the node reports until the kubelet is restarted
+	KubeletVersion    string `json:"kubelet_version,omitempty"`
+	ContainerdVersion string `json:"containerd_version,omitempty"`
+	// The fields below come from the Node object and are unset when the API
+	// server could not be reached with the node's credentials
+	NodeKubeletVersion string                    `json:"node_kubelet_version,omitempty"`
+	ContainerRuntime   string                    `json:"container_runtime,omitempty"` // e.g. containerd://1.7.19
+	KernelVersion      string                    `json:"kernel_version,omitempty"`
+	OSImage            string                    `json:"os_image,omitempty"`
+	Roles              []string                  `json:"roles,omitempty"`
+	Unschedulable      bool                      `json:"unschedulable"` // Cordoned
+	Conditions         []KubernetesNodeCondition `json:"conditions,omitempty"`
+	// Packages are the installed Kubernetes and container runtime packages.
+	// AvailableVersion and NeedsUpdate come from the host's last package
+	// report.
+	Packages []Package `json:"packages"`
+	Warnings []string  `json:"warnings,omitempty"`
+}
+
+// KubernetesPayload is sent to the server with Kubernetes node data
+type KubernetesPayload struct {
+	KubernetesData
+	SchemaVersion int `json:"schema_version,omitempty"`
+
+	Hostname     string `json:"hostname"`
+	MachineID    string `json:"machine_id"`
+	AgentVersion string `json:"agent_version"`
+}
+
+// KubernetesResponse is the server response to a Kubernetes node upload
+type KubernetesResponse struct {
+	Message string `json:"message"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// MaintenanceConfig holds disruptive server commands back until one of the
+// windows is open
+type MaintenanceConfig struct {
+	Windows  []MaintenanceWindow `yaml:"windows" mapstructure:"windows"`
+	Timezone string              `yaml:"timezone,omitempty" mapstructure:"timezone"` // IANA zone the windows are in (default: the host's)
+	// Actions are the server commands held back (default: agent updates,
+	// patching, package and container updates and remediation)
+	Actions []string `yaml:"actions,omitempty" mapstructure:"actions"`
+}
+
+// MaintenanceWindow is a weekly time range (Days, Start and End) or a
+// recurring one opening on a cron schedule (Cron and Duration)
+type MaintenanceWindow struct {
+	Name     string   `yaml:"name,omitempty" mapstructure:"name"`
+	Days     []string `yaml:"days,omitempty" mapstructure:"days"`         // mon..sun or ranges like mon-fri (default every day)
+	Start    string   `yaml:"start,omitempty" mapstructure:"start"`       // HH:MM
+	End      string   `yaml:"end,omitempty" mapstructure:"end"`           // HH:MM; before Start means the window ends the next day
+	Cron     string   `yaml:"cron,omitempty" mapstructure:"cron"`         // minute hour day-of-month month day-of-week
+	Duration string   `yaml:"duration,omitempty" mapstructure:"duration"` // How long a cron window stays open, e.g. 2h
+}
+
+// MaintenanceStatus tells the server whether disruptive commands run now
+type MaintenanceStatus struct {
+	Open     bool       `json:"open"`
+	Window   string     `json:"window,omitempty"`   // Name of the open window
+	NextOpen *time.Time `json:"nextOpen,omitempty"` // When the next window opens, while none is open
+	Error    string     `json:"error,omitempty"`    // The windows in config.yml are invalid; held commands are refused
+}
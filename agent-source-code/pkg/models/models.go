@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // Package represents a software package
 type Package struct {
 	Name             string `json:"name"`
@@ -10,6 +12,16 @@ type Package struct {
 	NeedsUpdate      bool   `json:"needsUpdate"`
 	IsSecurityUpdate bool   `json:"isSecurityUpdate"`
 	SourceRepository string `json:"sourceRepository,omitempty"`
+	// Classification of SourceRepository: "distro", "vendor" or "custom"
+	SourceClassification string `json:"sourceClassification,omitempty"`
+	SourceVendor         string `json:"sourceVendor,omitempty"`
+	// PhasedUpdate marks an AvailableVersion that Ubuntu is still phasing in
+	// and apt holds back on this host for now. NeedsUpdate stays false until
+	// the rollout reaches the host.
+	PhasedUpdate bool `json:"phasedUpdate,omitempty"`
+	// PendingSince is when the agent first saw this package needing an
+	// update, kept until it is updated
+	PendingSince *time.Time `json:"pendingSince,omitempty"`
 	// WUA fields - only populated for Category="Windows Update" entries
 	WUAGuid           string   `json:"wuaGuid,omitempty"`
 	WUAKb             string   `json:"wuaKb,omitempty"`
@@ -28,6 +40,12 @@ type Repository struct {
 	RepoType     string `json:"repoType"`
 	IsEnabled    bool   `json:"isEnabled"`
 	IsSecure     bool   `json:"isSecure"`
+	// Classification is "distro", "vendor" or "custom"; Vendor names the distro or vendor when known
+	Classification string `json:"classification,omitempty"`
+	Vendor         string `json:"vendor,omitempty"`
+	// EntitlementGated marks repositories served only to hosts with an active
+	// subscription (Red Hat CDN, SUSE, Ubuntu Pro)
+	EntitlementGated bool `json:"entitlementGated,omitempty"`
 }
 
 // SystemInfo represents system information
@@ -36,6 +54,7 @@ type SystemInfo struct {
 	SELinuxStatus string    `json:"selinuxStatus"`
 	SystemUptime  string    `json:"systemUptime"`
 	LoadAverage   []float64 `json:"loadAverage"`
+	Jailed        bool      `json:"jailed,omitempty"` // Running inside a FreeBSD jail
 }
 
 // HardwareInfo represents hardware information
@@ -45,6 +64,25 @@ type HardwareInfo struct {
 	RAMInstalled float64    `json:"ramInstalled"` // GB
 	SwapSize     float64    `json:"swapSize"`     // GB
 	DiskDetails  []DiskInfo `json:"diskDetails"`
+	GPUs         []GPUInfo  `json:"gpus,omitempty"`
+}
+
+// GPUInfo describes a GPU and the driver and compute stack serving it
+type GPUInfo struct {
+	Vendor        string `json:"vendor"` // nvidia, amd or intel
+	Model         string `json:"model,omitempty"`
+	PCISlot       string `json:"pciSlot"`
+	MemoryMB      int    `json:"memoryMb,omitempty"`
+	Driver        string `json:"driver,omitempty"`        // Kernel driver bound to the GPU, e.g. nvidia, amdgpu, i915
+	DriverVersion string `json:"driverVersion,omitempty"` // Empty for in-tree drivers, which carry the kernel's version
+	// CUDAVersion is the newest CUDA the NVIDIA driver supports;
+	// CUDAToolkitVersion is the toolkit installed under /usr/local/cuda
+	CUDAVersion        string `json:"cudaVersion,omitempty"`
+	CUDAToolkitVersion string `json:"cudaToolkitVersion,omitempty"`
+	ROCmVersion        string `json:"rocmVersion,omitempty"`
+	// DriverUpdates are the packages of this vendor's driver stack with an
+	// update available
+	DriverUpdates []string `json:"driverUpdates,omitempty"`
 }
 
 // DiskInfo represents disk information
@@ -83,32 +121,179 @@ type NetworkAddress struct {
 
 // ReportPayload represents the data sent to the server
 type ReportPayload struct {
-	Packages               []Package          `json:"packages"`
-	Repositories           []Repository       `json:"repositories"`
-	OSType                 string             `json:"osType"`
-	OSVersion              string             `json:"osVersion"`
-	Hostname               string             `json:"hostname"`
-	IP                     string             `json:"ip"`
-	Architecture           string             `json:"architecture"`
-	AgentVersion           string             `json:"agentVersion"`
-	MachineID              string             `json:"machineId"`
-	KernelVersion          string             `json:"kernelVersion"`
-	InstalledKernelVersion string             `json:"installedKernelVersion,omitempty"`
-	SELinuxStatus          string             `json:"selinuxStatus"`
-	SystemUptime           string             `json:"systemUptime"`
-	LoadAverage            []float64          `json:"loadAverage"`
-	CPUModel               string             `json:"cpuModel"`
-	CPUCores               int                `json:"cpuCores"`
-	RAMInstalled           float64            `json:"ramInstalled"`
-	SwapSize               float64            `json:"swapSize"`
-	DiskDetails            []DiskInfo         `json:"diskDetails"`
-	GatewayIP              string             `json:"gatewayIp"`
-	DNSServers             []string           `json:"dnsServers"`
-	NetworkInterfaces      []NetworkInterface `json:"networkInterfaces"`
-	ExecutionTime          float64            `json:"executionTime"` // Collection time in seconds
-	NeedsReboot            bool               `json:"needsReboot"`
-	RebootReason           string             `json:"rebootReason,omitempty"`
-	PackageManager         string             `json:"packageManager,omitempty"`
+	SchemaVersion int `json:"schemaVersion,omitempty"` // See SchemaVersion; left out for schema 1
+
+	Packages               []Package           `json:"packages"`
+	Repositories           []Repository        `json:"repositories"`
+	OSType                 string              `json:"osType"`
+	OSVersion              string              `json:"osVersion"`
+	Hostname               string              `json:"hostname"`
+	IP                     string              `json:"ip"`
+	Architecture           string              `json:"architecture"`
+	AgentVersion           string              `json:"agentVersion"`
+	MachineID              string              `json:"machineId"`
+	KernelVersion          string              `json:"kernelVersion"`
+	InstalledKernelVersion string              `json:"installedKernelVersion,omitempty"`
+	SELinuxStatus          string              `json:"selinuxStatus"`
+	SystemUptime           string              `json:"systemUptime"`
+	LoadAverage            []float64           `json:"loadAverage"`
+	Jailed                 bool                `json:"jailed,omitempty"` // Running inside a FreeBSD jail; kernel and reboot state are the host's
+	CPUModel               string              `json:"cpuModel"`
+	CPUCores               int                 `json:"cpuCores"`
+	RAMInstalled           float64             `json:"ramInstalled"`
+	SwapSize               float64             `json:"swapSize"`
+	DiskDetails            []DiskInfo          `json:"diskDetails"`
+	GPUs                   []GPUInfo           `json:"gpus,omitempty"`
+	GatewayIP              string              `json:"gatewayIp"`
+	DNSServers             []string            `json:"dnsServers"`
+	NetworkInterfaces      []NetworkInterface  `json:"networkInterfaces"`
+	ExecutionTime          float64             `json:"executionTime"` // Collection time in seconds
+	NeedsReboot            bool                `json:"needsReboot"`
+	RebootReason           string              `json:"rebootReason,omitempty"`
+	PackageManager         string              `json:"packageManager,omitempty"`
+	PackageCountSuspect    bool                `json:"packageCountSuspect,omitempty"` // Package count dropped implausibly vs. recent history
+	Warnings               []string            `json:"warnings,omitempty"`
+	PreviousHostname       string              `json:"previousHostname,omitempty"`  // Set when the hostname changed since the last report
+	Sections               SectionStatuses     `json:"sections,omitempty"`          // Per-section collection status
+	RefreshedSections      []string            `json:"refreshedSections,omitempty"` // Set on partial reports: the sections collected again; the rest is from the previous report
+	SSHDConfig             *SSHDConfig         `json:"sshdConfig,omitempty"`        // Effective sshd settings; nil when no OpenSSH server is installed
+	KeyServices            []KeyService        `json:"keyServices,omitempty"`       // Web servers, databases and caches with their versions
+	Subscription           *SubscriptionStatus `json:"subscription,omitempty"`      // RHSM, SUSEConnect or Ubuntu Pro state; nil when the host has none of them
+	Boot                   *BootInfo           `json:"boot,omitempty"`              // Set on the first report after a reboot
+}
+
+// Section status values
+const (
+	SectionOK       = "ok"
+	SectionDegraded = "degraded"
+	SectionFailed   = "failed"
+)
+
+// SectionStatuses maps a report section (packages, hardware, docker, ...) to
+// how its collection went
+type SectionStatuses map[string]SectionStatus
+
+// SectionStatus tells the server whether a report section was collected fully,
+// partially or not at all, so stale data can be explained instead of guessed at
+type SectionStatus struct {
+	Status    string     `json:"status"` // ok, degraded, failed
+	Error     string     `json:"error,omitempty"`
+	CheckedAt *time.Time `json:"checkedAt,omitempty"` // Set for integrations, which are collected after the report is sent
+}
+
+// HostnameChangeEvent tells the server a host was renamed, so it updates the
+// existing host instead of treating the new name as a new machine
+type HostnameChangeEvent struct {
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+
+	PreviousHostname string    `json:"previousHostname"`
+	Hostname         string    `json:"hostname"`
+	MachineID        string    `json:"machineId"`
+	ChangedAt        time.Time `json:"changedAt"`
+}
+
+// ConnectivityCheck holds the result of a DNS and transport self-test against the server.
+// Distinguishes "server down" from "local resolver broken" and from middleboxes that
+// drop large TLS records (typically an MTU/PMTUD black hole).
+type ConnectivityCheck struct {
+	CheckedAt        string   `json:"checkedAt"`
+	ServerHost       string   `json:"serverHost"`
+	HostIsIP         bool     `json:"hostIsIp,omitempty"`
+	SystemDNSOK      bool     `json:"systemDnsOk"`
+	SystemDNSAddrs   []string `json:"systemDnsAddrs,omitempty"`
+	SystemDNSError   string   `json:"systemDnsError,omitempty"`
+	FallbackResolver string   `json:"fallbackResolver,omitempty"`
+	FallbackDNSOK    bool     `json:"fallbackDnsOk"`
+	FallbackDNSAddrs []string `json:"fallbackDnsAddrs,omitempty"`
+	FallbackDNSError string   `json:"fallbackDnsError,omitempty"`
+	TCPReachable     bool     `json:"tcpReachable"`
+	TCPReachableByIP bool     `json:"tcpReachableByIp,omitempty"` // Reached via fallback-resolved IP when system DNS failed
+	DoHFallbackOK    bool     `json:"dohFallbackOk,omitempty"`    // Resolved over dns_over_https after system DNS failed
+	DoHFallbackAddrs []string `json:"dohFallbackAddrs,omitempty"`
+	DoHFallbackError string   `json:"dohFallbackError,omitempty"`
+	LargeRequestOK   bool     `json:"largeRequestOk"`
+	LargeRequestErr  string   `json:"largeRequestError,omitempty"`
+	// AddressFamilies is the server's TCP reachability over IPv4 and IPv6
+	// separately; Happy Eyeballs hides a broken family from TCPReachable
+	AddressFamilies []AddressFamilyCheck `json:"addressFamilies,omitempty"`
+	Problems        []string             `json:"problems,omitempty"`
+}
+
+// AddressFamilyCheck is the connectivity self-test for one address family
+type AddressFamilyCheck struct {
+	Family    string   `json:"family"`          // ipv4 or ipv6
+	Routed    bool     `json:"routed"`          // This host has a route for the family
+	Addrs     []string `json:"addrs,omitempty"` // The server's addresses in the family
+	Reachable bool     `json:"reachable"`       // A TCP connection to the first address succeeded
+	Error     string   `json:"error,omitempty"`
+}
+
+// AgentHealth is the agent's self-reported health state
+type AgentHealth struct {
+	Connectivity       *ConnectivityCheck `json:"connectivity,omitempty"`
+	ClockSkewSeconds   *float64           `json:"clockSkewSeconds,omitempty"`
+	LastReportAt       *time.Time         `json:"lastReportAt,omitempty"`
+	WebSocketDownSince *time.Time         `json:"webSocketDownSince,omitempty"`
+	LastIncident       *WatchdogIncident  `json:"lastIncident,omitempty"`
+	Resources          *AgentResources    `json:"resources,omitempty"`
+}
+
+// AgentResources is the serve process's own resource use, sampled
+// periodically so slow leaks show up before they hurt the host
+type AgentResources struct {
+	SampledAt  time.Time `json:"sampledAt"`
+	CPUPercent float64   `json:"cpuPercent"` // Average over the last sample interval, of one CPU
+	RSSBytes   uint64    `json:"rssBytes"`
+	HeapBytes  uint64    `json:"heapBytes"`           // Go heap in use
+	OpenFiles  int       `json:"openFiles,omitempty"` // File descriptors; left out where the OS doesn't say
+	Goroutines int       `json:"goroutines"`
+}
+
+// WatchdogIncident records a window of silence detected by the serve watchdog
+// and the recovery steps taken
+type WatchdogIncident struct {
+	StartedAt  time.Time  `json:"startedAt"`
+	Reason     string     `json:"reason"`
+	Actions    []string   `json:"actions"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+	// LastRestartAt is when the watchdog last restarted the agent for this
+	// incident; the restart cooldown is measured from it
+	LastRestartAt *time.Time `json:"lastRestartAt,omitempty"`
+}
+
+// PingRequest is the optional body of a ping
+type PingRequest struct {
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+
+	ClockSkewSeconds *float64                 `json:"clockSkewSeconds,omitempty"` // Local clock minus server clock
+	Status           string                   `json:"status,omitempty"`           // "active" or "paused"; sent by serve
+	Paused           *PauseState              `json:"paused,omitempty"`
+	AgentPublicKey   string                   `json:"agentPublicKey,omitempty"` // X25519 key for server-pushed secrets (base64)
+	ObserverMode     bool                     `json:"observerMode,omitempty"`   // Mutating server commands are refused
+	Permissions      map[string]bool          `json:"permissions,omitempty"`    // Effective allow_* flags from config.yml
+	LastActions      map[string]*RemoteAction `json:"lastActions,omitempty"`    // Last run of each server command type
+	Jobs             []Job                    `json:"jobs,omitempty"`           // Running and recently finished jobs
+	Resources        *AgentResources          `json:"resources,omitempty"`      // The agent's own CPU, memory and descriptor use
+	Maintenance      *MaintenanceStatus       `json:"maintenance,omitempty"`    // Set when maintenance windows are configured
+}
+
+// RemoteAction records the last run of one server command type, so operators
+// can check that e.g. a forced update actually ran on every host
+type RemoteAction struct {
+	At           time.Time `json:"at"`
+	ParamsDigest string    `json:"paramsDigest,omitempty"` // sha256 of the command's non-secret parameters
+	Outcome      string    `json:"outcome"`                // running, success, failed, cancelled, refused, skipped, interrupted, deferred
+	Error        string    `json:"error,omitempty"`
+	AgentVersion string    `json:"agentVersion,omitempty"` // agent version that received the command
+}
+
+// PauseState records a temporary suspension of reporting, scans and remote
+// actions. The agent stays connected so the host doesn't show as offline.
+type PauseState struct {
+	PausedAt time.Time `json:"pausedAt"`
+	Until    time.Time `json:"until"`
+	Reason   string    `json:"reason,omitempty"`
+	Source   string    `json:"source"` // cli or server
 }
 
 // PingResponse represents server ping response
@@ -119,6 +304,7 @@ type PingResponse struct {
 	AgentStartup  bool               `json:"agentStartup,omitempty"`
 	Integrations  map[string]bool    `json:"integrations,omitempty"` // Server-side integration enable states
 	CrontabUpdate *CrontabUpdateInfo `json:"crontabUpdate,omitempty"`
+	SchemaVersion int                `json:"schemaVersion,omitempty"` // Newest payload schema the server accepts; 0 means the current one
 }
 
 // UpdateResponse represents server update response
@@ -129,6 +315,8 @@ type UpdateResponse struct {
 	SecurityUpdates   int                `json:"securityUpdates,omitempty"`
 	AutoUpdate        *AutoUpdateInfo    `json:"autoUpdate,omitempty"`
 	CrontabUpdate     *CrontabUpdateInfo `json:"crontabUpdate,omitempty"`
+	PayloadHash       string             `json:"payloadHash,omitempty"` // SHA-256 of the body the server processed, echoed back
+	Duplicate         bool               `json:"duplicate,omitempty"`   // Server had already processed this idempotency key
 }
 
 // AutoUpdateInfo represents agent auto-update information
@@ -196,6 +384,10 @@ type IntegrationSetupStatus struct {
 	Components    map[string]string         `json:"components,omitempty"` // Component name -> status
 	ScannerInfo   *ComplianceScannerDetails `json:"scanner_info,omitempty"`
 	InstallEvents []InstallEvent            `json:"install_events,omitempty"`
+	// Sequence orders statuses across retries and agent restarts: the server
+	// should drop a status older than the last one it stored for the integration
+	Sequence  int64  `json:"sequence,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"` // RFC 3339, when the agent recorded the status
 }
 
 // ComplianceScannerDetails contains detailed OpenSCAP scanner information
@@ -211,6 +403,8 @@ type ComplianceScannerDetails struct {
 	SSGMinVersion     string `json:"ssg_min_version,omitempty"`     // Minimum required version for this OS
 	SSGNeedsUpgrade   bool   `json:"ssg_needs_upgrade,omitempty"`   // True if upgrade is recommended
 	SSGUpgradeMessage string `json:"ssg_upgrade_message,omitempty"` // Message explaining why upgrade is needed
+	// ContentUpdatedAt is when the content file last changed on disk
+	ContentUpdatedAt *time.Time `json:"content_updated_at,omitempty"`
 
 	// Available scan profiles
 	AvailableProfiles []ScanProfileInfo `json:"available_profiles,omitempty"`
@@ -218,9 +412,17 @@ type ComplianceScannerDetails struct {
 	// Docker Bench info
 	DockerBenchAvailable bool   `json:"docker_bench_available"`
 	DockerBenchVersion   string `json:"docker_bench_version,omitempty"`
+	DockerBenchImage     string `json:"docker_bench_image,omitempty"` // Local image as repo@sha256:digest
 
 	// oscap-docker info (for Docker image CVE scanning)
-	OscapDockerAvailable bool `json:"oscap_docker_available"`
+	OscapDockerAvailable bool            `json:"oscap_docker_available"`
+	OscapDockerVersion   string          `json:"oscap_docker_version,omitempty"`
+	CVEFeeds             []CVEFeedStatus `json:"cve_feeds,omitempty"` // Cached OVAL feeds image scans evaluate
+
+	// SBOM tools, when installed
+	SyftVersion      string     `json:"syft_version,omitempty"`
+	TrivyVersion     string     `json:"trivy_version,omitempty"`
+	TrivyDBUpdatedAt *time.Time `json:"trivy_db_updated_at,omitempty"`
 
 	// OS info for content matching
 	OSName    string `json:"os_name,omitempty"`
@@ -232,6 +434,13 @@ type ComplianceScannerDetails struct {
 	MismatchWarning string `json:"mismatch_warning,omitempty"`
 }
 
+// CVEFeedStatus is the age of a cached CVE feed
+type CVEFeedStatus struct {
+	Name         string    `json:"name"`          // e.g. "rhel-9.oval.xml"
+	LastModified time.Time `json:"last_modified"` // When the feed was published upstream
+	CheckedAt    time.Time `json:"checked_at"`    // When the mirror was last asked for a newer one
+}
+
 // ScanProfileInfo describes an available scan profile
 type ScanProfileInfo struct {
 	ID          string `json:"id"`                    // Internal ID (e.g., "level1_server") or full XCCDF ID
@@ -260,6 +469,8 @@ type ComplianceScanOptions struct {
 type Credentials struct {
 	APIID  string `yaml:"api_id" mapstructure:"api_id"`
 	APIKey string `yaml:"api_key" mapstructure:"api_key"`
+	// Observer marks a read-only credential: the agent refuses mutating server commands
+	Observer bool `yaml:"observer,omitempty" mapstructure:"observer"`
 }
 
 // Config represents agent configuration
@@ -275,4 +486,89 @@ type Config struct {
 	PackageCacheRefreshMode   string                 `yaml:"package_cache_refresh_mode" mapstructure:"package_cache_refresh_mode"`       // always, if_stale, never
 	PackageCacheRefreshMaxAge int                    `yaml:"package_cache_refresh_max_age" mapstructure:"package_cache_refresh_max_age"` // minutes
 	Integrations              map[string]interface{} `yaml:"integrations" mapstructure:"integrations"`                                   // Supports bool for simple integrations, string for compliance mode
+	FallbackDNSServers        []string               `yaml:"fallback_dns_servers,omitempty" mapstructure:"fallback_dns_servers"`         // host:port resolvers used by the connectivity self-test
+	DNSOverHTTPS              []string               `yaml:"dns_over_https,omitempty" mapstructure:"dns_over_https"`                     // DoH URLs (IP host) for resolving the server when the system resolver fails; empty disables
+	IgnorePackages            []string               `yaml:"ignore_packages,omitempty" mapstructure:"ignore_packages"`                   // Globs, or "regex:<expr>", excluded from reports
+	IgnoreRepositories        []string               `yaml:"ignore_repositories,omitempty" mapstructure:"ignore_repositories"`           // Matched against repository name and URL
+	DockerSBOM                bool                   `yaml:"docker_sbom,omitempty" mapstructure:"docker_sbom"`                           // Generate and upload CycloneDX SBOMs for local images (needs syft or trivy)
+	DockerDigestHours         *int                   `yaml:"docker_digest_hours,omitempty" mapstructure:"docker_digest_hours"`           // Ask registries what running containers' tags point to this often (default 6, 0 = off)
+	DisablePackageWatch       bool                   `yaml:"disable_package_watch,omitempty" mapstructure:"disable_package_watch"`       // Don't report immediately when the package database changes
+	LocalAPIListen            string                 `yaml:"local_api_listen,omitempty" mapstructure:"local_api_listen"`                 // "unix", "unix:/path.sock" or "127.0.0.1:port"; empty disables
+	LocalAPIToken             string                 `yaml:"local_api_token,omitempty" mapstructure:"local_api_token"`                   // Bearer token; required for TCP
+	LocalAPIGroup             string                 `yaml:"local_api_group,omitempty" mapstructure:"local_api_group"`                   // Group (name or GID) given the local API socket and its directory
+	MetricsListen             string                 `yaml:"metrics_listen,omitempty" mapstructure:"metrics_listen"`                     // host:port for serve's Prometheus /metrics; empty disables
+	HostnameOverride          string                 `yaml:"hostname_override,omitempty" mapstructure:"hostname_override"`               // Reported instead of the detected hostname
+	UseFQDN                   bool                   `yaml:"use_fqdn,omitempty" mapstructure:"use_fqdn"`                                 // Report the fully qualified domain name
+	WSPingInterval            int                    `yaml:"ws_ping_interval,omitempty" mapstructure:"ws_ping_interval"`                 // Seconds between WebSocket pings (default 30)
+	WSReadTimeout             int                    `yaml:"ws_read_timeout,omitempty" mapstructure:"ws_read_timeout"`                   // Seconds without a pong before reconnecting (default 90)
+	AutoInstallTools          *bool                  `yaml:"auto_install_tools,omitempty" mapstructure:"auto_install_tools"`             // Let scanners install packages and pull images (default true)
+	DockerBenchScript         string                 `yaml:"docker_bench_script,omitempty" mapstructure:"docker_bench_script"`           // Local docker-bench-security.sh to run instead of the image
+	DockerBenchImage          string                 `yaml:"docker_bench_image,omitempty" mapstructure:"docker_bench_image"`             // Docker Bench image, optionally pinned as repo@sha256:digest
+	ImageScanConcurrency      int                    `yaml:"image_scan_concurrency,omitempty" mapstructure:"image_scan_concurrency"`     // Docker images scanned for CVEs at once (default 2, max 8)
+	ImageScanTimeout          int                    `yaml:"image_scan_timeout,omitempty" mapstructure:"image_scan_timeout"`             // Seconds allowed per image CVE scan (default 900)
+	ImageScanSkipHours        *int                   `yaml:"image_scan_skip_hours,omitempty" mapstructure:"image_scan_skip_hours"`       // Skip images scanned this recently when scanning all (default 24, 0 = never)
+	ImageScanCacheHours       *int                   `yaml:"image_scan_cache_hours,omitempty" mapstructure:"image_scan_cache_hours"`     // Reuse an unchanged image's CVE results this long (default 168, 0 = off)
+	CVEFeedCache              *bool                  `yaml:"cve_feed_cache,omitempty" mapstructure:"cve_feed_cache"`                     // Keep OVAL CVE feeds on disk for image scans (default true)
+	CVEFeedURL                string                 `yaml:"cve_feed_url,omitempty" mapstructure:"cve_feed_url"`                         // OVAL feed mirror: http(s)://, file:// or a directory (default Red Hat)
+	CVEFeedMaxAge             int                    `yaml:"cve_feed_max_age,omitempty" mapstructure:"cve_feed_max_age"`                 // Hours before checking the mirror for a newer feed (default 24)
+	ContentTrustedKeys        []string               `yaml:"content_trusted_keys,omitempty" mapstructure:"content_trusted_keys"`         // Ed25519 public keys (base64) whose bundles content import accepts
+	ImageCVEWaivers           []ImageCVEWaiver       `yaml:"image_cve_waivers,omitempty" mapstructure:"image_cve_waivers"`               // CVEs accepted per image, reported as waived
+	MaxRSSMB                  int                    `yaml:"max_rss_mb,omitempty" mapstructure:"max_rss_mb"`                             // Restart serve when its RSS stays above this many MB (0 = off)
+	GOGC                      *int                   `yaml:"gogc,omitempty" mapstructure:"gogc"`                                         // Go GC target percentage (default by host memory, -1 = off); GOGC in the environment wins
+	MemoryLimitMB             *int                   `yaml:"memory_limit_mb,omitempty" mapstructure:"memory_limit_mb"`                   // Go soft memory limit (default by host memory, 0 = none); GOMEMLIMIT in the environment wins
+	MaxProcs                  int                    `yaml:"max_procs,omitempty" mapstructure:"max_procs"`                               // Go GOMAXPROCS (default by CPU count); GOMAXPROCS in the environment wins
+	StartupReportWindow       *int                   `yaml:"startup_report_window,omitempty" mapstructure:"startup_report_window"`       // Seconds to spread the initial report over (default 120, 0 = immediate)
+	PayloadEncryptionKey      string                 `yaml:"payload_encryption_key,omitempty" mapstructure:"payload_encryption_key"`     // Server X25519 public key (base64); seals report bodies end to end
+	ObserverMode              bool                   `yaml:"observer_mode,omitempty" mapstructure:"observer_mode"`                       // Collect and report only; refuse mutating server commands
+	FixFilePermissions        bool                   `yaml:"fix_file_permissions,omitempty" mapstructure:"fix_file_permissions"`         // Correct unsafe owners and modes of agent files at serve startup instead of only warning
+	AllowReportNow            *bool                  `yaml:"allow_report_now,omitempty" mapstructure:"allow_report_now"`                 // Server may trigger report_now (default true)
+	AllowComplianceScan       *bool                  `yaml:"allow_compliance_scan,omitempty" mapstructure:"allow_compliance_scan"`       // Server may start compliance scans (default true)
+	AllowRemediation          *bool                  `yaml:"allow_remediation,omitempty" mapstructure:"allow_remediation"`               // Server may remediate compliance rules (default true)
+	AllowAgentUpdate          *bool                  `yaml:"allow_agent_update,omitempty" mapstructure:"allow_agent_update"`             // Server may update the agent (default true)
+	AllowSSHProxy             *bool                  `yaml:"allow_ssh_proxy,omitempty" mapstructure:"allow_ssh_proxy"`                   // Server may open SSH proxy sessions (default true; ssh-proxy-enabled is still required)
+	AllowRDPProxy             *bool                  `yaml:"allow_rdp_proxy,omitempty" mapstructure:"allow_rdp_proxy"`                   // Server may open RDP proxy sessions (default true; rdp-proxy-enabled is still required)
+	AllowDockerActions        *bool                  `yaml:"allow_docker_actions,omitempty" mapstructure:"allow_docker_actions"`         // Server may trigger Docker inventory refreshes and image scans (default true)
+	AllowPackageUpdate        *bool                  `yaml:"allow_package_update,omitempty" mapstructure:"allow_package_update"`         // Server may upgrade packages with package_update other than dry runs (default false)
+	AllowRunPatch             *bool                  `yaml:"allow_run_patch,omitempty" mapstructure:"allow_run_patch"`                   // Server may start patch runs with run_patch other than dry runs (default true)
+	DockerUpdate              *DockerUpdateConfig    `yaml:"docker_update,omitempty" mapstructure:"docker_update"`                       // Containers the server may update with docker_update_container (default none)
+	DockerPrune               *DockerPruneConfig     `yaml:"docker_prune,omitempty" mapstructure:"docker_prune"`                         // Opt-in to docker_prune (default off)
+	Maintenance               *MaintenanceConfig     `yaml:"maintenance,omitempty" mapstructure:"maintenance"`                           // Windows outside which disruptive server commands wait
+	Notifications             *NotificationsConfig   `yaml:"notifications,omitempty" mapstructure:"notifications"`                       // Local webhook / exec notifications
+	TLSCertPaths              []string               `yaml:"tls_cert_paths,omitempty" mapstructure:"tls_cert_paths"`                     // Files or directories the tls-certificates integration scans (default: web server cert dirs)
+	Redaction                 *RedactionConfig       `yaml:"redaction,omitempty" mapstructure:"redaction"`                               // Fields and patterns masked in outgoing payloads
+	Endpoints                 map[string]string      `yaml:"endpoints,omitempty" mapstructure:"endpoints"`                               // Payload type to base URL used instead of patchmon_server
+	AuthHeaders               *AuthHeadersConfig     `yaml:"auth_headers,omitempty" mapstructure:"auth_headers"`                         // Extra headers for reverse proxies in front of the server
+	ClientTLS                 *ClientTLSConfig       `yaml:"client_tls,omitempty" mapstructure:"client_tls"`                             // Client certificate for servers that require mutual TLS
+	RelayURL                  string                 `yaml:"relay_url,omitempty" mapstructure:"relay_url"`                               // Send all server traffic through a patchmon-agent relay: https://host:port or unix:/path.sock
+	Relay                     *RelayConfig           `yaml:"relay,omitempty" mapstructure:"relay"`                                       // Relay listener, or the certificates used to reach relay_url
+	Servers                   []ServerProfile        `yaml:"servers,omitempty" mapstructure:"servers"`                                   // Other servers reported to alongside patchmon_server
+	Tracing                   *TracingConfig         `yaml:"tracing,omitempty" mapstructure:"tracing"`                                   // OpenTelemetry spans exported over OTLP/HTTP
+}
+
+// PackageTransaction is a completed package manager transaction reported by
+// the apt or dnf hook
+type PackageTransaction struct {
+	Manager     string                   `json:"manager"` // apt, dnf
+	StartedAt   *time.Time               `json:"startedAt,omitempty"`
+	CompletedAt time.Time                `json:"completedAt"`
+	Command     string                   `json:"command,omitempty"`
+	Packages    []PackageTransactionItem `json:"packages"`
+}
+
+// PackageTransactionItem is one package changed by a transaction
+type PackageTransactionItem struct {
+	Name        string `json:"name"`
+	Action      string `json:"action"` // install, upgrade, downgrade, reinstall, remove
+	FromVersion string `json:"fromVersion,omitempty"`
+	ToVersion   string `json:"toVersion,omitempty"`
+}
+
+// PackageTransactionPayload is sent to the server for each hook-reported transaction
+type PackageTransactionPayload struct {
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+
+	Transaction  *PackageTransaction `json:"transaction"`
+	Hostname     string              `json:"hostname"`
+	MachineID    string              `json:"machineId"`
+	AgentVersion string              `json:"agentVersion"`
 }
@@ -0,0 +1,46 @@
+package models
+
+// NotificationsConfig configures local notifications the agent sends itself,
+// for sites without an alerting pipeline. Thresholds left at zero use defaults.
+type NotificationsConfig struct {
+	ReportFailureThreshold   int     `yaml:"report_failure_threshold,omitempty" mapstructure:"report_failure_threshold"`     // Consecutive failed reports before report_failed (default 3)
+	ComplianceScoreThreshold float64 `yaml:"compliance_score_threshold,omitempty" mapstructure:"compliance_score_threshold"` // Score (0-100) below which compliance_score_low fires; 0 disables
+	CrashLoopRestarts        int     `yaml:"crash_loop_restarts,omitempty" mapstructure:"crash_loop_restarts"`               // Container exits within crash_loop_window that count as a crash loop (default 3)
+	CrashLoopWindow          int     `yaml:"crash_loop_window,omitempty" mapstructure:"crash_loop_window"`                   // Seconds (default 600)
+
+	Webhooks []WebhookTarget `yaml:"webhooks,omitempty" mapstructure:"webhooks"`
+	Ntfy     []NtfyTarget    `yaml:"ntfy,omitempty" mapstructure:"ntfy"`
+	Gotify   []GotifyTarget  `yaml:"gotify,omitempty" mapstructure:"gotify"`
+	Exec     []ExecTarget    `yaml:"exec,omitempty" mapstructure:"exec"`
+}
+
+// WebhookTarget posts events to a URL
+type WebhookTarget struct {
+	URL     string            `yaml:"url" mapstructure:"url"`
+	Format  string            `yaml:"format,omitempty" mapstructure:"format"` // json (default) or slack
+	Headers map[string]string `yaml:"headers,omitempty" mapstructure:"headers"`
+	Events  []string          `yaml:"events,omitempty" mapstructure:"events"` // Empty means all events
+}
+
+// NtfyTarget publishes events to an ntfy topic
+type NtfyTarget struct {
+	Server   string   `yaml:"server,omitempty" mapstructure:"server"`     // Default https://ntfy.sh
+	Topic    string   `yaml:"topic" mapstructure:"topic"`                 // Required
+	Token    string   `yaml:"token,omitempty" mapstructure:"token"`       // Access token for protected topics
+	Priority int      `yaml:"priority,omitempty" mapstructure:"priority"` // 1-5; unset uses 4 for failures and 3 otherwise
+	Events   []string `yaml:"events,omitempty" mapstructure:"events"`
+}
+
+// GotifyTarget pushes events to a Gotify application
+type GotifyTarget struct {
+	Server   string   `yaml:"server" mapstructure:"server"`               // Gotify base URL, required
+	Token    string   `yaml:"token" mapstructure:"token"`                 // Application token, required
+	Priority int      `yaml:"priority,omitempty" mapstructure:"priority"` // 0-10; unset uses 8 for failures and 5 otherwise
+	Events   []string `yaml:"events,omitempty" mapstructure:"events"`
+}
+
+// ExecTarget runs a local command per event, with the event as JSON on stdin
+type ExecTarget struct {
+	Command []string `yaml:"command" mapstructure:"command"` // argv; no shell is involved
+	Events  []string `yaml:"events,omitempty" mapstructure:"events"`
+}
@@ -0,0 +1,42 @@
+package models
+
+// NspawnMachine is a machine registered with systemd-machined, normally a
+// systemd-nspawn container, reported as a child of the host the way Docker
+// containers are
+type NspawnMachine struct {
+	Name            string    `json:"name"`
+	Class           string    `json:"class"`             // container or vm
+	Service         string    `json:"service,omitempty"` // Registering service, e.g. systemd-nspawn
+	State           string    `json:"state,omitempty"`   // opening, running or closing
+	RootDirectory   string    `json:"root_directory,omitempty"`
+	OSID            string    `json:"os_id,omitempty"` // ID from the machine's os-release
+	OSVersion       string    `json:"os_version,omitempty"`
+	OSPrettyName    string    `json:"os_pretty_name,omitempty"`
+	PackageManager  string    `json:"package_manager,omitempty"`
+	PackageCount    int       `json:"package_count"`
+	Updates         []Package `json:"updates"` // Installed packages with an update available
+	SecurityUpdates int       `json:"security_updates"`
+	Error           string    `json:"error,omitempty"` // Set when packages could not be read inside the machine
+}
+
+// NspawnData is the systemd-nspawn machine inventory for a host
+type NspawnData struct {
+	Machines []NspawnMachine `json:"machines"`
+	Warnings []string        `json:"warnings,omitempty"`
+}
+
+// NspawnPayload is sent to the server with systemd-nspawn machine data
+type NspawnPayload struct {
+	NspawnData
+	SchemaVersion int `json:"schema_version,omitempty"`
+
+	Hostname     string `json:"hostname"`
+	MachineID    string `json:"machine_id"`
+	AgentVersion string `json:"agent_version"`
+}
+
+// NspawnResponse is the server response to a systemd-nspawn machine upload
+type NspawnResponse struct {
+	Message          string `json:"message"`
+	MachinesReceived int    `json:"machines_received"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Outcomes of a package_update command
+const (
+	PackageUpdateSucceeded = "succeeded"
+	PackageUpdateFailed    = "failed"
+)
+
+// PackageUpdateResult is the outcome of a package_update command: the
+// packages it changed, or on a dry run the packages it would change
+type PackageUpdateResult struct {
+	CommandID string   `json:"commandId,omitempty"`
+	Manager   string   `json:"manager"` // apt, dnf, yum, zypper, pkg
+	DryRun    bool     `json:"dryRun"`
+	Requested []string `json:"requested,omitempty"` // Packages the server selected; empty when it asked for every update
+	Status    string   `json:"status"`
+	Error     string   `json:"error,omitempty"`
+	// Packages lists every package installed, upgraded or removed, including
+	// dependencies pulled in by the requested ones
+	Packages    []PackageTransactionItem `json:"packages"`
+	Unchanged   []string                 `json:"unchanged,omitempty"` // Requested packages left as they were
+	Output      string                   `json:"output,omitempty"`    // Package manager output, the end of it when long
+	StartedAt   time.Time                `json:"startedAt"`
+	CompletedAt time.Time                `json:"completedAt"`
+}
+
+// PackageUpdatePayload is sent to the server when a package_update command finishes
+type PackageUpdatePayload struct {
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+
+	Result       *PackageUpdateResult `json:"result"`
+	Hostname     string               `json:"hostname"`
+	MachineID    string               `json:"machineId"`
+	AgentVersion string               `json:"agentVersion"`
+}
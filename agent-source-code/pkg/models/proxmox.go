@@ -0,0 +1,64 @@
+package models
+
+// ProxmoxGuest is a QEMU virtual machine or LXC container on a Proxmox VE
+// node, reported as a child of the host the way Docker containers are
+type ProxmoxGuest struct {
+	VMID          int      `json:"vmid"`
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`   // qemu or lxc
+	Status        string   `json:"status"` // running, stopped or paused
+	Template      bool     `json:"template,omitempty"`
+	CPUs          float64  `json:"cpus"` // Fractional for containers with a CPU limit
+	MemoryBytes   int64    `json:"memory_bytes"`
+	DiskBytes     int64    `json:"disk_bytes"`
+	UptimeSeconds int64    `json:"uptime_seconds,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+	HAState       string   `json:"ha_state,omitempty"` // Set when the guest is managed by the HA stack
+	Lock          string   `json:"lock,omitempty"`     // e.g. backup or migrate
+}
+
+// ProxmoxClusterNode is a member of a Proxmox VE cluster
+type ProxmoxClusterNode struct {
+	Name   string `json:"name"`
+	NodeID int    `json:"node_id"`
+	IP     string `json:"ip,omitempty"`
+	Online bool   `json:"online"`
+	Local  bool   `json:"local"` // The node this agent runs on
+}
+
+// ProxmoxCluster is the corosync cluster a node belongs to
+type ProxmoxCluster struct {
+	Name    string               `json:"name"`
+	Quorate bool                 `json:"quorate"`
+	Version int                  `json:"version"` // Cluster configuration version
+	Nodes   []ProxmoxClusterNode `json:"nodes"`
+}
+
+// ProxmoxData is the Proxmox VE inventory of one node. Guests are the ones
+// on this node; every node of a cluster reports its own.
+type ProxmoxData struct {
+	Node       string          `json:"node"`
+	PVEVersion string          `json:"pve_version"`       // pve-manager version, e.g. 8.2.4
+	Cluster    *ProxmoxCluster `json:"cluster,omitempty"` // Unset on a standalone node
+	Guests     []ProxmoxGuest  `json:"guests"`
+	// Updates are the pending updates from the Proxmox repositories, as
+	// the node's own update view lists them
+	Updates  []Package `json:"updates"`
+	Warnings []string  `json:"warnings,omitempty"`
+}
+
+// ProxmoxPayload is sent to the server with Proxmox VE data
+type ProxmoxPayload struct {
+	ProxmoxData
+	SchemaVersion int `json:"schema_version,omitempty"`
+
+	Hostname     string `json:"hostname"`
+	MachineID    string `json:"machine_id"`
+	AgentVersion string `json:"agent_version"`
+}
+
+// ProxmoxResponse is the server response to a Proxmox VE upload
+type ProxmoxResponse struct {
+	Message        string `json:"message"`
+	GuestsReceived int    `json:"guests_received"`
+}
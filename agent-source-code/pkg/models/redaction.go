@@ -0,0 +1,11 @@
+package models
+
+// RedactionConfig masks data in outgoing payloads before it leaves the host,
+// for servers hosted by a third party under data-minimization rules
+type RedactionConfig struct {
+	Fields      []string `yaml:"fields,omitempty" mapstructure:"fields"`           // JSON field names whose string values are masked wherever they appear; case, "_" and "-" are ignored
+	Patterns    []string `yaml:"patterns,omitempty" mapstructure:"patterns"`       // Regular expressions; matches in any string value are masked
+	IPRanges    []string `yaml:"ip_ranges,omitempty" mapstructure:"ip_ranges"`     // CIDRs; addresses inside them are masked in any string value
+	Replacement string   `yaml:"replacement,omitempty" mapstructure:"replacement"` // Default "[redacted]"
+	Hash        bool     `yaml:"hash,omitempty" mapstructure:"hash"`               // Replace with a stable hash instead, so values can still be told apart
+}
@@ -0,0 +1,14 @@
+package models
+
+// RelayConfig holds the settings for relay mode, where agents on a segmented
+// network reach PatchMon through one agent that can. On the relay (the relay
+// command) the certificate is served to agents and ca_file verifies their
+// client certificates; on an agent with relay_url set the certificate is
+// presented to the relay and ca_file verifies the relay's.
+type RelayConfig struct {
+	Listen   string `yaml:"listen,omitempty" mapstructure:"listen"`       // Relay only: "host:port" (mutual TLS) or "unix:/path.sock"
+	CertFile string `yaml:"cert_file,omitempty" mapstructure:"cert_file"` // PEM certificate
+	KeyFile  string `yaml:"key_file,omitempty" mapstructure:"key_file"`   // PEM private key for cert_file
+	CAFile   string `yaml:"ca_file,omitempty" mapstructure:"ca_file"`     // PEM CA bundle that signed the other side's certificate
+	Group    string `yaml:"group,omitempty" mapstructure:"group"`         // Relay only: group (name or GID) given the unix socket and its directory
+}
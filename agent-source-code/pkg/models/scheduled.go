@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Scheduled task sources
+const (
+	TaskSourceCrontab      = "crontab"       // /etc/crontab
+	TaskSourceCronD        = "cron.d"        // /etc/cron.d/*
+	TaskSourceCronPeriodic = "cron.periodic" // Scripts in /etc/cron.{hourly,daily,weekly,monthly}
+	TaskSourceUserCrontab  = "user-crontab"  // crontab -e files under /var/spool/cron
+	TaskSourceSystemdTimer = "systemd-timer"
+)
+
+// ScheduledTask is a cron job or systemd timer
+type ScheduledTask struct {
+	Source     string     `json:"source"` // crontab, cron.d, cron.periodic, user-crontab, systemd-timer
+	File       string     `json:"file,omitempty"`
+	Unit       string     `json:"unit,omitempty"` // Timer unit, for systemd timers
+	User       string     `json:"user,omitempty"`
+	Schedule   string     `json:"schedule"` // Cron expression, @keyword, "daily" etc. for periodic scripts, or the timer's OnCalendar/monotonic settings
+	Command    string     `json:"command"`
+	Enabled    bool       `json:"enabled"`               // False for disabled timers and non-executable periodic scripts
+	LastRun    *time.Time `json:"last_run,omitempty"`    // systemd timers only
+	NextRun    *time.Time `json:"next_run,omitempty"`    // systemd timers only
+	ModifiedAt *time.Time `json:"modified_at,omitempty"` // mtime of the file defining the task
+}
+
+// ScheduledTasksData is the scheduled task inventory for a host
+type ScheduledTasksData struct {
+	Tasks    []ScheduledTask `json:"tasks"`
+	Warnings []string        `json:"warnings,omitempty"` // Unreadable files, systemctl failures
+}
+
+// ScheduledTasksPayload is sent to the server with scheduled task data
+type ScheduledTasksPayload struct {
+	ScheduledTasksData
+	SchemaVersion int `json:"schema_version,omitempty"`
+
+	Hostname     string `json:"hostname"`
+	MachineID    string `json:"machine_id"`
+	AgentVersion string `json:"agent_version"`
+}
+
+// ScheduledTasksResponse is the server response to a scheduled task upload
+type ScheduledTasksResponse struct {
+	Message       string `json:"message"`
+	TasksReceived int    `json:"tasks_received"`
+}
@@ -0,0 +1,199 @@
+package models
+
+// Payload schema versions. SchemaVersion is the schema this agent produces.
+// A server that only understands an older schema says so in its handshake
+// (ping response or WebSocket "connected" message) and gets payloads rendered
+// down with ForSchema, so agents can be upgraded ahead of the server.
+//
+// Schema history:
+//
+//	1: payloads from before schema versioning. No schema_version field, no ping
+//	   body, and no language package, SBOM, package transaction or hostname
+//	   change endpoints.
+//	2: schema_version on every payload; report sections, refreshedSections,
+//	   warnings, packageCountSuspect and previousHostname; package and
+//	   repository source classification; ping body.
+//
+// New optional fields and new upload endpoints don't need a new schema
+// version: servers ignore fields they don't know, and an upload to an endpoint
+// the server lacks fails on its own and is retried with the next report.
+const (
+	SchemaVersion    = 2
+	MinSchemaVersion = 1
+)
+
+// ClampSchemaVersion maps a version announced by the server to one this agent
+// can render. 0 (not announced) means the server accepts the current schema.
+func ClampSchemaVersion(v int) int {
+	switch {
+	case v <= 0 || v > SchemaVersion:
+		return SchemaVersion
+	case v < MinSchemaVersion:
+		return MinSchemaVersion
+	}
+	return v
+}
+
+// schemaField is the schema_version value written for schema v. Schema 1
+// predates the field, so it is left out.
+func schemaField(v int) int {
+	if v < 2 {
+		return 0
+	}
+	return v
+}
+
+// ForSchema returns a copy of p as schema v. Fields newer than v are cleared,
+// which drops them from the JSON.
+func (p *ReportPayload) ForSchema(v int) *ReportPayload {
+	out := *p
+	out.SchemaVersion = schemaField(v)
+	if v < 2 {
+		out.PackageCountSuspect = false
+		out.Warnings = nil
+		out.PreviousHostname = ""
+		out.Sections = nil
+		out.RefreshedSections = nil
+		out.Packages = make([]Package, len(p.Packages))
+		for i, pkg := range p.Packages {
+			pkg.SourceClassification, pkg.SourceVendor = "", ""
+			out.Packages[i] = pkg
+		}
+		out.Repositories = make([]Repository, len(p.Repositories))
+		for i, repo := range p.Repositories {
+			repo.Classification, repo.Vendor = "", ""
+			out.Repositories[i] = repo
+		}
+	}
+	return &out
+}
+
+// ForSchema returns a copy of p as schema v
+func (p *DockerPayload) ForSchema(v int) *DockerPayload {
+	out := *p
+	out.SchemaVersion = schemaField(v)
+	return &out
+}
+
+// ForSchema returns a copy of p as schema v
+func (p *CompliancePayload) ForSchema(v int) *CompliancePayload {
+	out := *p
+	out.SchemaVersion = schemaField(v)
+	return &out
+}
+
+// ForSchema returns a copy of p as schema v
+func (p *LanguagePackagesPayload) ForSchema(v int) *LanguagePackagesPayload {
+	out := *p
+	out.SchemaVersion = schemaField(v)
+	return &out
+}
+
+// ForSchema returns a copy of p as schema v
+func (p *PackageTransactionPayload) ForSchema(v int) *PackageTransactionPayload {
+	out := *p
+	out.SchemaVersion = schemaField(v)
+	return &out
+}
+
+// ForSchema returns a copy of p as schema v
+func (p *PackageUpdatePayload) ForSchema(v int) *PackageUpdatePayload {
+	out := *p
+	out.SchemaVersion = schemaField(v)
+	return &out
+}
+
+// ForSchema returns a copy of e as schema v
+func (e *HostnameChangeEvent) ForSchema(v int) *HostnameChangeEvent {
+	out := *e
+	out.SchemaVersion = schemaField(v)
+	return &out
+}
+
+// ForSchema returns a copy of i as schema v
+func (i *ImageSBOMInfo) ForSchema(v int) *ImageSBOMInfo {
+	out := *i
+	out.SchemaVersion = schemaField(v)
+	return &out
+}
+
+// ForSchema returns a copy of r as schema v. Schema 1 pings have no body, so
+// it returns nil.
+func (r *PingRequest) ForSchema(v int) *PingRequest {
+	if v < 2 {
+		return nil
+	}
+	out := *r
+	out.SchemaVersion = schemaField(v)
+	return &out
+}
+
+// ForSchema returns a copy of p as schema v
+func (p *UserAccountsPayload) ForSchema(v int) *UserAccountsPayload {
+	out := *p
+	out.SchemaVersion = schemaField(v)
+	return &out
+}
+
+// ForSchema returns a copy of p as schema v
+func (p *TLSCertificatesPayload) ForSchema(v int) *TLSCertificatesPayload {
+	out := *p
+	out.SchemaVersion = schemaField(v)
+	return &out
+}
+
+// ForSchema returns a copy of p as schema v
+func (p *ScheduledTasksPayload) ForSchema(v int) *ScheduledTasksPayload {
+	out := *p
+	out.SchemaVersion = schemaField(v)
+	return &out
+}
+
+// ForSchema returns a copy of p as schema v
+func (p *JailsPayload) ForSchema(v int) *JailsPayload {
+	out := *p
+	out.SchemaVersion = schemaField(v)
+	return &out
+}
+
+// ForSchema returns a copy of p as schema v
+func (p *NspawnPayload) ForSchema(v int) *NspawnPayload {
+	out := *p
+	out.SchemaVersion = schemaField(v)
+	return &out
+}
+
+// ForSchema returns a copy of p as schema v
+func (p *HardwareInventoryPayload) ForSchema(v int) *HardwareInventoryPayload {
+	out := *p
+	out.SchemaVersion = schemaField(v)
+	return &out
+}
+
+// ForSchema returns a copy of p as schema v
+func (p *ProxmoxPayload) ForSchema(v int) *ProxmoxPayload {
+	out := *p
+	out.SchemaVersion = schemaField(v)
+	return &out
+}
+
+// ForSchema returns a copy of p as schema v
+func (p *DockerContainerUpdatePayload) ForSchema(v int) *DockerContainerUpdatePayload {
+	out := *p
+	out.SchemaVersion = schemaField(v)
+	return &out
+}
+
+// ForSchema returns a copy of p as schema v
+func (p *DockerPrunePayload) ForSchema(v int) *DockerPrunePayload {
+	out := *p
+	out.SchemaVersion = schemaField(v)
+	return &out
+}
+
+// ForSchema returns a copy of p as schema v
+func (p *KubernetesPayload) ForSchema(v int) *KubernetesPayload {
+	out := *p
+	out.SchemaVersion = schemaField(v)
+	return &out
+}
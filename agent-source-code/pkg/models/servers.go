@@ -0,0 +1,14 @@
+package models
+
+// ServerProfile is another PatchMon server the agent reports to alongside
+// patchmon_server, such as an MSP's central instance. Each has its own
+// credentials and WebSocket; it only receives the payload types it lists and
+// may only send the commands it lists.
+type ServerProfile struct {
+	Name            string   `yaml:"name" mapstructure:"name"`                                               // Shown in logs and refusals; must be unique
+	Server          string   `yaml:"patchmon_server" mapstructure:"patchmon_server"`                         // Base URL, like patchmon_server
+	CredentialsFile string   `yaml:"credentials_file" mapstructure:"credentials_file"`                       // credentials.yml with this server's api_id and api_key
+	Payloads        []string `yaml:"payloads,omitempty" mapstructure:"payloads"`                             // Payload types sent, named as in endpoints (default all)
+	AllowCommands   []string `yaml:"allow_commands,omitempty" mapstructure:"allow_commands"`                 // Server commands accepted from it (default none)
+	EncryptionKey   string   `yaml:"payload_encryption_key,omitempty" mapstructure:"payload_encryption_key"` // This server's key, like payload_encryption_key
+}
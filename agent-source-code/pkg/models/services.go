@@ -0,0 +1,15 @@
+package models
+
+// KeyService is a web server, database or cache found on the host, with the
+// version application owners care about and whether its package has updates
+// pending
+type KeyService struct {
+	Name             string `json:"name"` // nginx, apache, postgresql, mysql, mariadb, redis
+	Version          string `json:"version,omitempty"`
+	Running          bool   `json:"running"`
+	Package          string `json:"package,omitempty"` // Empty when only a running binary was found
+	AvailableVersion string `json:"availableVersion,omitempty"`
+	NeedsUpdate      bool   `json:"needsUpdate"`
+	SecurityUpdate   bool   `json:"securityUpdate"`
+	Source           string `json:"source"` // "package", or "binary" when installed outside the package manager
+}
@@ -0,0 +1,22 @@
+package models
+
+// SimulatedHostsRequest asks the server to enroll fake hosts for the simulate
+// command. Servers refuse it with ERR_SIMULATION_DISABLED unless simulated
+// host enrollment has been switched on there.
+type SimulatedHostsRequest struct {
+	Hostnames []string `json:"hostnames"`
+}
+
+// SimulatedHostsResponse carries the credentials of the enrolled fake hosts.
+// The server flags these hosts as simulated so they can be told apart from
+// real ones and removed together.
+type SimulatedHostsResponse struct {
+	Hosts []SimulatedHost `json:"hosts"`
+}
+
+// SimulatedHost is one fake host and the credentials it reports with
+type SimulatedHost struct {
+	Hostname string `json:"hostname"`
+	APIID    string `json:"apiId"`
+	APIKey   string `json:"apiKey"`
+}
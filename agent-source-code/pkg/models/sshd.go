@@ -0,0 +1,26 @@
+package models
+
+// SSHDConfig summarises the effective OpenSSH server configuration, as printed
+// by `sshd -T` after defaults, includes and drop-ins are applied. Match blocks
+// are not evaluated, so this is what a connection matching none of them gets.
+type SSHDConfig struct {
+	Version                      string   `json:"version,omitempty"` // e.g. "OpenSSH_9.6p1"
+	Ports                        []int    `json:"ports"`
+	ListenAddresses              []string `json:"listenAddresses,omitempty"`
+	PermitRootLogin              string   `json:"permitRootLogin"` // yes, no, prohibit-password, forced-commands-only
+	PasswordAuthentication       bool     `json:"passwordAuthentication"`
+	KbdInteractiveAuthentication bool     `json:"kbdInteractiveAuthentication"`
+	PubkeyAuthentication         bool     `json:"pubkeyAuthentication"`
+	PermitEmptyPasswords         bool     `json:"permitEmptyPasswords"`
+	X11Forwarding                bool     `json:"x11Forwarding"`
+	AllowTCPForwarding           string   `json:"allowTcpForwarding,omitempty"`
+	MaxAuthTries                 int      `json:"maxAuthTries"`
+	LoginGraceTime               int      `json:"loginGraceTime"` // Seconds
+	AllowUsers                   []string `json:"allowUsers,omitempty"`
+	AllowGroups                  []string `json:"allowGroups,omitempty"`
+	Ciphers                      []string `json:"ciphers"`
+	MACs                         []string `json:"macs"`
+	KexAlgorithms                []string `json:"kexAlgorithms"`
+	HostKeyAlgorithms            []string `json:"hostKeyAlgorithms,omitempty"`
+	WeakAlgorithms               []string `json:"weakAlgorithms,omitempty"` // Enabled ciphers, MACs and key exchanges considered weak
+}
@@ -0,0 +1,30 @@
+package models
+
+// Subscription providers
+const (
+	SubscriptionRHSM        = "rhsm"
+	SubscriptionSUSEConnect = "suseconnect"
+	SubscriptionUbuntuPro   = "ubuntu-pro"
+)
+
+// SubscriptionStatus is the host's vendor subscription. On an unentitled RHEL
+// or SLES host the update repositories are unreachable, so an empty update
+// list means nothing; the server shows this instead of "up to date".
+type SubscriptionStatus struct {
+	Provider   string `json:"provider"` // rhsm, suseconnect, ubuntu-pro
+	Registered bool   `json:"registered"`
+	// Entitled is true when the subscription gives access to the
+	// entitlement-gated repositories
+	Entitled bool   `json:"entitled"`
+	Status   string `json:"status,omitempty"` // As the provider words it, e.g. "Current", "Registered", "attached"
+	// Products lists the registered products or enabled Ubuntu Pro services
+	Products []SubscriptionProduct `json:"products,omitempty"`
+	Error    string                `json:"error,omitempty"` // Set when the provider's tool failed
+}
+
+// SubscriptionProduct is one registered product or Ubuntu Pro service
+type SubscriptionProduct struct {
+	Name      string `json:"name"`
+	Status    string `json:"status,omitempty"`
+	ExpiresAt string `json:"expiresAt,omitempty"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// TracingConfig sends OpenTelemetry spans for reports, compliance scans and
+// Docker collection to an OTLP/HTTP collector
+type TracingConfig struct {
+	Endpoint    string            `yaml:"endpoint" mapstructure:"endpoint"`                   // Collector base URL, e.g. http://otel-collector:4318; https uses TLS
+	Headers     map[string]string `yaml:"headers,omitempty" mapstructure:"headers"`           // Sent with every export, e.g. an API key
+	SampleRatio *float64          `yaml:"sample_ratio,omitempty" mapstructure:"sample_ratio"` // Fraction of traces kept (default 1)
+	Timeout     int               `yaml:"timeout,omitempty" mapstructure:"timeout"`           // Seconds allowed per export (default 10)
+}